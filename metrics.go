@@ -0,0 +1,132 @@
+package sqs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/sqs/v4/sqsjobs"
+)
+
+const namespace string = "rr_sqs"
+
+// MetricsCollector implements the metrics plugin StatProvider, the metrics of every pipeline are labeled by the pipeline and the queue
+func (p *Plugin) MetricsCollector() []prometheus.Collector {
+	return []prometheus.Collector{newStatsExporter(p)}
+}
+
+// statsExporter collects the sqsjobs.Metrics snapshot of the registered pipelines on every scrape
+type statsExporter struct {
+	p *Plugin
+
+	pushed     *prometheus.Desc
+	received   *prometheus.Desc
+	acked      *prometheus.Desc
+	nacked     *prometheus.Desc
+	requeued   *prometheus.Desc
+	extensions *prometheus.Desc
+	buffered   *prometheus.Desc
+	capacity   *prometheus.Desc
+	inFlight   *prometheus.Desc
+	apiLatency *prometheus.Desc
+	apiErrors  *prometheus.Desc
+
+	receiveLatency *prometheus.Desc
+	messageAge     *prometheus.Desc
+	payloadSize    *prometheus.Desc
+}
+
+func newStatsExporter(p *Plugin) *statsExporter {
+	labels := []string{"pipeline", "queue"}
+	desc := func(name, help string, extra ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, append(labels, extra...), nil)
+	}
+
+	return &statsExporter{
+		p:          p,
+		pushed:     desc("messages_pushed_total", "Total number of the messages pushed to the queue."),
+		received:   desc("messages_received_total", "Total number of the messages received from the queue."),
+		acked:      desc("messages_acked_total", "Total number of the acknowledged messages."),
+		nacked:     desc("messages_nacked_total", "Total number of the negatively acknowledged messages."),
+		requeued:   desc("messages_requeued_total", "Total number of the requeued messages."),
+		extensions: desc("visibility_extensions_total", "Total number of the visibility timeout extensions."),
+		buffered:   desc("prefetch_buffered", "Number of the received messages waiting in the prefetch buffer."),
+		capacity:   desc("prefetch_capacity", "Size of the prefetch buffer."),
+		inFlight:   desc("messages_in_flight", "Number of the messages being processed."),
+		apiLatency: desc("api_call_duration_seconds", "Latency of the SQS API calls, including the long poll wait.", "operation"),
+		apiErrors:  desc("api_errors_total", "Total number of the failed SQS API calls.", "operation", "code"),
+
+		receiveLatency: desc("receive_duration_seconds", "Latency of the ReceiveMessage calls of the poll loop, including the long poll wait."),
+		messageAge:     desc("message_age_seconds", "Age of the dispatched messages, from the SentTimestamp to the dispatch."),
+		payloadSize:    desc("payload_size_bytes", "Size of the sent messages, including the attributes."),
+	}
+}
+
+func (e *statsExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.pushed
+	ch <- e.received
+	ch <- e.acked
+	ch <- e.nacked
+	ch <- e.requeued
+	ch <- e.extensions
+	ch <- e.buffered
+	ch <- e.capacity
+	ch <- e.inFlight
+	ch <- e.apiLatency
+	ch <- e.apiErrors
+	ch <- e.receiveLatency
+	ch <- e.messageAge
+	ch <- e.payloadSize
+}
+
+func (e *statsExporter) Collect(ch chan<- prometheus.Metric) {
+	e.p.mu.RLock()
+	defer e.p.mu.RUnlock()
+
+	for _, d := range e.p.drivers {
+		// destroyed, unregistered on the next status check
+		if d.drv.Stopped() {
+			continue
+		}
+		m := d.drv.Metrics()
+
+		counter := func(desc *prometheus.Desc, v uint64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), m.Pipeline, m.Queue)
+		}
+		gauge := func(desc *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, m.Pipeline, m.Queue)
+		}
+
+		counter(e.pushed, m.Pushed)
+		counter(e.received, m.Received)
+		counter(e.acked, m.Acked)
+		counter(e.nacked, m.Nacked)
+		counter(e.requeued, m.Requeued)
+		counter(e.extensions, m.VisibilityExtensions)
+		gauge(e.buffered, float64(m.PrefetchBuffered))
+		gauge(e.capacity, float64(m.PrefetchCapacity))
+		gauge(e.inFlight, float64(m.InFlight))
+
+		for op, h := range m.APILatency {
+			ch <- histogram(e.apiLatency, h, m.Pipeline, m.Queue, op)
+		}
+
+		// nil if disabled
+		for desc, h := range map[*prometheus.Desc]*sqsjobs.Histogram{e.receiveLatency: m.ReceiveLatency, e.messageAge: m.MessageAge, e.payloadSize: m.PayloadSize} {
+			if h != nil {
+				ch <- histogram(desc, h, m.Pipeline, m.Queue)
+			}
+		}
+
+		for key, n := range m.APIErrors {
+			ch <- prometheus.MustNewConstMetric(e.apiErrors, prometheus.CounterValue, float64(n), m.Pipeline, m.Queue, key.Operation, key.Code)
+		}
+	}
+}
+
+// histogram converts the snapshot, the snapshot counts are cumulative, the +Inf bucket is the total count
+func histogram(desc *prometheus.Desc, h *sqsjobs.Histogram, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, upper := range h.Buckets {
+		buckets[upper] = h.Counts[i]
+	}
+
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum, buckets, labels...)
+}
@@ -1,6 +1,14 @@
 package sqs
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/roadrunner-server/api/v4/plugins/v1/status"
 	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
 	"github.com/roadrunner-server/endure/v2/dep"
 	"github.com/roadrunner-server/errors"
@@ -19,6 +27,38 @@ type Plugin struct {
 
 	log *zap.Logger
 	cfg Configurer
+
+	mu      sync.RWMutex
+	drivers map[string]*driver
+
+	reloadOnSighup bool
+	sighup         chan os.Signal
+	// report the pipelines readiness to the status plugin
+	readinessCheck bool
+	// the plugins subscribed to the pipeline events
+	listeners []EventListener
+	// the store of the offloaded message bodies (s3_bucket), nil if no provider was collected
+	payloadStore sqsjobs.PayloadStore
+	// the compression codecs by the Content-Encoding name (e.g. zstd)
+	codecs map[string]sqsjobs.Codec
+}
+
+// driver is the registered pipeline driver, configKey is empty for the pipelines created from the jobs RPC
+type driver struct {
+	drv       *sqsjobs.Driver
+	configKey string
+}
+
+// global options
+type pluginConfig struct {
+	// ReloadOnSighup re-reads the pipelines configuration on SIGHUP (and on rr reset) and applies the settings which
+	// support live change. The configuration source is re-read only if the configurer supports it, otherwise
+	// the configuration loaded on start (or overwritten at runtime) is applied.
+	ReloadOnSighup bool `mapstructure:"reload_on_sighup"`
+	// ReadinessCheck reports the plugin not ready (503 on the status plugin readiness endpoint) until every pipeline
+	// resolved its queue and the first receive succeeded, so the traffic is not routed to the instance which can't reach SQS.
+	// The reasons are logged and returned by the Stats RPC (not_ready_reason).
+	ReadinessCheck bool `mapstructure:"readiness_check"`
 }
 
 type Configurer interface {
@@ -28,6 +68,13 @@ type Configurer interface {
 	Has(name string) bool
 }
 
+// configReader is implemented by the configurers which can re-read their source (e.g. the viper based ones).
+// The RoadRunner config plugin holds the configuration read on start, so without it the reload applies the values
+// the configurer holds at the moment (e.g. changed with the config Overwrite).
+type configReader interface {
+	ReadInConfig() error
+}
+
 type Tracer interface {
 	Tracer() *sdktrace.TracerProvider
 }
@@ -36,6 +83,24 @@ type Logger interface {
 	NamedLogger(name string) *zap.Logger
 }
 
+// EventListener is implemented by the plugins consuming the lifecycle and message events of the sqs pipelines,
+// e.g. the metrics or the audit log. The events are delivered asynchronously, see sqsjobs.EventListener.
+type EventListener interface {
+	SQSEvent(ev sqsjobs.Event)
+}
+
+// PayloadStoreProvider is implemented by the plugin providing the store of the offloaded message bodies (s3_bucket),
+// e.g. an S3 client. The store is registered on every sqs pipeline, the pipelines with the s3_bucket fail to start without it.
+type PayloadStoreProvider interface {
+	SQSPayloadStore() sqsjobs.PayloadStore
+}
+
+// CodecProvider is implemented by the plugins providing the compression codecs by the Content-Encoding name (e.g. zstd),
+// the codecs are registered on every sqs pipeline
+type CodecProvider interface {
+	SQSCodecs() map[string]sqsjobs.Codec
+}
+
 func (p *Plugin) Init(log Logger, cfg Configurer) error {
 	// if there is no sqs section and no job section -> disable
 	if !cfg.Has(pluginName) && !cfg.Has(masterPluginName) {
@@ -44,6 +109,46 @@ func (p *Plugin) Init(log Logger, cfg Configurer) error {
 
 	p.log = log.NamedLogger(pluginName)
 	p.cfg = cfg
+	p.drivers = make(map[string]*driver)
+
+	if cfg.Has(pluginName) {
+		var pc pluginConfig
+		err := cfg.UnmarshalKey(pluginName, &pc)
+		if err != nil {
+			return errors.E(errors.Op("sqs_plugin_init"), err)
+		}
+		p.reloadOnSighup = pc.ReloadOnSighup
+		p.readinessCheck = pc.ReadinessCheck
+	}
+
+	return nil
+}
+
+func (p *Plugin) Serve() chan error {
+	errCh := make(chan error, 1)
+	if !p.reloadOnSighup {
+		return errCh
+	}
+
+	p.sighup = make(chan os.Signal, 1)
+	signal.Notify(p.sighup, syscall.SIGHUP)
+
+	go func() {
+		for range p.sighup {
+			p.reload()
+		}
+	}()
+
+	return errCh
+}
+
+func (p *Plugin) Stop(context.Context) error {
+	if p.sighup != nil {
+		signal.Stop(p.sighup)
+		close(p.sighup)
+		p.sighup = nil
+	}
+
 	return nil
 }
 
@@ -51,18 +156,182 @@ func (p *Plugin) Name() string {
 	return pluginName
 }
 
+// Reset implements the resetter plugin hook (rr reset sqs), the pipelines are reloaded as on SIGHUP
+func (p *Plugin) Reset() error {
+	p.reload()
+	return nil
+}
+
+// Ready implements the status plugin readiness check, always ready if the readiness_check is disabled
+func (p *Plugin) Ready() (*status.Status, error) {
+	if !p.readinessCheck {
+		return &status.Status{Code: http.StatusOK}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune()
+
+	code := http.StatusOK
+	for name, d := range p.drivers {
+		if ok, reason := d.drv.Ready(); !ok {
+			p.log.Debug("pipeline is not ready", zap.String("pipeline", name), zap.String("reason", reason))
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	return &status.Status{Code: code}, nil
+}
+
+// Status implements the status plugin health check: unavailable if the health check of any pipeline fails,
+// the degraded pipelines (throttling or auth errors) are logged and reported via the Stats RPC
+func (p *Plugin) Status() (*status.Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune()
+
+	code := http.StatusOK
+	for name, d := range p.drivers {
+		switch state, reason := d.drv.Health(); state {
+		case sqsjobs.HealthUnavailable:
+			p.log.Warn("pipeline is unavailable", zap.String("pipeline", name), zap.String("reason", reason))
+			code = http.StatusServiceUnavailable
+		case sqsjobs.HealthDegraded:
+			p.log.Debug("pipeline is degraded", zap.String("pipeline", name), zap.String("reason", reason))
+		}
+	}
+
+	return &status.Status{Code: code}, nil
+}
+
 func (p *Plugin) Collects() []*dep.In {
 	return []*dep.In{
 		dep.Fits(func(pp any) {
 			p.tracer = pp.(Tracer).Tracer()
 		}, (*Tracer)(nil)),
+		dep.Fits(func(pp any) {
+			l := pp.(EventListener)
+			p.mu.Lock()
+			p.listeners = append(p.listeners, l)
+			// the pipelines created before the listener was collected
+			for _, d := range p.drivers {
+				d.drv.RegisterEventListener(l.SQSEvent)
+			}
+			p.mu.Unlock()
+		}, (*EventListener)(nil)),
+		dep.Fits(func(pp any) {
+			s := pp.(PayloadStoreProvider).SQSPayloadStore()
+			p.mu.Lock()
+			p.payloadStore = s
+			for _, d := range p.drivers {
+				d.drv.RegisterPayloadStore(s)
+			}
+			p.mu.Unlock()
+		}, (*PayloadStoreProvider)(nil)),
+		dep.Fits(func(pp any) {
+			codecs := pp.(CodecProvider).SQSCodecs()
+			p.mu.Lock()
+			if p.codecs == nil {
+				p.codecs = make(map[string]sqsjobs.Codec, len(codecs))
+			}
+			for name, codec := range codecs {
+				p.codecs[name] = codec
+				for _, d := range p.drivers {
+					d.drv.RegisterCodec(name, codec)
+				}
+			}
+			p.mu.Unlock()
+		}, (*CodecProvider)(nil)),
 	}
 }
 
+// RPC returns the plugin RPC methods
+func (p *Plugin) RPC() any {
+	return &rpc{p: p}
+}
+
 func (p *Plugin) DriverFromConfig(configKey string, pq jobs.Queue, pipeline jobs.Pipeline, _ chan<- jobs.Commander) (jobs.Driver, error) {
-	return sqsjobs.FromConfig(p.tracer, configKey, pipeline, p.log, p.cfg, pq)
+	drv, err := sqsjobs.FromConfig(p.tracer, configKey, pipeline, p.log, p.cfg, pq)
+	if err != nil {
+		return nil, err
+	}
+
+	p.register(pipeline.Name(), configKey, drv)
+	return drv, nil
 }
 
 func (p *Plugin) DriverFromPipeline(pipe jobs.Pipeline, pq jobs.Queue, _ chan<- jobs.Commander) (jobs.Driver, error) {
-	return sqsjobs.FromPipeline(p.tracer, pipe, p.log, p.cfg, pq)
+	drv, err := sqsjobs.FromPipeline(p.tracer, pipe, p.log, p.cfg, pq)
+	if err != nil {
+		return nil, err
+	}
+
+	p.register(pipe.Name(), "", drv)
+	return drv, nil
+}
+
+func (p *Plugin) register(pipeline, configKey string, drv *sqsjobs.Driver) {
+	p.mu.Lock()
+	p.prune()
+	p.drivers[pipeline] = &driver{drv: drv, configKey: configKey}
+	for _, l := range p.listeners {
+		drv.RegisterEventListener(l.SQSEvent)
+	}
+	if p.payloadStore != nil {
+		drv.RegisterPayloadStore(p.payloadStore)
+	}
+	for name, codec := range p.codecs {
+		drv.RegisterCodec(name, codec)
+	}
+	p.mu.Unlock()
+}
+
+func (p *Plugin) driver(pipeline string) (*sqsjobs.Driver, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	d, ok := p.drivers[pipeline]
+	if !ok || d.drv.Stopped() {
+		return nil, false
+	}
+	return d.drv, true
+}
+
+// prune unregisters the stopped (destroyed) pipelines, requires the write lock
+func (p *Plugin) prune() {
+	for name, d := range p.drivers {
+		if d.drv.Stopped() {
+			delete(p.drivers, name)
+		}
+	}
+}
+
+// reload re-reads the configuration of the pipelines declared in the config and reconfigures them
+func (p *Plugin) reload() {
+	if r, ok := p.cfg.(configReader); ok {
+		err := r.ReadInConfig()
+		if err != nil {
+			p.log.Error("failed to re-read the configuration, reload skipped", zap.Error(err))
+			return
+		}
+	} else {
+		p.log.Debug("configurer can't re-read the configuration source, the loaded configuration is applied")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for name, d := range p.drivers {
+		if d.configKey == "" {
+			p.log.Debug("pipeline was created dynamically, reload skipped", zap.String("pipeline", name))
+			continue
+		}
+
+		conf, err := sqsjobs.ReadConfig(d.configKey, p.cfg)
+		if err != nil {
+			p.log.Error("failed to reload the pipeline configuration", zap.String("pipeline", name), zap.Error(err))
+			continue
+		}
+
+		d.drv.Reconfigure(conf)
+	}
 }
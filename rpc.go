@@ -0,0 +1,167 @@
+package sqs
+
+import (
+	"context"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/sqs/v4/sqsjobs"
+)
+
+const (
+	// defaultDrainTimeout is the DrainPipeline timeout if not set
+	defaultDrainTimeout = time.Minute * 5
+	// maxDrainTimeout caps the DrainPipeline timeout, the RPC call never blocks forever
+	maxDrainTimeout = time.Hour
+)
+
+type rpc struct {
+	p *Plugin
+}
+
+// DrainRequest is the DrainPipeline RPC request
+type DrainRequest struct {
+	// Pipeline name
+	Pipeline string `json:"pipeline"`
+	// Timeout in seconds to wait for the in-flight messages, up to 3600. Default (0): 300
+	Timeout int `json:"timeout"`
+}
+
+// DrainResponse is the DrainPipeline RPC response
+type DrainResponse struct {
+	// InFlight is the number of messages still in flight after the drain
+	InFlight int64 `json:"in_flight"`
+	// Drained is true if all in-flight messages were processed before the timeout
+	Drained bool `json:"drained"`
+}
+
+// DrainPipeline stops receiving new messages for the pipeline and waits for the in-flight messages to be processed.
+// Other pipelines are not affected.
+func (r *rpc) DrainPipeline(in *DrainRequest, out *DrainResponse) error {
+	const op = errors.Op("sqs_drain_pipeline")
+
+	drv, ok := r.p.driver(in.Pipeline)
+	if !ok {
+		return errors.E(op, errors.Errorf("no such pipeline: %s", in.Pipeline))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout(in.Timeout))
+	defer cancel()
+
+	inFlight, err := drv.Drain(ctx)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	out.InFlight = inFlight
+	out.Drained = inFlight == 0
+
+	return nil
+}
+
+// drainTimeout returns the DrainPipeline timeout, the default one if not set and capped by the maxDrainTimeout
+func drainTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultDrainTimeout
+	}
+
+	return min(time.Duration(seconds)*time.Second, maxDrainTimeout)
+}
+
+// IdleRequest is the IdleState RPC request
+type IdleRequest struct {
+	// Pipeline name
+	Pipeline string `json:"pipeline"`
+}
+
+// IdleResponse is the IdleState RPC response
+type IdleResponse struct {
+	// IdleSince is the unix time (in seconds) of the last received message or the pipeline start
+	IdleSince int64 `json:"idle_since"`
+	// Idle is true if no messages were received for the scale_to_zero_idle duration
+	Idle bool `json:"idle"`
+}
+
+// IdleState returns the idle state of the pipeline, used by the external autoscalers to scale the workers to zero
+func (r *rpc) IdleState(in *IdleRequest, out *IdleResponse) error {
+	const op = errors.Op("sqs_idle_state")
+
+	drv, ok := r.p.driver(in.Pipeline)
+	if !ok {
+		return errors.E(op, errors.Errorf("no such pipeline: %s", in.Pipeline))
+	}
+
+	since, idle := drv.IdleSince()
+	out.IdleSince = since.Unix()
+	out.Idle = idle
+
+	return nil
+}
+
+// StatsRequest is the Stats RPC request
+type StatsRequest struct {
+	// Pipeline name
+	Pipeline string `json:"pipeline"`
+}
+
+// Stats returns the pipeline state with the sqs specific values, e.g. the dead-letter queue depth
+func (r *rpc) Stats(in *StatsRequest, out *sqsjobs.Stats) error {
+	const op = errors.Op("sqs_stats")
+
+	drv, ok := r.p.driver(in.Pipeline)
+	if !ok {
+		return errors.E(op, errors.Errorf("no such pipeline: %s", in.Pipeline))
+	}
+
+	st, err := drv.Stats(context.Background())
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	*out = *st
+
+	return nil
+}
+
+// RedriveRequest is the Redrive RPC request
+type RedriveRequest struct {
+	// Pipeline name
+	Pipeline string `json:"pipeline"`
+	// Action is one of: start, pause, resume, stop. Empty - the progress is returned only
+	Action string `json:"action"`
+	// Rate overrides the redrive_rate on start, messages per second
+	Rate int `json:"rate"`
+}
+
+// Redrive moves the messages from the dead-letter queue back to the pipeline queue, paced by the redrive_rate.
+// Returns the progress of the redrive after the action.
+func (r *rpc) Redrive(in *RedriveRequest, out *sqsjobs.RedriveProgress) error {
+	const op = errors.Op("sqs_redrive")
+
+	drv, ok := r.p.driver(in.Pipeline)
+	if !ok {
+		return errors.E(op, errors.Errorf("no such pipeline: %s", in.Pipeline))
+	}
+
+	var err error
+	switch in.Action {
+	case "start":
+		err = drv.Redrive(in.Rate)
+	case "pause":
+		err = drv.PauseRedrive()
+	case "resume":
+		err = drv.ResumeRedrive()
+	case "stop":
+		drv.StopRedrive()
+	case "":
+	default:
+		err = errors.Errorf("unknown redrive action: %s", in.Action)
+	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	*out = drv.RedriveProgress()
+
+	return nil
+}
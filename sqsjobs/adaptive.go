@@ -0,0 +1,150 @@
+package sqsjobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// number of the successful receives (across all pollers) to add a poller
+	adaptiveIncreaseAfter int = 10
+	// the throttled calls of the concurrent pollers are counted as a single decrease within the cooldown
+	adaptiveCooldown = time.Second
+)
+
+// adaptivePollers adjusts the number of the pollers AIMD-style: the number is halved on the SQS throttling
+// and increased by one after the series of the successful receives, within the min/max bounds
+type adaptivePollers struct {
+	mu  sync.Mutex
+	min int
+	max int
+
+	successes    int
+	lastDecrease time.Time
+}
+
+// newAdaptivePollers validates the bounds, nil if the adaptive concurrency is disabled (adaptive_max_pollers <= 0)
+func newAdaptivePollers(lo, hi int) (*adaptivePollers, error) {
+	if hi <= 0 {
+		return nil, nil
+	}
+
+	if lo <= 0 {
+		lo = 1
+	}
+
+	if hi > maxPollers {
+		return nil, errors.Errorf("adaptive_max_pollers should be in the range 1-%d, provided: %d", maxPollers, hi)
+	}
+
+	if lo > hi {
+		return nil, errors.Errorf("adaptive_min_pollers (%d) should not be greater than adaptive_max_pollers (%d)", lo, hi)
+	}
+
+	return &adaptivePollers{min: lo, max: hi}, nil
+}
+
+// clamp returns the number of the pollers within the bounds, n as is if disabled
+func (a *adaptivePollers) clamp(n int) int {
+	if a == nil {
+		return n
+	}
+
+	return min(max(n, a.min), a.max)
+}
+
+// throttleRetryer wraps the client's retryer and reports the throttled attempts, the retry decision is left to the client's retryer
+type throttleRetryer struct {
+	aws.Retryer
+	onThrottle func()
+}
+
+func (t *throttleRetryer) IsErrorRetryable(err error) bool {
+	if isThrottled(err) {
+		t.onThrottle()
+	}
+
+	return t.Retryer.IsErrorRetryable(err)
+}
+
+// withThrottleObserver is a per-operation option which reports the throttled attempts retried by the SDK
+// no-op if the adaptive concurrency is disabled
+func (c *Driver) withThrottleObserver() func(*sqs.Options) {
+	if c.adaptive == nil {
+		return func(_ *sqs.Options) {}
+	}
+
+	return func(o *sqs.Options) {
+		if o.Retryer == nil {
+			return
+		}
+
+		o.Retryer = &throttleRetryer{
+			Retryer:    o.Retryer,
+			onThrottle: c.throttled,
+		}
+	}
+}
+
+// throttled halves the number of the pollers
+func (c *Driver) throttled() {
+	a := c.adaptive
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successes = 0
+	if time.Since(a.lastDecrease) < adaptiveCooldown {
+		return
+	}
+	a.lastDecrease = time.Now()
+
+	cur := c.pollersNumber()
+	next := a.clamp(cur / 2)
+	if next >= cur {
+		return
+	}
+
+	c.log.Warn("SQS throttling detected, reducing the number of pollers", zap.Int("previous", cur), zap.Int("current", next))
+	c.setPollers(next)
+}
+
+// received adds a poller after the series of the successful receives
+func (c *Driver) received() {
+	a := c.adaptive
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successes++
+	if a.successes < adaptiveIncreaseAfter {
+		return
+	}
+	a.successes = 0
+
+	cur := c.pollersNumber()
+	if cur >= a.max {
+		return
+	}
+
+	c.log.Debug("no SQS throttling, increasing the number of pollers", zap.Int("previous", cur), zap.Int("current", cur+1))
+	c.setPollers(cur + 1)
+}
+
+func (c *Driver) pollersNumber() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pollers
+}
@@ -0,0 +1,93 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptivePollersThrottling(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	var throttle atomic.Bool
+	throttle.Store(true)
+	fc := newFakeClient()
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+		}
+		if throttle.Load() {
+			return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+		}
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	c.client = fc
+
+	var err error
+	c.adaptive, err = newAdaptivePollers(2, 8)
+	require.NoError(t, err)
+	c.pollers = 8
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	defer func() {
+		_ = c.Stop(context.Background())
+	}()
+
+	// halved once within the cooldown
+	require.Eventually(t, func() bool {
+		return c.pollersNumber() == 4 && atomic.LoadInt32(&c.activePollers) == 4
+	}, time.Second*5, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 300)
+	require.Equal(t, 4, c.pollersNumber())
+
+	// not lower than the min
+	require.Eventually(t, func() bool {
+		return c.pollersNumber() == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	// recovered after the throttling stops
+	throttle.Store(false)
+	require.Eventually(t, func() bool {
+		return c.pollersNumber() == 8 && atomic.LoadInt32(&c.activePollers) == 8
+	}, time.Second*10, time.Millisecond*10)
+}
+
+func TestAdaptivePollersBounds(t *testing.T) {
+	a, err := newAdaptivePollers(0, 0)
+	require.NoError(t, err)
+	require.Nil(t, a)
+	require.Equal(t, 5, a.clamp(5))
+
+	a, err = newAdaptivePollers(0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.clamp(0))
+	require.Equal(t, 10, a.clamp(20))
+
+	_, err = newAdaptivePollers(5, 2)
+	require.Error(t, err)
+	_, err = newAdaptivePollers(1, maxPollers+1)
+	require.Error(t, err)
+}
+
+func TestThrottleRetryer(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.adaptive = &adaptivePollers{min: 1, max: 4}
+	c.pollers = 4
+
+	o := &sqs.Options{Retryer: retry.NewStandard()}
+	c.withThrottleObserver()(o)
+
+	o.Retryer.IsErrorRetryable(&smithy.GenericAPIError{Code: "ServiceUnavailable"})
+	require.Equal(t, 4, c.pollersNumber())
+
+	// the attempts retried by the SDK are observed
+	o.Retryer.IsErrorRetryable(&smithy.GenericAPIError{Code: "RequestThrottled"})
+	require.Equal(t, 2, c.pollersNumber())
+}
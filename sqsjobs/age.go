@@ -0,0 +1,170 @@
+package sqsjobs
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// SentTimestamp is the system attribute with the time the message was sent to the queue (epoch time in milliseconds)
+const SentTimestamp string = "SentTimestamp"
+
+// defaultTimestampSkewThreshold is the future SentTimestamp offset logged as the clock skew
+const defaultTimestampSkewThreshold = time.Second
+
+// receiveAttributes returns the system attributes requested with every ReceiveMessage call: the ones required by the
+// driver and the message_system_attribute_names
+func (c *Driver) receiveAttributes() []types.QueueAttributeName {
+	attrs := []types.QueueAttributeName{types.QueueAttributeName(ApproximateReceiveCount)}
+	if c.maxMessageAge > 0 || c.latency != nil {
+		attrs = append(attrs, types.QueueAttributeName(SentTimestamp))
+	}
+
+	if c.dlqEnrich {
+		attrs = append(attrs, types.QueueAttributeName(ApproximateFirstReceiveTimestamp))
+	}
+
+	if c.groups != nil {
+		attrs = append(attrs, types.QueueAttributeName(MessageGroupIDAttr))
+	}
+
+	// message_system_attribute_names
+	for _, name := range c.poll.systemAttrs {
+		if !slices.Contains(attrs, name) {
+			attrs = append(attrs, name)
+		}
+	}
+
+	return attrs
+}
+
+// messageAge returns the age of the message based on the SentTimestamp attribute
+// false is returned if the attribute is absent or malformed
+func messageAge(msg *types.Message, now time.Time) (time.Duration, bool) {
+	ts, ok := msg.Attributes[SentTimestamp]
+	if !ok {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return now.Sub(time.UnixMilli(ms)), true
+}
+
+// sentAge returns the age of the message, the SentTimestamp in the future (the local clock is behind the SQS one) is
+// clamped to zero, so the skewed message is treated as fresh. The skew beyond the timestamp_skew_threshold is logged
+// if logSkew is set, once per message.
+func (c *Driver) sentAge(msg *types.Message, logSkew bool) (time.Duration, bool) {
+	age, ok := messageAge(msg, time.Now())
+	if !ok || age >= 0 {
+		return age, ok
+	}
+
+	if logSkew && -age > c.skewThreshold {
+		c.log.Debug("SentTimestamp is in the future, the clock skew is detected, the message age is clamped to zero", zap.Stringp("ID", msg.MessageId), zap.Duration("skew", -age), zap.Duration("threshold", c.skewThreshold))
+	}
+
+	return 0, true
+}
+
+// timestampSkewThreshold converts the timestamp_skew_threshold option (milliseconds), 0 - default
+func timestampSkewThreshold(ms int) time.Duration {
+	if ms <= 0 {
+		return defaultTimestampSkewThreshold
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// expired checks the message age against the max_message_age (+ clock skew tolerance)
+func (c *Driver) expired(msg *types.Message) (time.Duration, bool) {
+	if c.maxMessageAge == 0 {
+		return 0, false
+	}
+
+	age, ok := c.sentAge(msg, true)
+	if !ok {
+		c.log.Debug("failed to get the SentTimestamp attribute, skipping the message age check", zap.Stringp("ID", msg.MessageId))
+		return 0, false
+	}
+
+	return age, age > c.maxMessageAge+c.messageAgeSkew
+}
+
+// maxAge returns the max_message_age, max_job_age is its alias
+func maxAge(maxJobAge, maxMessageAge int) (time.Duration, error) {
+	if maxJobAge < 0 || maxMessageAge < 0 {
+		return 0, errors.Errorf("max_job_age and max_message_age should not be negative, provided: %d, %d", maxJobAge, maxMessageAge)
+	}
+
+	if maxJobAge > 0 && maxMessageAge > 0 && maxJobAge != maxMessageAge {
+		return 0, errors.Errorf("max_job_age (%d) conflicts with the max_message_age (%d), set one of them", maxJobAge, maxMessageAge)
+	}
+
+	return time.Duration(max(maxJobAge, maxMessageAge)) * time.Second, nil
+}
+
+// expiredQueue validates the expired_queue (the name or the URL), the name is resolved on setup
+func expiredQueue(prefix, queue string, maxAge time.Duration) (*string, *string, error) {
+	if queue == "" {
+		return nil, nil, nil
+	}
+
+	if maxAge == 0 {
+		return nil, nil, errors.Str("expired_queue requires the max_job_age")
+	}
+
+	name, url, err := queueTarget(prefix, queue)
+	if err != nil {
+		return nil, nil, errors.Errorf("expired_queue: %v", err)
+	}
+
+	return name, url, nil
+}
+
+// dropExpired deletes the expired message from the queue (or moves it to the expired_queue) without dispatching it
+// to the workers
+func (c *Driver) dropExpired(ctx context.Context, msg *types.Message, age time.Duration) {
+	if c.expiredURL != nil {
+		err := c.moveTo(ctx, msg, c.expiredURL, errors.Errorf("message is older than max_job_age: %s", age.Round(time.Second)))
+		if err != nil {
+			c.log.Error("failed to move the expired message to the expired_queue", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+			return
+		}
+
+		atomic.AddUint64(&c.expiredTotal, 1)
+		c.log.Warn("message is older than max_message_age, moved to the expired_queue", zap.Stringp("ID", msg.MessageId), zap.Duration("age", age), zap.Duration("max_message_age", c.maxMessageAge))
+		return
+	}
+
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+		QueueUrl:      queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.log.Error("failed to delete the expired message from the queue", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return
+	}
+
+	atomic.AddUint64(&c.expiredTotal, 1)
+	c.log.Warn("message is older than max_message_age, dropped", zap.Stringp("ID", msg.MessageId), zap.Duration("age", age), zap.Duration("max_message_age", c.maxMessageAge))
+}
+
+// ExpiredMessages returns the number of the messages dropped (or moved to the expired_queue) by the max_job_age since the pipeline start
+func (c *Driver) ExpiredMessages() uint64 {
+	return atomic.LoadUint64(&c.expiredTotal)
+}
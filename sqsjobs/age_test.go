@@ -0,0 +1,149 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func sentAt(id string, t time.Time) types.Message {
+	return types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("receipt-" + id),
+		Body:          aws.String("body-" + id),
+		Attributes:    map[string]string{SentTimestamp: strconv.FormatInt(t.UnixMilli(), 10)},
+	}
+}
+
+func TestMaxMessageAgeDrop(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.maxMessageAge = time.Minute
+	c.messageAgeSkew = time.Second * 5
+
+	fc := newFakeClient()
+	var attrs []types.QueueAttributeName
+	recv := receiveOnce(
+		sentAt("old", time.Now().Add(-time.Hour)),
+		sentAt("fresh", time.Now().Add(-time.Second)),
+		// within the skew tolerance
+		sentAt("skewed", time.Now().Add(-time.Minute-time.Second*2)),
+	)
+	fc.receiveFn = func(ctx context.Context, in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		attrs = in.AttributeNames
+		return recv(ctx, in)
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Contains(t, attrs, types.QueueAttributeName(SentTimestamp))
+
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+	require.Equal(t, "receipt-old", aws.ToString(fc.deleted[0].ReceiptHandle))
+
+	bodies := make([]string, 0, 2)
+	for _, j := range pq.Remove("") {
+		bodies = append(bodies, string(j.Body()))
+	}
+	require.ElementsMatch(t, []string{"body-fresh", "body-skewed"}, bodies)
+}
+
+func TestMaxMessageAgeDisabled(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	require.NotContains(t, c.receiveAttributes(), types.QueueAttributeName(SentTimestamp))
+
+	m := sentAt("old", time.Now().Add(-time.Hour))
+	_, ok := c.expired(&m)
+	require.False(t, ok)
+
+	// no SentTimestamp - never expired
+	c.maxMessageAge = time.Second
+	_, ok = c.expired(&types.Message{MessageId: aws.String("1")})
+	require.False(t, ok)
+}
+
+func TestMaxMessageAgeFutureTimestamp(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	core, logs := observer.New(zapcore.DebugLevel)
+	c.log = zap.New(core)
+	c.maxMessageAge = time.Minute
+	c.skewThreshold = timestampSkewThreshold(0)
+	c.latency = newLatencyMetrics(true, nil)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		// the local clock is behind the SQS one
+		sentAt("future", time.Now().Add(time.Minute*10)),
+		// within the skew threshold, not logged
+		sentAt("near", time.Now().Add(time.Millisecond*100)),
+	)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+
+	age := c.latency.age.snapshot()
+	require.Equal(t, uint64(2), age.Count)
+	require.Equal(t, float64(0), age.Sum)
+
+	skewed := logs.FilterMessageSnippet("clock skew").All()
+	require.Len(t, skewed, 1)
+	require.Equal(t, "future", skewed[0].ContextMap()["ID"])
+
+	m := sentAt("future", time.Now().Add(time.Hour))
+	got, ok := c.sentAge(&m, false)
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), got)
+	require.Equal(t, time.Millisecond*250, timestampSkewThreshold(250))
+}
+
+func TestExpiredQueue(t *testing.T) {
+	_, err := maxAge(60, 120)
+	require.Error(t, err)
+	age, err := maxAge(60, 0)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, age)
+	_, _, err = expiredQueue("", "expired", 0)
+	require.Error(t, err)
+
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.maxMessageAge = time.Minute
+	c.expiredQueue, c.expiredURL, err = expiredQueue("", "http://127.0.0.1:9324/000000000000/expired", c.maxMessageAge)
+	require.NoError(t, err)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(sentAt("old", time.Now().Add(-time.Hour)), sentAt("fresh", time.Now()))
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 1 && c.ExpiredMessages() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/expired", aws.ToString(fc.sent[0].QueueUrl))
+	require.Equal(t, "body-old", aws.ToString(fc.sent[0].MessageBody))
+	require.Equal(t, "receipt-old", aws.ToString(fc.deleted[0].ReceiptHandle))
+}
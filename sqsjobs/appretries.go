@@ -0,0 +1,52 @@
+package sqsjobs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// checkMaxAppRetries validates the max_app_retries option, the messages are moved to the dead-letter queue explicitly
+func checkMaxAppRetries(retries int, dlq string) error {
+	if retries < 0 {
+		return errors.Errorf("max_app_retries should be positive, provided: %d", retries)
+	}
+
+	if retries > 0 && dlq == "" {
+		return errors.Str("max_app_retries requires the dead_letter_queue")
+	}
+
+	return nil
+}
+
+// attempts returns the number of the processing attempts of the message before this one: the previous receives
+// (visibility timeouts) and the nacks (the nacked message is sent again, its receive count starts over)
+func attempts(item *Item) int64 {
+	return max(item.Options.approxReceiveCount-1, 0) + item.Options.retries
+}
+
+// retriesExhausted moves the message to the dead-letter queue if the max_app_retries is exceeded, returns true if the
+// message should not be dispatched. The message is left in the queue if the move fails.
+func (c *Driver) retriesExhausted(ctx context.Context, m *types.Message, item *Item) bool {
+	if c.maxAppRetries == 0 {
+		return false
+	}
+
+	n := attempts(item)
+	if n <= int64(c.maxAppRetries) {
+		return false
+	}
+
+	log := item.Options.log
+	err := c.moveToDLQ(ctx, m, errors.Errorf("max_app_retries (%d) exceeded, retries: %d", c.maxAppRetries, n))
+	item.Options.receipt.done()
+	if err != nil {
+		log.Error("failed to move the message with the exhausted retries to the dead-letter queue", zap.Stringp("ID", m.MessageId), zap.Error(err))
+		return true
+	}
+
+	log.Warn("max_app_retries exceeded, message moved to the dead-letter queue", zap.Stringp("ID", m.MessageId), zap.Int64("retries", n))
+	return true
+}
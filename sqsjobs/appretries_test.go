@@ -0,0 +1,122 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxAppRetriesMovesToDLQ(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+	c.dlqEnrich = true
+	c.maxAppRetries = 3
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{
+		MessageId:     aws.String("exhausted"),
+		ReceiptHandle: aws.String("receipt-exhausted"),
+		Body:          aws.String("body"),
+		// the 5th receive, 4 retries
+		Attributes: map[string]string{ApproximateReceiveCount: "5"},
+	}, types.Message{
+		MessageId:     aws.String("retried"),
+		ReceiptHandle: aws.String("receipt-retried"),
+		Body:          aws.String("retried body"),
+		Attributes:    map[string]string{ApproximateReceiveCount: "4"},
+	})
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return fc.called("DeleteMessage") == 1 && pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, c.dlqURL, sent.QueueUrl)
+	require.Equal(t, "body", aws.ToString(sent.MessageBody))
+	require.Equal(t, "5", aws.ToString(sent.MessageAttributes[DLQReceiveCount].StringValue))
+	require.Contains(t, aws.ToString(sent.MessageAttributes[DLQLastError].StringValue), "max_app_retries (3) exceeded")
+	require.Equal(t, "receipt-exhausted", aws.ToString(fc.deleted[0].ReceiptHandle))
+
+	// the message within the limit is dispatched
+	require.Equal(t, "retried body", string(pq.ExtractMin().(*Item).Payload))
+}
+
+func TestMaxAppRetriesSlowDLQ(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+	c.maxAppRetries = 3
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveEach(types.Message{
+		MessageId:     aws.String("exhausted"),
+		ReceiptHandle: aws.String("receipt-exhausted"),
+		Body:          aws.String("body"),
+		Attributes:    map[string]string{ApproximateReceiveCount: "5"},
+	}, types.Message{
+		MessageId:     aws.String("retried"),
+		ReceiptHandle: aws.String("receipt-retried"),
+		Body:          aws.String("retried body"),
+		Attributes:    map[string]string{ApproximateReceiveCount: "4"},
+	})
+	sending := make(chan struct{})
+	unblock := make(chan struct{})
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		close(sending)
+		<-unblock
+		return &sqs.SendMessageOutput{MessageId: aws.String("dlq")}, nil
+	}
+	c.client = fc
+
+	// two pollers, the slow dead-letter queue send doesn't block the other one
+	stop := runListeners(c, 2)
+
+	<-sending
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+
+	close(unblock)
+	require.Eventually(t, func() bool { return fc.called("DeleteMessage") == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, "retried body", string(pq.ExtractMin().(*Item).Payload))
+}
+
+func TestMaxAppRetriesCountsNacks(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.maxAppRetries = 3
+	fc := newFakeClient()
+	c.client = fc
+
+	item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body"), Attributes: map[string]string{ApproximateReceiveCount: "2"}, MessageAttributes: map[string]types.MessageAttributeValue{
+		RetryCountAttr: {DataType: aws.String(NumberType), StringValue: aws.String("2")},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), attempts(item))
+	require.False(t, c.retriesExhausted(context.Background(), &types.Message{}, item))
+
+	*c.msgInFlight = 1
+	require.NoError(t, item.Nack())
+
+	// the nacked message is sent again with the count
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, aws.ToString(c.queueURL), aws.ToString(fc.sent[0].QueueUrl))
+	require.Equal(t, "3", aws.ToString(fc.sent[0].MessageAttributes[RetryCountAttr].StringValue))
+
+	require.NoError(t, checkMaxAppRetries(0, ""))
+	require.NoError(t, checkMaxAppRetries(3, "test-dlq"))
+	require.Error(t, checkMaxAppRetries(3, ""))
+	require.Error(t, checkMaxAppRetries(-1, "test-dlq"))
+}
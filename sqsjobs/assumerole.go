@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/roadrunner-server/errors"
+)
+
+// defaultRoleSessionName is the session name of the assumed role if the session_name is not set
+const defaultRoleSessionName string = "roadrunner-sqs"
+
+// checkAssumeRole validates the role_arn options, the web identity token file should exist
+func checkAssumeRole(conf *Config) error {
+	if conf.RoleARN == "" {
+		if conf.ExternalID != "" || conf.RoleSessionName != "" || conf.WebIdentityTokenFile != "" {
+			return errors.Str("external_id, session_name and web_identity_token_file require the role_arn")
+		}
+		return nil
+	}
+
+	if conf.WebIdentityTokenFile == "" {
+		return nil
+	}
+
+	if conf.ExternalID != "" {
+		return errors.Str("external_id can't be used with the web_identity_token_file, the web identity role is assumed without it")
+	}
+
+	if _, err := os.Stat(conf.WebIdentityTokenFile); err != nil {
+		return errors.Errorf("web_identity_token_file: %v", err)
+	}
+
+	return nil
+}
+
+// assumeRoleProvider returns the credentials of the role_arn, nil if not configured. The role is assumed with the
+// credentials of the AWS config (static, profile, chain or the default chain), or with the web identity token
+// (e.g. EKS IRSA). The credentials are cached and refreshed by the SDK before they expire.
+func assumeRoleProvider(conf *Config, awsConf aws.Config) aws.CredentialsProvider {
+	if conf.RoleARN == "" {
+		return nil
+	}
+
+	client := sts.NewFromConfig(awsConf)
+	if conf.WebIdentityTokenFile != "" {
+		return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(client, conf.RoleARN, stscreds.IdentityTokenFile(conf.WebIdentityTokenFile), webIdentityOptions(conf)))
+	}
+
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, conf.RoleARN, assumeRoleOptions(conf)))
+}
+
+func assumeRoleOptions(conf *Config) func(*stscreds.AssumeRoleOptions) {
+	return func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName(conf.RoleSessionName)
+		if conf.ExternalID != "" {
+			o.ExternalID = aws.String(conf.ExternalID)
+		}
+	}
+}
+
+func webIdentityOptions(conf *Config) func(*stscreds.WebIdentityRoleOptions) {
+	return func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = sessionName(conf.RoleSessionName)
+	}
+}
+
+func sessionName(name string) string {
+	if name == "" {
+		return defaultRoleSessionName
+	}
+
+	return name
+}
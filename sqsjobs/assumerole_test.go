@@ -0,0 +1,49 @@
+package sqsjobs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAssumeRole(t *testing.T) {
+	require.NoError(t, checkAssumeRole(&Config{}))
+	require.NoError(t, checkAssumeRole(&Config{RoleARN: "arn:aws:iam::123456789012:role/rr", ExternalID: "ext"}))
+
+	// the role options without the role
+	require.Error(t, checkAssumeRole(&Config{ExternalID: "ext"}))
+	require.Error(t, checkAssumeRole(&Config{WebIdentityTokenFile: "/token"}))
+
+	token := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(token, []byte("jwt"), 0o600))
+	require.NoError(t, checkAssumeRole(&Config{RoleARN: "arn:aws:iam::123456789012:role/rr", WebIdentityTokenFile: token}))
+
+	err := checkAssumeRole(&Config{RoleARN: "arn:aws:iam::123456789012:role/rr", WebIdentityTokenFile: filepath.Join(t.TempDir(), "missing")})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "web_identity_token_file")
+
+	require.Error(t, checkAssumeRole(&Config{RoleARN: "arn:aws:iam::123456789012:role/rr", WebIdentityTokenFile: token, ExternalID: "ext"}))
+}
+
+func TestAssumeRoleOptions(t *testing.T) {
+	require.Nil(t, assumeRoleProvider(&Config{}, aws.Config{}))
+	require.NotNil(t, assumeRoleProvider(&Config{RoleARN: "arn:aws:iam::123456789012:role/rr"}, aws.Config{}))
+
+	aro := &stscreds.AssumeRoleOptions{}
+	assumeRoleOptions(&Config{ExternalID: "ext"})(aro)
+	require.Equal(t, defaultRoleSessionName, aro.RoleSessionName)
+	require.Equal(t, "ext", aws.ToString(aro.ExternalID))
+
+	aro = &stscreds.AssumeRoleOptions{}
+	assumeRoleOptions(&Config{RoleSessionName: "billing"})(aro)
+	require.Equal(t, "billing", aro.RoleSessionName)
+	require.Nil(t, aro.ExternalID)
+
+	wio := &stscreds.WebIdentityRoleOptions{}
+	webIdentityOptions(&Config{RoleSessionName: "irsa"})(wio)
+	require.Equal(t, "irsa", wio.RoleSessionName)
+}
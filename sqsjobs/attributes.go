@@ -1,5 +1,11 @@
 package sqsjobs
 
+import (
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
 const (
 	Policy                                   string = "policy"
 	PolicyAWS                                string = "Policy"
@@ -121,3 +127,26 @@ func toAwsAttribute(attrs map[string]string, ret map[string]string) { //nolint:g
 		}
 	}
 }
+
+// managedSSE enables the SQS managed server-side encryption (SSE-SQS) in the queue attributes.
+// SSE-SQS and SSE-KMS (KmsMasterKeyId attribute) are mutually exclusive.
+func managedSSE(attrs map[string]string, enabled bool) error {
+	if !enabled {
+		return nil
+	}
+
+	for k, v := range attrs {
+		switch {
+		case strings.EqualFold(k, KmsMasterKeyIDAWS) && v != "":
+			return errors.Str("sse_managed can't be combined with the KMS encryption (KmsMasterKeyId attribute)")
+		case strings.EqualFold(k, SqsManagedSseEnabledAWS):
+			if !strings.EqualFold(v, "true") {
+				return errors.Errorf("sse_managed conflicts with the %s attribute: %s", SqsManagedSseEnabledAWS, v)
+			}
+			delete(attrs, k)
+		}
+	}
+
+	attrs[SqsManagedSseEnabledAWS] = "true"
+	return nil
+}
@@ -1,6 +1,7 @@
 package sqsjobs
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -111,3 +112,36 @@ func TestAttributes2(t *testing.T) {
 	require.Equal(t, "foo", m2["SqsManagedSseEnabled"])
 	require.Equal(t, "foo", m2["ReceiveMessageWaitTimeSeconds"])
 }
+
+func TestManagedSSE(t *testing.T) {
+	cfg := Config{Attributes: map[string]string{"visibilitytimeout": "30", "sqsmanagedsseenabled": "true"}}
+	cfg.InitDefault()
+	require.NoError(t, managedSSE(cfg.Attributes, true))
+
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.attributes = cfg.Attributes
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Len(t, fc.created, 1)
+	require.Equal(t, map[string]string{VisibilityTimeoutAWS: "30", SqsManagedSseEnabledAWS: "true"}, fc.created[0].Attributes)
+
+	// pipeline attributes are not converted
+	attrs := map[string]string{}
+	require.NoError(t, managedSSE(attrs, true))
+	require.Equal(t, "true", attrs[SqsManagedSseEnabledAWS])
+
+	attrs = map[string]string{"VisibilityTimeout": "30"}
+	require.NoError(t, managedSSE(attrs, false))
+	require.NotContains(t, attrs, SqsManagedSseEnabledAWS)
+}
+
+func TestManagedSSEWithKMS(t *testing.T) {
+	cfg := Config{Attributes: map[string]string{"kmsmasterkeyid": "alias/aws/sqs"}}
+	cfg.InitDefault()
+	require.Error(t, managedSSE(cfg.Attributes, true))
+
+	require.Error(t, managedSSE(map[string]string{"KmsMasterKeyId": "alias/aws/sqs"}, true))
+	require.Error(t, managedSSE(map[string]string{"SqsManagedSseEnabled": "false"}, true))
+}
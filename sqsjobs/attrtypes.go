@@ -0,0 +1,124 @@
+package sqsjobs
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// AttrTypesHeader is the job header with the message attribute data types, one <name>:<type> value per attribute,
+	// e.g. amount:Number.int. Used with preserve_attribute_types.
+	AttrTypesHeader string = "X-RR-Attr-Types"
+	// SQS limit for the data type length, including the custom label
+	maxDataTypeLen int = 256
+)
+
+// baseType returns the SQS data type without the custom label, e.g. Number for Number.int
+func baseType(dataType string) string {
+	base, _, _ := strings.Cut(dataType, ".")
+	return base
+}
+
+// checkDataType validates the data type: String, Number or Binary with an optional custom label
+func checkDataType(dataType string) error {
+	switch baseType(dataType) {
+	case StringType, NumberType, BinaryType:
+	default:
+		return errors.Errorf("unsupported message attribute data type %s, should be String, Number or Binary with an optional custom label", dataType)
+	}
+
+	if len(dataType) > maxDataTypeLen || strings.HasSuffix(dataType, ".") {
+		return errors.Errorf("invalid message attribute data type %s", dataType)
+	}
+
+	return nil
+}
+
+// typedAttributes writes the headers listed in the X-RR-Attr-Types header as the message attributes with their data types
+// (preserve_attribute_types), so the consumers validating on the type label see the original one
+func (c *Driver) typedAttributes(item *Item, in *sqs.SendMessageInput) error {
+	if !c.preserveTypes {
+		return nil
+	}
+
+	entries := item.headers[AttrTypesHeader]
+	for i := 0; i < len(entries); i++ {
+		name, dataType, ok := strings.Cut(entries[i], ":")
+		if !ok || name == "" {
+			return errors.Errorf("malformed %s header value %s, should be <name>:<type>", AttrTypesHeader, entries[i])
+		}
+
+		err := checkDataType(dataType)
+		if err != nil {
+			return err
+		}
+
+		value := headerValue(item.headers, name)
+		if value == "" {
+			continue
+		}
+
+		if _, exists := in.MessageAttributes[name]; exists {
+			return errors.Errorf("typed header %s conflicts with the existing message attribute", name)
+		}
+
+		attr := types.MessageAttributeValue{DataType: aws.String(dataType)}
+		if baseType(dataType) == BinaryType {
+			attr.BinaryValue = []byte(value)
+		} else {
+			attr.StringValue = aws.String(value)
+		}
+		in.MessageAttributes[name] = attr
+	}
+
+	return nil
+}
+
+// readAttrTypes promotes the message attributes with the custom type labels to the headers (if absent) and records
+// the data types (other than plain String) in the X-RR-Attr-Types header, so the types survive the requeue
+func (c *Driver) readAttrTypes(attrs map[string]types.MessageAttributeValue, h map[string][]string) {
+	if !c.preserveTypes {
+		return
+	}
+
+	for name, v := range attrs {
+		if v.DataType == nil || *v.DataType == StringType || reservedAttr(name) {
+			continue
+		}
+
+		if _, ok := h[name]; !ok {
+			switch {
+			case baseType(*v.DataType) == BinaryType || len(v.BinaryValue) > 0:
+				h[name] = []string{string(v.BinaryValue)}
+			case v.StringValue != nil:
+				h[name] = []string{*v.StringValue}
+			default:
+				continue
+			}
+		}
+
+		entry := name + ":" + *v.DataType
+		if !slices.Contains(h[AttrTypesHeader], entry) {
+			h[AttrTypesHeader] = append(h[AttrTypesHeader], entry)
+		}
+	}
+
+	// stable order for the map iteration above
+	slices.Sort(h[AttrTypesHeader])
+}
+
+// reservedAttr returns true for the RR and the driver metadata attributes
+func reservedAttr(name string) bool {
+	switch name {
+	case jobs.RRJob, jobs.RRID, jobs.RRDelay, jobs.RRAutoAck, jobs.RRPriority, jobs.RRPipeline, jobs.RRHeaders:
+		return true
+	}
+
+	return pinnedAttr(name) || name == AttrOverflow
+}
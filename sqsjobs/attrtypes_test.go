@@ -0,0 +1,62 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeTypeLabelRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.preserveTypes = true
+	fc := newFakeClient()
+	c.client = fc
+
+	// third-party message with the custom labeled attributes
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		"amount": {DataType: aws.String("Number.int"), StringValue: aws.String("42")},
+		"email":  {DataType: aws.String("String.email"), StringValue: aws.String("a@example.com")},
+		"plain":  {DataType: aws.String(StringType), StringValue: aws.String("value")},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, out.headers["amount"])
+	require.Equal(t, []string{"a@example.com"}, out.headers["email"])
+	require.Equal(t, []string{"amount:Number.int", "email:String.email"}, out.headers[AttrTypesHeader])
+
+	// requeue keeps the labels
+	require.NoError(t, c.handleItem(context.Background(), out))
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0].MessageAttributes
+	require.Equal(t, "Number.int", aws.ToString(sent["amount"].DataType))
+	require.Equal(t, "42", aws.ToString(sent["amount"].StringValue))
+	require.Equal(t, "String.email", aws.ToString(sent["email"].DataType))
+	require.NotContains(t, sent, "plain")
+
+	// and the RR message is restored with the same types
+	back, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("2"), Body: fc.sent[0].MessageBody, MessageAttributes: sent})
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, back.headers["amount"])
+	require.Equal(t, []string{"amount:Number.int", "email:String.email"}, back.headers[AttrTypesHeader])
+}
+
+func TestAttributeTypeLabelDisabled(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		"amount": {DataType: aws.String("Number.int"), StringValue: aws.String("42")},
+	}})
+	require.NoError(t, err)
+	require.NotContains(t, out.headers, AttrTypesHeader)
+
+	c.preserveTypes = true
+	item := &Item{Job: "job", Ident: "id", headers: map[string][]string{"amount": {"42"}, AttrTypesHeader: {"amount:Float"}}, Options: &Options{}}
+	in, err := item.pack(c.queueURL, c.queue, "", false)
+	require.NoError(t, err)
+	require.Error(t, c.typedAttributes(item, in))
+
+	require.NoError(t, checkDataType("Binary.gif"))
+	require.Error(t, checkDataType("Number."))
+}
@@ -0,0 +1,67 @@
+package sqsjobs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/logging"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	awsLogRetries   string = "retries"
+	awsLogRequests  string = "requests"
+	awsLogResponses string = "responses"
+	awsLogAll       string = "all"
+)
+
+// zapLogger forwards the AWS SDK logs to the zap logger
+type zapLogger struct {
+	log *zap.Logger
+}
+
+func (z *zapLogger) Logf(classification logging.Classification, format string, v ...any) {
+	z.log.Debug(fmt.Sprintf(format, v...), zap.String("classification", string(classification)))
+}
+
+// parseAWSLogMode parses the comma separated aws_log_mode option
+func parseAWSLogMode(mode string) (aws.ClientLogMode, error) {
+	var lm aws.ClientLogMode
+	for _, m := range strings.Split(mode, ",") {
+		switch strings.ToLower(strings.TrimSpace(m)) {
+		case "":
+		case awsLogRetries:
+			lm |= aws.LogRetries
+		case awsLogRequests:
+			lm |= aws.LogRequest
+		case awsLogResponses:
+			lm |= aws.LogResponse
+		case awsLogAll:
+			lm |= aws.LogRetries | aws.LogRequest | aws.LogResponse | aws.LogSigning
+		default:
+			return 0, errors.Errorf("unknown aws_log_mode: %s, supported: %s, %s, %s, %s", m, awsLogRetries, awsLogRequests, awsLogResponses, awsLogAll)
+		}
+	}
+
+	return lm, nil
+}
+
+// awsLogOptions returns the config options to forward the AWS SDK logs to the zap logger, nil if logging is disabled
+func awsLogOptions(mode string, log *zap.Logger) ([]func(*config.LoadOptions) error, error) {
+	lm, err := parseAWSLogMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if lm == 0 || log == nil {
+		return nil, nil
+	}
+
+	return []func(*config.LoadOptions) error{
+		config.WithLogger(&zapLogger{log: log.Named("aws")}),
+		config.WithClientLogMode(lm),
+	}, nil
+}
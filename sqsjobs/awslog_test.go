@@ -0,0 +1,59 @@
+package sqsjobs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/logging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAWSLogMode(t *testing.T) {
+	lm, err := parseAWSLogMode("")
+	require.NoError(t, err)
+	require.Equal(t, aws.ClientLogMode(0), lm)
+
+	lm, err = parseAWSLogMode("retries, Requests")
+	require.NoError(t, err)
+	require.Equal(t, aws.LogRetries|aws.LogRequest, lm)
+
+	lm, err = parseAWSLogMode("all")
+	require.NoError(t, err)
+	require.Equal(t, aws.LogResponse, lm&aws.LogResponse)
+
+	_, err = parseAWSLogMode("everything")
+	require.Error(t, err)
+}
+
+func TestAWSLogForwardedToZap(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	opts, err := awsLogOptions("retries", zap.New(core))
+	require.NoError(t, err)
+
+	lo := &config.LoadOptions{}
+	for _, opt := range opts {
+		require.NoError(t, opt(lo))
+	}
+
+	require.NotNil(t, lo.ClientLogMode)
+	require.Equal(t, aws.LogRetries, *lo.ClientLogMode)
+
+	// the SDK writes the logs through the configured logger
+	lo.Logger.Logf(logging.Debug, "retrying request %s, attempt %d", "ReceiveMessage", 2)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "retrying request ReceiveMessage, attempt 2", entries[0].Message)
+	require.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	require.Equal(t, "DEBUG", entries[0].ContextMap()["classification"])
+
+	// disabled
+	opts, err = awsLogOptions("", zap.New(core))
+	require.NoError(t, err)
+	require.Empty(t, opts)
+}
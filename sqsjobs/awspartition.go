@@ -0,0 +1,80 @@
+package sqsjobs
+
+import (
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// AWS partitions
+const (
+	partitionAWS   string = "aws"
+	partitionGov   string = "aws-us-gov"
+	partitionChina string = "aws-cn"
+)
+
+// partitionDNSSuffix returns the DNS suffix of the partition endpoints
+func partitionDNSSuffix(partition string) (string, error) {
+	switch partition {
+	case partitionAWS, partitionGov:
+		return "amazonaws.com", nil
+	case partitionChina:
+		return "amazonaws.com.cn", nil
+	default:
+		return "", errors.Errorf("unknown partition: %s, supported: aws, aws-us-gov, aws-cn", partition)
+	}
+}
+
+// regionPartition returns the partition the region belongs to, e.g. us-gov-west-1 -> aws-us-gov
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return partitionGov
+	case strings.HasPrefix(region, "cn-"):
+		return partitionChina
+	default:
+		return partitionAWS
+	}
+}
+
+// checkPartition validates the partition and the region consistency, empty partition is not checked
+func checkPartition(partition, region string) error {
+	if partition == "" {
+		return nil
+	}
+
+	_, err := partitionDNSSuffix(partition)
+	if err != nil {
+		return err
+	}
+
+	if region != "" && regionPartition(region) != partition {
+		return errors.Errorf("region %s belongs to the %s partition, configured partition: %s", region, regionPartition(region), partition)
+	}
+
+	return nil
+}
+
+// partitionEndpoint returns the SQS endpoint: the configured one or, if empty, the regional endpoint of the partition,
+// e.g. https://sqs.us-gov-west-1.amazonaws.com
+func partitionEndpoint(conf *Config, region string) (string, error) {
+	err := checkPartition(conf.Partition, region)
+	if err != nil {
+		return "", err
+	}
+
+	if conf.Endpoint != "" {
+		return conf.Endpoint, nil
+	}
+
+	if region == "" {
+		return "", errors.Errorf("region is required to resolve the %s partition endpoint", conf.Partition)
+	}
+
+	suffix, err := partitionDNSSuffix(conf.Partition)
+	if err != nil {
+		return "", err
+	}
+
+	return "https://sqs." + region + "." + suffix, nil
+}
@@ -0,0 +1,60 @@
+package sqsjobs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPartitionEndpoint(t *testing.T) {
+	endpoint, err := partitionEndpoint(&Config{Partition: partitionGov}, "us-gov-west-1")
+	require.NoError(t, err)
+	require.Equal(t, "https://sqs.us-gov-west-1.amazonaws.com", endpoint)
+
+	endpoint, err = partitionEndpoint(&Config{Partition: partitionChina}, "cn-north-1")
+	require.NoError(t, err)
+	require.Equal(t, "https://sqs.cn-north-1.amazonaws.com.cn", endpoint)
+
+	// explicit endpoint wins
+	endpoint, err = partitionEndpoint(&Config{Partition: partitionGov, Endpoint: "https://vpce.example"}, "us-gov-east-1")
+	require.NoError(t, err)
+	require.Equal(t, "https://vpce.example", endpoint)
+
+	// no partition - the default local endpoint
+	conf := &Config{}
+	conf.InitDefault()
+	endpoint, err = partitionEndpoint(conf, "us-east-1")
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:9324", endpoint)
+
+	// region/partition consistency
+	_, err = partitionEndpoint(&Config{Partition: partitionAWS}, "us-gov-west-1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "aws-us-gov")
+
+	_, err = partitionEndpoint(&Config{Partition: partitionGov}, "us-east-1")
+	require.Error(t, err)
+
+	_, err = partitionEndpoint(&Config{Partition: "aws-iso"}, "us-iso-east-1")
+	require.Error(t, err)
+
+	_, err = partitionEndpoint(&Config{Partition: partitionGov}, "")
+	require.Error(t, err)
+}
+
+func TestPipelineEndpointInsideAWS(t *testing.T) {
+	conf := &Config{Region: "us-east-1", Key: "key", Secret: "secret", SessionToken: "token", Endpoint: "http://127.0.0.1:9324", SkipWarmup: true}
+
+	// the global endpoint is resolved by the SDK inside AWS
+	client, err := checkEnv(true, conf, zap.NewNop())
+	require.NoError(t, err)
+	require.Nil(t, client.Options().BaseEndpoint)
+
+	// the endpoint of the pipeline, e.g. a VPC interface endpoint
+	conf.pipelineEndpoint = "https://vpce-0123.sqs.us-east-1.vpce.amazonaws.com"
+	client, err = checkEnv(true, conf, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, conf.pipelineEndpoint, aws.ToString(client.Options().BaseEndpoint))
+}
@@ -0,0 +1,47 @@
+package sqsjobs
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// BaggageAttr is the message attribute with the W3C (OpenTelemetry) baggage
+	BaggageAttr string = "baggage"
+	// maxBaggageSize is the W3C baggage size limit (in bytes)
+	maxBaggageSize int = 8192
+)
+
+// baggageAttribute copies the propagated baggage header to the baggage message attribute, so the consumers not aware of the RR headers see it.
+// The attribute is optional (the baggage is still in the headers), it's skipped if it would exceed the attributes or size limits.
+func baggageAttribute(headers map[string][]string, attrs map[string]types.MessageAttributeValue) {
+	val := http.Header(headers).Get(BaggageAttr)
+	if val == "" || len(val) > maxBaggageSize || len(attrs) >= maxMessageAttributes {
+		return
+	}
+
+	if _, ok := attrs[BaggageAttr]; ok {
+		return
+	}
+
+	attrs[BaggageAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(val)}
+}
+
+// restoreBaggage restores the baggage header from the message attribute, so it's extracted into the span context on receive.
+// The baggage from the RR headers takes precedence.
+func restoreBaggage(attrs map[string]types.MessageAttributeValue, headers map[string][]string) {
+	attr, ok := attrs[BaggageAttr]
+	if !ok || attr.StringValue == nil || *attr.StringValue == "" || len(*attr.StringValue) > maxBaggageSize {
+		return
+	}
+
+	if http.Header(headers).Get(BaggageAttr) != "" {
+		return
+	}
+
+	// third-party messages: the attribute was copied to the headers as is
+	delete(headers, BaggageAttr)
+	http.Header(headers).Set(BaggageAttr, *attr.StringValue)
+}
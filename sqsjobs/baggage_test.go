@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestBaggageRoundTrip(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	tenant, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	bg, err := baggage.New(tenant)
+	require.NoError(t, err)
+	ctx := baggage.ContextWithBaggage(context.Background(), bg)
+
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{}}
+	require.NoError(t, c.handleItem(ctx, item))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, "tenant=acme", aws.ToString(sent.MessageAttributes[BaggageAttr].StringValue))
+
+	// third-party consumer view: a message with the baggage attribute only
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("2"), Body: aws.String("foreign"), MessageAttributes: map[string]types.MessageAttributeValue{
+			BaggageAttr: {DataType: aws.String(StringType), StringValue: aws.String("tenant=globex")},
+		}},
+	)
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	for _, want := range []string{"acme", "globex"} {
+		j := pq.ExtractMin()
+		// downstream workers see the same baggage
+		got := baggage.FromContext(c.prop.Extract(context.Background(), propagation.HeaderCarrier(j.Headers())))
+		require.Equal(t, want, got.Member("tenant").Value())
+		require.Len(t, j.Headers()[http.CanonicalHeaderKey(BaggageAttr)], 1)
+		require.NotContains(t, j.Headers(), BaggageAttr)
+	}
+}
+
+func TestBaggageLimits(t *testing.T) {
+	attrs := map[string]types.MessageAttributeValue{}
+	baggageAttribute(map[string][]string{"Baggage": {"k=" + strings.Repeat("v", maxBaggageSize)}}, attrs)
+	require.Empty(t, attrs)
+
+	for i := 0; i < maxMessageAttributes; i++ {
+		attrs[string(rune('a'+i))] = types.MessageAttributeValue{}
+	}
+	baggageAttribute(map[string][]string{"Baggage": {"k=v"}}, attrs)
+	require.NotContains(t, attrs, BaggageAttr)
+
+	// the baggage from the headers takes precedence
+	h := map[string][]string{"Baggage": {"tenant=acme"}}
+	restoreBaggage(map[string]types.MessageAttributeValue{BaggageAttr: {DataType: aws.String(StringType), StringValue: aws.String("tenant=globex")}}, h)
+	require.Equal(t, []string{"tenant=acme"}, h["Baggage"])
+}
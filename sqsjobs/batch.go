@@ -0,0 +1,413 @@
+package sqsjobs
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxBatchEntries is the maximum number of entries in the single SendMessageBatch call
+	maxBatchEntries int = 10
+	// maxBatchBytes is the maximum aggregate size of all messages in the SendMessageBatch call (256 KiB)
+	maxBatchBytes int = 256 * 1024
+	// default time to wait for the batch to fill up
+	defaultBatchFlushInterval = time.Millisecond * 10
+	// timeout for the single batch flush
+	batchFlushTimeout = time.Minute
+	// default time to wait for the final flush result after the caller's context is canceled
+	defaultCancelFlush = time.Second
+)
+
+type sendEntry struct {
+	input *sqs.SendMessageInput
+	size  int
+	res   chan error
+}
+
+// sendBatcher aggregates pushed messages and sends them with SendMessageBatch.
+// A batch is flushed when it has size entries, when the next message would exceed maxBytes, or after the interval.
+// For the FIFO queues (ordered) the batches are sent one by one in the order they were taken, since SQS orders
+// the messages of a group by the send sequence, the concurrent flushes might reorder the group across the batches.
+type sendBatcher struct {
+	mu sync.Mutex
+
+	client   sqsClient
+	queueURL *string
+	log      *zap.Logger
+
+	size     int
+	maxBytes int
+	interval time.Duration
+	// the window of the final flush on the caller's context cancel
+	cancelFlush time.Duration
+
+	pending      []*sendEntry
+	pendingBytes int
+	timer        *time.Timer
+
+	ordered bool
+	// retries on the transient network errors
+	retries int
+	budget  *retryBudget
+	// tickets issued (in the take order) and the ticket allowed to send
+	tickets uint64
+	turn    uint64
+	next    *sync.Cond
+}
+
+func newSendBatcher(client sqsClient, queueURL *string, log *zap.Logger, size, maxBytes int, interval time.Duration) *sendBatcher {
+	b := &sendBatcher{
+		client:   client,
+		queueURL: queueURL,
+		log:      log,
+		size:     size,
+		maxBytes: maxBytes,
+		interval: interval,
+		pending:  make([]*sendEntry, 0, size),
+
+		cancelFlush: defaultCancelFlush,
+	}
+	b.next = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// send adds the message to the current batch and waits for the batch result
+func (b *sendBatcher) send(ctx context.Context, in *sqs.SendMessageInput) error {
+	size := messageSize(in)
+	// a message which doesn't fit into the batch is sent individually
+	if size > b.maxBytes {
+		b.log.Debug("message is larger than max_batch_bytes, sending individually", zap.Int("size", size), zap.Int("max_batch_bytes", b.maxBytes))
+		if !b.ordered {
+			_, err := b.client.SendMessage(ctx, in, withDeadline(ctx, sendDeadlineMargin))
+			return err
+		}
+
+		// the pending messages were submitted earlier, so they go first
+		b.mu.Lock()
+		pending, pt := b.takeLocked()
+		own := b.ticketLocked()
+		b.mu.Unlock()
+
+		if len(pending) > 0 {
+			b.flush(pending, pt)
+		}
+
+		b.await(own)
+		_, err := b.client.SendMessage(ctx, in, withDeadline(ctx, sendDeadlineMargin))
+		b.release()
+		return err
+	}
+
+	entry := &sendEntry{
+		input: in,
+		size:  size,
+		res:   make(chan error, 1),
+	}
+
+	b.mu.Lock()
+	var overflow, full []*sendEntry
+	var ot, ft uint64
+	// flush the current batch before exceeding the byte cap
+	if b.pendingBytes+size > b.maxBytes {
+		overflow, ot = b.takeLocked()
+	}
+
+	b.pending = append(b.pending, entry)
+	b.pendingBytes += size
+
+	if len(b.pending) >= b.size {
+		full, ft = b.takeLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flushPending)
+	}
+	b.mu.Unlock()
+
+	if len(overflow) > 0 {
+		b.flush(overflow, ot)
+	}
+	if len(full) > 0 {
+		b.flush(full, ft)
+	}
+
+	select {
+	case err := <-entry.res:
+		return err
+	case <-ctx.Done():
+		return b.canceled(ctx, entry)
+	}
+}
+
+// canceled flushes the batch with the entry of the canceled caller right away instead of waiting for the timer and
+// reports the result if it arrives within the cancel_flush window. The late result is logged, so the message is never
+// lost silently: it's either sent or the failure is reported (returned or logged).
+func (b *sendBatcher) canceled(ctx context.Context, entry *sendEntry) error {
+	b.mu.Lock()
+	var entries []*sendEntry
+	var t uint64
+	// otherwise the batch is already being flushed
+	if slices.Contains(b.pending, entry) {
+		entries, t = b.takeLocked()
+	}
+	b.mu.Unlock()
+
+	if len(entries) > 0 {
+		go b.flush(entries, t)
+	}
+
+	window := time.NewTimer(b.cancelFlush)
+	defer window.Stop()
+
+	select {
+	case err := <-entry.res:
+		if err != nil {
+			return errors.Errorf("context canceled (%v), the final flush failed: %v", ctx.Err(), err)
+		}
+		b.log.Debug("context canceled, the buffered message was flushed", zap.Error(ctx.Err()))
+		return nil
+	case <-window.C:
+		go b.reportLate(entry)
+		return errors.Errorf("context canceled (%v), the message was not flushed within %s (send_batch.cancel_flush), the result is logged", ctx.Err(), b.cancelFlush)
+	}
+}
+
+// reportLate logs the result of the flush which didn't fit into the cancel_flush window
+func (b *sendBatcher) reportLate(entry *sendEntry) {
+	err := <-entry.res
+	if err != nil {
+		b.log.Error("buffered message of the canceled push was not sent", zap.Error(err))
+		return
+	}
+
+	b.log.Warn("buffered message of the canceled push was sent after the cancel_flush window")
+}
+
+// takeLocked returns the pending entries with the send ticket and resets the batch, should be called under the lock
+func (b *sendBatcher) takeLocked() ([]*sendEntry, uint64) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return nil, 0
+	}
+
+	entries := b.pending
+	b.pending = make([]*sendEntry, 0, b.size)
+	b.pendingBytes = 0
+
+	return entries, b.ticketLocked()
+}
+
+// ticketLocked issues the next send ticket, should be called under the lock
+func (b *sendBatcher) ticketLocked() uint64 {
+	t := b.tickets
+	b.tickets++
+	return t
+}
+
+// await blocks until all the batches taken before the ticket are sent, no-op for the unordered batcher
+func (b *sendBatcher) await(ticket uint64) {
+	if !b.ordered {
+		return
+	}
+
+	b.mu.Lock()
+	for b.turn != ticket {
+		b.next.Wait()
+	}
+	b.mu.Unlock()
+}
+
+// release passes the turn to the next ticket
+func (b *sendBatcher) release() {
+	if !b.ordered {
+		return
+	}
+
+	b.mu.Lock()
+	b.turn++
+	b.next.Broadcast()
+	b.mu.Unlock()
+}
+
+func (b *sendBatcher) flushPending() {
+	b.mu.Lock()
+	entries, t := b.takeLocked()
+	b.mu.Unlock()
+
+	if len(entries) > 0 {
+		b.flush(entries, t)
+	}
+}
+
+// flush sends the entries with a single SendMessageBatch call and reports the per entry results
+func (b *sendBatcher) flush(entries []*sendEntry, ticket uint64) {
+	b.await(ticket)
+	defer b.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchFlushTimeout)
+	defer cancel()
+
+	in := &sqs.SendMessageBatchInput{
+		QueueUrl: b.queueURL,
+		Entries:  make([]types.SendMessageBatchRequestEntry, 0, len(entries)),
+	}
+
+	for i := 0; i < len(entries); i++ {
+		msg := entries[i].input
+		in.Entries = append(in.Entries, types.SendMessageBatchRequestEntry{
+			Id:                     ptr(strconv.Itoa(i)),
+			MessageBody:            msg.MessageBody,
+			DelaySeconds:           msg.DelaySeconds,
+			MessageAttributes:      msg.MessageAttributes,
+			MessageDeduplicationId: msg.MessageDeduplicationId,
+			MessageGroupId:         msg.MessageGroupId,
+		})
+	}
+
+	out, err := sendRetry(ctx, b.log, b.retries, b.budget, "SendMessageBatch", func() (*sqs.SendMessageBatchOutput, error) {
+		return b.client.SendMessageBatch(ctx, in)
+	})
+	if err != nil {
+		for i := 0; i < len(entries); i++ {
+			entries[i].res <- err
+		}
+		return
+	}
+
+	done := make([]bool, len(entries))
+	for i := 0; i < len(out.Successful); i++ {
+		if idx, ok := entryIndex(out.Successful[i].Id, len(entries)); ok {
+			done[idx] = true
+			entries[idx].res <- nil
+		}
+	}
+
+	for i := 0; i < len(out.Failed); i++ {
+		if idx, ok := entryIndex(out.Failed[i].Id, len(entries)); ok && !done[idx] {
+			done[idx] = true
+			entries[idx].res <- errors.Errorf("failed to send the message, code: %s, message: %s, sender fault: %t", getordefault(out.Failed[i].Code), getordefault(out.Failed[i].Message), out.Failed[i].SenderFault)
+		}
+	}
+
+	for i := 0; i < len(done); i++ {
+		if !done[i] {
+			entries[i].res <- errors.Str("no result for the message in the SendMessageBatch response")
+		}
+	}
+}
+
+func entryIndex(id *string, n int) (int, bool) {
+	idx, err := strconv.Atoi(getordefault(id))
+	if err != nil || idx < 0 || idx >= n {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+// messageSize returns the size of the message as calculated by SQS: body + attribute names, types and values
+func messageSize(in *sqs.SendMessageInput) int {
+	size := len(getordefault(in.MessageBody))
+	for name, attr := range in.MessageAttributes {
+		size += len(name) + len(getordefault(attr.DataType)) + len(getordefault(attr.StringValue)) + len(attr.BinaryValue)
+	}
+
+	return size
+}
+
+// initSendBatcher validates the send_batch options and enables the send batching, batch size <= 1 - disabled
+func (c *Driver) initSendBatcher(size, maxBytes, interval, cancelFlush int) error {
+	flushInterval, err := batchWindow(sendBatchOpt, size, interval)
+	if err != nil || flushInterval == 0 {
+		return err
+	}
+
+	if maxBytes == 0 {
+		maxBytes = maxBatchBytes
+	}
+
+	if maxBytes < 0 || maxBytes > maxBatchBytes {
+		return errors.Errorf("send_batch.max_bytes should be in the range 1-%d, provided: %d", maxBatchBytes, maxBytes)
+	}
+
+	if cancelFlush < 0 {
+		return errors.Errorf("send_batch.cancel_flush should not be negative, provided: %d", cancelFlush)
+	}
+
+	c.sendBatch = newSendBatcher(c.client, c.queueURL, c.log, size, maxBytes, flushInterval)
+	if cancelFlush > 0 {
+		c.sendBatch.cancelFlush = time.Duration(cancelFlush) * time.Millisecond
+	}
+	c.sendBatch.ordered = strings.HasSuffix(getordefault(c.queue), fifoSuffix)
+	c.sendBatch.retries = c.netRetries
+	c.sendBatch.budget = c.budget
+
+	// the fan_out_queues are batched the same way, the URLs are resolved on setup
+	for _, t := range c.fanOut {
+		t.batch = newSendBatcher(c.client, t.url, c.log, size, maxBytes, flushInterval)
+		t.batch.cancelFlush = c.sendBatch.cancelFlush
+		t.batch.ordered = c.sendBatch.ordered
+		t.batch.retries = c.netRetries
+		t.batch.budget = c.budget
+	}
+
+	return nil
+}
+
+// batchWindow validates the size and the flush interval of the batch block, returns 0 if the batching is disabled
+func batchWindow(name string, size, interval int) (time.Duration, error) {
+	if size <= 1 {
+		return 0, nil
+	}
+
+	if size > maxBatchEntries {
+		return 0, errors.Errorf("%s.max_size should be in the range 1-%d, provided: %d", name, maxBatchEntries, size)
+	}
+
+	if interval < 0 {
+		return 0, errors.Errorf("%s.flush_interval should not be negative, provided: %d", name, interval)
+	}
+
+	if interval == 0 {
+		return defaultBatchFlushInterval, nil
+	}
+
+	return time.Duration(interval) * time.Millisecond, nil
+}
+
+// pipelineBatch reads the batch block of the pipeline options, the unset values are taken from def
+func pipelineBatch(pipe jobs.Pipeline, name string, def BatchConfig) (BatchConfig, error) {
+	raw := make(map[string]string)
+	err := pipe.Map(name, raw)
+	if err != nil {
+		return def, err
+	}
+
+	for key, dst := range map[string]*int{"max_size": &def.MaxSize, "flush_interval": &def.FlushInterval, "max_bytes": &def.MaxBytes, "cancel_flush": &def.CancelFlush} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return def, errors.Errorf("%s.%s should be an integer, provided: %s", name, key, v)
+		}
+		*dst = n
+	}
+
+	return def, nil
+}
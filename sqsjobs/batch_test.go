@@ -0,0 +1,253 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSendBatcherMaxBytes(t *testing.T) {
+	const limit = 1000
+	fc := newFakeClient()
+	b := newSendBatcher(fc, aws.String("url"), zap.NewNop(), maxBatchEntries, limit, time.Millisecond*20)
+
+	sizes := []int{600, 100, 300, 1500, 100, 900, 50, 400, 700, 2000, 10, 10, 10}
+	wg := sync.WaitGroup{}
+	for _, s := range sizes {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			require.NoError(t, b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String(strings.Repeat("a", s))}))
+		}(s)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, batch := range fc.batches {
+		require.LessOrEqual(t, len(batch.Entries), maxBatchEntries)
+		size := 0
+		for _, e := range batch.Entries {
+			size += len(aws.ToString(e.MessageBody))
+		}
+		require.LessOrEqual(t, size, limit)
+		total += len(batch.Entries)
+	}
+
+	// messages larger than the limit are sent individually
+	require.Equal(t, 2, fc.called("SendMessage"))
+	for _, in := range fc.sent {
+		require.Greater(t, len(aws.ToString(in.MessageBody)), limit)
+	}
+	require.Equal(t, len(sizes)-2, total)
+}
+
+func TestSendBatcherFlushOnSize(t *testing.T) {
+	fc := newFakeClient()
+	// the timer should never fire
+	b := newSendBatcher(fc, aws.String("url"), zap.NewNop(), 2, maxBatchBytes, time.Hour)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("small")}))
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 2, fc.called("SendMessageBatch"))
+}
+
+func TestSendBatcherFlushOnInterval(t *testing.T) {
+	fc := newFakeClient()
+	b := newSendBatcher(fc, aws.String("url"), zap.NewNop(), maxBatchEntries, maxBatchBytes, time.Millisecond*50)
+
+	// the batch is not full, sent once the flush interval passed
+	start := time.Now()
+	require.NoError(t, b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("small")}))
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*50)
+
+	require.Equal(t, 1, fc.called("SendMessageBatch"))
+	require.Equal(t, 0, fc.called("SendMessage"))
+	require.Len(t, fc.batches[0].Entries, 1)
+	require.Equal(t, "small", aws.ToString(fc.batches[0].Entries[0].MessageBody))
+}
+
+func TestSendBatcherPartialFailure(t *testing.T) {
+	fc := newFakeClient()
+	fc.sendBatchFn = func(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+		out := &sqs.SendMessageBatchOutput{}
+		for _, e := range in.Entries {
+			if aws.ToString(e.MessageBody) == "bad" {
+				out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InvalidMessageContents"), SenderFault: true})
+				continue
+			}
+			out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{Id: e.Id})
+		}
+		return out, nil
+	}
+	b := newSendBatcher(fc, aws.String("url"), zap.NewNop(), 2, maxBatchBytes, time.Hour)
+
+	var errGood, errBad error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errGood = b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("good")})
+	}()
+	go func() {
+		defer wg.Done()
+		errBad = b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("bad")})
+	}()
+	wg.Wait()
+
+	require.NoError(t, errGood)
+	require.Error(t, errBad)
+	require.Contains(t, errBad.Error(), "InvalidMessageContents")
+}
+
+func TestInitSendBatcher(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	require.NoError(t, c.initSendBatcher(0, 0, 0, 0))
+	require.Nil(t, c.sendBatch)
+
+	require.Error(t, c.initSendBatcher(11, 0, 0, 0))
+	require.Error(t, c.initSendBatcher(5, maxBatchBytes+1, 0, 0))
+
+	require.NoError(t, c.initSendBatcher(5, 0, 0, 0))
+	require.Equal(t, maxBatchBytes, c.sendBatch.maxBytes)
+	require.Equal(t, defaultBatchFlushInterval, c.sendBatch.interval)
+	require.False(t, c.sendBatch.ordered)
+
+	c.queue = aws.String("orders.fifo")
+	require.NoError(t, c.initSendBatcher(5, 0, 0, 0))
+	require.True(t, c.sendBatch.ordered)
+}
+
+func TestSendBatcherFIFOOrder(t *testing.T) {
+	const total = 20
+
+	fc := newFakeClient()
+	var mu sync.Mutex
+	var seq int
+	sequence := make(map[string]int, total)
+	calls := 0
+	// the earlier batches are slower, the concurrent flushes would be reordered
+	fc.sendBatchFn = func(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+		mu.Lock()
+		calls++
+		delay := time.Millisecond * time.Duration(5*(total-calls))
+		mu.Unlock()
+		time.Sleep(delay)
+
+		mu.Lock()
+		defer mu.Unlock()
+		out := &sqs.SendMessageBatchOutput{}
+		for _, e := range in.Entries {
+			seq++
+			sequence[aws.ToString(e.MessageBody)] = seq
+			out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{Id: e.Id, SequenceNumber: aws.String(strconv.Itoa(seq))})
+		}
+		return out, nil
+	}
+
+	b := newSendBatcher(fc, aws.String("url"), zap.NewNop(), 2, maxBatchBytes, time.Hour)
+	b.ordered = true
+
+	submitted := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return int(b.tickets)*2 + len(b.pending)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String(strconv.Itoa(i)), MessageGroupId: aws.String("group")}))
+		}(i)
+		// submit in order, the next message only after the previous one is batched
+		require.Eventually(t, func() bool { return submitted() == i+1 }, time.Second, time.Millisecond)
+	}
+	wg.Wait()
+
+	require.Equal(t, total/2, fc.called("SendMessageBatch"))
+	for i := 0; i < total; i++ {
+		require.Equal(t, i+1, sequence[strconv.Itoa(i)])
+	}
+}
+
+func TestSendBatcherCancelFlush(t *testing.T) {
+	release := make(chan struct{})
+	var fail, block bool
+	fc := newFakeClient()
+	fc.sendBatchFn = func(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+		if block {
+			<-release
+		}
+		out := &sqs.SendMessageBatchOutput{}
+		for _, e := range in.Entries {
+			if fail {
+				out.Failed = append(out.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InternalError")})
+				continue
+			}
+			out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{Id: e.Id})
+		}
+		return out, nil
+	}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	// the timer never fires within the test, only the cancel flushes
+	b := newSendBatcher(fc, aws.String("url"), zap.New(core), maxBatchEntries, maxBatchBytes, time.Hour)
+	b.cancelFlush = time.Millisecond * 200
+
+	push := func() (error, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(time.Millisecond*20, cancel)
+		other := make(chan error, 1)
+		// another buffered message of the same batch, its caller is still waiting
+		go func() { other <- b.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("other")}) }()
+		require.Eventually(t, func() bool { b.mu.Lock(); defer b.mu.Unlock(); return len(b.pending) == 1 }, time.Second, time.Millisecond)
+		err := b.send(ctx, &sqs.SendMessageInput{MessageBody: aws.String("canceled")})
+		return err, <-other
+	}
+
+	// flushed within the window
+	err, otherErr := push()
+	require.NoError(t, err)
+	require.NoError(t, otherErr)
+	require.Equal(t, 1, fc.called("SendMessageBatch"))
+
+	// the failed flush is reported to the caller
+	fail = true
+	err, otherErr = push()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "InternalError")
+	require.Error(t, otherErr)
+	fail = false
+
+	// the flush outlives the window, the result is logged
+	block = true
+	go func() {
+		time.Sleep(time.Millisecond * 400)
+		close(release)
+	}()
+	err, otherErr = push()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cancel_flush")
+	require.NoError(t, otherErr)
+	require.Eventually(t, func() bool { return logs.FilterMessageSnippet("sent after the cancel_flush window").Len() == 1 }, time.Second*5, time.Millisecond*10)
+}
@@ -0,0 +1,199 @@
+package sqsjobs
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// circuit breaker states
+const (
+	BreakerClosed   string = "closed"
+	BreakerOpen     string = "open"
+	BreakerHalfOpen string = "half_open"
+
+	defaultBreakerCooldown int = 30
+	// breakerProbeWait is how often the pollers check the breaker while another poller probes the queue
+	breakerProbeWait = time.Millisecond * 500
+
+	// receiveErrorBackoff is the first pause of the poller after the failed receive, doubled on every consecutive
+	// failure up to the maxReceiveErrorBackoff
+	receiveErrorBackoff    = time.Millisecond * 200
+	maxReceiveErrorBackoff = time.Second * 20
+
+	// deleteRetries is the number of the additional DeleteMessage attempts on the throttling errors
+	deleteRetries int = 3
+)
+
+// jitter randomizes the backoff in the range [d/2, d), so the pollers and the instances don't retry in lockstep
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+
+	return d/2 + rand.N(d/2) //nolint:gosec
+}
+
+// nextBackoff doubles the backoff up to the max, the first one is the base
+func nextBackoff(d, base, maxBackoff time.Duration) time.Duration {
+	if d == 0 {
+		return base
+	}
+
+	return min(d*2, maxBackoff)
+}
+
+// sleepCtx waits for the duration, returns true if the context is done first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// circuitBreaker pauses all the pollers of the pipeline after the circuit_breaker_threshold consecutive receive
+// failures for the circuit_breaker_cooldown. A single poller probes the queue then (half-open): the breaker is closed
+// on success, opened again on failure.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openUntil time.Time
+	trips     uint64
+	log       *zap.Logger
+	now       func() time.Time
+}
+
+// newCircuitBreaker validates the circuit_breaker options, nil if disabled (threshold 0)
+func newCircuitBreaker(threshold, cooldown int, log *zap.Logger) (*circuitBreaker, error) {
+	if threshold < 0 || cooldown < 0 {
+		return nil, errors.Errorf("circuit_breaker_threshold and circuit_breaker_cooldown should not be negative, provided: %d, %d", threshold, cooldown)
+	}
+
+	if threshold == 0 {
+		if cooldown > 0 {
+			return nil, errors.Str("circuit_breaker_cooldown requires the circuit_breaker_threshold")
+		}
+		return nil, nil
+	}
+
+	if cooldown == 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  time.Duration(cooldown) * time.Second,
+		state:     BreakerClosed,
+		log:       log,
+		now:       time.Now,
+	}, nil
+}
+
+// wait blocks the poller while the breaker is open, returns true if the context is done first
+func (b *circuitBreaker) wait(ctx context.Context) bool {
+	if b == nil {
+		return false
+	}
+
+	for {
+		b.mu.Lock()
+		var d time.Duration
+		switch b.state {
+		case BreakerClosed:
+			b.mu.Unlock()
+			return false
+		case BreakerOpen:
+			d = b.openUntil.Sub(b.now())
+			if d <= 0 {
+				// this poller probes the queue, the rest wait for the result
+				b.state = BreakerHalfOpen
+				b.log.Info("circuit breaker is half-open, probing the queue")
+				b.mu.Unlock()
+				return false
+			}
+		case BreakerHalfOpen:
+			d = breakerProbeWait
+		}
+		b.mu.Unlock()
+
+		if sleepCtx(ctx, d) {
+			return true
+		}
+	}
+}
+
+// failure counts the failed receive, the breaker is opened after the threshold or on the failed probe
+func (b *circuitBreaker) failure(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerOpen || (b.state == BreakerClosed && b.failures < b.threshold) {
+		return
+	}
+
+	b.state = BreakerOpen
+	b.openUntil = b.now().Add(b.cooldown)
+	b.trips++
+	b.log.Warn("circuit breaker is open, polling is paused", zap.Int("consecutive_failures", b.failures), zap.Duration("cooldown", b.cooldown), zap.Error(err))
+}
+
+// success resets the failures, closes the breaker
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != BreakerClosed {
+		b.state = BreakerClosed
+		b.log.Info("circuit breaker is closed, polling is resumed")
+	}
+}
+
+// stats returns the state and the number of the trips, empty if disabled
+func (b *circuitBreaker) stats() (string, uint64) {
+	if b == nil {
+		return "", 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state, b.trips
+}
+
+// throttleRetry calls fn, retrying the throttling errors with the jittered backoff up to the deleteRetries times
+func throttleRetry(ctx context.Context, fn func() error) error {
+	backoff := time.Duration(0)
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= deleteRetries || !isThrottled(err) {
+			return err
+		}
+
+		backoff = nextBackoff(backoff, netRetryBackoff, maxNetRetryBackoff)
+		if sleepCtx(ctx, jitter(backoff)) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b, err := newCircuitBreaker(2, 10, zap.NewNop())
+	require.NoError(t, err)
+	b.now = func() time.Time { return now }
+
+	fail := &smithy.GenericAPIError{Code: "ServiceUnavailable"}
+	b.failure(fail)
+	state, _ := b.stats()
+	require.Equal(t, BreakerClosed, state)
+
+	b.failure(fail)
+	state, trips := b.stats()
+	require.Equal(t, BreakerOpen, state)
+	require.Equal(t, uint64(1), trips)
+
+	// the cooldown is not over yet
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	require.True(t, b.wait(ctx))
+	cancel()
+
+	// half-open, the failed probe opens the breaker again
+	now = now.Add(time.Second * 10)
+	require.False(t, b.wait(context.Background()))
+	state, _ = b.stats()
+	require.Equal(t, BreakerHalfOpen, state)
+	b.failure(fail)
+	state, trips = b.stats()
+	require.Equal(t, BreakerOpen, state)
+	require.Equal(t, uint64(2), trips)
+
+	now = now.Add(time.Second * 10)
+	require.False(t, b.wait(context.Background()))
+	b.success()
+	state, _ = b.stats()
+	require.Equal(t, BreakerClosed, state)
+	require.False(t, b.wait(context.Background()))
+
+	_, err = newCircuitBreaker(0, 5, zap.NewNop())
+	require.Error(t, err)
+	disabled, err := newCircuitBreaker(0, 0, zap.NewNop())
+	require.NoError(t, err)
+	require.Nil(t, disabled)
+	require.False(t, disabled.wait(context.Background()))
+}
+
+func TestReceiveErrorBackoff(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	var calls int32
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, &smithy.GenericAPIError{Code: "InvalidClientTokenId"}
+	}
+
+	stop := runListener(c)
+	time.Sleep(time.Millisecond * 500)
+	stop()
+
+	// 200ms, 400ms, ... instead of the hot loop
+	require.LessOrEqual(t, atomic.LoadInt32(&calls), int32(4))
+	require.GreaterOrEqual(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestDeleteThrottleRetry(t *testing.T) {
+	var calls int
+	err := throttleRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return &smithy.GenericAPIError{Code: "RequestThrottled"}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err = throttleRetry(context.Background(), func() error {
+		calls++
+		return &smithy.GenericAPIError{Code: "ReceiptHandleIsInvalid"}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	for range 100 {
+		d := jitter(time.Second)
+		require.GreaterOrEqual(t, d, time.Millisecond*500)
+		require.Less(t, d, time.Second)
+	}
+}
@@ -0,0 +1,163 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// defaultRetryBudgetRefill is the number of the retry tokens restored per second
+const defaultRetryBudgetRefill int = 1
+
+var errRetryBudgetExhausted = stderr.New("retry budget exhausted, the request is not retried")
+
+// retryBudget is the token bucket shared by all the retries of the pipeline (the SDK retryer and the network retries),
+// every retry attempt takes a token, the first attempts are free. A burst of failures can't multiply the API load:
+// once the bucket is empty the requests fail fast until the tokens are refilled.
+type retryBudget struct {
+	mu     sync.Mutex
+	size   float64
+	refill float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newRetryBudget creates the full bucket, nil if disabled (size <= 0)
+func newRetryBudget(size, refill int) *retryBudget {
+	if size <= 0 {
+		return nil
+	}
+
+	if refill <= 0 {
+		refill = defaultRetryBudgetRefill
+	}
+
+	return &retryBudget{
+		size:   float64(size),
+		refill: float64(refill),
+		tokens: float64(size),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// take returns false if the budget is exhausted, nil-safe (no budget - always allowed)
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = min(b.size, b.tokens+now.Sub(b.last).Seconds()*b.refill)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// budgetRetryer wraps the client's retryer and refuses to schedule a retry attempt when the budget is exhausted
+type budgetRetryer struct {
+	aws.Retryer
+	budget *retryBudget
+}
+
+func (r *budgetRetryer) GetRetryToken(ctx context.Context, opErr error) (func(error) error, error) {
+	if !r.budget.take() {
+		return nil, stderr.Join(errRetryBudgetExhausted, opErr)
+	}
+
+	return r.Retryer.GetRetryToken(ctx, opErr)
+}
+
+// budgetClient applies the retry budget to every call of the wrapped client, the budget outlives the client rotation
+type budgetClient struct {
+	sqsClient
+	budget *retryBudget
+}
+
+// withRetryBudget wraps the client, the client is returned as is if the budget is disabled
+func withRetryBudget(client sqsClient, budget *retryBudget) sqsClient {
+	if budget == nil {
+		return client
+	}
+
+	return &budgetClient{sqsClient: client, budget: budget}
+}
+
+func (b *budgetClient) withBudget(optFns []func(*sqs.Options)) []func(*sqs.Options) {
+	// the caller's slice is never modified
+	return append(optFns[:len(optFns):len(optFns)], func(o *sqs.Options) {
+		if o.Retryer == nil {
+			return
+		}
+
+		o.Retryer = &budgetRetryer{
+			Retryer: o.Retryer,
+			budget:  b.budget,
+		}
+	})
+}
+
+func (b *budgetClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	return b.sqsClient.SendMessage(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	return b.sqsClient.SendMessageBatch(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return b.sqsClient.ReceiveMessage(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return b.sqsClient.ChangeMessageVisibility(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return b.sqsClient.DeleteMessage(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	return b.sqsClient.DeleteMessageBatch(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	return b.sqsClient.CreateQueue(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return b.sqsClient.GetQueueUrl(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	return b.sqsClient.DeleteQueue(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	return b.sqsClient.GetQueueAttributes(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	return b.sqsClient.SetQueueAttributes(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	return b.sqsClient.ListQueueTags(ctx, params, b.withBudget(optFns)...)
+}
+
+func (b *budgetClient) TagQueue(ctx context.Context, params *sqs.TagQueueInput, optFns ...func(*sqs.Options)) (*sqs.TagQueueOutput, error) {
+	return b.sqsClient.TagQueue(ctx, params, b.withBudget(optFns)...)
+}
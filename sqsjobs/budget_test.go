@@ -0,0 +1,77 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRetryBudget(t *testing.T) {
+	require.Nil(t, newRetryBudget(0, 1))
+	require.True(t, (*retryBudget)(nil).take())
+
+	now := time.Now()
+	b := newRetryBudget(2, 0)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// network retries stop once the budget is drained
+	calls := 0
+	_, err := netRetry(context.Background(), zap.NewNop(), 5, b, "SendMessage", func() (any, error) {
+		calls++
+		return nil, connReset()
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	_, err = netRetry(context.Background(), zap.NewNop(), 5, b, "SendMessage", func() (any, error) {
+		calls++
+		return nil, connReset()
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	// the SDK retries share the same budget
+	r := &budgetRetryer{Retryer: retry.NewStandard(), budget: b}
+	_, err = r.GetRetryToken(context.Background(), connReset())
+	require.ErrorIs(t, err, errRetryBudgetExhausted)
+
+	// resumed after the refill (default: 1 token per second)
+	now = now.Add(time.Second)
+	_, err = r.GetRetryToken(context.Background(), connReset())
+	require.NoError(t, err)
+	require.False(t, b.take())
+
+	now = now.Add(time.Minute)
+	calls = 0
+	_, err = netRetry(context.Background(), zap.NewNop(), 5, b, "SendMessage", func() (any, error) {
+		calls++
+		return nil, connReset()
+	})
+	require.Error(t, err)
+	// refilled up to the size only
+	require.Equal(t, 3, calls)
+}
+
+func TestRetryBudgetClient(t *testing.T) {
+	fc := newFakeClient()
+	require.True(t, withRetryBudget(fc, nil) == sqsClient(fc))
+
+	client := withRetryBudget(fc, newRetryBudget(1, 1))
+	o := sqs.Options{Retryer: retry.NewStandard()}
+	for _, fn := range client.(*budgetClient).withBudget(nil) {
+		fn(&o)
+	}
+	_, ok := o.Retryer.(*budgetRetryer)
+	require.True(t, ok)
+
+	_, err := client.SendMessage(context.Background(), &sqs.SendMessageInput{})
+	require.NoError(t, err)
+	require.Equal(t, 1, fc.called("SendMessage"))
+}
@@ -0,0 +1,83 @@
+package sqsjobs
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// md5SendAttempts is the number of the sends (including the first one) while the returned MD5 doesn't match (verify_md5)
+const md5SendAttempts = 3
+
+// verifySendMD5 compares the MD5 digests of the body and the message attributes returned by SendMessage with the local ones.
+// The digests absent in the response are not checked.
+func verifySendMD5(in *sqs.SendMessageInput, out *sqs.SendMessageOutput) error {
+	if out == nil {
+		return nil
+	}
+
+	if out.MD5OfMessageBody != nil {
+		if local := md5OfBody(getordefault(in.MessageBody)); local != *out.MD5OfMessageBody {
+			return errors.Errorf("MD5 of the message body mismatch: local %s, returned %s", local, *out.MD5OfMessageBody)
+		}
+	}
+
+	if out.MD5OfMessageAttributes != nil && len(in.MessageAttributes) > 0 {
+		if local := md5OfAttributes(in.MessageAttributes); local != *out.MD5OfMessageAttributes {
+			return errors.Errorf("MD5 of the message attributes mismatch: local %s, returned %s", local, *out.MD5OfMessageAttributes)
+		}
+	}
+
+	return nil
+}
+
+// skipSDKChecksum disables the SDK checksum validation, the mismatch is handled (retried) by the driver
+func skipSDKChecksum(o *sqs.Options) {
+	o.DisableMessageChecksumValidation = true
+}
+
+func md5OfBody(body string) string {
+	sum := md5.Sum([]byte(body)) //nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
+
+// md5OfAttributes calculates the digest the same way as SQS: the attributes are sorted by name, every name, data type
+// and value is prefixed with its 4-byte length, the value - with the transport type (1 - string, 2 - binary)
+func md5OfAttributes(attrs map[string]types.MessageAttributeValue) string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := md5.New() //nolint:gosec
+	for _, name := range names {
+		v := attrs[name]
+		md5Field(h, []byte(name))
+		md5Field(h, []byte(getordefault(v.DataType)))
+
+		switch {
+		case v.StringValue != nil:
+			_, _ = h.Write([]byte{1})
+			md5Field(h, []byte(*v.StringValue))
+		case v.BinaryValue != nil:
+			_, _ = h.Write([]byte{2})
+			md5Field(h, v.BinaryValue)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func md5Field(h hash.Hash, b []byte) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(b))) //nolint:gosec
+	_, _ = h.Write(l[:])
+	_, _ = h.Write(b)
+}
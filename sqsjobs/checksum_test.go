@@ -0,0 +1,72 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyMD5Retry(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.verifyMD5 = true
+
+	in := &sqs.SendMessageInput{
+		QueueUrl:    c.queueURL,
+		MessageBody: aws.String("hello"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"rr_job": {DataType: aws.String(StringType), StringValue: aws.String("job")},
+			"blob":   {DataType: aws.String(BinaryType), BinaryValue: []byte{1, 2, 3}},
+		},
+	}
+
+	calls := 0
+	fc := newFakeClient()
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		calls++
+		body := md5OfBody("hello")
+		if calls == 1 {
+			// corrupted in transit
+			body = md5OfBody("hellp")
+		}
+		return &sqs.SendMessageOutput{MessageId: aws.String("1"), MD5OfMessageBody: aws.String(body), MD5OfMessageAttributes: aws.String(md5OfAttributes(in.MessageAttributes))}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.sendPrimary(context.Background(), in))
+	require.Equal(t, 2, fc.called("SendMessage"))
+
+	// the attributes mismatch on every attempt fails the send
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		return &sqs.SendMessageOutput{MessageId: aws.String("2"), MD5OfMessageBody: aws.String(md5OfBody("hello")), MD5OfMessageAttributes: aws.String(md5OfBody(""))}, nil
+	}
+	err := c.sendPrimary(context.Background(), in)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "MD5 of the message attributes mismatch")
+	require.Equal(t, 2+md5SendAttempts, fc.called("SendMessage"))
+
+	// disabled - the response is not checked
+	c.verifyMD5 = false
+	require.NoError(t, c.sendPrimary(context.Background(), in))
+}
+
+func TestMD5Digests(t *testing.T) {
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", md5OfBody("hello"))
+
+	a := map[string]types.MessageAttributeValue{
+		"a": {DataType: aws.String(StringType), StringValue: aws.String("1")},
+		"b": {DataType: aws.String(NumberType), StringValue: aws.String("2")},
+	}
+	// the order of the attributes doesn't matter, the values do
+	b := map[string]types.MessageAttributeValue{"b": a["b"], "a": a["a"]}
+	require.Equal(t, md5OfAttributes(a), md5OfAttributes(b))
+	b["a"] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String("11")}
+	require.NotEqual(t, md5OfAttributes(a), md5OfAttributes(b))
+
+	// absent digests are not checked
+	require.NoError(t, verifySendMD5(&sqs.SendMessageInput{MessageBody: aws.String("x")}, &sqs.SendMessageOutput{}))
+	require.NoError(t, verifySendMD5(&sqs.SendMessageInput{}, nil))
+}
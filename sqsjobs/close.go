@@ -0,0 +1,123 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// Close tears the driver down synchronously, e.g. when the driver is embedded outside RoadRunner: stops receiving,
+// waits for the in-flight messages and the pollers, flushes the pending send batch, stops the pipeline and the dispatcher
+// and closes the idle HTTP connections. All steps are bounded by the context. Close is idempotent, the concurrent
+// calls wait for the first one and return its result.
+func (c *Driver) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.close(ctx)
+	})
+
+	return c.closeErr
+}
+
+func (c *Driver) close(ctx context.Context) error {
+	const op = errors.Op("sqs_close")
+	start := time.Now().UTC()
+	pipe := *c.pipeline.Load()
+
+	// pause the listeners and wait for the acks/nacks of the dispatched messages
+	inFlight, err := c.Drain(ctx)
+	if err != nil {
+		c.log.Debug("failed to pause the listeners on close", zap.Error(err))
+	}
+
+	// the listeners might be paused already, cancel them anyway
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	c.cond.Broadcast()
+
+	err = waitFor(ctx, func() bool { return atomic.LoadInt32(&c.activePollers) == 0 })
+	if err != nil {
+		return errors.E(op, errors.Errorf("pollers did not exit: %v", err))
+	}
+
+	// nacked/requeued messages might be still in the batch
+	if c.sendBatch != nil {
+		c.sendBatch.flushPending()
+	}
+	c.flushFanOut()
+	if c.deleteBatch != nil {
+		c.deleteBatch.flushPending()
+	}
+
+	err = c.Stop(ctx)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if c.dispatchDone != nil {
+		err = waitFor(ctx, func() bool {
+			select {
+			case <-c.dispatchDone:
+				return true
+			default:
+				return false
+			}
+		})
+		if err != nil {
+			return errors.E(op, errors.Errorf("dispatcher did not exit: %v", err))
+		}
+	}
+
+	c.log.Debug("pipeline was closed", zap.String("pipeline", pipe.Name()), zap.Int64("in_flight", inFlight), zap.Time("start", start), zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// waitFor polls the condition until it's true or the context is done
+func waitFor(ctx context.Context, cond func() bool) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for !cond() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// releaseClients flushes the pending batches and closes the idle connections of the pipeline clients on stop, so the
+// pipeline destroyed at runtime (the jobs Destroy RPC) doesn't keep the connections open
+func (c *Driver) releaseClients() {
+	if c.sendBatch != nil {
+		c.sendBatch.flushPending()
+	}
+	c.flushFanOut()
+	if c.deleteBatch != nil {
+		c.deleteBatch.flushPending()
+	}
+
+	closeIdleConnections(c.client)
+	if c.failover != nil {
+		closeIdleConnections(c.failover.client)
+	}
+}
+
+// closeIdleConnections closes the idle connections of the SQS client HTTP transport (if supported)
+func closeIdleConnections(client sqsClient) {
+	opts, ok := clientOptions(client)
+	if !ok {
+		return
+	}
+
+	if hc, ok := opts.HTTPClient.(interface{ CloseIdleConnections() }); ok {
+		hc.CloseIdleConnections()
+	}
+}
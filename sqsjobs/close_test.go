@@ -0,0 +1,131 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCloseFlushesAndWaits(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String("{}")})
+	c.client = fc
+	c.initDispatcher(dispatchBufferSize(1))
+	// the timer should never fire, the batch is flushed by Close
+	c.sendBatch = newSendBatcher(fc, c.queueURL, zap.NewNop(), maxBatchEntries, maxBatchBytes, time.Hour)
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, c.sendBatch.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("body")}))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		c.sendBatch.mu.Lock()
+		defer c.sendBatch.mu.Unlock()
+		return len(c.sendBatch.pending) == 3
+	}, time.Second*5, time.Millisecond*10)
+
+	res := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			res <- c.Close(context.Background())
+		}()
+	}
+
+	// waiting for the in-flight message
+	time.Sleep(time.Millisecond * 100)
+	select {
+	case <-res:
+		t.Fatal("close returned before the in-flight message was acknowledged")
+	default:
+	}
+
+	item, ok := pq.ExtractMin().(*Item)
+	require.True(t, ok)
+	require.NoError(t, item.Ack())
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-res:
+			require.NoError(t, err)
+		case <-time.After(time.Second * 5):
+			t.Fatal("close didn't return")
+		}
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+	require.Equal(t, 1, fc.called("SendMessageBatch"))
+	require.Len(t, fc.batches[0].Entries, 3)
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.activePollers))
+	select {
+	case <-c.dispatchDone:
+	default:
+		t.Fatal("dispatcher is still running")
+	}
+
+	// idempotent
+	require.NoError(t, c.Close(context.Background()))
+}
+
+func TestCloseTimeout(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	blocked := make(chan struct{})
+	defer close(blocked)
+	fc := newFakeClient()
+	// the receive ignores the cancellation
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		<-blocked
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return fc.called("ReceiveMessage") > 0
+	}, time.Second*5, time.Millisecond*10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	require.Error(t, c.Close(ctx))
+}
+
+func TestStopFlushesPendingDeletes(t *testing.T) {
+	fc := newFakeClient()
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = fc
+	// the timer should never fire, the pending delete is flushed by the stop (jobs Destroy)
+	c.deleteBatch = newDeleteBatcher(fc, aws.String("url"), zap.NewNop(), maxBatchEntries, time.Hour)
+
+	deleted := make(chan error, 1)
+	go func() {
+		deleted <- c.deleteBatch.delete(context.Background(), aws.String("handle"))
+	}()
+	require.Eventually(t, func() bool {
+		c.deleteBatch.mu.Lock()
+		defer c.deleteBatch.mu.Unlock()
+		return len(c.deleteBatch.pending) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, c.Stop(context.Background()))
+	require.NoError(t, <-deleted)
+	require.Equal(t, 1, fc.called("DeleteMessageBatch"))
+}
@@ -0,0 +1,318 @@
+package sqsjobs
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// ContentEncodingAttr is the message attribute set on the compressed messages, such messages are decompressed
+	// on receive regardless of the compression option
+	ContentEncodingAttr string = "Content-Encoding"
+	// ContentDictionaryAttr is the ID of the preset dictionary (gzip_dictionary) of the deflate-dict messages
+	ContentDictionaryAttr string = "Content-Dictionary"
+	// the built-in compression
+	compressionGzip string = "gzip"
+	// the gzip compression with the preset dictionary, the gzip format has no dictionary field: the raw DEFLATE is used
+	compressionDeflateDict string = "deflate-dict"
+	// zstd requires the registered codec (RegisterCodec or the CodecProvider plugin), checked on the pipeline start
+	compressionZstd string = "zstd"
+	// defaultGzipLevel is the balance between the CPU and the ratio
+	defaultGzipLevel int = 6
+	// maxGzipDictionary is the DEFLATE window, the larger dictionary is not used in full
+	maxGzipDictionary int = 32 << 10
+	// defaultMaxDecompressed is the max_decompressed_size default (in bytes)
+	defaultMaxDecompressed int = 64 << 20
+)
+
+// Codec compresses the message bodies for the compression option, e.g. zstd. The compressed messages are marked with
+// the codec name in the Content-Encoding attribute and decompressed by the codec registered with the same name.
+type Codec interface {
+	Compress(body []byte) ([]byte, error)
+	Decompress(body []byte) ([]byte, error)
+}
+
+// RegisterCodec registers (or overrides) the compression codec by the Content-Encoding name, e.g. zstd, nil removes it.
+// The built-in gzip can't be overridden.
+func (c *Driver) RegisterCodec(name string, codec Codec) {
+	c.decodersMu.Lock()
+	defer c.decodersMu.Unlock()
+
+	if codec == nil {
+		delete(c.codecs, name)
+		return
+	}
+
+	if c.codecs == nil {
+		c.codecs = make(map[string]Codec, 1)
+	}
+
+	c.codecs[name] = codec
+}
+
+// codec returns the registered codec, nil if not registered
+func (c *Driver) codec(name string) Codec {
+	c.decodersMu.RLock()
+	defer c.decodersMu.RUnlock()
+
+	return c.codecs[name]
+}
+
+// checkCodec returns an error if the compression requires a codec which is not registered
+func (c *Driver) checkCodec() error {
+	if c.compression == "" || c.compression == compressionGzip || c.codec(c.compression) != nil {
+		return nil
+	}
+
+	return errors.Errorf("no codec registered for the compression: %s (RegisterCodec or the CodecProvider plugin)", c.compression)
+}
+
+// checkCompression validates the compression and gzip_level options, returns the gzip level (0 - default)
+func checkCompression(compression string, level int) (string, int, error) {
+	switch compression {
+	case "", compressionGzip:
+	case compressionZstd:
+		if level != 0 {
+			return "", 0, errors.Str("gzip_level requires the gzip compression")
+		}
+		return compression, 0, nil
+	default:
+		return "", 0, errors.Errorf("unknown compression: %s, supported: gzip, zstd", compression)
+	}
+
+	if level == 0 {
+		return compression, defaultGzipLevel, nil
+	}
+
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return "", 0, errors.Errorf("gzip_level should be in the range 1-9, provided: %d", level)
+	}
+
+	return compression, level, nil
+}
+
+// gzipDictionary reads the preset dictionary of the gzip compression, returns the dictionary and its ID
+// (the Content-Dictionary attribute), nil if the path is empty
+func gzipDictionary(path, compression string) ([]byte, string, error) {
+	if path == "" {
+		return nil, "", nil
+	}
+
+	if compression != compressionGzip {
+		return nil, "", errors.Str("gzip_dictionary requires the gzip compression")
+	}
+
+	dict, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", errors.Errorf("failed to read the gzip dictionary: %v", err)
+	}
+
+	if len(dict) == 0 || len(dict) > maxGzipDictionary {
+		return nil, "", errors.Errorf("gzip dictionary %s should be 1-%d bytes, provided: %d", path, maxGzipDictionary, len(dict))
+	}
+
+	sum := sha256.Sum256(dict)
+	return dict, hex.EncodeToString(sum[:8]), nil
+}
+
+// checkMaxDecompressed validates the max_decompressed_size (in bytes), 0 - the default one
+func checkMaxDecompressed(size int) (int, error) {
+	if size < 0 {
+		return 0, errors.Errorf("max_decompressed_size should not be negative, provided: %d", size)
+	}
+
+	if size == 0 {
+		return defaultMaxDecompressed, nil
+	}
+
+	return size, nil
+}
+
+// decompressLimit returns the max_decompressed_size
+func (c *Driver) decompressLimit() int {
+	if c.maxDecompressed <= 0 {
+		return defaultMaxDecompressed
+	}
+
+	return c.maxDecompressed
+}
+
+// checkCompressionThreshold validates the compression_threshold (in bytes), 0 - all the bodies are compressed
+func checkCompressionThreshold(threshold int) (int, error) {
+	if threshold < 0 || threshold > maxMessageBytes {
+		return 0, errors.Errorf("compression_threshold should be in the range [0, %d] bytes, provided: %d", maxMessageBytes, threshold)
+	}
+
+	return threshold, nil
+}
+
+// compressBody compresses the message body and marks the message with the Content-Encoding attribute,
+// no-op if the compression is not set or the body is below the compression_threshold. The compressed body is
+// base64 encoded (encodeBody).
+func (c *Driver) compressBody(in *sqs.SendMessageInput) error {
+	if c.compression == "" || len(getordefault(in.MessageBody)) < c.compressThreshold {
+		return nil
+	}
+
+	var out []byte
+	var err error
+	encoding := c.compression
+	switch {
+	case c.compression == compressionGzip && c.gzipDict != nil:
+		encoding = compressionDeflateDict
+		out, err = deflateDictBody([]byte(getordefault(in.MessageBody)), c.gzipLevel, c.gzipDict)
+	case c.compression == compressionGzip:
+		out, err = gzipBody([]byte(getordefault(in.MessageBody)), c.gzipLevel)
+	default:
+		codec := c.codec(c.compression)
+		if codec == nil {
+			return c.checkCodec()
+		}
+		out, err = codec.Compress([]byte(getordefault(in.MessageBody)))
+	}
+	if err != nil {
+		return err
+	}
+
+	in.MessageBody = aws.String(string(out))
+
+	if in.MessageAttributes == nil {
+		in.MessageAttributes = make(map[string]types.MessageAttributeValue, 2)
+	}
+	in.MessageAttributes[ContentEncodingAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(encoding)}
+	if encoding == compressionDeflateDict {
+		in.MessageAttributes[ContentDictionaryAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(c.gzipDictID)}
+	}
+	return nil
+}
+
+// decompressBody decompresses the body of the messages with the Content-Encoding attribute,
+// the bodies above the max_decompressed_size are rejected
+func (c *Driver) decompressBody(body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+	val, ok := attrs[ContentEncodingAttr]
+	if !ok || val.StringValue == nil {
+		return body, nil
+	}
+
+	limit := c.decompressLimit()
+	switch *val.StringValue {
+	case "", "identity":
+		return body, nil
+	case compressionGzip:
+		return gunzipBody(body, limit)
+	case compressionDeflateDict:
+		id, ok := attrs[ContentDictionaryAttr]
+		if c.gzipDict == nil || !ok || getordefault(id.StringValue) != c.gzipDictID {
+			return nil, errors.Errorf("message body is compressed with an unknown gzip dictionary: %s", getordefault(id.StringValue))
+		}
+		return inflateDictBody(body, c.gzipDict, limit)
+	}
+
+	codec := c.codec(*val.StringValue)
+	if codec == nil {
+		return nil, errors.Errorf("unsupported %s: %s", ContentEncodingAttr, *val.StringValue)
+	}
+
+	out, err := codec.Decompress(body)
+	if err != nil {
+		return nil, errors.Errorf("message body is not a valid %s: %v", *val.StringValue, err)
+	}
+
+	if len(out) > limit {
+		return nil, errors.Errorf("decompressed message body exceeds the max_decompressed_size (%d bytes)", limit)
+	}
+
+	return out, nil
+}
+
+func gzipBody(body []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gunzipBody(body []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Errorf("message body is not a valid gzip: %v", err)
+	}
+
+	out, err := readLimited(r, limit)
+	if err != nil {
+		return nil, errors.Errorf("message body is not a valid gzip: %v", err)
+	}
+
+	return out, nil
+}
+
+func deflateDictBody(body []byte, level int, dict []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, level, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func inflateDictBody(body, dict []byte, limit int) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(body), dict)
+	defer func() {
+		_ = r.Close()
+	}()
+
+	out, err := readLimited(r, limit)
+	if err != nil {
+		return nil, errors.Errorf("message body is not a valid %s: %v", compressionDeflateDict, err)
+	}
+
+	return out, nil
+}
+
+// readLimited reads the decompressed body, fails above the limit (in bytes) instead of reading a decompression bomb in full
+func readLimited(r io.Reader, limit int) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out) > limit {
+		return nil, errors.Errorf("decompressed body exceeds the max_decompressed_size (%d bytes)", limit)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,187 @@
+package sqsjobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipLevelRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	payload := bytes.Repeat([]byte(`{"id":1,"name":"roadrunner"},`), 100)
+	// gzip header XFL: 2 - best compression, 4 - best speed
+	for level, xfl := range map[int]byte{9: 2, 1: 4} {
+		var err error
+		c.compression, c.gzipLevel, err = checkCompression(compressionGzip, level)
+		require.NoError(t, err)
+
+		fc.sent = nil
+		require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: payload, headers: map[string][]string{}, Options: &Options{}}))
+
+		require.Len(t, fc.sent, 1)
+		sent := fc.sent[0]
+		require.Equal(t, compressionGzip, aws.ToString(sent.MessageAttributes[ContentEncodingAttr].StringValue))
+		require.Equal(t, encodingBase64, aws.ToString(sent.MessageAttributes[ContentTransferEncodingAttr].StringValue))
+
+		raw, err := base64.StdEncoding.DecodeString(aws.ToString(sent.MessageBody))
+		require.NoError(t, err)
+		require.Equal(t, xfl, raw[8])
+		require.Less(t, len(raw), len(payload))
+
+		out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+		require.NoError(t, err)
+		require.Equal(t, payload, out.Payload)
+	}
+
+	// decompressed by the attribute regardless of the pipeline option
+	c.compression = ""
+	sent := fc.sent[0]
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, payload, out.Payload)
+
+	_, level, err := checkCompression(compressionGzip, 0)
+	require.NoError(t, err)
+	require.Equal(t, defaultGzipLevel, level)
+
+	_, _, err = checkCompression(compressionGzip, 10)
+	require.Error(t, err)
+	_, _, err = checkCompression("brotli", 0)
+	require.Error(t, err)
+	_, _, err = checkCompression(compressionZstd, 3)
+	require.Error(t, err)
+}
+
+// reverseCodec is the test codec, reverses the body
+type reverseCodec struct{}
+
+func (reverseCodec) Compress(body []byte) ([]byte, error) {
+	out := bytes.Clone(body)
+	slices.Reverse(out)
+	return out, nil
+}
+
+func (r reverseCodec) Decompress(body []byte) ([]byte, error) {
+	return r.Compress(body)
+}
+
+func TestCompressionCodec(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	var err error
+	c.compression, c.gzipLevel, err = checkCompression(compressionZstd, 0)
+	require.NoError(t, err)
+	c.compressThreshold, err = checkCompressionThreshold(10)
+	require.NoError(t, err)
+
+	// not registered
+	require.Error(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("0123456789"), headers: map[string][]string{}, Options: &Options{}}))
+	require.Error(t, c.checkCodec())
+
+	c.RegisterCodec(compressionZstd, reverseCodec{})
+	require.NoError(t, c.checkCodec())
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("0123456789"), headers: map[string][]string{}, Options: &Options{}}))
+	// below the threshold
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("short"), headers: map[string][]string{}, Options: &Options{}}))
+
+	require.Len(t, fc.sent, 2)
+	sent := fc.sent[0]
+	require.Equal(t, compressionZstd, aws.ToString(sent.MessageAttributes[ContentEncodingAttr].StringValue))
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789"), out.Payload)
+
+	_, ok := fc.sent[1].MessageAttributes[ContentEncodingAttr]
+	require.False(t, ok)
+	require.Equal(t, "short", aws.ToString(fc.sent[1].MessageBody))
+
+	// the codec is removed, the message can't be decompressed
+	c.RegisterCodec(compressionZstd, nil)
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.Error(t, err)
+
+	_, err = checkCompressionThreshold(-1)
+	require.Error(t, err)
+}
+
+func TestGzipDictionary(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	path := filepath.Join(t.TempDir(), "dict")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":,"name":"roadrunner"}`), 0o600))
+
+	var err error
+	c.compression, c.gzipLevel, err = checkCompression(compressionGzip, 0)
+	require.NoError(t, err)
+	c.gzipDict, c.gzipDictID, err = gzipDictionary(path, c.compression)
+	require.NoError(t, err)
+
+	payload := []byte(`{"id":1,"name":"roadrunner"}`)
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: payload, headers: map[string][]string{}, Options: &Options{}}))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, compressionDeflateDict, aws.ToString(sent.MessageAttributes[ContentEncodingAttr].StringValue))
+	require.Equal(t, c.gzipDictID, aws.ToString(sent.MessageAttributes[ContentDictionaryAttr].StringValue))
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, payload, out.Payload)
+
+	// another dictionary
+	c.gzipDictID = "other"
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.Error(t, err)
+
+	_, _, err = gzipDictionary(path, compressionZstd)
+	require.Error(t, err)
+	_, _, err = gzipDictionary(filepath.Join(t.TempDir(), "missing"), compressionGzip)
+	require.Error(t, err)
+	dict, _, err := gzipDictionary("", compressionGzip)
+	require.NoError(t, err)
+	require.Nil(t, dict)
+}
+
+func TestMaxDecompressedSize(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	body, err := gzipBody(bytes.Repeat([]byte("a"), 1024), defaultGzipLevel)
+	require.NoError(t, err)
+	attrs := map[string]types.MessageAttributeValue{ContentEncodingAttr: {DataType: aws.String(StringType), StringValue: aws.String(compressionGzip)}}
+
+	c.maxDecompressed = 1024
+	out, err := c.decompressBody(body, attrs)
+	require.NoError(t, err)
+	require.Len(t, out, 1024)
+
+	c.maxDecompressed = 1023
+	_, err = c.decompressBody(body, attrs)
+	require.Error(t, err)
+
+	// the codec output is limited as well
+	c.RegisterCodec(compressionZstd, reverseCodec{})
+	_, err = c.decompressBody(bytes.Repeat([]byte("a"), 1024), map[string]types.MessageAttributeValue{ContentEncodingAttr: {DataType: aws.String(StringType), StringValue: aws.String(compressionZstd)}})
+	require.Error(t, err)
+
+	size, err := checkMaxDecompressed(0)
+	require.NoError(t, err)
+	require.Equal(t, defaultMaxDecompressed, size)
+	_, err = checkMaxDecompressed(-1)
+	require.Error(t, err)
+}
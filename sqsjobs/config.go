@@ -2,6 +2,7 @@ package sqsjobs
 
 import (
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 )
@@ -15,9 +16,161 @@ const (
 	messageGroupID       string = "message_group_id"
 	waitTime             string = "wait_time"
 	skipQueueDeclaration string = "skip_queue_declaration"
+	profile              string = "profile"
+	dispatchBuffer       string = "dispatch_buffer"
+	bodyFormat           string = "body_format"
+	maxMessageAge        string = "max_message_age"
+	messageAgeSkew       string = "message_age_skew"
+	skipPermissionCheck  string = "skip_permission_check"
+	batchSize            string = "batch_size"
+	batchFlushInterval   string = "batch_flush_interval"
+	maxBatchBytesOpt     string = "max_batch_bytes"
+	deadLetterQueue      string = "dead_letter_queue"
+	dlqEnrichMetadata    string = "dlq_enrich_metadata"
+	awsLogMode           string = "aws_log_mode"
+	queuePrefix          string = "queue_prefix"
+	strictGroupOrdering  string = "strict_group_ordering"
+	setupTimeout         string = "setup_timeout"
+	pollers              string = "pollers"
+	metadataMode         string = "metadata_mode"
+	dedupWindow          string = "dedup_window"
+	dedupDelete          string = "dedup_delete"
+	dedupAttribute       string = "dedup_attribute"
+	dedupMaxEntries      string = "dedup_max_entries"
+	priorityAttribute    string = "priority_attribute"
+	delayAttribute       string = "delay_attribute"
+	jobAttribute         string = "job_attribute"
+	emptyBodyPolicy      string = "empty_body_policy"
+	skipWarmup           string = "skip_warmup"
+	sseManaged           string = "sse_managed"
+	scaleToZeroIdle      string = "scale_to_zero_idle"
+	deleteOnStop         string = "delete_on_stop"
+	queueReadyTimeout    string = "queue_ready_timeout"
+	propagateHeaders     string = "propagate_headers"
+	redactHeaders        string = "redact_headers"
+	partitionKeyOpt      string = "partition_key_attribute"
+	encryptionKey        string = "encryption_key"
+	encryptionKeyEnv     string = "encryption_key_env"
+	deliveryMode         string = "delivery"
+	retryQueue           string = "retry_queue"
+	retryDelay           string = "retry_delay"
+	splitArrays          string = "split_oversized_arrays"
+	clientMaxLifetime    string = "client_max_lifetime"
+	workerTimeout        string = "worker_timeout"
+	visibilityMargin     string = "visibility_margin"
+	autoAdjustVisibility string = "auto_adjust_visibility"
+	correlationAttribute string = "correlation_attribute"
+	usePriorityQueue     string = "use_priority_queue"
+	networkRetries       string = "network_retries"
+	preserveAttrTypes    string = "preserve_attribute_types"
+	executeAtAttribute   string = "execute_at_attribute"
+	dnsCacheTTL          string = "dns_cache_ttl"
+	httpClientOpt        string = "http_client"
+	queueOwnerAccountID  string = "queue_owner_account_id"
+	adaptiveMinPollers   string = "adaptive_min_pollers"
+	adaptiveMaxPollers   string = "adaptive_max_pollers"
+	fastRequeueShutdown  string = "fast_requeue_on_shutdown"
+	endpointDiscovery    string = "endpoint_discovery"
+	handlerTimeout       string = "handler_timeout"
+	sourceQueueHeader    string = "source_queue_header"
+	retryBudgetOpt       string = "retry_budget"
+	retryBudgetRefill    string = "retry_budget_refill"
+	queueEventsBuffer    string = "queue_events_buffer"
+	eventsBuffer         string = "events_buffer"
+	failoverQueue        string = "failover_queue"
+	failoverRegion       string = "failover_region"
+	failoverThreshold    string = "failover_threshold"
+	failoverProbe        string = "failover_probe_interval"
+	failoverReceiveOpt   string = "failover_receive"
+	maxInFlightBytes     string = "max_in_flight_bytes"
+	bodyEncoding         string = "body_encoding"
+	leaseTTL             string = "lease_ttl"
+	leaseRetryDelay      string = "lease_retry_delay"
+	maxAppRetries        string = "max_app_retries"
+	compression          string = "compression"
+	gzipLevel            string = "gzip_level"
+	compressionThreshold string = "compression_threshold"
+	gzipDictionaryOpt    string = "gzip_dictionary"
+	maxDecompressedSize  string = "max_decompressed_size"
+	warmPoolSize         string = "warm_pool_size"
+	deadlineAttribute    string = "deadline_attribute"
+	latencyMetricsOpt    string = "latency_metrics"
+	tracing              string = "tracing"
+	sendBatchOpt         string = "send_batch"
+	deleteBatchOpt       string = "delete_batch"
+	redriveRate          string = "redrive_rate"
+	skewThresholdOpt     string = "timestamp_skew_threshold"
+	dedupStoreTTL        string = "dedup_store_ttl"
+	lookupBeforeCreate   string = "lookup_before_create"
+	routeAttribute       string = "route_attribute"
+	routePipelines       string = "route_pipelines"
+	overLimitBackoffOpt  string = "in_flight_limit_backoff"
+	credentialChainOpt   string = "credential_chain"
+	statsMaxStaleness    string = "stats_max_staleness"
+	verifyMD5            string = "verify_md5"
+	shardIndex           string = "shard_index"
+	shardCount           string = "shard_count"
+	payloadWarnBytes     string = "payload_warn_bytes"
+	enrichRetryDelay     string = "enrich_retry_delay"
+	setQueueWaitTime     string = "set_queue_wait_time"
+	sharedConfigFile     string = "shared_config_file"
+	sharedCredsFile      string = "shared_credentials_file"
+	snsUnwrap            string = "sns_unwrap"
+	deleteFlushCount     string = "delete_flush_count"
+	replyToAttribute     string = "reply_to_attribute"
+	fifoOpt              string = "fifo"
+	messageDedupID       string = "message_deduplication_id"
+	contentDedup         string = "content_based_deduplication"
+	heartbeatOpt         string = "visibility_heartbeat"
+	maxVisibilityExt     string = "max_visibility_extension"
+	maxReceiveCount      string = "max_receive_count"
+	defaultJob           string = "default_job"
+	roleARN              string = "role_arn"
+	externalID           string = "external_id"
+	roleSessionName      string = "session_name"
+	webIdentityToken     string = "web_identity_token_file"
+	s3Bucket             string = "s3_bucket"
+	s3Prefix             string = "s3_prefix"
+	alwaysThroughS3      string = "always_through_s3"
+	s3Threshold          string = "s3_threshold"
+	s3OffloadAttributes  string = "s3_offload_attributes"
+	nackBackoffOpt       string = "nack_backoff"
+	nackBackoffBase      string = "nack_backoff_base"
+	nackBackoffMax       string = "nack_backoff_max"
+	rateLimitOpt         string = "rate_limit"
+	rateLimitBurst       string = "rate_limit_burst"
+	drainTimeout         string = "drain_timeout"
+	endpointOpt          string = "endpoint"
+	priorityMap          string = "priority_map"
+	headerAttributesOpt  string = "header_attributes"
+	attributeHeadersOpt  string = "attribute_headers"
+	healthCheckOpt       string = "health_check_interval"
+	breakerThreshold     string = "circuit_breaker_threshold"
+	breakerCooldown      string = "circuit_breaker_cooldown"
+	replyQueueOpt        string = "reply_queue"
+	replyAllowedQueues   string = "reply_allowed_queues"
+	invalidBodyPolicy    string = "invalid_body_policy"
+	invalidBodyQueueOpt  string = "invalid_body_queue"
+	waitTimeSeconds      string = "wait_time_seconds"
+	maxMessagesPerPoll   string = "max_messages_per_poll"
+	messageAttrNames     string = "message_attribute_names"
+	systemAttrNames      string = "message_system_attribute_names"
+	maxJobAge            string = "max_job_age"
+	expiredQueueOpt      string = "expired_queue"
+	groupMaxParked       string = "group_max_parked"
+	recreateQueueOpt     string = "recreate_queue"
+	fanOutQueuesOpt      string = "fan_out_queues"
+	onNackOpt            string = "on_nack"
+	nackReleaseDelay     string = "nack_release_delay"
+	inMemoryOpt          string = "in_memory"
+	chaosOpt             string = "chaos"
 )
 
-// Config is used to parse pipeline configuration
+// Config is used to parse pipeline configuration.
+// Every string option (including the lists, the maps and the nested sections) can be sourced from the environment:
+// ${VAR} is replaced with the variable value, env:VAR is the variable value as a whole, e.g. secret: env:AWS_SECRET.
+// The unset variable fails the pipeline initialization. $${ and $env: are the escapes of the literal ${ and env:,
+// ${...} with a name which is not a variable name (e.g. the ${aws:SourceArn} IAM policy variable) is kept as is.
 type Config struct {
 	// global
 	Key          string `mapstructure:"key"`
@@ -25,24 +178,514 @@ type Config struct {
 	Region       string `mapstructure:"region"`
 	SessionToken string `mapstructure:"session_token"`
 	Endpoint     string `mapstructure:"endpoint"`
+	// MetadataEndpoint is the base URL of the EC2 instance metadata service (IMDS) used to detect the AWS environment
+	// and resolve the region, e.g. a proxy or a local stub. Default: http://169.254.169.254.
+	MetadataEndpoint string `mapstructure:"metadata_endpoint"`
+	// Partition is the AWS partition: aws, aws-us-gov (GovCloud) or aws-cn (China). Outside AWS, the regional endpoint
+	// of the partition is used if the endpoint is not set, e.g. https://sqs.us-gov-west-1.amazonaws.com.
+	// The region must belong to the partition.
+	Partition string `mapstructure:"partition"`
+	// AWSDetectionTimeout is the timeout (in milliseconds) of the metadata service probes used to detect the AWS environment.
+	// Both IMDSv1 and IMDSv2 are probed concurrently, the first positive result wins. Default: 2000. Negative - detection is skipped.
+	AWSDetectionTimeout int `mapstructure:"aws_detection_timeout"`
+	// SkipAWSDetection disables the metadata service probes, the environment is treated as non-AWS (the global sqs section is required).
+	SkipAWSDetection bool `mapstructure:"skip_aws_detection"`
+	// InsideAWS overrides the AWS environment detection: true or false are used without probing the metadata service
+	// (e.g. in the air-gapped environments), auto - detected once per metadata endpoint, the result is shared by the pipelines (default).
+	InsideAWS string `mapstructure:"inside_aws"`
+	// Profile is the name of the profile from the shared AWS config (~/.aws/config) to load the credentials from.
+	// Chained profiles (source_profile + role_arn) are supported, profiles with mfa_serial are not.
+	// The profile (or the shared files below) takes precedence over the static key/secret/session_token,
+	// both inside and outside AWS, the static credentials are ignored with a warning.
+	Profile string `mapstructure:"profile"`
+	// SharedConfigFile and SharedCredentialsFile replace the default shared config (~/.aws/config) and credentials
+	// (~/.aws/credentials) files, so the pipelines might use entirely separate AWS configs. The Profile (or the default
+	// profile) is loaded from them. Empty - the SDK defaults, including AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE.
+	SharedConfigFile      string `mapstructure:"shared_config_file"`
+	SharedCredentialsFile string `mapstructure:"shared_credentials_file"`
+	// CredentialChain is the explicit list of the credential providers tried in order, the first one returning
+	// the credentials wins: static (key/secret/session_token), env (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY),
+	// web_identity (AWS_WEB_IDENTITY_TOKEN_FILE/AWS_ROLE_ARN), ecs (the container credentials endpoint) and ec2
+	// (the instance role). Can't be used with the Profile. Empty - the implicit detection (default).
+	CredentialChain []string `mapstructure:"credential_chain"`
+	// RoleARN is the IAM role assumed with the resolved credentials (static, profile, credential_chain or the default chain),
+	// e.g. to consume the queues of another account. ExternalID is passed to the AssumeRole call, RoleSessionName defaults
+	// to roadrunner-sqs. With the WebIdentityTokenFile the role is assumed with the web identity token instead (e.g. EKS IRSA).
+	// The assumed role credentials are refreshed before they expire.
+	RoleARN              string `mapstructure:"role_arn"`
+	ExternalID           string `mapstructure:"external_id"`
+	RoleSessionName      string `mapstructure:"session_name"`
+	WebIdentityTokenFile string `mapstructure:"web_identity_token_file"`
+	// AWSLogMode enables the AWS SDK logs (forwarded to the plugin logger at the debug level):
+	// retries, requests, responses or all. Might be combined with a comma. Empty - disabled (default).
+	AWSLogMode string `mapstructure:"aws_log_mode"`
+
+	// QueuePrefix is prepended to the queue names (including the dead-letter queue) on create/resolve,
+	// e.g. staging- or prod-, so the same pipelines might target different environments.
+	QueuePrefix string `mapstructure:"queue_prefix"`
+	// QueueOwnerAccountID is the 12-digit ID of the AWS account owning the queues resolved by name (QueueOwnerAWSAccountId),
+	// so the cross-account queues might be configured without the full URLs. Applied to the pipeline, dead-letter and retry
+	// queues. The queue of another account is never created: the pipeline queue declaration is skipped (as with the
+	// skip_queue_declaration), can't be combined with the delete_on_stop.
+	QueueOwnerAccountID string `mapstructure:"queue_owner_account_id"`
+
+	// SetupTimeout is the timeout (in seconds) for the queue declaration/resolution on the pipeline start. Default: 30.
+	SetupTimeout int `mapstructure:"setup_timeout"`
+	// QueueReadyTimeout is the maximum time (in seconds) to wait for the declared queue to become resolvable
+	// (GetQueueUrl might briefly return QueueDoesNotExist right after CreateQueue). Default: 10.
+	QueueReadyTimeout int `mapstructure:"queue_ready_timeout"`
+
+	// SkipWarmup disables the warm-up of the new (and the rotated) clients (e.g. for the fast local starts): the credentials
+	// pre-fetch and the connection opened with a ListQueues call.
+	SkipWarmup bool `mapstructure:"skip_warmup"`
+	// NetworkRetries is the number of the additional receive/send attempts (with backoff) on the transient network errors,
+	// e.g. connection reset or DNS timeout, on top of the SDK retryer. Permanent DNS failures (no such host) are not retried.
+	// The sends are retried only on the errors before the request is sent (the failed dial, the DNS timeout), so a message
+	// accepted by SQS is never sent twice. 0 - default (3), negative - disabled.
+	NetworkRetries int `mapstructure:"network_retries"`
+	// SkipClockSkewCorrection disables the signing time correction: by default, on the RequestTimeTooSkewed (and similar) errors
+	// the offset between the local clock and the server time is detected from the response and the request is retried.
+	SkipClockSkewCorrection bool `mapstructure:"skip_clock_skew_correction"`
+	// ClientMaxLifetime is the time (in seconds) after which the SQS client is rebuilt and the credentials are re-resolved,
+	// e.g. for the assume-role sessions. The in-flight calls finish on the previous client. 0 - no rotation (default).
+	ClientMaxLifetime int `mapstructure:"client_max_lifetime"`
+	// DNSCacheTTL is the time (in seconds) the resolved SQS endpoint addresses are reused for the new connections,
+	// e.g. under a high throughput with many short-lived connections. The addresses are resolved again after the TTL
+	// or when none of them accepts the connection. 0 - disabled, every dial resolves the endpoint (default).
+	DNSCacheTTL int `mapstructure:"dns_cache_ttl"`
+	// HTTPClient is the transport of the SDK client: proxy, timeouts, keep-alive, idle connections and TLS.
+	HTTPClient HTTPClientConfig `mapstructure:"http_client"`
+	// EndpointDiscovery is the AWS SDK endpoint discovery setting: disabled, enabled or auto. SQS doesn't rely on it,
+	// so it's disabled by default to avoid the needless discovery calls. Default: disabled.
+	EndpointDiscovery string `mapstructure:"endpoint_discovery"`
 
 	// pipeline
 
+	// SSEManaged enables the SQS managed server-side encryption (SqsManagedSseEnabled attribute) on the queue declaration.
+	// Can't be combined with the KMS encryption (KmsMasterKeyId attribute).
+	SSEManaged bool `mapstructure:"sse_managed"`
 	// get queue url, do not declare
 	SkipQueueDeclaration bool `mapstructure:"skip_queue_declaration"`
+	// DeleteOnStop deletes the queue when the pipeline stops, only if the queue was created by the pipeline
+	// (ephemeral queues for CI, etc.). Pre-existing queues are never deleted. No effect with skip_queue_declaration.
+	DeleteOnStop bool `mapstructure:"delete_on_stop"`
+	// FastRequeueOnShutdown returns the received messages which were not taken by a worker yet (in the priority queue,
+	// the dispatch buffer or parked by the group ordering) to the queue on stop by resetting their visibility timeout to 0,
+	// so another instance picks them up right away. The messages being processed are not affected.
+	FastRequeueOnShutdown bool `mapstructure:"fast_requeue_on_shutdown"`
+	// DrainTimeout is the maximum time (in seconds) the stop waits for the messages taken by the workers to be
+	// acknowledged: the pollers are stopped first, the received messages not taken by a worker yet are returned to
+	// the queue right away (visibility timeout 0), then the pipeline is stopped once the in-flight messages are acked
+	// or nacked, or the timeout is reached. 0 - disabled (default), the late acks of the stopped pipeline fail.
+	DrainTimeout int `mapstructure:"drain_timeout"`
+	// HealthCheckInterval is how often (in seconds) the running pipeline verifies the queue is reachable with the current
+	// credentials (GetQueueAttributes), the pipeline is reported unavailable by the status plugin while the check fails.
+	// 0 - disabled (default).
+	HealthCheckInterval int `mapstructure:"health_check_interval"`
+	// CircuitBreakerThreshold is the number of the consecutive receive failures pausing all the pollers of the pipeline
+	// for the CircuitBreakerCooldown (in seconds, default: 30), then a single receive probes the queue: the polling is
+	// resumed on success, paused again on failure. 0 - disabled (default), the pollers back off individually.
+	CircuitBreakerThreshold int `mapstructure:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  int `mapstructure:"circuit_breaker_cooldown"`
+	// HandlerTimeout is the maximum time (in seconds) to wait for the ack of the message taken by a worker (the time
+	// spent in the priority queue is not counted), independent of the visibility timeout. The message is then returned to the queue with the visibility timeout
+	// of 2^(receive count - 1) seconds (up to 15 minutes), the late ack/nack of the worker returns an error.
+	// Not applied to the auto_ack messages. 0 - disabled (default).
+	HandlerTimeout int `mapstructure:"handler_timeout"`
+	// VisibilityHeartbeat is the interval (in seconds) of the visibility timeout extension of the messages being processed:
+	// the visibility is set to the visibility timeout again until the message is acknowledged, nacked or requeued, so the
+	// long-running jobs are not redelivered. Should be shorter than the visibility timeout. Not applied to the auto_ack
+	// messages. 0 - disabled (default).
+	VisibilityHeartbeat int `mapstructure:"visibility_heartbeat"`
+	// MaxVisibilityExtension is the maximum time (in seconds, counted from the receive) the visibility is extended for,
+	// the message is then redelivered once the visibility timeout expires. Default: 43200 (the SQS limit, 12 hours).
+	MaxVisibilityExtension int `mapstructure:"max_visibility_extension"`
+	// do not run the startup receive check (verifies that the credentials are allowed to consume from the queue)
+	SkipPermissionCheck bool `mapstructure:"skip_permission_check"`
 
 	// The duration (in seconds) that the received messages are hidden from subsequent
 	// retrieve requests after being retrieved by a ReceiveMessage request.
 	VisibilityTimeout int32 `mapstructure:"visibility_timeout"`
+	// WorkerTimeout is the maximum job execution time (in seconds) of the pipeline workers, e.g. the pool exec_ttl.
+	// The visibility timeout shorter than the worker timeout plus the VisibilityMargin is reported on init and reconfigure,
+	// since the message might be redelivered to another worker while the first one still processes it. 0 - disabled (default).
+	WorkerTimeout int `mapstructure:"worker_timeout"`
+	// VisibilityMargin is added to the WorkerTimeout (in seconds) to get the minimal visibility timeout. Default: 10.
+	VisibilityMargin int `mapstructure:"visibility_margin"`
+	// AutoAdjustVisibility raises the visibility timeout to the WorkerTimeout plus the VisibilityMargin (up to 12 hours)
+	// instead of the warning.
+	AutoAdjustVisibility bool `mapstructure:"auto_adjust_visibility"`
 	// The duration (in seconds) for which the call waits for a message to arrive
 	// in the queue before returning. If a message is available, the call returns
 	// sooner than WaitTimeSeconds. If no messages are available and the wait time
 	// expires, the call returns successfully with an empty list of messages.
+	// Valid values: up to 20, negative - the short polling (e.g. for the latency-sensitive pipelines). Default: 5.
 	WaitTimeSeconds int32 `mapstructure:"wait_time_seconds"`
+	// MaxMessagesPerPoll is the maximum number of messages requested by a single ReceiveMessage call,
+	// valid values: 1 to 10. Default: 10.
+	MaxMessagesPerPoll int32 `mapstructure:"max_messages_per_poll"`
+	// MessageAttributeNames are the message attributes requested with every receive, e.g. to cut the payload of
+	// the foreign attributes. The attributes read by the driver (rr_*, X-RR-*, Content-* and the configured
+	// hint attributes) are always requested. The SQS prefix notation (e.g. order_.*) is supported. Default: All.
+	MessageAttributeNames []string `mapstructure:"message_attribute_names"`
+	// MessageSystemAttributeNames are the system attributes (e.g. SenderId, AWSTraceHeader) requested in addition
+	// to the ones required by the driver, available in the job headers.
+	MessageSystemAttributeNames []string `mapstructure:"message_system_attribute_names"`
+	// SetQueueWaitTime sets the WaitTimeSeconds as the queue ReceiveMessageWaitTimeSeconds attribute on the queue declaration,
+	// so the other consumers of the queue long-poll by default as well. The per-call WaitTimeSeconds of this consumer
+	// still takes precedence over the queue attribute. Default: false.
+	SetQueueWaitTime bool `mapstructure:"set_queue_wait_time"`
 	// Prefetch is the maximum number of messages to return. Amazon SQS never returns more messages
 	// than this value (however, fewer messages might be returned). Valid values: 1 to
 	// 10. Default: 1.
 	Prefetch int32 `mapstructure:"prefetch"`
+	// RateLimit caps the consumption rate (messages per second) of the pipeline, e.g. when the queue is shared with other
+	// consumers. The received messages wait before the dispatch, up to the RateLimitBurst (default: the rate) is dispatched
+	// at once. 0 - unlimited (default).
+	RateLimit      int `mapstructure:"rate_limit"`
+	RateLimitBurst int `mapstructure:"rate_limit_burst"`
+	// MaxInFlightBytes bounds the total payload size of the received messages not acknowledged yet, on top of the prefetch.
+	// The pollers pause when the sum reaches the limit, a single message bigger than the limit is still received when
+	// nothing else is in flight. Applied on reconfigure. 0 - unlimited (default).
+	MaxInFlightBytes int64 `mapstructure:"max_in_flight_bytes"`
+	// InFlightLimitBackoff is the receive pause (in seconds) after the queue reached the SQS in-flight messages quota
+	// (OverLimit, ~120,000 messages for the standard queues), doubled on every OverLimit up to a minute and reset on
+	// the successful receive. Default: 5.
+	InFlightLimitBackoff int `mapstructure:"in_flight_limit_backoff"`
+	// UsePriorityQueue false dispatches the received messages in the receive order: all jobs get the pipeline priority
+	// (the priority hints are ignored) and the next message is inserted into the jobs priority queue once the previous
+	// one is taken by a worker, so the priority queue never reorders them, e.g. for the FIFO queues where
+	// the order is handled by SQS. Can't be used with the PriorityAttribute. With several pollers the order is kept
+	// within every receive batch. Default: true.
+	UsePriorityQueue *bool `mapstructure:"use_priority_queue"`
+	// LookupBeforeCreate resolves the queue with GetQueueUrl before the declaration: CreateQueue is called only if the
+	// queue is missing, the attributes of the existing queue are updated (SetQueueAttributes) only if they differ,
+	// so the boot doesn't fail with QueueNameExists on the attributes mismatch. The missing or changed tags are added
+	// (TagQueue), the other tags of the queue are kept. Default: true.
+	LookupBeforeCreate *bool `mapstructure:"lookup_before_create"`
+	// RecreateQueue creates the queue deleted out-of-band again, on the QueueDoesNotExist error of the receive or
+	// the push: the queue URL is resolved again, the recreation waits out the 60 seconds QueueDeletedRecently lockout,
+	// the failed push is sent once more. Never applies to the queues not declared by the pipeline
+	// (skip_queue_declaration, queue_owner_account_id). Default: true.
+	RecreateQueue *bool `mapstructure:"recreate_queue"`
+	// Pollers is the number of concurrent ReceiveMessage loops. Default: 1.
+	Pollers int `mapstructure:"pollers"`
+	// AdaptiveMaxPollers enables the adaptive concurrency on the SQS throttling: the number of pollers is halved
+	// when the calls are throttled and increased by one after the series of the successful receives, up to this value.
+	// Pollers is the initial number. 0 - disabled (default).
+	AdaptiveMaxPollers int `mapstructure:"adaptive_max_pollers"`
+	// AdaptiveMinPollers is the lower bound of the adaptive concurrency. Default: 1.
+	AdaptiveMinPollers int `mapstructure:"adaptive_min_pollers"`
+	// ScaleToZeroIdle is the time (in seconds) without received messages after which the pipeline is reported as idle
+	// (logged and exposed via the IdleState RPC), so an external autoscaler might scale the workers to zero. 0 - disabled (default).
+	ScaleToZeroIdle int `mapstructure:"scale_to_zero_idle"`
+	// DispatchBuffer is the size of the buffer between the receiver and the priority queue.
+	// Received messages are staged there, so the receive loop keeps making progress when the
+	// priority queue insert is slow. Polling is paused while the buffer is full. 0 - disabled (default).
+	DispatchBuffer int `mapstructure:"dispatch_buffer"`
+	// WarmPoolSize is the target number of the received messages buffered ahead of the prefetch limit, so a free
+	// worker gets the next message without waiting for the ReceiveMessage. The pollers refill the pool up to this depth,
+	// the buffered messages are returned to the queue on stop/pause. 0 - disabled (default).
+	WarmPoolSize int `mapstructure:"warm_pool_size"`
+	// BodyFormat is the format of the message body: json, text, binary or a custom registered one.
+	// The Content-Type message attribute overrides it per message. Empty - the body is passed as is (the Content-Type is ignored).
+	BodyFormat string `mapstructure:"body_format"`
+	// BodyEncoding is the transfer encoding of the message body: base64 (e.g. the binary payloads). The pushed bodies are
+	// encoded and marked with the Content-Transfer-Encoding attribute, the received ones are decoded before the body_format.
+	// The messages with the Content-Transfer-Encoding attribute are decoded regardless of this option.
+	// Empty - the body is sent and received as is (default).
+	BodyEncoding string `mapstructure:"body_encoding"`
+	// Compression of the pushed message bodies: gzip or zstd (the codec should be registered with Driver.RegisterCodec
+	// or the CodecProvider plugin, otherwise the pipeline fails to start).
+	// The compressed bodies are base64 encoded and marked with the Content-Encoding and Content-Transfer-Encoding
+	// attributes, the received ones are decompressed by the attribute. Empty - no compression (default).
+	Compression string `mapstructure:"compression"`
+	// GzipLevel is the gzip compression level: 1 (best speed) - 9 (best compression). Default: 6.
+	GzipLevel int `mapstructure:"gzip_level"`
+	// CompressionThreshold is the minimal size (in bytes) of the compressed bodies, the smaller ones are sent as is.
+	// Default: 0 - all the bodies are compressed.
+	CompressionThreshold int `mapstructure:"compression_threshold"`
+	// GzipDictionary is the path to the preset dictionary (up to 32 KiB) of the gzip compression, e.g. the common JSON keys
+	// of the repeated-structure payloads. The gzip format has no dictionary field, such bodies are compressed with
+	// the raw DEFLATE and marked with the Content-Encoding: deflate-dict and the Content-Dictionary (the dictionary ID)
+	// attributes: the consumers need the same dictionary. Empty - no dictionary (default).
+	GzipDictionary string `mapstructure:"gzip_dictionary"`
+	// MaxDecompressedSize is the limit (in bytes) of the decompressed message body, the larger ones are rejected.
+	// Default: 64 MiB.
+	MaxDecompressedSize int `mapstructure:"max_decompressed_size"`
+	// LeaseTTL is the lease duration (in seconds) of the partition key requested from the registered Locker
+	// (Driver.RegisterLocker), the lease is released on the ack/nack. Default: 30.
+	LeaseTTL int `mapstructure:"lease_ttl"`
+	// DedupStoreTTL is the time (in seconds) the processed message IDs are kept in the registered DedupStore
+	// (Driver.RegisterDedupStore), the redeliveries within the TTL are deleted without the dispatch. Default: 3600.
+	DedupStoreTTL int `mapstructure:"dedup_store_ttl"`
+	// LeaseRetryDelay is the visibility timeout (in seconds) of the messages with the partition key leased by another
+	// consumer, the message is returned to the queue instead of the dispatch. Default: 5.
+	LeaseRetryDelay int `mapstructure:"lease_retry_delay"`
+	// EnrichRetryDelay is the visibility timeout (in seconds) of the messages failed in the registered enrichment stage
+	// (Driver.RegisterEnricher), doubled with every receive up to 15 minutes. Default: 5.
+	EnrichRetryDelay int `mapstructure:"enrich_retry_delay"`
+	// MaxMessageAge is the maximum age (in seconds) of the message, based on the SentTimestamp attribute.
+	// Older messages are deleted from the queue on receive and never reach the workers. 0 - disabled (default).
+	MaxMessageAge int `mapstructure:"max_message_age"`
+	// MaxJobAge is the alias of the MaxMessageAge.
+	MaxJobAge int `mapstructure:"max_job_age"`
+	// ExpiredQueue is the name (or the URL) of the existing queue to move the expired messages to instead of the delete,
+	// e.g. to audit the missed notifications. Requires the MaxMessageAge.
+	ExpiredQueue string `mapstructure:"expired_queue"`
+	// FanOutQueues are the names (or the URLs) of the existing queues every pushed message is sent to in addition to
+	// the pipeline queue, concurrently and batched with the SendBatch. The push fails if any destination failed, the
+	// error lists the failed destinations. The destinations should be FIFO queues only if the pipeline queue is.
+	FanOutQueues []string `mapstructure:"fan_out_queues"`
+	// MessageAgeSkew is the clock skew tolerance (in seconds) added to the MaxMessageAge.
+	MessageAgeSkew int `mapstructure:"message_age_skew"`
+	// TimestampSkewThreshold is the offset (in milliseconds) of the SentTimestamp in the future logged as the clock skew.
+	// The age of such messages is clamped to zero, so they are never dropped as expired. Default: 1000.
+	TimestampSkewThreshold int `mapstructure:"timestamp_skew_threshold"`
+	// InMemory replaces SQS with the in-memory queues of the process, shared by the pipelines, so the pipelines can be
+	// tested without LocalStack. The credentials and the endpoint are ignored, the messages are lost on restart.
+	InMemory bool `mapstructure:"in_memory"`
+	// Chaos injects the faults (the latency, the throttling, the duplicate delivery) into the InMemory queues.
+	Chaos Chaos `mapstructure:"chaos"`
+	// SendBatch aggregates the pushed messages into the SendMessageBatch calls.
+	SendBatch BatchConfig `mapstructure:"send_batch"`
+	// DeleteBatch aggregates the deletes of the acknowledged messages into the DeleteMessageBatch calls,
+	// flushed on its own schedule, independently of the send batches.
+	DeleteBatch BatchConfig `mapstructure:"delete_batch"`
+	// DeleteFlushCount flushes the delete batch once that many receipt handles are pending, without waiting for the
+	// delete_batch.flush_interval, so the delay before the acknowledged message is deleted stays bounded under the steady
+	// load. Should not exceed the delete_batch.max_size. 0 - flushed at the max_size (default).
+	DeleteFlushCount int `mapstructure:"delete_flush_count"`
+	// Deprecated: use send_batch.max_size. Used if the send_batch block is not set.
+	BatchSize int `mapstructure:"batch_size"`
+	// Deprecated: use send_batch.flush_interval.
+	BatchFlushInterval int `mapstructure:"batch_flush_interval"`
+	// Deprecated: use send_batch.max_bytes.
+	MaxBatchBytes int `mapstructure:"max_batch_bytes"`
+	// SplitOversizedArrays splits the pushed JSON array payloads exceeding the SQS message size limit (256 KiB)
+	// into several messages, each with a subset of the array elements. The parts share the X-RR-Split-ID attribute
+	// (the original job ID) and carry the X-RR-Split-Part attribute (e.g. 2/5), both are also set as the job headers.
+	// Every part is processed as a separate job, the workers are responsible for the re-assembly (if needed).
+	SplitOversizedArrays bool `mapstructure:"split_oversized_arrays"`
+	// S3Bucket offloads the message bodies above the S3Threshold (in bytes, default: 262144, the SQS limit) to the bucket with
+	// the registered payload store (the PayloadStoreProvider plugin or Driver.RegisterPayloadStore), the message carries
+	// the pointer to the object (the SQS Extended Client format). The S3Prefix is prepended to the object keys, AlwaysThroughS3
+	// offloads every body. The offloaded bodies are fetched on receive (with the registered store) and deleted with the message.
+	// The pipeline fails to start if the store is not registered.
+	S3Bucket        string `mapstructure:"s3_bucket"`
+	S3Prefix        string `mapstructure:"s3_prefix"`
+	AlwaysThroughS3 bool   `mapstructure:"always_through_s3"`
+	S3Threshold     int    `mapstructure:"s3_threshold"`
+	// S3OffloadAttributes also offloads the message attributes if the message is still above the S3Threshold with the body
+	// offloaded (e.g. the large headers), the object is stored next to the body one (the .attributes key suffix) and the
+	// message carries only the X-RR-Offloaded-Attributes pointer. The attributes read before the message is unpacked
+	// (dedup_attribute, execute_at_attribute and the partition key) are kept. The attributes are restored on receive and
+	// the object is deleted with the message. Requires the S3Bucket.
+	S3OffloadAttributes bool `mapstructure:"s3_offload_attributes"`
+	// DeadLetterQueue is the name (or the URL) of the existing queue to move the messages which can't be processed (e.g. malformed body) to.
+	DeadLetterQueue string `mapstructure:"dead_letter_queue"`
+	// MaxReceiveCount creates the DeadLetterQueue (if missing) and sets the RedrivePolicy of the declared queue to it, so SQS
+	// moves the messages received that many times to the dead-letter queue. Can't be combined with the RedrivePolicy attribute,
+	// not applied with skip_queue_declaration. 0 - disabled (default).
+	MaxReceiveCount int `mapstructure:"max_receive_count"`
+	// DLQEnrichMetadata adds the failure metadata (original queue, receive count, first failure timestamp, last error)
+	// as message attributes to the messages moved to the dead-letter queue.
+	DLQEnrichMetadata bool `mapstructure:"dlq_enrich_metadata"`
+	// MaxAppRetries moves the message to the DeadLetterQueue (send + delete) once it was retried more than this number
+	// of times, independent of the queue redrive policy. The retries are the receives after the visibility timeout
+	// (ApproximateReceiveCount) and the nacks (counted in the X-Retry-Count attribute, the nacked message is sent again).
+	// The moved messages carry the failure metadata (dlq_enrich_metadata is implied). 0 - disabled (default).
+	MaxAppRetries int `mapstructure:"max_app_retries"`
+	// RedriveRate paces the replay of the dead-letter queue back to the queue (the Redrive RPC), messages per second,
+	// so the replay doesn't overwhelm the downstream again. 0 - unlimited (default).
+	RedriveRate int `mapstructure:"redrive_rate"`
+	// RetryQueue is the name (or the URL) of the existing queue to send the nacked messages to (instead of the pipeline queue),
+	// e.g. for the delayed or manual reprocessing. The X-Retry-Count attribute is incremented on every route.
+	RetryQueue string `mapstructure:"retry_queue"`
+	// RetryDelay is the delay (in seconds) of the messages sent to the retry queue, 0 to 900. Ignored for the FIFO queues.
+	RetryDelay int `mapstructure:"retry_delay"`
+	// NackBackoff keeps the nacked messages in the queue and delays the redelivery by the receive count (ApproximateReceiveCount)
+	// with the ChangeMessageVisibility, instead of sending them again: exponential - NackBackoffBase * 2^(receive count - 1),
+	// linear - NackBackoffBase * receive count, up to the NackBackoffMax (in seconds, default: 1 and 900, up to 43200).
+	// Can't be combined with the RetryQueue. Empty - disabled (default).
+	NackBackoff     string `mapstructure:"nack_backoff"`
+	NackBackoffBase int    `mapstructure:"nack_backoff_base"`
+	NackBackoffMax  int    `mapstructure:"nack_backoff_max"`
+	// OnNack is the policy of the nacked messages: requeue - the message is sent again and the original one is deleted
+	// (to the RetryQueue if configured), release - the message stays in the queue and is visible again after the
+	// NackReleaseDelay (in seconds, up to 43200) or the NackBackoff, dlq - the message is moved to the DeadLetterQueue.
+	// The on_nack and the nack_delay job headers override the policy and the delay for the job.
+	// Empty - release with the NackBackoff if configured, requeue otherwise (default).
+	OnNack           string `mapstructure:"on_nack"`
+	NackReleaseDelay int    `mapstructure:"nack_release_delay"`
+	// StrictGroupOrdering allows at most one in-flight message per FIFO message group, the next message of the
+	// group is dispatched only after the previous one is acknowledged. Costs throughput, disabled by default.
+	StrictGroupOrdering bool `mapstructure:"strict_group_ordering"`
+	// GroupMaxParked bounds the number of the messages parked by the StrictGroupOrdering (of all groups) waiting for
+	// their group, the messages over the limit are returned to the queue and received again after a short delay.
+	// On the standard queues the group is the partition key (partition_key_attribute). 0 - unlimited (default).
+	GroupMaxParked int `mapstructure:"group_max_parked"`
+	// MetadataMode is the way the RR job metadata is sent: spread (default) - every field is a separate message attribute,
+	// bundled - all fields (including the headers) are sent as a single JSON String attribute X-RR-Meta.
+	// Messages in both modes are accepted on receive.
+	MetadataMode string `mapstructure:"metadata_mode"`
+	// Delivery is the delivery semantics: at_least_once (default) - the message is deleted after the job is acknowledged,
+	// a worker crash or a timeout means the job is processed again, so the jobs should be idempotent.
+	// at_most_once - the message is deleted on receive before the dispatch (as with auto_ack for every job),
+	// a worker crash means the job is lost, but it's never processed twice. Nack has no effect in this mode.
+	Delivery string `mapstructure:"delivery"`
+	// DedupWindow is the time (in seconds) the received message IDs are remembered while the message is in flight.
+	// Messages with the same ID received within the window are not dispatched to the workers. The ID is forgotten
+	// once the message is acked, nacked or returned to the queue, so the redeliveries are processed. 0 - disabled (default).
+	DedupWindow int `mapstructure:"dedup_window"`
+	// DedupDelete deletes the suppressed duplicates from the queue, by default they are ignored
+	// and become visible again after the visibility timeout.
+	DedupDelete bool `mapstructure:"dedup_delete"`
+	// DedupMaxEntries bounds the number of the remembered IDs, the oldest ones are evicted first. 0 - unbounded (default).
+	DedupMaxEntries int `mapstructure:"dedup_max_entries"`
+	// DedupAttribute is the message attribute to deduplicate by (the dedup window and the DedupStore) instead of the
+	// message ID, e.g. the business key set by the producer. The message ID is used if the attribute is not set.
+	DedupAttribute string `mapstructure:"dedup_attribute"`
+	// PriorityAttribute, DelayAttribute and JobAttribute are the message attribute names to read the priority, delay (in seconds)
+	// and job name hints from, e.g. for the messages sent by the third-party producers. Take precedence over the RR attributes.
+	PriorityAttribute string `mapstructure:"priority_attribute"`
+	DelayAttribute    string `mapstructure:"delay_attribute"`
+	JobAttribute      string `mapstructure:"job_attribute"`
+	// PriorityMap translates the values of the priority hint to the priorities (case-insensitive), e.g. high: 1, low: 100
+	// for the third-party messages with the severity attribute. The values not in the map are parsed as the numbers.
+	PriorityMap map[string]string `mapstructure:"priority_map"`
+	// DefaultJob is the job name of the messages without the job name hint (e.g. published by SNS, EventBridge or
+	// other apps without the RR attributes). Default: deduced_by_rr.
+	DefaultJob string `mapstructure:"default_job"`
+	// PropagateHeaders is the allow-list of the headers sent with the message and promoted from the received message attributes.
+	// Empty - all headers. The tracing headers (traceparent, baggage, etc.) are always allowed. Case-insensitive.
+	PropagateHeaders []string `mapstructure:"propagate_headers"`
+	// RedactHeaders is the deny-list of the headers never sent or promoted, takes precedence over the PropagateHeaders.
+	RedactHeaders []string `mapstructure:"redact_headers"`
+	// PartitionKeyAttribute is the message attribute name for the job partition key (partition_key job header). Default: partition_key.
+	PartitionKeyAttribute string `mapstructure:"partition_key_attribute"`
+	// ShardIndex and ShardCount limit the instance to the messages with the partition key (PartitionKeyAttribute) hashing
+	// into its shard, e.g. for the cache locality or the tenant isolation without separate queues. The messages of the other
+	// shards are made visible again right away, the ones without the partition key are processed by any shard.
+	// Every shard should have a running instance, otherwise its messages are received again until the redrive policy moves them.
+	// The RR_SQS_SHARD_INDEX and RR_SQS_SHARD_COUNT environment variables take precedence (e.g. the per-instance index).
+	// ShardCount 0 or 1 - disabled (default).
+	ShardIndex int `mapstructure:"shard_index"`
+	ShardCount int `mapstructure:"shard_count"`
+	// CorrelationAttribute is the message attribute (and the job header) name with the correlation/request ID, e.g. X-Request-ID.
+	// The header is written to the attribute on send, the attribute is promoted to the header on receive, and the ID
+	// is added to the log fields (correlation_id) of the message processing. Empty - disabled (default).
+	CorrelationAttribute string `mapstructure:"correlation_attribute"`
+	// HeaderAttributes maps the job headers to the String message attributes written on push (header: attribute), e.g.
+	// the tenant ID for the SNS/Lambda filter policies. The mapped attributes are never moved to the overflow attribute,
+	// the push fails if they don't fit in the 10 attributes limit together with the RR metadata.
+	HeaderAttributes map[string]string `mapstructure:"header_attributes"`
+	// AttributeHeaders maps the received message attributes to the job headers (attribute: header), the existing headers
+	// are kept. The headers are subject to the propagate_headers/redact_headers.
+	AttributeHeaders map[string]string `mapstructure:"attribute_headers"`
+	// ReplyToAttribute is the message attribute name with the reply queue (the name or the URL) of the RPC over SQS requests,
+	// e.g. ReplyTo. The value is available as Item.ReplyTo, Driver.Reply (Item.Respond) sends the response there with the correlation
+	// attribute (correlation_attribute) copied from the request. The attribute is set by the sender, the URLs outside of
+	// the pipeline queue account (and the endpoint) are ignored unless listed in the ReplyAllowedQueues, the reply_queue
+	// is used instead. Empty - disabled (default).
+	ReplyToAttribute string `mapstructure:"reply_to_attribute"`
+	// ReplyQueue is the reply queue (the name or the URL) of the requests without the reply_to_attribute, the worker
+	// responses (Item.Respond) are sent there. Empty - only the reply_to_attribute (default).
+	ReplyQueue string `mapstructure:"reply_queue"`
+	// ReplyAllowedQueues are the reply queues (the names or the URLs, as in the reply_to_attribute) allowed besides
+	// the queues of the pipeline queue account, e.g. the reply queues of the other accounts.
+	ReplyAllowedQueues []string `mapstructure:"reply_allowed_queues"`
+	// RouteAttribute is the message attribute name with the target pipeline of the job, so one queue can carry the jobs
+	// of several pipelines. The job is dispatched to the named pipeline, the message is still acknowledged in this queue.
+	// Absent attribute - the consuming pipeline. Empty - disabled (default).
+	RouteAttribute string `mapstructure:"route_attribute"`
+	// RoutePipelines is the list of the pipelines the RouteAttribute may name, required with the RouteAttribute.
+	// The message naming another pipeline is not dispatched (the dead-letter queue, if configured).
+	RoutePipelines []string `mapstructure:"route_pipelines"`
+	// SourceQueueHeader is the job header name set to the URL of the queue the message was received from, e.g. to dedup
+	// the fan-in messages or to route the acks. Set on receive, the value of the message itself is replaced. Empty - disabled (default).
+	SourceQueueHeader string `mapstructure:"source_queue_header"`
+	// InvalidBodyPolicy handles the messages failed the registered BodyValidator (Driver.RegisterBodyValidator): dead_letter - moved to the dead-letter queue if configured, otherwise left in the
+	// queue (default), delete - deleted, queue - moved to the InvalidBodyQueue (the name or the URL).
+	InvalidBodyPolicy string `mapstructure:"invalid_body_policy"`
+	InvalidBodyQueue  string `mapstructure:"invalid_body_queue"`
+	// RetryBudget is the number of the retry attempts (the SDK retries and the network retries) the pipeline might spend
+	// in a burst, the budget is restored by RetryBudgetRefill tokens per second (default: 1). The requests are not retried
+	// when the budget is exhausted, so the failures don't multiply the API load. 0 - disabled (default).
+	RetryBudget int `mapstructure:"retry_budget"`
+	// RetryBudgetRefill is the number of the retry budget tokens restored per second. Default: 1.
+	RetryBudgetRefill int `mapstructure:"retry_budget_refill"`
+	// QueueEventsBuffer enables the provisioning audit events (the queue declared, created, recreated or deleted by the driver
+	// with the queue attributes) read from the Driver.QueueEvents channel of this size. The events are dropped when the
+	// channel is full. 0 - disabled (default).
+	QueueEventsBuffer int `mapstructure:"queue_events_buffer"`
+	// EventsBuffer is the number of the lifecycle and message events (Driver.RegisterEventListener) buffered for the
+	// listeners, the events are dropped when the buffer is full. No events are emitted without the listeners. Default: 1024.
+	EventsBuffer int `mapstructure:"events_buffer"`
+	// FailoverQueue is the URL of the secondary queue (in the FailoverRegion) the sends are routed to after FailoverThreshold
+	// (default: 3) consecutive connectivity errors of the primary region. The auth, throttling and validation errors never
+	// trigger the failover. The primary queue is probed every FailoverProbeInterval seconds (default: 30), the sends are
+	// failed back on success. Only the sends are failed over unless FailoverReceive, the pipeline keeps consuming the primary
+	// queue, so the secondary queue should be consumed by another pipeline. The order of the FIFO messages is not kept across the queues,
+	// and a send timed out in the primary region might be delivered there too (a duplicate in the secondary queue).
+	// Empty - disabled (default).
+	FailoverQueue         string `mapstructure:"failover_queue"`
+	FailoverRegion        string `mapstructure:"failover_region"`
+	FailoverThreshold     int    `mapstructure:"failover_threshold"`
+	FailoverProbeInterval int    `mapstructure:"failover_probe_interval"`
+	// FailoverReceive fails over the polling too: the connectivity errors of the receive count to the FailoverThreshold,
+	// the pollers consume the secondary queue while failed over and the primary one again once it's reachable. The messages
+	// are acknowledged in the queue they were received from. Every switch is reported as the failed_over/failed_back
+	// queue event (QueueEventsBuffer).
+	FailoverReceive bool `mapstructure:"failover_receive"`
+	// PreserveAttributeTypes keeps the message attribute data types with the custom labels (e.g. Number.int, String.email).
+	// On receive, the typed attributes are promoted to the headers and their types are recorded in the X-RR-Attr-Types header
+	// (<name>:<type> values). On send, the headers listed in X-RR-Attr-Types are written as the message attributes with
+	// these types. Default: false (the custom labeled attributes of the third-party messages are ignored).
+	PreserveAttributeTypes bool `mapstructure:"preserve_attribute_types"`
+	// ExecuteAtAttribute is the message attribute name with the desired execution time (RFC 3339 or the epoch time in seconds
+	// or milliseconds) set by an external scheduler. The messages with the future time are not dispatched, they are hidden
+	// with the visibility change until the time passes (beyond the 15 minutes DelaySeconds limit). Every hold is a receive,
+	// so the redrive policy maxReceiveCount and the queue retention period should allow it. The pushed jobs with the delay
+	// over 900 seconds are sent with the execution time in this attribute. Empty - disabled (default).
+	ExecuteAtAttribute string `mapstructure:"execute_at_attribute"`
+	// DeadlineAttribute is the message attribute (or the header) name with the processing deadline (RFC 3339 or the epoch
+	// time in seconds or milliseconds). The messages past the deadline are deleted without the dispatch, the deadline of
+	// the dispatched ones is passed to the worker in the job context (deadline). A malformed deadline is handled as a
+	// poison message (dead_letter_queue). Empty - disabled (default).
+	DeadlineAttribute string `mapstructure:"deadline_attribute"`
+	// LatencyMetrics enables the poll loop histograms in the pipeline stats: the ReceiveMessage round trip latency
+	// and the end-to-end message age (SentTimestamp to the dispatch).
+	LatencyMetrics bool `mapstructure:"latency_metrics"`
+	// LatencyBuckets are the histogram upper bounds in seconds. Default: 0.005 to 300.
+	LatencyBuckets []float64 `mapstructure:"latency_buckets"`
+	// StatsMaxStaleness is the maximum age (in seconds) of the cached queue depth (State and Stats, including the dead-letter
+	// queue) before a read refreshes it with GetQueueAttributes. Above the polling interval of the autoscaler it saves
+	// the API calls, but the autoscaler may act on a depth that old. 0 - no cache, every read calls GetQueueAttributes (default).
+	StatsMaxStaleness int `mapstructure:"stats_max_staleness"`
+	// VerifyMD5 compares the MD5OfMessageBody and MD5OfMessageAttributes returned by SendMessage with the local digests
+	// and sends the message again on the mismatch (up to 3 attempts), instead of failing the push as the SDK does.
+	// Not applied to the batched sends (send_batch). Default: false.
+	VerifyMD5 bool `mapstructure:"verify_md5"`
+	// PayloadWarnBytes logs a warning when the sent message (the body and the attributes) is larger than the value, e.g. 204800
+	// to notice the payloads approaching the 256 KiB limit. Enables the payload_size histogram in the pipeline stats.
+	// 0 - disabled (default), the histogram is still recorded with latency_metrics.
+	PayloadWarnBytes int `mapstructure:"payload_warn_bytes"`
+	// SNSUnwrap replaces the SNS notification envelope with the published message, the envelope message attributes are
+	// promoted to the SQS message attributes. The envelope is detected per message, so the raw message delivery might be
+	// switched on or off for the subscription without the config change, the detected mode is logged. Default: false.
+	SNSUnwrap bool `mapstructure:"sns_unwrap"`
+	// Tracing is the tracing mode: auto - the tracer plugin is used if present (default), disabled - no spans even if
+	// the tracer plugin is present, required - the pipeline fails to start without the tracer plugin.
+	Tracing string `mapstructure:"tracing"`
+	// EmptyBodyPolicy controls the messages without a body: drop - delete and log a warning (default),
+	// dispatch - push an empty job, error - treat as a poison message (moved to the dead-letter queue if configured).
+	EmptyBodyPolicy string `mapstructure:"empty_body_policy"`
+	// EncryptionKey is the base64 encoded AES key (16, 24 or 32 bytes) for the client-side message body encryption (AES-GCM).
+	// Encrypted messages are marked with the Content-Encryption attribute, messages without it are received as is. Empty - disabled.
+	EncryptionKey string `mapstructure:"encryption_key"`
+	// EncryptionKeyEnv is the name of the env variable with the EncryptionKey, so the key is not stored in the config.
+	EncryptionKeyEnv string `mapstructure:"encryption_key_env"`
 	// The name of the new queue. The following limits apply to this name:
 	//
 	// * A queue
@@ -56,6 +699,9 @@ type Config struct {
 	//
 	// Queue URLs and names are case-sensitive.
 	//
+	// The full queue URL (e.g. https://sqs.us-east-1.amazonaws.com/123456789012/orders) might be used instead of the name,
+	// it is validated on init and used as is (the queue_prefix is not applied).
+	//
 	// This member is required.
 	Queue *string `mapstructure:"queue"`
 
@@ -71,6 +717,16 @@ type Config struct {
 		The length of MessageGroupId is 128 characters. Valid values: alphanumeric characters and punctuation (!"#$%&'()*+,-./:;<=>?@[\]^_`{|}~).
 	*/
 	MessageGroupID string `mapstructure:"message_group_id"`
+	// MessageDeduplicationID is the FIFO deduplication ID. The message_group_id and message_deduplication_id might be
+	// the templates: {job}, {id}, {pipeline} and {header:name} are replaced with the job values, the job headers with
+	// the same names take precedence. Empty - the job ID (default).
+	MessageDeduplicationID string `mapstructure:"message_deduplication_id"`
+	// FIFO declares the FIFO queue: the .fifo suffix is appended to the queue name if missing. The FifoQueue attribute
+	// is set on create for every queue with the .fifo suffix.
+	FIFO bool `mapstructure:"fifo"`
+	// ContentBasedDeduplication sets the ContentBasedDeduplication attribute on create, the messages are deduplicated
+	// by the body unless the deduplication ID is set with the message_deduplication_id. FIFO queues only. Default: false.
+	ContentBasedDeduplication bool `mapstructure:"content_based_deduplication"`
 
 	// A map of attributes with their corresponding values. The following lists the
 	// names, descriptions, and values of the special request parameters that the
@@ -106,10 +762,31 @@ type Config struct {
 	// (https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-customer-managed-policy-examples.html#grant-cross-account-permissions-to-role-and-user-name)
 	// in the Amazon SQS Developer Guide.
 	Tags map[string]string `mapstructure:"tags"`
+
+	// endpoint of the pipeline (the endpoint pipeline option), unlike the global one it's used inside AWS as well,
+	// e.g. a VPC interface endpoint
+	pipelineEndpoint string
+}
+
+// BatchConfig is the aggregation of the single calls into the batch calls. A batch is flushed when it has max_size
+// entries or after the flush_interval, whichever comes first.
+type BatchConfig struct {
+	// MaxSize is the number of entries in a single batch call. Valid values: 1 to 10. 0 or 1 - batching is disabled (default).
+	MaxSize int `mapstructure:"max_size"`
+	// FlushInterval is the maximum time (in milliseconds) to wait for the batch to fill up. Default: 10.
+	FlushInterval int `mapstructure:"flush_interval"`
+	// MaxBytes is the maximum aggregate size of the messages in a single batch (send_batch only), the batch is flushed
+	// before exceeding it. Messages larger than this value are sent individually. Default and maximum: 262144 (256 KiB).
+	MaxBytes int `mapstructure:"max_bytes"`
+	// CancelFlush is the time (in milliseconds) to wait for the final flush when the push context is canceled while
+	// the message is buffered (send_batch only): the batch is flushed right away instead of waiting for the flush_interval.
+	// The push fails if the result doesn't arrive within the window, the late result is logged. Default: 1000.
+	CancelFlush int `mapstructure:"cancel_flush"`
 }
 
 func (c *Config) InitDefault() {
-	if c.Endpoint == "" {
+	// with the partition set, the endpoint is resolved from the region
+	if c.Endpoint == "" && c.Partition == "" {
 		c.Endpoint = "http://127.0.0.1:9324"
 	}
 
@@ -121,10 +798,41 @@ func (c *Config) InitDefault() {
 		c.Prefetch = 10
 	}
 
+	// the flat batch options predate the send_batch block
+	if c.SendBatch == (BatchConfig{}) {
+		c.SendBatch = BatchConfig{
+			MaxSize:       c.BatchSize,
+			FlushInterval: c.BatchFlushInterval,
+			MaxBytes:      c.MaxBatchBytes,
+		}
+	}
+
+	if c.SetupTimeout == 0 {
+		c.SetupTimeout = 30
+	}
+
+	if c.AWSDetectionTimeout == 0 {
+		c.AWSDetectionTimeout = 2000
+	}
+
+	if c.QueueReadyTimeout == 0 {
+		c.QueueReadyTimeout = 10
+	}
+
 	if c.WaitTimeSeconds == 0 {
 		c.WaitTimeSeconds = 5
 	}
 
+	c.Pollers = pollersCount(c.Pollers)
+	c.DispatchBuffer = dispatchBufferSize(c.DispatchBuffer)
+	c.BodyFormat = strings.ToLower(c.BodyFormat)
+	c.BodyEncoding = strings.ToLower(c.BodyEncoding)
+	c.Compression = strings.ToLower(c.Compression)
+	c.Tracing = strings.ToLower(c.Tracing)
+	c.MetadataMode = strings.ToLower(c.MetadataMode)
+	c.Delivery = strings.ToLower(c.Delivery)
+	c.EmptyBodyPolicy = strings.ToLower(c.EmptyBodyPolicy)
+
 	if c.Attributes != nil {
 		newAttr := make(map[string]string, len(c.Attributes))
 		toAwsAttribute(c.Attributes, newAttr)
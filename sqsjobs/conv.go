@@ -1,8 +1,11 @@
 package sqsjobs
 
 import (
+	"unicode/utf8"
+
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
 )
 
 func convAttr(h map[string]string) map[string][]string {
@@ -63,3 +66,58 @@ func convMessageAttr(h map[string]types.MessageAttributeValue, curr *map[string]
 		}
 	}
 }
+
+// splitBinaryHeaders separates the headers with non UTF-8 values, they are sent as Binary message attributes
+func splitBinaryHeaders(h map[string][]string) (map[string][]string, map[string][]byte, error) {
+	var binary map[string][]byte
+	for k, v := range h {
+		for j := 0; j < len(v); j++ {
+			if utf8.ValidString(v[j]) {
+				continue
+			}
+
+			if len(v) > 1 {
+				return nil, nil, errors.Errorf("header %s: multiple values with non UTF-8 data are not supported", k)
+			}
+
+			if binary == nil {
+				binary = make(map[string][]byte, 1)
+			}
+			binary[k] = []byte(v[j])
+		}
+	}
+
+	if binary == nil {
+		return h, nil, nil
+	}
+
+	headers := make(map[string][]string, len(h)-len(binary))
+	for k, v := range h {
+		if _, ok := binary[k]; ok {
+			continue
+		}
+		headers[k] = v
+	}
+
+	return headers, binary, nil
+}
+
+// convBinaryAttr adds the Binary message attributes (not reserved by RR) to the headers, existing headers are not overwritten
+func convBinaryAttr(h map[string]types.MessageAttributeValue, curr map[string][]string) {
+	for k, v := range h {
+		if v.DataType == nil || *v.DataType != BinaryType || v.BinaryValue == nil {
+			continue
+		}
+
+		switch k {
+		case jobs.RRJob, jobs.RRID, jobs.RRDelay, jobs.RRAutoAck, jobs.RRPriority, jobs.RRPipeline, jobs.RRHeaders:
+			continue
+		}
+
+		if _, ok := curr[k]; ok {
+			continue
+		}
+
+		curr[k] = []string{string(v.BinaryValue)}
+	}
+}
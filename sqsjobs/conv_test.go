@@ -0,0 +1,61 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryHeadersRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	sig := string([]byte{0xff, 0xfe, 0x00, 0x01, 0x80})
+	item := &Item{
+		Job:     "job",
+		Ident:   "id",
+		Payload: []byte("payload"),
+		headers: map[string][]string{
+			"signature": {sig},
+			"plain":     {"value"},
+		},
+		Options: &Options{},
+	}
+
+	in, err := item.pack(c.queueURL, c.queue, "", false)
+	require.NoError(t, err)
+
+	attr, ok := in.MessageAttributes["signature"]
+	require.True(t, ok)
+	require.Equal(t, BinaryType, aws.ToString(attr.DataType))
+	require.Equal(t, []byte(sig), attr.BinaryValue)
+	require.NotContains(t, string(in.MessageAttributes[jobs.RRHeaders].BinaryValue), "signature")
+
+	out, err := c.unpack(context.Background(), &types.Message{
+		MessageId:         aws.String("1"),
+		Body:              in.MessageBody,
+		MessageAttributes: in.MessageAttributes,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{sig}, out.headers["signature"])
+	require.Equal(t, []string{"value"}, out.headers["plain"])
+}
+
+func TestBinaryHeadersErrors(t *testing.T) {
+	bin := string([]byte{0xff})
+
+	_, _, err := splitBinaryHeaders(map[string][]string{"multi": {bin, bin}})
+	require.Error(t, err)
+
+	item := &Item{Options: &Options{}, headers: map[string][]string{jobs.RRID: {bin}}}
+	_, err = item.pack(aws.String("url"), aws.String("q"), "", false)
+	require.Error(t, err)
+
+	h, binary, err := splitBinaryHeaders(map[string][]string{"a": {"b"}})
+	require.NoError(t, err)
+	require.Nil(t, binary)
+	require.Equal(t, []string{"b"}, h["a"])
+}
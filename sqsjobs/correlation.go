@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// correlationField is the log field with the message correlation ID
+const correlationField string = "correlation_id"
+
+// correlationAttribute writes the correlation ID job header to the message attribute with the same name,
+// so the consumers which don't read the RR headers see it
+func (c *Driver) correlationAttribute(item *Item, in *sqs.SendMessageInput) error {
+	if c.correlationAttr == "" {
+		return nil
+	}
+
+	id := headerValue(item.headers, c.correlationAttr)
+	if id == "" {
+		return nil
+	}
+
+	if _, ok := in.MessageAttributes[c.correlationAttr]; ok {
+		return errors.Errorf("correlation attribute %s conflicts with the existing message attribute", c.correlationAttr)
+	}
+
+	in.MessageAttributes[c.correlationAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(id)}
+	return nil
+}
+
+// readCorrelationID reads the correlation ID from the message attribute or the headers, the ID is added to the headers (if absent),
+// so the workers see it
+func (c *Driver) readCorrelationID(attrs map[string]types.MessageAttributeValue, h map[string][]string) string {
+	if c.correlationAttr == "" {
+		return ""
+	}
+
+	id := c.correlationValue(attrs)
+	if id == "" {
+		return headerValue(h, c.correlationAttr)
+	}
+
+	if headerValue(h, c.correlationAttr) == "" {
+		h[c.correlationAttr] = []string{id}
+	}
+
+	return id
+}
+
+func (c *Driver) correlationValue(attrs map[string]types.MessageAttributeValue) string {
+	if attr, ok := attrs[c.correlationAttr]; ok && attr.StringValue != nil {
+		return *attr.StringValue
+	}
+
+	return ""
+}
+
+// messageLog returns the logger with the correlation ID of the raw message (if any), used until the message is unpacked
+func (c *Driver) messageLog(m *types.Message) *zap.Logger {
+	if c.correlationAttr == "" {
+		return c.log
+	}
+
+	return withCorrelation(c.log, c.correlationValue(m.MessageAttributes))
+}
+
+func withCorrelation(log *zap.Logger, id string) *zap.Logger {
+	if id == "" {
+		return log
+	}
+
+	return log.With(zap.String(correlationField, id))
+}
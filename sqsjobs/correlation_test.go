@@ -0,0 +1,64 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCorrelationID(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.log = zap.New(core)
+	c.correlationAttr = "X-Request-ID"
+	fc := newFakeClient()
+	c.client = fc
+
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{"X-Request-ID": {"req-1"}}, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, "req-1", aws.ToString(sent.MessageAttributes["X-Request-ID"].StringValue))
+
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	received := pq.ExtractMin().(*Item)
+	require.Equal(t, []string{"req-1"}, received.headers["X-Request-ID"])
+	require.NoError(t, received.Ack())
+
+	for _, msg := range []string{"receive message", "message pushed to the priority queue", "message acknowledged"} {
+		entries := logs.FilterMessage(msg).FilterField(zap.String(correlationField, "req-1")).All()
+		require.Len(t, entries, 1, msg)
+	}
+}
+
+func TestCorrelationIDHeaderOnly(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.correlationAttr = "X-Request-ID"
+
+	// attribute spilled (or dropped), the id is still in the RR headers
+	in, err := (&Item{Job: "job", Ident: "id", headers: map[string][]string{"X-Request-ID": {"req-2"}}, Options: &Options{}}).pack(c.queueURL, c.queue, "", false)
+	require.NoError(t, err)
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String(""), MessageAttributes: in.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, "req-2", c.readCorrelationID(in.MessageAttributes, out.headers))
+
+	// disabled
+	c.correlationAttr = ""
+	require.Equal(t, "", c.readCorrelationID(in.MessageAttributes, out.headers))
+	require.NoError(t, c.correlationAttribute(out, in))
+	require.NotContains(t, in.MessageAttributes, "X-Request-ID")
+}
@@ -0,0 +1,88 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// SQS doesn't allow to create a queue with the same name within 60 seconds after the deletion
+	queueDeletedRecentlyWait = time.Second * 60
+	createThrottleBackoff    = time.Millisecond * 500
+	maxCreateThrottleBackoff = time.Second * 10
+)
+
+// isQueueDeletedRecently checks whether the error is a QueueDeletedRecently API error
+func isQueueDeletedRecently(err error) bool {
+	var qErr *types.QueueDeletedRecently
+	if stderr.As(err, &qErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "AWS.SimpleQueueService.QueueDeletedRecently", "QueueDeletedRecently":
+		return true
+	default:
+		return false
+	}
+}
+
+// isThrottled checks whether the error is a rate limit API error
+func isThrottled(err error) bool {
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "OverLimit", "RequestThrottled", "ThrottlingException", "TooManyRequestsException", "AWS.SimpleQueueService.RequestThrottled":
+		return true
+	default:
+		return false
+	}
+}
+
+// createQueueRetry creates the queue, waiting out the QueueDeletedRecently lockout and backing off on the rate limits.
+// The retries are capped by the context (setup_timeout).
+func (c *Driver) createQueueRetry(ctx context.Context) (*string, error) {
+	backoff := createThrottleBackoff
+	for {
+		url, err := createQueue(ctx, c.client, c.queue, c.attributes, c.tags)
+		if err == nil {
+			return url, nil
+		}
+
+		var wait time.Duration
+		switch {
+		case isQueueDeletedRecently(err):
+			wait = c.deletedRecentlyWait
+			if wait == 0 {
+				wait = queueDeletedRecentlyWait
+			}
+			c.log.Warn("queue was deleted recently, waiting before the create retry", zap.Stringp("queue", c.queue), zap.Duration("wait", wait))
+		case isThrottled(err):
+			wait = backoff
+			backoff = min(backoff*2, maxCreateThrottleBackoff)
+			c.log.Warn("queue create was throttled, backing off", zap.Stringp("queue", c.queue), zap.Duration("wait", wait), zap.Error(err))
+		default:
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Errorf("queue %s create retry was canceled (increase setup_timeout to wait longer): %v", getordefault(c.queue), err)
+		case <-time.After(wait):
+		}
+	}
+}
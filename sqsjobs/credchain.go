@@ -0,0 +1,159 @@
+package sqsjobs
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// credential_chain providers, the static one is credsStatic
+const (
+	credsEnv         string = "env"
+	credsWebIdentity string = "web_identity"
+	credsECS         string = "ecs"
+	credsEC2         string = "ec2"
+
+	// ECS task role endpoint for the AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+	ecsCredentialsHost string = "http://169.254.170.2"
+)
+
+// credentialChain validates the credential_chain names (case-insensitive), empty - the SDK default chain (or the profile/static credentials)
+func credentialChain(names []string, profile string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	if profile != "" {
+		return nil, errors.Str("credential_chain can't be used with the profile, the profile credentials are resolved by the shared config")
+	}
+
+	out := make([]string, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		switch n {
+		case credsStatic, credsEnv, credsWebIdentity, credsECS, credsEC2:
+		case "":
+			continue
+		default:
+			return nil, errors.Errorf("unknown credential_chain provider %s, should be one of: static, env, web_identity, ecs, ec2", n)
+		}
+
+		if _, ok := seen[n]; ok {
+			return nil, errors.Errorf("credential_chain provider %s is listed twice", n)
+		}
+		seen[n] = struct{}{}
+		out = append(out, n)
+	}
+
+	return out, nil
+}
+
+type credentialSource struct {
+	name     string
+	provider aws.CredentialsProvider
+}
+
+// chainProvider tries the providers in the configured order, the first one returning the credentials wins.
+// The chain is walked again from the start on every refresh (the result is cached by the SDK until it expires).
+type chainProvider struct {
+	sources []credentialSource
+	log     *zap.Logger
+}
+
+func (p *chainProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	failed := make([]string, 0, len(p.sources))
+	for _, s := range p.sources {
+		creds, err := s.provider.Retrieve(ctx)
+		if err == nil {
+			p.log.Debug("credentials were resolved", zap.String("provider", s.name), zap.Strings("skipped", failed))
+			return creds, nil
+		}
+
+		p.log.Debug("credential provider failed, trying the next one", zap.String("provider", s.name), zap.Error(err))
+		failed = append(failed, s.name+": "+err.Error())
+	}
+
+	return aws.Credentials{}, errors.Errorf("no credentials from the credential_chain: %s", strings.Join(failed, "; "))
+}
+
+// newChainProvider builds the credential_chain providers, the AWS config is used for the STS client (web_identity)
+func newChainProvider(names []string, conf *Config, awsConf aws.Config, log *zap.Logger) aws.CredentialsProvider {
+	sources := make([]credentialSource, 0, len(names))
+	for _, n := range names {
+		var p aws.CredentialsProvider
+		switch n {
+		case credsStatic:
+			p = credentials.NewStaticCredentialsProvider(conf.Key, conf.Secret, conf.SessionToken)
+		case credsEnv:
+			p = aws.CredentialsProviderFunc(envCredentials)
+		case credsWebIdentity:
+			p = webIdentityCredentials(awsConf)
+		case credsECS:
+			p = ecsCredentials(awsConf)
+		case credsEC2:
+			p = ec2rolecreds.New(func(o *ec2rolecreds.Options) {
+				o.Client = imds.New(imds.Options{Endpoint: conf.MetadataEndpoint, HTTPClient: awsConf.HTTPClient})
+			})
+		}
+
+		sources = append(sources, credentialSource{name: n, provider: p})
+	}
+
+	return aws.NewCredentialsCache(&chainProvider{sources: sources, log: log})
+}
+
+// envCredentials reads the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables
+func envCredentials(context.Context) (aws.Credentials, error) {
+	key, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if key == "" || secret == "" {
+		return aws.Credentials{}, errors.Str("AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY is not set")
+	}
+
+	return aws.Credentials{AccessKeyID: key, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN"), Source: "EnvCredentials"}, nil
+}
+
+// webIdentityCredentials assumes the AWS_ROLE_ARN with the AWS_WEB_IDENTITY_TOKEN_FILE token (e.g. EKS IRSA)
+func webIdentityCredentials(awsConf aws.Config) aws.CredentialsProvider {
+	tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || role == "" {
+		return missingCredentials("AWS_WEB_IDENTITY_TOKEN_FILE or AWS_ROLE_ARN is not set")
+	}
+
+	return stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(awsConf), role, stscreds.IdentityTokenFile(tokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+	})
+}
+
+// ecsCredentials reads the ECS task role (or the container credentials endpoint) credentials
+func ecsCredentials(awsConf aws.Config) aws.CredentialsProvider {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		endpoint = ecsCredentialsHost + rel
+	}
+
+	if endpoint == "" {
+		return missingCredentials("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI is not set")
+	}
+
+	return endpointcreds.New(endpoint, func(o *endpointcreds.Options) {
+		o.HTTPClient = awsConf.HTTPClient
+		o.AuthorizationToken = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	})
+}
+
+func missingCredentials(reason string) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.Str(reason)
+	})
+}
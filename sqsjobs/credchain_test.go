@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCredentialChainOrder(t *testing.T) {
+	var tried []string
+	source := func(name string, err error) credentialSource {
+		return credentialSource{name: name, provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			tried = append(tried, name)
+			if err != nil {
+				return aws.Credentials{}, err
+			}
+			return aws.Credentials{AccessKeyID: name, SecretAccessKey: "secret", Source: name}, nil
+		})}
+	}
+
+	p := &chainProvider{log: zap.NewNop(), sources: []credentialSource{
+		source(credsWebIdentity, stderr.New("no token file")),
+		source(credsEnv, nil),
+		source(credsEC2, nil),
+	}}
+
+	creds, err := p.Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, credsEnv, creds.AccessKeyID)
+	// the first successful provider wins, the rest are not called
+	require.Equal(t, []string{credsWebIdentity, credsEnv}, tried)
+
+	tried = nil
+	p.sources = []credentialSource{source(credsECS, stderr.New("no endpoint")), source(credsStatic, stderr.New("empty"))}
+	_, err = p.Retrieve(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ecs: no endpoint; static: empty")
+	require.Equal(t, []string{credsECS, credsStatic}, tried)
+}
+
+func TestCredentialChainProviders(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+
+	names, err := credentialChain([]string{" Web_Identity", "ecs", "", "static", "env"}, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{credsWebIdentity, credsECS, credsStatic, credsEnv}, names)
+
+	// no web identity, ECS and static credentials in the environment, env wins
+	creds, err := newChainProvider(names, &Config{}, aws.Config{}, zap.NewNop()).Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "env-key", creds.AccessKeyID)
+
+	// static first
+	creds, err = newChainProvider([]string{credsStatic, credsEnv}, &Config{Key: "key", Secret: "secret"}, aws.Config{}, zap.NewNop()).Retrieve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "key", creds.AccessKeyID)
+
+	names, err = credentialChain(nil, "dev")
+	require.NoError(t, err)
+	require.Nil(t, names)
+
+	_, err = credentialChain([]string{"env"}, "dev")
+	require.Error(t, err)
+	_, err = credentialChain([]string{"env", "sso"}, "")
+	require.Error(t, err)
+	_, err = credentialChain([]string{"env", "ENV"}, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// sendDeadlineMargin is the minimum time which should be left in the context to start (or continue) sending a message
+const sendDeadlineMargin = time.Millisecond * 100
+
+var errDeadlineWouldExceed = stderr.New("deadline would be exceeded, not enough time left in the context to send the message")
+
+// deadlineRetryer wraps the client's retryer and refuses to schedule a retry attempt if the
+// context deadline would expire before the attempt (backoff delay + margin) could complete
+type deadlineRetryer struct {
+	aws.Retryer
+	deadline time.Time
+	margin   time.Duration
+}
+
+func (d *deadlineRetryer) RetryDelay(attempt int, opErr error) (time.Duration, error) {
+	delay, err := d.Retryer.RetryDelay(attempt, opErr)
+	if err != nil {
+		return 0, err
+	}
+
+	if time.Until(d.deadline) < delay+d.margin {
+		return 0, stderr.Join(errDeadlineWouldExceed, opErr)
+	}
+
+	return delay, nil
+}
+
+func (d *deadlineRetryer) GetRetryToken(ctx context.Context, opErr error) (func(error) error, error) {
+	if time.Until(d.deadline) < d.margin {
+		return nil, stderr.Join(errDeadlineWouldExceed, opErr)
+	}
+
+	return d.Retryer.GetRetryToken(ctx, opErr)
+}
+
+// checkDeadline returns an error if the context deadline is closer than the margin
+func checkDeadline(ctx context.Context, margin time.Duration) error {
+	if dl, ok := ctx.Deadline(); ok && time.Until(dl) < margin {
+		return errDeadlineWouldExceed
+	}
+
+	return nil
+}
+
+// withDeadline is a per-operation option which makes the SDK retryer context deadline aware
+// no-op if the context has no deadline
+func withDeadline(ctx context.Context, margin time.Duration) func(*sqs.Options) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return func(_ *sqs.Options) {}
+	}
+
+	return func(o *sqs.Options) {
+		if o.Retryer == nil {
+			return
+		}
+
+		o.Retryer = &deadlineRetryer{
+			Retryer:  o.Retryer,
+			deadline: dl,
+			margin:   margin,
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedRetryer struct {
+	delay   time.Duration
+	retries int
+}
+
+func (f *fixedRetryer) IsErrorRetryable(error) bool { return true }
+func (f *fixedRetryer) MaxAttempts() int            { return 60 }
+func (f *fixedRetryer) RetryDelay(int, error) (time.Duration, error) {
+	f.retries++
+	return f.delay, nil
+}
+func (f *fixedRetryer) GetRetryToken(context.Context, error) (func(error) error, error) {
+	return func(error) error { return nil }, nil
+}
+func (f *fixedRetryer) GetInitialToken() func(error) error {
+	return func(error) error { return nil }
+}
+
+func TestCheckDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	require.ErrorIs(t, checkDeadline(ctx, sendDeadlineMargin), errDeadlineWouldExceed)
+	require.NoError(t, checkDeadline(context.Background(), sendDeadlineMargin))
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel2()
+	require.NoError(t, checkDeadline(ctx2, sendDeadlineMargin))
+}
+
+func TestDeadlineRetryerFailsFast(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+
+	inner := &fixedRetryer{delay: time.Second * 2}
+	opts := &sqs.Options{Retryer: inner}
+	withDeadline(ctx, sendDeadlineMargin)(opts)
+
+	start := time.Now()
+	opErr := stderr.New("throttled")
+	for attempt := 1; attempt < opts.Retryer.MaxAttempts(); attempt++ {
+		_, err := opts.Retryer.RetryDelay(attempt, opErr)
+		if err != nil {
+			require.ErrorIs(t, err, errDeadlineWouldExceed)
+			require.ErrorIs(t, err, opErr)
+			break
+		}
+	}
+
+	// only the first attempt should be evaluated, no sleeps
+	require.Equal(t, 1, inner.retries)
+	require.Less(t, time.Since(start), time.Millisecond*100)
+}
+
+func TestDeadlineRetryerAllowsRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	inner := &fixedRetryer{delay: time.Millisecond}
+	opts := &sqs.Options{Retryer: inner}
+	withDeadline(ctx, sendDeadlineMargin)(opts)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		d, err := opts.Retryer.RetryDelay(attempt, stderr.New("throttled"))
+		require.NoError(t, err)
+		require.Equal(t, time.Millisecond, d)
+	}
+
+	require.Equal(t, 3, inner.retries)
+
+	// no deadline - retryer is not wrapped
+	opts2 := &sqs.Options{Retryer: inner}
+	withDeadline(context.Background(), sendDeadlineMargin)(opts2)
+	require.Equal(t, inner, opts2.Retryer)
+}
@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// readDeadline reads the processing deadline from the deadline_attribute or the header with the same name:
+// RFC 3339 or the epoch time in seconds (or milliseconds). The value is added to the headers (if absent), so the workers see it.
+// A malformed deadline fails the unpack, the message is handled as a poison message.
+func (c *Driver) readDeadline(attrs map[string]types.MessageAttributeValue, h map[string][]string) (time.Time, error) {
+	if c.deadlineAttr == "" {
+		return time.Time{}, nil
+	}
+
+	val := headerValue(h, c.deadlineAttr)
+	if attr, ok := attrs[c.deadlineAttr]; ok && attr.StringValue != nil {
+		val = *attr.StringValue
+	}
+
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	deadline, err := parseExecuteAt(val)
+	if err != nil {
+		return time.Time{}, errors.Errorf("malformed %s attribute: %v", c.deadlineAttr, err)
+	}
+
+	if headerValue(h, c.deadlineAttr) == "" {
+		h[c.deadlineAttr] = []string{val}
+	}
+
+	return deadline, nil
+}
+
+// deadlinePassed deletes the message with the passed deadline without dispatching it to the workers,
+// returns true if the message was dropped (or the delete failed, then it will be visible again after the visibility timeout)
+func (c *Driver) deadlinePassed(m *types.Message, item *Item) bool {
+	deadline := item.Options.deadline
+	if deadline.IsZero() || time.Now().Before(deadline) {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	log := item.Options.log
+	_, err := item.Options.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      item.Options.queue,
+		ReceiptHandle: m.ReceiptHandle,
+	})
+	item.Options.receipt.done()
+	if err != nil {
+		log.Error("failed to delete the message with the passed deadline from the queue", zap.Stringp("ID", m.MessageId), zap.Error(err))
+		return true
+	}
+
+	log.Warn("message deadline has passed, dropped", zap.Stringp("ID", m.MessageId), zap.Time("deadline", deadline), zap.Duration("overdue", time.Since(deadline)))
+	return true
+}
+
+// deadlineValue formats the deadline for the job context, empty if the message has no deadline
+func (o *Options) deadlineValue() string {
+	if o.deadline.IsZero() {
+		return ""
+	}
+
+	return o.deadline.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,85 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadlineAttribute(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.deadlineAttr = "deadline"
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+
+	future := time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond)
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{
+		MessageId:     aws.String("expired"),
+		ReceiptHandle: aws.String("receipt-expired"),
+		Body:          aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"deadline": {DataType: aws.String(StringType), StringValue: aws.String(time.Now().Add(-time.Minute).Format(time.RFC3339))},
+		},
+	}, types.Message{
+		MessageId:     aws.String("malformed"),
+		ReceiptHandle: aws.String("receipt-malformed"),
+		Body:          aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"deadline": {DataType: aws.String(StringType), StringValue: aws.String("tomorrow")},
+		},
+	}, types.Message{
+		MessageId:     aws.String("future"),
+		ReceiptHandle: aws.String("receipt-future"),
+		Body:          aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"deadline": {DataType: aws.String(NumberType), StringValue: aws.String(strconv.FormatInt(future.UnixMilli(), 10))},
+		},
+	})
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1 && fc.called("DeleteMessage") == 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	fc.mu.Lock()
+	// the passed deadline is dropped, the malformed one is moved to the dead-letter queue
+	require.Equal(t, "receipt-expired", aws.ToString(fc.deleted[0].ReceiptHandle))
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, c.dlqURL, fc.sent[0].QueueUrl)
+	require.Equal(t, "receipt-malformed", aws.ToString(fc.deleted[1].ReceiptHandle))
+	fc.mu.Unlock()
+
+	// the future deadline is dispatched, the worker gets it in the context
+	item := pq.ExtractMin().(*Item)
+	require.Equal(t, future, item.Options.deadline.UTC())
+
+	data, err := item.Context()
+	require.NoError(t, err)
+
+	var jc struct {
+		Deadline string              `json:"deadline"`
+		Headers  map[string][]string `json:"headers"`
+	}
+	require.NoError(t, json.Unmarshal(data, &jc))
+	require.Equal(t, future.Format(time.RFC3339Nano), jc.Deadline)
+	require.Equal(t, []string{strconv.FormatInt(future.UnixMilli(), 10)}, jc.Headers["deadline"])
+
+	// the remaining time
+	deadline, err := time.Parse(time.RFC3339Nano, jc.Deadline)
+	require.NoError(t, err)
+	require.Greater(t, time.Until(deadline), time.Second*50)
+
+	// no deadline
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String("body")})
+	require.NoError(t, err)
+	require.True(t, out.Options.deadline.IsZero())
+}
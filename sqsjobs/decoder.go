@@ -0,0 +1,112 @@
+package sqsjobs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// body formats
+	formatJSON   string = "json"
+	formatText   string = "text"
+	formatBinary string = "binary"
+
+	// contentTypeAttr message attribute overrides the pipeline body format
+	contentTypeAttr string = "Content-Type"
+)
+
+// BodyDecoder decodes the raw SQS message body into the job payload
+type BodyDecoder func(body []byte) ([]byte, error)
+
+func defaultDecoders() map[string]BodyDecoder {
+	return map[string]BodyDecoder{
+		formatJSON:   decodeJSON,
+		formatText:   decodeRaw,
+		formatBinary: decodeRaw,
+	}
+}
+
+// RegisterBodyDecoder registers (or overrides) a decoder for the body format, e.g. protobuf or avro
+func (c *Driver) RegisterBodyDecoder(format string, dec BodyDecoder) {
+	c.decodersMu.Lock()
+	defer c.decodersMu.Unlock()
+
+	if c.decoders == nil {
+		c.decoders = defaultDecoders()
+	}
+
+	c.decoders[strings.ToLower(format)] = dec
+}
+
+// decodeBody decodes the message body according to the Content-Type message attribute or the pipeline body_format.
+// Empty body_format means that the body is passed to the worker as is, the Content-Type is honored only if the body_format is set.
+func (c *Driver) decodeBody(body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+	format := c.bodyFormat
+	if format == "" {
+		return body, nil
+	}
+
+	if ct, ok := attrs[contentTypeAttr]; ok && ct.StringValue != nil {
+		format = contentTypeFormat(*ct.StringValue)
+	}
+
+	c.decodersMu.RLock()
+	dec, ok := c.decoders[format]
+	c.decodersMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown body format: %s", format)
+	}
+
+	return dec(body)
+}
+
+// contentTypeFormat maps the MIME content type to the body format
+func contentTypeFormat(ct string) string {
+	// strip parameters, e.g. charset
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	switch ct {
+	case "application/json":
+		return formatJSON
+	case "text/plain":
+		return formatText
+	case "application/octet-stream":
+		return formatBinary
+	default:
+		// custom decoders might be registered by the content type or the short name
+		return ct
+	}
+}
+
+func decodeJSON(body []byte) ([]byte, error) {
+	if !json.Valid(body) {
+		return nil, errors.Str("message body is not a valid JSON")
+	}
+
+	return body, nil
+}
+
+func decodeRaw(body []byte) ([]byte, error) {
+	return body, nil
+}
+
+// checkBodyFormat validates the configured body format
+func (c *Driver) checkBodyFormat() error {
+	if c.bodyFormat == "" {
+		return nil
+	}
+
+	c.decodersMu.RLock()
+	defer c.decodersMu.RUnlock()
+	if _, ok := c.decoders[c.bodyFormat]; !ok {
+		return errors.Errorf("unknown body_format: %s, supported: json, text, binary", c.bodyFormat)
+	}
+
+	return nil
+}
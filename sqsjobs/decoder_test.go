@@ -0,0 +1,78 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeBodyText(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.bodyFormat = formatText
+
+	item, err := c.unpack(context.Background(), &types.Message{Body: aws.String("plain text body"), MessageId: aws.String("1")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain text body"), item.Payload)
+}
+
+func TestDecodeBodyJSON(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.bodyFormat = formatJSON
+
+	item, err := c.unpack(context.Background(), &types.Message{Body: aws.String(`{"foo":"bar"}`), MessageId: aws.String("1")})
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"foo":"bar"}`), item.Payload)
+
+	_, err = c.unpack(context.Background(), &types.Message{Body: aws.String("not a json"), MessageId: aws.String("2")})
+	require.Error(t, err)
+}
+
+func TestDecodeBodyContentType(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.bodyFormat = formatJSON
+
+	// content type overrides the pipeline format
+	attrs := map[string]types.MessageAttributeValue{
+		contentTypeAttr: {DataType: aws.String(StringType), StringValue: aws.String("text/plain; charset=utf-8")},
+	}
+	pl, err := c.decodeBody([]byte("not a json"), attrs)
+	require.NoError(t, err)
+	require.Equal(t, []byte("not a json"), pl)
+
+	attrs[contentTypeAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String("application/x-protobuf")}
+	_, err = c.decodeBody([]byte{0x08, 0x96, 0x01}, attrs)
+	require.Error(t, err)
+
+	// pluggable decoder
+	c.RegisterBodyDecoder("application/x-protobuf", func(body []byte) ([]byte, error) {
+		if len(body) == 0 {
+			return nil, stderr.New("empty")
+		}
+		return []byte(`{"decoded":true}`), nil
+	})
+	pl, err = c.decodeBody([]byte{0x08, 0x96, 0x01}, attrs)
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"decoded":true}`), pl)
+
+	// no body_format, the body is passed as is
+	c.bodyFormat = ""
+	attrs[contentTypeAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String("application/json")}
+	pl, err = c.decodeBody([]byte("not a json"), attrs)
+	require.NoError(t, err)
+	require.Equal(t, []byte("not a json"), pl)
+}
+
+func TestCheckBodyFormat(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	require.NoError(t, c.checkBodyFormat())
+
+	c.bodyFormat = "avro"
+	require.Error(t, c.checkBodyFormat())
+
+	c.bodyFormat = formatBinary
+	require.NoError(t, c.checkBodyFormat())
+}
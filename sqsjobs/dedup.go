@@ -0,0 +1,142 @@
+package sqsjobs
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// dedupSet is a short-lived set of the received message IDs, used to suppress the duplicates returned by ReceiveMessage.
+// The IDs are ordered by the receive time, the expired ones and (with the dedup_max_entries) the oldest ones are evicted first.
+type dedupSet struct {
+	mu     sync.Mutex
+	window time.Duration
+	// 0 - unbounded
+	maxEntries int
+	seen       map[string]*list.Element
+	order      *list.List
+}
+
+// dedupEntry is the recorded ID and its receive time
+type dedupEntry struct {
+	id string
+	ts time.Time
+}
+
+func newDedupSet(window time.Duration, maxEntries int) *dedupSet {
+	return &dedupSet{
+		window:     window,
+		maxEntries: maxEntries,
+		seen:       make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// duplicate returns true if the ID was seen within the window, otherwise the ID is recorded
+func (d *dedupSet) duplicate(id string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// drop the expired IDs, the oldest first
+	for e := d.order.Front(); e != nil && now.Sub(e.Value.(*dedupEntry).ts) >= d.window; e = d.order.Front() {
+		delete(d.seen, e.Value.(*dedupEntry).id)
+		d.order.Remove(e)
+	}
+
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+
+	if d.maxEntries > 0 && d.order.Len() >= d.maxEntries {
+		e := d.order.Front()
+		delete(d.seen, e.Value.(*dedupEntry).id)
+		d.order.Remove(e)
+	}
+
+	d.seen[id] = d.order.PushBack(&dedupEntry{id: id, ts: now})
+	return false
+}
+
+// dedupKey returns the dedup key of the message: the dedup_attribute value if configured and set, otherwise the message ID
+func (c *Driver) dedupKey(msg *types.Message) (string, bool) {
+	if c.dedupAttribute != "" {
+		if attr, ok := msg.MessageAttributes[c.dedupAttribute]; ok && attr.StringValue != nil && *attr.StringValue != "" {
+			return *attr.StringValue, true
+		}
+	}
+
+	if msg.MessageId == nil {
+		return "", false
+	}
+
+	return *msg.MessageId, true
+}
+
+// isDuplicate checks the message against the dedup window, always false if the dedup is disabled
+func (c *Driver) isDuplicate(msg *types.Message) bool {
+	if c.dedup == nil {
+		return false
+	}
+
+	key, ok := c.dedupKey(msg)
+	if !ok {
+		return false
+	}
+
+	return c.dedup.duplicate(key, time.Now())
+}
+
+// dedupForget returns the func removing the message from the dedup window, nil if the dedup is disabled
+func (c *Driver) dedupForget(msg *types.Message) func() {
+	if c.dedup == nil {
+		return nil
+	}
+
+	key, ok := c.dedupKey(msg)
+	if !ok {
+		return nil
+	}
+
+	return func() {
+		c.dedup.forget(key)
+	}
+}
+
+// dropDuplicate skips the duplicate message. With dedup_delete the duplicate is deleted from the queue,
+// otherwise it becomes visible again after the visibility timeout.
+func (c *Driver) dropDuplicate(ctx context.Context, msg *types.Message) {
+	if !c.dedupDelete {
+		c.log.Warn("duplicate message was received within the dedup window, ignored", zap.Stringp("ID", msg.MessageId))
+		return
+	}
+
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+		QueueUrl:      queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.log.Error("failed to delete the duplicate message from the queue", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return
+	}
+
+	c.log.Warn("duplicate message was received within the dedup window, deleted", zap.Stringp("ID", msg.MessageId))
+}
+
+// forget removes the ID, so the redelivered message is not treated as a duplicate
+func (d *dedupSet) forget(id string) {
+	d.mu.Lock()
+	if e, ok := d.seen[id]; ok {
+		delete(d.seen, id)
+		d.order.Remove(e)
+	}
+	d.mu.Unlock()
+}
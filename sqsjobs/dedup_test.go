@@ -0,0 +1,154 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func dupMessage(id, receipt string) types.Message {
+	return types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String(receipt),
+		Body:          aws.String(receipt),
+	}
+}
+
+func TestDedupDuplicateDispatch(t *testing.T) {
+	for _, del := range []bool{false, true} {
+		pq := &testQueue{}
+		c := newTestDriver(pq, nil)
+		c.dedup = newDedupSet(time.Minute, 0)
+		c.dedupDelete = del
+
+		fc := newFakeClient()
+		fc.receiveFn = receiveOnce(dupMessage("1", "r1"), dupMessage("1", "r2"), dupMessage("2", "r3"))
+		c.client = fc
+
+		stop := runListener(c)
+		require.Eventually(t, func() bool {
+			return pq.Len() == 2
+		}, time.Second*5, time.Millisecond*10)
+		// give the listener a chance to dispatch the duplicate
+		time.Sleep(time.Millisecond * 50)
+		stop()
+
+		items := pq.Remove("")
+		require.Len(t, items, 2)
+		require.Equal(t, "r1", string(items[0].Body()))
+		require.Equal(t, "r3", string(items[1].Body()))
+
+		fc.mu.Lock()
+		if del {
+			require.Len(t, fc.deleted, 1)
+			require.Equal(t, "r2", aws.ToString(fc.deleted[0].ReceiptHandle))
+		} else {
+			require.Empty(t, fc.deleted)
+		}
+		fc.mu.Unlock()
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	d := newDedupSet(time.Second*10, 0)
+	now := time.Now()
+
+	require.False(t, d.duplicate("1", now))
+	require.True(t, d.duplicate("1", now.Add(time.Second*5)))
+	require.False(t, d.duplicate("2", now.Add(time.Second*5)))
+
+	// window has passed
+	require.False(t, d.duplicate("1", now.Add(time.Second*11)))
+	// expired IDs are pruned
+	d.mu.Lock()
+	require.Len(t, d.seen, 2)
+	d.mu.Unlock()
+
+	c := newTestDriver(&testQueue{}, nil)
+	m := dupMessage("1", "r1")
+	require.False(t, c.isDuplicate(&m))
+	require.False(t, c.isDuplicate(&m))
+}
+
+func TestDedupMaxEntriesAndAttribute(t *testing.T) {
+	d := newDedupSet(time.Minute, 2)
+	now := time.Now()
+
+	require.False(t, d.duplicate("1", now))
+	require.False(t, d.duplicate("2", now))
+	require.False(t, d.duplicate("3", now))
+	// the oldest one is evicted
+	require.False(t, d.duplicate("1", now))
+	require.True(t, d.duplicate("3", now))
+	d.mu.Lock()
+	require.Len(t, d.seen, 2)
+	require.Equal(t, 2, d.order.Len())
+	d.mu.Unlock()
+
+	c := newTestDriver(&testQueue{}, nil)
+	c.dedup = newDedupSet(time.Minute, 0)
+	c.dedupAttribute = "order_id"
+
+	withKey := func(id, key string) *types.Message {
+		m := dupMessage(id, "r-"+id)
+		m.MessageAttributes = map[string]types.MessageAttributeValue{
+			"order_id": {DataType: aws.String("String"), StringValue: aws.String(key)},
+		}
+		return &m
+	}
+
+	// different message IDs, the same business key
+	require.False(t, c.isDuplicate(withKey("a", "order-1")))
+	require.True(t, c.isDuplicate(withKey("b", "order-1")))
+	require.False(t, c.isDuplicate(withKey("c", "order-2")))
+
+	// the attribute is not set, the message ID is used
+	m := dupMessage("x", "r")
+	require.False(t, c.isDuplicate(&m))
+	require.True(t, c.isDuplicate(&m))
+
+	// the DedupStore uses the same key
+	c.dedupStoreTTL = defaultDedupStoreTTL
+	c.RegisterDedupStore(NewMemoryDedupStore())
+	c.dedupRecord(withKey("d", "order-3"))()
+	require.True(t, c.processedBefore(withKey("e", "order-3")))
+}
+
+func TestDedupInFlightOnly(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.dedup = newDedupSet(time.Minute, 0)
+	fc := newFakeClient()
+	c.client = fc
+
+	received := func(receipt string) *Item {
+		m := dupMessage("1", receipt)
+		require.False(t, c.isDuplicate(&m))
+		item, err := c.unpack(context.Background(), &m)
+		require.NoError(t, err)
+		// in flight, the redelivery is suppressed
+		require.True(t, c.isDuplicate(&m))
+		return item
+	}
+
+	// acked, the later delivery of the same ID is processed
+	require.NoError(t, received("r1").Ack())
+
+	// released back to the queue
+	c.onNack = nackPolicy{mode: nackRelease}
+	require.NoError(t, received("r2").Nack())
+
+	// the failed ack leaves the message in the queue
+	fc.deleteFn = func(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	}
+	require.Error(t, received("r3").Ack())
+
+	m := dupMessage("1", "r4")
+	require.False(t, c.isDuplicate(&m))
+}
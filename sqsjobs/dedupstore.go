@@ -0,0 +1,160 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDedupStoreTTL = time.Hour
+	// the store call shouldn't block the listener (or the ack) for long
+	dedupStoreTimeout = time.Second * 5
+)
+
+// DedupStore records the processed message IDs across the consumers, e.g. a Redis or DynamoDB table with the TTL.
+// The already processed messages are deleted from the queue before the dispatch, the ID is recorded after the
+// successful ack. This is the best-effort exactly-once within the TTL: the store errors never block the processing.
+type DedupStore interface {
+	// Seen returns true if the message ID was recorded within the ttl
+	Seen(ctx context.Context, id string) (bool, error)
+	// Record marks the message ID as processed for the ttl
+	Record(ctx context.Context, id string, ttl time.Duration) error
+}
+
+// RegisterDedupStore enables the check of the processed message IDs, nil disables it
+func (c *Driver) RegisterDedupStore(s DedupStore) {
+	if s == nil {
+		c.dedupStore.Store(nil)
+		return
+	}
+
+	c.dedupStore.Store(&s)
+}
+
+// processedBefore returns true if the message ID is recorded in the DedupStore, false if the store is not registered or failed
+func (c *Driver) processedBefore(msg *types.Message) bool {
+	s := c.dedupStore.Load()
+	if s == nil {
+		return false
+	}
+
+	key, ok := c.dedupKey(msg)
+	if !ok {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dedupStoreTimeout)
+	defer cancel()
+
+	seen, err := (*s).Seen(ctx, key)
+	if err != nil {
+		c.log.Warn("failed to check the message in the dedup store, the message is processed", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return false
+	}
+
+	return seen
+}
+
+// dropProcessed deletes the already processed message from the queue, it becomes visible again if the delete failed
+func (c *Driver) dropProcessed(ctx context.Context, msg *types.Message) {
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+		QueueUrl:      queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.log.Error("failed to delete the already processed message from the queue", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return
+	}
+
+	c.log.Debug("message was already processed (dedup store), deleted", zap.Stringp("ID", msg.MessageId))
+}
+
+// dedupRecord returns the func recording the message ID on the ack, nil if the store is not registered
+func (c *Driver) dedupRecord(msg *types.Message) func() {
+	s := c.dedupStore.Load()
+	if s == nil {
+		return nil
+	}
+
+	id, ok := c.dedupKey(msg)
+	if !ok {
+		return nil
+	}
+	ttl := c.dedupStoreTTL
+	log := c.log
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dedupStoreTimeout)
+		defer cancel()
+
+		err := (*s).Record(ctx, id, ttl)
+		if err != nil {
+			log.Warn("failed to record the processed message in the dedup store, a redelivery will be processed again", zap.String("ID", id), zap.Error(err))
+		}
+	}
+}
+
+// MemoryDedupStore is the in-memory DedupStore, the processed IDs are not shared across the instances.
+// Intended for the tests and the single instance deployments.
+type MemoryDedupStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+	// last cleanup of the expired IDs
+	pruned time.Time
+}
+
+// NewMemoryDedupStore creates an empty store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Seen returns true if the ID was recorded and not expired
+func (m *MemoryDedupStore) Seen(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exp, ok := m.seen[id]
+	if !ok {
+		return false, nil
+	}
+
+	if !m.now().Before(exp) {
+		delete(m.seen, id)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Record stores the ID until the ttl expires
+func (m *MemoryDedupStore) Record(_ context.Context, id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// drop the expired IDs at most once per ttl
+	now := m.now()
+	if now.Sub(m.pruned) >= ttl {
+		for k, exp := range m.seen {
+			if !now.Before(exp) {
+				delete(m.seen, k)
+			}
+		}
+		m.pruned = now
+	}
+
+	m.seen[id] = now.Add(ttl)
+	return nil
+}
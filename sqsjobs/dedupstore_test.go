@@ -0,0 +1,116 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupStoreSkipsProcessed(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.dedupStoreTTL = defaultDedupStoreTTL
+	store := NewMemoryDedupStore()
+	c.RegisterDedupStore(store)
+
+	msg := func(id, receipt string) types.Message {
+		return types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(receipt), Body: aws.String(id)}
+	}
+
+	var acked, redelivered atomic.Bool
+	calls := 0
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		calls++
+		switch {
+		case calls == 1:
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{msg("a", "receipt-a1")}}, nil
+		// the standard queue delivers a once more after the ack
+		case acked.Load() && !redelivered.Load():
+			redelivered.Store(true)
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{msg("a", "receipt-a2"), msg("b", "receipt-b")}}, nil
+		default:
+			time.Sleep(time.Millisecond * 10)
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+
+	seen, err := store.Seen(context.Background(), "a")
+	require.NoError(t, err)
+	require.True(t, seen)
+	acked.Store(true)
+
+	// the duplicate of a is deleted without the dispatch, b is dispatched
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, "b", string(pq.ExtractMin().(*Item).Payload))
+	require.Eventually(t, func() bool { return fc.called("DeleteMessage") == 2 }, time.Second*5, time.Millisecond*10)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Equal(t, "receipt-a1", aws.ToString(fc.deleted[0].ReceiptHandle))
+	require.Equal(t, "receipt-a2", aws.ToString(fc.deleted[1].ReceiptHandle))
+}
+
+type failingDedupStore struct{}
+
+func (failingDedupStore) Seen(context.Context, string) (bool, error) {
+	return false, stderr.New("redis is unavailable")
+}
+
+func (failingDedupStore) Record(context.Context, string, time.Duration) error {
+	return stderr.New("redis is unavailable")
+}
+
+func TestDedupStoreErrors(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = newFakeClient()
+
+	// not registered
+	m := types.Message{MessageId: aws.String("a")}
+	require.False(t, c.processedBefore(&m))
+	require.Nil(t, c.dedupRecord(&m))
+
+	// the store errors never block the processing
+	c.RegisterDedupStore(failingDedupStore{})
+	require.False(t, c.processedBefore(&m))
+	record := c.dedupRecord(&m)
+	require.NotNil(t, record)
+	record()
+
+	c.RegisterDedupStore(nil)
+	require.Nil(t, c.dedupRecord(&m))
+}
+
+func TestMemoryDedupStoreTTL(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryDedupStore()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Record(context.Background(), "a", time.Minute))
+	seen, _ := s.Seen(context.Background(), "a")
+	require.True(t, seen)
+
+	now = now.Add(time.Minute)
+	seen, _ = s.Seen(context.Background(), "a")
+	require.False(t, seen)
+
+	// the expired IDs are pruned on the record
+	require.NoError(t, s.Record(context.Background(), "b", time.Second))
+	now = now.Add(time.Second * 2)
+	require.NoError(t, s.Record(context.Background(), "c", time.Second))
+	require.Len(t, s.seen, 1)
+}
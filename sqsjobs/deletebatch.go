@@ -0,0 +1,181 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+type deleteEntry struct {
+	handle *string
+	res    chan error
+}
+
+// deleteBatcher aggregates the deletes of the acknowledged messages and sends them with DeleteMessageBatch.
+// A batch is flushed when it has size entries or after the interval. The order doesn't matter for the deletes,
+// so the batches are flushed concurrently.
+type deleteBatcher struct {
+	mu sync.Mutex
+
+	client   sqsClient
+	queueURL *string
+	log      *zap.Logger
+
+	size     int
+	interval time.Duration
+	// delete_flush_count, the batch is flushed once that many receipt handles are pending, 0 - at the size
+	flushCount int
+
+	pending []*deleteEntry
+	timer   *time.Timer
+
+	// retries on the transient network errors
+	retries int
+	budget  *retryBudget
+}
+
+func newDeleteBatcher(client sqsClient, queueURL *string, log *zap.Logger, size int, interval time.Duration) *deleteBatcher {
+	return &deleteBatcher{
+		client:   client,
+		queueURL: queueURL,
+		log:      log,
+		size:     size,
+		interval: interval,
+		pending:  make([]*deleteEntry, 0, size),
+	}
+}
+
+// delete adds the receipt handle to the current batch and waits for the batch result
+func (b *deleteBatcher) delete(ctx context.Context, handle *string) error {
+	entry := &deleteEntry{
+		handle: handle,
+		res:    make(chan error, 1),
+	}
+
+	b.mu.Lock()
+	var full []*deleteEntry
+	b.pending = append(b.pending, entry)
+	if len(b.pending) >= b.size || (b.flushCount > 0 && len(b.pending) >= b.flushCount) {
+		full = b.takeLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, b.flushPending)
+	}
+	b.mu.Unlock()
+
+	if len(full) > 0 {
+		b.flush(full)
+	}
+
+	select {
+	case err := <-entry.res:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// takeLocked returns the pending entries and resets the batch, should be called under the lock
+func (b *deleteBatcher) takeLocked() []*deleteEntry {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	entries := b.pending
+	b.pending = make([]*deleteEntry, 0, b.size)
+
+	return entries
+}
+
+func (b *deleteBatcher) flushPending() {
+	b.mu.Lock()
+	entries := b.takeLocked()
+	b.mu.Unlock()
+
+	if len(entries) > 0 {
+		b.flush(entries)
+	}
+}
+
+// flush deletes the entries with a single DeleteMessageBatch call and reports the per entry results. The failed entries
+// are reported as the API errors with the entry code, so the expired receipt handles are recognized by the caller.
+func (b *deleteBatcher) flush(entries []*deleteEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchFlushTimeout)
+	defer cancel()
+
+	in := &sqs.DeleteMessageBatchInput{
+		QueueUrl: b.queueURL,
+		Entries:  make([]types.DeleteMessageBatchRequestEntry, 0, len(entries)),
+	}
+
+	for i := 0; i < len(entries); i++ {
+		in.Entries = append(in.Entries, types.DeleteMessageBatchRequestEntry{
+			Id:            ptr(strconv.Itoa(i)),
+			ReceiptHandle: entries[i].handle,
+		})
+	}
+
+	out, err := netRetry(ctx, b.log, b.retries, b.budget, "DeleteMessageBatch", func() (*sqs.DeleteMessageBatchOutput, error) {
+		return b.client.DeleteMessageBatch(ctx, in)
+	})
+	if err != nil {
+		for i := 0; i < len(entries); i++ {
+			entries[i].res <- err
+		}
+		return
+	}
+
+	done := make([]bool, len(entries))
+	for i := 0; i < len(out.Successful); i++ {
+		if idx, ok := entryIndex(out.Successful[i].Id, len(entries)); ok {
+			done[idx] = true
+			entries[idx].res <- nil
+		}
+	}
+
+	for i := 0; i < len(out.Failed); i++ {
+		if idx, ok := entryIndex(out.Failed[i].Id, len(entries)); ok && !done[idx] {
+			done[idx] = true
+			entries[idx].res <- &smithy.GenericAPIError{
+				Code:    getordefault(out.Failed[i].Code),
+				Message: getordefault(out.Failed[i].Message),
+			}
+		}
+	}
+
+	for i := 0; i < len(done); i++ {
+		if !done[i] {
+			entries[i].res <- errors.Str("no result for the message in the DeleteMessageBatch response")
+		}
+	}
+}
+
+// initDeleteBatcher validates the delete_batch options and enables the delete batching, batch size <= 1 - disabled
+func (c *Driver) initDeleteBatcher(size, interval, flushCount int) error {
+	flushInterval, err := batchWindow(deleteBatchOpt, size, interval)
+	if err != nil || flushInterval == 0 {
+		return err
+	}
+
+	if flushCount < 0 || flushCount > size {
+		return errors.Errorf("delete_flush_count should be in the range 1-%d (delete_batch.max_size), provided: %d", size, flushCount)
+	}
+
+	c.deleteBatch = newDeleteBatcher(c.client, c.queueURL, c.log, size, flushInterval)
+	c.deleteBatch.flushCount = flushCount
+	c.deleteBatch.retries = c.netRetries
+	c.deleteBatch.budget = c.budget
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeleteBatcherFlushOnSize(t *testing.T) {
+	fc := newFakeClient()
+	// the timer should never fire
+	b := newDeleteBatcher(fc, aws.String("url"), zap.NewNop(), 3, time.Hour)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, b.delete(context.Background(), aws.String("handle")))
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 2, fc.called("DeleteMessageBatch"))
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+}
+
+func TestBatchersFlushOnOwnSchedule(t *testing.T) {
+	fc := newFakeClient()
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = fc
+	c.queueURL = aws.String("url")
+
+	require.NoError(t, c.initSendBatcher(maxBatchEntries, 0, 10, 0))
+	require.NoError(t, c.initDeleteBatcher(maxBatchEntries, 500, 0))
+
+	start := time.Now()
+	deleted := make(chan time.Duration, 1)
+	go func() {
+		require.NoError(t, c.deleteBatch.delete(context.Background(), aws.String("handle")))
+		deleted <- time.Since(start)
+	}()
+
+	// the send batch is flushed after its own interval, the delete is still pending
+	require.NoError(t, c.sendBatch.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("body")}))
+	require.Equal(t, 1, fc.called("SendMessageBatch"))
+	require.Equal(t, 0, fc.called("DeleteMessageBatch"))
+
+	select {
+	case elapsed := <-deleted:
+		require.GreaterOrEqual(t, elapsed, time.Millisecond*500)
+	case <-time.After(time.Second * 5):
+		t.Fatal("delete batch was not flushed")
+	}
+	require.Equal(t, 1, fc.called("DeleteMessageBatch"))
+	require.Equal(t, 1, fc.called("SendMessageBatch"))
+}
+
+func TestDeleteBatchExpiredHandle(t *testing.T) {
+	fc := newFakeClient()
+	fc.delBatchFn = func(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		return &sqs.DeleteMessageBatchOutput{
+			Failed: []types.BatchResultErrorEntry{{Id: in.Entries[0].Id, Code: aws.String(ReceiptHandleIsInvalid), SenderFault: true}},
+		}, nil
+	}
+
+	var expired uint64
+	item := &Item{Options: &Options{
+		client:       fc,
+		expiredOnAck: &expired,
+		deleteBatch:  newDeleteBatcher(fc, aws.String("url"), zap.NewNop(), 2, time.Millisecond),
+	}}
+
+	require.NoError(t, item.deleteMessage(context.Background()))
+	require.Equal(t, uint64(1), expired)
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+
+	// other entry errors are reported
+	fc.delBatchFn = func(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+		return &sqs.DeleteMessageBatchOutput{
+			Failed: []types.BatchResultErrorEntry{{Id: in.Entries[0].Id, Code: aws.String("AccessDenied")}},
+		}, nil
+	}
+	err := item.deleteMessage(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "AccessDenied")
+}
+
+func TestBatchOptionsValidatedIndependently(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	require.NoError(t, c.initSendBatcher(5, 0, 20, 0))
+	err := c.initDeleteBatcher(maxBatchEntries+1, 0, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "delete_batch.max_size")
+
+	err = c.initDeleteBatcher(5, -1, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "delete_batch.flush_interval")
+
+	err = c.initSendBatcher(maxBatchEntries+1, 0, 0, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "send_batch.max_size")
+
+	require.NoError(t, c.initDeleteBatcher(0, 0, 0))
+	require.Nil(t, c.deleteBatch)
+
+	require.NoError(t, c.initDeleteBatcher(5, 0, 0))
+	require.Equal(t, defaultBatchFlushInterval, c.deleteBatch.interval)
+}
+
+func TestPipelineBatch(t *testing.T) {
+	pipe := testPipeline{
+		"send_batch":   map[string]string{"max_size": "5", "flush_interval": "50"},
+		"delete_batch": map[string]string{"max_size": "ten"},
+	}
+
+	sb, err := pipelineBatch(pipe, sendBatchOpt, BatchConfig{MaxSize: 2, MaxBytes: 1024})
+	require.NoError(t, err)
+	require.Equal(t, BatchConfig{MaxSize: 5, FlushInterval: 50, MaxBytes: 1024}, sb)
+
+	_, err = pipelineBatch(pipe, deleteBatchOpt, BatchConfig{})
+	require.Error(t, err)
+
+	// the flat options are the send_batch fallback
+	conf := &Config{BatchSize: 4, BatchFlushInterval: 30}
+	conf.InitDefault()
+	require.Equal(t, BatchConfig{MaxSize: 4, FlushInterval: 30}, conf.SendBatch)
+}
+
+func TestDeleteFlushCount(t *testing.T) {
+	fc := newFakeClient()
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = fc
+	c.queueURL = aws.String("url")
+
+	err := c.initDeleteBatcher(maxBatchEntries, 0, maxBatchEntries+1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "delete_flush_count")
+
+	// the timer doesn't fire within the test
+	require.NoError(t, c.initDeleteBatcher(maxBatchEntries, 60000, 3))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, c.deleteBatch.delete(context.Background(), aws.String("handle-"+strconv.Itoa(i))))
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("delete batch was not flushed at the delete_flush_count")
+	}
+
+	require.Equal(t, 1, fc.called("DeleteMessageBatch"))
+	fc.mu.Lock()
+	require.Len(t, fc.delBatches[0].Entries, 3)
+	fc.mu.Unlock()
+}
@@ -0,0 +1,25 @@
+package sqsjobs
+
+import (
+	"github.com/roadrunner-server/errors"
+)
+
+// delivery semantics
+const (
+	// deliveryAtLeastOnce deletes the message after the job is acknowledged (default), a crashed worker means a redelivery
+	deliveryAtLeastOnce string = "at_least_once"
+	// deliveryAtMostOnce deletes the message on receive before the dispatch, a crashed worker means a lost message
+	deliveryAtMostOnce string = "at_most_once"
+)
+
+// checkDelivery validates the delivery option, empty means at_least_once. Returns true for at_most_once.
+func checkDelivery(mode string) (bool, error) {
+	switch mode {
+	case "", deliveryAtLeastOnce:
+		return false, nil
+	case deliveryAtMostOnce:
+		return true, nil
+	default:
+		return false, errors.Errorf("unknown delivery: %s, supported: at_least_once, at_most_once", mode)
+	}
+}
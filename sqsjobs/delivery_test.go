@@ -0,0 +1,51 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryAtMostOnce(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.atMostOnce = true
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")})
+	// the message must not reach the priority queue before it's deleted
+	queued := -1
+	fc.deleteFn = func(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+		queued = int(pq.Len())
+		return &sqs.DeleteMessageOutput{}, nil
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+	require.Equal(t, 0, queued)
+
+	// the ack doesn't delete the message again
+	require.NoError(t, pq.ExtractMin().Ack())
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+}
+
+func TestDeliveryMode(t *testing.T) {
+	for mode, atMostOnce := range map[string]bool{"": false, deliveryAtLeastOnce: false, deliveryAtMostOnce: true} {
+		got, err := checkDelivery(mode)
+		require.NoError(t, err)
+		require.Equal(t, atMostOnce, got)
+	}
+
+	_, err := checkDelivery("exactly_once")
+	require.Error(t, err)
+}
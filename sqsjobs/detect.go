@@ -0,0 +1,90 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// insideAWSAuto is the default inside_aws mode: the environment is detected by the metadata service probes
+const insideAWSAuto string = "auto"
+
+// awsDetection caches the detection results per metadata endpoint, so the pipelines constructed concurrently wait for
+// a single probe instead of probing (and waiting for the timeout in the air-gapped environments) on every construction
+var awsDetection = struct {
+	mu      sync.Mutex
+	results map[string]bool
+}{results: make(map[string]bool)}
+
+// resolveInsideAWS returns whether the driver runs inside AWS: inside_aws true/false is used as is, without probing,
+// auto (default) detects the environment once per metadata endpoint and caches the result
+func resolveInsideAWS(conf *Config) (bool, error) {
+	// in_memory, no metadata endpoint to probe
+	if conf.InMemory {
+		return false, nil
+	}
+
+	if conf.InsideAWS == "" || conf.InsideAWS == insideAWSAuto {
+		return cachedDetectAWS(conf), nil
+	}
+
+	inside, err := strconv.ParseBool(conf.InsideAWS)
+	if err != nil {
+		return false, errors.Errorf("unknown inside_aws value: %s, supported: auto, true, false", conf.InsideAWS)
+	}
+
+	if inside && conf.SkipAWSDetection {
+		return false, errors.Str("inside_aws: true conflicts with the skip_aws_detection")
+	}
+
+	return inside, nil
+}
+
+// cachedDetectAWS returns the cached detection result of the metadata endpoint, the first caller probes, the rest wait
+func cachedDetectAWS(conf *Config) bool {
+	if conf.SkipAWSDetection || conf.AWSDetectionTimeout < 0 {
+		return false
+	}
+
+	awsDetection.mu.Lock()
+	defer awsDetection.mu.Unlock()
+
+	if inside, ok := awsDetection.results[conf.MetadataEndpoint]; ok {
+		return inside
+	}
+
+	inside := detectAWS(conf)
+	awsDetection.results[conf.MetadataEndpoint] = inside
+	return inside
+}
+
+// detectAWS probes the IMDSv1 and IMDSv2 endpoints concurrently and returns true on the first positive result.
+// Both probes share the aws_detection_timeout, the probing is skipped with skip_aws_detection or a negative timeout.
+func detectAWS(conf *Config) bool {
+	if conf.SkipAWSDetection || conf.AWSDetectionTimeout < 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.AWSDetectionTimeout)*time.Millisecond)
+	// the slower probe is canceled once the result is known
+	defer cancel()
+
+	probes := []func(context.Context, string) bool{isInAWS, isinAWSIMDSv2}
+	res := make(chan bool, len(probes))
+	for _, probe := range probes {
+		go func(probe func(context.Context, string) bool) {
+			res <- probe(ctx, conf.MetadataEndpoint)
+		}(probe)
+	}
+
+	for range probes {
+		if <-res {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package sqsjobs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectAWS(t *testing.T) {
+	var probes, v2 int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		if r.Method == http.MethodPut && atomic.LoadInt32(&v2) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		// unresponsive metadata service
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	// the configured timeout is honored
+	start := time.Now()
+	require.False(t, detectAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: 200}))
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, int32(2), atomic.LoadInt32(&probes))
+
+	// IMDSv2 responds, the hanging IMDSv1 probe doesn't delay the result
+	atomic.StoreInt32(&v2, 1)
+	start = time.Now()
+	require.True(t, detectAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: 5000}))
+	require.Less(t, time.Since(start), time.Second*2)
+
+	// no probing
+	atomic.StoreInt32(&probes, 0)
+	require.False(t, detectAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: 5000, SkipAWSDetection: true}))
+	require.False(t, detectAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: -1}))
+	require.Equal(t, int32(0), atomic.LoadInt32(&probes))
+}
+
+func TestInsideAWSOverride(t *testing.T) {
+	var probes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	// no probing with the explicit value, the weakly typed YAML bools are accepted
+	for value, expected := range map[string]bool{"true": true, "false": false, "1": true, "0": false} {
+		inside, err := resolveInsideAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: 5000, InsideAWS: value})
+		require.NoError(t, err)
+		require.Equal(t, expected, inside, value)
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&probes))
+
+	_, err := resolveInsideAWS(&Config{InsideAWS: "maybe"})
+	require.Error(t, err)
+	_, err = resolveInsideAWS(&Config{InsideAWS: "true", SkipAWSDetection: true})
+	require.Error(t, err)
+
+	// auto, probed once, the concurrent constructions share the result
+	var wg sync.WaitGroup
+	var detected int32
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inside, err := resolveInsideAWS(&Config{MetadataEndpoint: srv.URL, AWSDetectionTimeout: 5000, InsideAWS: insideAWSAuto})
+			if err == nil && inside {
+				atomic.AddInt32(&detected, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(5), atomic.LoadInt32(&detected))
+	// a single detection, both IMDS versions
+	require.LessOrEqual(t, atomic.LoadInt32(&probes), int32(2))
+	require.GreaterOrEqual(t, atomic.LoadInt32(&probes), int32(1))
+}
@@ -0,0 +1,33 @@
+package sqsjobs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	discoveryAuto     string = "auto"
+	discoveryEnabled  string = "enabled"
+	discoveryDisabled string = "disabled"
+)
+
+// endpointDiscoveryOption maps the endpoint_discovery option to the SDK setting, disabled by default,
+// so the SDK never makes the extra endpoint discovery calls
+func endpointDiscoveryOption(mode string) (func(*config.LoadOptions) error, error) {
+	var state aws.EndpointDiscoveryEnableState
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", discoveryDisabled:
+		state = aws.EndpointDiscoveryDisabled
+	case discoveryEnabled:
+		state = aws.EndpointDiscoveryEnabled
+	case discoveryAuto:
+		state = aws.EndpointDiscoveryAuto
+	default:
+		return nil, errors.Errorf("unknown endpoint_discovery: %s, supported: %s, %s, %s", mode, discoveryDisabled, discoveryEnabled, discoveryAuto)
+	}
+
+	return config.WithEndpointDiscovery(state), nil
+}
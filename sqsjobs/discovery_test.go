@@ -0,0 +1,28 @@
+package sqsjobs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointDiscoveryOption(t *testing.T) {
+	for mode, state := range map[string]aws.EndpointDiscoveryEnableState{
+		"":         aws.EndpointDiscoveryDisabled,
+		"disabled": aws.EndpointDiscoveryDisabled,
+		"Enabled":  aws.EndpointDiscoveryEnabled,
+		"auto":     aws.EndpointDiscoveryAuto,
+	} {
+		opt, err := endpointDiscoveryOption(mode)
+		require.NoError(t, err)
+
+		var lo config.LoadOptions
+		require.NoError(t, opt(&lo))
+		require.Equal(t, state, lo.EnableEndpointDiscovery, mode)
+	}
+
+	_, err := endpointDiscoveryOption("on")
+	require.Error(t, err)
+}
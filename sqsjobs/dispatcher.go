@@ -0,0 +1,111 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dispatchBackoff is the time to wait before the next poll when the dispatch buffer is full
+const dispatchBackoff = time.Millisecond * 100
+
+// startDispatcher moves staged messages from the dispatch buffer to the priority queue.
+// The listener only puts the messages into the buffer, so a slow priority queue doesn't block receiving.
+func (c *Driver) startDispatcher() {
+	var ctx context.Context
+	ctx, c.dispatchCancel = context.WithCancel(context.Background())
+	c.dispatchDone = make(chan struct{})
+
+	go func() {
+		defer close(c.dispatchDone)
+		for {
+			select {
+			case <-ctx.Done():
+				c.log.Debug("sqs dispatcher was stopped", zap.Int("buffered", len(c.dispatchCh)))
+				return
+			case item := <-c.dispatchCh:
+				c.enqueue(item)
+			}
+		}
+	}()
+}
+
+// insert puts the item into the dispatch buffer if configured, or directly to the priority queue
+func (c *Driver) insert(item *Item) {
+	if c.dispatchCh != nil {
+		// the listeners reserve the space (reserveDispatch), never blocks for long under the prefetch lock.
+		// Once the dispatcher is stopped nothing drains the buffer anymore, the item goes to the priority queue.
+		select {
+		case <-c.dispatchDone:
+		default:
+			select {
+			case c.dispatchCh <- item:
+				return
+			case <-c.dispatchDone:
+			}
+		}
+	}
+
+	c.enqueue(item)
+}
+
+// reserveDispatch reserves the space for the whole receive batch in the dispatch buffer, false if the buffer is full.
+// The reservation is released with releaseDispatch once the batch is dispatched.
+func (c *Driver) reserveDispatch() bool {
+	if c.dispatchCh == nil {
+		return true
+	}
+
+	for {
+		// the reservations first: the items of the released reservation are already in the buffer
+		reserved := atomic.LoadInt32(&c.dispatchReserved)
+		if cap(c.dispatchCh)-len(c.dispatchCh)-int(reserved) < int(maxMessages) {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&c.dispatchReserved, reserved, reserved+maxMessages) {
+			return true
+		}
+	}
+}
+
+// releaseDispatch releases the reservation of the dispatched receive batch
+func (c *Driver) releaseDispatch() {
+	if c.dispatchCh != nil {
+		atomic.AddInt32(&c.dispatchReserved, -maxMessages)
+	}
+}
+
+// initDispatcher creates the dispatch buffer and starts the dispatcher, size 0 means disabled
+func (c *Driver) initDispatcher(size int) {
+	if size <= 0 {
+		return
+	}
+
+	c.dispatchCh = make(chan *Item, size)
+	c.startDispatcher()
+}
+
+// dispatchBufferSize rounds up the buffer size, so it can fit at least one receive batch
+func dispatchBufferSize(size int) int {
+	if size > 0 && size < int(maxMessages) {
+		return int(maxMessages)
+	}
+
+	return size
+}
+
+func (c *Driver) stopDispatcher() {
+	if c.dispatchCancel != nil {
+		c.dispatchCancel()
+	}
+}
+
+// waitDispatcher waits for the stopped dispatcher to exit
+func (c *Driver) waitDispatcher() {
+	if c.dispatchDone != nil {
+		<-c.dispatchDone
+	}
+}
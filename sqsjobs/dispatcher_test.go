@@ -0,0 +1,60 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchBufferSlowInsert(t *testing.T) {
+	pq := &testQueue{insertDelay: time.Millisecond * 100}
+	c := newTestDriver(pq, nil)
+	c.initDispatcher(dispatchBufferSize(20))
+	defer c.stopDispatcher()
+
+	start := time.Now()
+	for i := 0; i < int(maxMessages); i++ {
+		c.insert(&Item{Ident: "id", Options: &Options{}})
+	}
+
+	// the receive goroutine should not wait for the slow priority queue
+	require.Less(t, time.Since(start), time.Millisecond*50)
+	require.Less(t, pq.Len(), uint64(maxMessages))
+
+	require.Eventually(t, func() bool {
+		return pq.Len() == uint64(maxMessages)
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestDispatchBufferFull(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	require.True(t, c.reserveDispatch())
+
+	// no dispatcher, nothing drains the buffer
+	c.dispatchCh = make(chan *Item, dispatchBufferSize(1))
+	require.Equal(t, int(maxMessages), cap(c.dispatchCh))
+	require.True(t, c.reserveDispatch())
+	// reserved by another poller
+	require.False(t, c.reserveDispatch())
+
+	c.insert(&Item{Options: &Options{}})
+	c.releaseDispatch()
+	// not enough space for the next receive batch
+	require.False(t, c.reserveDispatch())
+}
+
+func TestDispatchStopped(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.initDispatcher(dispatchBufferSize(1))
+	c.stopDispatcher()
+	c.waitDispatcher()
+
+	// nothing drains the buffer, the insert doesn't block
+	for i := 0; i < int(maxMessages)+1; i++ {
+		c.insert(&Item{Ident: "id", Options: &Options{}})
+	}
+	require.Equal(t, uint64(maxMessages)+1, pq.Len())
+	require.Empty(t, c.dispatchCh)
+}
@@ -0,0 +1,134 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ApproximateFirstReceiveTimestamp is the system attribute with the time the message was first received (epoch time in milliseconds)
+	ApproximateFirstReceiveTimestamp string = "ApproximateFirstReceiveTimestamp"
+
+	// dead-letter metadata attributes
+	DLQOriginalQueue string = "rr_dlq_original_queue"
+	DLQReceiveCount  string = "rr_dlq_receive_count"
+	DLQFirstFailure  string = "rr_dlq_first_failure"
+	DLQLastError     string = "rr_dlq_last_error"
+
+	// maxMessageAttributes is the SQS limit for the number of message attributes
+	maxMessageAttributes int = 10
+	// maxLastErrorLen limits the size of the last error attribute
+	maxLastErrorLen int = 1024
+)
+
+// moveToDLQ sends the message to the dead-letter queue and deletes it from the source queue (see sourceOf)
+func (c *Driver) moveToDLQ(ctx context.Context, msg *types.Message, reason error) error {
+	if c.dlqURL == nil {
+		return errors.Str("dead-letter queue is not configured")
+	}
+
+	err := c.moveTo(ctx, msg, c.dlqURL, reason)
+	if err != nil {
+		return err
+	}
+
+	c.emitEvent(EventMessageDLQ, getordefault(msg.MessageId), "", reason)
+	return nil
+}
+
+// moveTo sends the message to the queue (the dead-letter, the invalid_body_queue or the expired_queue) and deletes it
+// from the source queue (see sourceOf)
+func (c *Driver) moveTo(ctx context.Context, msg *types.Message, queueURL *string, reason error) error {
+	client, sourceURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	attrs := make(map[string]types.MessageAttributeValue, len(msg.MessageAttributes)+4)
+	for k, v := range msg.MessageAttributes {
+		attrs[k] = v
+	}
+
+	if c.dlqEnrich {
+		c.enrichDLQ(msg, attrs, reason)
+	}
+
+	_, err := c.client.SendMessage(ctxT, &sqs.SendMessageInput{
+		QueueUrl:               queueURL,
+		MessageBody:            msg.Body,
+		MessageAttributes:      attrs,
+		MessageDeduplicationId: dedup(getordefault(msg.MessageId), queueURL),
+		MessageGroupId:         mgr(c.messageGroupID),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+		QueueUrl:      sourceURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// enrichDLQ adds the failure metadata to the message attributes.
+// Existing attributes are never overwritten, metadata which doesn't fit into the attributes limit is skipped.
+func (c *Driver) enrichDLQ(msg *types.Message, attrs map[string]types.MessageAttributeValue, reason error) {
+	firstFailure, ok := msg.Attributes[ApproximateFirstReceiveTimestamp]
+	if !ok {
+		firstFailure = strconv.FormatInt(time.Now().UnixMilli(), 10)
+	}
+
+	lastErr := ""
+	if reason != nil {
+		lastErr = reason.Error()
+		if len(lastErr) > maxLastErrorLen {
+			lastErr = lastErr[:maxLastErrorLen]
+		}
+	}
+
+	receiveCount, ok := msg.Attributes[ApproximateReceiveCount]
+	if !ok {
+		receiveCount = "0"
+	}
+
+	// ordered by importance
+	meta := []struct {
+		name  string
+		tp    string
+		value string
+	}{
+		{DLQOriginalQueue, StringType, getordefault(c.queue)},
+		{DLQReceiveCount, NumberType, receiveCount},
+		{DLQFirstFailure, NumberType, firstFailure},
+		{DLQLastError, StringType, lastErr},
+	}
+
+	for i := 0; i < len(meta); i++ {
+		if _, exists := attrs[meta[i].name]; exists {
+			continue
+		}
+
+		// SQS rejects empty attribute values
+		if meta[i].value == "" {
+			continue
+		}
+
+		if len(attrs) >= maxMessageAttributes {
+			c.log.Debug("message attributes limit reached, skipping the dead-letter metadata", zap.Stringp("ID", msg.MessageId), zap.String("attribute", meta[i].name))
+			continue
+		}
+
+		attrs[meta[i].name] = types.MessageAttributeValue{DataType: aws.String(meta[i].tp), StringValue: aws.String(meta[i].value)}
+	}
+}
@@ -0,0 +1,79 @@
+package sqsjobs
+
+import (
+	stderr "errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDLQEnrichMetadata(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+	c.dlqEnrich = true
+	c.bodyFormat = formatJSON
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{
+		MessageId:     aws.String("poison"),
+		ReceiptHandle: aws.String("receipt-poison"),
+		Body:          aws.String("not a json"),
+		Attributes: map[string]string{
+			ApproximateReceiveCount:          "3",
+			ApproximateFirstReceiveTimestamp: "1700000000000",
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"custom": {DataType: aws.String(StringType), StringValue: aws.String("value")},
+		},
+	})
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return fc.called("DeleteMessage") == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, uint64(0), pq.Len())
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, c.dlqURL, sent.QueueUrl)
+	require.Equal(t, "not a json", aws.ToString(sent.MessageBody))
+
+	attrs := sent.MessageAttributes
+	require.Equal(t, "value", aws.ToString(attrs["custom"].StringValue))
+	require.Equal(t, "test", aws.ToString(attrs[DLQOriginalQueue].StringValue))
+	require.Equal(t, "3", aws.ToString(attrs[DLQReceiveCount].StringValue))
+	require.Equal(t, "1700000000000", aws.ToString(attrs[DLQFirstFailure].StringValue))
+	require.NotEmpty(t, aws.ToString(attrs[DLQLastError].StringValue))
+
+	require.Equal(t, "receipt-poison", aws.ToString(fc.deleted[0].ReceiptHandle))
+}
+
+func TestDLQEnrichRespectsExisting(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	attrs := map[string]types.MessageAttributeValue{
+		DLQOriginalQueue: {DataType: aws.String(StringType), StringValue: aws.String("first-queue")},
+	}
+	// 1 existing + 8 = 9, only one enrichment attribute fits
+	for i := 0; i < 8; i++ {
+		attrs["attr"+strconv.Itoa(i)] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String("v")}
+	}
+
+	c.enrichDLQ(&types.Message{MessageId: aws.String("1")}, attrs, stderr.New("failed"))
+
+	require.Len(t, attrs, maxMessageAttributes)
+	require.Equal(t, "first-queue", aws.ToString(attrs[DLQOriginalQueue].StringValue))
+	require.Equal(t, "0", aws.ToString(attrs[DLQReceiveCount].StringValue))
+	require.NotContains(t, attrs, DLQFirstFailure)
+	require.NotContains(t, attrs, DLQLastError)
+}
@@ -0,0 +1,118 @@
+package sqsjobs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"go.uber.org/zap"
+)
+
+// dnsCache caches the resolved addresses of the dialed hosts for the TTL, so the new connections to the SQS endpoint
+// don't wait for the DNS lookups. The host is resolved again after the TTL or when none of the cached addresses accepts the connection.
+type dnsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*dnsEntry
+
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	log    *zap.Logger
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration, dialer *net.Dialer, log *zap.Logger) *dnsCache {
+	return &dnsCache{
+		ttl:     ttl,
+		entries: make(map[string]*dnsEntry),
+		lookup:  net.DefaultResolver.LookupHost,
+		dial:    dialer.DialContext,
+		log:     log,
+	}
+}
+
+// newDNSCachedHTTPClient returns the SDK HTTP client with the DNS cache on the transport dialer, nil if the cache is disabled (ttl <= 0)
+func newDNSCachedHTTPClient(ttl int, log *zap.Logger) *awshttp.BuildableClient {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return withDNSCache(awshttp.NewBuildableClient(), ttl, log)
+}
+
+// withDNSCache sets the DNS cache on the transport dialer of the client, the client is returned as is if the cache is disabled
+func withDNSCache(client *awshttp.BuildableClient, ttl int, log *zap.Logger) *awshttp.BuildableClient {
+	if ttl <= 0 {
+		return client
+	}
+
+	cache := newDNSCache(time.Duration(ttl)*time.Second, client.GetDialer(), log)
+
+	return client.WithTransportOptions(func(tr *http.Transport) {
+		tr.DialContext = cache.DialContext
+	})
+}
+
+// DialContext dials the cached addresses of the host one by one
+func (d *dnsCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dial(ctx, network, addr)
+	}
+
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		var conn net.Conn
+		conn, err = d.dial(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// the addresses might be stale (e.g. the endpoint IPs were rotated), resolve on the next dial
+	d.invalidate(host)
+	d.log.Debug("failed to connect to the cached addresses, DNS cache entry was reset", zap.String("host", host), zap.Error(err))
+
+	return nil, err
+}
+
+func (d *dnsCache) resolve(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	e, ok := d.entries[host]
+	d.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.entries[host] = &dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+
+	return addrs, nil
+}
+
+func (d *dnsCache) invalidate(host string) {
+	d.mu.Lock()
+	delete(d.entries, host)
+	d.mu.Unlock()
+}
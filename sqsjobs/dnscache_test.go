@@ -0,0 +1,104 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testResolver struct {
+	mu      sync.Mutex
+	lookups int
+	dialed  []string
+	refuse  bool
+}
+
+func (r *testResolver) cache(ttl time.Duration) *dnsCache {
+	d := newDNSCache(ttl, &net.Dialer{}, zap.NewNop())
+	d.lookup = func(_ context.Context, host string) ([]string, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lookups++
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	}
+	d.dial = func(_ context.Context, _, addr string) (net.Conn, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.dialed = append(r.dialed, addr)
+		if r.refuse {
+			return nil, stderr.New("connection refused")
+		}
+		c, _ := net.Pipe()
+		return c, nil
+	}
+
+	return d
+}
+
+func (r *testResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+func TestDNSCacheResolvesOnce(t *testing.T) {
+	r := &testResolver{}
+	d := r.cache(time.Minute)
+
+	for i := 0; i < 5; i++ {
+		conn, err := d.DialContext(context.Background(), "tcp", "sqs.us-east-1.amazonaws.com:443")
+		require.NoError(t, err)
+		_ = conn.Close()
+	}
+
+	require.Equal(t, 1, r.count())
+	require.Equal(t, "10.0.0.1:443", r.dialed[0])
+
+	// IP addresses are dialed as is
+	conn, err := d.DialContext(context.Background(), "tcp", "127.0.0.1:9324")
+	require.NoError(t, err)
+	_ = conn.Close()
+	require.Equal(t, 1, r.count())
+	require.Equal(t, "127.0.0.1:9324", r.dialed[len(r.dialed)-1])
+}
+
+func TestDNSCacheRefresh(t *testing.T) {
+	r := &testResolver{}
+	d := r.cache(time.Millisecond * 50)
+
+	_, err := d.DialContext(context.Background(), "tcp", "sqs.us-east-1.amazonaws.com:443")
+	require.NoError(t, err)
+
+	// TTL expired
+	time.Sleep(time.Millisecond * 100)
+	_, err = d.DialContext(context.Background(), "tcp", "sqs.us-east-1.amazonaws.com:443")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.count())
+
+	// connection failure, all the cached addresses are tried and the entry is reset
+	r.mu.Lock()
+	r.refuse = true
+	r.dialed = nil
+	r.mu.Unlock()
+	_, err = d.DialContext(context.Background(), "tcp", "sqs.us-east-1.amazonaws.com:443")
+	require.Error(t, err)
+	require.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443"}, r.dialed)
+
+	r.mu.Lock()
+	r.refuse = false
+	r.mu.Unlock()
+	_, err = d.DialContext(context.Background(), "tcp", "sqs.us-east-1.amazonaws.com:443")
+	require.NoError(t, err)
+	require.Equal(t, 3, r.count())
+}
+
+func TestDNSCachedHTTPClientDisabled(t *testing.T) {
+	require.Nil(t, newDNSCachedHTTPClient(0, zap.NewNop()))
+	require.NotNil(t, newDNSCachedHTTPClient(30, zap.NewNop()))
+}
@@ -0,0 +1,109 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const drainPollInterval = time.Millisecond * 50
+
+// Drain stops receiving new messages and waits until all in-flight messages are processed or the context is done.
+// Returns the number of messages still in flight. Unlike Stop, the driver might be resumed after the drain.
+func (c *Driver) Drain(ctx context.Context) (int64, error) {
+	start := time.Now().UTC()
+	pipe := *c.pipeline.Load()
+
+	if atomic.LoadUint32(&c.listeners) > 0 {
+		err := c.Pause(ctx, pipe.Name())
+		if err != nil {
+			return atomic.LoadInt64(c.msgInFlight), err
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		inFlight := atomic.LoadInt64(c.msgInFlight)
+		if inFlight <= 0 {
+			c.log.Debug("pipeline was drained", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("start", start), zap.Duration("elapsed", time.Since(start)))
+			return 0, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			c.log.Warn("pipeline drain timeout, messages are still in flight", zap.String("pipeline", pipe.Name()), zap.Int64("in_flight", inFlight), zap.Duration("elapsed", time.Since(start)))
+			return inFlight, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainOnStop stops the pollers, returns the received messages not taken by a worker to the queue and waits for
+// the in-flight messages to be acknowledged, up to the drain_timeout
+func (c *Driver) drainOnStop(ctx context.Context, pipeline string) {
+	start := time.Now().UTC()
+	c.notReady("pipeline is draining")
+
+	ctxT, cancel := context.WithTimeout(ctx, c.drainTimeout)
+	defer cancel()
+
+	// stop polling first, the listeners waiting for the prefetch limit are woken up to exit
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.cond.Broadcast()
+
+	// the pollers might be pushing the last received messages
+	if !c.waitPollers(ctxT) {
+		c.log.Warn("drain timeout, the pollers are still running", zap.String("pipeline", pipeline), zap.Int32("pollers", atomic.LoadInt32(&c.activePollers)))
+		return
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	c.stopDispatcher()
+	c.waitDispatcher()
+	unstarted := c.unstarted(c.pq.Remove(pipeline))
+	c.requeueUnstarted(ctx, unstarted)
+
+	// the returned messages are never acked by the workers
+	for {
+		inFlight := atomic.LoadInt64(c.msgInFlight) - int64(len(unstarted))
+		if inFlight <= 0 {
+			c.log.Debug("pipeline was drained before the stop", zap.String("pipeline", pipeline), zap.Int("requeued", len(unstarted)), zap.Duration("elapsed", time.Since(start)))
+			return
+		}
+
+		select {
+		case <-ctxT.Done():
+			c.log.Warn("drain timeout, messages are still in flight, they will be visible again after the visibility timeout", zap.String("pipeline", pipeline), zap.Int64("in_flight", inFlight), zap.Duration("elapsed", time.Since(start)))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseReceived returns the received messages which were not dispatched to the queue right away (visibility timeout 0)
+func (c *Driver) releaseReceived(ctx context.Context, msgs []types.Message) {
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for i := range msgs {
+		_, err := client.ChangeMessageVisibility(ctxT, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          queueURL,
+			ReceiptHandle:     msgs[i].ReceiptHandle,
+			VisibilityTimeout: 0,
+		})
+		if err != nil && !isNotInflight(err) {
+			c.log.Warn("failed to return the received message to the queue, it will be visible again after the visibility timeout", zap.Stringp("ID", msgs[i].MessageId), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainStopsReceiving(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return fc.called("ReceiveMessage") > 0
+	}, time.Second*5, time.Millisecond*10)
+
+	atomic.StoreInt64(c.msgInFlight, 2)
+
+	res := make(chan int64, 1)
+	go func() {
+		inFlight, err := c.Drain(context.Background())
+		require.NoError(t, err)
+		res <- inFlight
+	}()
+
+	// receive loop should stop right away
+	time.Sleep(time.Millisecond * 50)
+	received := fc.called("ReceiveMessage")
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, received, fc.called("ReceiveMessage"))
+
+	// still waiting for the in-flight messages
+	select {
+	case <-res:
+		t.Fatal("drain returned before the in-flight messages were processed")
+	default:
+	}
+
+	atomic.AddInt64(c.msgInFlight, -1)
+	atomic.AddInt64(c.msgInFlight, -1)
+
+	select {
+	case inFlight := <-res:
+		require.Equal(t, int64(0), inFlight)
+	case <-time.After(time.Second * 5):
+		t.Fatal("drain didn't return after the in-flight messages were processed")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	atomic.StoreInt64(c.msgInFlight, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	inFlight, err := c.Drain(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), inFlight)
+}
+
+func TestDrainOnStop(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.drainTimeout = time.Second * 5
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("body")},
+	)
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	// taken by a worker
+	processing := pq.ExtractMin().(*Item)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.Stop(context.Background())
+	}()
+
+	// the not started message is returned right away, the stop waits for the worker
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") == 1
+	}, time.Second*5, time.Millisecond*10)
+	select {
+	case <-stopped:
+		t.Fatal("stop returned before the in-flight message was acknowledged")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	require.NoError(t, processing.Ack())
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("stop didn't return after the ack")
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Equal(t, "receipt-2", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(0), fc.visibility[0].VisibilityTimeout)
+	require.Len(t, fc.deleted, 1)
+}
+
+func TestDrainOnStopTimeout(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.drainTimeout = time.Millisecond * 200
+	atomic.StoreInt64(c.msgInFlight, 1)
+
+	start := time.Now()
+	require.NoError(t, c.Stop(context.Background()))
+	require.GreaterOrEqual(t, time.Since(start), c.drainTimeout)
+}
@@ -2,15 +2,16 @@ package sqsjobs
 
 import (
 	"context"
+	"crypto/cipher"
 	stderr "errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -29,8 +30,9 @@ import (
 const (
 	pluginName           string = "sqs"
 	tracerName           string = "jobs"
-	awsMetaDataURL       string = "http://169.254.169.254/latest/dynamic/instance-identity/"
-	awsMetaDataIMDSv2URL string = "http://169.254.169.254/latest/api/token"
+	awsMetaDataEndpoint  string = "http://169.254.169.254"
+	awsMetaDataPath      string = "/latest/dynamic/instance-identity/"
+	awsMetaDataTokenPath string = "/latest/api/token"
 	awsTokenHeader       string = "X-aws-ec2-metadata-token-ttl-seconds" //nolint:gosec
 )
 
@@ -48,14 +50,25 @@ type Driver struct {
 	cond             sync.Cond
 	msgInFlight      *int64
 	msgInFlightLimit *int32
+	// payload bytes of the messages in flight and the max_in_flight_bytes limit (0 - unlimited)
+	bytesInFlight    *int64
+	maxInFlightBytes int64
 
 	pq          jobs.Queue
 	log         *zap.Logger
 	pipeline    atomic.Pointer[jobs.Pipeline]
 	skipDeclare bool
-
-	tracer *sdktrace.TracerProvider
+	// lookup_before_create, GetQueueUrl before the CreateQueue
+	lookupFirst bool
+	// recreate_queue, the queue deleted out-of-band is created again by the listener or the push
+	recreate    bool
+	recreateMu  sync.Mutex
+	recreatedAt time.Time
+
+	tracer trace.TracerProvider
 	prop   propagation.TextMapPropagator
+	// tracing: disabled, the context spans are not created either
+	tracingOff bool
 
 	// func to cancel listener
 	cancel context.CancelFunc
@@ -65,6 +78,11 @@ type Driver struct {
 	messageGroupID    string
 	waitTime          int32
 	visibilityTimeout int32
+	// max_messages_per_poll and the requested attributes
+	poll receivePoll
+	// FIFO deduplication ID template, empty - the job ID (or none with the content based deduplication)
+	dedupIDTemplate string
+	contentDedup    bool
 
 	// if user invoke several resume operations
 	listeners uint32
@@ -73,11 +91,246 @@ type Driver struct {
 	attributes map[string]string
 	tags       map[string]string
 
-	client   *sqs.Client
+	client   sqsClient
 	queueURL *string
+	// the queue URL is configured explicitly (instead of the name), so it's not resolved on setup
+	queueURLFixed bool
+	// the account owning the queues resolved by name, nil - the caller account
+	queueOwner *string
 
 	stopped uint64
-	pauseCh chan struct{}
+	// recovered panics in the message handling
+	panics uint64
+	// receipt handles expired before the ack
+	expiredOnAck uint64
+	// OverLimit receive responses and the pollers backing off on them
+	inFlightLimitHits uint64
+	inFlightLimited   int32
+	// last receive/send success and error
+	health health
+	// queue resolved and receiving
+	readiness readiness
+
+	// configuration the pipeline was started (FromConfig only) or last reconfigured with, used on reconfigure
+	conf *Config
+
+	// receive loops
+	pollers       int
+	pollerCancels []context.CancelFunc
+	runCtx        context.Context
+	activePollers int32
+	// adjusts the number of pollers on the throttling, nil if disabled
+	adaptive *adaptivePollers
+	// receipt handles of the in-flight messages
+	receipts *receiptTracker
+
+	// staging buffer between the listener and the priority queue
+	dispatchCh     chan *Item
+	dispatchCancel context.CancelFunc
+	// closed when the dispatcher exits
+	dispatchDone chan struct{}
+	// the buffer space reserved by the pollers for the receive batches
+	dispatchReserved int32
+	// received messages waiting for the prefetch limit, nil if disabled
+	warmPool chan warmMessage
+
+	// Close is called once
+	closeOnce sync.Once
+	closeErr  error
+
+	// client-side body encryption, nil if disabled
+	aead cipher.AEAD
+
+	// body decoding
+	bodyFormat string
+	decodersMu sync.RWMutex
+	decoders   map[string]BodyDecoder
+	// the compression codecs (zstd, etc.), guarded by the decodersMu
+	codecs map[string]Codec
+	// applied to the decoded body, guarded by the decodersMu
+	transformers []BodyTransformer
+	// body_encoding, empty - as is
+	bodyEncoding string
+	// compression, empty - disabled
+	compression string
+	gzipLevel   int
+	// gzip_dictionary, nil if not configured
+	gzipDict   []byte
+	gzipDictID string
+	// the smaller bodies are not compressed
+	compressThreshold int
+	// max_decompressed_size, 0 - the default one
+	maxDecompressed int
+
+	// send the RR metadata as a single attribute
+	bundledMeta bool
+	// delivery: at_most_once, delete on receive
+	atMostOnce bool
+	// split the oversized JSON array payloads on push
+	splitArrays bool
+	// propagate_headers/redact_headers, nil if not configured
+	headers *headerFilter
+	// partition key message attribute name, empty - default
+	partitionAttr string
+	// correlation ID message attribute (and job header) name, empty - disabled
+	correlationAttr string
+	// header_attributes/attribute_headers
+	attrMapping attributeMapping
+	// reply queue message attribute name (RPC over SQS), empty - disabled
+	replyToAttr string
+	// the default reply queue, empty - only the reply_to_attribute
+	replyQueue string
+	// reply_allowed_queues, the reply queues of the other accounts
+	replyAllowed map[string]struct{}
+	// resolved reply queue URLs by the queue name
+	replyURLs sync.Map
+	// source queue job header name, empty - disabled
+	sourceHeader string
+	// the registered validation stage, nil if disabled
+	validator atomic.Pointer[BodyValidator]
+	// invalid_body_policy, the number of the rejected messages
+	invalidBody   invalidBody
+	invalidBodies uint64
+	// custom attribute names for the job hints
+	hints hintNames
+	// use_priority_queue: false, the jobs are dispatched in the receive order with the pipeline priority, nil otherwise
+	order *orderGate
+	// retries on the transient network errors (on top of the SDK retryer)
+	netRetries int
+	// retry_budget shared by the SDK and the network retries, nil if disabled
+	budget *retryBudget
+	// provisioning audit events, nil if disabled
+	queueEvents chan QueueEvent
+	// lifecycle and message events (RegisterEventListener)
+	events *eventBus
+	// sends failover to the secondary region, nil if disabled
+	failover *sendFailover
+	// the pollers consume the secondary queue while failed over
+	failoverReceive bool
+	// partition key leases across the instances, disabled until a Locker is registered
+	locker          atomic.Pointer[Locker]
+	leaseTTL        time.Duration
+	leaseRetryDelay time.Duration
+	// processed message IDs across the instances, disabled until a DedupStore is registered
+	dedupStore    atomic.Pointer[DedupStore]
+	dedupStoreTTL time.Duration
+	// enrichment stage before the dispatch, disabled until an Enricher is registered
+	enricher         atomic.Pointer[Enricher]
+	enrichRetryDelay time.Duration
+	// offloaded message bodies, disabled until a PayloadStore is registered; nil if the s3_bucket is not set
+	payloadStore atomic.Pointer[PayloadStore]
+	offload      *payloadOffload
+	// preserve_attribute_types, the message attribute type labels are kept in the X-RR-Attr-Types header
+	preserveTypes bool
+	// rate_limit, nil if unlimited
+	limiter *rateLimiter
+	// push/ack/nack counters and rates for the stats
+	throughput *throughput
+	// the SQS calls latency and errors, the received messages and the visibility extensions (Metrics)
+	api           *apiMetrics
+	receivedTotal uint64
+	extensions    uint64
+	// route_attribute dispatching to the other pipelines, nil if disabled
+	routes *pipelineRoutes
+	// execute_at_attribute, empty - disabled
+	executeAtAttr string
+	// deadline_attribute, empty - disabled
+	deadlineAttr string
+	// poll loop histograms, nil if disabled
+	latency *latencyMetrics
+	// what to do with the messages without a body
+	emptyBodyPolicy string
+
+	// scale-to-zero: idle threshold (0 - disabled), last received message (unix nano) and the idle flag
+	idleAfter  time.Duration
+	lastActive int64
+	idle       uint32
+
+	// post-create resolution wait
+	queueReadyTimeout time.Duration
+	// QueueDeletedRecently lockout, 0 - 60 seconds
+	deletedRecentlyWait time.Duration
+
+	// ephemeral queue: delete on stop if created by the pipeline
+	deleteOnStop bool
+	createdQueue bool
+
+	// reset the visibility of the not started messages on stop
+	fastRequeue bool
+	// wait for the in-flight messages on stop, 0 - disabled
+	drainTimeout time.Duration
+	// probe the queue periodically, 0 - disabled
+	healthInterval time.Duration
+	// pause the pollers after the consecutive receive failures, nil - disabled
+	breaker *circuitBreaker
+	// the last pause and resume (unix nano), 0 - never
+	pausedAt  int64
+	resumedAt int64
+	// nack the messages not acknowledged in time, 0 - disabled
+	handlerTimeout time.Duration
+	// extend the visibility of the messages being processed, 0 - disabled
+	heartbeat    time.Duration
+	maxExtension time.Duration
+
+	// received message IDs, nil if disabled
+	dedup       *dedupSet
+	dedupDelete bool
+	// the message attribute to dedup by instead of the message ID, empty - the message ID
+	dedupAttribute string
+
+	// dead-letter queue, nil if not configured
+	dlqURL    *string
+	dlqEnrich bool
+	// max_app_retries, 0 - disabled
+	maxAppRetries int
+	// the redrive policy of the declared queue, 0 - disabled
+	maxReceiveCount int
+	// dead-letter queue replay, the last redrive is kept for the progress
+	redriveRate int
+	redriveMu   sync.Mutex
+	redrive     *redriveRun
+
+	// retry queue for the nacked messages, nil if not configured
+	retryQueue *string
+	retryURL   *string
+	retryDelay int32
+	// nacked messages are delayed by the receive count instead of sending them again, nil if disabled
+	nackBackoff *nackBackoff
+	// on_nack, the empty mode - the nack_backoff or the requeue
+	onNack nackPolicy
+
+	// per-group ordering for the FIFO queues, nil if disabled
+	groups *groupGate
+
+	// push batching, nil if disabled
+	sendBatch *sendBatcher
+	// ack batching, nil if disabled
+	deleteBatch *deleteBatcher
+
+	// drop policy for the time-sensitive messages
+	maxMessageAge  time.Duration
+	messageAgeSkew time.Duration
+	// expired_queue, nil - the expired messages are deleted
+	expiredQueue *string
+	expiredURL   *string
+	expiredTotal uint64
+	// fan_out_queues, the additional destinations of the pushed messages
+	fanOut []*fanOutTarget
+	// the future SentTimestamp beyond the threshold is logged
+	skewThreshold time.Duration
+	// the first receive backoff after OverLimit
+	overLimitBackoff time.Duration
+	// queue depth attributes of the State/Stats, nil if disabled
+	statsCache *statsCache
+	// verify the MD5 digests returned by SendMessage
+	verifyMD5 bool
+	// partition key shard of this instance, nil if disabled
+	shard *shardAffinity
+	// sent payload size histogram and the warning threshold, nil if disabled
+	payload *payloadMetrics
+	// unwrap the SNS notifications, the last detected delivery mode
+	snsUnwrap bool
+	snsMode   int32
 }
 
 func FromConfig(tracer *sdktrace.TracerProvider, configKey string, pipe jobs.Pipeline, log *zap.Logger, cfg Configurer, pq jobs.Queue) (*Driver, error) {
@@ -87,78 +340,386 @@ func FromConfig(tracer *sdktrace.TracerProvider, configKey string, pipe jobs.Pip
 		1. Non-AWS - global sqs config should be set
 		2. AWS - configuration should be obtained from the env, but with the ability to override them with the global config
 	*/
-	insideAWS := false
-	if isInAWS() || isinAWSIMDSv2() {
-		insideAWS = true
-	}
 
 	// if no such key - error
 	if !cfg.Has(configKey) {
 		return nil, errors.E(op, errors.Errorf("no configuration by provided key: %s", configKey))
 	}
 
+	// PARSE CONFIGURATION -------
+	cp, err := ReadConfig(configKey, cfg)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	conf := *cp
+
+	insideAWS, err := resolveInsideAWS(&conf)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
 	// if no global section - try to fetch IAM creds
 	if !cfg.Has(pluginName) && !insideAWS {
 		return nil, errors.E(op, errors.Str("no global sqs configuration, global configuration should contain sqs section"))
 	}
 
-	if tracer == nil {
-		tracer = sdktrace.NewTracerProvider()
+	tp, err := newTracerProvider(conf.Tracing, tracer)
+	if err != nil {
+		return nil, errors.E(op, err)
 	}
 
 	prop := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, jprop.Jaeger{})
 	otel.SetTextMapPropagator(prop)
 
-	// PARSE CONFIGURATION -------
-	var conf Config
-	err := cfg.UnmarshalKey(configKey, &conf)
+	err = managedSSE(conf.Attributes, conf.SSEManaged)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	// parse global config if exists
-	if cfg.Has(pluginName) {
-		err = cfg.UnmarshalKey(pluginName, &conf)
-		if err != nil {
-			return nil, errors.E(op, err)
-		}
+	err = queueWaitTime(conf.Attributes, conf.SetQueueWaitTime, conf.WaitTimeSeconds)
+	if err != nil {
+		return nil, errors.E(op, err)
 	}
 
-	conf.InitDefault()
+	heartbeat, maxExtension, err := heartbeatConfig(conf.VisibilityHeartbeat, conf.MaxVisibilityExtension)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	healthInterval, err := healthCheckConfig(conf.HealthCheckInterval)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
 
 	// initialize job Driver
 	jb := &Driver{
-		tracer:            tracer,
+		tracer:            tp,
+		tracingOff:        conf.Tracing == tracingDisabled,
 		prop:              prop,
 		cond:              sync.Cond{L: &sync.Mutex{}},
 		pq:                pq,
 		log:               log,
 		skipDeclare:       conf.SkipQueueDeclaration,
+		lookupFirst:       lookupEnabled(conf.LookupBeforeCreate),
+		recreate:          lookupEnabled(conf.RecreateQueue),
+		deleteOnStop:      conf.DeleteOnStop,
+		fastRequeue:       conf.FastRequeueOnShutdown,
+		handlerTimeout:    time.Duration(conf.HandlerTimeout) * time.Second,
+		drainTimeout:      time.Duration(conf.DrainTimeout) * time.Second,
+		healthInterval:    healthInterval,
+		heartbeat:         heartbeat,
+		maxExtension:      maxExtension,
+		queueReadyTimeout: time.Duration(conf.QueueReadyTimeout) * time.Second,
 		messageGroupID:    conf.MessageGroupID,
+		dedupIDTemplate:   conf.MessageDeduplicationID,
+		contentDedup:      conf.ContentBasedDeduplication,
 		attributes:        conf.Attributes,
 		tags:              conf.Tags,
-		queue:             conf.Queue,
 		visibilityTimeout: conf.VisibilityTimeout,
 		waitTime:          conf.WaitTimeSeconds,
-		pauseCh:           make(chan struct{}, 1),
+		bodyFormat:        conf.BodyFormat,
+		dlqEnrich:         conf.DLQEnrichMetadata || conf.MaxAppRetries > 0,
+		maxAppRetries:     conf.MaxAppRetries,
+		maxReceiveCount:   conf.MaxReceiveCount,
+		redriveRate:       conf.RedriveRate,
+		messageAgeSkew:    time.Duration(conf.MessageAgeSkew) * time.Second,
+		skewThreshold:     timestampSkewThreshold(conf.TimestampSkewThreshold),
+		overLimitBackoff:  inFlightLimitBackoff(conf.InFlightLimitBackoff),
+		statsCache:        newStatsCache(conf.StatsMaxStaleness),
+		verifyMD5:         conf.VerifyMD5,
+		snsUnwrap:         conf.SNSUnwrap,
+		decoders:          defaultDecoders(),
+		pollers:           conf.Pollers,
+		splitArrays:       conf.SplitOversizedArrays,
+		partitionAttr:     conf.PartitionKeyAttribute,
+		correlationAttr:   conf.CorrelationAttribute,
+		replyToAttr:       conf.ReplyToAttribute,
+		replyQueue:        conf.ReplyQueue,
+		replyAllowed:      replyAllowlist(conf.ReplyAllowedQueues),
+		sourceHeader:      conf.SourceQueueHeader,
+		netRetries:        netRetries(conf.NetworkRetries),
+		budget:            newRetryBudget(conf.RetryBudget, conf.RetryBudgetRefill),
+		queueEvents:       newQueueEvents(conf.QueueEventsBuffer),
+		events:            newEventBus(conf.EventsBuffer, log),
+		leaseTTL:          leaseDuration(conf.LeaseTTL, defaultLeaseTTL),
+		dedupStoreTTL:     leaseDuration(conf.DedupStoreTTL, defaultDedupStoreTTL),
+		enrichRetryDelay:  leaseDuration(conf.EnrichRetryDelay, defaultEnrichRetryDelay),
+		leaseRetryDelay:   leaseDuration(conf.LeaseRetryDelay, defaultLeaseRetryDelay),
+		preserveTypes:     conf.PreserveAttributeTypes,
+		throughput:        newThroughput(),
+		api:               newAPIMetrics(),
+		executeAtAttr:     conf.ExecuteAtAttribute,
+		deadlineAttr:      conf.DeadlineAttribute,
+		latency:           newLatencyMetrics(conf.LatencyMetrics, conf.LatencyBuckets),
+		payload:           newPayloadMetrics(conf.LatencyMetrics, conf.PayloadWarnBytes),
+		idleAfter:         time.Duration(conf.ScaleToZeroIdle) * time.Second,
+		hints:             hintNames{priority: conf.PriorityAttribute, delay: conf.DelayAttribute, job: conf.JobAttribute, defaultJob: conf.DefaultJob},
+		conf:              &conf,
 		// new in 2.12.1
 		msgInFlightLimit: ptr(conf.Prefetch),
 		msgInFlight:      ptr(int64(0)),
+		bytesInFlight:    ptr(int64(0)),
+		maxInFlightBytes: conf.MaxInFlightBytes,
+		receipts:         newReceiptTracker(),
+	}
+
+	jb.queue, jb.queueURL, err = queueTarget(conf.QueuePrefix, *conf.Queue)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = fifoQueue(jb.queue, jb.queueURL, jb.attributes, conf.FIFO, conf.ContentBasedDeduplication)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.queueURLFixed = jb.queueURL != nil
+	jb.queueOwner, err = checkAccountID(conf.QueueOwnerAccountID)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.crossAccountQueue()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.breaker, err = newCircuitBreaker(conf.CircuitBreakerThreshold, conf.CircuitBreakerCooldown, jb.log)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.adaptive, err = newAdaptivePollers(conf.AdaptiveMinPollers, conf.AdaptiveMaxPollers)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.pollers = jb.adaptive.clamp(jb.pollers)
+
+	jb.checkVisibility(conf.WorkerTimeout, conf.VisibilityMargin, conf.AutoAdjustVisibility)
+
+	err = jb.checkBodyFormat()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.bundledMeta, err = checkMetadataMode(conf.MetadataMode)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.atMostOnce, err = checkDelivery(conf.Delivery)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.order, err = checkReceiveOrder(conf.UsePriorityQueue, conf.PriorityAttribute)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.hints.priorities, err = parsePriorityMap(conf.PriorityMap)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.attrMapping, err = newAttributeMapping(conf.HeaderAttributes, conf.AttributeHeaders)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.emptyBodyPolicy, err = checkEmptyBodyPolicy(conf.EmptyBodyPolicy)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.bodyEncoding, err = checkBodyEncoding(conf.BodyEncoding)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.compression, jb.gzipLevel, err = checkCompression(conf.Compression, conf.GzipLevel)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.compressThreshold, err = checkCompressionThreshold(conf.CompressionThreshold)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.gzipDict, jb.gzipDictID, err = gzipDictionary(conf.GzipDictionary, jb.compression)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.maxDecompressed, err = checkMaxDecompressed(conf.MaxDecompressedSize)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.headers = newHeaderFilter(conf.PropagateHeaders, conf.RedactHeaders, prop.Fields())
+
+	jb.routes, err = newPipelineRoutes(conf.RouteAttribute, conf.RoutePipelines)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.shard, err = newShardAffinity(conf.ShardIndex, conf.ShardCount)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.aead, err = newBodyCipher(conf.EncryptionKey, conf.EncryptionKeyEnv)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.invalidBody, err = newInvalidBody(conf.InvalidBodyPolicy, conf.InvalidBodyQueue, conf.QueuePrefix)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.maxMessageAge, err = maxAge(conf.MaxJobAge, conf.MaxMessageAge)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.expiredQueue, jb.expiredURL, err = expiredQueue(conf.QueuePrefix, conf.ExpiredQueue, jb.maxMessageAge)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.fanOut, err = fanOutQueues(conf.QueuePrefix, conf.FanOutQueues, jb.queue)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.offload, err = newPayloadOffload(conf.S3Bucket, conf.S3Prefix, conf.AlwaysThroughS3, conf.S3Threshold)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.offload.withAttributes(conf.S3OffloadAttributes)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.limiter, err = newRateLimiter(conf.RateLimit, conf.RateLimitBurst)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.waitTime, err = receiveWaitTime(conf.WaitTimeSeconds)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.poll, err = newReceivePoll(int(conf.MaxMessagesPerPoll), conf.MessageAttributeNames, conf.MessageSystemAttributeNames)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkRequestTimeout(conf.HTTPClient.RequestTimeout, jb.waitTime)
+	if err != nil {
+		return nil, errors.E(op, err)
 	}
 
 	// PARSE CONFIGURATION -------
-	jb.client, err = checkEnv(insideAWS, conf.Key, conf.Secret, conf.SessionToken, conf.Endpoint, conf.Region)
+	jb.client, err = newClient(insideAWS, &conf, log, time.Duration(conf.ClientMaxLifetime)*time.Second)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.client = withRetryBudget(withAPIMetrics(jb.client, jb.api), jb.budget)
+
+	err = jb.initFailover(insideAWS, &conf, conf.FailoverQueue, conf.FailoverThreshold, conf.FailoverProbeInterval, time.Duration(conf.ClientMaxLifetime)*time.Second)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.failoverReceive, err = checkFailoverReceive(conf.FailoverReceive, jb.failover)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var dlq *string
+	if conf.DeadLetterQueue != "" {
+		dlq, jb.dlqURL, err = queueTarget(conf.QueuePrefix, conf.DeadLetterQueue)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	err = checkMaxAppRetries(conf.MaxAppRetries, conf.DeadLetterQueue)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkMaxReceiveCount(jb.maxReceiveCount, conf.DeadLetterQueue, jb.attributes)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkRedriveRate(jb.redriveRate)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if conf.RetryQueue != "" {
+		err = checkRetryDelay(conf.RetryDelay)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		jb.retryQueue, jb.retryURL, err = queueTarget(conf.QueuePrefix, conf.RetryQueue)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		jb.retryDelay = int32(conf.RetryDelay)
+	}
+
+	jb.nackBackoff, err = newNackBackoff(conf.NackBackoff, conf.NackBackoffBase, conf.NackBackoffMax, jb.retryQueue != nil)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.onNack, err = newNackPolicy(conf.OnNack, conf.NackReleaseDelay, jb.dlqURL != nil || dlq != nil, jb.retryQueue != nil, jb.nackBackoff != nil)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// declare or resolve the queues
+	err = jb.setup(time.Duration(conf.SetupTimeout)*time.Second, conf.SkipPermissionCheck, dlq)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	// if the queue is already declared and user do not want to
-	err = manageQueue(jb)
+	jb.groups, err = groupOrdering(conf.StrictGroupOrdering, conf.GroupMaxParked)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if conf.DedupWindow > 0 {
+		jb.dedup = newDedupSet(time.Duration(conf.DedupWindow)*time.Second, conf.DedupMaxEntries)
+		jb.dedupDelete = conf.DedupDelete
+	}
+
+	if conf.DedupMaxEntries < 0 {
+		return nil, errors.E(op, errors.Errorf("dedup_max_entries should not be negative, provided: %d", conf.DedupMaxEntries))
+	}
+	jb.dedupAttribute = conf.DedupAttribute
+
+	err = jb.initSendBatcher(conf.SendBatch.MaxSize, conf.SendBatch.MaxBytes, conf.SendBatch.FlushInterval, conf.SendBatch.CancelFlush)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.initDeleteBatcher(conf.DeleteBatch.MaxSize, conf.DeleteBatch.FlushInterval, conf.DeleteFlushCount)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
 	jb.pipeline.Store(&pipe)
+	jb.initDispatcher(conf.DispatchBuffer)
+	jb.warmPool = newWarmPool(conf.WarmPoolSize)
+	jb.logStartup(&conf, insideAWS)
 
 	// To successfully create a new queue, you must provide a
 	// queue name that adheres to the limits related to queues
@@ -166,11 +727,41 @@ func FromConfig(tracer *sdktrace.TracerProvider, configKey string, pipe jobs.Pip
 	// and is unique within the scope of your queues. After you create a queue, you
 	// must wait at least one second after the queue is created to be able to use the <------------
 	// queue. To get the queue URL, use the GetQueueUrl action. GetQueueUrl require
-	time.Sleep(time.Second)
+	// The queue not declared by the pipeline (skip_queue_declaration, queue_owner_account_id) is used right away.
+	if !jb.skipDeclare {
+		time.Sleep(time.Second)
+	}
 
 	return jb, nil
 }
 
+// ReadConfig reads the pipeline configuration merged with the global sqs section
+func ReadConfig(configKey string, cfg Configurer) (*Config, error) {
+	var conf Config
+	err := cfg.UnmarshalKey(configKey, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse global config if exists
+	if cfg.Has(pluginName) {
+		err = cfg.UnmarshalKey(pluginName, &conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conf.InitDefault()
+
+	// ${VAR} and env:VAR values, e.g. for the credentials
+	err = expandConfigEnv(&conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
 func FromPipeline(tracer *sdktrace.TracerProvider, pipe jobs.Pipeline, log *zap.Logger, cfg Configurer, pq jobs.Queue) (*Driver, error) {
 	const op = errors.Op("new_sqs_consumer")
 
@@ -179,89 +770,536 @@ func FromPipeline(tracer *sdktrace.TracerProvider, pipe jobs.Pipeline, log *zap.
 		1. Non-AWS - global sqs config should be set
 		2. AWS - configuration should be obtained from the env
 	*/
-	insideAWS := false
-	if isInAWS() || isinAWSIMDSv2() {
-		insideAWS = true
+
+	// PARSE CONFIGURATION -------
+	var conf Config
+
+	// parse global config if exists
+	if cfg.Has(pluginName) {
+		err := cfg.UnmarshalKey(pluginName, &conf)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
 	}
 
-	// if no global section
-	if !cfg.Has(pluginName) && !insideAWS {
+	conf.InitDefault()
+
+	// ${VAR} and env:VAR values, e.g. for the credentials
+	err := expandConfigEnv(&conf)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = expandPipelineEnv(pipe)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	conf.InMemory = pipe.Bool(inMemoryOpt, conf.InMemory)
+	conf.Chaos, err = pipelineChaos(pipe, chaosOpt, conf.Chaos)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	insideAWS, err := resolveInsideAWS(&conf)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// if no global section, the in_memory queues need no configuration
+	if !cfg.Has(pluginName) && !insideAWS && !conf.InMemory {
 		return nil, errors.E(op, errors.Str("no global sqs configuration, global configuration should contain sqs section"))
 	}
 
-	if tracer == nil {
-		tracer = sdktrace.NewTracerProvider()
+	tracingMode := strings.ToLower(pipe.String(tracing, conf.Tracing))
+	tp, err := newTracerProvider(tracingMode, tracer)
+	if err != nil {
+		return nil, errors.E(op, err)
 	}
 
 	prop := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, jprop.Jaeger{})
 	otel.SetTextMapPropagator(prop)
 
+	attr := make(map[string]string)
+	err = pipe.Map(attributes, attr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = managedSSE(attr, pipe.Bool(sseManaged, conf.SSEManaged))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// wait_time_seconds, the same as in the config, takes precedence over the wait_time
+	wait := int32(pipe.Int(waitTimeSeconds, pipe.Int(waitTime, 0))) //nolint:gosec
+	err = queueWaitTime(attr, pipe.Bool(setQueueWaitTime, conf.SetQueueWaitTime), wait)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	heartbeat, maxExtension, err := heartbeatConfig(pipe.Int(heartbeatOpt, conf.VisibilityHeartbeat), pipe.Int(maxVisibilityExt, conf.MaxVisibilityExtension))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	healthInterval, err := healthCheckConfig(pipe.Int(healthCheckOpt, conf.HealthCheckInterval))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	tg := make(map[string]string)
+	err = pipe.Map(tags, tg)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// pipeline prefix overrides the global one
+	prefix := pipe.String(queuePrefix, conf.QueuePrefix)
+
+	// initialize job Driver
+	jb := &Driver{
+		tracer:            tp,
+		tracingOff:        tracingMode == tracingDisabled,
+		prop:              prop,
+		cond:              sync.Cond{L: &sync.Mutex{}},
+		pq:                pq,
+		log:               log,
+		messageGroupID:    pipe.String(messageGroupID, ""),
+		dedupIDTemplate:   pipe.String(messageDedupID, conf.MessageDeduplicationID),
+		contentDedup:      pipe.Bool(contentDedup, conf.ContentBasedDeduplication),
+		attributes:        attr,
+		tags:              tg,
+		skipDeclare:       pipe.Bool(skipQueueDeclaration, false),
+		lookupFirst:       pipe.Bool(lookupBeforeCreate, lookupEnabled(conf.LookupBeforeCreate)),
+		recreate:          pipe.Bool(recreateQueueOpt, lookupEnabled(conf.RecreateQueue)),
+		deleteOnStop:      pipe.Bool(deleteOnStop, false),
+		fastRequeue:       pipe.Bool(fastRequeueShutdown, conf.FastRequeueOnShutdown),
+		handlerTimeout:    time.Duration(pipe.Int(handlerTimeout, conf.HandlerTimeout)) * time.Second,
+		drainTimeout:      time.Duration(pipe.Int(drainTimeout, conf.DrainTimeout)) * time.Second,
+		healthInterval:    healthInterval,
+		heartbeat:         heartbeat,
+		maxExtension:      maxExtension,
+		queueReadyTimeout: time.Duration(pipe.Int(queueReadyTimeout, conf.QueueReadyTimeout)) * time.Second,
+		visibilityTimeout: int32(pipe.Int(visibility, 0)),
+		waitTime:          wait,
+		bodyFormat:        strings.ToLower(pipe.String(bodyFormat, "")),
+		dlqEnrich:         pipe.Bool(dlqEnrichMetadata, conf.DLQEnrichMetadata) || pipe.Int(maxAppRetries, conf.MaxAppRetries) > 0,
+		maxAppRetries:     pipe.Int(maxAppRetries, conf.MaxAppRetries),
+		maxReceiveCount:   pipe.Int(maxReceiveCount, conf.MaxReceiveCount),
+		redriveRate:       pipe.Int(redriveRate, conf.RedriveRate),
+		messageAgeSkew:    time.Duration(pipe.Int(messageAgeSkew, 0)) * time.Second,
+		skewThreshold:     timestampSkewThreshold(pipe.Int(skewThresholdOpt, conf.TimestampSkewThreshold)),
+		overLimitBackoff:  inFlightLimitBackoff(pipe.Int(overLimitBackoffOpt, conf.InFlightLimitBackoff)),
+		statsCache:        newStatsCache(pipe.Int(statsMaxStaleness, conf.StatsMaxStaleness)),
+		verifyMD5:         pipe.Bool(verifyMD5, conf.VerifyMD5),
+		snsUnwrap:         pipe.Bool(snsUnwrap, conf.SNSUnwrap),
+		decoders:          defaultDecoders(),
+		pollers:           pollersCount(pipe.Int(pollers, conf.Pollers)),
+		splitArrays:       pipe.Bool(splitArrays, false),
+		partitionAttr:     pipe.String(partitionKeyOpt, conf.PartitionKeyAttribute),
+		correlationAttr:   pipe.String(correlationAttribute, conf.CorrelationAttribute),
+		replyToAttr:       pipe.String(replyToAttribute, conf.ReplyToAttribute),
+		replyQueue:        pipe.String(replyQueueOpt, conf.ReplyQueue),
+		sourceHeader:      pipe.String(sourceQueueHeader, conf.SourceQueueHeader),
+		netRetries:        netRetries(pipe.Int(networkRetries, conf.NetworkRetries)),
+		budget:            newRetryBudget(pipe.Int(retryBudgetOpt, conf.RetryBudget), pipe.Int(retryBudgetRefill, conf.RetryBudgetRefill)),
+		queueEvents:       newQueueEvents(pipe.Int(queueEventsBuffer, conf.QueueEventsBuffer)),
+		events:            newEventBus(pipe.Int(eventsBuffer, conf.EventsBuffer), log),
+		leaseTTL:          leaseDuration(pipe.Int(leaseTTL, conf.LeaseTTL), defaultLeaseTTL),
+		dedupStoreTTL:     leaseDuration(pipe.Int(dedupStoreTTL, conf.DedupStoreTTL), defaultDedupStoreTTL),
+		enrichRetryDelay:  leaseDuration(pipe.Int(enrichRetryDelay, conf.EnrichRetryDelay), defaultEnrichRetryDelay),
+		leaseRetryDelay:   leaseDuration(pipe.Int(leaseRetryDelay, conf.LeaseRetryDelay), defaultLeaseRetryDelay),
+		preserveTypes:     pipe.Bool(preserveAttrTypes, conf.PreserveAttributeTypes),
+		throughput:        newThroughput(),
+		api:               newAPIMetrics(),
+		executeAtAttr:     pipe.String(executeAtAttribute, conf.ExecuteAtAttribute),
+		deadlineAttr:      pipe.String(deadlineAttribute, conf.DeadlineAttribute),
+		latency:           newLatencyMetrics(pipe.Bool(latencyMetricsOpt, conf.LatencyMetrics), conf.LatencyBuckets),
+		payload:           newPayloadMetrics(pipe.Bool(latencyMetricsOpt, conf.LatencyMetrics), pipe.Int(payloadWarnBytes, conf.PayloadWarnBytes)),
+		idleAfter:         time.Duration(pipe.Int(scaleToZeroIdle, 0)) * time.Second,
+		hints:             hintNames{priority: pipe.String(priorityAttribute, ""), delay: pipe.String(delayAttribute, ""), job: pipe.String(jobAttribute, ""), defaultJob: pipe.String(defaultJob, conf.DefaultJob)},
+		// new in 2.12.1
+		msgInFlightLimit: ptr(int32(pipe.Int(pref, 10))),
+		msgInFlight:      ptr(int64(0)),
+		bytesInFlight:    ptr(int64(0)),
+		maxInFlightBytes: int64(pipe.Int(maxInFlightBytes, int(conf.MaxInFlightBytes))),
+		receipts:         newReceiptTracker(),
+	}
+
 	// PARSE CONFIGURATION -------
-	var conf Config
 
-	// parse global config if exists
-	if cfg.Has(pluginName) {
-		err := cfg.UnmarshalKey(pluginName, &conf)
+	jb.queue, jb.queueURL, err = queueTarget(prefix, pipe.String(queue, "default"))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = fifoQueue(jb.queue, jb.queueURL, jb.attributes, pipe.Bool(fifoOpt, conf.FIFO), jb.contentDedup)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.queueURLFixed = jb.queueURL != nil
+	jb.queueOwner, err = checkAccountID(pipe.String(queueOwnerAccountID, conf.QueueOwnerAccountID))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.crossAccountQueue()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.breaker, err = newCircuitBreaker(pipe.Int(breakerThreshold, conf.CircuitBreakerThreshold), pipe.Int(breakerCooldown, conf.CircuitBreakerCooldown), jb.log)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.adaptive, err = newAdaptivePollers(pipe.Int(adaptiveMinPollers, conf.AdaptiveMinPollers), pipe.Int(adaptiveMaxPollers, conf.AdaptiveMaxPollers))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.pollers = jb.adaptive.clamp(jb.pollers)
+
+	jb.checkVisibility(pipe.Int(workerTimeout, conf.WorkerTimeout), pipe.Int(visibilityMargin, conf.VisibilityMargin), pipe.Bool(autoAdjustVisibility, conf.AutoAdjustVisibility))
+
+	err = jb.checkBodyFormat()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.bundledMeta, err = checkMetadataMode(strings.ToLower(pipe.String(metadataMode, conf.MetadataMode)))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.atMostOnce, err = checkDelivery(strings.ToLower(pipe.String(deliveryMode, conf.Delivery)))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	usePQ := conf.UsePriorityQueue
+	if pipe.Has(usePriorityQueue) {
+		usePQ = ptr(pipe.Bool(usePriorityQueue, true))
+	}
+	jb.order, err = checkReceiveOrder(usePQ, jb.hints.priority)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// the pipeline map replaces the global one
+	pm := make(map[string]string)
+	err = pipe.Map(priorityMap, pm)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if len(pm) == 0 {
+		pm = conf.PriorityMap
+	}
+
+	jb.hints.priorities, err = parsePriorityMap(pm)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// the pipeline maps replace the global ones
+	ha := make(map[string]string)
+	err = pipe.Map(headerAttributesOpt, ha)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if len(ha) == 0 {
+		ha = conf.HeaderAttributes
+	}
+
+	ah := make(map[string]string)
+	err = pipe.Map(attributeHeadersOpt, ah)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if len(ah) == 0 {
+		ah = conf.AttributeHeaders
+	}
+
+	jb.attrMapping, err = newAttributeMapping(ha, ah)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.emptyBodyPolicy, err = checkEmptyBodyPolicy(strings.ToLower(pipe.String(emptyBodyPolicy, conf.EmptyBodyPolicy)))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.bodyEncoding, err = checkBodyEncoding(strings.ToLower(pipe.String(bodyEncoding, conf.BodyEncoding)))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.compression, jb.gzipLevel, err = checkCompression(strings.ToLower(pipe.String(compression, conf.Compression)), pipe.Int(gzipLevel, conf.GzipLevel))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.compressThreshold, err = checkCompressionThreshold(pipe.Int(compressionThreshold, conf.CompressionThreshold))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.gzipDict, jb.gzipDictID, err = gzipDictionary(pipe.String(gzipDictionaryOpt, conf.GzipDictionary), jb.compression)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.maxDecompressed, err = checkMaxDecompressed(pipe.Int(maxDecompressedSize, conf.MaxDecompressedSize))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	allow, deny := conf.PropagateHeaders, conf.RedactHeaders
+	if pipe.Has(propagateHeaders) {
+		allow = headerList(pipe.String(propagateHeaders, ""))
+	}
+	if pipe.Has(redactHeaders) {
+		deny = headerList(pipe.String(redactHeaders, ""))
+	}
+	jb.headers = newHeaderFilter(allow, deny, prop.Fields())
+
+	routes := conf.RoutePipelines
+	if pipe.Has(routePipelines) {
+		routes = headerList(pipe.String(routePipelines, ""))
+	}
+	jb.routes, err = newPipelineRoutes(pipe.String(routeAttribute, conf.RouteAttribute), routes)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.shard, err = newShardAffinity(pipe.Int(shardIndex, conf.ShardIndex), pipe.Int(shardCount, conf.ShardCount))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.aead, err = newBodyCipher(pipe.String(encryptionKey, conf.EncryptionKey), pipe.String(encryptionKeyEnv, conf.EncryptionKeyEnv))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.invalidBody, err = newInvalidBody(pipe.String(invalidBodyPolicy, conf.InvalidBodyPolicy), pipe.String(invalidBodyQueueOpt, conf.InvalidBodyQueue), prefix)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.maxMessageAge, err = maxAge(pipe.Int(maxJobAge, 0), pipe.Int(maxMessageAge, 0))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.expiredQueue, jb.expiredURL, err = expiredQueue(prefix, pipe.String(expiredQueueOpt, ""), jb.maxMessageAge)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	replyAllowed := conf.ReplyAllowedQueues
+	if pipe.Has(replyAllowedQueues) {
+		replyAllowed = headerList(pipe.String(replyAllowedQueues, ""))
+	}
+	jb.replyAllowed = replyAllowlist(replyAllowed)
+
+	fanOut := conf.FanOutQueues
+	if pipe.Has(fanOutQueuesOpt) {
+		fanOut = headerList(pipe.String(fanOutQueuesOpt, ""))
+	}
+	jb.fanOut, err = fanOutQueues(prefix, fanOut, jb.queue)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.offload, err = newPayloadOffload(pipe.String(s3Bucket, conf.S3Bucket), pipe.String(s3Prefix, conf.S3Prefix), pipe.Bool(alwaysThroughS3, conf.AlwaysThroughS3), pipe.Int(s3Threshold, conf.S3Threshold))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.offload.withAttributes(pipe.Bool(s3OffloadAttributes, conf.S3OffloadAttributes))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.limiter, err = newRateLimiter(pipe.Int(rateLimitOpt, conf.RateLimit), pipe.Int(rateLimitBurst, conf.RateLimitBurst))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// pipeline profile overrides the global one
+	conf.Profile = pipe.String(profile, conf.Profile)
+	conf.SharedConfigFile = pipe.String(sharedConfigFile, conf.SharedConfigFile)
+	conf.SharedCredentialsFile = pipe.String(sharedCredsFile, conf.SharedCredentialsFile)
+	conf.RoleARN = pipe.String(roleARN, conf.RoleARN)
+	conf.ExternalID = pipe.String(externalID, conf.ExternalID)
+	conf.RoleSessionName = pipe.String(roleSessionName, conf.RoleSessionName)
+	conf.WebIdentityTokenFile = pipe.String(webIdentityToken, conf.WebIdentityTokenFile)
+	if pipe.Has(credentialChainOpt) {
+		conf.CredentialChain = headerList(pipe.String(credentialChainOpt, ""))
+	}
+	conf.AWSLogMode = pipe.String(awsLogMode, conf.AWSLogMode)
+	conf.SkipWarmup = pipe.Bool(skipWarmup, conf.SkipWarmup)
+	conf.DNSCacheTTL = pipe.Int(dnsCacheTTL, conf.DNSCacheTTL)
+	conf.HTTPClient, err = pipelineHTTPClient(pipe, httpClientOpt, conf.HTTPClient)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.waitTime, err = receiveWaitTime(jb.waitTime)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	messageAttrs, systemAttrs := conf.MessageAttributeNames, conf.MessageSystemAttributeNames
+	if pipe.Has(messageAttrNames) {
+		messageAttrs = headerList(pipe.String(messageAttrNames, ""))
+	}
+	if pipe.Has(systemAttrNames) {
+		systemAttrs = headerList(pipe.String(systemAttrNames, ""))
+	}
+	jb.poll, err = newReceivePoll(pipe.Int(maxMessagesPerPoll, int(conf.MaxMessagesPerPoll)), messageAttrs, systemAttrs)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkRequestTimeout(conf.HTTPClient.RequestTimeout, jb.waitTime)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	conf.EndpointDiscovery = pipe.String(endpointDiscovery, conf.EndpointDiscovery)
+	if pipe.Has(endpointOpt) {
+		conf.Endpoint = pipe.String(endpointOpt, "")
+		conf.pipelineEndpoint = conf.Endpoint
+	}
+
+	jb.client, err = newClient(insideAWS, &conf, log, time.Duration(pipe.Int(clientMaxLifetime, conf.ClientMaxLifetime))*time.Second)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	jb.client = withRetryBudget(withAPIMetrics(jb.client, jb.api), jb.budget)
+
+	conf.FailoverRegion = pipe.String(failoverRegion, conf.FailoverRegion)
+	err = jb.initFailover(insideAWS, &conf, pipe.String(failoverQueue, conf.FailoverQueue), pipe.Int(failoverThreshold, conf.FailoverThreshold),
+		pipe.Int(failoverProbe, conf.FailoverProbeInterval), time.Duration(pipe.Int(clientMaxLifetime, conf.ClientMaxLifetime))*time.Second)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	jb.failoverReceive, err = checkFailoverReceive(pipe.Bool(failoverReceiveOpt, conf.FailoverReceive), jb.failover)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var dlq *string
+	dlqName := pipe.String(deadLetterQueue, conf.DeadLetterQueue)
+	if dlqName != "" {
+		dlq, jb.dlqURL, err = queueTarget(prefix, dlqName)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	err = checkMaxAppRetries(jb.maxAppRetries, dlqName)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkMaxReceiveCount(jb.maxReceiveCount, dlqName, jb.attributes)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkRedriveRate(jb.redriveRate)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if name := pipe.String(retryQueue, conf.RetryQueue); name != "" {
+		err = checkRetryDelay(pipe.Int(retryDelay, conf.RetryDelay))
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		jb.retryQueue, jb.retryURL, err = queueTarget(prefix, name)
 		if err != nil {
 			return nil, errors.E(op, err)
 		}
+		jb.retryDelay = int32(pipe.Int(retryDelay, conf.RetryDelay))
 	}
 
-	conf.InitDefault()
+	jb.nackBackoff, err = newNackBackoff(pipe.String(nackBackoffOpt, conf.NackBackoff), pipe.Int(nackBackoffBase, conf.NackBackoffBase), pipe.Int(nackBackoffMax, conf.NackBackoffMax), jb.retryQueue != nil)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
 
-	attr := make(map[string]string)
-	err := pipe.Map(attributes, attr)
+	jb.onNack, err = newNackPolicy(pipe.String(onNackOpt, conf.OnNack), pipe.Int(nackReleaseDelay, conf.NackReleaseDelay), jb.dlqURL != nil || dlq != nil, jb.retryQueue != nil, jb.nackBackoff != nil)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	tg := make(map[string]string)
-	err = pipe.Map(tags, tg)
+	// declare or resolve the queues
+	err = jb.setup(time.Duration(pipe.Int(setupTimeout, conf.SetupTimeout))*time.Second, pipe.Bool(skipPermissionCheck, false), dlq)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	// initialize job Driver
-	jb := &Driver{
-		tracer:            tracer,
-		prop:              prop,
-		cond:              sync.Cond{L: &sync.Mutex{}},
-		pq:                pq,
-		log:               log,
-		messageGroupID:    pipe.String(messageGroupID, ""),
-		attributes:        attr,
-		tags:              tg,
-		skipDeclare:       pipe.Bool(skipQueueDeclaration, false),
-		queue:             aws.String(pipe.String(queue, "default")),
-		visibilityTimeout: int32(pipe.Int(visibility, 0)),
-		waitTime:          int32(pipe.Int(waitTime, 0)),
-		pauseCh:           make(chan struct{}, 1),
-		// new in 2.12.1
-		msgInFlightLimit: ptr(int32(pipe.Int(pref, 10))),
-		msgInFlight:      ptr(int64(0)),
+	jb.groups, err = groupOrdering(pipe.Bool(strictGroupOrdering, conf.StrictGroupOrdering), pipe.Int(groupMaxParked, conf.GroupMaxParked))
+	if err != nil {
+		return nil, errors.E(op, err)
 	}
 
-	// PARSE CONFIGURATION -------
+	maxEntries := pipe.Int(dedupMaxEntries, conf.DedupMaxEntries)
+	if dw := pipe.Int(dedupWindow, conf.DedupWindow); dw > 0 {
+		jb.dedup = newDedupSet(time.Duration(dw)*time.Second, maxEntries)
+		jb.dedupDelete = pipe.Bool(dedupDelete, conf.DedupDelete)
+	}
+
+	if maxEntries < 0 {
+		return nil, errors.E(op, errors.Errorf("dedup_max_entries should not be negative, provided: %d", maxEntries))
+	}
+	jb.dedupAttribute = pipe.String(dedupAttribute, conf.DedupAttribute)
+
+	// the flat batch options predate the send_batch block
+	sb, err := pipelineBatch(pipe, sendBatchOpt, BatchConfig{
+		MaxSize:       pipe.Int(batchSize, 0),
+		FlushInterval: pipe.Int(batchFlushInterval, 0),
+		MaxBytes:      pipe.Int(maxBatchBytesOpt, 0),
+	})
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = jb.initSendBatcher(sb.MaxSize, sb.MaxBytes, sb.FlushInterval, sb.CancelFlush)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
 
-	jb.client, err = checkEnv(insideAWS, conf.Key, conf.Secret, conf.SessionToken, conf.Endpoint, conf.Region)
+	db, err := pipelineBatch(pipe, deleteBatchOpt, BatchConfig{})
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	// if the queue is already declared and user do not want to
-	err = manageQueue(jb)
+	err = jb.initDeleteBatcher(db.MaxSize, db.FlushInterval, pipe.Int(deleteFlushCount, conf.DeleteFlushCount))
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
 	jb.pipeline.Store(&pipe)
+	jb.initDispatcher(dispatchBufferSize(pipe.Int(dispatchBuffer, 0)))
+	jb.warmPool = newWarmPool(pipe.Int(warmPoolSize, conf.WarmPoolSize))
+	jb.logStartup(&conf, insideAWS)
+
 	// To successfully create a new queue, you must provide a
 	// queue name that adheres to the limits related to queues
 	// (https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/limits-queues.html)
 	// and is unique within the scope of your queues. After you create a queue, you
 	// must wait at least one second after the queue is created to be able to use the <------------
 	// queue. To get the queue URL, use the GetQueueUrl action. GetQueueUrl require
-	time.Sleep(time.Second)
+	// The queue not declared by the pipeline (skip_queue_declaration, queue_owner_account_id) is used right away.
+	if !jb.skipDeclare {
+		time.Sleep(time.Second)
+	}
 
 	return jb, nil
 }
@@ -270,7 +1308,7 @@ func (c *Driver) Push(ctx context.Context, jb jobs.Message) error {
 	const op = errors.Op("sqs_push")
 	// check if the pipeline registered
 
-	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_push")
+	ctx, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_push", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(c.queueSpanAttributes()...))
 	defer span.End()
 
 	// load atomic value
@@ -281,15 +1319,36 @@ func (c *Driver) Push(ctx context.Context, jb jobs.Message) error {
 
 	// The length of time, in seconds, for which to delay a specific message. Valid
 	// values: 0 to 900. Maximum: 15 minutes.
-	if jb.Delay() > 900 {
-		return errors.E(op, errors.Errorf("unable to push, maximum possible delay is 900 seconds (15 minutes), provided: %d", jb.Delay()))
+	// the longer delays are scheduled with the execute_at_attribute
+	if jb.Delay() > maxDelay && c.executeAtAttr == "" {
+		return errors.E(op, errors.Errorf("unable to push, maximum possible delay is 900 seconds (15 minutes) without the execute_at_attribute, provided: %d", jb.Delay()))
 	}
 
-	err := c.handleItem(ctx, fromJob(jb))
+	var err error
+	if c.splitArrays {
+		err = c.pushSplit(ctx, fromJob(jb))
+	} else {
+		err = c.pushItem(ctx, fromJob(jb))
+		// the queue was deleted out-of-band, the message is sent again once the queue is recreated. The split arrays
+		// are not retried, some parts might be sent already, as well as the fan-out, the destinations got the message.
+		if err != nil && isNonExistentQueue(err) {
+			c.failure(err)
+			rerr := c.recreateQueue(ctx)
+			switch {
+			case rerr != nil:
+				c.log.Error("failed to recreate the queue", zap.Error(rerr))
+			case len(c.fanOut) == 0:
+				err = c.pushItem(ctx, fromJob(jb))
+			}
+		}
+	}
 	if err != nil {
+		c.failure(err)
 		return errors.E(op, err)
 	}
 
+	c.success()
+	c.throughput.push()
 	return nil
 }
 
@@ -297,7 +1356,7 @@ func (c *Driver) Run(ctx context.Context, p jobs.Pipeline) error {
 	start := time.Now().UTC()
 	const op = errors.Op("sqs_run")
 
-	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_run")
+	_, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_run")
 	defer span.End()
 
 	c.mu.Lock()
@@ -308,13 +1367,28 @@ func (c *Driver) Run(ctx context.Context, p jobs.Pipeline) error {
 		return errors.E(op, errors.Errorf("no such pipeline registered: %s", pipe.Name()))
 	}
 
+	// s3_bucket without the payload store, fail on start instead of the first push
+	err := c.checkPayloadStore()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// the compression codec (e.g. zstd) should be registered before the start as well
+	err = c.checkCodec()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
 	atomic.AddUint32(&c.listeners, 1)
+	c.notReady("waiting for the first receive")
 
-	// start listener
+	// start listeners
 	var ctxCancel context.Context
 	ctxCancel, c.cancel = context.WithCancel(context.Background())
-	c.listen(ctxCancel)
+	c.startPollers(ctxCancel)
+	c.startHealthCheck(ctxCancel)
 
+	c.emitEvent(EventPipelineStarted, "", "", nil)
 	c.log.Debug("pipeline was started", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("start", start), zap.Duration("elapsed", time.Since(start)))
 	return nil
 }
@@ -322,31 +1396,66 @@ func (c *Driver) Run(ctx context.Context, p jobs.Pipeline) error {
 func (c *Driver) Stop(ctx context.Context) error {
 	start := time.Now().UTC()
 
-	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_stop")
+	_, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_stop")
 	defer span.End()
 
-	atomic.StoreUint64(&c.stopped, 1)
 	pipe := *c.pipeline.Load()
-	_ = c.pq.Remove(pipe.Name())
+	// drain_timeout, the acks of the workers should succeed until the pipeline is marked as stopped
+	if c.drainTimeout > 0 {
+		c.drainOnStop(ctx, pipe.Name())
+	}
+
+	atomic.StoreUint64(&c.stopped, 1)
+	c.notReady("pipeline is stopped")
 
+	// cancel the pollers first, the dispatcher keeps draining the buffer until it is stopped
 	if atomic.LoadUint32(&c.listeners) > 0 {
+		// stop all listeners
 		if c.cancel != nil {
 			c.cancel()
 		}
-		// if blocked, let 1 item to pass to unblock the listener and close the pipe
-		c.cond.Signal()
+		// if blocked, wake up the listeners to close the pipe
+		c.cond.Broadcast()
+	}
+
+	if c.fastRequeue {
+		// the pollers might be dispatching the last received messages, they are collected once the pollers exit
+		ctxT, cancel := context.WithTimeout(ctx, pausePollersTimeout)
+		if !c.waitPollers(ctxT) {
+			c.log.Warn("stop timeout, the pollers are still running", zap.String("pipeline", pipe.Name()), zap.Int32("pollers", atomic.LoadInt32(&c.activePollers)))
+		}
+		cancel()
+	}
 
-		c.pauseCh <- struct{}{}
+	c.stopDispatcher()
+
+	if c.fastRequeue {
+		// the buffered items might be still moving to the priority queue
+		c.waitDispatcher()
+		c.requeueUnstarted(ctx, c.unstarted(c.pq.Remove(pipe.Name())))
+	} else {
+		_ = c.pq.Remove(pipe.Name())
 	}
 
+	c.StopRedrive()
+	c.deleteCreatedQueue(ctx)
+	c.releaseClients()
+
+	c.emitEvent(EventPipelineStopped, "", "", nil)
+	c.events.close()
 	c.log.Debug("pipeline was stopped", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("start", time.Now().UTC()), zap.Duration("elapsed", time.Since(start)))
 	return nil
 }
 
+// Stopped returns true once the pipeline was stopped (destroyed), the stopped pipeline can't be started again
+func (c *Driver) Stopped() bool {
+	return atomic.LoadUint64(&c.stopped) == 1
+}
+
 func (c *Driver) Pause(ctx context.Context, p string) error {
 	start := time.Now().UTC()
 
-	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_pause")
+	_, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_pause")
 	defer span.End()
 
 	// load atomic value
@@ -362,16 +1471,21 @@ func (c *Driver) Pause(ctx context.Context, p string) error {
 	}
 
 	atomic.AddUint32(&c.listeners, ^uint32(0))
+	atomic.StoreInt64(&c.pausedAt, time.Now().UnixNano())
+	c.notReady("pipeline is paused")
 
+	// stop consume
 	if c.cancel != nil {
 		c.cancel()
 	}
 
-	// stop consume
-	c.pauseCh <- struct{}{}
-	// if blocked, let 1 item to pass to unblock the listener and close the pipe
-	c.cond.Signal()
+	// if blocked, wake up the listeners to close the pipe
+	c.cond.Broadcast()
 
+	// the in-progress long polls are canceled, no receives (and no requests billed) while paused
+	c.releaseOnPause(ctx, pipe.Name())
+
+	c.emitEvent(EventPipelinePaused, "", "", nil)
 	c.log.Debug("pipeline was paused", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("start", time.Now().UTC()), zap.Duration("elapsed", time.Since(start)))
 
 	return nil
@@ -380,7 +1494,7 @@ func (c *Driver) Pause(ctx context.Context, p string) error {
 func (c *Driver) Resume(ctx context.Context, p string) error {
 	start := time.Now().UTC()
 
-	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_resume")
+	_, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_resume")
 	defer span.End()
 
 	c.mu.Lock()
@@ -398,13 +1512,20 @@ func (c *Driver) Resume(ctx context.Context, p string) error {
 		return errors.Str("sqs listener is already in the active state")
 	}
 
-	// start listener
+	c.notReady("waiting for the first receive")
+	// the failures before the pause don't keep the resumed pollers waiting
+	c.breaker.success()
+
+	// start listeners with the current pollers, visibility and wait time
 	var ctxCancel context.Context
 	ctxCancel, c.cancel = context.WithCancel(context.Background())
-	c.listen(ctxCancel)
+	c.startPollers(ctxCancel)
+	c.startHealthCheck(ctxCancel)
 
 	// increase num of listeners
 	atomic.AddUint32(&c.listeners, 1)
+	atomic.StoreInt64(&c.resumedAt, time.Now().UnixNano())
+	c.emitEvent(EventPipelineResumed, "", "", nil)
 	c.log.Debug("pipeline was resumed", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("start", time.Now().UTC()), zap.Duration("elapsed", time.Since(start)))
 
 	return nil
@@ -413,17 +1534,14 @@ func (c *Driver) Resume(ctx context.Context, p string) error {
 func (c *Driver) State(ctx context.Context) (*jobs.State, error) {
 	const op = errors.Op("sqs_state")
 
-	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "sqs_state")
+	ctx, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_state")
 	defer span.End()
 
-	attr, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
-		QueueUrl: c.queueURL,
-		AttributeNames: []types.QueueAttributeName{
-			types.QueueAttributeNameApproximateNumberOfMessages,
-			types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
-			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
-		},
-	})
+	attr, err := c.depthAttributes(ctx, c.queueURL,
+		types.QueueAttributeNameApproximateNumberOfMessages,
+		types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+	)
 
 	if err != nil {
 		return nil, errors.E(op, err)
@@ -439,17 +1557,17 @@ func (c *Driver) State(ctx context.Context) (*jobs.State, error) {
 		Ready:    ready(atomic.LoadUint32(&c.listeners)),
 	}
 
-	nom, err := strconv.Atoi(attr.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+	nom, err := strconv.Atoi(attr[string(types.QueueAttributeNameApproximateNumberOfMessages)])
 	if err == nil {
 		out.Active = int64(nom)
 	}
 
-	delayed, err := strconv.Atoi(attr.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessagesDelayed)])
+	delayed, err := strconv.Atoi(attr[string(types.QueueAttributeNameApproximateNumberOfMessagesDelayed)])
 	if err == nil {
 		out.Delayed = int64(delayed)
 	}
 
-	nv, err := strconv.Atoi(attr.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)])
+	nv, err := strconv.Atoi(attr[string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)])
 	if err == nil {
 		out.Reserved = int64(nv)
 	}
@@ -458,36 +1576,235 @@ func (c *Driver) State(ctx context.Context) (*jobs.State, error) {
 }
 
 func (c *Driver) handleItem(ctx context.Context, msg *Item) error {
-	c.prop.Inject(ctx, propagation.HeaderCarrier(msg.headers))
-
-	d, err := msg.pack(c.queueURL, c.queue, c.messageGroupID)
+	d, err := c.prepare(ctx, msg, c.queueURL, c.queue)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.client.SendMessage(ctx, d)
+	return c.send(ctx, d)
+}
+
+// pushItem sends the pushed message to the pipeline queue and the fan_out_queues, the requeued messages are sent
+// to the pipeline queue only (handleItem)
+func (c *Driver) pushItem(ctx context.Context, msg *Item) error {
+	d, err := c.prepare(ctx, msg, c.queueURL, c.queue)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return c.deliver(ctx, d)
+}
+
+// send sends the message to the pipeline queue or to the secondary queue while the primary region is unreachable (failover_queue)
+func (c *Driver) send(ctx context.Context, d *sqs.SendMessageInput) error {
+	c.observePayload(d)
+
+	if c.failover.useSecondary(ctx, c.probePrimary) {
+		return c.failover.send(ctx, d)
+	}
+
+	err := c.sendPrimary(ctx, d)
+	if c.failover.observe(err) {
+		return c.failover.send(ctx, d)
+	}
+
+	return err
+}
+
+// sendPrimary sends the message to the pipeline queue, batched if enabled
+func (c *Driver) sendPrimary(ctx context.Context, d *sqs.SendMessageInput) error {
+	if c.sendBatch != nil {
+		return c.sendBatch.send(ctx, d)
+	}
+
+	if !c.verifyMD5 {
+		_, err := sendRetry(ctx, c.log, c.netRetries, c.budget, "SendMessage", func() (*sqs.SendMessageOutput, error) {
+			return c.client.SendMessage(ctx, d, withDeadline(ctx, sendDeadlineMargin))
+		})
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	// verify_md5: the message corrupted in transit is sent again, so the queue might get a corrupted copy as well
+	for attempt := 1; ; attempt++ {
+		out, err := sendRetry(ctx, c.log, c.netRetries, c.budget, "SendMessage", func() (*sqs.SendMessageOutput, error) {
+			return c.client.SendMessage(ctx, d, withDeadline(ctx, sendDeadlineMargin), skipSDKChecksum)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = verifySendMD5(d, out)
+		if err == nil {
+			return nil
+		}
+
+		if attempt == md5SendAttempts {
+			c.log.Error("MD5 mismatch in the SendMessage response, giving up", zap.Stringp("ID", out.MessageId), zap.Int("attempts", attempt), zap.Error(err))
+			return err
+		}
+
+		c.log.Warn("MD5 mismatch in the SendMessage response, the message might be corrupted in transit, sending again", zap.Stringp("ID", out.MessageId), zap.Int("attempt", attempt), zap.Error(err))
+	}
+}
+
+// prepare packs the item into the message for the queue
+func (c *Driver) prepare(ctx context.Context, msg *Item, queueURL, queue *string) (*sqs.SendMessageInput, error) {
+	// do not start sending if the caller's deadline is about to expire
+	err := checkDeadline(ctx, sendDeadlineMargin)
+	if err != nil {
+		return nil, err
+	}
+
+	c.prop.Inject(ctx, propagation.HeaderCarrier(msg.headers))
+	// propagate_headers/redact_headers
+	msg.headers = c.headers.filter(msg.headers)
+
+	d, err := msg.pack(queueURL, queue, c.messageGroup(msg), c.bundledMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	// message_deduplication_id/content_based_deduplication
+	c.deduplicationID(msg, d, queue)
+
+	err = c.partitionAttribute(msg, d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.correlationAttribute(msg, d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.typedAttributes(msg, d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.headerAttributes(msg, d)
+	if err != nil {
+		return nil, err
+	}
+
+	retryAttribute(msg, d)
+	splitAttributes(msg, d)
+	c.scheduleLongDelay(d)
+
+	// compression, the compressed body is base64 encoded
+	err = c.compressBody(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// body_encoding, the encoded body is encrypted
+	c.encodeBody(d)
+
+	// the attributes are not encrypted, only the body
+	err = c.encryptBody(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// s3_bucket, the body is replaced with the pointer
+	err = c.offloadBody(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQS supports up to 10 message attributes
+	err = spillAttributes(d, c.attrMapping.pinned...)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
 }
 
-func checkEnv(insideAWS bool, key, secret, sessionToken, endpoint, region string) (*sqs.Client, error) {
+func checkEnv(insideAWS bool, conf *Config, log *zap.Logger) (*sqs.Client, error) {
 	const op = errors.Op("check_env")
 	var client *sqs.Client
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
+	logOpts, err := awsLogOptions(conf.AWSLogMode, log)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	discovery, err := endpointDiscoveryOption(conf.EndpointDiscovery)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// explicit providers order, nil - the implicit detection below
+	chain, err := credentialChain(conf.CredentialChain, conf.Profile)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	err = checkAssumeRole(conf)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// shared_config_file/shared_credentials_file, nil - the default files
+	files, err := sharedFilesOptions(conf.SharedConfigFile, conf.SharedCredentialsFile)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// SigV4 signing with the clock skew correction, nil if disabled
+	skew := newClockSkew(conf, log)
+	// HTTP client with the http_client settings and the DNS cache, nil if neither is configured
+	hc, err := newHTTPClient(&conf.HTTPClient, conf.DNSCacheTTL, conf.MetadataEndpoint, log)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	region, err := resolveRegion(ctx, regionChain(conf, insideAWS), log)
+	if err != nil {
+		// the region might be set in the shared config profile, resolved by the SDK
+		if conf.Profile == "" && len(files) == 0 {
+			return nil, errors.E(op, err)
+		}
+		log.Debug("region is not set, using the region from the shared config profile", zap.String("profile", conf.Profile))
+	}
+
+	// the same precedence inside and outside AWS: the profile (shared config) over the static credentials
+	if chain == nil && (conf.Profile != "" || len(files) > 0) && (conf.Key != "" || conf.Secret != "" || conf.SessionToken != "") {
+		log.Warn("both the profile (shared config) and the static credentials are configured, the static key/secret/session_token are ignored", zap.String("profile", conf.Profile))
+	}
+
 	switch insideAWS {
 	case true:
+		// the SDK resolves the endpoint from the region, only the consistency is checked
+		err = checkPartition(conf.Partition, region)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
 		// respect user provided values for the sqs
 		opts := make([]func(*config.LoadOptions) error, 0, 1)
 		if region != "" {
 			opts = append(opts, config.WithRegion(region))
 		}
-		if secret != "" && key != "" && sessionToken != "" {
-			opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(key, secret, sessionToken)))
+		// profile (shared config) has a priority over the static credentials, as outside AWS
+		if chain == nil && conf.Profile == "" && len(files) == 0 && conf.Secret != "" && conf.Key != "" && conf.SessionToken != "" {
+			opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(conf.Key, conf.Secret, conf.SessionToken)))
+		}
+		opts = append(opts, files...)
+		if conf.Profile != "" {
+			opts = append(opts, profileOptions(conf.Profile)...)
+		}
+		opts = append(opts, logOpts...)
+		opts = append(opts, discovery)
+		if hc != nil {
+			opts = append(opts, config.WithHTTPClient(hc))
 		}
 
 		awsConf, err := config.LoadDefaultConfig(ctx, opts...)
@@ -495,47 +1812,158 @@ func checkEnv(insideAWS bool, key, secret, sessionToken, endpoint, region string
 			return nil, errors.E(op, err)
 		}
 
+		if chain != nil {
+			awsConf.Credentials = newChainProvider(chain, conf, awsConf, log)
+		}
+
+		if p := assumeRoleProvider(conf, awsConf); p != nil {
+			awsConf.Credentials = p
+		}
+
+		err = checkProfileCredentials(ctx, conf.Profile, awsConf)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if !conf.SkipWarmup {
+			err = warmUpCredentials(ctx, awsConf)
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+		}
+
 		// config with retries
 		client = sqs.NewFromConfig(awsConf, func(o *sqs.Options) {
+			if conf.pipelineEndpoint != "" {
+				o.BaseEndpoint = &conf.pipelineEndpoint
+			}
 			o.Retryer = retry.NewStandard(func(opts *retry.StandardOptions) {
 				opts.MaxAttempts = 60
 				opts.MaxBackoff = time.Second * 2
+				if skew != nil {
+					opts.Retryables = append(opts.Retryables, retry.IsErrorRetryableFunc(skew.retryable))
+				}
 			})
+			if skew != nil {
+				o.HTTPSignerV4 = skew
+			}
 		})
 	case false:
-		awsConf, err := config.LoadDefaultConfig(ctx,
-			config.WithRegion(region),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(key, secret, sessionToken)))
+		endpoint, err := partitionEndpoint(conf, region)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		opts := make([]func(*config.LoadOptions) error, 0, 2)
+		if region != "" {
+			opts = append(opts, config.WithRegion(region))
+		}
+		// profile (shared config) has a priority over the static credentials
+		opts = append(opts, files...)
+		switch {
+		case chain != nil:
+			// set after the config is loaded
+		case conf.Profile != "":
+			opts = append(opts, profileOptions(conf.Profile)...)
+		case len(files) > 0:
+			// the default profile of the shared files
+		default:
+			opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(conf.Key, conf.Secret, conf.SessionToken)))
+		}
+		opts = append(opts, logOpts...)
+		opts = append(opts, discovery)
+		if hc != nil {
+			opts = append(opts, config.WithHTTPClient(hc))
+		}
+
+		awsConf, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if chain != nil {
+			awsConf.Credentials = newChainProvider(chain, conf, awsConf, log)
+		}
+
+		if p := assumeRoleProvider(conf, awsConf); p != nil {
+			awsConf.Credentials = p
+		}
+
+		err = checkProfileCredentials(ctx, conf.Profile, awsConf)
 		if err != nil {
 			return nil, errors.E(op, err)
 		}
 
+		if !conf.SkipWarmup {
+			err = warmUpCredentials(ctx, awsConf)
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+		}
+
 		// config with retries
 		client = sqs.NewFromConfig(awsConf, func(o *sqs.Options) {
 			o.BaseEndpoint = &endpoint
 			o.Retryer = retry.NewStandard(func(opts *retry.StandardOptions) {
 				opts.MaxAttempts = 60
 				opts.MaxBackoff = time.Second * 2
+				if skew != nil {
+					opts.Retryables = append(opts.Retryables, retry.IsErrorRetryableFunc(skew.retryable))
+				}
 			})
+			if skew != nil {
+				o.HTTPSignerV4 = skew
+			}
 		})
 	}
 
+	if !conf.SkipWarmup {
+		warmUpConnection(ctx, client, log)
+	}
+
 	return client, nil
 }
 
-func manageQueue(jb *Driver) error {
+func manageQueue(ctx context.Context, jb *Driver) error {
 	var err error
 	switch jb.skipDeclare {
 	case true:
-		jb.queueURL, err = getQueueURL(jb.client, jb.queue)
+		// the queue URL is configured explicitly, nothing to resolve
+		if jb.queueURLFixed {
+			return nil
+		}
+
+		jb.queueURL, err = getQueueURL(ctx, jb.client, jb.queue, jb.queueOwner)
 		if err != nil {
 			return err
 		}
 	case false:
-		jb.queueURL, err = createQueue(jb.client, jb.queue, jb.attributes, jb.tags)
-		if err != nil {
-			return err
+		// the queue should be deleted on stop, only if it is created by the pipeline
+		if jb.deleteOnStop {
+			return declareEphemeral(ctx, jb)
+		}
+
+		// the existing queue is resolved first, CreateQueue is called only for the missing one
+		if jb.lookupFirst {
+			jb.queueURL = jb.lookupQueue(ctx)
+		}
+
+		if jb.lookupFirst && jb.queueURL != nil {
+			jb.syncQueueAttributes(ctx)
+			jb.syncQueueTags(ctx)
+		} else {
+			jb.queueURL, err = jb.createQueueRetry(ctx)
+			if err != nil {
+				return err
+			}
+
+			err = jb.waitQueueReady(ctx)
+			if err != nil {
+				return err
+			}
 		}
+
+		jb.emitQueueEvent(ctx, QueueDeclared, nil)
 	}
 
 	return nil
@@ -543,11 +1971,14 @@ func manageQueue(jb *Driver) error {
 
 // https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-instance-metadata.html
 // https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/identify_ec2_instances.html
-func isInAWS() bool {
-	client := &http.Client{
-		Timeout: time.Second * 2,
+// isInAWS checks the IMDSv1 instance identity endpoint, empty endpoint means the default link-local address
+func isInAWS(ctx context.Context, endpoint string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL(endpoint, awsMetaDataPath), nil)
+	if err != nil {
+		return false
 	}
-	resp, err := client.Get(awsMetaDataURL) //nolint:noctx
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false
 	}
@@ -558,13 +1989,9 @@ func isInAWS() bool {
 }
 
 // https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/configuring-instance-metadata-service.html
-func isinAWSIMDSv2() bool {
-	client := &http.Client{
-		Timeout: time.Second * 2,
-	}
-
+func isinAWSIMDSv2(ctx context.Context, endpoint string) bool {
 	// probably we're in the IMDSv2, let's try different endpoint
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, awsMetaDataIMDSv2URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, metadataURL(endpoint, awsMetaDataTokenPath), nil)
 	if err != nil {
 		return false
 	}
@@ -572,7 +1999,7 @@ func isinAWSIMDSv2() bool {
 	// 10 seconds should be fine to just check
 	req.Header.Set(awsTokenHeader, "10")
 
-	resp, err := client.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false
 	}
@@ -582,16 +2009,21 @@ func isinAWSIMDSv2() bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-func createQueue(client *sqs.Client, queueName *string, attributes map[string]string, tags map[string]string) (*string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
+// metadataURL joins the metadata service base URL and the path
+func metadataURL(endpoint, path string) string {
+	if endpoint == "" {
+		endpoint = awsMetaDataEndpoint
+	}
+
+	return strings.TrimSuffix(endpoint, "/") + path
+}
+
+func createQueue(ctx context.Context, client sqsClient, queueName *string, attributes map[string]string, tags map[string]string) (*string, error) {
 	out, err := client.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: queueName, Attributes: attributes, Tags: tags})
 	if err != nil {
 		var qErr *types.QueueNameExists
 		if stderr.As(err, &qErr) {
-			ctxGet, cancelGet := context.WithTimeout(context.Background(), time.Second*30)
-			defer cancelGet()
-			res, errQ := client.GetQueueUrl(ctxGet, &sqs.GetQueueUrlInput{
+			res, errQ := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
 				QueueName: queueName,
 			}, func(_ *sqs.Options) {})
 			if errQ != nil {
@@ -607,10 +2039,9 @@ func createQueue(client *sqs.Client, queueName *string, attributes map[string]st
 	return out.QueueUrl, nil
 }
 
-func getQueueURL(client *sqs.Client, queueName *string) (*string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
-	out, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: queueName})
+// getQueueURL resolves the queue by name, the owner is the account ID of the queue owner (nil - the caller account)
+func getQueueURL(ctx context.Context, client sqsClient, queueName, owner *string) (*string, error) {
+	out, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: queueName, QueueOwnerAWSAccountId: owner})
 	if err != nil {
 		return nil, err
 	}
@@ -618,6 +2049,11 @@ func getQueueURL(client *sqs.Client, queueName *string) (*string, error) {
 	return out.QueueUrl, nil
 }
 
+// queueName applies the environment prefix to the queue name, the .fifo suffix (if any) stays at the end
+func queueName(prefix, name string) string {
+	return prefix + name
+}
+
 func ptr[T any](val T) *T {
 	return &val
 }
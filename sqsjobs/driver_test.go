@@ -0,0 +1,167 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuePrefix(t *testing.T) {
+	require.Equal(t, "staging-orders.fifo", queueName("staging-", "orders.fifo"))
+	require.Equal(t, "orders", queueName("", "orders"))
+
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.queue = aws.String(queueName("prod-", "orders.fifo"))
+
+	// declare
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Len(t, fc.created, 1)
+	require.Equal(t, "prod-orders.fifo", aws.ToString(fc.created[0].QueueName))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/prod-orders.fifo", aws.ToString(c.queueURL))
+
+	// resolve
+	c.skipDeclare = true
+	require.NoError(t, manageQueue(context.Background(), c))
+	// post-create resolution + resolve
+	require.Len(t, fc.resolved, 2)
+	require.Equal(t, "prod-orders.fifo", aws.ToString(fc.resolved[1].QueueName))
+
+	// fifo logic sees the suffix after the prefix is applied
+	require.NotNil(t, dedup("id", c.queue))
+}
+
+func TestSetupTimeout(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	// hangs until the context is done
+	fc.createFn = func(ctx context.Context, _ *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	c.client = fc
+
+	start := time.Now()
+	err := c.setup(time.Millisecond*200, false, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timed out")
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, 0, fc.called("ReceiveMessage"))
+
+	// non-timeout errors are returned as is
+	fc.createFn = func(context.Context, *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+		return nil, stderr.New("boom")
+	}
+	err = c.setup(time.Second, false, nil)
+	require.Error(t, err)
+	require.Equal(t, "boom", err.Error())
+}
+
+func TestQueueReadyAfterCreate(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	var calls int
+	fc.getURLFn = func(_ context.Context, in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		calls++
+		// eventual consistency, the queue is not visible right after create
+		if calls <= 2 {
+			return nil, &types.QueueDoesNotExist{Message: aws.String("no such queue")}
+		}
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(in.QueueName))}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.setup(time.Second*5, false, nil))
+	require.Equal(t, 1, fc.called("CreateQueue"))
+	require.Equal(t, 3, fc.called("GetQueueUrl"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test", aws.ToString(c.queueURL))
+
+	// the wait is capped
+	calls = -100
+	c.queueReadyTimeout = time.Millisecond * 300
+	start := time.Now()
+	err := c.setup(time.Second*5, true, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "queue_ready_timeout")
+	require.Less(t, time.Since(start), time.Second*2)
+
+	// other errors are returned right away
+	fc.getURLFn = func(context.Context, *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		return nil, stderr.New("boom")
+	}
+	err = c.setup(time.Second*5, true, nil)
+	require.Error(t, err)
+	require.Equal(t, "boom", err.Error())
+}
+
+func TestCreateQueueDeletedRecently(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.deletedRecentlyWait = time.Millisecond * 200
+	fc := newFakeClient()
+	var calls int
+	fc.createFn = func(_ context.Context, in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, &types.QueueDeletedRecently{Message: aws.String("wait 60 seconds")}
+		case 2:
+			return nil, &smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"}
+		}
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(in.QueueName))}, nil
+	}
+	c.client = fc
+
+	start := time.Now()
+	require.NoError(t, c.setup(time.Second*5, true, nil))
+	require.GreaterOrEqual(t, time.Since(start), c.deletedRecentlyWait+createThrottleBackoff)
+	require.Equal(t, 3, fc.called("CreateQueue"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test", aws.ToString(c.queueURL))
+
+	// the wait is capped by the setup timeout
+	calls = 0
+	c.deletedRecentlyWait = time.Second * 60
+	start = time.Now()
+	err := c.setup(time.Millisecond*300, true, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "setup_timeout")
+	require.Less(t, time.Since(start), time.Second*2)
+}
+
+func TestMetadataEndpointOverride(t *testing.T) {
+	var v1, v2 bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == awsMetaDataPath && v1:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut && r.URL.Path == awsMetaDataTokenPath && v2 && r.Header.Get(awsTokenHeader) != "":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	require.False(t, isInAWS(context.Background(), srv.URL))
+	require.False(t, isinAWSIMDSv2(context.Background(), srv.URL))
+
+	v1 = true
+	require.True(t, isInAWS(context.Background(), srv.URL))
+	// trailing slash is tolerated
+	require.True(t, isInAWS(context.Background(), srv.URL+"/"))
+
+	v1, v2 = false, true
+	require.False(t, isInAWS(context.Background(), srv.URL))
+	require.True(t, isinAWSIMDSv2(context.Background(), srv.URL))
+
+	require.Equal(t, "http://169.254.169.254/latest/api/token", metadataURL("", awsMetaDataTokenPath))
+}
@@ -0,0 +1,70 @@
+package sqsjobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// empty body policies
+const (
+	// emptyBodyDrop deletes the message and logs a warning (default)
+	emptyBodyDrop string = "drop"
+	// emptyBodyDispatch dispatches the job with an empty payload
+	emptyBodyDispatch string = "dispatch"
+	// emptyBodyError treats the message as a poison one (moved to the dead-letter queue if configured)
+	emptyBodyError string = "error"
+)
+
+// checkEmptyBodyPolicy validates the empty_body_policy option, empty means drop
+func checkEmptyBodyPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return emptyBodyDrop, nil
+	case emptyBodyDrop, emptyBodyDispatch, emptyBodyError:
+		return policy, nil
+	default:
+		return "", errors.Errorf("unknown empty_body_policy: %s, supported: drop, dispatch, error", policy)
+	}
+}
+
+// emptyBody returns an error for the message without a body if the policy is error
+func (c *Driver) emptyBody(msg *types.Message) error {
+	if c.emptyBodyPolicy == emptyBodyError && getordefault(msg.Body) == "" {
+		return errors.Str("empty message body")
+	}
+
+	return nil
+}
+
+// dropEmpty deletes the message without a body if the policy is drop, true is returned if the message should be skipped
+func (c *Driver) dropEmpty(ctx context.Context, msg *types.Message) bool {
+	if getordefault(msg.Body) != "" {
+		return false
+	}
+
+	switch c.emptyBodyPolicy {
+	case emptyBodyDispatch, emptyBodyError:
+		return false
+	}
+
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+		QueueUrl:      queueURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.log.Error("failed to delete the message with an empty body from the queue", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return true
+	}
+
+	c.log.Warn("message with an empty body was dropped", zap.Stringp("ID", msg.MessageId))
+	return true
+}
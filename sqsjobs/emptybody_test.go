@@ -0,0 +1,99 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func emptyBodyMessages() []types.Message {
+	return []types.Message{
+		{MessageId: aws.String("empty"), ReceiptHandle: aws.String("receipt-empty")},
+		{MessageId: aws.String("full"), ReceiptHandle: aws.String("receipt-full"), Body: aws.String("body")},
+	}
+}
+
+func TestEmptyBodyDrop(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.emptyBodyPolicy = emptyBodyDrop
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(emptyBodyMessages()...)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1 && fc.called("DeleteMessage") == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, "body", string(pq.ExtractMin().Body()))
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Equal(t, "receipt-empty", aws.ToString(fc.deleted[0].ReceiptHandle))
+}
+
+func TestEmptyBodyDispatch(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.emptyBodyPolicy = emptyBodyDispatch
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(emptyBodyMessages()...)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Empty(t, pq.ExtractMin().Body())
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+}
+
+func TestEmptyBodyError(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.emptyBodyPolicy = emptyBodyError
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(emptyBodyMessages()...)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1 && fc.called("DeleteMessage") == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, "body", string(pq.ExtractMin().Body()))
+
+	// poison message, moved to the dead-letter queue
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, c.dlqURL, fc.sent[0].QueueUrl)
+	require.Equal(t, "receipt-empty", aws.ToString(fc.deleted[0].ReceiptHandle))
+}
+
+func TestEmptyBodyPolicy(t *testing.T) {
+	p, err := checkEmptyBodyPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, emptyBodyDrop, p)
+
+	for _, policy := range []string{emptyBodyDrop, emptyBodyDispatch, emptyBodyError} {
+		p, err = checkEmptyBodyPolicy(policy)
+		require.NoError(t, err)
+		require.Equal(t, policy, p)
+	}
+
+	_, err = checkEmptyBodyPolicy("foo")
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package sqsjobs
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// ContentTransferEncodingAttr is the message attribute set on the messages with the encoded body,
+	// such messages are decoded on receive regardless of the body_encoding option
+	ContentTransferEncodingAttr string = "Content-Transfer-Encoding"
+	// the only supported body encoding
+	encodingBase64 string = "base64"
+)
+
+// checkBodyEncoding validates the body_encoding option, empty means the body is sent and received as is
+func checkBodyEncoding(enc string) (string, error) {
+	switch enc {
+	case "", encodingBase64:
+		return enc, nil
+	default:
+		return "", errors.Errorf("unknown body_encoding: %s, supported: base64", enc)
+	}
+}
+
+// encodeBody encodes the message body and marks the message with the Content-Transfer-Encoding attribute,
+// no-op if the body_encoding is not set and the body is not compressed
+func (c *Driver) encodeBody(in *sqs.SendMessageInput) {
+	if _, compressed := in.MessageAttributes[ContentEncodingAttr]; c.bodyEncoding != encodingBase64 && !compressed {
+		return
+	}
+
+	in.MessageBody = aws.String(base64.StdEncoding.EncodeToString([]byte(getordefault(in.MessageBody))))
+
+	if in.MessageAttributes == nil {
+		in.MessageAttributes = make(map[string]types.MessageAttributeValue, 1)
+	}
+	in.MessageAttributes[ContentTransferEncodingAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(encodingBase64)}
+}
+
+// decodeTransferEncoding decodes the body by the Content-Transfer-Encoding attribute or the pipeline body_encoding.
+// The identity encodings (7bit, 8bit, binary) are passed through as is.
+func (c *Driver) decodeTransferEncoding(body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+	enc := c.bodyEncoding
+	if val, ok := attrs[ContentTransferEncodingAttr]; ok && val.StringValue != nil {
+		enc = strings.ToLower(strings.TrimSpace(*val.StringValue))
+	}
+
+	switch enc {
+	case "", "identity", "7bit", "8bit", "binary":
+		return body, nil
+	case encodingBase64:
+		return decodeBase64(body)
+	default:
+		return nil, errors.Errorf("unsupported %s: %s", ContentTransferEncodingAttr, enc)
+	}
+}
+
+// decodeBase64 decodes the padded or unpadded standard base64, the line breaks (MIME) are ignored
+func decodeBase64(body []byte) ([]byte, error) {
+	s := strings.NewReplacer("\r", "", "\n", "").Replace(bytesToStr(body))
+
+	enc := base64.StdEncoding
+	if len(s)%4 != 0 {
+		enc = base64.RawStdEncoding
+	}
+
+	out, err := enc.DecodeString(s)
+	if err != nil {
+		return nil, errors.Errorf("message body is not a valid base64: %v", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package sqsjobs
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase64BodyRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.bodyEncoding = encodingBase64
+	fc := newFakeClient()
+	c.client = fc
+
+	payload := []byte{0x00, 0xff, 0x10, 'r', 'r', 0x80}
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: payload, headers: map[string][]string{}, Options: &Options{}}))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, base64.StdEncoding.EncodeToString(payload), aws.ToString(sent.MessageBody))
+	require.Equal(t, encodingBase64, aws.ToString(sent.MessageAttributes[ContentTransferEncodingAttr].StringValue))
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, payload, out.Payload)
+
+	// third-party message without the attribute, decoded by the pipeline option (unpadded, MIME line breaks)
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: aws.String("aGVsbG8g\r\nd29ybGQ")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), out.Payload)
+
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("3"), Body: aws.String("not base64!")})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not a valid base64")
+
+	// the attribute is respected regardless of the pipeline option
+	c.bodyEncoding = ""
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("4"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, payload, out.Payload)
+
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("5"), Body: aws.String("plain"), MessageAttributes: map[string]types.MessageAttributeValue{
+		ContentTransferEncodingAttr: {DataType: aws.String(StringType), StringValue: aws.String("8bit")},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), out.Payload)
+
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("6"), Body: aws.String("plain"), MessageAttributes: map[string]types.MessageAttributeValue{
+		ContentTransferEncodingAttr: {DataType: aws.String(StringType), StringValue: aws.String("quoted-printable")},
+	}})
+	require.Error(t, err)
+}
+
+func TestBase64BodyEncrypted(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.bodyEncoding = encodingBase64
+	c.bodyFormat = formatJSON
+	var err error
+	c.aead, err = newBodyCipher(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")), "")
+	require.NoError(t, err)
+	fc := newFakeClient()
+	c.client = fc
+
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte(`{"a":1}`), headers: map[string][]string{}, Options: &Options{}}))
+
+	sent := fc.sent[0]
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"a":1}`), out.Payload)
+
+	_, err = checkBodyEncoding("base32")
+	require.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package sqsjobs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// ContentEncryptionAttr is the message attribute set on the messages with the encrypted body
+	ContentEncryptionAttr string = "Content-Encryption"
+	// the only supported scheme, the body is base64(nonce + ciphertext)
+	encryptionAESGCM string = "aes-gcm"
+)
+
+// newBodyCipher creates the AES-GCM cipher from the base64 encoded key (16, 24 or 32 bytes) set directly or via the env variable.
+// Returns nil if no key is configured. The key is never a part of the returned errors.
+func newBodyCipher(key, keyEnv string) (cipher.AEAD, error) {
+	if key != "" && keyEnv != "" {
+		return nil, errors.Str("encryption_key and encryption_key_env are mutually exclusive")
+	}
+
+	if keyEnv != "" {
+		key = os.Getenv(keyEnv)
+		if key == "" {
+			return nil, errors.Errorf("encryption key env variable %s is empty", keyEnv)
+		}
+	}
+
+	if key == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.Str("encryption key is not a valid base64 string")
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, errors.Errorf("invalid encryption key length: %d bytes, supported: 16, 24, 32", len(raw))
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptBody encrypts the message body and marks the message with the Content-Encryption attribute, no-op if encryption is disabled
+func (c *Driver) encryptBody(in *sqs.SendMessageInput) error {
+	if c.aead == nil {
+		return nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(getordefault(in.MessageBody)), nil)
+	in.MessageBody = aws.String(base64.StdEncoding.EncodeToString(sealed))
+
+	if in.MessageAttributes == nil {
+		in.MessageAttributes = make(map[string]types.MessageAttributeValue, 1)
+	}
+	in.MessageAttributes[ContentEncryptionAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(encryptionAESGCM)}
+
+	return nil
+}
+
+// decryptBody decrypts the body of the messages with the Content-Encryption attribute,
+// messages without it are passed through as is (e.g. sent before the encryption was enabled)
+func (c *Driver) decryptBody(body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+	val, ok := attrs[ContentEncryptionAttr]
+	if !ok {
+		return body, nil
+	}
+
+	if scheme := getordefault(val.StringValue); scheme != encryptionAESGCM {
+		return nil, errors.Errorf("unsupported %s: %s", ContentEncryptionAttr, scheme)
+	}
+
+	if c.aead == nil {
+		return nil, errors.Str("message body is encrypted, but no encryption key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(bytesToStr(body))
+	if err != nil {
+		return nil, errors.Errorf("encrypted message body is not a valid base64 string: %v", err)
+	}
+
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.Str("encrypted message body is too short")
+	}
+
+	nonce, data := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, errors.Str("failed to decrypt the message body")
+	}
+
+	return plain, nil
+}
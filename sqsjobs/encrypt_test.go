@@ -0,0 +1,74 @@
+package sqsjobs
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+	c := newTestDriver(&testQueue{}, nil)
+	var err error
+	c.aead, err = newBodyCipher(key, "")
+	require.NoError(t, err)
+	fc := newFakeClient()
+	c.client = fc
+
+	item := &Item{Job: "job", Ident: "id", Payload: []byte(`{"secret":"value"}`), headers: map[string][]string{}, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, encryptionAESGCM, aws.ToString(sent.MessageAttributes[ContentEncryptionAttr].StringValue))
+	require.NotContains(t, aws.ToString(sent.MessageBody), "secret")
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, []byte(`{"secret":"value"}`), out.Payload)
+
+	// rollout: the plaintext messages pass through
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: aws.String("plain")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), out.Payload)
+
+	// tampered body
+	tampered := []byte(aws.ToString(sent.MessageBody))
+	tampered[len(tampered)-3] ^= 1
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("3"), Body: aws.String(string(tampered)), MessageAttributes: sent.MessageAttributes})
+	require.Error(t, err)
+
+	// no key on the receiver
+	c.aead = nil
+	_, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("4"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no encryption key")
+}
+
+func TestNewBodyCipher(t *testing.T) {
+	aead, err := newBodyCipher("", "")
+	require.NoError(t, err)
+	require.Nil(t, aead)
+
+	t.Setenv("RR_SQS_TEST_KEY", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	aead, err = newBodyCipher("", "RR_SQS_TEST_KEY")
+	require.NoError(t, err)
+	require.NotNil(t, aead)
+
+	_, err = newBodyCipher("", "RR_SQS_TEST_MISSING")
+	require.Error(t, err)
+
+	// the key is never a part of the error
+	_, err = newBodyCipher("not-base64-secret!", "")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "secret")
+
+	_, err = newBodyCipher(base64.StdEncoding.EncodeToString([]byte("short")), "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "key length")
+}
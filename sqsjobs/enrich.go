@@ -0,0 +1,90 @@
+package sqsjobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEnrichRetryDelay = time.Second * 5
+	// the SQS maximum is 12 hours, the failing enrichment is retried at least every 15 minutes
+	maxEnrichBackoff = time.Minute * 15
+)
+
+// Enricher is the enrichment stage run after the receive and before the dispatch, e.g. calls an external service and
+// adds the result to the payload or the headers. Unlike the BodyTransformer it runs after the message checks (max_app_retries,
+// deadline_attribute, leases) and before the auto_ack delete, so the message is deleted (auto_ack) only after the enrichment
+// succeeds. It runs before the prefetch wait, a slow enrichment doesn't block the other pollers. The returned item is
+// dispatched, it should be the received item (or keep its Options), nil - the received one. An error nacks the message:
+// it's visible again after the enrich_retry_delay, doubled with every receive.
+type Enricher func(ctx context.Context, item *Item) (*Item, error)
+
+// RegisterEnricher enables the enrichment stage, nil disables it
+func (c *Driver) RegisterEnricher(e Enricher) {
+	if e == nil {
+		c.enricher.Store(nil)
+		return
+	}
+
+	c.enricher.Store(&e)
+}
+
+// enrich runs the enrichment stage, returns false if the enrichment failed and the message was returned to the queue.
+// The enrichment is bound by the visibility timeout, the message is redelivered after it anyway.
+func (c *Driver) enrich(ctx context.Context, item *Item, m *types.Message) (*Item, bool) {
+	e := c.enricher.Load()
+	if e == nil {
+		return item, true
+	}
+
+	ctxT, cancel := context.WithTimeout(ctx, time.Duration(c.effectiveVisibility())*time.Second)
+	enriched, err := (*e)(ctxT, item)
+	cancel()
+
+	if err == nil {
+		if enriched == nil {
+			return item, true
+		}
+		if enriched.Options == nil {
+			enriched.Options = item.Options
+		}
+		return enriched, true
+	}
+
+	backoff := enrichBackoff(c.enrichRetryDelay, item.Options.approxReceiveCount)
+	log := item.Options.log
+	log.Warn("failed to enrich the message, message returned to the queue", zap.Stringp("ID", m.MessageId), zap.Duration("retry_in", backoff), zap.Error(err))
+
+	// the lease (if any) is not needed until the redelivery
+	if item.Options.release != nil {
+		item.Options.release()
+	}
+
+	ctxV, cancelV := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelV()
+
+	_, errV := item.Options.client.ChangeMessageVisibility(ctxV, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          item.Options.queue,
+		ReceiptHandle:     m.ReceiptHandle,
+		VisibilityTimeout: int32(backoff.Seconds()),
+	})
+	if errV != nil && !isNotInflight(errV) {
+		log.Warn("failed to return the not enriched message to the queue, it will be visible after the visibility timeout", zap.Stringp("ID", m.MessageId), zap.Error(errV))
+	}
+	item.Options.receipt.done()
+
+	return nil, false
+}
+
+// enrichBackoff doubles the delay with every receive (ApproximateReceiveCount), up to 15 minutes
+func enrichBackoff(delay time.Duration, receiveCount int64) time.Duration {
+	for i := int64(1); i < receiveCount && delay < maxEnrichBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxEnrichBackoff)
+}
@@ -0,0 +1,89 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnricher(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.enrichRetryDelay = defaultEnrichRetryDelay
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("ok"), ReceiptHandle: aws.String("rh-ok"), Body: aws.String("ok"), Attributes: map[string]string{ApproximateReceiveCount: "1"}},
+		types.Message{MessageId: aws.String("fail"), ReceiptHandle: aws.String("rh-fail"), Body: aws.String("fail"), Attributes: map[string]string{ApproximateReceiveCount: "3"}},
+	)
+	c.client = fc
+
+	c.RegisterEnricher(func(_ context.Context, item *Item) (*Item, error) {
+		if string(item.Payload) == "fail" {
+			return nil, stderr.New("enrichment service is unavailable")
+		}
+		item.Payload = append(item.Payload, []byte("-enriched")...)
+		return item, nil
+	})
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 1 && fc.called("ChangeMessageVisibility") == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	// the enriched message is dispatched
+	require.Equal(t, "ok-enriched", string(pq.ExtractMin().(*Item).Payload))
+
+	// the failed one is nacked with the backoff of the third receive, not deleted
+	fc.mu.Lock()
+	require.Equal(t, "rh-fail", *fc.visibility[0].ReceiptHandle)
+	require.Equal(t, int32(20), fc.visibility[0].VisibilityTimeout)
+	require.Empty(t, fc.deleted)
+	fc.mu.Unlock()
+	// only the dispatched message is in flight
+	require.Equal(t, int64(1), atomic.LoadInt64(c.msgInFlight))
+}
+
+func TestEnrichBackoff(t *testing.T) {
+	require.Equal(t, time.Second*5, enrichBackoff(time.Second*5, -1))
+	require.Equal(t, time.Second*5, enrichBackoff(time.Second*5, 1))
+	require.Equal(t, time.Second*10, enrichBackoff(time.Second*5, 2))
+	require.Equal(t, maxEnrichBackoff, enrichBackoff(time.Second*5, 100))
+}
+
+func TestEnricherDoesNotBlockPollers(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+	fc.receiveFn = receiveEach(
+		types.Message{MessageId: aws.String("slow"), ReceiptHandle: aws.String("rh-slow"), Body: aws.String("slow")},
+		types.Message{MessageId: aws.String("fast"), ReceiptHandle: aws.String("rh-fast"), Body: aws.String("fast")},
+	)
+	c.client = fc
+
+	enriching := make(chan struct{})
+	unblock := make(chan struct{})
+	c.RegisterEnricher(func(_ context.Context, item *Item) (*Item, error) {
+		if string(item.Payload) == "slow" {
+			close(enriching)
+			<-unblock
+		}
+		return item, nil
+	})
+
+	// two pollers, the slow enrichment doesn't block the other one
+	stop := runListeners(c, 2)
+
+	<-enriching
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, "fast", string(pq.ExtractMin().(*Item).Payload))
+
+	close(unblock)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+	require.Equal(t, "slow", string(pq.ExtractMin().(*Item).Payload))
+}
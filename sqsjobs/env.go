@@ -0,0 +1,214 @@
+package sqsjobs
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// envPrefix marks the value sourced from the environment variable as a whole, e.g. secret: env:AWS_SECRET_ACCESS_KEY
+	envPrefix string = "env:"
+	// the escape of the literal ${ and env: values: $${ and $env:
+	envEscape byte = '$'
+)
+
+// expandEnv resolves the ${VAR} placeholders and the env:VAR value from the environment,
+// the unset variable is an error (the empty one is not). The escaped $${ and $env: are kept literally (${ and env:).
+func expandEnv(value string) (string, error) {
+	if literal, ok := strings.CutPrefix(value, string(envEscape)+envPrefix); ok {
+		return envPrefix + literal, nil
+	}
+
+	if name, ok := strings.CutPrefix(value, envPrefix); ok {
+		return lookupEnv(name)
+	}
+
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var sb strings.Builder
+	rest := value
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			sb.WriteString(rest)
+			return sb.String(), nil
+		}
+
+		if start > 0 && rest[start-1] == envEscape {
+			sb.WriteString(rest[:start-1])
+			sb.WriteString("${")
+			rest = rest[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", errors.Errorf("unterminated environment variable placeholder in: %s", value)
+		}
+
+		// not a variable name, e.g. the ${aws:SourceArn} IAM policy variable, kept as is
+		if name := strings.TrimSpace(rest[start+2 : start+end]); name != "" && !isEnvName(name) {
+			sb.WriteString(rest[:start+end+1])
+			rest = rest[start+end+1:]
+			continue
+		}
+
+		env, err := lookupEnv(rest[start+2 : start+end])
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(rest[:start])
+		sb.WriteString(env)
+		rest = rest[start+end+1:]
+	}
+}
+
+// isEnvName checks the environment variable name: letters, digits and underscores, not starting with a digit
+func isEnvName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch == '_', ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z':
+		case ch >= '0' && ch <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func lookupEnv(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.Str("empty environment variable name in the placeholder")
+	}
+
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.Errorf("environment variable %s is not set", name)
+	}
+
+	return v, nil
+}
+
+// expandConfigEnv resolves the environment placeholders in all string options of the configuration
+// (including the nested sections, e.g. http_client)
+func expandConfigEnv(conf *Config) error {
+	return expandStruct(reflect.ValueOf(conf).Elem())
+}
+
+// expandStruct resolves the placeholders in the exported fields decoded from the config
+func expandStruct(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		// not decoded from the config
+		if !t.Field(i).IsExported() {
+			continue
+		}
+
+		name := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		err := expandValue(v.Field(i))
+		if err != nil {
+			return errors.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// expandValue resolves the placeholders in the string, *string, []string, map[string]string or the struct value
+func expandValue(f reflect.Value) error {
+	switch f.Kind() { //nolint:exhaustive
+	case reflect.String:
+		s, err := expandEnv(f.String())
+		if err != nil {
+			return err
+		}
+		f.SetString(s)
+	case reflect.Pointer:
+		if !f.IsNil() && f.Elem().Kind() == reflect.String {
+			return expandValue(f.Elem())
+		}
+	case reflect.Struct:
+		return expandStruct(f)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for j := 0; j < f.Len(); j++ {
+			err := expandValue(f.Index(j))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if f.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := f.MapRange()
+		for iter.Next() {
+			s, err := expandEnv(iter.Value().String())
+			if err != nil {
+				return err
+			}
+			f.SetMapIndex(iter.Key(), reflect.ValueOf(s).Convert(f.Type().Elem()))
+		}
+	}
+
+	return nil
+}
+
+// expandPipelineEnv resolves the environment placeholders in the string options of the pipeline
+// (including the nested maps, e.g. attributes and tags). The pipeline is a map of the options.
+func expandPipelineEnv(pipe jobs.Pipeline) error {
+	v := reflect.ValueOf(pipe)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		switch val := iter.Value().Interface().(type) {
+		case string:
+			s, err := expandEnv(val)
+			if err != nil {
+				return errors.Errorf("%s: %v", key, err)
+			}
+			if s != val {
+				pipe.With(key, s)
+			}
+		case map[string]string:
+			for k, nested := range val {
+				s, err := expandEnv(nested)
+				if err != nil {
+					return errors.Errorf("%s.%s: %v", key, k, err)
+				}
+				val[k] = s
+			}
+		case map[string]any:
+			for k, nested := range val {
+				ns, ok := nested.(string)
+				if !ok {
+					continue
+				}
+				s, err := expandEnv(ns)
+				if err != nil {
+					return errors.Errorf("%s.%s: %v", key, k, err)
+				}
+				val[k] = s
+			}
+		}
+	}
+
+	return nil
+}
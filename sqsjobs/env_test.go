@@ -0,0 +1,91 @@
+package sqsjobs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	t.Setenv("RR_SQS_KEY", "key-from-env")
+	t.Setenv("RR_SQS_SECRET", "secret-from-env")
+	t.Setenv("RR_SQS_HOST", "127.0.0.1")
+	t.Setenv("RR_SQS_TEAM", "payments")
+
+	conf := &Config{
+		Key:              "${RR_SQS_KEY}",
+		Secret:           "env:RR_SQS_SECRET",
+		Endpoint:         "http://${RR_SQS_HOST}:9324",
+		Region:           "us-east-1",
+		Queue:            aws.String("${RR_SQS_TEAM}-orders"),
+		PropagateHeaders: []string{"X-${RR_SQS_TEAM}"},
+		Tags:             map[string]string{"team": "${RR_SQS_TEAM}"},
+		Prefetch:         10,
+		HTTPClient:       HTTPClientConfig{Proxy: "http://${RR_SQS_HOST}:3128"},
+		// the escaped placeholder and the IAM policy variables are kept
+		Attributes: map[string]string{"Policy": `{"aws:SourceArn":"${aws:SourceArn}"}`, "Literal": "$${RR_SQS_TEAM}"},
+	}
+	require.NoError(t, expandConfigEnv(conf))
+
+	require.Equal(t, "key-from-env", conf.Key)
+	require.Equal(t, "secret-from-env", conf.Secret)
+	require.Equal(t, "http://127.0.0.1:9324", conf.Endpoint)
+	require.Equal(t, "us-east-1", conf.Region)
+	require.Equal(t, "payments-orders", aws.ToString(conf.Queue))
+	require.Equal(t, []string{"X-payments"}, conf.PropagateHeaders)
+	require.Equal(t, map[string]string{"team": "payments"}, conf.Tags)
+	require.Equal(t, int32(10), conf.Prefetch)
+	require.Equal(t, "http://127.0.0.1:3128", conf.HTTPClient.Proxy)
+	require.Equal(t, map[string]string{"Policy": `{"aws:SourceArn":"${aws:SourceArn}"}`, "Literal": "${RR_SQS_TEAM}"}, conf.Attributes)
+
+	// the missing variable is reported with the option name
+	err := expandConfigEnv(&Config{Secret: "${RR_SQS_MISSING}"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "secret: environment variable RR_SQS_MISSING is not set")
+	err = expandConfigEnv(&Config{HTTPClient: HTTPClientConfig{Proxy: "${RR_SQS_MISSING}"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "http_client: proxy: environment variable RR_SQS_MISSING is not set")
+}
+
+func TestExpandPipelineEnv(t *testing.T) {
+	t.Setenv("RR_SQS_TEAM", "payments")
+
+	pipe := testPipeline{
+		"name":       "test",
+		"queue":      "${RR_SQS_TEAM}-orders",
+		"prefetch":   10,
+		"attributes": map[string]string{"DelaySeconds": "0", "Policy": "env:RR_SQS_TEAM"},
+		"tags":       map[string]any{"team": "${RR_SQS_TEAM}", "id": 1},
+	}
+	require.NoError(t, expandPipelineEnv(pipe))
+	require.Equal(t, "payments-orders", pipe.String("queue", ""))
+	require.Equal(t, 10, pipe.Int("prefetch", 0))
+
+	attr := make(map[string]string)
+	require.NoError(t, pipe.Map("attributes", attr))
+	require.Equal(t, "payments", attr["Policy"])
+	require.Equal(t, map[string]any{"team": "payments", "id": 1}, pipe["tags"])
+
+	err := expandPipelineEnv(testPipeline{"dead_letter_queue": "${RR_SQS_MISSING}"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "dead_letter_queue: environment variable RR_SQS_MISSING is not set")
+
+	_, err = expandEnv("${RR_SQS_TEAM")
+	require.Error(t, err)
+	_, err = expandEnv("${}")
+	require.Error(t, err)
+
+	// the escapes
+	for in, out := range map[string]string{
+		"$env:RR_SQS_TEAM":               "env:RR_SQS_TEAM",
+		"$${RR_SQS_TEAM}-$${x}":          "${RR_SQS_TEAM}-${x}",
+		"$${RR_SQS_TEAM}-${RR_SQS_TEAM}": "${RR_SQS_TEAM}-payments",
+		"${aws:username}":                "${aws:username}",
+		"${ RR_SQS_TEAM }":               "payments",
+	} {
+		v, err := expandEnv(in)
+		require.NoError(t, err)
+		require.Equal(t, out, v, in)
+	}
+}
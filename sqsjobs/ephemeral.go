@@ -0,0 +1,83 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+// isNonExistentQueue checks whether the error is a QueueDoesNotExist API error
+func isNonExistentQueue(err error) bool {
+	var qErr *types.QueueDoesNotExist
+	if stderr.As(err, &qErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case NonExistentQueue, "QueueDoesNotExist":
+		return true
+	default:
+		return false
+	}
+}
+
+// declareEphemeral resolves the queue and creates it only if it doesn't exist, so the driver knows whether the queue
+// was created by it. CreateQueue alone is idempotent and returns the URL of the existing queue.
+func declareEphemeral(ctx context.Context, jb *Driver) error {
+	url, err := getQueueURL(ctx, jb.client, jb.queue, nil)
+	if err == nil {
+		jb.queueURL = url
+		jb.log.Debug("queue already exists, it won't be deleted on stop", zap.Stringp("queue", jb.queue))
+		return nil
+	}
+
+	if !isNonExistentQueue(err) {
+		return err
+	}
+
+	jb.queueURL, err = jb.createQueueRetry(ctx)
+	if err != nil {
+		return err
+	}
+
+	jb.createdQueue = true
+	err = jb.waitQueueReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	jb.emitQueueEvent(ctx, QueueCreated, nil)
+	return nil
+}
+
+// deleteCreatedQueue deletes the queue on the pipeline stop (delete_on_stop), only if it was created by the driver
+func (c *Driver) deleteCreatedQueue(ctx context.Context) {
+	if !c.deleteOnStop || !c.createdQueue {
+		return
+	}
+
+	// the last attributes of the queue for the audit event
+	var before map[string]string
+	if c.queueEvents != nil {
+		before = c.queueAttributes(ctx)
+	}
+
+	_, err := c.client.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: c.queueURL})
+	if err != nil {
+		c.log.Error("failed to delete the queue on stop", zap.Stringp("queue", c.queue), zap.Error(err))
+		return
+	}
+
+	c.createdQueue = false
+	c.log.Debug("queue created by the pipeline was deleted", zap.Stringp("queue", c.queue))
+	c.emitQueueEvent(ctx, QueueDeleted, before)
+}
@@ -0,0 +1,57 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteOnStopCreatedQueue(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.deleteOnStop = true
+	c.queue = aws.String("ci-run-42")
+
+	fc := newFakeClient()
+	fc.getURLFn = func(_ context.Context, in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		if len(fc.created) == 0 {
+			return nil, &types.QueueDoesNotExist{Message: aws.String("no such queue")}
+		}
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(in.QueueName))}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Len(t, fc.created, 1)
+	require.True(t, c.createdQueue)
+
+	require.NoError(t, c.Stop(context.Background()))
+	require.Len(t, fc.dropped, 1)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/ci-run-42", aws.ToString(fc.dropped[0].QueueUrl))
+}
+
+func TestDeleteOnStopExistingQueue(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.deleteOnStop = true
+
+	fc := newFakeClient()
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Empty(t, fc.created)
+	require.False(t, c.createdQueue)
+
+	require.NoError(t, c.Stop(context.Background()))
+	require.Equal(t, 0, fc.called("DeleteQueue"))
+
+	// disabled - never deleted, even if just created
+	c = newTestDriver(&testQueue{}, nil)
+	fc = newFakeClient()
+	c.client = fc
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.NoError(t, c.Stop(context.Background()))
+	require.Equal(t, 0, fc.called("DeleteQueue"))
+}
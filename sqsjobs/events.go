@@ -0,0 +1,197 @@
+package sqsjobs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event types
+const (
+	EventPipelineStarted string = "pipeline_started"
+	EventPipelinePaused  string = "pipeline_paused"
+	EventPipelineResumed string = "pipeline_resumed"
+	EventPipelineStopped string = "pipeline_stopped"
+	EventMessageReceived string = "message_received"
+	EventMessageAcked    string = "message_acked"
+	EventMessageNacked   string = "message_nacked"
+	EventMessageRequeued string = "message_requeued"
+	// EventMessageDLQ - the message was moved to the dead-letter queue by the driver (poison message, max_app_retries, on_nack: dlq)
+	EventMessageDLQ string = "message_dlq"
+	// EventAWSError - the SQS call failed, Error is the redacted error message
+	EventAWSError string = "aws_error"
+
+	defaultEventsBuffer int = 1024
+)
+
+// Event is the structured lifecycle or message event of the pipeline. MessageID and JobID are empty for the pipeline
+// events, Error is empty unless the event is the failure.
+type Event struct {
+	Type      string
+	Pipeline  string
+	Queue     string
+	MessageID string
+	JobID     string
+	Error     string
+	Time      time.Time
+}
+
+// EventListener receives the events of the pipeline. The listeners are called sequentially from a single goroutine
+// (in the emit order), a slow listener delays the following events but never the driver: the events are dropped
+// once the events_buffer is full.
+type EventListener func(ev Event)
+
+// eventBus delivers the events to the registered listeners, the emit is a no-op until the first listener is registered
+type eventBus struct {
+	mu        sync.RWMutex
+	listeners []EventListener
+	ch        chan Event
+	start     sync.Once
+	stop      sync.Once
+	done      chan struct{}
+	// the number of the registered listeners, checked on every emit
+	active  int32
+	dropped uint64
+	log     *zap.Logger
+}
+
+func newEventBus(size int, log *zap.Logger) *eventBus {
+	if size <= 0 {
+		size = defaultEventsBuffer
+	}
+
+	return &eventBus{
+		ch:   make(chan Event, size),
+		done: make(chan struct{}),
+		log:  log,
+	}
+}
+
+// RegisterEventListener subscribes the listener to the pipeline events, unlike the other Register* hooks the
+// listeners are accumulated. nil is ignored.
+func (c *Driver) RegisterEventListener(l EventListener) {
+	if l == nil || c.events == nil {
+		return
+	}
+
+	c.events.mu.Lock()
+	c.events.listeners = append(c.events.listeners, l)
+	c.events.mu.Unlock()
+	atomic.AddInt32(&c.events.active, 1)
+
+	c.events.start.Do(func() {
+		go c.events.deliver()
+	})
+}
+
+// emit queues the event, never blocks
+func (b *eventBus) emit(ev Event) {
+	if b == nil || atomic.LoadInt32(&b.active) == 0 {
+		return
+	}
+
+	ev.Time = time.Now()
+	select {
+	case b.ch <- ev:
+	default:
+		if atomic.AddUint64(&b.dropped, 1) == 1 {
+			b.log.Warn("event was dropped, the events buffer is full", zap.String("type", ev.Type), zap.String("pipeline", ev.Pipeline))
+		}
+	}
+}
+
+// deliver calls the listeners until the bus is closed, the events queued before the close are delivered
+func (b *eventBus) deliver() {
+	for {
+		select {
+		case ev := <-b.ch:
+			b.call(ev)
+		case <-b.done:
+			for {
+				select {
+				case ev := <-b.ch:
+					b.call(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *eventBus) call(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, l := range b.listeners {
+		b.safeCall(l, ev)
+	}
+}
+
+// safeCall recovers the listener panic, the rest of the listeners still receive the event
+func (b *eventBus) safeCall(l EventListener, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error("panic in the event listener, recovered", zap.String("type", ev.Type), zap.Any("panic", r))
+		}
+	}()
+
+	l(ev)
+}
+
+// close stops the delivery once the queued events are delivered
+func (b *eventBus) close() {
+	if b == nil {
+		return
+	}
+
+	b.stop.Do(func() {
+		close(b.done)
+	})
+}
+
+// eventsDropped returns the number of the events dropped on the full buffer
+func (b *eventBus) eventsDropped() uint64 {
+	if b == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// emitEvent sends the event of the pipeline, the queue and the pipeline name are filled in
+func (c *Driver) emitEvent(typ, messageID, jobID string, err error) {
+	if c.events == nil || atomic.LoadInt32(&c.events.active) == 0 {
+		return
+	}
+
+	ev := Event{
+		Type:      typ,
+		Pipeline:  (*c.pipeline.Load()).Name(),
+		Queue:     getordefault(c.queue),
+		MessageID: messageID,
+		JobID:     jobID,
+	}
+	if err != nil {
+		ev.Error = redactError(err.Error())
+	}
+
+	c.events.emit(ev)
+}
+
+// emitItemEvent sends the message event of the item, no-op for the items without the events (the pushed jobs)
+func (i *Item) emitItemEvent(typ string) {
+	if i.Options.events == nil {
+		return
+	}
+
+	i.Options.events.emit(Event{
+		Type:      typ,
+		Pipeline:  i.Options.Pipeline,
+		Queue:     i.Options.Queue,
+		MessageID: i.Options.messageID,
+		JobID:     i.Ident,
+	})
+}
@@ -0,0 +1,98 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// eventRecorder collects the delivered events
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) listen(ev Event) {
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func (r *eventRecorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]string, 0, len(r.events))
+	for _, ev := range r.events {
+		out = append(out, ev.Type)
+	}
+	return out
+}
+
+func TestEventListener(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.events = newEventBus(16, zap.NewNop())
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String("body")})
+	c.client = fc
+
+	// no listeners, nothing is queued
+	c.emitEvent(EventPipelineStarted, "", "", nil)
+	require.Empty(t, c.events.ch)
+
+	rec := &eventRecorder{}
+	c.RegisterEventListener(rec.listen)
+	// a panicking listener doesn't break the delivery
+	c.RegisterEventListener(func(Event) { panic("listener") })
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+
+	item := pq.ExtractMin().(*Item)
+	require.NoError(t, item.Ack())
+	require.NoError(t, c.Stop(context.Background()))
+
+	require.Eventually(t, func() bool { return len(rec.types()) >= 4 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, []string{EventPipelineStarted, EventMessageReceived, EventMessageAcked, EventPipelineStopped}, rec.types())
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	ack := rec.events[2]
+	require.Equal(t, "m1", ack.MessageID)
+	require.Equal(t, item.ID(), ack.JobID)
+	require.Equal(t, "test", ack.Queue)
+	require.Equal(t, (*c.pipeline.Load()).Name(), ack.Pipeline)
+	require.False(t, ack.Time.IsZero())
+}
+
+func TestEventBusDrops(t *testing.T) {
+	b := newEventBus(1, zap.NewNop())
+	block := make(chan struct{})
+	b.listeners = []EventListener{func(Event) { <-block }}
+	b.active = 1
+	go b.deliver()
+
+	// the first event is being delivered, the second one is buffered, the rest are dropped
+	for range 5 {
+		b.emit(Event{Type: EventAWSError})
+		time.Sleep(time.Millisecond * 10)
+	}
+	require.GreaterOrEqual(t, b.eventsDropped(), uint64(3))
+
+	close(block)
+	b.close()
+	b.close()
+
+	// the pushed jobs and the test drivers have no bus
+	var nilBus *eventBus
+	nilBus.emit(Event{Type: EventAWSError})
+	nilBus.close()
+	require.Equal(t, uint64(0), nilBus.eventsDropped())
+}
@@ -0,0 +1,86 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// ReceiptHandleIsInvalid is returned by SQS for the receipt handles expired after the visibility timeout
+	ReceiptHandleIsInvalid string = "ReceiptHandleIsInvalid"
+	// MessageNotInflight is returned by SQS on the visibility change of the message which is not in flight anymore:
+	// already deleted or visible again (redelivered), there is nothing to change
+	MessageNotInflight string = "AWS.SimpleQueueService.MessageNotInflight"
+)
+
+func isExpiredHandle(err error) bool {
+	var hErr *types.ReceiptHandleIsInvalid
+	if stderr.As(err, &hErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	return stderr.As(err, &apiErr) && apiErr.ErrorCode() == ReceiptHandleIsInvalid
+}
+
+// isNotInflight reports the benign visibility change error, unlike the expired receipt handle the message is not
+// processed by anyone, so the visibility change is just skipped
+func isNotInflight(err error) bool {
+	var nErr *types.MessageNotInflight
+	if stderr.As(err, &nErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	// the JSON protocol returns the short code
+	return apiErr.ErrorCode() == MessageNotInflight || apiErr.ErrorCode() == "MessageNotInflight"
+}
+
+// deleteMessage deletes the processed message from the queue. The expired receipt handle means the message
+// is already visible again (and probably redelivered), so it is dropped and counted instead of failing the ack.
+func (i *Item) deleteMessage(ctx context.Context) error {
+	var err error
+	if i.Options.deleteBatch != nil {
+		err = i.Options.deleteBatch.delete(ctx, i.Options.receipt.get())
+	} else {
+		// the ack shouldn't fail on the short throttling
+		err = throttleRetry(ctx, func() error {
+			_, errD := i.Options.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      i.Options.queue,
+				ReceiptHandle: i.Options.receipt.get(),
+			})
+			return errD
+		})
+	}
+	if err == nil {
+		i.Options.receipt.done()
+		if i.Options.dropPayload != nil {
+			i.Options.dropPayload()
+		}
+		return nil
+	}
+
+	if !isExpiredHandle(err) || i.Options.expiredOnAck == nil {
+		return err
+	}
+
+	i.Options.receipt.done()
+	atomic.AddUint64(i.Options.expiredOnAck, 1)
+	i.debug("receipt handle expired before the delete, the message will be redelivered")
+
+	return nil
+}
+
+// ExpiredOnAck returns the number of the messages with the expired receipt handles on the ack
+func (c *Driver) ExpiredOnAck() uint64 {
+	return atomic.LoadUint64(&c.expiredOnAck)
+}
@@ -0,0 +1,61 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAckExpiredReceiptHandle(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+
+	var mu sync.Mutex
+	var confirmed []string
+	// odd handles expired while the messages were processed
+	fc.deleteFn = func(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+		n, _ := strconv.Atoi(aws.ToString(in.ReceiptHandle))
+		if n%2 == 1 {
+			return nil, &types.ReceiptHandleIsInvalid{Message: aws.String("The receipt handle has expired.")}
+		}
+		mu.Lock()
+		confirmed = append(confirmed, aws.ToString(in.ReceiptHandle))
+		mu.Unlock()
+		return &sqs.DeleteMessageOutput{}, nil
+	}
+
+	msgs := make([]types.Message, 0, 4)
+	for i := 0; i < 4; i++ {
+		id := strconv.Itoa(i)
+		msgs = append(msgs, types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id), Body: aws.String(id)})
+	}
+	fc.receiveFn = receiveOnce(msgs...)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 4 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	}
+
+	require.Equal(t, []string{"0", "2"}, confirmed)
+	require.Equal(t, uint64(2), c.ExpiredOnAck())
+
+	// other delete errors are still reported
+	fc.deleteFn = func(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+	}
+	item := &Item{Options: &Options{client: fc, expiredOnAck: &c.expiredOnAck}}
+	require.Error(t, item.deleteMessage(context.Background()))
+}
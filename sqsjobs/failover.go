@@ -0,0 +1,281 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFailoverThreshold is the number of the consecutive failed sends to the primary queue before the failover
+	defaultFailoverThreshold int = 3
+	defaultFailoverProbe         = time.Second * 30
+	// the primary region probe is a single attempt, the outage should not slow down the sends
+	failoverProbeTimeout = time.Second * 5
+)
+
+// sendFailover routes the sends (and the receives with the failover_receive) to the secondary queue (in another region)
+// while the primary region is unreachable. Only the connectivity errors count, the auth, throttling and validation errors
+// are returned to the caller as is.
+type sendFailover struct {
+	client    sqsClient
+	queueURL  *string
+	threshold int
+	interval  time.Duration
+	log       *zap.Logger
+	// called on every switch (true - failed over, false - failed back) outside the lock, nil - no-op
+	onSwitch func(active bool)
+
+	mu        sync.Mutex
+	failures  int
+	active    bool
+	nextProbe time.Time
+	now       func() time.Time
+}
+
+// newSendFailover creates the failover to the secondary queue URL, the client should be configured for the secondary region
+func newSendFailover(client sqsClient, queueURL string, threshold, interval int, log *zap.Logger) *sendFailover {
+	if threshold <= 0 {
+		threshold = defaultFailoverThreshold
+	}
+
+	probe := defaultFailoverProbe
+	if interval > 0 {
+		probe = time.Duration(interval) * time.Second
+	}
+
+	return &sendFailover{
+		client:    client,
+		queueURL:  &queueURL,
+		threshold: threshold,
+		interval:  probe,
+		log:       log,
+		now:       time.Now,
+	}
+}
+
+// failoverTarget validates the secondary queue and region, returns an empty URL if the failover is disabled
+func failoverTarget(queue, region string) (string, error) {
+	if queue == "" {
+		return "", nil
+	}
+
+	if region == "" {
+		return "", errors.Str("failover_region is required with failover_queue")
+	}
+
+	_, err := parseQueueURL(queue)
+	if err != nil {
+		return "", errors.Errorf("failover_queue should be the queue URL: %v", err)
+	}
+
+	return queue, nil
+}
+
+// observe records the result of the send to the primary queue, returns true if the sends are failed over
+func (f *sendFailover) observe(err error) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+
+	if err == nil {
+		f.failures = 0
+		f.mu.Unlock()
+		return false
+	}
+
+	if !isTransientNetError(err) {
+		f.mu.Unlock()
+		return false
+	}
+
+	f.failures++
+	if f.failures < f.threshold {
+		f.mu.Unlock()
+		return false
+	}
+
+	switched := !f.active
+	if switched {
+		f.active = true
+		f.nextProbe = f.now().Add(f.interval)
+		f.log.Warn("primary queue is unreachable, failed over to the secondary queue", zap.Stringp("failover queue", f.queueURL), zap.Int("failures", f.failures), zap.Error(err))
+	}
+	f.mu.Unlock()
+
+	if switched {
+		f.switched(true)
+	}
+
+	return true
+}
+
+// switched notifies about the switch, no-op if disabled
+func (f *sendFailover) switched(active bool) {
+	if f.onSwitch != nil {
+		f.onSwitch(active)
+	}
+}
+
+// useSecondary returns true while the sends are failed over. The primary is probed once per interval (by a single sender),
+// the sends are failed back after a successful probe.
+func (f *sendFailover) useSecondary(ctx context.Context, probe func(context.Context) error) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+	if !f.active {
+		f.mu.Unlock()
+		return false
+	}
+
+	now := f.now()
+	if now.Before(f.nextProbe) {
+		f.mu.Unlock()
+		return true
+	}
+	f.nextProbe = now.Add(f.interval)
+	f.mu.Unlock()
+
+	err := probe(ctx)
+
+	f.mu.Lock()
+	if err != nil {
+		f.log.Debug("primary queue is still unreachable", zap.Time("next probe", f.nextProbe), zap.Error(err))
+		f.mu.Unlock()
+		return true
+	}
+
+	// failed back by another caller
+	switched := f.active
+	f.active = false
+	f.failures = 0
+	f.mu.Unlock()
+
+	if switched {
+		f.log.Info("primary queue is reachable again, failed back to the primary queue")
+		f.switched(false)
+	}
+
+	return false
+}
+
+// send sends the message to the secondary queue, the input of the caller is not modified
+func (f *sendFailover) send(ctx context.Context, d *sqs.SendMessageInput) error {
+	in := *d
+	in.QueueUrl = f.queueURL
+
+	_, err := f.client.SendMessage(ctx, &in, withDeadline(ctx, sendDeadlineMargin))
+	return err
+}
+
+// checkFailoverReceive validates the failover_receive, requires the failover_queue
+func checkFailoverReceive(receive bool, f *sendFailover) (bool, error) {
+	if receive && f == nil {
+		return false, errors.Str("failover_receive requires the failover_queue")
+	}
+
+	return receive, nil
+}
+
+// secondarySource marks the context of the messages received from the secondary queue
+type secondarySource struct{}
+
+// receiveTarget returns the client and the queue to receive from: the secondary queue while failed over with the
+// failover_receive, the pipeline queue otherwise
+func (c *Driver) receiveTarget(ctx context.Context) (sqsClient, *string, bool) {
+	if c.failoverReceive && c.failover.useSecondary(ctx, c.probePrimary) {
+		return c.failover.client, c.failover.queueURL, true
+	}
+
+	return c.client, c.queueURL, false
+}
+
+// observeReceive records the result of the receive from the primary queue (failover_receive)
+func (c *Driver) observeReceive(err error) {
+	if c.failoverReceive {
+		c.failover.observe(err)
+	}
+}
+
+// withSource marks the context of the messages received from the secondary queue
+func withSource(ctx context.Context, secondary bool) context.Context {
+	if !secondary {
+		return ctx
+	}
+
+	return context.WithValue(ctx, secondarySource{}, true)
+}
+
+// sourceOf returns the client and the queue the message was received from, the receipt handles are valid only there
+func (c *Driver) sourceOf(ctx context.Context) (sqsClient, *string, bool) {
+	if v, ok := ctx.Value(secondarySource{}).(bool); ok && v && c.failover != nil {
+		return c.failover.client, c.failover.queueURL, true
+	}
+
+	return c.client, c.queueURL, false
+}
+
+// itemQueue returns the client and the queue of the received message
+func (c *Driver) itemQueue(item *Item) (sqsClient, *string) {
+	if item.Options.fromSecondary && c.failover != nil {
+		return c.failover.client, c.failover.queueURL
+	}
+
+	return c.client, c.queueURL
+}
+
+// failoverSwitched emits the failover event on every switch
+func (c *Driver) failoverSwitched(active bool) {
+	action := QueueFailedBack
+	if active {
+		action = QueueFailedOver
+	}
+
+	c.emitQueueEvent(context.Background(), action, nil)
+}
+
+// probePrimary checks the primary queue with a single attempt
+func (c *Driver) probePrimary(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, failoverProbeTimeout)
+	defer cancel()
+
+	_, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       c.queueURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	}, func(o *sqs.Options) {
+		o.RetryMaxAttempts = 1
+	})
+
+	return err
+}
+
+// initFailover creates the client for the secondary region, no-op if failover_queue is not set
+func (c *Driver) initFailover(insideAWS bool, conf *Config, queue string, threshold, interval int, lifetime time.Duration) error {
+	url, err := failoverTarget(queue, conf.FailoverRegion)
+	if err != nil || url == "" {
+		return err
+	}
+
+	secondary := *conf
+	secondary.Region = conf.FailoverRegion
+	// the VPC endpoint of the pipeline is regional
+	secondary.pipelineEndpoint = ""
+
+	client, err := newClient(insideAWS, &secondary, c.log, lifetime)
+	if err != nil {
+		return errors.Errorf("failed to create the failover client: %v", err)
+	}
+
+	c.failover = newSendFailover(withRetryBudget(withAPIMetrics(client, c.api), c.budget), url, threshold, interval, c.log)
+	c.failover.onSwitch = c.failoverSwitched
+	return nil
+}
@@ -0,0 +1,186 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSendFailover(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.netRetries = 0
+
+	outage := true
+	primary := newFakeClient()
+	primary.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		if outage {
+			return nil, connReset()
+		}
+		return &sqs.SendMessageOutput{MessageId: aws.String("1")}, nil
+	}
+	primary.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		if outage {
+			return nil, connReset()
+		}
+		return &sqs.GetQueueAttributesOutput{}, nil
+	}
+	c.client = primary
+
+	secondary := newFakeClient()
+	now := time.Now()
+	c.failover = newSendFailover(secondary, "https://sqs.us-west-2.amazonaws.com/000000000000/test", 2, 10, zap.NewNop())
+	c.failover.now = func() time.Time { return now }
+
+	push := func() error {
+		return c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), Options: &Options{}})
+	}
+
+	// below the threshold, the error is returned
+	require.Error(t, push())
+	require.Len(t, secondary.sent, 0)
+
+	// failed over, the message is sent to the secondary queue
+	require.NoError(t, push())
+	require.Len(t, secondary.sent, 1)
+	require.Equal(t, "https://sqs.us-west-2.amazonaws.com/000000000000/test", aws.ToString(secondary.sent[0].QueueUrl))
+
+	// the primary is not called until the probe
+	require.NoError(t, push())
+	require.Len(t, secondary.sent, 2)
+	require.Equal(t, 2, primary.called("SendMessage"))
+
+	// the probe fails, still failed over
+	now = now.Add(time.Second * 10)
+	require.NoError(t, push())
+	require.Len(t, secondary.sent, 3)
+	require.Equal(t, 1, primary.called("GetQueueAttributes"))
+
+	// recovered, failed back after the next probe
+	outage = false
+	now = now.Add(time.Second * 5)
+	require.NoError(t, push())
+	require.Len(t, secondary.sent, 4)
+	now = now.Add(time.Second * 5)
+	require.NoError(t, push())
+	require.Len(t, secondary.sent, 4)
+	require.Equal(t, 3, primary.called("SendMessage"))
+	require.Equal(t, aws.ToString(c.queueURL), aws.ToString(primary.sent[0].QueueUrl))
+}
+
+func TestSendFailoverErrors(t *testing.T) {
+	f := newSendFailover(newFakeClient(), "https://sqs.us-west-2.amazonaws.com/000000000000/test", 1, 0, zap.NewNop())
+	require.Equal(t, defaultFailoverProbe, f.interval)
+
+	// auth and throttling errors never trigger the failover
+	require.False(t, f.observe(stderr.New("AccessDenied")))
+	require.False(t, f.observe(nil))
+	require.True(t, f.observe(connReset()))
+
+	// disabled
+	require.False(t, (*sendFailover)(nil).observe(connReset()))
+	require.False(t, (*sendFailover)(nil).useSecondary(context.Background(), nil))
+
+	url, err := failoverTarget("", "")
+	require.NoError(t, err)
+	require.Empty(t, url)
+	_, err = failoverTarget("https://sqs.us-west-2.amazonaws.com/000000000000/test", "")
+	require.Error(t, err)
+	_, err = failoverTarget("test", "us-west-2")
+	require.Error(t, err)
+}
+
+func TestReceiveFailover(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.queueEvents = newQueueEvents(4)
+
+	outage := true
+	primary := newFakeClient()
+	primary.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		if outage {
+			return nil, connReset()
+		}
+		return &sqs.GetQueueAttributesOutput{}, nil
+	}
+	c.client = primary
+
+	secondary := newFakeClient()
+	now := time.Now()
+	c.failover = newSendFailover(secondary, "https://sqs.us-west-2.amazonaws.com/000000000000/test", 2, 10, zap.NewNop())
+	c.failover.now = func() time.Time { return now }
+	c.failover.onSwitch = c.failoverSwitched
+
+	var err error
+	_, err = checkFailoverReceive(true, nil)
+	require.Error(t, err)
+	c.failoverReceive, err = checkFailoverReceive(true, c.failover)
+	require.NoError(t, err)
+
+	_, url, fromSecondary := c.receiveTarget(context.Background())
+	require.False(t, fromSecondary)
+	require.Equal(t, c.queueURL, url)
+
+	c.observeReceive(connReset())
+	c.observeReceive(connReset())
+	client, url, fromSecondary := c.receiveTarget(context.Background())
+	require.True(t, fromSecondary)
+	require.Equal(t, sqsClient(secondary), client)
+	require.Equal(t, "https://sqs.us-west-2.amazonaws.com/000000000000/test", aws.ToString(url))
+
+	ev := <-c.queueEvents
+	require.Equal(t, QueueFailedOver, ev.Action)
+	require.Nil(t, ev.After)
+
+	// the message is acknowledged in the secondary queue
+	item, err := c.unpack(withSource(context.Background(), true), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String("body")})
+	require.NoError(t, err)
+	require.NoError(t, item.Ack())
+	require.Equal(t, 1, secondary.called("DeleteMessage"))
+	require.Equal(t, 0, primary.called("DeleteMessage"))
+
+	// the primary is reachable again
+	outage = false
+	now = now.Add(time.Second * 10)
+	_, _, fromSecondary = c.receiveTarget(context.Background())
+	require.False(t, fromSecondary)
+
+	ev = <-c.queueEvents
+	require.Equal(t, QueueFailedBack, ev.Action)
+}
+
+func TestFailoverSource(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	primary := newFakeClient()
+	c.client = primary
+	secondary := newFakeClient()
+	c.failover = newSendFailover(secondary, "https://sqs.us-west-2.amazonaws.com/000000000000/test", 2, 10, zap.NewNop())
+	c.atMostOnce = true
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/dlq")
+
+	ctx := withSource(context.Background(), true)
+
+	// at_most_once, deleted from the secondary queue before the dispatch
+	require.False(t, c.handleMessage(ctx, &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String("body")}))
+	require.Equal(t, uint64(1), pq.Len())
+	require.Equal(t, 1, secondary.called("DeleteMessage"))
+	require.Equal(t, "https://sqs.us-west-2.amazonaws.com/000000000000/test", aws.ToString(secondary.deleted[0].QueueUrl))
+
+	// returned to the secondary queue
+	c.releaseReceived(ctx, []types.Message{{MessageId: aws.String("2"), ReceiptHandle: aws.String("rh-2")}})
+	require.Equal(t, 1, secondary.called("ChangeMessageVisibility"))
+
+	// sent to the dead-letter queue in the primary region, deleted from the secondary queue
+	require.NoError(t, c.moveToDLQ(ctx, &types.Message{MessageId: aws.String("3"), ReceiptHandle: aws.String("rh-3"), Body: aws.String("body")}, stderr.New("poison")))
+	require.Equal(t, 1, primary.called("SendMessage"))
+	require.Equal(t, 2, secondary.called("DeleteMessage"))
+
+	require.Equal(t, 0, primary.called("DeleteMessage"))
+	require.Equal(t, 0, primary.called("ChangeMessageVisibility"))
+}
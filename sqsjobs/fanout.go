@@ -0,0 +1,114 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/errors"
+)
+
+// fanOutTarget is the additional destination queue of the pushed messages (fan_out_queues)
+type fanOutTarget struct {
+	name *string
+	url  *string
+	// push batching of the destination, nil if disabled
+	batch *sendBatcher
+}
+
+// fanOutQueues validates the fan_out_queues (the names or the URLs), the names are resolved on setup. The destinations
+// should be of the same type (standard or FIFO) as the pipeline queue, the FIFO message group is sent to every queue.
+func fanOutQueues(prefix string, queues []string, queue *string) ([]*fanOutTarget, error) {
+	fifo := strings.HasSuffix(getordefault(queue), fifoSuffix)
+	seen := make(map[string]struct{}, len(queues))
+
+	var targets []*fanOutTarget
+	for _, value := range queues {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if strings.HasPrefix(value, "arn:") {
+			return nil, errors.Errorf("fan_out_queues: %s, the SNS topics are not supported, subscribe the queues to the topic instead", value)
+		}
+
+		name, url, err := queueTarget(prefix, value)
+		if err != nil {
+			return nil, errors.Errorf("fan_out_queues: %v", err)
+		}
+
+		if *name == getordefault(queue) {
+			return nil, errors.Errorf("fan_out_queues: %s is the pipeline queue", *name)
+		}
+
+		if strings.HasSuffix(*name, fifoSuffix) != fifo {
+			return nil, errors.Errorf("fan_out_queues: %s, the destination should be a FIFO queue only if the pipeline queue is", *name)
+		}
+
+		if _, ok := seen[*name]; ok {
+			continue
+		}
+		seen[*name] = struct{}{}
+
+		targets = append(targets, &fanOutTarget{name: name, url: url})
+	}
+
+	return targets, nil
+}
+
+// deliver sends the prepared message to the pipeline queue and, concurrently, its copies to the fan_out_queues.
+// The errors of the destinations are aggregated, a failed destination doesn't stop the others.
+func (c *Driver) deliver(ctx context.Context, d *sqs.SendMessageInput) error {
+	if len(c.fanOut) == 0 {
+		return c.send(ctx, d)
+	}
+
+	errs := make([]error, len(c.fanOut)+1)
+
+	var wg sync.WaitGroup
+	wg.Add(len(c.fanOut))
+	for i, t := range c.fanOut {
+		in := *d
+		in.QueueUrl = t.url
+
+		go func() {
+			defer wg.Done()
+
+			err := t.send(ctx, c, &in)
+			if err != nil {
+				errs[i+1] = errors.Errorf("fan_out %s: %v", getordefault(t.name), err)
+			}
+		}()
+	}
+
+	// the error of the pipeline queue is kept as is, e.g. to recreate the queue deleted out-of-band
+	errs[0] = c.send(ctx, d)
+	wg.Wait()
+
+	return stderr.Join(errs...)
+}
+
+// send sends the message to the destination queue, batched if enabled
+func (t *fanOutTarget) send(ctx context.Context, c *Driver, in *sqs.SendMessageInput) error {
+	if t.batch != nil {
+		return t.batch.send(ctx, in)
+	}
+
+	_, err := sendRetry(ctx, c.log, c.netRetries, c.budget, "SendMessage", func() (*sqs.SendMessageOutput, error) {
+		return c.client.SendMessage(ctx, in, withDeadline(ctx, sendDeadlineMargin))
+	})
+
+	return err
+}
+
+// flushFanOut flushes the pending batches of the fan_out_queues
+func (c *Driver) flushFanOut() {
+	for _, t := range c.fanOut {
+		if t.batch != nil {
+			t.batch.flushPending()
+		}
+	}
+}
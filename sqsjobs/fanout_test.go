@@ -0,0 +1,111 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanOutQueues(t *testing.T) {
+	targets, err := fanOutQueues("rr-", []string{"a", " ", "http://127.0.0.1:9324/000000000000/b", "a"}, ptr("rr-test"))
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	require.Equal(t, "rr-a", *targets[0].name)
+	require.Nil(t, targets[0].url)
+	require.Equal(t, "b", *targets[1].name)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/b", *targets[1].url)
+
+	targets, err = fanOutQueues("", nil, ptr("test"))
+	require.NoError(t, err)
+	require.Empty(t, targets)
+
+	_, err = fanOutQueues("", []string{"arn:aws:sns:us-east-1:000000000000:topic"}, ptr("test"))
+	require.Error(t, err)
+	_, err = fanOutQueues("", []string{"test"}, ptr("test"))
+	require.Error(t, err)
+	_, err = fanOutQueues("", []string{"a.fifo"}, ptr("test"))
+	require.Error(t, err)
+	_, err = fanOutQueues("", []string{"a"}, ptr("test.fifo"))
+	require.Error(t, err)
+	_, err = fanOutQueues("", []string{"ftp://127.0.0.1/000000000000/a"}, ptr("test"))
+	require.Error(t, err)
+}
+
+func TestFanOutPush(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.fanOut = []*fanOutTarget{
+		{name: ptr("a"), url: ptr("http://127.0.0.1:9324/000000000000/a")},
+		{name: ptr("b"), url: ptr("http://127.0.0.1:9324/000000000000/b")},
+	}
+
+	require.NoError(t, c.pushItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{}}))
+	require.Len(t, fc.sent, 3)
+
+	urls := make(map[string]string, len(fc.sent))
+	for _, in := range fc.sent {
+		urls[aws.ToString(in.QueueUrl)] = aws.ToString(in.MessageBody)
+	}
+	require.Len(t, urls, 3)
+	for _, name := range []string{"test", "a", "b"} {
+		require.Contains(t, urls, "http://127.0.0.1:9324/000000000000/"+name)
+		require.Equal(t, urls["http://127.0.0.1:9324/000000000000/test"], urls["http://127.0.0.1:9324/000000000000/"+name])
+	}
+
+	// the failed destination doesn't stop the others, the error names it
+	fc.sendFn = func(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		if aws.ToString(in.QueueUrl) == "http://127.0.0.1:9324/000000000000/a" {
+			return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+		}
+		return &sqs.SendMessageOutput{MessageId: aws.String("id")}, nil
+	}
+
+	err := c.pushItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fan_out a")
+	require.NotContains(t, err.Error(), "fan_out b")
+	require.Len(t, fc.sent, 6)
+}
+
+func TestFanOutBatch(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.fanOut = []*fanOutTarget{{name: ptr("a"), url: ptr("http://127.0.0.1:9324/000000000000/a")}}
+	require.NoError(t, c.initSendBatcher(2, 0, 0, 0))
+	require.NotNil(t, c.fanOut[0].batch)
+
+	var wg sync.WaitGroup
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, c.pushItem(context.Background(), &Item{Job: "job", Ident: "id" + string(rune('0'+i)), Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{}}))
+		}()
+	}
+	wg.Wait()
+
+	require.Empty(t, fc.sent)
+	require.Len(t, fc.batches, 2)
+	urls := []string{aws.ToString(fc.batches[0].QueueUrl), aws.ToString(fc.batches[1].QueueUrl)}
+	require.Contains(t, urls, "http://127.0.0.1:9324/000000000000/test")
+	require.Contains(t, urls, "http://127.0.0.1:9324/000000000000/a")
+}
+
+func TestFanOutRequeue(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.fanOut = []*fanOutTarget{{name: ptr("a"), url: ptr("http://127.0.0.1:9324/000000000000/a")}}
+
+	// the requeued message isn't sent to the destinations again
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{}}))
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test", aws.ToString(fc.sent[0].QueueUrl))
+}
@@ -0,0 +1,104 @@
+package sqsjobs
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// MessageGroupIDHeader is the job header with the FIFO message group, takes precedence over the message_group_id option
+	MessageGroupIDHeader string = "message_group_id"
+	// MessageDeduplicationIDHeader is the job header with the FIFO deduplication ID, takes precedence over the message_deduplication_id option
+	MessageDeduplicationIDHeader string = "message_deduplication_id"
+)
+
+// fifoPlaceholder matches the message_group_id/message_deduplication_id template placeholders: {job}, {id}, {pipeline} and {header:name}
+var fifoPlaceholder = regexp.MustCompile(`\{(job|id|pipeline|header:[^{}]+)\}`)
+
+// fifoQueue applies the fifo option: the .fifo suffix is appended to the queue name if missing, the FifoQueue
+// (and the ContentBasedDeduplication) attribute is set for the FIFO queues on create
+func fifoQueue(queue, queueURL *string, attrs map[string]string, fifo, contentDedup bool) error {
+	if fifo && !strings.HasSuffix(*queue, fifoSuffix) {
+		if queueURL != nil {
+			return errors.Errorf("fifo: the queue URL %s doesn't have the %s suffix", *queueURL, fifoSuffix)
+		}
+		*queue += fifoSuffix
+	}
+
+	if !strings.HasSuffix(*queue, fifoSuffix) {
+		if contentDedup {
+			return errors.Errorf("content_based_deduplication is supported only by the FIFO queues, queue: %s", *queue)
+		}
+		return nil
+	}
+
+	if _, ok := attrs[FifoQueueAWS]; !ok {
+		attrs[FifoQueueAWS] = "true"
+	}
+
+	if contentDedup {
+		if v, ok := attrs[ContentBasedDeduplicationAWS]; ok && !strings.EqualFold(v, "true") {
+			return errors.Errorf("content_based_deduplication conflicts with the %s attribute: %s", ContentBasedDeduplicationAWS, v)
+		}
+		attrs[ContentBasedDeduplicationAWS] = "true"
+	}
+
+	return nil
+}
+
+// messageGroup returns the message group of the job: the message_group_id header or the expanded message_group_id option
+func (c *Driver) messageGroup(item *Item) string {
+	if v := headerValue(item.headers, MessageGroupIDHeader); v != "" {
+		return v
+	}
+
+	return c.expandFIFOTemplate(c.messageGroupID, item)
+}
+
+// deduplicationID sets the deduplication ID of the message sent to the FIFO queue: the message_deduplication_id header,
+// the expanded message_deduplication_id option or the job ID. With content_based_deduplication the job ID is not used,
+// SQS deduplicates the messages by the body.
+func (c *Driver) deduplicationID(item *Item, in *sqs.SendMessageInput, queue *string) {
+	if !strings.HasSuffix(*queue, fifoSuffix) {
+		return
+	}
+
+	id := headerValue(item.headers, MessageDeduplicationIDHeader)
+	if id == "" {
+		id = c.expandFIFOTemplate(c.dedupIDTemplate, item)
+	}
+
+	switch {
+	case id != "":
+		in.MessageDeduplicationId = aws.String(id)
+	case c.contentDedup:
+		in.MessageDeduplicationId = nil
+	default:
+		in.MessageDeduplicationId = dedup(item.ID(), queue)
+	}
+}
+
+// expandFIFOTemplate replaces the placeholders with the job values, the missing headers are replaced with an empty string
+func (c *Driver) expandFIFOTemplate(tpl string, item *Item) string {
+	if !strings.Contains(tpl, "{") {
+		return tpl
+	}
+
+	return fifoPlaceholder.ReplaceAllStringFunc(tpl, func(p string) string {
+		name := p[1 : len(p)-1]
+		switch name {
+		case "job":
+			return item.Job
+		case "id":
+			return item.ID()
+		case "pipeline":
+			return (*c.pipeline.Load()).Name()
+		default:
+			return headerValue(item.headers, strings.TrimPrefix(name, "header:"))
+		}
+	})
+}
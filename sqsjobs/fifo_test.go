@@ -0,0 +1,80 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFIFOMessageIDs(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.queue = aws.String("orders.fifo")
+	c.messageGroupID = "{header:tenant}-{job}"
+	c.dedupIDTemplate = "{pipeline}:{id}"
+
+	item := &Item{Job: "order.created", Ident: "id-1", headers: map[string][]string{"tenant": {"acme"}}, Options: &Options{}}
+	in, err := c.prepare(context.Background(), item, c.queueURL, c.queue)
+	require.NoError(t, err)
+	require.Equal(t, "acme-order.created", aws.ToString(in.MessageGroupId))
+	require.Equal(t, "test:id-1", aws.ToString(in.MessageDeduplicationId))
+
+	// the job headers take precedence over the templates
+	item = &Item{Job: "order.created", Ident: "id-2", headers: map[string][]string{
+		MessageGroupIDHeader:         {"group-7"},
+		MessageDeduplicationIDHeader: {"dedup-7"},
+	}, Options: &Options{}}
+	in, err = c.prepare(context.Background(), item, c.queueURL, c.queue)
+	require.NoError(t, err)
+	require.Equal(t, "group-7", aws.ToString(in.MessageGroupId))
+	require.Equal(t, "dedup-7", aws.ToString(in.MessageDeduplicationId))
+
+	// content based deduplication - no ID unless set explicitly
+	c.dedupIDTemplate = ""
+	c.contentDedup = true
+	in, err = c.prepare(context.Background(), &Item{Job: "j", Ident: "id-3", Options: &Options{}}, c.queueURL, c.queue)
+	require.NoError(t, err)
+	require.Nil(t, in.MessageDeduplicationId)
+
+	// the job ID by default
+	c.contentDedup = false
+	in, err = c.prepare(context.Background(), &Item{Job: "j", Ident: "id-4", Options: &Options{}}, c.queueURL, c.queue)
+	require.NoError(t, err)
+	require.Equal(t, "id-4", aws.ToString(in.MessageDeduplicationId))
+
+	// standard queue - no deduplication ID
+	c.queue = aws.String("orders")
+	in, err = c.prepare(context.Background(), &Item{Job: "j", Ident: "id-5", Options: &Options{}}, c.queueURL, c.queue)
+	require.NoError(t, err)
+	require.Nil(t, in.MessageDeduplicationId)
+}
+
+func TestFIFOQueueDeclaration(t *testing.T) {
+	// the fifo option appends the suffix
+	attrs := map[string]string{}
+	queue := aws.String("orders")
+	require.NoError(t, fifoQueue(queue, nil, attrs, true, true))
+	require.Equal(t, "orders.fifo", *queue)
+	require.Equal(t, map[string]string{FifoQueueAWS: "true", ContentBasedDeduplicationAWS: "true"}, attrs)
+
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.queue = queue
+	c.attributes = attrs
+	require.NoError(t, manageQueue(context.Background(), c))
+	fc.mu.Lock()
+	require.Equal(t, "orders.fifo", aws.ToString(fc.created[0].QueueName))
+	require.Equal(t, "true", fc.created[0].Attributes[ContentBasedDeduplicationAWS])
+	fc.mu.Unlock()
+
+	// the suffix alone makes the queue FIFO
+	attrs = map[string]string{}
+	require.NoError(t, fifoQueue(aws.String("events.fifo"), nil, attrs, false, false))
+	require.Equal(t, "true", attrs[FifoQueueAWS])
+
+	require.Error(t, fifoQueue(aws.String("events"), nil, map[string]string{}, false, true))
+	require.Error(t, fifoQueue(aws.String("events"), aws.String("http://127.0.0.1:9324/000000000000/events"), map[string]string{}, true, false))
+	require.Error(t, fifoQueue(aws.String("events.fifo"), nil, map[string]string{ContentBasedDeduplicationAWS: "false"}, false, true))
+}
@@ -0,0 +1,165 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// MessageGroupIDAttr is the system attribute with the FIFO message group
+const MessageGroupIDAttr string = "MessageGroupId"
+
+// groupFullDelay is the visibility timeout (in seconds) of the messages not parked because of the group_max_parked,
+// they are received again once the delay expires
+const groupFullDelay int32 = 5
+
+// acquire results
+const (
+	groupFree int = iota
+	groupParked
+	groupFull
+)
+
+// groupGate allows at most one in-flight message per FIFO message group.
+// Messages of the busy group are parked in the receive order until the previous one is acknowledged.
+type groupGate struct {
+	mu sync.Mutex
+	// group is busy if present in the map, value - parked messages
+	busy map[string][]*Item
+	// the number of the parked messages of all groups, bounded by the maxParked (0 - unlimited)
+	parked    int
+	maxParked int
+}
+
+// groupOrdering returns the gate of the strict_group_ordering, nil if disabled
+func groupOrdering(enabled bool, maxParked int) (*groupGate, error) {
+	if maxParked < 0 {
+		return nil, errors.Errorf("group_max_parked should not be negative, provided: %d", maxParked)
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	return newGroupGate(maxParked), nil
+}
+
+func newGroupGate(maxParked int) *groupGate {
+	return &groupGate{
+		busy:      make(map[string][]*Item),
+		maxParked: maxParked,
+	}
+}
+
+// acquire marks the group as busy, groupFree - the item might be dispatched right away, groupParked - the item is parked,
+// groupFull - the group is busy and the parked limit is reached, the item is neither dispatched nor parked.
+// The unbounded items (already deleted from the queue) are always parked.
+func (g *groupGate) acquire(group string, item *Item, bounded bool) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	parked, ok := g.busy[group]
+	if !ok {
+		g.busy[group] = nil
+		return groupFree
+	}
+
+	if bounded && g.maxParked > 0 && g.parked >= g.maxParked {
+		return groupFull
+	}
+
+	g.busy[group] = append(parked, item)
+	g.parked++
+	return groupParked
+}
+
+// release returns the next parked item of the group, the group becomes free if there are no parked items
+func (g *groupGate) release(group string) *Item {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	parked, ok := g.busy[group]
+	if !ok {
+		return nil
+	}
+
+	if len(parked) == 0 {
+		delete(g.busy, group)
+		return nil
+	}
+
+	next := parked[0]
+	parked[0] = nil
+	g.busy[group] = parked[1:]
+	g.parked--
+
+	return next
+}
+
+// drain returns all parked items, the groups become free
+func (g *groupGate) drain() []*Item {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var parked []*Item
+	for group, items := range g.busy {
+		parked = append(parked, items...)
+		delete(g.busy, group)
+	}
+	g.parked = 0
+
+	return parked
+}
+
+// dispatch sends the item to the priority queue, respecting the per-group ordering if enabled
+func (c *Driver) dispatch(item *Item) {
+	group := item.Options.groupID
+	// no native grouping on the standard queues, the partition key is used instead
+	if group == "" {
+		group = item.Options.PartitionKey
+	}
+	if c.groups == nil || group == "" {
+		c.insert(item)
+		return
+	}
+
+	// the partition key lease (if any) is released first
+	prev := item.Options.release
+	item.Options.release = chainRelease(prev, c.releaseGroup(group))
+
+	// the auto-acked messages can't be returned to the queue
+	bounded := !item.Options.AutoAck && item.Options.receipt.get() != nil
+
+	switch c.groups.acquire(group, item, bounded) {
+	case groupParked:
+		c.log.Debug("message group is busy, message parked", zap.String("group", group), zap.String("ID", item.ID()))
+		return
+	case groupFull:
+		// the group is not owned by the item, bounded memory: the message is received again after the delay
+		item.Options.release = prev
+		c.log.Debug("message group is busy and group_max_parked is reached, message returned to the queue", zap.String("group", group), zap.String("ID", item.ID()))
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			c.returnItem(ctx, item, groupFullDelay)
+		}()
+		return
+	}
+
+	c.insert(item)
+}
+
+// releaseGroup returns a func called once the item is processed, it dispatches the next parked item of the group
+func (c *Driver) releaseGroup(group string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if next := c.groups.release(group); next != nil {
+				c.insert(next)
+			}
+		})
+	}
+}
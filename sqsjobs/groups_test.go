@@ -0,0 +1,115 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func groupMessage(t *testing.T, c *Driver, group string, n int) *Item {
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:     aws.String(group + strconv.Itoa(n)),
+		ReceiptHandle: aws.String(group + strconv.Itoa(n)),
+		Body:          aws.String(group + strconv.Itoa(n)),
+		Attributes:    map[string]string{MessageGroupIDAttr: group},
+	})
+	require.NoError(t, err)
+	atomic.AddInt64(c.msgInFlight, 1)
+	return item
+}
+
+func bodies(pq *testQueue) []string {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	ret := make([]string, 0, len(pq.items))
+	for _, j := range pq.items {
+		ret = append(ret, string(j.Body()))
+	}
+	return ret
+}
+
+func TestStrictGroupOrdering(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.groups = newGroupGate(0)
+	require.Contains(t, c.receiveAttributes(), types.QueueAttributeName(MessageGroupIDAttr))
+
+	for i := 1; i <= 3; i++ {
+		c.dispatch(groupMessage(t, c, "a", i))
+	}
+	c.dispatch(groupMessage(t, c, "b", 1))
+	c.dispatch(groupMessage(t, c, "b", 2))
+
+	// different groups in parallel, one message per group
+	require.ElementsMatch(t, []string{"a1", "b1"}, bodies(pq))
+
+	a1 := pq.ExtractMin()
+	require.Equal(t, "a1", string(a1.Body()))
+	require.NoError(t, a1.Ack())
+	require.ElementsMatch(t, []string{"b1", "a2"}, bodies(pq))
+
+	b1 := pq.ExtractMin()
+	require.NoError(t, b1.Ack())
+	a2 := pq.ExtractMin()
+	require.Equal(t, "a2", string(a2.Body()))
+	require.NoError(t, a2.Ack())
+	require.ElementsMatch(t, []string{"b2", "a3"}, bodies(pq))
+
+	b2 := pq.ExtractMin()
+	require.NoError(t, b2.Ack())
+	a3 := pq.ExtractMin()
+	require.NoError(t, a3.Ack())
+	require.Empty(t, c.groups.busy)
+}
+
+func TestGroupOrderingDisabled(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+
+	for i := 1; i <= 3; i++ {
+		c.dispatch(groupMessage(t, c, "a", i))
+	}
+
+	require.Equal(t, []string{"a1", "a2", "a3"}, bodies(pq))
+}
+
+func TestGroupMaxParked(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.groups = newGroupGate(1)
+
+	for i := 1; i <= 3; i++ {
+		c.dispatch(groupMessage(t, c, "a", i))
+	}
+	c.dispatch(groupMessage(t, c, "b", 1))
+
+	// a2 is parked, a3 is over the limit and returned to the queue
+	require.ElementsMatch(t, []string{"a1", "b1"}, bodies(pq))
+	require.Eventually(t, func() bool { return fc.called("ChangeMessageVisibility") == 1 }, time.Second*5, time.Millisecond*10)
+
+	fc.mu.Lock()
+	require.Equal(t, "a3", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, groupFullDelay, fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+	require.Eventually(t, func() bool { return atomic.LoadInt64(c.msgInFlight) == 3 }, time.Second*5, time.Millisecond*10)
+
+	a1 := pq.ExtractMin()
+	require.Equal(t, "a1", string(a1.Body()))
+	require.NoError(t, a1.Ack())
+	require.ElementsMatch(t, []string{"b1", "a2"}, bodies(pq))
+	require.Equal(t, 0, c.groups.parked)
+
+	_, err := groupOrdering(true, -1)
+	require.Error(t, err)
+	g, err := groupOrdering(false, 10)
+	require.NoError(t, err)
+	require.Nil(t, g)
+}
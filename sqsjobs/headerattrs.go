@@ -0,0 +1,101 @@
+package sqsjobs
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// attributeMapping is the header_attributes/attribute_headers configuration: the job headers written as the message
+// attributes on push (e.g. for the SNS/Lambda filter policies) and the message attributes read into the headers on receive
+type attributeMapping struct {
+	// header -> attribute
+	promote map[string]string
+	// attribute -> header
+	demote map[string]string
+	// the promoted attribute names, never moved to the overflow attribute
+	pinned []string
+}
+
+// newAttributeMapping validates the header_attributes and attribute_headers maps
+func newAttributeMapping(headerAttrs, attrHeaders map[string]string) (attributeMapping, error) {
+	m := attributeMapping{}
+
+	if len(headerAttrs) > 0 {
+		m.promote = make(map[string]string, len(headerAttrs))
+		for header, name := range headerAttrs {
+			name = strings.TrimSpace(name)
+			if header == "" || name == "" {
+				return attributeMapping{}, errors.Str("header_attributes: the header and the attribute names should not be empty")
+			}
+
+			if reservedAttr(name) || strings.HasPrefix(strings.ToLower(name), "aws.") || strings.HasPrefix(strings.ToLower(name), "amazon.") {
+				return attributeMapping{}, errors.Errorf("header_attributes: the attribute name %s is reserved", name)
+			}
+
+			if slices.Contains(m.pinned, name) {
+				return attributeMapping{}, errors.Errorf("header_attributes: several headers are mapped to the attribute %s", name)
+			}
+
+			m.promote[header] = name
+			m.pinned = append(m.pinned, name)
+		}
+	}
+
+	if len(attrHeaders) > 0 {
+		m.demote = make(map[string]string, len(attrHeaders))
+		for name, header := range attrHeaders {
+			header = strings.TrimSpace(header)
+			if name == "" || header == "" {
+				return attributeMapping{}, errors.Str("attribute_headers: the attribute and the header names should not be empty")
+			}
+
+			m.demote[name] = header
+		}
+	}
+
+	return m, nil
+}
+
+// headerAttributes writes the header_attributes headers as the String message attributes
+func (c *Driver) headerAttributes(item *Item, in *sqs.SendMessageInput) error {
+	for header, name := range c.attrMapping.promote {
+		value := headerValue(item.headers, header)
+		if value == "" {
+			continue
+		}
+
+		if _, ok := in.MessageAttributes[name]; ok {
+			return errors.Errorf("header_attributes: the header %s conflicts with the existing message attribute %s", header, name)
+		}
+
+		in.MessageAttributes[name] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(value)}
+	}
+
+	return nil
+}
+
+// attributeHeaders reads the attribute_headers message attributes into the headers, the existing headers are kept
+func (c *Driver) attributeHeaders(attrs map[string]types.MessageAttributeValue, h map[string][]string) {
+	for name, header := range c.attrMapping.demote {
+		attr, ok := attrs[name]
+		if !ok {
+			continue
+		}
+
+		if _, exists := h[header]; exists {
+			continue
+		}
+
+		switch {
+		case attr.StringValue != nil:
+			h[header] = []string{*attr.StringValue}
+		case len(attr.BinaryValue) > 0:
+			h[header] = []string{string(attr.BinaryValue)}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderAttributesRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	var err error
+	c.attrMapping, err = newAttributeMapping(map[string]string{"X-Tenant-ID": "tenant_id"}, map[string]string{"tenant_id": "X-Tenant", "region": "X-Region"})
+	require.NoError(t, err)
+
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{"X-Tenant-ID": {"acme"}}, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	require.Len(t, fc.sent, 1)
+	attrs := fc.sent[0].MessageAttributes
+	require.Equal(t, "acme", aws.ToString(attrs["tenant_id"].StringValue))
+	require.Equal(t, StringType, aws.ToString(attrs["tenant_id"].DataType))
+
+	// the third-party message attributes are read into the headers
+	attrs["region"] = strAttr("eu-west-1")
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: fc.sent[0].MessageBody, MessageAttributes: attrs})
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme"}, out.headers["X-Tenant"])
+	require.Equal(t, []string{"eu-west-1"}, out.headers["X-Region"])
+	require.Equal(t, []string{"acme"}, out.headers["X-Tenant-ID"])
+}
+
+func TestHeaderAttributesPinned(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	var err error
+	c.attrMapping, err = newAttributeMapping(map[string]string{"X-Tenant-ID": "tenant_id"}, nil)
+	require.NoError(t, err)
+
+	// the typed attributes exceed the limit, the mapped one stays top-level
+	c.preserveTypes = true
+	headers := map[string][]string{"X-Tenant-ID": {"acme"}}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		headers[name] = []string{"v"}
+		headers[AttrTypesHeader] = append(headers[AttrTypesHeader], name+":String")
+	}
+
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: headers, Options: &Options{}}))
+	attrs := fc.sent[0].MessageAttributes
+	require.Len(t, attrs, maxMessageAttributes)
+	require.Contains(t, attrs, AttrOverflow)
+	require.Contains(t, attrs, "tenant_id")
+	require.Contains(t, attrs, jobs.RRHeaders)
+}
+
+func TestNewAttributeMapping(t *testing.T) {
+	m, err := newAttributeMapping(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, m.pinned)
+
+	for _, bad := range []map[string]string{
+		{"X-Tenant-ID": ""},
+		{"X-Tenant-ID": jobs.RRJob},
+		{"X-Tenant-ID": "AWS.TraceHeader"},
+		{"X-Tenant-ID": "tenant", "X-Tenant": "tenant"},
+	} {
+		_, err = newAttributeMapping(bad, nil)
+		require.Error(t, err, bad)
+	}
+
+	_, err = newAttributeMapping(nil, map[string]string{"tenant": " "})
+	require.Error(t, err)
+}
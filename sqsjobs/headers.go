@@ -0,0 +1,86 @@
+package sqsjobs
+
+import (
+	"strings"
+)
+
+// headerFilter limits the propagated headers: only the allow-listed (all, if the list is empty) and not redacted headers
+// are sent and promoted from the message attributes. The tracing propagation headers are always allowed unless redacted.
+type headerFilter struct {
+	// nil - all headers are allowed
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// newHeaderFilter returns nil if both lists are empty
+func newHeaderFilter(allow, deny, always []string) *headerFilter {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	f := &headerFilter{
+		deny: headerSet(deny),
+	}
+
+	if len(allow) > 0 {
+		f.allow = headerSet(allow)
+		for _, h := range always {
+			f.allow[strings.ToLower(h)] = struct{}{}
+		}
+	}
+
+	return f
+}
+
+// allowed checks the header name, case-insensitive
+func (f *headerFilter) allowed(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := f.deny[name]; ok {
+		return false
+	}
+
+	if f.allow == nil {
+		return true
+	}
+
+	_, ok := f.allow[name]
+	return ok
+}
+
+// filter returns the headers without the filtered ones, the original map is not modified
+func (f *headerFilter) filter(h map[string][]string) map[string][]string {
+	if f == nil || len(h) == 0 {
+		return h
+	}
+
+	ret := make(map[string][]string, len(h))
+	for k, v := range h {
+		if f.allowed(k) {
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+func headerSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" {
+			continue
+		}
+		set[n] = struct{}{}
+	}
+
+	return set
+}
+
+// headerList parses the comma separated list from the pipeline options
+func headerList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
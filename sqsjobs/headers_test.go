@@ -0,0 +1,74 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderFilterSend(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.headers = newHeaderFilter([]string{"X-Tenant", "x-internal-token"}, []string{"X-Internal-Token"}, c.prop.Fields())
+
+	orig := map[string][]string{
+		"X-Tenant":         {"acme"},
+		"X-Internal-Token": {"secret"},
+		"X-Debug":          {"1"},
+		"Traceparent":      {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: orig, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	require.Len(t, fc.sent, 1)
+	h := make(map[string][]string)
+	require.NoError(t, json.Unmarshal(fc.sent[0].MessageAttributes[jobs.RRHeaders].BinaryValue, &h))
+	require.Equal(t, []string{"acme"}, h["X-Tenant"])
+	require.Contains(t, h, "Traceparent")
+	// redacted and not allow-listed
+	require.NotContains(t, h, "X-Internal-Token")
+	require.NotContains(t, h, "X-Debug")
+
+	// the job headers are not modified
+	require.Len(t, orig, 4)
+}
+
+func TestHeaderFilterReceive(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.headers = newHeaderFilter([]string{"x-tenant"}, nil, c.prop.Fields())
+
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId: aws.String("1"),
+		Body:      aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"x-tenant":    {DataType: aws.String(StringType), StringValue: aws.String("acme")},
+			"x-secret":    {DataType: aws.String(StringType), StringValue: aws.String("secret")},
+			"x-signature": {DataType: aws.String(BinaryType), BinaryValue: []byte{0xff}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"x-tenant": {"acme"}}, item.headers)
+}
+
+func TestHeaderFilterLists(t *testing.T) {
+	require.Nil(t, newHeaderFilter(nil, nil, []string{"traceparent"}))
+
+	// deny-list only
+	f := newHeaderFilter(nil, headerList("authorization, cookie"), nil)
+	require.False(t, f.allowed("Authorization"))
+	require.False(t, f.allowed("Cookie"))
+	require.True(t, f.allowed("X-Tenant"))
+
+	// redact wins over the always allowed tracing headers
+	f = newHeaderFilter([]string{"x-tenant"}, []string{"baggage"}, []string{"traceparent", "baggage"})
+	require.True(t, f.allowed("Traceparent"))
+	require.False(t, f.allowed("Baggage"))
+
+	require.Nil(t, headerList(" "))
+}
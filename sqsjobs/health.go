@@ -0,0 +1,86 @@
+package sqsjobs
+
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// health is the last successful and failed receive/send and the health check results, exposed via the Stats RPC
+type health struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastErrorAt time.Time
+	lastError   string
+	// the last throttling or auth error
+	degradedAt     time.Time
+	degradedReason string
+	// the last health check (health_check_interval), the error is empty if succeeded
+	probeAt     time.Time
+	probeError  string
+	credsExpire time.Time
+}
+
+// success records the successful receive/send
+func (c *Driver) success() {
+	c.health.mu.Lock()
+	c.health.lastSuccess = time.Now()
+	c.health.mu.Unlock()
+}
+
+// failure records the failed receive/send, the error is redacted
+func (c *Driver) failure(err error) {
+	msg := redactError(err.Error())
+	c.health.mu.Lock()
+	c.health.lastErrorAt = time.Now()
+	c.health.lastError = msg
+	c.health.mu.Unlock()
+
+	c.observeDegraded(err)
+	c.emitEvent(EventAWSError, "", "", err)
+}
+
+// fillHealth sets the last success/error and the health check fields of the stats, unset timestamps are omitted
+func (c *Driver) fillHealth(st *Stats) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	if !c.health.lastSuccess.IsZero() {
+		st.LastSuccessAt = ptr(c.health.lastSuccess)
+	}
+
+	if !c.health.lastErrorAt.IsZero() {
+		st.LastErrorAt = ptr(c.health.lastErrorAt)
+		st.LastError = c.health.lastError
+	}
+
+	st.HealthState, st.HealthReason = c.health.state()
+
+	if !c.health.probeAt.IsZero() {
+		st.LastHealthCheckAt = ptr(c.health.probeAt)
+	}
+
+	if !c.health.credsExpire.IsZero() {
+		st.CredentialsExpireAt = ptr(c.health.credsExpire)
+	}
+}
+
+var (
+	// accessKeyID matches the long-term (AKIA) and the temporary (ASIA) access key IDs
+	accessKeyID = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+	// secretParam matches the signatures, the security tokens and the receipt handles, the name is kept
+	secretParam = regexp.MustCompile(`(?i)((?:X-Amz-(?:Signature|Credential|Security-Token)|Signature|ReceiptHandle|SessionToken)\s*[=:]\s*)[^&\s",]+`)
+)
+
+// redactError removes the access key IDs, the signatures, the security tokens and the receipt handles from the error text
+// and limits its size
+func redactError(s string) string {
+	s = accessKeyID.ReplaceAllString(s, "[redacted]")
+	s = secretParam.ReplaceAllString(s, "${1}[redacted]")
+
+	if len(s) > maxLastErrorLen {
+		s = s[:maxLastErrorLen]
+	}
+
+	return s
+}
@@ -0,0 +1,169 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// the health states of the pipeline
+const (
+	HealthOK          string = "ok"
+	HealthDegraded    string = "degraded"
+	HealthUnavailable string = "unavailable"
+
+	// degradedWindow is how long the pipeline is degraded after the last throttling or auth error
+	degradedWindow = time.Minute
+	// healthProbeTimeout limits the GetQueueAttributes call of the health check
+	healthProbeTimeout = time.Second * 10
+)
+
+// healthCheckConfig validates the health_check_interval (in seconds), 0 - disabled
+func healthCheckConfig(interval int) (time.Duration, error) {
+	if interval < 0 {
+		return 0, errors.Errorf("health_check_interval should not be negative: %d", interval)
+	}
+
+	return time.Duration(interval) * time.Second, nil
+}
+
+// isAuthError checks whether the error is an authentication/authorization API error (denied, expired or invalid credentials)
+func isAuthError(err error) bool {
+	if isAccessDenied(err) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "ExpiredToken", "ExpiredTokenException", "InvalidClientTokenId", "UnrecognizedClientException", "SignatureDoesNotMatch",
+		"InvalidSecurity", "MissingAuthenticationToken", "AuthFailure":
+		return true
+	default:
+		return false
+	}
+}
+
+// observeDegraded marks the pipeline degraded on the throttling and auth errors
+func (c *Driver) observeDegraded(err error) {
+	var reason string
+	switch {
+	case isThrottled(err):
+		reason = "throttled: "
+	case isAuthError(err):
+		reason = "auth error: "
+	default:
+		return
+	}
+
+	msg := redactError(err.Error())
+	c.health.mu.Lock()
+	c.health.degradedAt = time.Now()
+	c.health.degradedReason = reason + msg
+	c.health.mu.Unlock()
+}
+
+// startHealthCheck probes the queue every health_check_interval until the context is canceled (the pipeline is
+// paused or stopped)
+func (c *Driver) startHealthCheck(ctx context.Context) {
+	if c.healthInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.healthInterval)
+		defer ticker.Stop()
+
+		for {
+			c.probeHealth(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probeHealth verifies the queue is reachable with the current credentials (GetQueueAttributes) and records the
+// credentials expiration time (the STS/role credentials)
+func (c *Driver) probeHealth(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	_, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       c.queueURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	})
+	// the pipeline is paused or stopped during the probe
+	if ctx.Err() != nil && stderr.Is(err, context.Canceled) {
+		return
+	}
+
+	expires, canExpire := c.credentialsExpiry(ctx)
+
+	c.health.mu.Lock()
+	c.health.probeAt = time.Now()
+	c.health.probeError = ""
+	if err != nil {
+		c.health.probeError = redactError(err.Error())
+	}
+	c.health.credsExpire = time.Time{}
+	if canExpire {
+		c.health.credsExpire = expires
+	}
+	c.health.mu.Unlock()
+
+	if err != nil {
+		c.observeDegraded(err)
+		c.log.Warn("health check failed", zap.String("queue", getordefault(c.queueURL)), zap.Error(err))
+	}
+}
+
+// credentialsExpiry returns the expiration time of the client credentials, false if they don't expire (static keys)
+// or can't be retrieved
+func (c *Driver) credentialsExpiry(ctx context.Context) (time.Time, bool) {
+	opts, ok := clientOptions(c.client)
+	if !ok || opts.Credentials == nil {
+		return time.Time{}, false
+	}
+
+	// cached by the credentials cache, refreshed only when expired
+	creds, err := opts.Credentials.Retrieve(ctx)
+	if err != nil || !creds.CanExpire {
+		return time.Time{}, false
+	}
+
+	return creds.Expires, true
+}
+
+// Health returns the health state of the pipeline with the reason: unavailable if the last health check failed,
+// degraded within a minute after the throttling or auth error, ok otherwise
+func (c *Driver) Health() (string, string) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	return c.health.state()
+}
+
+// state requires the health lock
+func (h *health) state() (string, string) {
+	switch {
+	case h.probeError != "":
+		return HealthUnavailable, "health check failed: " + h.probeError
+	case !h.degradedAt.IsZero() && time.Since(h.degradedAt) < degradedWindow:
+		return HealthDegraded, h.degradedReason
+	default:
+		return HealthOK, ""
+	}
+}
@@ -0,0 +1,84 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheckProbe(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	state, _ := c.Health()
+	require.Equal(t, HealthOK, state)
+
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "InvalidClientTokenId", Message: "The security token included in the request is invalid"}
+	}
+	c.probeHealth(context.Background())
+
+	state, reason := c.Health()
+	require.Equal(t, HealthUnavailable, state)
+	require.Contains(t, reason, "InvalidClientTokenId")
+
+	// recovered, still degraded after the auth error
+	fc.getAttrsFn = nil
+	c.probeHealth(context.Background())
+
+	state, reason = c.Health()
+	require.Equal(t, HealthDegraded, state)
+	require.Contains(t, reason, "auth error")
+
+	st := &Stats{}
+	c.fillHealth(st)
+	require.Equal(t, HealthDegraded, st.HealthState)
+	require.NotNil(t, st.LastHealthCheckAt)
+	require.Nil(t, st.CredentialsExpireAt)
+
+	c.health.degradedAt = time.Now().Add(-degradedWindow)
+	state, _ = c.Health()
+	require.Equal(t, HealthOK, state)
+}
+
+func TestHealthDegradedOnThrottling(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	c.failure(&smithy.GenericAPIError{Code: "InvalidParameterValue"})
+	state, _ := c.Health()
+	require.Equal(t, HealthOK, state)
+
+	c.failure(&smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"})
+	state, reason := c.Health()
+	require.Equal(t, HealthDegraded, state)
+	require.Contains(t, reason, "throttled")
+}
+
+func TestHealthCredentialsExpiry(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	c.client = sqs.New(sqs.Options{Region: "us-east-1", Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", CanExpire: true, Expires: expires}, nil
+	})})
+
+	out, ok := c.credentialsExpiry(context.Background())
+	require.True(t, ok)
+	require.Equal(t, expires, out)
+
+	// the static keys don't expire
+	c.client = sqs.New(sqs.Options{Region: "us-east-1", Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"}, nil
+	})})
+
+	_, ok = c.credentialsExpiry(context.Background())
+	require.False(t, ok)
+
+	_, err := healthCheckConfig(-1)
+	require.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// visibilityHeartbeat extends the visibility timeout of the message being processed until it's acknowledged,
+// nacked or requeued (visibility_heartbeat), so the long-running job is not redelivered to another worker.
+// The total extension is bound by the max_visibility_extension.
+type visibilityHeartbeat struct {
+	// held while the visibility is extended, so no extension is sent after the stop
+	mu      sync.Mutex
+	stopped bool
+	done    chan struct{}
+}
+
+// heartbeatConfig validates the visibility_heartbeat interval and the max_visibility_extension (both in seconds)
+func heartbeatConfig(interval, maxExtension int) (time.Duration, time.Duration, error) {
+	if interval < 0 {
+		return 0, 0, errors.Errorf("visibility_heartbeat should not be negative: %d", interval)
+	}
+
+	if maxExtension < 0 || maxExtension > int(maxVisibilityTimeout) {
+		return 0, 0, errors.Errorf("max_visibility_extension should be in the range [0, %d] seconds: %d", maxVisibilityTimeout, maxExtension)
+	}
+
+	if maxExtension == 0 {
+		maxExtension = int(maxVisibilityTimeout)
+	}
+
+	if interval > 0 && interval >= maxExtension {
+		return 0, 0, errors.Errorf("visibility_heartbeat (%d) should be less than the max_visibility_extension (%d)", interval, maxExtension)
+	}
+
+	return time.Duration(interval) * time.Second, time.Duration(maxExtension) * time.Second, nil
+}
+
+// startHeartbeat starts extending the visibility of the received message, nil if the visibility_heartbeat is disabled
+// or the message is already deleted (auto_ack)
+func (c *Driver) startHeartbeat(item *Item) *visibilityHeartbeat {
+	if c.heartbeat <= 0 || item.Options.AutoAck {
+		return nil
+	}
+
+	hb := &visibilityHeartbeat{done: make(chan struct{})}
+	go c.extendVisibility(item, hb, time.Now())
+
+	return hb
+}
+
+// stop stops the heartbeat, waits for the extension in progress (if any)
+func (hb *visibilityHeartbeat) stop() {
+	if hb == nil {
+		return
+	}
+
+	hb.mu.Lock()
+	if !hb.stopped {
+		hb.stopped = true
+		close(hb.done)
+	}
+	hb.mu.Unlock()
+}
+
+// extendVisibility sets the visibility timeout of the message to the effective visibility every visibility_heartbeat,
+// the last extension is shortened to the max_visibility_extension (counted from the receive)
+func (c *Driver) extendVisibility(item *Item, hb *visibilityHeartbeat, received time.Time) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hb.done:
+			return
+		case <-ticker.C:
+		}
+
+		if atomic.LoadUint64(&c.stopped) == 1 {
+			return
+		}
+
+		remaining := c.maxExtension - time.Since(received)
+		if remaining <= 0 {
+			c.log.Warn("max_visibility_extension reached, the visibility timeout is not extended anymore, the message might be redelivered",
+				zap.String("ID", item.ID()), zap.Duration("max_visibility_extension", c.maxExtension))
+			return
+		}
+
+		visibility := min(c.effectiveVisibility(), int32((remaining+time.Second-1)/time.Second))
+		if !c.heartbeatOnce(item, hb, visibility) {
+			return
+		}
+	}
+}
+
+// heartbeatOnce extends the visibility of the message, false if the heartbeat should be stopped
+func (c *Driver) heartbeatOnce(item *Item, hb *visibilityHeartbeat, visibility int32) bool {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	// acknowledged while waiting for the lock
+	if hb.stopped {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client, queue := c.itemQueue(item)
+	_, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queue,
+		ReceiptHandle:     item.Options.receipt.get(),
+		VisibilityTimeout: visibility,
+	})
+	if err != nil {
+		if isNotInflight(err) {
+			c.log.Debug("visibility heartbeat stopped, the message is not in flight anymore (deleted or redelivered)", zap.String("ID", item.ID()))
+			return false
+		}
+
+		// the next heartbeat might still succeed before the visibility timeout expires
+		c.log.Warn("failed to extend the visibility timeout of the message", zap.String("ID", item.ID()), zap.Error(err))
+		return true
+	}
+
+	atomic.AddUint64(&c.extensions, 1)
+	c.log.Debug("visibility timeout extended", zap.String("ID", item.ID()), zap.Int32("visibility_timeout", visibility))
+
+	return true
+}
+
+// logHeartbeat warns if the visibility_heartbeat is not shorter than the visibility timeout, the message is then
+// visible again before the first extension
+func (c *Driver) logHeartbeat() {
+	if c.heartbeat <= 0 {
+		return
+	}
+
+	visibility := c.effectiveVisibility()
+	if c.heartbeat >= time.Duration(visibility)*time.Second {
+		c.log.Warn("visibility_heartbeat is not shorter than the visibility timeout, the messages might be redelivered between the heartbeats",
+			zap.Duration("visibility_heartbeat", c.heartbeat), zap.Int32("visibility_timeout", visibility))
+	}
+}
@@ -0,0 +1,114 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisibilityHeartbeat(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.heartbeat = time.Millisecond * 50
+	c.maxExtension = time.Hour
+	c.visibilityTimeout = 60
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("long")})
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// extended until acknowledged
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") >= 2
+	}, time.Second*5, time.Millisecond*10)
+
+	fc.mu.Lock()
+	require.Equal(t, "receipt-1", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(60), fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	extended := fc.called("ChangeMessageVisibility")
+
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, extended, fc.called("ChangeMessageVisibility"))
+}
+
+func TestVisibilityHeartbeatMaxExtension(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.heartbeat = time.Millisecond * 50
+	c.maxExtension = time.Millisecond * 130
+	c.visibilityTimeout = 60
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("stuck")})
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// the extension is shortened to the rest of the max_visibility_extension, then stopped
+	time.Sleep(time.Millisecond * 300)
+	extended := fc.called("ChangeMessageVisibility")
+	require.Equal(t, 2, extended)
+
+	fc.mu.Lock()
+	require.Equal(t, int32(1), fc.visibility[extended-1].VisibilityTimeout)
+	fc.mu.Unlock()
+
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+}
+
+func TestVisibilityHeartbeatNotInflight(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.heartbeat = time.Millisecond * 50
+	c.maxExtension = time.Hour
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("gone")})
+	fc.visibilityFn = func(*sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+		return nil, &types.MessageNotInflight{}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// deleted or redelivered, not extended anymore
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+}
+
+func TestHeartbeatConfig(t *testing.T) {
+	interval, maxExtension, err := heartbeatConfig(30, 0)
+	require.NoError(t, err)
+	require.Equal(t, time.Second*30, interval)
+	require.Equal(t, time.Hour*12, maxExtension)
+
+	_, _, err = heartbeatConfig(-1, 0)
+	require.Error(t, err)
+
+	_, _, err = heartbeatConfig(30, 43201)
+	require.Error(t, err)
+
+	_, _, err = heartbeatConfig(60, 60)
+	require.Error(t, err)
+}
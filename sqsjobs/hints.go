@@ -0,0 +1,136 @@
+package sqsjobs
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxDelay is the SQS limit for the message delay (in seconds)
+	maxDelay int64 = 900
+	// maxPriority is the upper bound for the priority hint
+	maxPriority int64 = 1 << 31
+)
+
+// hintNames are the message attribute names to read the job hints from, empty - RR attribute
+type hintNames struct {
+	priority string
+	delay    string
+	job      string
+	// the job name of the messages without the job name hint, empty - deduced_by_rr
+	defaultJob string
+	// priority_map, the lowercased hint values
+	priorities map[string]int64
+}
+
+// hints are the job parameters decoded from the message attributes
+type hints struct {
+	job      string
+	delay    int64
+	priority int64
+}
+
+// readHints reads the priority, delay and job name from the message attributes in one pass.
+// The configured attribute names take precedence over the RR ones. Missing or invalid values fall back to the defaults:
+// pipeline priority, no delay and the default_job (or the deduced job name). Numbers are clamped to the valid range.
+// The priority hint is ignored in the receive order mode.
+func (c *Driver) readHints(attrs map[string]types.MessageAttributeValue) hints {
+	h := hints{
+		job:      auto,
+		priority: (*c.pipeline.Load()).Priority(),
+	}
+
+	if c.hints.defaultJob != "" {
+		h.job = c.hints.defaultJob
+	}
+
+	if val, name, ok := hintValue(attrs, c.hints.job, jobs.RRJob); ok {
+		if val == "" {
+			c.log.Debug("empty job name hint, using the default job name", zap.String("attribute", name))
+		} else {
+			h.job = val
+		}
+	}
+
+	if val, name, ok := hintValue(attrs, c.hints.delay, jobs.RRDelay); ok {
+		dl, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			c.log.Debug("failed to unpack the delay, not a number", zap.String("attribute", name), zap.Error(err))
+		} else {
+			h.delay = clamp(dl, 0, maxDelay)
+		}
+	}
+
+	// use_priority_queue: false, the receive order is kept
+	if c.order != nil {
+		return h
+	}
+
+	if val, name, ok := hintValue(attrs, c.hints.priority, jobs.RRPriority); ok {
+		if pr, ok := c.hints.priorities[strings.ToLower(val)]; ok {
+			h.priority = pr
+			return h
+		}
+
+		pr, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			c.log.Debug("failed to unpack the priority; inheriting the pipeline's default priority", zap.String("attribute", name), zap.Error(err))
+		} else {
+			h.priority = clamp(pr, 0, maxPriority)
+		}
+	}
+
+	return h
+}
+
+// parsePriorityMap validates the priority_map values, nil if the map is empty
+func parsePriorityMap(m map[string]string) (map[string]int64, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]int64, len(m))
+	for k, v := range m {
+		pr, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil || pr < 0 || pr > maxPriority {
+			return nil, errors.Errorf("priority_map: the priority of %s should be a number in the range [0, %d], provided: %s", k, maxPriority, v)
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = pr
+	}
+
+	return out, nil
+}
+
+// hintValue returns the trimmed string value of the configured attribute or the RR one
+func hintValue(attrs map[string]types.MessageAttributeValue, names ...string) (string, string, bool) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		attr, ok := attrs[name]
+		if !ok || attr.StringValue == nil {
+			continue
+		}
+
+		return strings.TrimSpace(*attr.StringValue), name, true
+	}
+
+	return "", "", false
+}
+
+func clamp(v, lo, hi int64) int64 {
+	switch {
+	case v < lo:
+		return lo
+	case v > hi:
+		return hi
+	default:
+		return v
+	}
+}
@@ -0,0 +1,152 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func strAttr(v string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(v)}
+}
+
+func numAttr(v string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String(NumberType), StringValue: aws.String(v)}
+}
+
+func TestReadHints(t *testing.T) {
+	c := newTestDriver(&testQueue{}, testPipeline{"name": "test", "priority": int64(7)})
+	c.hints = hintNames{priority: "x-priority", delay: "x-delay", job: "x-job"}
+
+	tests := []struct {
+		name  string
+		attrs map[string]types.MessageAttributeValue
+		want  hints
+	}{
+		{
+			name: "all present",
+			attrs: map[string]types.MessageAttributeValue{
+				"x-priority": numAttr("3"),
+				"x-delay":    numAttr("60"),
+				"x-job":      strAttr("App\\Job"),
+			},
+			want: hints{job: "App\\Job", delay: 60, priority: 3},
+		},
+		{
+			name: "partially present",
+			attrs: map[string]types.MessageAttributeValue{
+				"x-delay": numAttr(" 5 "),
+			},
+			want: hints{job: auto, delay: 5, priority: 7},
+		},
+		{
+			name: "rr attributes fallback",
+			attrs: map[string]types.MessageAttributeValue{
+				jobs.RRPriority: numAttr("2"),
+				jobs.RRJob:      strAttr("rr"),
+			},
+			want: hints{job: "rr", priority: 2},
+		},
+		{
+			name: "custom attributes take precedence",
+			attrs: map[string]types.MessageAttributeValue{
+				jobs.RRPriority: numAttr("2"),
+				"x-priority":    numAttr("4"),
+			},
+			want: hints{job: auto, priority: 4},
+		},
+		{
+			name: "invalid values",
+			attrs: map[string]types.MessageAttributeValue{
+				"x-priority": strAttr("high"),
+				"x-delay":    strAttr("1.5"),
+				"x-job":      strAttr(""),
+			},
+			want: hints{job: auto, priority: 7},
+		},
+		{
+			name: "clamped",
+			attrs: map[string]types.MessageAttributeValue{
+				"x-priority": numAttr("-1"),
+				"x-delay":    numAttr("3600"),
+			},
+			want: hints{job: auto, delay: maxDelay, priority: 0},
+		},
+		{
+			name:  "none",
+			attrs: nil,
+			want:  hints{job: auto, priority: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, c.readHints(tt.attrs))
+		})
+	}
+}
+
+func TestHintsUnpack(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.hints = hintNames{priority: "x-priority"}
+
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:         aws.String("1"),
+		Body:              aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{"x-priority": numAttr("1"), jobs.RRDelay: strAttr("10")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), item.Priority())
+	require.Equal(t, int64(10), item.Options.Delay)
+	require.Equal(t, auto, item.Job)
+}
+
+func TestDefaultJob(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.hints = hintNames{job: "x-job", defaultJob: "external"}
+
+	// the third-party message without the job hint, the attributes are promoted to the headers
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:         aws.String("1"),
+		Body:              aws.String(`{"order":1}`),
+		MessageAttributes: map[string]types.MessageAttributeValue{"source": strAttr("billing")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "external", item.Job)
+	require.Equal(t, []string{"billing"}, item.headers["source"])
+
+	// the hint takes precedence
+	item, err = c.unpack(context.Background(), &types.Message{
+		MessageId:         aws.String("2"),
+		Body:              aws.String("body"),
+		MessageAttributes: map[string]types.MessageAttributeValue{"x-job": strAttr("invoice")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "invoice", item.Job)
+}
+
+func TestPriorityMap(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	priorities, err := parsePriorityMap(map[string]string{"High": "1", "low": "100"})
+	require.NoError(t, err)
+	c.hints = hintNames{priority: "severity", priorities: priorities}
+
+	require.Equal(t, int64(1), c.readHints(map[string]types.MessageAttributeValue{"severity": strAttr("HIGH")}).priority)
+	require.Equal(t, int64(100), c.readHints(map[string]types.MessageAttributeValue{"severity": strAttr(" low ")}).priority)
+	// not in the map, parsed as the number
+	require.Equal(t, int64(5), c.readHints(map[string]types.MessageAttributeValue{"severity": strAttr("5")}).priority)
+	require.Equal(t, int64(10), c.readHints(map[string]types.MessageAttributeValue{"severity": strAttr("medium")}).priority)
+
+	_, err = parsePriorityMap(map[string]string{"high": "urgent"})
+	require.Error(t, err)
+	_, err = parsePriorityMap(map[string]string{"high": "-1"})
+	require.Error(t, err)
+
+	priorities, err = parsePriorityMap(nil)
+	require.NoError(t, err)
+	require.Nil(t, priorities)
+}
@@ -0,0 +1,209 @@
+package sqsjobs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// HTTPClientConfig is the transport of the SDK client, e.g. for the egress proxy with the custom CA.
+// The zero values keep the SDK defaults.
+type HTTPClientConfig struct {
+	// Proxy is the proxy URL, e.g. http://proxy.internal:3128. Empty - the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment (default).
+	// The metadata service (metadata_endpoint) is never proxied.
+	Proxy string `mapstructure:"proxy"`
+	// DialTimeout is the time (in seconds) to establish the TCP connection
+	DialTimeout int `mapstructure:"dial_timeout"`
+	// KeepAlive is the TCP keep-alive period (in seconds) of the connections, negative - disabled
+	KeepAlive int `mapstructure:"keep_alive"`
+	// RequestTimeout is the time (in seconds) of the whole API call, including the long poll: it should be greater
+	// than the wait_time_seconds. 0 - no timeout, the calls are bounded by the context (default).
+	RequestTimeout int `mapstructure:"request_timeout"`
+	// MaxIdleConns and MaxIdleConnsPerHost limit the idle (keep-alive) connections, IdleConnTimeout (in seconds) closes
+	// the idle connections after the timeout
+	MaxIdleConns        int `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+	IdleConnTimeout     int `mapstructure:"idle_conn_timeout"`
+	// CAFile is the PEM file with the root CAs trusted in addition to the system ones, e.g. the TLS-inspecting proxy CA
+	CAFile string `mapstructure:"ca_file"`
+	// InsecureSkipVerify disables the TLS certificate verification, e.g. for LocalStack with the self-signed
+	// certificate. Never use it with AWS.
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+}
+
+// newHTTPClient returns the SDK HTTP client with the http_client settings and the DNS cache, nil if neither is configured
+func newHTTPClient(conf *HTTPClientConfig, dnsTTL int, metadataEndpoint string, log *zap.Logger) (*awshttp.BuildableClient, error) {
+	if *conf == (HTTPClientConfig{}) {
+		return newDNSCachedHTTPClient(dnsTTL, log), nil
+	}
+
+	for name, v := range map[string]int{
+		"dial_timeout": conf.DialTimeout, "request_timeout": conf.RequestTimeout, "max_idle_conns": conf.MaxIdleConns,
+		"max_idle_conns_per_host": conf.MaxIdleConnsPerHost, "idle_conn_timeout": conf.IdleConnTimeout,
+	} {
+		if v < 0 {
+			return nil, errors.Errorf("http_client.%s should not be negative, provided: %d", name, v)
+		}
+	}
+
+	client := awshttp.NewBuildableClient()
+
+	if conf.DialTimeout > 0 || conf.KeepAlive != 0 {
+		client = client.WithDialerOptions(func(d *net.Dialer) {
+			if conf.DialTimeout > 0 {
+				d.Timeout = time.Duration(conf.DialTimeout) * time.Second
+			}
+			if conf.KeepAlive != 0 {
+				d.KeepAlive = time.Duration(conf.KeepAlive) * time.Second
+			}
+		})
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	if conf.Proxy != "" {
+		u, err := url.Parse(conf.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("malformed http_client.proxy: %s", conf.Proxy)
+		}
+		proxy = proxyExceptMetadata(http.ProxyURL(u), metadataEndpoint)
+	}
+
+	var roots *x509.CertPool
+	if conf.CAFile != "" {
+		var err error
+		roots, err = loadRootCAs(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client = client.WithTransportOptions(func(tr *http.Transport) {
+		if proxy != nil {
+			tr.Proxy = proxy
+		}
+		if conf.MaxIdleConns > 0 {
+			tr.MaxIdleConns = conf.MaxIdleConns
+		}
+		if conf.MaxIdleConnsPerHost > 0 {
+			tr.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+		}
+		if conf.IdleConnTimeout > 0 {
+			tr.IdleConnTimeout = time.Duration(conf.IdleConnTimeout) * time.Second
+		}
+		if roots != nil || conf.InsecureSkipVerify {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			if roots != nil {
+				tr.TLSClientConfig.RootCAs = roots
+			}
+			tr.TLSClientConfig.InsecureSkipVerify = conf.InsecureSkipVerify //nolint:gosec
+		}
+	})
+
+	if conf.RequestTimeout > 0 {
+		client = client.WithTimeout(time.Duration(conf.RequestTimeout) * time.Second)
+	}
+
+	if conf.InsecureSkipVerify {
+		log.Warn("TLS certificate verification of the SQS endpoint is disabled (http_client.insecure_skip_verify)")
+	}
+
+	return withDNSCache(client, dnsTTL, log), nil
+}
+
+// proxyExceptMetadata proxies all the requests except the ones to the metadata service, it's reachable only from the instance
+func proxyExceptMetadata(proxy func(*http.Request) (*url.URL, error), metadataEndpoint string) func(*http.Request) (*url.URL, error) {
+	host := ""
+	if u, err := url.Parse(metadataURL(metadataEndpoint, "")); err == nil {
+		host = u.Host
+	}
+
+	return func(r *http.Request) (*url.URL, error) {
+		if r.URL.Host == host {
+			return nil, nil
+		}
+
+		return proxy(r)
+	}
+}
+
+// loadRootCAs appends the PEM certificates of the file to the system roots
+func loadRootCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read the http_client.ca_file: %v", err)
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("http_client.ca_file %s contains no PEM certificates", path)
+	}
+
+	return roots, nil
+}
+
+// checkRequestTimeout validates the http_client.request_timeout against the long polling wait time, the long poll
+// would always time out otherwise
+func checkRequestTimeout(timeout int, wait int32) error {
+	if timeout > 0 && timeout <= int(wait) {
+		return errors.Errorf("http_client.request_timeout (%d) should be greater than the wait_time_seconds (%d)", timeout, wait)
+	}
+
+	return nil
+}
+
+// pipelineHTTPClient reads the http_client block of the pipeline, the set keys override the global ones
+func pipelineHTTPClient(pipe jobs.Pipeline, name string, def HTTPClientConfig) (HTTPClientConfig, error) {
+	raw := make(map[string]string)
+	err := pipe.Map(name, raw)
+	if err != nil {
+		return def, err
+	}
+
+	for key, dst := range map[string]*string{"proxy": &def.Proxy, "ca_file": &def.CAFile} {
+		if v, ok := raw[key]; ok {
+			*dst = v
+		}
+	}
+
+	for key, dst := range map[string]*int{
+		"dial_timeout": &def.DialTimeout, "keep_alive": &def.KeepAlive, "request_timeout": &def.RequestTimeout,
+		"max_idle_conns": &def.MaxIdleConns, "max_idle_conns_per_host": &def.MaxIdleConnsPerHost, "idle_conn_timeout": &def.IdleConnTimeout,
+	} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return def, errors.Errorf("%s.%s should be an integer, provided: %s", name, key, v)
+		}
+		*dst = n
+	}
+
+	if v, ok := raw["insecure_skip_verify"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return def, errors.Errorf("%s.insecure_skip_verify should be a boolean, provided: %s", name, v)
+		}
+		def.InsecureSkipVerify = b
+	}
+
+	return def, nil
+}
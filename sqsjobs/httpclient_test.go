@@ -0,0 +1,97 @@
+package sqsjobs
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHTTPClientTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	get := func(conf HTTPClientConfig) error {
+		hc, err := newHTTPClient(&conf, 0, "", zap.NewNop())
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := hc.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	// the self-signed certificate is rejected by default
+	require.Error(t, get(HTTPClientConfig{RequestTimeout: 5}))
+	require.NoError(t, get(HTTPClientConfig{InsecureSkipVerify: true}))
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+	require.NoError(t, get(HTTPClientConfig{CAFile: ca}))
+
+	// not a PEM file
+	require.NoError(t, os.WriteFile(ca, []byte("garbage"), 0o600))
+	_, err := newHTTPClient(&HTTPClientConfig{CAFile: ca}, 0, "", zap.NewNop())
+	require.Error(t, err)
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	conf := HTTPClientConfig{Proxy: proxy.URL, DialTimeout: 5, MaxIdleConns: 4, MaxIdleConnsPerHost: 2, IdleConnTimeout: 30}
+	hc, err := newHTTPClient(&conf, 30, proxy.URL, zap.NewNop())
+	require.NoError(t, err)
+
+	tr := hc.GetTransport()
+	require.Equal(t, 4, tr.MaxIdleConns)
+	require.Equal(t, 2, tr.MaxIdleConnsPerHost)
+	require.Equal(t, time.Second*30, tr.IdleConnTimeout)
+	require.Equal(t, time.Second*5, hc.GetDialer().Timeout)
+
+	req, err := http.NewRequest(http.MethodGet, "http://sqs.us-east-1.amazonaws.com/", nil)
+	require.NoError(t, err)
+	u, err := tr.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, proxy.URL, u.String())
+
+	// the metadata service is reached directly
+	req, err = http.NewRequest(http.MethodGet, proxy.URL+awsMetaDataPath, nil)
+	require.NoError(t, err)
+	u, err = tr.Proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u)
+
+	_, err = newHTTPClient(&HTTPClientConfig{Proxy: "proxy:3128"}, 0, "", zap.NewNop())
+	require.Error(t, err)
+	_, err = newHTTPClient(&HTTPClientConfig{MaxIdleConns: -1}, 0, "", zap.NewNop())
+	require.Error(t, err)
+
+	// nothing configured, the SDK default client
+	hc, err = newHTTPClient(&HTTPClientConfig{}, 0, "", zap.NewNop())
+	require.NoError(t, err)
+	require.Nil(t, hc)
+
+	require.Error(t, checkRequestTimeout(20, 20))
+	require.NoError(t, checkRequestTimeout(25, 20))
+	require.NoError(t, checkRequestTimeout(0, 20))
+
+	pc, err := pipelineHTTPClient(testPipeline{httpClientOpt: map[string]string{"proxy": proxy.URL, "request_timeout": "30", "insecure_skip_verify": "true"}},
+		httpClientOpt, HTTPClientConfig{MaxIdleConns: 8, RequestTimeout: 10})
+	require.NoError(t, err)
+	require.Equal(t, HTTPClientConfig{Proxy: proxy.URL, RequestTimeout: 30, MaxIdleConns: 8, InsecureSkipVerify: true}, pc)
+	_, err = pipelineHTTPClient(testPipeline{httpClientOpt: map[string]string{"dial_timeout": "5s"}}, httpClientOpt, HTTPClientConfig{})
+	require.Error(t, err)
+}
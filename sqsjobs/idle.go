@@ -0,0 +1,43 @@
+package sqsjobs
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// resetIdle marks the pipeline as active, called on start and every time the messages arrive
+func (c *Driver) resetIdle(now time.Time) {
+	atomic.StoreInt64(&c.lastActive, now.UnixNano())
+	if atomic.CompareAndSwapUint32(&c.idle, 1, 0) {
+		pipe := *c.pipeline.Load()
+		c.log.Info("pipeline is active again", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()))
+	}
+}
+
+// checkIdle emits the idle event once the pipeline has seen no messages for the scale_to_zero_idle duration
+func (c *Driver) checkIdle(now time.Time) {
+	if c.idleAfter == 0 || atomic.LoadUint32(&c.idle) == 1 {
+		return
+	}
+
+	since := time.Unix(0, atomic.LoadInt64(&c.lastActive))
+	if now.Sub(since) < c.idleAfter {
+		return
+	}
+
+	// concurrent pollers, only one emits the event
+	if !atomic.CompareAndSwapUint32(&c.idle, 0, 1) {
+		return
+	}
+
+	pipe := *c.pipeline.Load()
+	c.log.Info("pipeline is idle, might be scaled to zero", zap.String("driver", pipe.Driver()), zap.String("pipeline", pipe.Name()), zap.Time("idle_since", since), zap.Duration("scale_to_zero_idle", c.idleAfter))
+}
+
+// IdleSince returns the time of the last received message (or the pipeline start) and true if
+// the scale_to_zero_idle threshold was crossed since then
+func (c *Driver) IdleSince() (time.Time, bool) {
+	return time.Unix(0, atomic.LoadInt64(&c.lastActive)).UTC(), atomic.LoadUint32(&c.idle) == 1
+}
@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestScaleToZeroIdle(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.log = zap.New(core)
+	c.idleAfter = time.Millisecond * 100
+
+	var deliver int32
+	fc := newFakeClient()
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		if atomic.CompareAndSwapInt32(&deliver, 1, 0) {
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("body")}}}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	start := time.Now()
+	c.resetIdle(start)
+	stop := runListener(c)
+	defer stop()
+
+	// empty receives only
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("pipeline is idle, might be scaled to zero").Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.GreaterOrEqual(t, time.Since(start), c.idleAfter)
+
+	since, idle := c.IdleSince()
+	require.True(t, idle)
+	require.WithinDuration(t, start, since, time.Millisecond)
+
+	// the event is emitted once
+	time.Sleep(time.Millisecond * 50)
+	require.Equal(t, 1, logs.FilterMessage("pipeline is idle, might be scaled to zero").Len())
+
+	// reset on the message arrival
+	atomic.StoreInt32(&deliver, 1)
+	require.Eventually(t, func() bool {
+		_, idle := c.IdleSince()
+		return !idle && pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, 1, logs.FilterMessage("pipeline is active again").Len())
+}
+
+func TestScaleToZeroIdleDisabled(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.resetIdle(time.Now().Add(-time.Hour))
+	c.checkIdle(time.Now())
+
+	_, idle := c.IdleSince()
+	require.False(t, idle)
+}
@@ -0,0 +1,32 @@
+package sqsjobs
+
+import (
+	"sync/atomic"
+)
+
+// bytesFull checks the max_in_flight_bytes limit, should be called under the cond lock
+func (c *Driver) bytesFull() bool {
+	limit := atomic.LoadInt64(&c.maxInFlightBytes)
+	return limit > 0 && c.bytesInFlight != nil && atomic.LoadInt64(c.bytesInFlight) >= limit
+}
+
+// trackBytes adds the payload size of the dispatched message to the in-flight bytes, released on ack/nack/requeue/handler_timeout
+func (c *Driver) trackBytes(item *Item) {
+	if c.bytesInFlight == nil {
+		return
+	}
+
+	item.Options.bytesInFlight = c.bytesInFlight
+	item.Options.size = int64(len(item.Payload))
+	atomic.AddInt64(c.bytesInFlight, item.Options.size)
+}
+
+// releaseBytes subtracts the message payload size from the in-flight bytes, the size is released only once
+func (o *Options) releaseBytes() {
+	if o.bytesInFlight == nil {
+		return
+	}
+
+	atomic.AddInt64(o.bytesInFlight, -o.size)
+	o.bytesInFlight = nil
+}
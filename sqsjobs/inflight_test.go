@@ -0,0 +1,56 @@
+package sqsjobs
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxInFlightBytes(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.bytesInFlight = ptr(int64(0))
+	c.maxInFlightBytes = 2500
+
+	msgs := make([]types.Message, 0, 5)
+	for i := 0; i < 5; i++ {
+		id := strconv.Itoa(i)
+		msgs = append(msgs, types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id), Body: aws.String(strings.Repeat("x", 1000))})
+	}
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(msgs...)
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	// 0, 1000 and 2000 bytes in flight are below the limit, the receives halt at 3000
+	require.Eventually(t, func() bool { return pq.Len() == 3 }, time.Second*5, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, uint64(3), pq.Len())
+	require.Equal(t, int64(3000), atomic.LoadInt64(c.bytesInFlight))
+
+	// ack releases the message bytes, one more message is received
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	require.Eventually(t, func() bool { return pq.Len() == 3 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, int64(3000), atomic.LoadInt64(c.bytesInFlight))
+
+	// nack and requeue release the bytes too, released only once
+	item := pq.ExtractMin().(*Item)
+	require.NoError(t, item.Nack())
+	item.Options.releaseBytes()
+	require.Eventually(t, func() bool { return pq.Len() == 3 }, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, pq.ExtractMin().(*Item).Requeue(nil, 0))
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	require.Eventually(t, func() bool { return atomic.LoadInt64(c.bytesInFlight) == 0 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, uint64(0), pq.Len())
+}
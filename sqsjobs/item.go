@@ -55,6 +55,9 @@ type Options struct {
 	AutoAck bool `json:"auto_ack"`
 	// SQS Queue name
 	Queue string `json:"queue,omitempty"`
+	// PartitionKey is the logical partition key, sent as a message attribute (partition_key header of the job).
+	// Used as the message group for the strict_group_ordering on the standard queues.
+	PartitionKey string `json:"partition_key,omitempty"`
 
 	// Private ================
 	cond               *sync.Cond
@@ -62,9 +65,57 @@ type Options struct {
 	msgInFlight        *int64
 	approxReceiveCount int64
 	queue              *string
-	receiptHandler     *string
-	client             *sqs.Client
+	receipt            *receiptHandle
+	client             sqsClient
 	requeueFn          RequeueFn
+	// retry queue route for the nacked messages, nil if not configured
+	retryFn RequeueFn
+	retries int64
+	// part of the split payload, nil if not split
+	split *splitInfo
+	// FIFO message group, used for the per-group ordering
+	groupID string
+	release func()
+	// inserts the next message of the use_priority_queue: false on the worker pickup, nil otherwise
+	pickup func()
+	// logger with the message correlation ID, nil for the pushed jobs
+	log *zap.Logger
+	// counts the expired receipt handles on the ack
+	expiredOnAck *uint64
+	// nacks the message on the handler_timeout, nil if disabled
+	watchdog *handlerWatchdog
+	// extends the visibility until the ack, nil if disabled
+	heartbeat *visibilityHeartbeat
+	// max_in_flight_bytes accounting, nil for the pushed jobs
+	bytesInFlight *int64
+	size          int64
+	// max_app_retries, the nacks are counted in the retry count attribute
+	countNacks bool
+	// deadline_attribute, zero if absent
+	deadline time.Time
+	// delete_batch, nil if disabled
+	deleteBatch *deleteBatcher
+	// records the acknowledged message in the DedupStore, nil if not registered
+	dedupRecord func()
+	// received from the secondary queue (failover_receive), the client and the queue are the secondary ones
+	fromSecondary bool
+	// reply queue of the RPC request (reply_to_attribute or reply_queue), empty if absent
+	replyTo string
+	// sends the worker response (Respond), nil for the pushed jobs
+	reply func(ctx context.Context, item *Item, body []byte, queue string) error
+	// deletes the offloaded body once the message is deleted, nil if not offloaded
+	dropPayload func()
+	// nack_backoff, nil if disabled
+	nackBackoff *nackBackoff
+	// on_nack policy and the dead-letter queue of the nacked messages, nil if not configured
+	onNack nackPolicy
+	dlqFn  RequeueFn
+	// the receive time and the counters for the stats, nil for the pushed jobs
+	receivedAt time.Time
+	throughput *throughput
+	// the SQS message ID and the pipeline events, nil for the pushed jobs
+	messageID string
+	events    *eventBus
 }
 
 // DelayDuration returns delay duration in the form of time.Duration.
@@ -96,6 +147,12 @@ func (i *Item) Body() []byte {
 // Context packs job context (job, id) into binary payload.
 // Not used in the sqs, MessageAttributes used instead
 func (i *Item) Context() ([]byte, error) {
+	// called on the worker pickup, the handler_timeout starts
+	i.Options.watchdog.start()
+	if i.Options.pickup != nil {
+		i.Options.pickup()
+	}
+
 	ctx, err := json.Marshal(
 		struct {
 			ID       string              `json:"id"`
@@ -104,6 +161,7 @@ func (i *Item) Context() ([]byte, error) {
 			Headers  map[string][]string `json:"headers"`
 			Queue    string              `json:"queue,omitempty"`
 			Pipeline string              `json:"pipeline"`
+			Deadline string              `json:"deadline,omitempty"`
 		}{
 			ID:       i.Ident,
 			Job:      i.Job,
@@ -111,6 +169,7 @@ func (i *Item) Context() ([]byte, error) {
 			Headers:  i.headers,
 			Queue:    i.Options.Queue,
 			Pipeline: i.Options.Pipeline,
+			Deadline: i.Options.deadlineValue(),
 		},
 	)
 
@@ -125,23 +184,42 @@ func (i *Item) Ack() error {
 	if atomic.LoadUint64(i.Options.stopped) == 1 {
 		return errors.Str("failed to acknowledge the JOB, the pipeline is probably stopped")
 	}
+	// the handler_timeout expired, the message was already returned to the queue
+	if !i.Options.watchdog.claim() {
+		return errHandlerTimeout
+	}
+	i.Options.heartbeat.stop()
 	defer func() {
+		i.Options.releaseBytes()
 		i.Options.cond.Signal()
 		atomic.AddInt64(i.Options.msgInFlight, ^int64(0))
+		if i.Options.release != nil {
+			i.Options.release()
+		}
+		// the failed ack leaves the message in the queue, its redelivery is not a duplicate
+		i.Options.receipt.forgetDedup()
 	}()
 	// just return in case of auto-ack
 	if i.Options.AutoAck {
+		if i.Options.dedupRecord != nil {
+			i.Options.dedupRecord()
+		}
+		i.Options.throughput.ack(i.Options.receivedAt)
+		i.emitItemEvent(EventMessageAcked)
 		return nil
 	}
-	_, err := i.Options.client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{
-		QueueUrl:      i.Options.queue,
-		ReceiptHandle: i.Options.receiptHandler,
-	})
-
+	err := i.deleteMessage(context.Background())
 	if err != nil {
 		return err
 	}
 
+	if i.Options.dedupRecord != nil {
+		i.Options.dedupRecord()
+	}
+	i.Options.throughput.ack(i.Options.receivedAt)
+	i.emitItemEvent(EventMessageAcked)
+	i.debug("message acknowledged")
+
 	return nil
 }
 
@@ -149,30 +227,83 @@ func (i *Item) Nack() error {
 	if atomic.LoadUint64(i.Options.stopped) == 1 {
 		return errors.Str("failed to acknowledge the JOB, the pipeline is probably stopped")
 	}
+	// the handler_timeout expired, the message was already returned to the queue
+	if !i.Options.watchdog.claim() {
+		return errHandlerTimeout
+	}
+	i.Options.heartbeat.stop()
 	defer func() {
+		i.Options.releaseBytes()
 		i.Options.cond.Signal()
 		atomic.AddInt64(i.Options.msgInFlight, ^int64(0))
+		if i.Options.release != nil {
+			i.Options.release()
+		}
+		// the failed ack leaves the message in the queue, its redelivery is not a duplicate
+		i.Options.receipt.forgetDedup()
 	}()
+	i.Options.throughput.nack()
 	// message already deleted
 	if i.Options.AutoAck {
+		i.emitItemEvent(EventMessageNacked)
 		return nil
 	}
 
-	// requeue message
-	err := i.Options.requeueFn(context.Background(), i)
-	if err != nil {
+	// on_nack (or the on_nack header) policy
+	policy := i.nackPolicy()
+
+	switch {
+	case policy.mode == nackRelease:
+		// the message stays in the queue, nack_backoff or nack_release_delay
+		var err error
+		if policy.backoff {
+			err = i.backoffNack(context.Background())
+		} else {
+			err = i.releaseNack(context.Background(), policy.delay)
+		}
+		if err == nil {
+			i.emitItemEvent(EventMessageNacked)
+		}
 		return err
+	case policy.mode == nackDLQ:
+		err := i.Options.dlqFn(context.Background(), i)
+		if err != nil {
+			return err
+		}
+
+		err = i.deleteMessage(context.Background())
+		if err != nil {
+			return err
+		}
+
+		i.emitItemEvent(EventMessageDLQ)
+		i.emitItemEvent(EventMessageNacked)
+		i.debug("message negatively acknowledged and moved to the dead-letter queue")
+		return nil
+	}
+
+	// requeue message, to the retry queue if configured
+	requeue := i.Options.requeueFn
+	if i.Options.retryFn != nil {
+		requeue = i.Options.retryFn
+	} else if i.Options.countNacks {
+		// the message is sent again, the receive count starts over
+		i.Options.retries++
 	}
 
-	_, err = i.Options.client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{
-		QueueUrl:      i.Options.queue,
-		ReceiptHandle: i.Options.receiptHandler,
-	})
+	err := requeue(context.Background(), i)
+	if err != nil {
+		return err
+	}
 
+	err = i.deleteMessage(context.Background())
 	if err != nil {
 		return err
 	}
 
+	i.emitItemEvent(EventMessageNacked)
+	i.debug("message negatively acknowledged")
+
 	return nil
 }
 
@@ -180,9 +311,20 @@ func (i *Item) Requeue(headers map[string][]string, delay int64) error {
 	if atomic.LoadUint64(i.Options.stopped) == 1 {
 		return errors.Str("failed to acknowledge the JOB, the pipeline is probably stopped")
 	}
+	// the handler_timeout expired, the message was already returned to the queue
+	if !i.Options.watchdog.claim() {
+		return errHandlerTimeout
+	}
+	i.Options.heartbeat.stop()
 	defer func() {
+		i.Options.releaseBytes()
 		i.Options.cond.Signal()
 		atomic.AddInt64(i.Options.msgInFlight, ^int64(0))
+		if i.Options.release != nil {
+			i.Options.release()
+		}
+		// the failed ack leaves the message in the queue, its redelivery is not a duplicate
+		i.Options.receipt.forgetDedup()
 	}()
 	// overwrite the delay
 	i.Options.Delay = delay
@@ -197,19 +339,28 @@ func (i *Item) Requeue(headers map[string][]string, delay int64) error {
 	// in case of auto_ack a message was already deleted from the queue
 	if !i.Options.AutoAck {
 		// Delete job from the queue only after successful requeue
-		_, err = i.Options.client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{
-			QueueUrl:      i.Options.queue,
-			ReceiptHandle: i.Options.receiptHandler,
-		})
-
+		err = i.deleteMessage(context.Background())
 		if err != nil {
 			return err
 		}
 	}
 
+	i.Options.throughput.requeue()
+	i.emitItemEvent(EventMessageRequeued)
+	i.debug("message requeued", zap.Int64("delay", delay))
+
 	return nil
 }
 
+// debug logs the message lifecycle event with the message logger (if any)
+func (i *Item) debug(msg string, fields ...zap.Field) {
+	if i.Options.log == nil {
+		return
+	}
+
+	i.Options.log.Debug(msg, append(fields, zap.String("ID", i.ID()))...)
+}
+
 func fromJob(job jobs.Message) *Item {
 	return &Item{
 		Job:     job.Name(),
@@ -221,36 +372,61 @@ func fromJob(job jobs.Message) *Item {
 			Pipeline: job.GroupID(),
 			Delay:    job.Delay(),
 			AutoAck:  job.AutoAck(),
+			// jobs API has no custom options
+			PartitionKey: headerValue(job.Headers(), PartitionKeyHeader),
 		},
 	}
 }
 
-func (i *Item) pack(queueURL, origQueue *string, mg string) (*sqs.SendMessageInput, error) {
+func (i *Item) pack(queueURL, origQueue *string, mg string, bundled bool) (*sqs.SendMessageInput, error) {
+	// non UTF-8 header values can't be represented in JSON, they are sent as Binary attributes
+	headers, binary, err := splitBinaryHeaders(i.headers)
+	if err != nil {
+		return nil, err
+	}
+
 	// pack a header map
-	data, err := json.Marshal(i.headers)
+	data, err := json.Marshal(headers)
 	if err != nil {
 		return nil, err
 	}
 
-	return &sqs.SendMessageInput{
+	in := &sqs.SendMessageInput{
 		MessageBody:            aws.String(bytesToStr(i.Payload)),
 		QueueUrl:               queueURL,
 		DelaySeconds:           delay(origQueue, int32(i.Options.Delay)),
 		MessageDeduplicationId: dedup(i.ID(), origQueue),
 		// message group used for the FIFO
 		MessageGroupId: mgr(mg),
-		MessageAttributes: map[string]types.MessageAttributeValue{
-			jobs.RRID:       {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(i.Ident)},
-			jobs.RRJob:      {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(i.Job)},
-			jobs.RRDelay:    {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(strconv.Itoa(int(i.Options.Delay)))},
-			jobs.RRHeaders:  {DataType: aws.String(BinaryType), BinaryValue: data, BinaryListValues: nil, StringListValues: nil, StringValue: nil},
-			jobs.RRPriority: {DataType: aws.String(NumberType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(strconv.Itoa(int(i.Options.Priority)))},
-			jobs.RRAutoAck:  {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(btos(i.Options.AutoAck))},
-		},
-	}, nil
+	}
+
+	if bundled {
+		in.MessageAttributes, err = i.bundledAttributes(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		in.MessageAttributes = i.spreadAttributes(data)
+	}
+
+	for k, v := range binary {
+		if _, ok := in.MessageAttributes[k]; ok {
+			return nil, errors.Errorf("header %s conflicts with the reserved message attribute", k)
+		}
+
+		in.MessageAttributes[k] = types.MessageAttributeValue{DataType: aws.String(BinaryType), BinaryValue: v}
+	}
+
+	// OpenTelemetry baggage for the consumers which don't read the RR headers
+	baggageAttribute(headers, in.MessageAttributes)
+
+	return in, nil
 }
 
-func (c *Driver) unpack(msg *types.Message) *Item {
+func (c *Driver) unpack(ctx context.Context, msg *types.Message) (*Item, error) {
+	// the secondary queue while failed over (failover_receive)
+	client, queueURL, secondary := c.sourceOf(ctx)
+
 	// reserved
 	var recCount int64
 	if _, ok := msg.Attributes[ApproximateReceiveCount]; !ok {
@@ -263,81 +439,182 @@ func (c *Driver) unpack(msg *types.Message) *Item {
 		recCount = int64(tmp)
 	}
 
+	// empty_body_policy: error
+	err := c.emptyBody(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// attributes over the SQS limit
+	attrs, err := expandOverflow(msg.MessageAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	// bundled metadata (metadata_mode: bundled), the raw message is kept intact for the dead-letter queue
+	attrs, err = expandMeta(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// sns_unwrap, the enveloped SNS notification is replaced with the published message
+	raw, attrs := c.unwrapSNS([]byte(getordefault(msg.Body)), attrs)
+
+	// the attributes offloaded with the body (s3_offload_attributes)
+	attrs, dropAttrs, err := c.fetchOffloadedAttributes(ctx, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the body offloaded to the payload store (s3_bucket)
+	raw, dropPayload, err := c.fetchOffloaded(ctx, raw, attrs)
+	if err != nil {
+		return nil, err
+	}
+	dropPayload = dropAll(dropPayload, dropAttrs)
+
 	h := make(map[string][]string)
-	if _, ok := msg.MessageAttributes[jobs.RRHeaders]; ok {
-		err := json.Unmarshal(msg.MessageAttributes[jobs.RRHeaders].BinaryValue, &h)
+	if _, ok := attrs[jobs.RRHeaders]; ok {
+		err := json.Unmarshal(attrs[jobs.RRHeaders].BinaryValue, &h)
 		if err != nil {
 			c.log.Debug("failed to unpack the headers, not a JSON", zap.Error(err))
 		}
+		// headers with non UTF-8 values
+		convBinaryAttr(attrs, h)
 	} else {
 		h = convAttr(msg.Attributes)
 	}
 
-	var dl int
-	var err error
-	if _, ok := msg.MessageAttributes[jobs.RRDelay]; ok {
-		dl, err = strconv.Atoi(*msg.MessageAttributes[jobs.RRDelay].StringValue)
-		if err != nil {
-			c.log.Debug("failed to unpack the delay, not a number", zap.Error(err))
-		}
-	}
-
-	var priority int
-	if _, ok := msg.Attributes[jobs.RRPriority]; ok {
-		priority, err = strconv.Atoi(*msg.MessageAttributes[jobs.RRPriority].StringValue)
-		if err != nil {
-			priority = int((*c.pipeline.Load()).Priority())
-			c.log.Debug("failed to unpack the priority; inheriting the pipeline's default priority", zap.Error(err))
-		}
-	}
+	// priority, delay and job name
+	hn := c.readHints(attrs)
 
 	// for the existing messages, auto_ack field might be absent
 	var autoAck bool
-	if aa, ok := msg.MessageAttributes[jobs.RRAutoAck]; ok {
+	if aa, ok := attrs[jobs.RRAutoAck]; ok {
 		autoAck = stob(aa.StringValue)
 	}
 
-	var rrj string
-	if val, ok := msg.MessageAttributes[jobs.RRJob]; ok {
-		rrj = *val.StringValue
-	} else {
-		rrj = auto
-	}
-
 	var rrid string
-	if val, ok := msg.MessageAttributes[jobs.RRID]; ok {
+	if val, ok := attrs[jobs.RRID]; ok {
 		rrid = *val.StringValue
 	} else {
 		rrid = uuid.NewString()
 		// if we don't have RRID we assume, that we received a third party message
-		convMessageAttr(msg.MessageAttributes, &h)
+		convMessageAttr(attrs, &h)
+	}
+
+	// preserve_attribute_types
+	c.readAttrTypes(attrs, h)
+	// attribute_headers
+	c.attributeHeaders(attrs, h)
+
+	restoreBaggage(attrs, h)
+
+	// only the allowed headers are promoted (propagate_headers/redact_headers)
+	h = c.headers.filter(h)
+
+	partitionKey := c.readPartitionKey(attrs, h)
+	correlationID := c.readCorrelationID(attrs, h)
+	readSplit(attrs, h)
+	c.stampSourceQueue(h)
+
+	deadline, err := c.readDeadline(attrs, h)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.decryptBody(raw, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = c.decodeTransferEncoding(body, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = c.decompressBody(body, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := c.decodeBody(body, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err = c.transformBody(ctx, payload, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.validateBody(ctx, payload, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := c.routePipeline(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	var retryFn RequeueFn
+	if c.retryURL != nil {
+		retryFn = c.retry
+	}
+
+	var dlqFn RequeueFn
+	if c.dlqURL != nil {
+		dlqFn = c.nackToDLQ
 	}
 
 	return &Item{
-		Job:     rrj,
+		Job:     hn.job,
 		Ident:   rrid,
-		Payload: []byte(getordefault(msg.Body)),
+		Payload: payload,
 		headers: h,
 		Options: &Options{
 			AutoAck:  autoAck,
-			Delay:    int64(dl),
-			Priority: int64(priority),
-			Pipeline: (*c.pipeline.Load()).Name(),
+			Delay:    hn.delay,
+			Priority: hn.priority,
+			Pipeline: pipeline,
 			Queue:    getordefault(c.queue),
+			// standard queues
+			PartitionKey: partitionKey,
 
 			// private
 			approxReceiveCount: recCount,
-			client:             c.client,
-			queue:              c.queueURL,
-			receiptHandler:     msg.ReceiptHandle,
+			client:             client,
+			queue:              queueURL,
+			fromSecondary:      secondary,
+			receipt:            c.receipts.track(msg, c.dedupForget(msg)),
 			requeueFn:          c.handleItem,
+			retryFn:            retryFn,
+			retries:            retryCount(attrs),
+			countNacks:         c.maxAppRetries > 0,
+			deadline:           deadline,
+			groupID:            msg.Attributes[MessageGroupIDAttr],
+			log:                withCorrelation(c.log, correlationID),
+			expiredOnAck:       &c.expiredOnAck,
+			deleteBatch:        c.deleteBatch,
+			dedupRecord:        c.dedupRecord(msg),
+			replyTo:            c.readReplyTo(attrs),
+			reply:              c.reply,
+			dropPayload:        dropPayload,
+			nackBackoff:        c.nackBackoff,
+			onNack:             c.onNack,
+			dlqFn:              dlqFn,
+			receivedAt:         time.Now(),
+			throughput:         c.throughput,
+			messageID:          getordefault(msg.MessageId),
+			events:             c.events,
 			// 2.12.1
 			msgInFlight: c.msgInFlight,
 			cond:        &c.cond,
 			// 2023.2
 			stopped: &c.stopped,
 		},
-	}
+	}, nil
 }
 
 func mgr(gr string) *string {
@@ -0,0 +1,130 @@
+package sqsjobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// defaultLatencyBuckets are the histogram upper bounds (in seconds), covering the long poll wait and the queue backlog
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 300}
+
+// Histogram is the snapshot of the latency (in seconds) or the payload size (in bytes) histogram, the Prometheus histogram model:
+// Counts[i] is the cumulative number of the observations <= Buckets[i], Count includes the observations above the last bucket.
+type Histogram struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []uint64  `json:"counts"`
+	Count   uint64    `json:"count"`
+	Sum     float64   `json:"sum"`
+}
+
+// histogram is the concurrent-safe histogram with the fixed buckets
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	// not cumulative, the last one is +Inf
+	counts []uint64
+	sum    float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+}
+
+// observe records the duration, nil-safe (the metrics are disabled)
+func (h *histogram) observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+
+	h.observeValue(d.Seconds())
+}
+
+// observeValue records the value in the units of the buckets, nil-safe
+func (h *histogram) observeValue(v float64) {
+	if h == nil {
+		return
+	}
+
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// snapshot returns the cumulative counts, nil if disabled
+func (h *histogram) snapshot() *Histogram {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := &Histogram{Buckets: h.buckets, Counts: make([]uint64, len(h.buckets)), Sum: h.sum}
+	for i := 0; i < len(h.counts); i++ {
+		out.Count += h.counts[i]
+		if i < len(h.buckets) {
+			out.Counts[i] = out.Count
+		}
+	}
+
+	return out
+}
+
+// latencyMetrics are the poll loop histograms: the ReceiveMessage round trip and the message age on dispatch
+type latencyMetrics struct {
+	receive *histogram
+	age     *histogram
+}
+
+// newLatencyMetrics creates the histograms, nil if disabled. The buckets are sorted, the default ones are used if empty.
+func newLatencyMetrics(enabled bool, buckets []float64) *latencyMetrics {
+	if !enabled {
+		return nil
+	}
+
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &latencyMetrics{receive: newHistogram(buckets), age: newHistogram(buckets)}
+}
+
+// observeReceive records the ReceiveMessage round trip, including the long poll wait
+func (m *latencyMetrics) observeReceive(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.receive.observe(d)
+}
+
+// observeAge records the end-to-end age of the dispatched message (SentTimestamp to the dispatch)
+func (c *Driver) observeAge(m *types.Message) {
+	if c.latency == nil {
+		return
+	}
+
+	// the skew is logged by the max_message_age check if enabled
+	if age, ok := c.sentAge(m, c.maxMessageAge == 0); ok {
+		c.latency.age.observe(age)
+	}
+}
+
+// fillLatency adds the histograms to the stats
+func (c *Driver) fillLatency(st *Stats) {
+	if c.latency == nil {
+		return
+	}
+
+	st.ReceiveLatency = c.latency.receive.snapshot()
+	st.MessageAge = c.latency.age.snapshot()
+}
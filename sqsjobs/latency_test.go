@@ -0,0 +1,82 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyHistograms(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.latency = newLatencyMetrics(true, []float64{1, 0.01, 0.1})
+
+	var calls int64
+	fc := newFakeClient()
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		n := atomic.AddInt64(&calls, 1)
+		// the long poll wait
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 20):
+		}
+
+		out := &sqs.ReceiveMessageOutput{}
+		if n <= 3 {
+			id := strconv.FormatInt(n, 10)
+			out.Messages = []types.Message{{
+				MessageId:     aws.String(id),
+				ReceiptHandle: aws.String("receipt-" + id),
+				Body:          aws.String("body"),
+				Attributes:    map[string]string{SentTimestamp: strconv.FormatInt(time.Now().Add(-time.Millisecond*500).UnixMilli(), 10)},
+			}}
+		}
+		return out, nil
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 3 && c.latency.receive.snapshot().Count >= 5
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	// the SentTimestamp is requested for the message age
+	require.Contains(t, c.receiveAttributes(), types.QueueAttributeName(SentTimestamp))
+
+	st := &Stats{}
+	c.fillLatency(st)
+
+	// the buckets are sorted, all the receives are between 10ms and 100ms (+ the last one, canceled)
+	rl := st.ReceiveLatency
+	require.Equal(t, []float64{0.01, 0.1, 1}, rl.Buckets)
+	require.GreaterOrEqual(t, rl.Count, uint64(5))
+	require.GreaterOrEqual(t, rl.Counts[1]-rl.Counts[0], uint64(5))
+	require.GreaterOrEqual(t, rl.Sum, 0.1)
+
+	// the dispatched messages are ~500ms old
+	age := st.MessageAge
+	require.Equal(t, uint64(3), age.Count)
+	require.Equal(t, []uint64{0, 0, 3}, age.Counts)
+	require.GreaterOrEqual(t, age.Sum, 1.5)
+
+	// exported by the metrics as well
+	m := c.Metrics()
+	require.Equal(t, uint64(3), m.MessageAge.Count)
+	require.NotNil(t, m.ReceiveLatency)
+
+	// disabled
+	st = &Stats{}
+	newTestDriver(pq, nil).fillLatency(st)
+	require.Nil(t, st.ReceiveLatency)
+	require.Nil(t, st.MessageAge)
+	require.Nil(t, newTestDriver(pq, nil).Metrics().PayloadSize)
+}
@@ -0,0 +1,106 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLeaseTTL        = time.Second * 30
+	defaultLeaseRetryDelay = time.Second * 5
+	// the lease call shouldn't block the listener for long
+	leaseTimeout = time.Second * 10
+)
+
+// ErrLeaseHeld is returned by the Locker if the key is locked by another consumer
+var ErrLeaseHeld = stderr.New("lease is held by another consumer")
+
+// Locker coordinates the processing of the messages with the same partition key across the instances,
+// e.g. a DynamoDB lease table. TryLock should not wait for the lease: ErrLeaseHeld is returned if the key is busy.
+// The ttl bounds the lease if the instance dies before the unlock. The returned unlock is called once the message
+// is acknowledged, nacked, requeued or the handler_timeout expires.
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+}
+
+// RegisterLocker enables the leases for the messages with the partition key, nil disables them
+func (c *Driver) RegisterLocker(l Locker) {
+	if l == nil {
+		c.locker.Store(nil)
+		return
+	}
+
+	c.locker.Store(&l)
+}
+
+// acquireLease locks the partition key of the message, returns false if the key is busy (or the lock failed),
+// the message is returned to the queue after the lease_retry_delay then
+func (c *Driver) acquireLease(ctx context.Context, item *Item, m *types.Message) bool {
+	l := c.locker.Load()
+	key := item.Options.PartitionKey
+	if l == nil || key == "" {
+		return true
+	}
+
+	ctxT, cancel := context.WithTimeout(ctx, leaseTimeout)
+	unlock, err := (*l).TryLock(ctxT, key, c.leaseTTL)
+	cancel()
+
+	if err == nil {
+		var once sync.Once
+		item.Options.release = chainRelease(func() { once.Do(unlock) }, item.Options.release)
+		return true
+	}
+
+	log := item.Options.log
+	if stderr.Is(err, ErrLeaseHeld) {
+		log.Debug("partition key is leased by another consumer, message returned to the queue", zap.String("key", key), zap.Stringp("ID", m.MessageId))
+	} else {
+		log.Error("failed to acquire the partition key lease, message returned to the queue", zap.String("key", key), zap.Stringp("ID", m.MessageId), zap.Error(err))
+	}
+
+	ctxV, cancelV := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelV()
+
+	_, errV := item.Options.client.ChangeMessageVisibility(ctxV, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          item.Options.queue,
+		ReceiptHandle:     m.ReceiptHandle,
+		VisibilityTimeout: int32(c.leaseRetryDelay.Seconds()),
+	})
+	if errV != nil && !isNotInflight(errV) {
+		log.Warn("failed to return the leased message to the queue, it will be visible after the visibility timeout", zap.Stringp("ID", m.MessageId), zap.Error(errV))
+	}
+	item.Options.receipt.done()
+
+	return false
+}
+
+// chainRelease calls both release funcs in order, nil funcs are skipped
+func chainRelease(first, second func()) func() {
+	switch {
+	case first == nil:
+		return second
+	case second == nil:
+		return first
+	default:
+		return func() {
+			first()
+			second()
+		}
+	}
+}
+
+// leaseDuration converts the lease (or another TTL) option in seconds, 0 - default
+func leaseDuration(sec int, def time.Duration) time.Duration {
+	if sec <= 0 {
+		return def
+	}
+
+	return time.Duration(sec) * time.Second
+}
@@ -0,0 +1,202 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+// memLocker is an in-memory Locker shared by the "instances"
+type memLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *memLocker) TryLock(_ context.Context, key string, _ time.Duration) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held[key] {
+		return nil, ErrLeaseHeld
+	}
+	l.held[key] = true
+
+	return func() {
+		l.mu.Lock()
+		delete(l.held, key)
+		l.mu.Unlock()
+	}, nil
+}
+
+func keyed(id, key string) types.Message {
+	return types.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("receipt-" + id),
+		Body:          aws.String(id),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			defaultPartitionKeyAttr: {DataType: aws.String(StringType), StringValue: aws.String(key)},
+		},
+	}
+}
+
+func TestLeaseSerializesSameKey(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.leaseRetryDelay = defaultLeaseRetryDelay
+	c.RegisterLocker(&memLocker{held: map[string]bool{}})
+
+	var acked, redelivered atomic.Bool
+	fc := newFakeClient()
+	calls := 0
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		calls++
+		switch {
+		case calls == 1:
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{keyed("a", "order-1"), keyed("b", "order-1"), keyed("c", "order-2")}}, nil
+		// b is visible again after the lease_retry_delay
+		case acked.Load() && !redelivered.Load():
+			redelivered.Store(true)
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{keyed("b", "order-1")}}, nil
+		default:
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	// a and c are dispatched, b is returned to the queue
+	require.Eventually(t, func() bool { return pq.Len() == 2 }, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool { return fc.called("ChangeMessageVisibility") == 1 }, time.Second*5, time.Millisecond*10)
+	fc.mu.Lock()
+	require.Equal(t, "receipt-b", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(5), fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+
+	items := map[string]*Item{}
+	for pq.Len() > 0 {
+		item := pq.ExtractMin().(*Item)
+		items[string(item.Payload)] = item
+	}
+	require.Contains(t, items, "a")
+	require.Contains(t, items, "c")
+
+	// the lease is released on ack, b is dispatched on the redelivery
+	require.NoError(t, items["a"].Ack())
+	acked.Store(true)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, "b", string(pq.ExtractMin().(*Item).Payload))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+}
+
+type failingLocker struct{}
+
+func (failingLocker) TryLock(context.Context, string, time.Duration) (func(), error) {
+	return nil, stderr.New("dynamodb is unavailable")
+}
+
+func TestLeaseErrors(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	item := &Item{Options: &Options{PartitionKey: "k", log: c.log, client: fc, queue: c.queueURL}}
+	m := keyed("a", "k")
+
+	// no locker or no key
+	require.True(t, c.acquireLease(context.Background(), item, m2p(m)))
+	c.RegisterLocker(failingLocker{})
+	require.True(t, c.acquireLease(context.Background(), &Item{Options: &Options{log: c.log}}, m2p(m)))
+
+	// the locker failure returns the message to the queue
+	require.False(t, c.acquireLease(context.Background(), item, m2p(m)))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+
+	c.RegisterLocker(nil)
+	require.True(t, c.acquireLease(context.Background(), item, m2p(m)))
+
+	calls := 0
+	release := chainRelease(func() { calls++ }, func() { calls += 10 })
+	release()
+	require.Equal(t, 11, calls)
+	require.Nil(t, chainRelease(nil, nil))
+}
+
+func m2p(m types.Message) *types.Message {
+	return &m
+}
+
+// slowLocker blocks the lock of the slow key until unblocked
+type slowLocker struct {
+	memLocker
+	locking chan struct{}
+	unblock chan struct{}
+}
+
+func (l *slowLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	if key == "slow" {
+		close(l.locking)
+		<-l.unblock
+	}
+
+	return l.memLocker.TryLock(ctx, key, ttl)
+}
+
+func TestLeaseSlowLocker(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	l := &slowLocker{memLocker: memLocker{held: map[string]bool{}}, locking: make(chan struct{}), unblock: make(chan struct{})}
+	c.RegisterLocker(l)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveEach(keyed("a", "slow"), keyed("b", "fast"))
+	c.client = fc
+
+	// two pollers, the slow lock store doesn't block the other one
+	stop := runListeners(c, 2)
+
+	<-l.locking
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, "b", string(pq.ExtractMin().(*Item).Payload))
+
+	close(l.unblock)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+	require.Equal(t, "a", string(pq.ExtractMin().(*Item).Payload))
+}
+
+func TestLeaseReleasedOnStop(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	l := &memLocker{held: map[string]bool{}}
+	c.RegisterLocker(l)
+	// the prefetch limit is reached
+	c.bytesInFlight = ptr(int64(0))
+	atomic.StoreInt32(c.msgInFlightLimit, 1)
+	atomic.StoreInt64(c.msgInFlight, 1)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(keyed("a", "order-1"))
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.held["order-1"]
+	}, time.Second*5, time.Millisecond*10)
+
+	// the listener is stopped while waiting for the prefetch, the lease is released
+	stop()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	require.Empty(t, l.held)
+}
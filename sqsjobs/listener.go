@@ -5,11 +5,10 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
-	"github.com/aws/smithy-go"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -22,104 +21,367 @@ const (
 
 	// consume all
 	auto string = "deduced_by_rr"
+
+	// maxMessages is the maximum number of messages returned by a single ReceiveMessage call
+	maxMessages int32 = 10
 )
 
 func (c *Driver) listen(ctx context.Context) { //nolint:gocognit
 	go func() {
+		atomic.AddInt32(&c.activePollers, 1)
+		defer atomic.AddInt32(&c.activePollers, -1)
+
+		// OverLimit backoff of this poller, 0 - not limited
+		var limitBackoff time.Duration
+		defer c.resumeInFlightLimit(&limitBackoff)
+		// the receive errors backoff of this poller, 0 - the last receive succeeded
+		var errBackoff time.Duration
+		// the dispatch buffer space for the next receive batch
+		var reserved bool
+		defer func() {
+			if reserved {
+				c.releaseDispatch()
+			}
+		}()
+
 		for {
 			select {
-			case <-c.pauseCh:
+			case <-ctx.Done():
 				c.log.Debug("sqs listener was stopped")
 				return
 			default:
-				message, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-					QueueUrl:              c.queueURL,
-					MaxNumberOfMessages:   10,
-					AttributeNames:        []types.QueueAttributeName{types.QueueAttributeName(ApproximateReceiveCount)},
-					MessageAttributeNames: []string{All},
-					// The new value for the message's visibility timeout (in seconds). Values range: 0
-					// to 43200. Maximum: 12 hours.
-					VisibilityTimeout: c.visibilityTimeout,
-					WaitTimeSeconds:   c.waitTime,
+				// dispatch buffer can't accept the whole batch, wait for the dispatcher to catch up
+				if !reserved && !c.reserveDispatch() {
+					c.log.Debug("dispatch buffer is full, waiting for the messages to be pushed to the priority queue")
+					select {
+					case <-ctx.Done():
+						c.log.Debug("sqs listener was stopped")
+						return
+					case <-time.After(dispatchBackoff):
+					}
+					continue
+				}
+				reserved = true
+
+				// circuit_breaker_threshold consecutive failures, wait for the cooldown
+				if c.breaker.wait(ctx) {
+					c.log.Debug("sqs listener was stopped")
+					return
+				}
+
+				// warm pool is at the target depth, wait for the messages to be dispatched
+				batch := c.receiveBatch()
+				if batch == 0 {
+					select {
+					case <-ctx.Done():
+						c.log.Debug("sqs listener was stopped")
+						return
+					case <-time.After(warmPoolBackoff):
+					}
+					continue
+				}
+
+				// the secondary queue while failed over (failover_receive)
+				client, queueURL, secondary := c.receiveTarget(ctx)
+
+				message, err := netRetry(ctx, c.log, c.netRetries, c.budget, "ReceiveMessage", func() (*sqs.ReceiveMessageOutput, error) {
+					start := time.Now()
+					defer func() { c.latency.observeReceive(time.Since(start)) }()
+
+					return client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+						QueueUrl:              queueURL,
+						MaxNumberOfMessages:   batch,
+						AttributeNames:        c.receiveAttributes(),
+						MessageAttributeNames: c.messageAttributeNames(),
+						// The new value for the message's visibility timeout (in seconds). Values range: 0
+						// to 43200. Maximum: 12 hours.
+						VisibilityTimeout: atomic.LoadInt32(&c.visibilityTimeout),
+						WaitTimeSeconds:   atomic.LoadInt32(&c.waitTime),
+					}, c.withThrottleObserver())
 				})
 
+				if !secondary && ctx.Err() == nil {
+					c.observeReceive(err)
+				}
+
 				if err != nil { //nolint:nestif
-					if oErr, ok := (err).(*smithy.OperationError); ok { //nolint:errorlint
-						if rErr, ok := oErr.Err.(*http.ResponseError); ok { //nolint:errorlint
-							if apiErr, ok := rErr.Err.(*smithy.GenericAPIError); ok { //nolint:errorlint
-								// in case of NonExistentQueue - recreate the queue
-								if apiErr.Code == NonExistentQueue {
-									c.log.Error("receive message", zap.String("error code", apiErr.ErrorCode()), zap.String("message", apiErr.ErrorMessage()), zap.String("error fault", apiErr.ErrorFault().String()))
-									_, err = c.client.CreateQueue(context.Background(), &sqs.CreateQueueInput{QueueName: c.queue, Attributes: c.attributes, Tags: c.tags})
-									if err != nil {
-										c.log.Error("create queue", zap.Error(err))
-									}
-									// To successfully create a new queue, you must provide a
-									// queue name that adheres to the limits related to the queues
-									// (https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/limits-queues.html)
-									// and is unique within the scope of your queues. After you create a queue, you
-									// must wait at least one second after the queue is created to be able to use the <------------
-									// queue. To get the queue URL, use the GetQueueUrl action. GetQueueUrl require
-									time.Sleep(time.Second)
-									continue
-								}
-							}
-						}
+					// listener was stopped, the in-progress receive was canceled
+					if ctx.Err() != nil {
+						c.log.Debug("sqs listener was stopped")
+						return
 					}
 
-					c.log.Error("receive message", zap.Error(err))
-					continue
-				}
+					// in case of NonExistentQueue (QueueDoesNotExist) - recreate the queue, the failover queue is never recreated
+					if !secondary && isNonExistentQueue(err) {
+						c.log.Error("receive message, queue does not exist", zap.Error(err))
+						c.failure(err)
 
-				for i := 0; i < len(message.Messages); i++ {
-					c.cond.L.Lock()
-					// lock when we hit the limit
-					for atomic.LoadInt64(c.msgInFlight) >= int64(atomic.LoadInt32(c.msgInFlightLimit)) {
-						c.log.Debug("prefetch limit was reached, waiting for the jobs to be processed", zap.Int64("current", atomic.LoadInt64(c.msgInFlight)), zap.Int32("limit", atomic.LoadInt32(c.msgInFlightLimit)))
-						c.cond.Wait()
+						err = c.recreateQueue(ctx)
+						if err != nil && ctx.Err() == nil {
+							c.log.Error("failed to recreate the queue", zap.Error(err))
+						}
+						// To successfully create a new queue, you must provide a
+						// queue name that adheres to the limits related to the queues
+						// (https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/limits-queues.html)
+						// and is unique within the scope of your queues. After you create a queue, you
+						// must wait at least one second after the queue is created to be able to use the <------------
+						// queue. To get the queue URL, use the GetQueueUrl action. GetQueueUrl require
+						if sleepCtx(ctx, time.Second) {
+							c.log.Debug("sqs listener was stopped")
+							return
+						}
+						continue
 					}
 
-					m := message.Messages[i]
-					c.log.Debug("receive message", zap.Stringp("ID", m.MessageId))
-					item := c.unpack(&m)
-
-					ctxspan, span := c.tracer.Tracer(tracerName).Start(c.prop.Extract(context.Background(), propagation.HeaderCarrier(item.headers)), "sqs_listener")
-
-					if item.Options.AutoAck {
-						ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
-						_, errD := c.client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
-							QueueUrl:      c.queueURL,
-							ReceiptHandle: m.ReceiptHandle,
-						})
-						if errD != nil {
-							cancel()
-							c.log.Error("message unpack, failed to delete the message from the queue", zap.Error(errD))
-							c.cond.L.Unlock()
-
-							span.RecordError(errD)
-							span.End()
-							continue
+					if isOverLimit(err) {
+						c.failure(err)
+						if c.waitInFlightLimit(ctx, &limitBackoff) {
+							c.log.Debug("sqs listener was stopped")
+							return
 						}
-						cancel()
+						continue
+					}
 
-						c.log.Debug("auto ack is turned on, message acknowledged")
-						span.End()
+					if isThrottled(err) {
+						c.throttled()
 					}
 
-					if item.headers == nil {
-						item.headers = make(map[string][]string, 2)
+					errBackoff = nextBackoff(errBackoff, receiveErrorBackoff, maxReceiveErrorBackoff)
+					d := jitter(errBackoff)
+					c.log.Error("receive message", zap.Duration("backoff", d), zap.Error(err))
+					c.failure(err)
+					c.receiveFailed(err)
+					c.breaker.failure(err)
+					if sleepCtx(ctx, d) {
+						c.log.Debug("sqs listener was stopped")
+						return
 					}
+					continue
+				}
 
-					c.prop.Inject(ctxspan, propagation.HeaderCarrier(item.headers))
+				errBackoff = 0
+				c.breaker.success()
+				c.success()
+				c.receiveSucceeded()
+				c.resumeInFlightLimit(&limitBackoff)
+				c.received()
+				atomic.AddUint64(&c.receivedTotal, uint64(len(message.Messages)))
 
-					c.pq.Insert(item)
-					// increase the current number of messages
-					atomic.AddInt64(c.msgInFlight, 1)
-					c.log.Debug("message pushed to the priority queue", zap.Int64("current", atomic.LoadInt64(c.msgInFlight)), zap.Int32("limit", atomic.LoadInt32(c.msgInFlightLimit)))
-					c.cond.L.Unlock()
-					span.End()
+				if len(message.Messages) == 0 {
+					c.checkIdle(time.Now())
+				} else {
+					c.resetIdle(time.Now())
+				}
+
+				// the receipt handles of the secondary queue are tracked in the context, the warm pool is bypassed
+				if c.warmPool != nil && !secondary {
+					if c.fillWarmPool(ctx, message.Messages) {
+						c.log.Debug("sqs listener was stopped")
+						return
+					}
+					c.releaseDispatch()
+					reserved = false
+					continue
+				}
+
+				msgCtx := withSource(ctx, secondary)
+				for i := 0; i < len(message.Messages); i++ {
+					if c.handleMessage(msgCtx, &message.Messages[i]) {
+						// drain_timeout or pause, the rest of the batch is not dispatched, returned to the queue right away
+						if c.drainTimeout > 0 || c.paused() {
+							c.releaseReceived(msgCtx, message.Messages[i:])
+						}
+						c.log.Debug("sqs listener was stopped")
+						return
+					}
 				}
+				// the batch is in the buffer (or in the priority queue)
+				c.releaseDispatch()
+				reserved = false
 			}
 		}
 	}()
 }
+
+// handleMessage unpacks and dispatches the received message, returns true if the listener was stopped.
+// A panic (e.g. in a custom body decoder) is recovered, the message is returned to the queue and the listener keeps running.
+func (c *Driver) handleMessage(ctx context.Context, m *types.Message) bool { //nolint:gocognit
+	var locked, dispatched bool
+	defer c.recoverMessage(ctx, m, &locked, &dispatched)
+
+	// redelivery of the in-flight message, its ack should use the new receipt handle
+	c.receipts.refresh(m)
+	c.emitEvent(EventMessageReceived, getordefault(m.MessageId), "", nil)
+
+	// the partition key of another shard, left for the instance owning it
+	if c.notOwned(m) {
+		c.releaseNotOwned(ctx, m)
+		return false
+	}
+
+	// scheduled by an external system, hold the message until the execution time
+	if at, ok := c.executeAt(m); ok && time.Until(at) > 0 {
+		c.holdUntil(ctx, m, at)
+		return false
+	}
+
+	// time-sensitive messages, drop them before they reach the workers
+	if age, ok := c.expired(m); ok {
+		c.dropExpired(ctx, m, age)
+		return false
+	}
+
+	// the same message ID within the dedup window, don't dispatch it twice
+	if c.isDuplicate(m) {
+		c.dropDuplicate(ctx, m)
+		return false
+	}
+	// not dispatched (dropped, returned or left in the queue), the redelivery must not be suppressed as a duplicate.
+	// The dispatched messages are forgotten on the ack, nack or the return to the queue.
+	defer func() {
+		if !dispatched {
+			if forget := c.dedupForget(m); forget != nil {
+				forget()
+			}
+		}
+	}()
+
+	// processed by this or another consumer (RegisterDedupStore), the redelivery is deleted
+	if c.processedBefore(m) {
+		c.dropProcessed(ctx, m)
+		return false
+	}
+
+	// empty_body_policy: drop
+	if c.dropEmpty(ctx, m) {
+		return false
+	}
+
+	// rate_limit, the listener was stopped while waiting
+	if c.limiter.wait(ctx) != nil {
+		return true
+	}
+
+	log := c.messageLog(m)
+	log.Debug("receive message", zap.Stringp("ID", m.MessageId))
+	// unpacked before the prefetch wait, the payload store fetch (s3_bucket) doesn't hold the lock
+	item, err := c.unpack(ctx, m)
+	if err != nil {
+		log.Error("failed to unpack the message", zap.Stringp("ID", m.MessageId), zap.Error(err))
+		// BodyValidator, invalid_body_policy
+		if isInvalidBody(err) {
+			c.rejectInvalid(ctx, m, err)
+			return false
+		}
+		// poison message, move it to the dead-letter queue if configured
+		// otherwise leave the message in the queue, it will be visible again after the visibility timeout
+		if c.dlqURL != nil {
+			errD := c.moveToDLQ(ctx, m, err)
+			if errD != nil {
+				log.Error("failed to move the message to the dead-letter queue", zap.Stringp("ID", m.MessageId), zap.Error(errD))
+			}
+		}
+		return false
+	}
+
+	log = item.Options.log
+
+	// max_app_retries exceeded, the message is moved to the dead-letter queue (a slow one doesn't block the other pollers)
+	if c.retriesExhausted(ctx, m, item) {
+		return false
+	}
+
+	// deadline_attribute, the message is too late to be processed
+	if c.deadlinePassed(m, item) {
+		return false
+	}
+
+	// the partition key is processed by another consumer (RegisterLocker), the message is returned to the queue.
+	// The lock store is called before the prefetch wait, a slow one doesn't block the other pollers.
+	if !c.acquireLease(ctx, item, m) {
+		return false
+	}
+
+	// the registered enrichment stage (the external calls) runs before the prefetch wait, the failed message is
+	// returned to the queue with the backoff
+	item, ok := c.enrich(ctx, item, m)
+	if !ok {
+		return false
+	}
+
+	c.cond.L.Lock()
+	locked = true
+	// lock when we hit the limit
+	for atomic.LoadInt64(c.msgInFlight) >= int64(atomic.LoadInt32(c.msgInFlightLimit)) || c.bytesFull() {
+		c.log.Debug("prefetch limit was reached, waiting for the jobs to be processed", zap.Int64("current", atomic.LoadInt64(c.msgInFlight)), zap.Int32("limit", atomic.LoadInt32(c.msgInFlightLimit)),
+			zap.Int64("bytes", atomic.LoadInt64(c.bytesInFlight)), zap.Int64("bytes limit", atomic.LoadInt64(&c.maxInFlightBytes)))
+		c.cond.Wait()
+		// listener was stopped while waiting, received messages will be visible again after the visibility timeout
+		if ctx.Err() != nil {
+			c.cond.L.Unlock()
+			locked = false
+			// the lease (if any) is not needed until the redelivery
+			if item.Options.release != nil {
+				item.Options.release()
+			}
+			item.Options.receipt.done()
+			return true
+		}
+	}
+
+	// delivery: at_most_once, the same way as auto_ack - the message is deleted before the dispatch
+	if c.atMostOnce {
+		item.Options.AutoAck = true
+	}
+
+	// the consumer span is the child of the producer one (the propagated trace context)
+	ctxspan, span := c.tracer.Tracer(tracerName).Start(c.prop.Extract(context.Background(), propagation.HeaderCarrier(item.headers)), "sqs_listener",
+		trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(c.receiveSpanAttributes(m, item.Options.approxReceiveCount)...))
+
+	if item.Options.AutoAck {
+		ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+		_, errD := item.Options.client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{
+			QueueUrl:      item.Options.queue,
+			ReceiptHandle: m.ReceiptHandle,
+		})
+		item.Options.receipt.done()
+		if errD != nil {
+			cancel()
+			log.Error("message unpack, failed to delete the message from the queue", zap.Error(errD))
+			c.cond.L.Unlock()
+			locked = false
+
+			span.RecordError(errD)
+			span.End()
+			return false
+		}
+		cancel()
+		if item.Options.dropPayload != nil {
+			item.Options.dropPayload()
+		}
+
+		log.Debug("auto ack is turned on, message acknowledged")
+		span.End()
+	}
+
+	if item.headers == nil {
+		item.headers = make(map[string][]string, 2)
+	}
+
+	c.prop.Inject(ctxspan, propagation.HeaderCarrier(item.headers))
+	item.Options.watchdog = c.watchHandler(item)
+	item.Options.heartbeat = c.startHeartbeat(item)
+	c.trackBytes(item)
+
+	c.observeAge(m)
+	c.dispatch(item)
+	dispatched = true
+	// increase the current number of messages
+	atomic.AddInt64(c.msgInFlight, 1)
+	log.Debug("message pushed to the priority queue", zap.Int64("current", atomic.LoadInt64(c.msgInFlight)), zap.Int32("limit", atomic.LoadInt32(c.msgInFlightLimit)))
+	c.cond.L.Unlock()
+	locked = false
+	span.End()
+
+	return false
+}
@@ -0,0 +1,696 @@
+package sqsjobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// memoryEndpoint is the endpoint of the in_memory queue URLs
+	memoryEndpoint string = "http://sqs.memory/000000000000/"
+	// the receive is checked for the new messages with this interval while waiting (WaitTimeSeconds)
+	memoryPollInterval = time.Millisecond * 10
+	// the FIFO deduplication interval
+	memoryDedupWindow = time.Minute * 5
+	// the default VisibilityTimeout of the queues
+	memoryVisibilityTimeout int32 = 30
+)
+
+// memoryQueues are the in_memory queues of the process, shared by the pipelines
+var memoryQueues = newMemoryBroker()
+
+// memoryBroker is the in-memory SQS: the queues, the visibility timeouts, the delays, the FIFO message groups
+// and the deduplication. The message and the system attribute selection of the receive is not applied, all of them
+// are returned.
+type memoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+type memoryQueue struct {
+	name     string
+	url      string
+	fifo     bool
+	created  time.Time
+	attrs    map[string]string
+	tags     map[string]string
+	messages []*memoryMessage
+	// FIFO, the deduplication ID and the ID of the sent message
+	dedup map[string]memoryDedup
+}
+
+type memoryDedup struct {
+	id string
+	at time.Time
+}
+
+type memoryMessage struct {
+	id        string
+	body      string
+	attrs     map[string]types.MessageAttributeValue
+	group     string
+	dedupID   string
+	sent      time.Time
+	received  time.Time
+	visibleAt time.Time
+	receives  int
+	receipt   string
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// queue returns the queue by its URL, the lock should be held
+func (b *memoryBroker) queue(url *string) (*memoryQueue, error) {
+	u := getordefault(url)
+	q, ok := b.queues[u[strings.LastIndexByte(u, '/')+1:]]
+	if !ok || q.url != u {
+		return nil, &types.QueueDoesNotExist{Message: aws.String("The specified queue does not exist: " + u)}
+	}
+
+	return q, nil
+}
+
+// inFlight returns true if the message was received and its visibility timeout didn't expire
+func (m *memoryMessage) inFlight(now time.Time) bool {
+	return m.receives > 0 && m.visibleAt.After(now)
+}
+
+// Chaos is the fault injection of the in_memory queues, to exercise the retry and the backoff paths
+type Chaos struct {
+	// Latency is the time (in milliseconds) added to every call.
+	Latency int `mapstructure:"latency"`
+	// ThrottleRate is the share (0 to 1) of the calls failed with the ThrottlingException.
+	ThrottleRate float64 `mapstructure:"throttle_rate"`
+	// DuplicateRate is the share (0 to 1) of the received messages left visible, so they are delivered again
+	// (the SQS at-least-once delivery).
+	DuplicateRate float64 `mapstructure:"duplicate_rate"`
+}
+
+// memoryClient is the sqsClient of the in_memory queues
+type memoryClient struct {
+	broker *memoryBroker
+	chaos  Chaos
+}
+
+// newMemoryClient validates the chaos options
+func newMemoryClient(broker *memoryBroker, chaos Chaos) (*memoryClient, error) {
+	if chaos.Latency < 0 {
+		return nil, errors.Errorf("chaos.latency should not be negative, provided: %d", chaos.Latency)
+	}
+
+	if chaos.ThrottleRate < 0 || chaos.ThrottleRate > 1 || chaos.DuplicateRate < 0 || chaos.DuplicateRate > 1 {
+		return nil, errors.Errorf("chaos.throttle_rate and chaos.duplicate_rate should be in the range 0-1, provided: %v, %v", chaos.ThrottleRate, chaos.DuplicateRate)
+	}
+
+	return &memoryClient{broker: broker, chaos: chaos}, nil
+}
+
+// pipelineChaos reads the chaos options of the pipeline, the global ones are the defaults
+func pipelineChaos(pipe jobs.Pipeline, name string, def Chaos) (Chaos, error) {
+	raw := make(map[string]string)
+	err := pipe.Map(name, raw)
+	if err != nil {
+		return def, err
+	}
+
+	if v, ok := raw["latency"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return def, errors.Errorf("%s.latency should be an integer, provided: %s", name, v)
+		}
+		def.Latency = n
+	}
+
+	for key, dst := range map[string]*float64{"throttle_rate": &def.ThrottleRate, "duplicate_rate": &def.DuplicateRate} {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return def, errors.Errorf("%s.%s should be a number, provided: %s", name, key, v)
+		}
+		*dst = f
+	}
+
+	return def, nil
+}
+
+// checkChaos rejects the chaos options without the in_memory queues
+func checkChaos(inMemory bool, chaos Chaos) error {
+	if !inMemory && chaos != (Chaos{}) {
+		return errors.Str("chaos requires the in_memory queues")
+	}
+
+	return nil
+}
+
+// inject applies the latency and the throttling of the call
+func (c *memoryClient) inject(ctx context.Context) error {
+	if c.chaos.Latency > 0 && sleepCtx(ctx, time.Duration(c.chaos.Latency)*time.Millisecond) {
+		return ctx.Err()
+	}
+
+	if c.chaos.ThrottleRate > 0 && rand.Float64() < c.chaos.ThrottleRate { //nolint:gosec
+		return &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded (chaos)"}
+	}
+
+	return nil
+}
+
+func (c *memoryClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.send(params.MessageBody, params.MessageAttributes, params.DelaySeconds, params.MessageGroupId, params.MessageDeduplicationId)
+}
+
+func (c *memoryClient) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &sqs.SendMessageBatchOutput{}
+	for _, e := range params.Entries {
+		res, err := q.send(e.MessageBody, e.MessageAttributes, e.DelaySeconds, e.MessageGroupId, e.MessageDeduplicationId)
+		if err != nil {
+			out.Failed = append(out.Failed, batchError(e.Id, err))
+			continue
+		}
+
+		out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{
+			Id:                     e.Id,
+			MessageId:              res.MessageId,
+			MD5OfMessageBody:       res.MD5OfMessageBody,
+			MD5OfMessageAttributes: res.MD5OfMessageAttributes,
+			SequenceNumber:         res.SequenceNumber,
+		})
+	}
+
+	return out, nil
+}
+
+// send adds the message to the queue, the lock should be held
+func (q *memoryQueue) send(body *string, attrs map[string]types.MessageAttributeValue, delaySeconds int32, group, dedupID *string) (*sqs.SendMessageOutput, error) {
+	now := time.Now()
+
+	m := &memoryMessage{
+		id:        uuid.NewString(),
+		body:      getordefault(body),
+		attrs:     maps.Clone(attrs),
+		sent:      now,
+		visibleAt: now,
+	}
+
+	if q.fifo {
+		if group == nil {
+			return nil, &smithy.GenericAPIError{Code: "MissingParameter", Message: "The request must contain the parameter MessageGroupId"}
+		}
+		m.group = *group
+
+		switch {
+		case dedupID != nil:
+			m.dedupID = *dedupID
+		case q.attrs[string(types.QueueAttributeNameContentBasedDeduplication)] == "true":
+			sum := sha256.Sum256([]byte(m.body))
+			m.dedupID = hex.EncodeToString(sum[:])
+		default:
+			return nil, &smithy.GenericAPIError{Code: "InvalidParameterValue", Message: "The queue should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly"}
+		}
+
+		for id, d := range q.dedup {
+			if now.Sub(d.at) >= memoryDedupWindow {
+				delete(q.dedup, id)
+			}
+		}
+
+		// the duplicate is accepted, but not added to the queue
+		if d, ok := q.dedup[m.dedupID]; ok {
+			m.id = d.id
+			return m.sendOutput(q.fifo), nil
+		}
+		q.dedup[m.dedupID] = memoryDedup{id: m.id, at: now}
+	} else {
+		if delaySeconds == 0 {
+			n, _ := strconv.Atoi(q.attrs[string(types.QueueAttributeNameDelaySeconds)])
+			delaySeconds = int32(n) //nolint:gosec
+		}
+		m.visibleAt = now.Add(time.Duration(delaySeconds) * time.Second)
+	}
+
+	q.messages = append(q.messages, m)
+
+	return m.sendOutput(q.fifo), nil
+}
+
+// sendOutput returns the result of the send with the checksums of the message
+func (m *memoryMessage) sendOutput(fifo bool) *sqs.SendMessageOutput {
+	out := &sqs.SendMessageOutput{MessageId: aws.String(m.id), MD5OfMessageBody: aws.String(md5OfBody(m.body))}
+	if len(m.attrs) > 0 {
+		out.MD5OfMessageAttributes = aws.String(md5OfAttributes(m.attrs))
+	}
+	if fifo {
+		out.SequenceNumber = aws.String(strconv.FormatInt(m.sent.UnixNano(), 10))
+	}
+
+	return out
+}
+
+func (c *memoryClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(time.Duration(params.WaitTimeSeconds) * time.Second)
+	for {
+		out, err := c.receive(params)
+		if err != nil || len(out.Messages) > 0 || !time.Now().Before(deadline) {
+			return out, err
+		}
+
+		if sleepCtx(ctx, memoryPollInterval) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// receive takes the visible messages, the messages of the FIFO group are received in order, one batch of the group at a time
+func (c *memoryClient) receive(params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	limit := max(int(params.MaxNumberOfMessages), 1)
+
+	visibility := params.VisibilityTimeout
+	if visibility == 0 {
+		visibility = memoryVisibilityTimeout
+		if n, err := strconv.Atoi(q.attrs[string(types.QueueAttributeNameVisibilityTimeout)]); err == nil {
+			visibility = int32(n) //nolint:gosec
+		}
+	}
+
+	// the FIFO groups with a message in flight
+	blocked := make(map[string]bool)
+	if q.fifo {
+		for _, m := range q.messages {
+			if m.inFlight(now) {
+				blocked[m.group] = true
+			}
+		}
+	}
+
+	out := &sqs.ReceiveMessageOutput{}
+	taken := make(map[string]bool)
+	for _, m := range q.messages {
+		if len(out.Messages) == limit {
+			break
+		}
+
+		if m.visibleAt.After(now) || blocked[m.group] {
+			continue
+		}
+
+		// the FIFO batch has the messages of a single group
+		if q.fifo && len(taken) > 0 && !taken[m.group] {
+			continue
+		}
+		taken[m.group] = true
+
+		m.receives++
+		if m.received.IsZero() {
+			m.received = now
+		}
+		m.receipt = uuid.NewString()
+		// chaos: the message stays visible and is delivered again
+		if c.chaos.DuplicateRate == 0 || rand.Float64() >= c.chaos.DuplicateRate { //nolint:gosec
+			m.visibleAt = now.Add(time.Duration(visibility) * time.Second)
+		}
+
+		out.Messages = append(out.Messages, q.message(m))
+	}
+
+	return out, nil
+}
+
+// message returns the received message with the system attributes
+func (q *memoryQueue) message(m *memoryMessage) types.Message {
+	msg := types.Message{
+		MessageId:         aws.String(m.id),
+		ReceiptHandle:     aws.String(m.receipt),
+		Body:              aws.String(m.body),
+		MD5OfBody:         aws.String(md5OfBody(m.body)),
+		MessageAttributes: maps.Clone(m.attrs),
+		Attributes: map[string]string{
+			SentTimestamp:                    strconv.FormatInt(m.sent.UnixMilli(), 10),
+			ApproximateReceiveCount:          strconv.Itoa(m.receives),
+			ApproximateFirstReceiveTimestamp: strconv.FormatInt(m.received.UnixMilli(), 10),
+			"SenderId":                       "000000000000",
+		},
+	}
+
+	if len(m.attrs) > 0 {
+		msg.MD5OfMessageAttributes = aws.String(md5OfAttributes(m.attrs))
+	}
+
+	if q.fifo {
+		msg.Attributes[MessageGroupIDAttr] = m.group
+		msg.Attributes["MessageDeduplicationId"] = m.dedupID
+	}
+
+	return msg
+}
+
+// byReceipt returns the message by its latest receipt handle, the lock should be held
+func (q *memoryQueue) byReceipt(receipt *string) (int, error) {
+	for i, m := range q.messages {
+		if m.receipt != "" && m.receipt == getordefault(receipt) {
+			return i, nil
+		}
+	}
+
+	return -1, &types.ReceiptHandleIsInvalid{Message: aws.String("The receipt handle is not valid: " + getordefault(receipt))}
+}
+
+func (c *memoryClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := q.byReceipt(params.ReceiptHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !q.messages[i].inFlight(now) {
+		return nil, &types.MessageNotInflight{Message: aws.String("The message referred to is not in flight")}
+	}
+
+	q.messages[i].visibleAt = now.Add(time.Duration(params.VisibilityTimeout) * time.Second)
+
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (c *memoryClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.delete(params.ReceiptHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *memoryClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, e := range params.Entries {
+		err = q.delete(e.ReceiptHandle)
+		if err != nil {
+			out.Failed = append(out.Failed, batchError(e.Id, err))
+			continue
+		}
+
+		out.Successful = append(out.Successful, types.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+
+	return out, nil
+}
+
+// delete removes the message by its latest receipt handle, the lock should be held
+func (q *memoryQueue) delete(receipt *string) error {
+	i, err := q.byReceipt(receipt)
+	if err != nil {
+		return err
+	}
+
+	q.messages = append(q.messages[:i], q.messages[i+1:]...)
+	return nil
+}
+
+// batchError returns the failed entry of the batch call
+func batchError(id *string, err error) types.BatchResultErrorEntry {
+	code := "InternalError"
+	if apiErr, ok := err.(smithy.APIError); ok { //nolint:errorlint
+		code = apiErr.ErrorCode()
+	}
+
+	return types.BatchResultErrorEntry{Id: id, Code: aws.String(code), Message: aws.String(err.Error()), SenderFault: true}
+}
+
+func (c *memoryClient) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, _ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	name := getordefault(params.QueueName)
+	if name == "" {
+		return nil, &smithy.GenericAPIError{Code: "MissingParameter", Message: "The request must contain the parameter QueueName"}
+	}
+
+	// idempotent, the attributes of the existing queue are kept
+	if q, ok := c.broker.queues[name]; ok {
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String(q.url)}, nil
+	}
+
+	q := &memoryQueue{
+		name:    name,
+		url:     memoryEndpoint + name,
+		fifo:    strings.HasSuffix(name, fifoSuffix),
+		created: time.Now(),
+		attrs:   maps.Clone(params.Attributes),
+		tags:    maps.Clone(params.Tags),
+		dedup:   make(map[string]memoryDedup),
+	}
+	if q.attrs == nil {
+		q.attrs = make(map[string]string)
+	}
+	if q.tags == nil {
+		q.tags = make(map[string]string)
+	}
+	c.broker.queues[name] = q
+
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(q.url)}, nil
+}
+
+func (c *memoryClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, _ ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) { //nolint:revive,stylecheck
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, ok := c.broker.queues[getordefault(params.QueueName)]
+	if !ok {
+		return nil, &types.QueueDoesNotExist{Message: aws.String("The specified queue does not exist: " + getordefault(params.QueueName))}
+	}
+
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(q.url)}, nil
+}
+
+func (c *memoryClient) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, _ ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(c.broker.queues, q.name)
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+func (c *memoryClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var visible, inFlight, delayed int
+	for _, m := range q.messages {
+		switch {
+		case m.inFlight(now):
+			inFlight++
+		case m.visibleAt.After(now):
+			delayed++
+		default:
+			visible++
+		}
+	}
+
+	attrs := maps.Clone(q.attrs)
+	attrs[QueueArnAWS] = "arn:aws:sqs:memory:000000000000:" + q.name
+	attrs[string(types.QueueAttributeNameCreatedTimestamp)] = strconv.FormatInt(q.created.Unix(), 10)
+	attrs[string(types.QueueAttributeNameApproximateNumberOfMessages)] = strconv.Itoa(visible)
+	attrs[string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)] = strconv.Itoa(inFlight)
+	attrs[string(types.QueueAttributeNameApproximateNumberOfMessagesDelayed)] = strconv.Itoa(delayed)
+	if q.fifo {
+		attrs[string(types.QueueAttributeNameFifoQueue)] = "true"
+	}
+
+	for _, name := range params.AttributeNames {
+		if name == types.QueueAttributeNameAll {
+			return &sqs.GetQueueAttributesOutput{Attributes: attrs}, nil
+		}
+	}
+
+	out := &sqs.GetQueueAttributesOutput{Attributes: make(map[string]string, len(params.AttributeNames))}
+	for _, name := range params.AttributeNames {
+		if v, ok := attrs[string(name)]; ok {
+			out.Attributes[string(name)] = v
+		}
+	}
+
+	return out, nil
+}
+
+func (c *memoryClient) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(q.attrs, params.Attributes)
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (c *memoryClient) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, _ ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqs.ListQueueTagsOutput{Tags: maps.Clone(q.tags)}, nil
+}
+
+func (c *memoryClient) TagQueue(ctx context.Context, params *sqs.TagQueueInput, _ ...func(*sqs.Options)) (*sqs.TagQueueOutput, error) {
+	err := c.inject(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	q, err := c.broker.queue(params.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(q.tags, params.Tags)
+	return &sqs.TagQueueOutput{}, nil
+}
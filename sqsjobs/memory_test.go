@@ -0,0 +1,193 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryQueue(t *testing.T, name string) (*memoryClient, *string) {
+	mc, err := newMemoryClient(newMemoryBroker(), Chaos{})
+	require.NoError(t, err)
+
+	out, err := mc.CreateQueue(context.Background(), &sqs.CreateQueueInput{QueueName: aws.String(name)})
+	require.NoError(t, err)
+
+	return mc, out.QueueUrl
+}
+
+func TestMemoryClient(t *testing.T) {
+	ctx := context.Background()
+	mc, url := newMemoryQueue(t, "test")
+
+	_, err := mc.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String("missing")})
+	require.True(t, isNonExistentQueue(err))
+
+	sent, err := mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		"a": {DataType: aws.String(StringType), StringValue: aws.String("1")},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, md5OfBody("body"), aws.ToString(sent.MD5OfMessageBody))
+
+	out, err := mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, MaxNumberOfMessages: 10, VisibilityTimeout: 30})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 1)
+	m := out.Messages[0]
+	require.Equal(t, aws.ToString(sent.MessageId), aws.ToString(m.MessageId))
+	require.Equal(t, "1", m.Attributes[ApproximateReceiveCount])
+	require.Equal(t, "1", aws.ToString(m.MessageAttributes["a"].StringValue))
+
+	// in flight
+	out, err = mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url})
+	require.NoError(t, err)
+	require.Empty(t, out.Messages)
+
+	attrs, err := mc.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: url, AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessagesNotVisible}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ApproximateNumberOfMessagesNotVisible": "1"}, attrs.Attributes)
+
+	// released, received again with a new receipt handle
+	_, err = mc.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{QueueUrl: url, ReceiptHandle: m.ReceiptHandle, VisibilityTimeout: 0})
+	require.NoError(t, err)
+	_, err = mc.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{QueueUrl: url, ReceiptHandle: m.ReceiptHandle, VisibilityTimeout: 0})
+	require.True(t, isNotInflight(err))
+
+	out, err = mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 1)
+	require.Equal(t, "2", out.Messages[0].Attributes[ApproximateReceiveCount])
+
+	_, err = mc.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: url, ReceiptHandle: m.ReceiptHandle})
+	require.True(t, isExpiredHandle(err))
+	_, err = mc.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: url, ReceiptHandle: out.Messages[0].ReceiptHandle})
+	require.NoError(t, err)
+
+	_, err = mc.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: url})
+	require.NoError(t, err)
+	_, err = mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body")})
+	require.True(t, isNonExistentQueue(err))
+}
+
+func TestMemoryClientWait(t *testing.T) {
+	ctx := context.Background()
+	mc, url := newMemoryQueue(t, "test")
+
+	_, err := mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body"), DelaySeconds: 1})
+	require.NoError(t, err)
+
+	out, err := mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url})
+	require.NoError(t, err)
+	require.Empty(t, out.Messages)
+
+	// the long poll returns the message once the delay passed
+	start := time.Now()
+	out, err = mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, WaitTimeSeconds: 5})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 1)
+	require.Less(t, time.Since(start), time.Second*3)
+
+	ctxC, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	_, err = mc.ReceiveMessage(ctxC, &sqs.ReceiveMessageInput{QueueUrl: url, WaitTimeSeconds: 5})
+	require.Error(t, err)
+}
+
+func TestMemoryClientFIFO(t *testing.T) {
+	ctx := context.Background()
+	mc, url := newMemoryQueue(t, "test.fifo")
+
+	send := func(group, dedupID string) {
+		_, err := mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String(group + dedupID), MessageGroupId: aws.String(group), MessageDeduplicationId: aws.String(dedupID)})
+		require.NoError(t, err)
+	}
+	send("a", "1")
+	send("a", "2")
+	send("b", "3")
+	// deduplicated
+	send("a", "1")
+
+	_, err := mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body")})
+	require.Error(t, err)
+
+	out, err := mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, MaxNumberOfMessages: 10})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 2)
+	require.Equal(t, "a1", aws.ToString(out.Messages[0].Body))
+	require.Equal(t, "a2", aws.ToString(out.Messages[1].Body))
+
+	// the group a is in flight
+	out, err = mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, MaxNumberOfMessages: 10})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 1)
+	require.Equal(t, "b3", aws.ToString(out.Messages[0].Body))
+	require.Equal(t, "b", out.Messages[0].Attributes[MessageGroupIDAttr])
+}
+
+func TestMemoryClientChaos(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := newMemoryClient(newMemoryBroker(), Chaos{ThrottleRate: 2})
+	require.Error(t, err)
+	_, err = newMemoryClient(newMemoryBroker(), Chaos{Latency: -1})
+	require.Error(t, err)
+	require.Error(t, checkChaos(false, Chaos{Latency: 1}))
+	require.NoError(t, checkChaos(true, Chaos{Latency: 1}))
+
+	// the pipeline options override the global ones
+	chaos, err := pipelineChaos(testPipeline{chaosOpt: map[string]string{"latency": "5", "duplicate_rate": "0.5"}}, chaosOpt, Chaos{Latency: 1, ThrottleRate: 0.1})
+	require.NoError(t, err)
+	require.Equal(t, Chaos{Latency: 5, ThrottleRate: 0.1, DuplicateRate: 0.5}, chaos)
+	_, err = pipelineChaos(testPipeline{chaosOpt: map[string]string{"throttle_rate": "often"}}, chaosOpt, Chaos{})
+	require.Error(t, err)
+
+	mc, url := newMemoryQueue(t, "test")
+	mc.chaos = Chaos{ThrottleRate: 1}
+	_, err = mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body")})
+	require.True(t, isThrottled(err))
+
+	// every receive is a duplicate delivery
+	mc.chaos = Chaos{DuplicateRate: 1, Latency: 20}
+	start := time.Now()
+	_, err = mc.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: url, MessageBody: aws.String("body")})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*20)
+
+	for i := 1; i <= 2; i++ {
+		out, err := mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, VisibilityTimeout: 30})
+		require.NoError(t, err)
+		require.Len(t, out.Messages, 1)
+		require.Equal(t, "body", aws.ToString(out.Messages[0].Body))
+	}
+}
+
+func TestMemoryPipeline(t *testing.T) {
+	ctx := context.Background()
+	mc, url := newMemoryQueue(t, "test")
+
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = mc
+	c.queueURL = url
+	c.verifyMD5 = true
+
+	require.NoError(t, c.handleItem(ctx, &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: map[string][]string{"h": {"v"}}, Options: &Options{}}))
+
+	out, err := mc.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: url, MaxNumberOfMessages: 10})
+	require.NoError(t, err)
+	require.Len(t, out.Messages, 1)
+
+	item, err := c.unpack(ctx, &out.Messages[0])
+	require.NoError(t, err)
+	require.Equal(t, "id", item.ID())
+	require.Equal(t, []string{"v"}, item.headers["h"])
+	require.NoError(t, item.Ack())
+
+	attrs, err := mc.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{QueueUrl: url, AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll}})
+	require.NoError(t, err)
+	require.Equal(t, "0", attrs.Attributes["ApproximateNumberOfMessages"])
+	require.Equal(t, "0", attrs.Attributes["ApproximateNumberOfMessagesNotVisible"])
+}
@@ -0,0 +1,119 @@
+package sqsjobs
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// RRMeta is the message attribute with the bundled RR job metadata (metadata_mode: bundled)
+	RRMeta string = "X-RR-Meta"
+
+	// metadata modes
+	metadataSpread  string = "spread"
+	metadataBundled string = "bundled"
+)
+
+// rrMeta is the JSON representation of the bundled job metadata
+type rrMeta struct {
+	ID       string          `json:"id"`
+	Job      string          `json:"job"`
+	Pipeline string          `json:"pipeline,omitempty"`
+	Delay    int64           `json:"delay"`
+	Priority int64           `json:"priority"`
+	AutoAck  bool            `json:"auto_ack"`
+	Headers  json.RawMessage `json:"headers,omitempty"`
+}
+
+// checkMetadataMode validates the metadata_mode option, empty means spread
+func checkMetadataMode(mode string) (bool, error) {
+	switch mode {
+	case "", metadataSpread:
+		return false, nil
+	case metadataBundled:
+		return true, nil
+	default:
+		return false, errors.Errorf("unknown metadata_mode: %s, supported: spread, bundled", mode)
+	}
+}
+
+// spreadAttributes returns the RR metadata as separate message attributes
+func (i *Item) spreadAttributes(headers []byte) map[string]types.MessageAttributeValue {
+	return map[string]types.MessageAttributeValue{
+		jobs.RRID:       {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(i.Ident)},
+		jobs.RRJob:      {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(i.Job)},
+		jobs.RRDelay:    {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(strconv.Itoa(int(i.Options.Delay)))},
+		jobs.RRHeaders:  {DataType: aws.String(BinaryType), BinaryValue: headers, BinaryListValues: nil, StringListValues: nil, StringValue: nil},
+		jobs.RRPriority: {DataType: aws.String(NumberType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(strconv.Itoa(int(i.Options.Priority)))},
+		jobs.RRAutoAck:  {DataType: aws.String(StringType), BinaryValue: nil, BinaryListValues: nil, StringListValues: nil, StringValue: aws.String(btos(i.Options.AutoAck))},
+	}
+}
+
+// bundledAttributes returns the RR metadata as a single JSON String attribute
+func (i *Item) bundledAttributes(headers []byte) (map[string]types.MessageAttributeValue, error) {
+	data, err := json.Marshal(&rrMeta{
+		ID:       i.Ident,
+		Job:      i.Job,
+		Pipeline: i.Options.Pipeline,
+		Delay:    i.Options.Delay,
+		Priority: i.Options.Priority,
+		AutoAck:  i.Options.AutoAck,
+		Headers:  headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.MessageAttributeValue{
+		RRMeta: {DataType: aws.String(StringType), StringValue: aws.String(bytesToStr(data))},
+	}, nil
+}
+
+// expandMeta replaces the bundled metadata attribute with the spread RR attributes, so the message is unpacked the usual way.
+// The original map is not modified. Attributes without the bundled metadata are returned as is.
+func expandMeta(attrs map[string]types.MessageAttributeValue) (map[string]types.MessageAttributeValue, error) {
+	val, ok := attrs[RRMeta]
+	if !ok {
+		return attrs, nil
+	}
+
+	if val.StringValue == nil {
+		return nil, errors.Errorf("%s attribute has no string value", RRMeta)
+	}
+
+	meta := &rrMeta{}
+	err := json.Unmarshal([]byte(*val.StringValue), meta)
+	if err != nil {
+		return nil, errors.Errorf("failed to unpack the %s attribute: %v", RRMeta, err)
+	}
+
+	i := &Item{
+		Job:   meta.Job,
+		Ident: meta.ID,
+		Options: &Options{
+			Delay:    meta.Delay,
+			Priority: meta.Priority,
+			AutoAck:  meta.AutoAck,
+		},
+	}
+
+	ret := i.spreadAttributes(meta.Headers)
+	if len(meta.Headers) == 0 {
+		delete(ret, jobs.RRHeaders)
+	}
+
+	for k, v := range attrs {
+		if k == RRMeta {
+			continue
+		}
+		// spread attributes take precedence over the bundled ones
+		ret[k] = v
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,98 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	for _, bundled := range []bool{false, true} {
+		t.Run("bundled="+strconv.FormatBool(bundled), func(t *testing.T) {
+			c := newTestDriver(&testQueue{}, nil)
+
+			item := &Item{
+				Job:     "job",
+				Ident:   "id",
+				Payload: []byte("payload"),
+				headers: map[string][]string{"foo": {"bar", "baz"}},
+				Options: &Options{Delay: 5, AutoAck: true, Priority: 3, Pipeline: "test"},
+			}
+
+			in, err := item.pack(c.queueURL, c.queue, "", bundled)
+			require.NoError(t, err)
+
+			if bundled {
+				require.Len(t, in.MessageAttributes, 1)
+				require.Equal(t, StringType, aws.ToString(in.MessageAttributes[RRMeta].DataType))
+				require.Contains(t, aws.ToString(in.MessageAttributes[RRMeta].StringValue), `"pipeline":"test"`)
+			} else {
+				require.NotContains(t, in.MessageAttributes, RRMeta)
+				require.Contains(t, in.MessageAttributes, jobs.RRID)
+			}
+
+			msg := &types.Message{
+				MessageId:         aws.String("1"),
+				Body:              in.MessageBody,
+				MessageAttributes: in.MessageAttributes,
+			}
+			out, err := c.unpack(context.Background(), msg)
+			require.NoError(t, err)
+			require.Equal(t, "id", out.ID())
+			require.Equal(t, "job", out.Job)
+			require.Equal(t, []byte("payload"), out.Body())
+			require.Equal(t, int64(5), out.Options.Delay)
+			require.True(t, out.Options.AutoAck)
+			require.Equal(t, []string{"bar", "baz"}, out.headers["foo"])
+
+			// the raw message is not modified
+			require.Equal(t, in.MessageAttributes, msg.MessageAttributes)
+		})
+	}
+}
+
+func TestBundledMetadataAttributeLimit(t *testing.T) {
+	bin := string([]byte{0xff, 0x01})
+	headers := make(map[string][]string, 6)
+	for i := 0; i < 6; i++ {
+		headers["bin"+strconv.Itoa(i)] = []string{bin}
+	}
+
+	item := &Item{Job: "job", Ident: "id", headers: headers, Options: &Options{}}
+
+	// 6 RR attributes + 6 binary headers, spilled into the overflow attribute
+	spread, err := item.pack(aws.String("url"), aws.String("q"), "", false)
+	require.NoError(t, err)
+	require.NoError(t, spillAttributes(spread))
+	require.Len(t, spread.MessageAttributes, maxMessageAttributes)
+	require.Contains(t, spread.MessageAttributes, AttrOverflow)
+
+	in, err := item.pack(aws.String("url"), aws.String("q"), "", true)
+	require.NoError(t, err)
+	require.Len(t, in.MessageAttributes, 7)
+
+	c := newTestDriver(&testQueue{}, nil)
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String(""), MessageAttributes: in.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, "id", out.ID())
+	require.Equal(t, []string{bin}, out.headers["bin5"])
+}
+
+func TestMetadataMode(t *testing.T) {
+	for mode, bundled := range map[string]bool{"": false, metadataSpread: false, metadataBundled: true} {
+		b, err := checkMetadataMode(mode)
+		require.NoError(t, err)
+		require.Equal(t, bundled, b)
+	}
+
+	_, err := checkMetadataMode("foo")
+	require.Error(t, err)
+
+	_, err = expandMeta(map[string]types.MessageAttributeValue{RRMeta: {DataType: aws.String(StringType), StringValue: aws.String("{")}})
+	require.Error(t, err)
+}
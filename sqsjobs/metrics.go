@@ -0,0 +1,240 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+)
+
+// apiErrorUnknown is the error code of the failed calls without the API error code, e.g. the connection errors
+const apiErrorUnknown string = "unknown"
+
+// APIError is the key of the failed SQS calls counter
+type APIError struct {
+	Operation string
+	Code      string
+}
+
+// Metrics is the snapshot of the pipeline counters and gauges for the metrics exporters (the Prometheus collector of
+// the plugin). The counters are the totals since the pipeline start.
+type Metrics struct {
+	Pipeline string
+	Queue    string
+
+	Pushed   uint64
+	Received uint64
+	Acked    uint64
+	Nacked   uint64
+	Requeued uint64
+	// VisibilityExtensions is the number of the visibility timeout extensions by the visibility_heartbeat
+	VisibilityExtensions uint64
+	// PrefetchBuffered is the number of the received messages waiting in the dispatch buffer, PrefetchCapacity is its size
+	PrefetchBuffered int
+	PrefetchCapacity int
+	InFlight         int64
+
+	// APILatency is the latency (in seconds) of the SQS calls per operation, including the long poll wait
+	APILatency map[string]*Histogram
+	// APIErrors is the number of the failed SQS calls per operation and error code
+	APIErrors map[APIError]uint64
+
+	// ReceiveLatency and MessageAge are the poll loop histograms (latency_metrics), nil if disabled
+	ReceiveLatency *Histogram
+	MessageAge     *Histogram
+	// PayloadSize is the size (in bytes) of the sent messages, nil if disabled
+	PayloadSize *Histogram
+}
+
+// apiMetrics are the latency histograms and the error counters of the SQS calls, shared by the rotated clients
+type apiMetrics struct {
+	mu      sync.Mutex
+	buckets []float64
+	latency map[string]*histogram
+	errors  map[APIError]uint64
+}
+
+func newAPIMetrics() *apiMetrics {
+	return &apiMetrics{
+		buckets: defaultLatencyBuckets,
+		latency: make(map[string]*histogram),
+		errors:  make(map[APIError]uint64),
+	}
+}
+
+// observe records the call, the calls canceled by the driver (stop, pause) are not counted as the errors
+func (m *apiMetrics) observe(op string, start time.Time, err error) {
+	d := time.Since(start)
+
+	m.mu.Lock()
+	h, ok := m.latency[op]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.latency[op] = h
+	}
+	if err != nil && !stderr.Is(err, context.Canceled) {
+		code := apiErrorUnknown
+		var apiErr smithy.APIError
+		if stderr.As(err, &apiErr) {
+			code = apiErr.ErrorCode()
+		}
+		m.errors[APIError{Operation: op, Code: code}]++
+	}
+	m.mu.Unlock()
+
+	h.observe(d)
+}
+
+func (m *apiMetrics) snapshot() (map[string]*Histogram, map[APIError]uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latency := make(map[string]*Histogram, len(m.latency))
+	for op, h := range m.latency {
+		latency[op] = h.snapshot()
+	}
+
+	return latency, maps.Clone(m.errors)
+}
+
+// Metrics returns the snapshot of the pipeline metrics
+func (c *Driver) Metrics() Metrics {
+	m := Metrics{
+		Pipeline:             (*c.pipeline.Load()).Name(),
+		Queue:                getordefault(c.queue),
+		Received:             atomic.LoadUint64(&c.receivedTotal),
+		VisibilityExtensions: atomic.LoadUint64(&c.extensions),
+		InFlight:             atomic.LoadInt64(c.msgInFlight),
+	}
+
+	if c.throughput != nil {
+		t := c.throughput.snapshot()
+		m.Pushed, m.Acked, m.Nacked, m.Requeued = t.Pushed, t.Acked, t.Nacked, t.Requeued
+	}
+
+	if ch := c.dispatchCh; ch != nil {
+		m.PrefetchBuffered, m.PrefetchCapacity = len(ch), cap(ch)
+	}
+
+	if c.api != nil {
+		m.APILatency, m.APIErrors = c.api.snapshot()
+	}
+
+	if c.latency != nil {
+		m.ReceiveLatency, m.MessageAge = c.latency.receive.snapshot(), c.latency.age.snapshot()
+	}
+	m.PayloadSize = c.payload.snapshot()
+
+	return m
+}
+
+// metricsClient records the latency and the errors of every call of the wrapped client
+type metricsClient struct {
+	sqsClient
+	m *apiMetrics
+}
+
+// withAPIMetrics wraps the client, the client is returned as is if the metrics are disabled
+func withAPIMetrics(client sqsClient, m *apiMetrics) sqsClient {
+	if m == nil {
+		return client
+	}
+
+	return &metricsClient{sqsClient: client, m: m}
+}
+
+func (mc *metricsClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.SendMessage(ctx, params, optFns...)
+	mc.m.observe("SendMessage", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.SendMessageBatch(ctx, params, optFns...)
+	mc.m.observe("SendMessageBatch", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.ReceiveMessage(ctx, params, optFns...)
+	mc.m.observe("ReceiveMessage", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.ChangeMessageVisibility(ctx, params, optFns...)
+	mc.m.observe("ChangeMessageVisibility", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.DeleteMessage(ctx, params, optFns...)
+	mc.m.observe("DeleteMessage", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.DeleteMessageBatch(ctx, params, optFns...)
+	mc.m.observe("DeleteMessageBatch", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.CreateQueue(ctx, params, optFns...)
+	mc.m.observe("CreateQueue", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.GetQueueUrl(ctx, params, optFns...)
+	mc.m.observe("GetQueueUrl", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.DeleteQueue(ctx, params, optFns...)
+	mc.m.observe("DeleteQueue", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.GetQueueAttributes(ctx, params, optFns...)
+	mc.m.observe("GetQueueAttributes", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.SetQueueAttributes(ctx, params, optFns...)
+	mc.m.observe("SetQueueAttributes", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.ListQueueTags(ctx, params, optFns...)
+	mc.m.observe("ListQueueTags", start, err)
+	return out, err
+}
+
+func (mc *metricsClient) TagQueue(ctx context.Context, params *sqs.TagQueueInput, optFns ...func(*sqs.Options)) (*sqs.TagQueueOutput, error) {
+	start := time.Now()
+	out, err := mc.sqsClient.TagQueue(ctx, params, optFns...)
+	mc.m.observe("TagQueue", start, err)
+	return out, err
+}
@@ -0,0 +1,73 @@
+package sqsjobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIMetrics(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.api = newAPIMetrics()
+	fc := newFakeClient()
+	calls := 0
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, &smithy.GenericAPIError{Code: "AccessDenied"}
+		case 2:
+			return nil, errors.New("connection reset")
+		case 3:
+			return nil, context.Canceled
+		}
+		return &sqs.SendMessageOutput{MessageId: aws.String("m1")}, nil
+	}
+	c.client = withAPIMetrics(fc, c.api)
+
+	for range 4 {
+		_, _ = c.client.SendMessage(context.Background(), &sqs.SendMessageInput{QueueUrl: c.queueURL, MessageBody: aws.String("body")})
+	}
+
+	m := c.Metrics()
+	require.Equal(t, "test", m.Pipeline)
+	require.Equal(t, "test", m.Queue)
+	require.Equal(t, uint64(4), m.APILatency["SendMessage"].Count)
+	// the canceled call is not an error
+	require.Equal(t, map[APIError]uint64{
+		{Operation: "SendMessage", Code: "AccessDenied"}:  1,
+		{Operation: "SendMessage", Code: apiErrorUnknown}: 1,
+	}, m.APIErrors)
+
+	// the snapshot is a copy
+	m.APIErrors[APIError{Operation: "SendMessage", Code: "AccessDenied"}] = 10
+	require.Equal(t, uint64(1), c.Metrics().APIErrors[APIError{Operation: "SendMessage", Code: "AccessDenied"}])
+
+	require.True(t, withAPIMetrics(fc, nil) == sqsClient(fc))
+}
+
+func TestMetricsReceived(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String("body")},
+		types.Message{MessageId: aws.String("m2"), ReceiptHandle: aws.String("r2"), Body: aws.String("body")},
+	)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return c.Metrics().Received == 2 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	m := c.Metrics()
+	require.Equal(t, uint64(2), m.Received)
+	// the api metrics are disabled for the test driver
+	require.Nil(t, m.APILatency)
+}
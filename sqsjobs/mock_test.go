@@ -0,0 +1,381 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// testQueue is an in-memory priority queue with an optional slow insert
+type testQueue struct {
+	mu          sync.Mutex
+	items       []jobs.Job
+	insertDelay time.Duration
+}
+
+func (q *testQueue) Remove(string) []jobs.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+func (q *testQueue) Insert(item jobs.Job) {
+	if q.insertDelay > 0 {
+		time.Sleep(q.insertDelay)
+	}
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+}
+
+func (q *testQueue) ExtractMin() jobs.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item
+}
+
+func (q *testQueue) Len() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return uint64(len(q.items))
+}
+
+// testPipeline is a map based jobs.Pipeline
+type testPipeline map[string]any
+
+func (p testPipeline) With(name string, value any) { p[name] = value }
+func (p testPipeline) Name() string                { return p.String("name", "") }
+func (p testPipeline) Driver() string              { return p.String("driver", "") }
+func (p testPipeline) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+func (p testPipeline) Get(key string) any { return p[key] }
+func (p testPipeline) String(name string, d string) string {
+	if v, ok := p[name].(string); ok {
+		return v
+	}
+	return d
+}
+func (p testPipeline) Int(name string, d int) int {
+	if v, ok := p[name].(int); ok {
+		return v
+	}
+	return d
+}
+func (p testPipeline) Bool(name string, d bool) bool {
+	if v, ok := p[name].(bool); ok {
+		return v
+	}
+	return d
+}
+func (p testPipeline) Map(name string, out map[string]string) error {
+	if v, ok := p[name].(map[string]string); ok {
+		for k := range v {
+			out[k] = v[k]
+		}
+	}
+	return nil
+}
+func (p testPipeline) Priority() int64 {
+	if v, ok := p["priority"].(int64); ok {
+		return v
+	}
+	return 10
+}
+
+// newTestDriver creates a driver without the AWS connection
+func newTestDriver(pq jobs.Queue, pipe testPipeline) *Driver {
+	if pipe == nil {
+		pipe = testPipeline{"name": "test", "driver": pluginName}
+	}
+
+	d := &Driver{
+		tracer:            sdktrace.NewTracerProvider(),
+		prop:              propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+		pq:                pq,
+		log:               zap.NewNop(),
+		queue:             ptr("test"),
+		queueURL:          ptr("http://127.0.0.1:9324/000000000000/test"),
+		pollers:           1,
+		queueReadyTimeout: time.Second * 10,
+		msgInFlightLimit:  ptr(int32(10)),
+		msgInFlight:       ptr(int64(0)),
+		decoders:          defaultDecoders(),
+		client:            newFakeClient(),
+		throughput:        newThroughput(),
+	}
+	d.cond = sync.Cond{L: &sync.Mutex{}}
+
+	var p jobs.Pipeline = pipe
+	d.pipeline.Store(&p)
+
+	return d
+}
+
+// fakeClient is an in-memory sqsClient, every call is recorded and might be overridden with the corresponding func
+type fakeClient struct {
+	mu    sync.Mutex
+	calls map[string]int
+
+	sent       []*sqs.SendMessageInput
+	batches    []*sqs.SendMessageBatchInput
+	received   []*sqs.ReceiveMessageInput
+	deleted    []*sqs.DeleteMessageInput
+	delBatches []*sqs.DeleteMessageBatchInput
+	visibility []*sqs.ChangeMessageVisibilityInput
+	created    []*sqs.CreateQueueInput
+	resolved   []*sqs.GetQueueUrlInput
+	dropped    []*sqs.DeleteQueueInput
+	setAttrs   []*sqs.SetQueueAttributesInput
+	tagged     []*sqs.TagQueueInput
+
+	sendFn       func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
+	sendBatchFn  func(*sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error)
+	receiveFn    func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
+	visibilityFn func(*sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error)
+	deleteFn     func(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+	delBatchFn   func(*sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error)
+	createFn     func(context.Context, *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error)
+	getURLFn     func(context.Context, *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error)
+	getAttrsFn   func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error)
+	listTagsFn   func(*sqs.ListQueueTagsInput) (*sqs.ListQueueTagsOutput, error)
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{calls: make(map[string]int)}
+}
+
+func (f *fakeClient) called(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[name]
+}
+
+func (f *fakeClient) record(name string) {
+	f.mu.Lock()
+	f.calls[name]++
+	f.mu.Unlock()
+}
+
+func (f *fakeClient) SendMessage(_ context.Context, params *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.record("SendMessage")
+	f.mu.Lock()
+	f.sent = append(f.sent, params)
+	f.mu.Unlock()
+	if f.sendFn != nil {
+		return f.sendFn(params)
+	}
+	return &sqs.SendMessageOutput{MessageId: aws.String(uuid.NewString())}, nil
+}
+
+func (f *fakeClient) SendMessageBatch(_ context.Context, params *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.record("SendMessageBatch")
+	f.mu.Lock()
+	f.batches = append(f.batches, params)
+	f.mu.Unlock()
+	if f.sendBatchFn != nil {
+		return f.sendBatchFn(params)
+	}
+	out := &sqs.SendMessageBatchOutput{}
+	for i := 0; i < len(params.Entries); i++ {
+		out.Successful = append(out.Successful, types.SendMessageBatchResultEntry{Id: params.Entries[i].Id, MessageId: aws.String(uuid.NewString())})
+	}
+	return out, nil
+}
+
+func (f *fakeClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.record("ReceiveMessage")
+	f.mu.Lock()
+	f.received = append(f.received, params)
+	f.mu.Unlock()
+	if f.receiveFn != nil {
+		return f.receiveFn(ctx, params)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Millisecond * 10):
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+}
+
+func (f *fakeClient) ChangeMessageVisibility(_ context.Context, params *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.record("ChangeMessageVisibility")
+	f.mu.Lock()
+	f.visibility = append(f.visibility, params)
+	f.mu.Unlock()
+	if f.visibilityFn != nil {
+		return f.visibilityFn(params)
+	}
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeClient) DeleteMessage(_ context.Context, params *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.record("DeleteMessage")
+	f.mu.Lock()
+	f.deleted = append(f.deleted, params)
+	f.mu.Unlock()
+	if f.deleteFn != nil {
+		return f.deleteFn(params)
+	}
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeClient) DeleteMessageBatch(_ context.Context, params *sqs.DeleteMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	f.record("DeleteMessageBatch")
+	f.mu.Lock()
+	f.delBatches = append(f.delBatches, params)
+	f.mu.Unlock()
+	if f.delBatchFn != nil {
+		return f.delBatchFn(params)
+	}
+
+	out := &sqs.DeleteMessageBatchOutput{}
+	for i := 0; i < len(params.Entries); i++ {
+		out.Successful = append(out.Successful, types.DeleteMessageBatchResultEntry{Id: params.Entries[i].Id})
+	}
+	return out, nil
+}
+
+func (f *fakeClient) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, _ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.record("CreateQueue")
+	f.mu.Lock()
+	f.created = append(f.created, params)
+	f.mu.Unlock()
+	if f.createFn != nil {
+		return f.createFn(ctx, params)
+	}
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(params.QueueName))}, nil
+}
+
+func (f *fakeClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, _ ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	f.record("GetQueueUrl")
+	f.mu.Lock()
+	f.resolved = append(f.resolved, params)
+	f.mu.Unlock()
+	if f.getURLFn != nil {
+		return f.getURLFn(ctx, params)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(params.QueueName))}, nil
+}
+
+func (f *fakeClient) DeleteQueue(_ context.Context, params *sqs.DeleteQueueInput, _ ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	f.record("DeleteQueue")
+	f.mu.Lock()
+	f.dropped = append(f.dropped, params)
+	f.mu.Unlock()
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+func (f *fakeClient) GetQueueAttributes(_ context.Context, params *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	f.record("GetQueueAttributes")
+	if f.getAttrsFn != nil {
+		return f.getAttrsFn(params)
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{}}, nil
+}
+
+func (f *fakeClient) SetQueueAttributes(_ context.Context, params *sqs.SetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	f.record("SetQueueAttributes")
+	f.mu.Lock()
+	f.setAttrs = append(f.setAttrs, params)
+	f.mu.Unlock()
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (f *fakeClient) ListQueueTags(_ context.Context, params *sqs.ListQueueTagsInput, _ ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	f.record("ListQueueTags")
+	if f.listTagsFn != nil {
+		return f.listTagsFn(params)
+	}
+	return &sqs.ListQueueTagsOutput{}, nil
+}
+
+func (f *fakeClient) TagQueue(_ context.Context, params *sqs.TagQueueInput, _ ...func(*sqs.Options)) (*sqs.TagQueueOutput, error) {
+	f.record("TagQueue")
+	f.mu.Lock()
+	f.tagged = append(f.tagged, params)
+	f.mu.Unlock()
+	return &sqs.TagQueueOutput{}, nil
+}
+
+// receiveOnce returns the messages on the first call and empty responses afterward
+func receiveOnce(msgs ...types.Message) func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	var once sync.Once
+	return func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		out := &sqs.ReceiveMessageOutput{}
+		once.Do(func() {
+			out.Messages = msgs
+		})
+		if len(out.Messages) > 0 {
+			return out, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+			return out, nil
+		}
+	}
+}
+
+// receiveEach returns one message per call (e.g. for several pollers) and empty responses afterward
+func receiveEach(msgs ...types.Message) func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	var mu sync.Mutex
+	return func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		mu.Lock()
+		if len(msgs) > 0 {
+			out := &sqs.ReceiveMessageOutput{Messages: msgs[:1]}
+			msgs = msgs[1:]
+			mu.Unlock()
+			return out, nil
+		}
+		mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+}
+
+// runListener starts the listener and returns a func to stop it, the func waits for the pollers to exit
+func runListener(c *Driver) func() {
+	return runListeners(c, 1)
+}
+
+// runListeners starts n pollers sharing the stop func
+func runListeners(c *Driver, n int) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	for range n {
+		c.listen(ctx)
+	}
+	return func() {
+		cancel()
+		deadline := time.Now().Add(time.Second * 5)
+		for atomic.LoadInt32(&c.activePollers) > 0 && time.Now().Before(deadline) {
+			c.cond.Broadcast()
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
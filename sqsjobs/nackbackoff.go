@@ -0,0 +1,83 @@
+package sqsjobs
+
+import (
+	"context"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// nack_backoff strategies
+const (
+	backoffExponential string = "exponential"
+	backoffLinear      string = "linear"
+
+	defaultNackBackoffBase int = 1
+	defaultNackBackoffMax  int = 900
+)
+
+// nackBackoff keeps the nacked message in the queue and delays its redelivery by the receive count, instead of
+// sending it again: exponential - base * 2^(receive count - 1), linear - base * receive count, up to the max (in seconds)
+type nackBackoff struct {
+	exponential bool
+	base        int64
+	max         int64
+}
+
+// newNackBackoff validates the nack_backoff options, nil if the strategy is not set. The nacked messages are sent to
+// the retry_queue otherwise, so the options can't be combined.
+func newNackBackoff(strategy string, base, maxDelay int, retryQueue bool) (*nackBackoff, error) {
+	switch strategy {
+	case "":
+		return nil, nil
+	case backoffExponential, backoffLinear:
+	default:
+		return nil, errors.Errorf("unknown nack_backoff strategy: %s, supported: exponential, linear", strategy)
+	}
+
+	if retryQueue {
+		return nil, errors.Str("nack_backoff can't be combined with the retry_queue")
+	}
+
+	if base < 0 || maxDelay < 0 {
+		return nil, errors.Errorf("nack_backoff_base and nack_backoff_max should not be negative, provided: %d, %d", base, maxDelay)
+	}
+
+	if base == 0 {
+		base = defaultNackBackoffBase
+	}
+
+	if maxDelay == 0 {
+		maxDelay = defaultNackBackoffMax
+	}
+
+	if maxDelay > int(maxVisibilityTimeout) || base > maxDelay {
+		return nil, errors.Errorf("nack_backoff_base (%d) should not exceed the nack_backoff_max (%d), up to %d seconds", base, maxDelay, maxVisibilityTimeout)
+	}
+
+	return &nackBackoff{exponential: strategy == backoffExponential, base: int64(base), max: int64(maxDelay)}, nil
+}
+
+// delay returns the visibility timeout (in seconds) of the nacked message
+func (b *nackBackoff) delay(receiveCount int64) int32 {
+	receiveCount = max(receiveCount, 1)
+
+	if !b.exponential {
+		return int32(min(b.base*receiveCount, b.max))
+	}
+
+	d := b.base
+	for i := int64(1); i < receiveCount && d < b.max; i++ {
+		d *= 2
+	}
+
+	return int32(min(d, b.max))
+}
+
+// backoffNack returns the message to the queue with the visibility timeout by the receive count (nack_backoff)
+func (i *Item) backoffNack(ctx context.Context) error {
+	delay := i.Options.nackBackoff.delay(i.Options.approxReceiveCount)
+	i.debug("nack backoff", zap.Int32("visibility_timeout", delay), zap.Int64("receive_count", i.Options.approxReceiveCount))
+
+	return i.releaseNack(ctx, delay)
+}
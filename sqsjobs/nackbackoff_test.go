@@ -0,0 +1,82 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNackBackoffDelay(t *testing.T) {
+	exp, err := newNackBackoff(backoffExponential, 2, 60, false)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), exp.delay(0))
+	require.Equal(t, int32(2), exp.delay(1))
+	require.Equal(t, int32(4), exp.delay(2))
+	require.Equal(t, int32(32), exp.delay(5))
+	require.Equal(t, int32(60), exp.delay(6))
+	require.Equal(t, int32(60), exp.delay(1000))
+
+	lin, err := newNackBackoff(backoffLinear, 10, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, int32(10), lin.delay(1))
+	require.Equal(t, int32(30), lin.delay(3))
+	require.Equal(t, int32(900), lin.delay(500))
+}
+
+func TestNewNackBackoff(t *testing.T) {
+	b, err := newNackBackoff("", 0, 0, true)
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	b, err = newNackBackoff(backoffExponential, 0, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), b.base)
+	require.Equal(t, int64(900), b.max)
+
+	_, err = newNackBackoff("fibonacci", 0, 0, false)
+	require.Error(t, err)
+
+	_, err = newNackBackoff(backoffLinear, 0, 0, true)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "retry_queue")
+
+	_, err = newNackBackoff(backoffLinear, -1, 0, false)
+	require.Error(t, err)
+
+	_, err = newNackBackoff(backoffLinear, 0, 43201, false)
+	require.Error(t, err)
+
+	_, err = newNackBackoff(backoffLinear, 100, 10, false)
+	require.Error(t, err)
+}
+
+func TestNackWithBackoff(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	var err error
+	c.nackBackoff, err = newNackBackoff(backoffExponential, 5, 0, false)
+	require.NoError(t, err)
+	fc := newFakeClient()
+	c.client = fc
+
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:     aws.String("1"),
+		ReceiptHandle: aws.String("receipt-1"),
+		Body:          aws.String("poison"),
+		Attributes:    map[string]string{ApproximateReceiveCount: "3"},
+	})
+	require.NoError(t, err)
+
+	// kept in the queue, visible again after the backoff
+	require.NoError(t, item.Nack())
+	require.Equal(t, 0, fc.called("SendMessage"))
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+
+	fc.mu.Lock()
+	require.Equal(t, "receipt-1", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(20), fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+}
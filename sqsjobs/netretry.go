@@ -0,0 +1,112 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultNetRetries is the number of the additional attempts on the transient network errors
+	defaultNetRetries  int = 3
+	netRetryBackoff        = time.Millisecond * 200
+	maxNetRetryBackoff     = time.Second * 5
+)
+
+// isTransientNetError checks the network layer errors which are worth retrying for a long-lived consumer:
+// connection reset/refused/aborted, broken pipe, unexpected EOF, timeouts and temporary DNS failures.
+// Permanent DNS failures (no such host) are never retried.
+func isTransientNetError(err error) bool {
+	if err == nil || stderr.Is(err, context.Canceled) || stderr.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if stderr.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+	}
+
+	switch {
+	case stderr.Is(err, syscall.ECONNRESET),
+		stderr.Is(err, syscall.ECONNREFUSED),
+		stderr.Is(err, syscall.ECONNABORTED),
+		stderr.Is(err, syscall.EPIPE),
+		stderr.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	var netErr net.Error
+	return stderr.As(err, &netErr) && netErr.Timeout()
+}
+
+// isUnsentNetError checks the transient network errors which happen before the request is sent: the failed dial
+// (connection refused, the dial timeout) and the temporary DNS failures. A reset, a timeout or an unexpected EOF of the
+// established connection might happen after SQS accepted the message, so the send is not retried on them.
+func isUnsentNetError(err error) bool {
+	if !isTransientNetError(err) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if stderr.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return stderr.Is(err, syscall.ECONNREFUSED) || (stderr.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// netRetry calls fn, retrying the transient network errors with the jittered backoff up to the retries times (independent of the SDK retryer).
+// The last error is returned when the retries or the retry budget are exhausted or the context is done.
+func netRetry[T any](ctx context.Context, log *zap.Logger, retries int, budget *retryBudget, op string, fn func() (T, error)) (T, error) {
+	return retryNet(ctx, log, retries, budget, op, isTransientNetError, fn)
+}
+
+// sendRetry is the netRetry of the sends, only the errors before the request is sent are retried (isUnsentNetError),
+// so the retry never duplicates the message
+func sendRetry[T any](ctx context.Context, log *zap.Logger, retries int, budget *retryBudget, op string, fn func() (T, error)) (T, error) {
+	return retryNet(ctx, log, retries, budget, op, isUnsentNetError, fn)
+}
+
+func retryNet[T any](ctx context.Context, log *zap.Logger, retries int, budget *retryBudget, op string, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	backoff := netRetryBackoff
+	for attempt := 0; ; attempt++ {
+		out, err := fn()
+		if err == nil || attempt >= retries || !retryable(err) {
+			return out, err
+		}
+
+		if !budget.take() {
+			log.Warn("retry budget exhausted, not retrying", zap.String("operation", op), zap.Error(err))
+			return out, err
+		}
+
+		d := jitter(backoff)
+		log.Warn("transient network error, retrying", zap.String("operation", op), zap.Int("attempt", attempt+1), zap.Duration("backoff", d), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-time.After(d):
+		}
+
+		backoff = min(backoff*2, maxNetRetryBackoff)
+	}
+}
+
+// netRetries returns the number of the retries on the transient network errors, 0 - default, negative - disabled
+func netRetries(n int) int {
+	switch {
+	case n == 0:
+		return defaultNetRetries
+	case n < 0:
+		return 0
+	default:
+		return n
+	}
+}
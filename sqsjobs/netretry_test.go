@@ -0,0 +1,116 @@
+package sqsjobs
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func connReset() error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+}
+
+func connRefused() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+}
+
+func TestTransientNetError(t *testing.T) {
+	require.True(t, isTransientNetError(connReset()))
+	require.True(t, isTransientNetError(&net.DNSError{Err: "i/o timeout", Name: "sqs.us-east-1.amazonaws.com", IsTimeout: true}))
+	require.True(t, isTransientNetError(io.ErrUnexpectedEOF))
+
+	require.False(t, isTransientNetError(&net.DNSError{Err: "no such host", Name: "sqs.us-east-42.amazonaws.com", IsNotFound: true}))
+	require.False(t, isTransientNetError(context.Canceled))
+	require.False(t, isTransientNetError(io.EOF))
+	require.False(t, isTransientNetError(nil))
+
+	// the request might be sent already
+	require.True(t, isUnsentNetError(connRefused()))
+	require.True(t, isUnsentNetError(&net.DNSError{Err: "i/o timeout", Name: "sqs.us-east-1.amazonaws.com", IsTimeout: true}))
+	require.False(t, isUnsentNetError(connReset()))
+	require.False(t, isUnsentNetError(io.ErrUnexpectedEOF))
+	require.False(t, isUnsentNetError(&net.DNSError{Err: "no such host", Name: "sqs.us-east-42.amazonaws.com", IsNotFound: true}))
+}
+
+func TestNetRetryConnectionReset(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.netRetries = netRetries(0)
+	fc := newFakeClient()
+	calls := 0
+	var sendErr error
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		calls++
+		if calls == 1 {
+			return nil, sendErr
+		}
+		return &sqs.SendMessageOutput{MessageId: aws.String("1")}, nil
+	}
+	c.client = fc
+
+	// the send is retried only if it didn't reach SQS
+	sendErr = connRefused()
+	require.NoError(t, c.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("body")}))
+	require.Equal(t, 2, fc.called("SendMessage"))
+
+	calls = 0
+	sendErr = connReset()
+	require.Error(t, c.send(context.Background(), &sqs.SendMessageInput{MessageBody: aws.String("body")}))
+	require.Equal(t, 3, fc.called("SendMessage"))
+
+	// receive path
+	pq := &testQueue{}
+	c = newTestDriver(pq, nil)
+	c.netRetries = netRetries(0)
+	fc = newFakeClient()
+	received := receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("body")})
+	reset := true
+	fc.receiveFn = func(ctx context.Context, in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		if reset {
+			reset = false
+			return nil, connReset()
+		}
+		return received(ctx, in)
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	// retried inside the receive, never reported as the failure
+	require.Nil(t, st.LastErrorAt)
+}
+
+func TestNetRetryPermanentDNS(t *testing.T) {
+	calls := 0
+	_, err := netRetry(context.Background(), zap.NewNop(), 3, nil, "SendMessage", func() (any, error) {
+		calls++
+		return nil, &net.DNSError{Err: "no such host", Name: "sqs.us-east-42.amazonaws.com", IsNotFound: true}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	// retries are bounded
+	calls = 0
+	_, err = netRetry(context.Background(), zap.NewNop(), 2, nil, "SendMessage", func() (any, error) {
+		calls++
+		return nil, connReset()
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+
+	require.Equal(t, defaultNetRetries, netRetries(0))
+	require.Equal(t, 0, netRetries(-1))
+}
@@ -0,0 +1,350 @@
+package sqsjobs
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ExtendedPayloadSize is the attribute with the size of the offloaded body, the same as the SQS Extended Client one
+	ExtendedPayloadSize string = "ExtendedPayloadSize"
+	// legacySQSLargePayloadSize is the attribute set by the older Extended Client versions
+	legacySQSLargePayloadSize string = "SQSLargePayloadSize"
+	// payloadPointerClass is the first element of the Extended Client pointer message
+	payloadPointerClass string = "software.amazon.payloadoffloading.PayloadS3Pointer"
+	// OffloadedAttributes is the attribute with the pointer to the offloaded message attributes (s3_offload_attributes),
+	// the JSON object with the s3BucketName and the s3Key
+	OffloadedAttributes string = "X-RR-Offloaded-Attributes"
+	// the attributes object is stored next to the body one
+	attributesKeySuffix string = ".attributes"
+
+	// the store call shouldn't block the listener (or the ack) for long
+	payloadStoreTimeout = time.Second * 30
+)
+
+// PayloadStore keeps the message bodies offloaded from the queue (s3_bucket), e.g. an S3 client. The message sent to the
+// queue carries the pointer to the object (the SQS Extended Client format), the body is fetched on receive and the object
+// is deleted once the message is deleted from the queue.
+type PayloadStore interface {
+	Put(ctx context.Context, bucket, key string, body []byte) error
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// RegisterPayloadStore enables the payload offloading (s3_bucket) and the fetch of the offloaded bodies, nil disables it
+func (c *Driver) RegisterPayloadStore(s PayloadStore) {
+	if s == nil {
+		c.payloadStore.Store(nil)
+		return
+	}
+
+	c.payloadStore.Store(&s)
+}
+
+// checkPayloadStore returns an error if the s3_bucket is set, but the payload store is not registered
+func (c *Driver) checkPayloadStore() error {
+	if c.offload != nil && c.payloadStore.Load() == nil {
+		return errors.Errorf("s3_bucket (%s) is set, but the payload store is not registered (RegisterPayloadStore or the PayloadStoreProvider plugin)", c.offload.bucket)
+	}
+
+	return nil
+}
+
+// payloadOffload is the s3_bucket configuration, nil if disabled
+type payloadOffload struct {
+	bucket string
+	prefix string
+	// always_through_s3, otherwise only the messages above the threshold are offloaded
+	always    bool
+	threshold int
+	// s3_offload_attributes
+	attributes bool
+}
+
+// payloadPointer is the location of the offloaded body
+type payloadPointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// newPayloadOffload validates the s3_bucket options, nil if the s3_bucket is not set
+func newPayloadOffload(bucket, prefix string, always bool, threshold int) (*payloadOffload, error) {
+	if bucket == "" {
+		if prefix != "" || always {
+			return nil, errors.Str("s3_prefix and always_through_s3 require the s3_bucket")
+		}
+		return nil, nil
+	}
+
+	if threshold < 0 || threshold > maxMessageBytes {
+		return nil, errors.Errorf("s3_threshold should be in the range [0, %d] bytes, provided: %d", maxMessageBytes, threshold)
+	}
+
+	if threshold == 0 {
+		threshold = maxMessageBytes
+	}
+
+	return &payloadOffload{bucket: bucket, prefix: prefix, always: always, threshold: threshold}, nil
+}
+
+// withAttributes enables the s3_offload_attributes, the offload is nil if the s3_bucket is not set
+func (o *payloadOffload) withAttributes(enabled bool) error {
+	if !enabled {
+		return nil
+	}
+
+	if o == nil {
+		return errors.Str("s3_offload_attributes requires the s3_bucket")
+	}
+
+	o.attributes = true
+	return nil
+}
+
+// offloadBody uploads the body of the message above the s3_threshold (or every body with the always_through_s3)
+// to the payload store and replaces it with the pointer
+func (c *Driver) offloadBody(ctx context.Context, d *sqs.SendMessageInput) error {
+	if c.offload == nil {
+		return nil
+	}
+
+	if !c.offload.always && messageSize(d) <= c.offload.threshold {
+		return nil
+	}
+
+	s := c.payloadStore.Load()
+	if s == nil {
+		return c.checkPayloadStore()
+	}
+
+	body := getordefault(d.MessageBody)
+	ptr := payloadPointer{Bucket: c.offload.bucket, Key: c.offload.prefix + uuid.NewString()}
+
+	ctxT, cancel := context.WithTimeout(ctx, payloadStoreTimeout)
+	defer cancel()
+
+	err := (*s).Put(ctxT, ptr.Bucket, ptr.Key, []byte(body))
+	if err != nil {
+		return errors.Errorf("failed to offload the message body to %s/%s: %v", ptr.Bucket, ptr.Key, err)
+	}
+
+	pointer, err := json.Marshal([]any{payloadPointerClass, ptr})
+	if err != nil {
+		return err
+	}
+
+	if d.MessageAttributes == nil {
+		d.MessageAttributes = make(map[string]types.MessageAttributeValue, 1)
+	}
+
+	d.MessageBody = aws.String(string(pointer))
+	d.MessageAttributes[ExtendedPayloadSize] = types.MessageAttributeValue{DataType: aws.String(NumberType), StringValue: aws.String(strconv.Itoa(len(body)))}
+
+	c.log.Debug("message body was offloaded", zap.String("bucket", ptr.Bucket), zap.String("key", ptr.Key), zap.Int("size", len(body)))
+
+	// s3_offload_attributes, the message is still above the threshold
+	if c.offload.attributes && messageSize(d) > c.offload.threshold {
+		err = c.offloadAttributes(ctx, *s, ptr, d)
+		if err != nil {
+			// the message is not sent, the body object would be orphaned
+			c.deleteOffloaded(*s, ptr)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// offloadAttributes uploads the message attributes next to the offloaded body and replaces them with the pointer.
+// The attributes read before the message is unpacked are kept.
+func (c *Driver) offloadAttributes(ctx context.Context, s PayloadStore, body payloadPointer, d *sqs.SendMessageInput) error {
+	if _, ok := d.MessageAttributes[OffloadedAttributes]; ok {
+		return errors.Errorf("%s message attribute is reserved", OffloadedAttributes)
+	}
+
+	keep := []string{ExtendedPayloadSize, c.dedupAttribute, c.executeAtAttr, c.partitionKeyAttr()}
+	offloaded := make(map[string]overflowAttr, len(d.MessageAttributes))
+	for k, v := range d.MessageAttributes {
+		if slices.Contains(keep, k) {
+			continue
+		}
+		offloaded[k] = overflowAttr{DataType: getordefault(v.DataType), String: v.StringValue, Binary: v.BinaryValue}
+	}
+
+	if len(offloaded) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(offloaded)
+	if err != nil {
+		return err
+	}
+
+	ptr := payloadPointer{Bucket: body.Bucket, Key: body.Key + attributesKeySuffix}
+
+	ctxT, cancel := context.WithTimeout(ctx, payloadStoreTimeout)
+	defer cancel()
+
+	err = s.Put(ctxT, ptr.Bucket, ptr.Key, data)
+	if err != nil {
+		return errors.Errorf("failed to offload the message attributes to %s/%s: %v", ptr.Bucket, ptr.Key, err)
+	}
+
+	pointer, err := json.Marshal(ptr)
+	if err != nil {
+		return err
+	}
+
+	for k := range offloaded {
+		delete(d.MessageAttributes, k)
+	}
+	d.MessageAttributes[OffloadedAttributes] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(string(pointer))}
+
+	c.log.Debug("message attributes were offloaded", zap.String("bucket", ptr.Bucket), zap.String("key", ptr.Key), zap.Int("attributes", len(offloaded)))
+
+	return nil
+}
+
+// fetchOffloadedAttributes restores the attributes offloaded with the body, the returned func deletes the object
+// (nil if not offloaded). The original map is not modified.
+func (c *Driver) fetchOffloadedAttributes(ctx context.Context, attrs map[string]types.MessageAttributeValue) (map[string]types.MessageAttributeValue, func(), error) {
+	val, ok := attrs[OffloadedAttributes]
+	if !ok {
+		return attrs, nil, nil
+	}
+
+	ptr := payloadPointer{}
+	if json.Unmarshal([]byte(getordefault(val.StringValue)), &ptr) != nil || ptr.Bucket == "" || ptr.Key == "" {
+		return nil, nil, errors.Errorf("malformed %s attribute", OffloadedAttributes)
+	}
+
+	s := c.payloadStore.Load()
+	if s == nil {
+		return nil, nil, errors.Errorf("the message attributes are offloaded to %s/%s, but the payload store is not registered (RegisterPayloadStore)", ptr.Bucket, ptr.Key)
+	}
+
+	ctxT, cancel := context.WithTimeout(ctx, payloadStoreTimeout)
+	defer cancel()
+
+	data, err := (*s).Get(ctxT, ptr.Bucket, ptr.Key)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to fetch the offloaded message attributes %s/%s: %v", ptr.Bucket, ptr.Key, err)
+	}
+
+	offloaded := make(map[string]overflowAttr)
+	err = json.Unmarshal(data, &offloaded)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to unpack the offloaded message attributes %s/%s: %v", ptr.Bucket, ptr.Key, err)
+	}
+
+	ret := make(map[string]types.MessageAttributeValue, len(attrs)+len(offloaded))
+	for k, v := range offloaded {
+		ret[k] = types.MessageAttributeValue{DataType: aws.String(v.DataType), StringValue: v.String, BinaryValue: v.Binary}
+	}
+
+	for k, v := range attrs {
+		if k == OffloadedAttributes {
+			continue
+		}
+		ret[k] = v
+	}
+
+	// the bundled metadata (metadata_mode: bundled) is offloaded as well
+	ret, err = expandMeta(ret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ret, func() {
+		c.deleteOffloaded(*s, ptr)
+	}, nil
+}
+
+// dropAll returns the func deleting all the offloaded objects of the message, nil if nothing is offloaded
+func dropAll(drops ...func()) func() {
+	drops = slices.DeleteFunc(drops, func(fn func()) bool {
+		return fn == nil
+	})
+
+	if len(drops) == 0 {
+		return nil
+	}
+
+	return func() {
+		for _, fn := range drops {
+			fn()
+		}
+	}
+}
+
+// fetchOffloaded replaces the pointer with the offloaded body, the returned func deletes the object (nil if not offloaded)
+func (c *Driver) fetchOffloaded(ctx context.Context, body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, func(), error) {
+	_, ok := attrs[ExtendedPayloadSize]
+	if !ok {
+		_, ok = attrs[legacySQSLargePayloadSize]
+	}
+	if !ok {
+		return body, nil, nil
+	}
+
+	ptr, err := parsePayloadPointer(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := c.payloadStore.Load()
+	if s == nil {
+		return nil, nil, errors.Errorf("the message body is offloaded to %s/%s, but the payload store is not registered (RegisterPayloadStore)", ptr.Bucket, ptr.Key)
+	}
+
+	ctxT, cancel := context.WithTimeout(ctx, payloadStoreTimeout)
+	defer cancel()
+
+	offloaded, err := (*s).Get(ctxT, ptr.Bucket, ptr.Key)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to fetch the offloaded message body %s/%s: %v", ptr.Bucket, ptr.Key, err)
+	}
+
+	return offloaded, func() {
+		c.deleteOffloaded(*s, ptr)
+	}, nil
+}
+
+// deleteOffloaded deletes the object of the deleted message, the failure only leaves the orphaned object
+func (c *Driver) deleteOffloaded(s PayloadStore, ptr payloadPointer) {
+	ctx, cancel := context.WithTimeout(context.Background(), payloadStoreTimeout)
+	defer cancel()
+
+	err := s.Delete(ctx, ptr.Bucket, ptr.Key)
+	if err != nil {
+		c.log.Warn("failed to delete the offloaded message body", zap.String("bucket", ptr.Bucket), zap.String("key", ptr.Key), zap.Error(err))
+	}
+}
+
+// parsePayloadPointer decodes the Extended Client pointer: ["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"...","s3Key":"..."}]
+func parsePayloadPointer(body []byte) (payloadPointer, error) {
+	var parts []json.RawMessage
+	err := json.Unmarshal(bytes.TrimSpace(body), &parts)
+	if err != nil || len(parts) != 2 {
+		return payloadPointer{}, errors.Str("malformed offloaded payload pointer")
+	}
+
+	var class string
+	ptr := payloadPointer{}
+	if json.Unmarshal(parts[0], &class) != nil || class != payloadPointerClass || json.Unmarshal(parts[1], &ptr) != nil || ptr.Bucket == "" || ptr.Key == "" {
+		return payloadPointer{}, errors.Str("malformed offloaded payload pointer")
+	}
+
+	return ptr, nil
+}
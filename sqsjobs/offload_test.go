@@ -0,0 +1,202 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type memPayloadStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	deleted []string
+}
+
+func newMemPayloadStore() *memPayloadStore {
+	return &memPayloadStore{objects: make(map[string][]byte)}
+}
+
+func (s *memPayloadStore) Put(_ context.Context, bucket, key string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[bucket+"/"+key] = body
+	return nil
+}
+
+func (s *memPayloadStore) Get(_ context.Context, bucket, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.objects[bucket+"/"+key]
+	if !ok {
+		return nil, errors.Str("no such key")
+	}
+	return body, nil
+}
+
+func (s *memPayloadStore) Delete(_ context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, bucket+"/"+key)
+	s.deleted = append(s.deleted, bucket+"/"+key)
+	return nil
+}
+
+func TestOffloadRoundTrip(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	var err error
+	c.offload, err = newPayloadOffload("jobs", "rr/", false, 1024)
+	require.NoError(t, err)
+	store := newMemPayloadStore()
+	c.RegisterPayloadStore(store)
+	fc := newFakeClient()
+	c.client = fc
+
+	// below the threshold, sent as is
+	small := &Item{Job: "job", Ident: "small", Payload: []byte("small"), headers: map[string][]string{}, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), small))
+
+	large := &Item{Job: "job", Ident: "large", Payload: []byte(strings.Repeat("x", 2048)), headers: map[string][]string{}, Options: &Options{}}
+	require.NoError(t, c.handleItem(context.Background(), large))
+
+	require.Len(t, fc.sent, 2)
+	require.Equal(t, "small", aws.ToString(fc.sent[0].MessageBody))
+	require.NotContains(t, fc.sent[0].MessageAttributes, ExtendedPayloadSize)
+
+	sent := fc.sent[1]
+	require.Equal(t, "2048", aws.ToString(sent.MessageAttributes[ExtendedPayloadSize].StringValue))
+	ptr, err := parsePayloadPointer([]byte(aws.ToString(sent.MessageBody)))
+	require.NoError(t, err)
+	require.Equal(t, "jobs", ptr.Bucket)
+	require.True(t, strings.HasPrefix(ptr.Key, "rr/"))
+	require.Len(t, store.objects, 1)
+
+	// fetched on receive, deleted with the message
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, large.Payload, out.Payload)
+
+	require.NoError(t, out.Ack())
+	require.Equal(t, []string{"jobs/" + ptr.Key}, store.deleted)
+	require.Empty(t, store.objects)
+}
+
+func TestOffloadAlwaysThroughS3(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	var err error
+	c.offload, err = newPayloadOffload("jobs", "", true, 0)
+	require.NoError(t, err)
+	fc := newFakeClient()
+	c.client = fc
+
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("small"), headers: map[string][]string{}, Options: &Options{}}
+
+	// the store is not registered
+	err = c.handleItem(context.Background(), item)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "RegisterPayloadStore")
+	// the pipeline doesn't start
+	require.Error(t, c.Run(context.Background(), *c.pipeline.Load()))
+
+	c.RegisterPayloadStore(newMemPayloadStore())
+	require.NoError(t, c.handleItem(context.Background(), item))
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, "5", aws.ToString(fc.sent[0].MessageAttributes[ExtendedPayloadSize].StringValue))
+}
+
+func TestOffloadAttributes(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	var err error
+	c.offload, err = newPayloadOffload("jobs", "", false, 1024)
+	require.NoError(t, err)
+	require.NoError(t, c.offload.withAttributes(true))
+	store := newMemPayloadStore()
+	c.RegisterPayloadStore(store)
+	fc := newFakeClient()
+	c.client = fc
+
+	headers := make(map[string][]string, 20)
+	for i := range 20 {
+		headers["h"+strconv.Itoa(i)] = []string{strings.Repeat("v", 100)}
+	}
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: headers, Options: &Options{Priority: 5}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	// only the pointers are sent
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Len(t, sent.MessageAttributes, 2)
+	require.Contains(t, sent.MessageAttributes, ExtendedPayloadSize)
+	require.Contains(t, sent.MessageAttributes, OffloadedAttributes)
+	require.Len(t, store.objects, 2)
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, []byte("body"), out.Payload)
+	require.Equal(t, "id", out.ID())
+	require.Equal(t, "job", out.Job)
+	require.Equal(t, int64(5), out.Priority())
+	for k, v := range headers {
+		require.Equal(t, v, out.headers[k])
+	}
+
+	// both objects are deleted with the message
+	require.NoError(t, out.Ack())
+	require.Len(t, store.deleted, 2)
+	require.Empty(t, store.objects)
+
+	// s3_offload_attributes without the s3_bucket
+	var o *payloadOffload
+	require.Error(t, o.withAttributes(true))
+	require.NoError(t, o.withAttributes(false))
+}
+
+func TestFetchOffloaded(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	attrs := map[string]types.MessageAttributeValue{legacySQSLargePayloadSize: numAttr("4")}
+	pointer := `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"jobs","s3Key":"k"}]`
+
+	// the consumer without the store
+	_, _, err := c.fetchOffloaded(context.Background(), []byte(pointer), attrs)
+	require.Error(t, err)
+
+	store := newMemPayloadStore()
+	require.NoError(t, store.Put(context.Background(), "jobs", "k", []byte("body")))
+	c.RegisterPayloadStore(store)
+
+	body, drop, err := c.fetchOffloaded(context.Background(), []byte(pointer), attrs)
+	require.NoError(t, err)
+	require.Equal(t, []byte("body"), body)
+	require.NotNil(t, drop)
+
+	// not offloaded
+	body, drop, err = c.fetchOffloaded(context.Background(), []byte("plain"), nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), body)
+	require.Nil(t, drop)
+
+	_, _, err = c.fetchOffloaded(context.Background(), []byte(`["other",{}]`), attrs)
+	require.Error(t, err)
+}
+
+func TestNewPayloadOffload(t *testing.T) {
+	o, err := newPayloadOffload("", "", false, 0)
+	require.NoError(t, err)
+	require.Nil(t, o)
+
+	o, err = newPayloadOffload("jobs", "", false, 0)
+	require.NoError(t, err)
+	require.Equal(t, maxMessageBytes, o.threshold)
+
+	_, err = newPayloadOffload("", "", true, 0)
+	require.Error(t, err)
+
+	_, err = newPayloadOffload("jobs", "", false, maxMessageBytes+1)
+	require.Error(t, err)
+}
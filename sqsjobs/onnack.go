@@ -0,0 +1,153 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// OnNackHeader overrides the on_nack policy of the pipeline for the job: requeue, release or dlq
+	OnNackHeader string = "on_nack"
+	// NackDelayHeader overrides the nack_release_delay (in seconds) for the job
+	NackDelayHeader string = "nack_delay"
+
+	// on_nack policies
+	nackRequeue string = "requeue"
+	nackRelease string = "release"
+	nackDLQ     string = "dlq"
+)
+
+// nackPolicy is the way the nacked messages are handled: requeue - the message is sent again and the original one
+// is deleted (to the retry_queue if configured), release - the message stays in the queue and is visible again after
+// the delay (the nack_backoff if configured), dlq - the message is moved to the dead-letter queue.
+// The empty mode is the default: release with the nack_backoff, requeue otherwise.
+type nackPolicy struct {
+	mode  string
+	delay int32
+	// release with the nack_backoff instead of the delay
+	backoff bool
+}
+
+// newNackPolicy validates the on_nack and the nack_release_delay options
+func newNackPolicy(mode string, delay int, dlq, retryQueue, backoff bool) (nackPolicy, error) {
+	switch mode {
+	case "", nackRequeue, nackRelease, nackDLQ:
+	default:
+		return nackPolicy{}, errors.Errorf("unknown on_nack policy: %s, supported: requeue, release, dlq", mode)
+	}
+
+	if delay < 0 || delay > int(maxVisibilityTimeout) {
+		return nackPolicy{}, errors.Errorf("nack_release_delay should be in the range 0-%d, provided: %d", maxVisibilityTimeout, delay)
+	}
+
+	if mode == nackDLQ && !dlq {
+		return nackPolicy{}, errors.Str("on_nack: dlq requires the dead_letter_queue")
+	}
+
+	if retryQueue && (mode == nackRelease || mode == nackDLQ) {
+		return nackPolicy{}, errors.Errorf("retry_queue can't be combined with the on_nack: %s", mode)
+	}
+
+	if backoff && (mode == nackRequeue || mode == nackDLQ) {
+		return nackPolicy{}, errors.Errorf("nack_backoff can't be combined with the on_nack: %s", mode)
+	}
+
+	return nackPolicy{mode: mode, delay: int32(delay)}, nil //nolint:gosec
+}
+
+// nackPolicy returns the policy of the nacked message, the on_nack and the nack_delay headers override the pipeline one.
+// The malformed overrides are ignored.
+func (i *Item) nackPolicy() nackPolicy {
+	p := i.Options.onNack
+	p.backoff = i.Options.nackBackoff != nil
+
+	if v := headerValue(i.headers, OnNackHeader); v != "" {
+		switch {
+		case v == nackDLQ && i.Options.dlqFn == nil:
+			i.debug("on_nack header ignored, the dead-letter queue is not configured", zap.String("on_nack", v))
+		case v == nackRequeue || v == nackRelease || v == nackDLQ:
+			p.mode = v
+		default:
+			i.debug("unknown on_nack header ignored", zap.String("on_nack", v))
+		}
+	}
+
+	if p.mode == "" {
+		p.mode = nackRequeue
+		if p.backoff {
+			p.mode = nackRelease
+		}
+	}
+
+	// an explicit delay of the job is applied instead of the nack_backoff
+	if v := headerValue(i.headers, NackDelayHeader); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 || n > int64(maxVisibilityTimeout) {
+			i.debug("malformed nack_delay header ignored", zap.String("nack_delay", v))
+		} else {
+			p.delay = int32(n)
+			p.backoff = false
+		}
+	}
+
+	return p
+}
+
+// releaseNack returns the message to the queue, visible again after the delay (in seconds)
+func (i *Item) releaseNack(ctx context.Context, delay int32) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	_, err := i.Options.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          i.Options.queue,
+		ReceiptHandle:     i.Options.receipt.get(),
+		VisibilityTimeout: delay,
+	})
+	if err != nil {
+		if isNotInflight(err) {
+			i.Options.receipt.done()
+			i.debug("message is not in flight anymore (deleted or redelivered), the nack release is not applied")
+			return nil
+		}
+		return err
+	}
+
+	i.Options.receipt.done()
+	i.debug("message negatively acknowledged and released", zap.Int32("visibility_timeout", delay))
+
+	return nil
+}
+
+// nackToDLQ sends the nacked message to the dead-letter queue, the original message is deleted by the caller
+func (c *Driver) nackToDLQ(ctx context.Context, msg *Item) error {
+	msg.Options.Delay = 0
+
+	// the dead-letter queue of a FIFO queue is a FIFO queue as well
+	d, err := c.prepare(ctx, msg, c.dlqURL, c.queue)
+	if err != nil {
+		return err
+	}
+
+	if c.dlqEnrich {
+		c.enrichDLQ(&types.Message{
+			MessageId:  &msg.Options.messageID,
+			Attributes: map[string]string{ApproximateReceiveCount: strconv.FormatInt(msg.Options.approxReceiveCount, 10)},
+		}, d.MessageAttributes, errors.Str("message was nacked with the on_nack: dlq"))
+	}
+
+	c.observePayload(d)
+
+	// batching is bound to the pipeline queue
+	_, err = c.client.SendMessage(ctx, d, withDeadline(ctx, sendDeadlineMargin))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNackPolicy(t *testing.T) {
+	p, err := newNackPolicy("", 0, false, true, true)
+	require.NoError(t, err)
+	require.Equal(t, "", p.mode)
+
+	p, err = newNackPolicy(nackRelease, 30, false, false, false)
+	require.NoError(t, err)
+	require.Equal(t, nackRelease, p.mode)
+	require.Equal(t, int32(30), p.delay)
+
+	_, err = newNackPolicy("drop", 0, false, false, false)
+	require.Error(t, err)
+	_, err = newNackPolicy(nackRelease, 43201, false, false, false)
+	require.Error(t, err)
+	_, err = newNackPolicy(nackDLQ, 0, false, false, false)
+	require.Error(t, err)
+	_, err = newNackPolicy(nackDLQ, 0, true, true, false)
+	require.Error(t, err)
+	_, err = newNackPolicy(nackRequeue, 0, false, false, true)
+	require.Error(t, err)
+
+	_, err = newNackPolicy(nackRequeue, 0, false, true, false)
+	require.NoError(t, err)
+	_, err = newNackPolicy(nackRelease, 0, false, false, true)
+	require.NoError(t, err)
+}
+
+func nackedItem(t *testing.T, c *Driver, headers map[string][]string) *Item {
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:     aws.String("1"),
+		ReceiptHandle: aws.String("receipt-1"),
+		Body:          aws.String("body"),
+		Attributes:    map[string]string{ApproximateReceiveCount: "2"},
+	})
+	require.NoError(t, err)
+	for k, v := range headers {
+		item.headers[k] = v
+	}
+
+	return item
+}
+
+func TestOnNackRelease(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.onNack = nackPolicy{mode: nackRelease, delay: 30}
+
+	require.NoError(t, nackedItem(t, c, nil).Nack())
+	require.Equal(t, 0, fc.called("SendMessage"))
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+	require.Equal(t, int32(30), fc.visibility[0].VisibilityTimeout)
+
+	// the job delay
+	require.NoError(t, nackedItem(t, c, map[string][]string{NackDelayHeader: {"5"}}).Nack())
+	require.Equal(t, int32(5), fc.visibility[1].VisibilityTimeout)
+
+	// the job delay replaces the nack_backoff
+	var err error
+	c.nackBackoff, err = newNackBackoff(backoffLinear, 100, 0, false)
+	require.NoError(t, err)
+	c.onNack = nackPolicy{}
+	require.NoError(t, nackedItem(t, c, map[string][]string{NackDelayHeader: {"7"}}).Nack())
+	require.Equal(t, int32(7), fc.visibility[2].VisibilityTimeout)
+	require.NoError(t, nackedItem(t, c, map[string][]string{NackDelayHeader: {"-1"}}).Nack())
+	require.Equal(t, int32(200), fc.visibility[3].VisibilityTimeout)
+	require.Equal(t, 0, fc.called("SendMessage"))
+}
+
+func TestOnNackDLQ(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.dlqURL = ptr("http://127.0.0.1:9324/000000000000/dlq")
+	c.dlqEnrich = true
+	c.onNack = nackPolicy{mode: nackDLQ}
+
+	require.NoError(t, nackedItem(t, c, nil).Nack())
+	require.Equal(t, 1, fc.called("SendMessage"))
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/dlq", aws.ToString(fc.sent[0].QueueUrl))
+	require.Equal(t, "body", aws.ToString(fc.sent[0].MessageBody))
+	require.Equal(t, "test", aws.ToString(fc.sent[0].MessageAttributes[DLQOriginalQueue].StringValue))
+	require.Equal(t, "2", aws.ToString(fc.sent[0].MessageAttributes[DLQReceiveCount].StringValue))
+
+	// the job requeue overrides the pipeline policy
+	require.NoError(t, nackedItem(t, c, map[string][]string{OnNackHeader: {nackRequeue}}).Nack())
+	require.Equal(t, 2, fc.called("SendMessage"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test", aws.ToString(fc.sent[1].QueueUrl))
+}
+
+func TestOnNackHeader(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	// the dead-letter queue is not configured, the header is ignored
+	require.NoError(t, nackedItem(t, c, map[string][]string{OnNackHeader: {nackDLQ}}).Nack())
+	require.Equal(t, 1, fc.called("SendMessage"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test", aws.ToString(fc.sent[0].QueueUrl))
+
+	require.NoError(t, nackedItem(t, c, map[string][]string{OnNackHeader: {nackRelease}}).Nack())
+	require.Equal(t, 1, fc.called("SendMessage"))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+	require.Equal(t, int32(0), fc.visibility[0].VisibilityTimeout)
+
+	require.NoError(t, nackedItem(t, c, map[string][]string{OnNackHeader: {"unknown"}}).Nack())
+	require.Equal(t, 2, fc.called("SendMessage"))
+}
@@ -0,0 +1,108 @@
+package sqsjobs
+
+import (
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// orderGate keeps the receive order of the dispatched messages (use_priority_queue: false). The jobs priority queue
+// is a heap and doesn't keep the insertion order of the equal priorities, so at most one message of the pipeline waits
+// in it: the next one is inserted once the previous one is taken by a worker.
+type orderGate struct {
+	mu sync.Mutex
+	// a message of the pipeline is waiting in the priority queue
+	queued bool
+	// waiting for the queued one to be taken, in the receive order
+	pending []*Item
+}
+
+// checkReceiveOrder validates the use_priority_queue option, nil means enabled (default).
+// With the priority queue disabled the messages are dispatched in the receive order with the pipeline priority,
+// the gate is nil otherwise. The priority hints can't be used in this mode.
+func checkReceiveOrder(usePriorityQueue *bool, priorityAttr string) (*orderGate, error) {
+	if priorityQueueEnabled(usePriorityQueue) {
+		return nil, nil
+	}
+
+	if priorityAttr != "" {
+		return nil, errors.Errorf("priority_attribute (%s) can't be used with use_priority_queue: false, the messages are dispatched in the receive order", priorityAttr)
+	}
+
+	return &orderGate{}, nil
+}
+
+func priorityQueueEnabled(v *bool) bool {
+	return v == nil || *v
+}
+
+// push returns true if the item might be inserted into the priority queue right away, it is kept pending otherwise
+func (g *orderGate) push(item *Item) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.queued {
+		g.queued = true
+		return true
+	}
+
+	g.pending = append(g.pending, item)
+	return false
+}
+
+// next returns the next pending item once the queued one is taken, nil if there are no pending items
+func (g *orderGate) next() *Item {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.pending) == 0 {
+		g.queued = false
+		return nil
+	}
+
+	item := g.pending[0]
+	g.pending[0] = nil
+	g.pending = g.pending[1:]
+
+	return item
+}
+
+// drain returns all pending items
+func (g *orderGate) drain() []*Item {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := g.pending
+	g.pending = nil
+	g.queued = false
+
+	return pending
+}
+
+// enqueue inserts the item into the priority queue, in the receive order if the use_priority_queue is disabled
+func (c *Driver) enqueue(item *Item) {
+	if c.order == nil {
+		c.pq.Insert(item)
+		return
+	}
+
+	if !c.order.push(item) {
+		return
+	}
+
+	c.insertOrdered(item)
+}
+
+// insertOrdered inserts the item, the next pending one follows on the worker pickup
+func (c *Driver) insertOrdered(item *Item) {
+	var once sync.Once
+	item.Options.pickup = func() {
+		once.Do(func() {
+			if next := c.order.next(); next != nil {
+				c.insertOrdered(next)
+			}
+		})
+	}
+
+	c.pq.Insert(item)
+}
@@ -0,0 +1,62 @@
+package sqsjobs
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiveOrder(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.order = &orderGate{}
+	fc := newFakeClient()
+	c.client = fc
+
+	msgs := make([]types.Message, 0, 10)
+	for i := 0; i < 10; i++ {
+		id := strconv.Itoa(i)
+		msgs = append(msgs, types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id), Body: aws.String(id), MessageAttributes: map[string]types.MessageAttributeValue{
+			// reversed priorities are ignored
+			jobs.RRPriority: {DataType: aws.String(NumberType), StringValue: aws.String(strconv.Itoa(100 - i))},
+		}})
+	}
+	fc.receiveFn = receiveOnce(msgs...)
+
+	stop := runListener(c)
+	defer stop()
+
+	// one message at a time waits in the priority queue, the next one is inserted on the worker pickup
+	for i := 0; i < 10; i++ {
+		require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+		item := pq.ExtractMin().(*Item)
+		require.Equal(t, strconv.Itoa(i), string(item.Body()))
+		require.Equal(t, int64(10), item.Priority())
+		if i == 0 {
+			require.Equal(t, uint64(0), pq.Len())
+		}
+		_, err := item.Context()
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, uint64(0), pq.Len())
+	require.Empty(t, c.order.drain())
+}
+
+func TestCheckReceiveOrder(t *testing.T) {
+	g, err := checkReceiveOrder(nil, "priority")
+	require.NoError(t, err)
+	require.Nil(t, g)
+
+	g, err = checkReceiveOrder(ptr(false), "")
+	require.NoError(t, err)
+	require.NotNil(t, g)
+
+	_, err = checkReceiveOrder(ptr(false), "priority")
+	require.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package sqsjobs
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+// AttrOverflow is the message attribute with the attributes which didn't fit into the SQS limit, bundled as a JSON object.
+// The attributes are restored on receive, the third-party consumers see only the bundle.
+const AttrOverflow string = "X-RR-Attr-Overflow"
+
+// overflowAttr is the JSON representation of the spilled message attribute
+type overflowAttr struct {
+	DataType string  `json:"type"`
+	String   *string `json:"string,omitempty"`
+	Binary   []byte  `json:"binary,omitempty"`
+}
+
+// spillAttributes moves the attributes exceeding the SQS limit into the AttrOverflow attribute.
+// The RR metadata, Content-Encryption and the keep (header_attributes) attributes are never spilled, the rest is kept
+// in the name order.
+func spillAttributes(in *sqs.SendMessageInput, keep ...string) error {
+	if len(in.MessageAttributes) <= maxMessageAttributes {
+		return nil
+	}
+
+	if _, ok := in.MessageAttributes[AttrOverflow]; ok {
+		return errors.Errorf("%s message attribute is reserved", AttrOverflow)
+	}
+
+	names := make([]string, 0, len(in.MessageAttributes))
+	// one slot is taken by the overflow attribute
+	room := maxMessageAttributes - 1
+	for k := range in.MessageAttributes {
+		if pinnedAttr(k) || slices.Contains(keep, k) {
+			room--
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	if room < 0 {
+		return errors.Errorf("the pinned message attributes (the RR metadata and the header_attributes) exceed the limit of %d attributes", maxMessageAttributes)
+	}
+
+	spilled := make(map[string]overflowAttr, len(names)-room)
+	for _, k := range names[room:] {
+		v := in.MessageAttributes[k]
+		spilled[k] = overflowAttr{DataType: getordefault(v.DataType), String: v.StringValue, Binary: v.BinaryValue}
+		delete(in.MessageAttributes, k)
+	}
+
+	data, err := json.Marshal(spilled)
+	if err != nil {
+		return err
+	}
+
+	in.MessageAttributes[AttrOverflow] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(bytesToStr(data))}
+	return nil
+}
+
+// pinnedAttr returns true for the attributes which must stay top-level
+func pinnedAttr(name string) bool {
+	switch name {
+	case jobs.RRID, jobs.RRJob, jobs.RRDelay, jobs.RRHeaders, jobs.RRPriority, jobs.RRAutoAck, RRMeta, ContentEncryptionAttr:
+		return true
+	default:
+		return false
+	}
+}
+
+// expandOverflow restores the spilled attributes. The original map is not modified.
+// Attributes without the overflow bundle are returned as is.
+func expandOverflow(attrs map[string]types.MessageAttributeValue) (map[string]types.MessageAttributeValue, error) {
+	val, ok := attrs[AttrOverflow]
+	if !ok {
+		return attrs, nil
+	}
+
+	spilled := make(map[string]overflowAttr)
+	err := json.Unmarshal([]byte(getordefault(val.StringValue)), &spilled)
+	if err != nil {
+		return nil, errors.Errorf("failed to unpack the %s attribute: %v", AttrOverflow, err)
+	}
+
+	ret := make(map[string]types.MessageAttributeValue, len(attrs)+len(spilled))
+	for k, v := range spilled {
+		ret[k] = types.MessageAttributeValue{DataType: aws.String(v.DataType), StringValue: v.String, BinaryValue: v.Binary}
+	}
+
+	for k, v := range attrs {
+		if k == AttrOverflow {
+			continue
+		}
+		ret[k] = v
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,68 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeOverflowRoundTrip(t *testing.T) {
+	attrs := make(map[string]types.MessageAttributeValue, 15)
+	for i := 0; i < 14; i++ {
+		attrs["attr"+strconv.Itoa(i)] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String("v" + strconv.Itoa(i))}
+	}
+	attrs["bin"] = types.MessageAttributeValue{DataType: aws.String(BinaryType), BinaryValue: []byte{0xff, 0x00}}
+
+	in := &sqs.SendMessageInput{MessageAttributes: attrs}
+	require.NoError(t, spillAttributes(in))
+	require.Len(t, in.MessageAttributes, maxMessageAttributes)
+	require.Contains(t, in.MessageAttributes, AttrOverflow)
+
+	out, err := expandOverflow(in.MessageAttributes)
+	require.NoError(t, err)
+	require.Len(t, out, 15)
+	for i := 0; i < 14; i++ {
+		require.Equal(t, "v"+strconv.Itoa(i), aws.ToString(out["attr"+strconv.Itoa(i)].StringValue))
+	}
+	require.Equal(t, []byte{0xff, 0x00}, out["bin"].BinaryValue)
+	require.Equal(t, BinaryType, aws.ToString(out["bin"].DataType))
+
+	// within the limit - untouched
+	small := &sqs.SendMessageInput{MessageAttributes: map[string]types.MessageAttributeValue{"a": {DataType: aws.String(StringType), StringValue: aws.String("v")}}}
+	require.NoError(t, spillAttributes(small))
+	require.NotContains(t, small.MessageAttributes, AttrOverflow)
+}
+
+func TestAttributeOverflowPush(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	// 6 RR attributes + 9 binary headers
+	headers := make(map[string][]string, 9)
+	for i := 0; i < 9; i++ {
+		headers["bin"+strconv.Itoa(i)] = []string{string([]byte{0xff, byte(i)})}
+	}
+	require.NoError(t, c.handleItem(context.Background(), &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: headers, Options: &Options{}}))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Len(t, sent.MessageAttributes, maxMessageAttributes)
+	// the RR metadata is never spilled
+	for _, k := range []string{jobs.RRID, jobs.RRJob, jobs.RRDelay, jobs.RRHeaders, jobs.RRPriority, jobs.RRAutoAck} {
+		require.Contains(t, sent.MessageAttributes, k)
+	}
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, "id", out.ID())
+	for i := 0; i < 9; i++ {
+		require.Equal(t, []string{string([]byte{0xff, byte(i)})}, out.headers["bin"+strconv.Itoa(i)])
+	}
+}
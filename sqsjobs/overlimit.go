@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// overLimit is returned by ReceiveMessage when the queue reaches the in-flight messages quota
+	// (~120,000 for the standard queues, 20,000 for the FIFO queues)
+	overLimit string = "OverLimit"
+
+	defaultInFlightLimitBackoff = time.Second * 5
+	maxInFlightLimitBackoff     = time.Minute
+)
+
+func isOverLimit(err error) bool {
+	var apiErr smithy.APIError
+	return stderr.As(err, &apiErr) && apiErr.ErrorCode() == overLimit
+}
+
+// inFlightLimitBackoff converts the in_flight_limit_backoff option (seconds), 0 or negative - the default
+func inFlightLimitBackoff(sec int) time.Duration {
+	if sec <= 0 {
+		return defaultInFlightLimitBackoff
+	}
+
+	return time.Duration(sec) * time.Second
+}
+
+// waitInFlightLimit pauses the poller after the OverLimit receive: the receives can't succeed until the in-flight messages
+// are acknowledged or become visible again, so the poller backs off (doubling up to a minute) instead of spinning on
+// the errors. backoff is the poller's current delay, 0 - the first OverLimit. Returns true if the listener was stopped.
+func (c *Driver) waitInFlightLimit(ctx context.Context, backoff *time.Duration) bool {
+	atomic.AddUint64(&c.inFlightLimitHits, 1)
+
+	if *backoff == 0 {
+		*backoff = c.overLimitBackoff
+		if atomic.AddInt32(&c.inFlightLimited, 1) == 1 {
+			c.log.Warn("the queue reached the in-flight messages limit (OverLimit), the receive is paused until the in-flight messages are processed; consider a shorter visibility timeout or more workers",
+				zap.Stringp("queue", c.queue), zap.Duration("backoff", *backoff))
+		}
+	} else {
+		*backoff = max(min(*backoff*2, maxInFlightLimitBackoff), c.overLimitBackoff)
+	}
+
+	select {
+	case <-ctx.Done():
+		return true
+	case <-time.After(*backoff):
+		return false
+	}
+}
+
+// resumeInFlightLimit resets the poller backoff after the successful receive (or the listener stop)
+func (c *Driver) resumeInFlightLimit(backoff *time.Duration) {
+	if *backoff == 0 {
+		return
+	}
+
+	*backoff = 0
+	if atomic.AddInt32(&c.inFlightLimited, -1) == 0 {
+		c.log.Info("the in-flight messages dropped below the limit, the receive is resumed", zap.Stringp("queue", c.queue))
+	}
+}
+
+// InFlightLimitReached returns the number of the OverLimit receive responses since the pipeline start
+func (c *Driver) InFlightLimitReached() uint64 {
+	return atomic.LoadUint64(&c.inFlightLimitHits)
+}
@@ -0,0 +1,86 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlightLimitBackoff(t *testing.T) {
+	const limited = 3
+
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.overLimitBackoff = time.Millisecond * 50
+
+	var mu sync.Mutex
+	var calls []time.Time
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		mu.Lock()
+		calls = append(calls, time.Now())
+		n := len(calls)
+		mu.Unlock()
+
+		switch {
+		case n <= limited:
+			return nil, &smithy.GenericAPIError{Code: overLimit, Message: "too many messages in flight"}
+		case n == limited+1:
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("1")}}}, nil
+		default:
+			time.Sleep(time.Millisecond * 10)
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&c.inFlightLimited) == 1 }, time.Second*5, time.Millisecond)
+	// the receive resumes once the in-flight messages dropped
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.inFlightLimited))
+	require.Equal(t, uint64(limited), c.InFlightLimitReached())
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(limited), st.InFlightLimitReached)
+	require.False(t, st.InFlightLimited)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 50ms, 100ms and 200ms between the receives instead of spinning on the errors
+	for i, want := range []time.Duration{50, 100, 200} {
+		require.GreaterOrEqual(t, calls[i+1].Sub(calls[i]), want*time.Millisecond)
+	}
+}
+
+func TestInFlightLimitOptions(t *testing.T) {
+	require.Equal(t, defaultInFlightLimitBackoff, inFlightLimitBackoff(0))
+	require.Equal(t, time.Second*2, inFlightLimitBackoff(2))
+
+	require.True(t, isOverLimit(&smithy.GenericAPIError{Code: overLimit}))
+	require.False(t, isOverLimit(&smithy.GenericAPIError{Code: "RequestThrottled"}))
+
+	// the backoff is capped, but never below the configured one
+	c := newTestDriver(&testQueue{}, nil)
+	c.overLimitBackoff = time.Minute * 2
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var backoff time.Duration
+	require.True(t, c.waitInFlightLimit(ctx, &backoff))
+	require.True(t, c.waitInFlightLimit(ctx, &backoff))
+	require.Equal(t, time.Minute*2, backoff)
+	c.resumeInFlightLimit(&backoff)
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.inFlightLimited))
+}
@@ -0,0 +1,50 @@
+package sqsjobs
+
+import (
+	"context"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// recoverMessage recovers a panic in the message handling, must be deferred by the handler.
+// The message which was not dispatched yet is returned to the queue (visibility timeout reset to 0).
+func (c *Driver) recoverMessage(ctx context.Context, msg *types.Message, locked, dispatched *bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if *locked {
+		c.cond.L.Unlock()
+	}
+
+	atomic.AddUint64(&c.panics, 1)
+	c.log.Error("panic while handling the message, recovered", zap.Stringp("ID", msg.MessageId), zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+
+	if *dispatched {
+		return
+	}
+
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.ChangeMessageVisibility(ctxT, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queueURL,
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: 0,
+	})
+	if err != nil && !isNotInflight(err) {
+		c.log.Error("failed to return the message to the queue after the panic", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+	}
+}
+
+// RecoveredPanics returns the number of the panics recovered in the message handling
+func (c *Driver) RecoveredPanics() uint64 {
+	return atomic.LoadUint64(&c.panics)
+}
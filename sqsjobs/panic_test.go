@@ -0,0 +1,58 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerRecoversPanic(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.dedup = newDedupSet(time.Minute, 0)
+	c.bodyFormat = formatBinary
+	c.RegisterBodyDecoder("application/x-boom", func(body []byte) ([]byte, error) {
+		if string(body) == "boom" {
+			panic("decoder failure")
+		}
+		return body, nil
+	})
+
+	boom := map[string]types.MessageAttributeValue{contentTypeAttr: {DataType: aws.String(StringType), StringValue: aws.String("application/x-boom")}}
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("boom"), MessageAttributes: boom},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("ok"), MessageAttributes: boom},
+	)
+	c.client = fc
+
+	stop := runListener(c)
+	// the listener survived the panic and handled the next message
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, int32(1), atomic.LoadInt32(&c.activePollers))
+	stop()
+
+	require.Equal(t, "ok", string(pq.ExtractMin().Body()))
+	require.Equal(t, uint64(1), c.RecoveredPanics())
+
+	// the message was returned to the queue
+	fc.mu.Lock()
+	require.Len(t, fc.visibility, 1)
+	require.Equal(t, "receipt-1", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(0), fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+
+	// the redelivered message is not suppressed as a duplicate
+	require.False(t, c.isDuplicate(&types.Message{MessageId: aws.String("1")}))
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), st.RecoveredPanics)
+}
@@ -0,0 +1,68 @@
+package sqsjobs
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// PartitionKeyHeader is the job header with the logical partition key (the jobs API has no custom job options)
+	PartitionKeyHeader string = "partition_key"
+	// partition key message attribute, might be changed with partition_key_attribute
+	defaultPartitionKeyAttr string = "partition_key"
+)
+
+// headerValue returns the first value of the header, exact match first, then the canonical one
+func headerValue(h map[string][]string, name string) string {
+	if v, ok := h[name]; ok && len(v) > 0 {
+		return v[0]
+	}
+
+	return http.Header(h).Get(name)
+}
+
+// partitionAttribute writes the partition key to the message attribute
+func (c *Driver) partitionAttribute(item *Item, in *sqs.SendMessageInput) error {
+	if item.Options.PartitionKey == "" {
+		return nil
+	}
+
+	name := c.partitionKeyAttr()
+	if _, ok := in.MessageAttributes[name]; ok {
+		return errors.Errorf("partition key attribute %s conflicts with the existing message attribute", name)
+	}
+
+	in.MessageAttributes[name] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(item.Options.PartitionKey)}
+	return nil
+}
+
+// readPartitionKey reads the partition key from the message attribute or the headers, the key is added to the headers (if absent),
+// so the workers see it
+func (c *Driver) readPartitionKey(attrs map[string]types.MessageAttributeValue, h map[string][]string) string {
+	var key string
+	if attr, ok := attrs[c.partitionKeyAttr()]; ok && attr.StringValue != nil {
+		key = *attr.StringValue
+	}
+
+	if key == "" {
+		return headerValue(h, PartitionKeyHeader)
+	}
+
+	if headerValue(h, PartitionKeyHeader) == "" {
+		h[PartitionKeyHeader] = []string{key}
+	}
+
+	return key
+}
+
+func (c *Driver) partitionKeyAttr() string {
+	if c.partitionAttr == "" {
+		return defaultPartitionKeyAttr
+	}
+
+	return c.partitionAttr
+}
@@ -0,0 +1,62 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionKeyRoundTrip(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.partitionAttr = "tenant"
+	fc := newFakeClient()
+	c.client = fc
+
+	headers := map[string][]string{PartitionKeyHeader: {"acme"}}
+	item := &Item{Job: "job", Ident: "id", Payload: []byte("body"), headers: headers, Options: &Options{PartitionKey: headerValue(headers, PartitionKeyHeader)}}
+	require.NoError(t, c.handleItem(context.Background(), item))
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, "acme", aws.ToString(sent.MessageAttributes["tenant"].StringValue))
+
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: sent.MessageBody, MessageAttributes: sent.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, "acme", out.Options.PartitionKey)
+	require.Equal(t, []string{"acme"}, out.headers[PartitionKeyHeader])
+
+	// third-party message, attribute only
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), Body: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		"tenant": {DataType: aws.String(StringType), StringValue: aws.String("globex")},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "globex", out.Options.PartitionKey)
+	require.Equal(t, []string{"globex"}, out.headers[PartitionKeyHeader])
+}
+
+func TestPartitionKeyGroups(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.groups = newGroupGate(0)
+
+	for _, key := range []string{"a", "a", "b"} {
+		item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String(key), Body: aws.String(key), MessageAttributes: map[string]types.MessageAttributeValue{
+			defaultPartitionKeyAttr: {DataType: aws.String(StringType), StringValue: aws.String(key)},
+		}})
+		require.NoError(t, err)
+		c.dispatch(item)
+	}
+
+	// standard queue, ordered by the partition key
+	require.ElementsMatch(t, []string{"a", "b"}, bodies(pq))
+
+	// no key - no attribute
+	in, err := (&Item{Options: &Options{}}).pack(c.queueURL, c.queue, "", false)
+	require.NoError(t, err)
+	require.NoError(t, c.partitionAttribute(&Item{Options: &Options{}}, in))
+	require.NotContains(t, in.MessageAttributes, defaultPartitionKeyAttr)
+}
@@ -0,0 +1,123 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+)
+
+// pausePollersTimeout limits the wait for the canceled receives on pause
+const pausePollersTimeout = time.Second * 30
+
+// paused reports whether the pipeline is paused (not stopped)
+func (c *Driver) paused() bool {
+	return atomic.LoadUint32(&c.listeners) == 0 && atomic.LoadUint64(&c.stopped) == 0
+}
+
+// waitPollers waits for the canceled pollers to exit, false if the context is done first
+func (c *Driver) waitPollers(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for atomic.LoadInt32(&c.activePollers) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	return true
+}
+
+// releaseOnPause waits for the in-progress long polls to be canceled and returns the messages buffered in the dispatch
+// buffer to the queue (visibility timeout 0), so the paused pipeline holds no messages. The messages already in the
+// priority queue are processed by the workers.
+func (c *Driver) releaseOnPause(ctx context.Context, pipeline string) {
+	ctxT, cancel := context.WithTimeout(ctx, pausePollersTimeout)
+	defer cancel()
+
+	if !c.waitPollers(ctxT) {
+		c.log.Warn("pause timeout, the pollers are still running", zap.String("pipeline", pipeline), zap.Int32("pollers", atomic.LoadInt32(&c.activePollers)))
+	}
+
+	if c.dispatchCh == nil {
+		return
+	}
+
+	var released int
+	for {
+		select {
+		case item := <-c.dispatchCh:
+			if c.releaseBuffered(ctxT, item) {
+				released++
+			}
+		default:
+			c.log.Debug("buffered messages were returned to the queue on pause", zap.String("pipeline", pipeline), zap.Int("released", released))
+			return
+		}
+	}
+}
+
+// releaseBuffered returns the not dispatched message to the queue, the message is not in flight anymore
+func (c *Driver) releaseBuffered(ctx context.Context, item *Item) bool {
+	// auto-acked messages are already deleted, dispatched as usual
+	if item.Options.AutoAck || item.Options.receipt.get() == nil {
+		c.enqueue(item)
+		return false
+	}
+
+	return c.returnItem(ctx, item, 0)
+}
+
+// returnItem makes the not dispatched message visible again after the visibility timeout (in seconds)
+func (c *Driver) returnItem(ctx context.Context, item *Item, visibility int32) bool {
+	handle := item.Options.receipt.get()
+	if handle == nil {
+		return false
+	}
+
+	// already returned to the queue on the handler_timeout
+	if !item.Options.watchdog.claim() {
+		return false
+	}
+	item.Options.heartbeat.stop()
+
+	defer func() {
+		item.Options.releaseBytes()
+		item.Options.cond.Signal()
+		atomic.AddInt64(item.Options.msgInFlight, ^int64(0))
+		if item.Options.release != nil {
+			item.Options.release()
+		}
+	}()
+
+	_, err := item.Options.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          item.Options.queue,
+		ReceiptHandle:     handle,
+		VisibilityTimeout: visibility,
+	})
+	if err != nil && !isNotInflight(err) {
+		c.log.Warn("failed to return the buffered message to the queue", zap.String("ID", item.ID()), zap.Error(err))
+		return false
+	}
+
+	item.Options.receipt.done()
+	return true
+}
+
+// fillPaused sets the paused state and the pause/resume timestamps of the stats
+func (c *Driver) fillPaused(st *Stats) {
+	st.Paused = c.paused() && atomic.LoadInt64(&c.pausedAt) > 0
+
+	if at := atomic.LoadInt64(&c.pausedAt); at > 0 {
+		st.PausedAt = ptr(time.Unix(0, at).UTC())
+	}
+
+	if at := atomic.LoadInt64(&c.resumedAt); at > 0 {
+		st.ResumedAt = ptr(time.Unix(0, at).UTC())
+	}
+}
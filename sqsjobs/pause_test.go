@@ -0,0 +1,69 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseCancelsLongPolls(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	var polling, receives int32
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		atomic.AddInt32(&receives, 1)
+		atomic.AddInt32(&polling, 1)
+		defer atomic.AddInt32(&polling, -1)
+		// the long poll returns only when canceled
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&polling) == 1 }, time.Second*5, time.Millisecond*5)
+
+	// the received message waiting in the dispatch buffer
+	c.dispatchCh = make(chan *Item, dispatchBufferSize(1))
+	item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("handle"), Body: aws.String("body")})
+	require.NoError(t, err)
+	atomic.AddInt64(c.msgInFlight, 1)
+	c.dispatchCh <- item
+
+	require.NoError(t, c.Pause(context.Background(), "test"))
+	require.Equal(t, int32(0), atomic.LoadInt32(&polling))
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.activePollers))
+	require.Empty(t, c.dispatchCh)
+	require.Equal(t, int64(0), atomic.LoadInt64(c.msgInFlight))
+	require.Len(t, fc.visibility, 1)
+	require.Equal(t, int32(0), fc.visibility[0].VisibilityTimeout)
+
+	// no receives while paused
+	n := atomic.LoadInt32(&receives)
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, n, atomic.LoadInt32(&receives))
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.True(t, st.Paused)
+	require.False(t, st.Ready)
+	require.NotNil(t, st.PausedAt)
+	require.Nil(t, st.ResumedAt)
+
+	require.NoError(t, c.Resume(context.Background(), "test"))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&polling) == 1 }, time.Second*5, time.Millisecond*5)
+
+	st, err = c.Stats(context.Background())
+	require.NoError(t, err)
+	require.False(t, st.Paused)
+	require.NotNil(t, st.ResumedAt)
+
+	require.NoError(t, c.Stop(context.Background()))
+}
@@ -0,0 +1,58 @@
+package sqsjobs
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+)
+
+// payloadSizeBuckets are the payload size histogram upper bounds (in bytes), denser close to the 256 KiB limit
+var payloadSizeBuckets = []float64{1024, 4096, 16384, 65536, 131072, 196608, 229376, 262144}
+
+// payloadMetrics is the sent payload size histogram and the warning threshold (payload_warn_bytes)
+type payloadMetrics struct {
+	warnBytes int
+	size      *histogram
+}
+
+// newPayloadMetrics returns nil if both the latency_metrics and the payload_warn_bytes are disabled
+func newPayloadMetrics(enabled bool, warnBytes int) *payloadMetrics {
+	if warnBytes < 0 {
+		warnBytes = 0
+	}
+
+	if !enabled && warnBytes == 0 {
+		return nil
+	}
+
+	return &payloadMetrics{warnBytes: warnBytes, size: newHistogram(payloadSizeBuckets)}
+}
+
+// snapshot returns the payload size histogram, nil if disabled
+func (m *payloadMetrics) snapshot() *Histogram {
+	if m == nil {
+		return nil
+	}
+
+	return m.size.snapshot()
+}
+
+// observePayload records the size of the message about to be sent (the body and the attributes, as counted by SQS)
+// and logs a warning above the payload_warn_bytes
+func (c *Driver) observePayload(d *sqs.SendMessageInput) {
+	if c.payload == nil {
+		return
+	}
+
+	size := messageSize(d)
+	c.payload.size.observeValue(float64(size))
+
+	if c.payload.warnBytes > 0 && size > c.payload.warnBytes {
+		c.log.Warn("message payload is approaching the SQS size limit",
+			zap.Int("size", size),
+			zap.Int("payload_warn_bytes", c.payload.warnBytes),
+			zap.Int("limit", maxMessageBytes),
+			zap.Int("body", len(getordefault(d.MessageBody))),
+			zap.Int("attributes", len(d.MessageAttributes)),
+		)
+	}
+}
@@ -0,0 +1,56 @@
+package sqsjobs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPayloadWarnBytes(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	core, logs := observer.New(zapcore.WarnLevel)
+	c.log = zap.New(core)
+	c.payload = newPayloadMetrics(false, 1000)
+
+	small := &sqs.SendMessageInput{QueueUrl: c.queueURL, MessageBody: aws.String("hello")}
+	require.NoError(t, c.send(context.Background(), small))
+	require.Equal(t, 0, logs.FilterMessageSnippet("size limit").Len())
+
+	// the body is below the threshold, the attributes push the message above it
+	large := &sqs.SendMessageInput{
+		QueueUrl:    c.queueURL,
+		MessageBody: aws.String(strings.Repeat("a", 900)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"rr_headers": {DataType: aws.String(StringType), StringValue: aws.String(strings.Repeat("h", 200))},
+		},
+	}
+	require.NoError(t, c.send(context.Background(), large))
+
+	warns := logs.FilterMessageSnippet("size limit").All()
+	require.Len(t, warns, 1)
+	require.Equal(t, int64(messageSize(large)), warns[0].ContextMap()["size"])
+	require.Equal(t, int64(1000), warns[0].ContextMap()["payload_warn_bytes"])
+
+	h := c.payload.snapshot()
+	require.Equal(t, uint64(2), h.Count)
+	require.Equal(t, float64(5+messageSize(large)), h.Sum)
+	// 5 bytes <= 1 KiB, 1.1 KB <= 4 KiB
+	require.Equal(t, uint64(1), h.Counts[0])
+	require.Equal(t, uint64(2), h.Counts[1])
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), st.PayloadSize.Count)
+
+	// disabled
+	require.Nil(t, newPayloadMetrics(false, 0))
+	require.NotNil(t, newPayloadMetrics(true, 0))
+}
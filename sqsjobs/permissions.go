@@ -0,0 +1,74 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const permissionCheckTimeout = time.Second * 30
+
+// IAM actions used by the driver
+const requiredActions string = "sqs:GetQueueUrl, sqs:CreateQueue (if the queue is declared by RR), sqs:TagQueue and sqs:ListQueueTags (if tags are set), sqs:SendMessage, sqs:ReceiveMessage, sqs:DeleteMessage, sqs:ChangeMessageVisibility, sqs:GetQueueAttributes"
+
+// isAccessDenied checks whether the error is an AccessDenied API error
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "AccessDenied", "AccessDeniedException", "AWS.SimpleQueueService.AccessDenied", "KMS.AccessDeniedException":
+		return true
+	default:
+		return false
+	}
+}
+
+// checkPermissions makes a single receive call to make sure that the queue is readable with the current credentials.
+// GetQueueUrl might succeed while ReceiveMessage is denied, in that case the listener would spin on errors forever.
+func (c *Driver) checkPermissions(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, permissionCheckTimeout)
+	defer cancel()
+
+	out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            c.queueURL,
+		MaxNumberOfMessages: 1,
+		WaitTimeSeconds:     0,
+	})
+	if err != nil {
+		if isAccessDenied(err) {
+			return errors.Errorf("access denied to the queue %s, the credentials should allow the following IAM actions: %s; error: %v", getordefault(c.queueURL), requiredActions, err)
+		}
+
+		if isInvalidAddress(err) {
+			return addressError(err, c.queueURL)
+		}
+
+		return errors.Errorf("startup receive check failed for the queue %s: %v", getordefault(c.queueURL), err)
+	}
+
+	// the check should not hide messages from the consumers, return them to the queue right away
+	for i := 0; i < len(out.Messages); i++ {
+		_, err = c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          c.queueURL,
+			ReceiptHandle:     out.Messages[i].ReceiptHandle,
+			VisibilityTimeout: 0,
+		})
+		if err != nil {
+			if isAccessDenied(err) {
+				return errors.Errorf("access denied to change the message visibility in the queue %s, the credentials should allow the following IAM actions: %s; error: %v", getordefault(c.queueURL), requiredActions, err)
+			}
+
+			c.log.Warn("failed to return the message received by the startup check", zap.Stringp("ID", out.Messages[i].MessageId), zap.Error(err))
+		}
+	}
+
+	return nil
+}
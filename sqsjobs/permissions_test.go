@@ -0,0 +1,65 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPermissionsAccessDenied(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return nil, &smithy.OperationError{
+			ServiceID:     "SQS",
+			OperationName: "ReceiveMessage",
+			Err:           &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: sqs:receivemessage"},
+		}
+	}
+	c.client = fc
+
+	err := c.checkPermissions(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "access denied")
+	require.Contains(t, err.Error(), "sqs:ReceiveMessage")
+	require.Contains(t, err.Error(), "sqs:DeleteMessage")
+	require.Equal(t, 1, fc.called("ReceiveMessage"))
+}
+
+func TestCheckPermissionsOtherError(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "InternalError"}
+	}
+	c.client = fc
+
+	err := c.checkPermissions(context.Background())
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "access denied")
+}
+
+func TestCheckPermissionsReturnsMessages(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	var in *sqs.ReceiveMessageInput
+	fc.receiveFn = func(_ context.Context, params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		in = params
+		return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("rh")}}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.checkPermissions(context.Background()))
+	require.Equal(t, int32(1), in.MaxNumberOfMessages)
+	require.Equal(t, int32(0), in.WaitTimeSeconds)
+
+	// received message should be visible again for the consumers
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+	require.Equal(t, "rh", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(0), fc.visibility[0].VisibilityTimeout)
+}
@@ -0,0 +1,96 @@
+package sqsjobs
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// the system attributes which might be requested with the ReceiveMessage call
+var systemAttributeNames = []string{
+	All, "SenderId", SentTimestamp, ApproximateReceiveCount, ApproximateFirstReceiveTimestamp, "SequenceNumber",
+	"MessageDeduplicationId", MessageGroupIDAttr, "AWSTraceHeader", "DeadLetterQueueSourceArn",
+}
+
+// receivePoll is the ReceiveMessage tuning of the pipeline, the zero value requests all the message attributes
+// and up to 10 messages
+type receivePoll struct {
+	// max_messages_per_poll, 0 - maxMessages
+	maxMessages int32
+	// message_attribute_names, nil - All
+	messageAttrs []string
+	// message_system_attribute_names, requested in addition to the ones required by the driver
+	systemAttrs []types.QueueAttributeName
+}
+
+// newReceivePoll validates the max_messages_per_poll and the attribute names
+func newReceivePoll(maxPerPoll int, messageAttrs, systemAttrs []string) (receivePoll, error) {
+	if maxPerPoll < 0 || maxPerPoll > int(maxMessages) {
+		return receivePoll{}, errors.Errorf("max_messages_per_poll should be in the range 1-%d, provided: %d", maxMessages, maxPerPoll)
+	}
+
+	p := receivePoll{maxMessages: int32(maxPerPoll)} //nolint:gosec
+
+	for _, name := range messageAttrs {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == All {
+			p.messageAttrs = nil
+			break
+		}
+		p.messageAttrs = append(p.messageAttrs, name)
+	}
+
+	for _, name := range systemAttrs {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(systemAttributeNames, name) {
+			return receivePoll{}, errors.Errorf("unknown message_system_attribute_names entry: %s, supported: %s", name, strings.Join(systemAttributeNames, ", "))
+		}
+		p.systemAttrs = append(p.systemAttrs, types.QueueAttributeName(name))
+	}
+
+	return p, nil
+}
+
+// batch returns the max_messages_per_poll
+func (p *receivePoll) batch() int32 {
+	if p.maxMessages == 0 {
+		return maxMessages
+	}
+
+	return p.maxMessages
+}
+
+// messageAttributeNames returns the message attributes requested with every ReceiveMessage call. The attributes
+// read by the driver are always requested, the RR, the X-RR and the Content prefixed ones included.
+func (c *Driver) messageAttributeNames() []string {
+	if len(c.poll.messageAttrs) == 0 {
+		return []string{All}
+	}
+
+	names := append(slices.Clone(c.poll.messageAttrs), "rr_.*", "X-RR-.*", "Content-.*", RetryCountAttr, ExtendedPayloadSize, BaggageAttr, c.partitionKeyAttr())
+
+	for _, name := range []string{c.hints.job, c.hints.delay, c.hints.priority, c.dedupAttribute, c.correlationAttr, c.replyToAttr, c.executeAtAttr, c.deadlineAttr} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if c.routes != nil {
+		names = append(names, c.routes.attr)
+	}
+
+	for attr := range c.attrMapping.demote {
+		names = append(names, attr)
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
@@ -0,0 +1,59 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceivePoll(t *testing.T) {
+	_, err := newReceivePoll(11, nil, nil)
+	require.Error(t, err)
+	_, err = newReceivePoll(-1, nil, nil)
+	require.Error(t, err)
+	_, err = newReceivePoll(0, nil, []string{"SenderID"})
+	require.Error(t, err)
+
+	p, err := newReceivePoll(0, []string{" ", "order_id", "All"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, maxMessages, p.batch())
+	require.Nil(t, p.messageAttrs)
+
+	wait, err := receiveWaitTime(-1)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), wait)
+	_, err = receiveWaitTime(21)
+	require.Error(t, err)
+}
+
+func TestReceivePollRequest(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.dedupAttribute = "order_key"
+
+	var err error
+	c.poll, err = newReceivePoll(3, []string{"tenant", "order_.*"}, []string{"SenderId", ApproximateReceiveCount})
+	require.NoError(t, err)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String("body")})
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return fc.called("ReceiveMessage") > 0 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	fc.mu.Lock()
+	in := fc.received[0]
+	fc.mu.Unlock()
+
+	require.Equal(t, int32(3), in.MaxNumberOfMessages)
+	for _, name := range []string{"tenant", "order_.*", "order_key", "rr_.*", "X-RR-.*", "Content-.*", RetryCountAttr} {
+		require.Contains(t, in.MessageAttributeNames, name)
+	}
+	require.NotContains(t, in.MessageAttributeNames, All)
+	// the required attributes are not duplicated
+	require.Equal(t, []types.QueueAttributeName{types.QueueAttributeName(ApproximateReceiveCount), "SenderId"}, in.AttributeNames)
+}
@@ -0,0 +1,78 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxPollers limits the number of concurrent receive loops per pipeline
+const maxPollers int = 100
+
+// pollersCount normalizes the number of receive loops
+func pollersCount(n int) int {
+	switch {
+	case n <= 0:
+		return 1
+	case n > maxPollers:
+		return maxPollers
+	default:
+		return n
+	}
+}
+
+// startPollers starts the receive loops bound to the pipeline run context, should be called under the c.mu
+func (c *Driver) startPollers(ctx context.Context) {
+	c.runCtx = ctx
+	c.resetIdle(time.Now())
+	c.pollerCancels = make([]context.CancelFunc, 0, c.pollers)
+
+	for i := 0; i < c.pollers; i++ {
+		c.addPoller()
+	}
+
+	c.startWarmPool(ctx)
+}
+
+func (c *Driver) addPoller() {
+	ctx, cancel := context.WithCancel(c.runCtx)
+	c.pollerCancels = append(c.pollerCancels, cancel)
+	c.listen(ctx)
+}
+
+func (c *Driver) removePoller() {
+	last := len(c.pollerCancels) - 1
+	c.pollerCancels[last]()
+	c.pollerCancels[last] = nil
+	c.pollerCancels = c.pollerCancels[:last]
+	// if blocked on the prefetch limit, wake up to exit
+	c.cond.Broadcast()
+}
+
+// setPollers changes the number of the receive loops, applied right away if the pipeline is active
+func (c *Driver) setPollers(n int) {
+	n = pollersCount(n)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.pollers
+	c.pollers = n
+
+	// not started or paused, will be applied on run/resume
+	if atomic.LoadUint32(&c.listeners) == 0 || c.runCtx == nil || c.runCtx.Err() != nil {
+		return
+	}
+
+	for len(c.pollerCancels) < n {
+		c.addPoller()
+	}
+
+	for len(c.pollerCancels) > n {
+		c.removePoller()
+	}
+
+	c.log.Debug("number of pollers was updated", zap.Int("previous", prev), zap.Int("current", n))
+}
@@ -0,0 +1,101 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// profileOptions loads the provided profile from the shared config. The full shared config resolution
+// is done by the SDK, including source_profile/role_arn chains.
+func profileOptions(profile string) []func(*config.LoadOptions) error {
+	return []func(*config.LoadOptions) error{
+		config.WithSharedConfigProfile(profile),
+		// RR is a server, there is no way to ask user for the MFA token
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = func() (string, error) {
+				return "", errors.Errorf("profile %s requires an MFA token (mfa_serial), interactive MFA is not supported by the sqs driver; use a role without MFA or provide temporary credentials via key/secret/session_token", profile)
+			}
+		}),
+	}
+}
+
+// sharedFilesOptions replaces the default shared config and credentials files, the files should exist
+func sharedFilesOptions(configFile, credentialsFile string) ([]func(*config.LoadOptions) error, error) {
+	var opts []func(*config.LoadOptions) error
+	if configFile != "" {
+		if _, err := os.Stat(configFile); err != nil {
+			return nil, errors.Errorf("shared_config_file: %v", err)
+		}
+		opts = append(opts, config.WithSharedConfigFiles([]string{configFile}))
+	}
+
+	if credentialsFile != "" {
+		if _, err := os.Stat(credentialsFile); err != nil {
+			return nil, errors.Errorf("shared_credentials_file: %v", err)
+		}
+		opts = append(opts, config.WithSharedCredentialsFiles([]string{credentialsFile}))
+	}
+
+	return opts, nil
+}
+
+// checkProfileCredentials retrieves credentials for the profile, so the errors (missing source profile, MFA, etc.) are surfaced on the pipeline initialization
+func checkProfileCredentials(ctx context.Context, profile string, awsConf aws.Config) error {
+	if profile == "" || awsConf.Credentials == nil {
+		return nil
+	}
+
+	_, err := awsConf.Credentials.Retrieve(ctx)
+	if err != nil {
+		return errors.Errorf("failed to retrieve credentials for the profile %s: %v", profile, err)
+	}
+
+	return nil
+}
+
+// warmUpCredentials resolves the credentials on the pipeline initialization, the SDK caches them,
+// so the first Push/receive doesn't pay the resolution cost and the credential errors are surfaced at boot
+func warmUpCredentials(ctx context.Context, awsConf aws.Config) error {
+	if awsConf.Credentials == nil {
+		return nil
+	}
+
+	_, err := awsConf.Credentials.Retrieve(ctx)
+	if err != nil {
+		return errors.Errorf("failed to retrieve the credentials (warm-up, might be disabled with skip_warmup): %v", err)
+	}
+
+	return nil
+}
+
+// queueLister is the Ping call of the connection warm-up
+type queueLister interface {
+	ListQueues(ctx context.Context, params *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error)
+}
+
+// warmUpConnection opens the connection (DNS, TCP and TLS handshake) with a single ListQueues call, so the first
+// Push/receive of the new or the rotated client is fast. Best effort: the API error (e.g. AccessDenied) means
+// the connection is open, the network error is logged and doesn't fail the initialization.
+func warmUpConnection(ctx context.Context, client queueLister, log *zap.Logger) {
+	_, err := client.ListQueues(ctx, &sqs.ListQueuesInput{MaxResults: aws.Int32(1)}, func(o *sqs.Options) {
+		// the warm-up should not wait for the retries of the unreachable endpoint
+		o.RetryMaxAttempts = 1
+	})
+
+	var apiErr smithy.APIError
+	switch {
+	case err == nil, stderr.As(err, &apiErr):
+		log.Debug("connection warmed up")
+	default:
+		log.Warn("connection warm-up failed", zap.Error(err))
+	}
+}
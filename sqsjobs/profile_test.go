@@ -0,0 +1,204 @@
+package sqsjobs
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const sharedConfigChain = `[profile base]
+aws_access_key_id = AKIDEXAMPLE
+aws_secret_access_key = secret
+region = us-east-1
+
+[profile chained]
+role_arn = arn:aws:iam::123456789012:role/rr-sqs
+source_profile = base
+
+[profile mfa]
+role_arn = arn:aws:iam::123456789012:role/rr-sqs-mfa
+source_profile = base
+mfa_serial = arn:aws:iam::123456789012:mfa/user
+`
+
+func TestProfileOptions(t *testing.T) {
+	lo := &config.LoadOptions{}
+	for _, opt := range profileOptions("mfa") {
+		require.NoError(t, opt(lo))
+	}
+
+	require.Equal(t, "mfa", lo.SharedConfigProfile)
+	require.NotNil(t, lo.AssumeRoleCredentialOptions)
+
+	aro := &stscreds.AssumeRoleOptions{}
+	lo.AssumeRoleCredentialOptions(aro)
+	require.NotNil(t, aro.TokenProvider)
+
+	_, err := aro.TokenProvider()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "MFA")
+	require.Contains(t, err.Error(), "mfa")
+}
+
+func TestProfileSourceProfileChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(sharedConfigChain), 0o600))
+
+	t.Setenv("AWS_CONFIG_FILE", path)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	// source_profile chain should be resolved by the shared config loader
+	_, err := config.LoadDefaultConfig(context.Background(), profileOptions("chained")...)
+	require.NoError(t, err)
+}
+
+func TestWarmUpCredentials(t *testing.T) {
+	var calls int32
+	awsConf := aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		atomic.AddInt32(&calls, 1)
+		return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+	})}
+
+	require.NoError(t, warmUpCredentials(context.Background(), awsConf))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// no provider - nothing to warm up
+	require.NoError(t, warmUpCredentials(context.Background(), aws.Config{}))
+}
+
+type fakeLister struct {
+	calls    int32
+	attempts int
+	err      error
+}
+
+func (f *fakeLister) ListQueues(_ context.Context, _ *sqs.ListQueuesInput, optFns ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	atomic.AddInt32(&f.calls, 1)
+	o := sqs.Options{RetryMaxAttempts: 3}
+	for _, fn := range optFns {
+		fn(&o)
+	}
+	f.attempts = o.RetryMaxAttempts
+
+	return &sqs.ListQueuesOutput{}, f.err
+}
+
+func TestWarmUpConnection(t *testing.T) {
+	fl := &fakeLister{}
+	warmUpConnection(context.Background(), fl, zap.NewNop())
+	require.Equal(t, int32(1), atomic.LoadInt32(&fl.calls))
+	// no retries of the unreachable endpoint
+	require.Equal(t, 1, fl.attempts)
+
+	// the API and the network errors don't fail the initialization
+	fl.err = &smithy.GenericAPIError{Code: "AccessDenied", Message: "denied"}
+	warmUpConnection(context.Background(), fl, zap.NewNop())
+	fl.err = &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	warmUpConnection(context.Background(), fl, zap.NewNop())
+	require.Equal(t, int32(3), atomic.LoadInt32(&fl.calls))
+}
+
+func TestCheckEnvWarmUp(t *testing.T) {
+	conf := &Config{Region: "us-east-1", Endpoint: "http://127.0.0.1:9324", Key: "key", Secret: "secret"}
+	_, err := checkEnv(false, conf, zap.NewNop())
+	require.NoError(t, err)
+
+	// credentials are retrieved during the init, the errors are surfaced at boot
+	conf = &Config{Region: "us-east-1", Endpoint: "http://127.0.0.1:9324"}
+	_, err = checkEnv(false, conf, zap.NewNop())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "skip_warmup")
+
+	conf.SkipWarmup = true
+	_, err = checkEnv(false, conf, zap.NewNop())
+	require.NoError(t, err)
+}
+
+func TestSharedFilesPerPipeline(t *testing.T) {
+	// no implicit credentials from the environment
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	files := func(key string) (string, string) {
+		dir := t.TempDir()
+		cfg := filepath.Join(dir, "config")
+		creds := filepath.Join(dir, "credentials")
+		require.NoError(t, os.WriteFile(cfg, []byte("[default]\nregion = eu-west-1\n"), 0o600))
+		require.NoError(t, os.WriteFile(creds, []byte("[default]\naws_access_key_id = "+key+"\naws_secret_access_key = secret\n"), 0o600))
+		return cfg, creds
+	}
+
+	resolve := func(cfg, creds string) string {
+		conf := &Config{Region: "us-east-1", Endpoint: "http://127.0.0.1:9324", SharedConfigFile: cfg, SharedCredentialsFile: creds}
+		client, err := checkEnv(false, conf, zap.NewNop())
+		require.NoError(t, err)
+		require.Equal(t, credsProfile, credentialsSource(conf, false))
+
+		v, err := client.Options().Credentials.Retrieve(context.Background())
+		require.NoError(t, err)
+		return v.AccessKeyID
+	}
+
+	// every pipeline resolves the credentials from its own files
+	cfgA, credsA := files("AKIDPIPELINEA")
+	cfgB, credsB := files("AKIDPIPELINEB")
+	require.Equal(t, "AKIDPIPELINEA", resolve(cfgA, credsA))
+	require.Equal(t, "AKIDPIPELINEB", resolve(cfgB, credsB))
+
+	_, err := sharedFilesOptions(filepath.Join(t.TempDir(), "missing"), "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shared_config_file")
+	_, err = sharedFilesOptions("", filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shared_credentials_file")
+}
+
+func TestProfileOverStaticCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config")
+	creds := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(cfg, []byte("[profile dev]\nregion = eu-west-1\n"), 0o600))
+	require.NoError(t, os.WriteFile(creds, []byte("[dev]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = secret\n"), 0o600))
+
+	// the same principal locally and in AWS
+	for _, insideAWS := range []bool{false, true} {
+		core, logs := observer.New(zapcore.WarnLevel)
+		conf := &Config{
+			Region:                "us-east-1",
+			Endpoint:              "http://127.0.0.1:9324",
+			Profile:               "dev",
+			SharedConfigFile:      cfg,
+			SharedCredentialsFile: creds,
+			Key:                   "AKIDSTATIC",
+			Secret:                "static",
+			SessionToken:          "token",
+		}
+		client, err := checkEnv(insideAWS, conf, zap.New(core))
+		require.NoError(t, err)
+		require.Equal(t, credsProfile, credentialsSource(conf, insideAWS))
+
+		v, err := client.Options().Credentials.Retrieve(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "AKIDPROFILE", v.AccessKeyID)
+		require.Equal(t, 1, logs.FilterMessageSnippet("static key/secret/session_token are ignored").Len())
+	}
+}
@@ -0,0 +1,102 @@
+package sqsjobs
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// QueueEvent actions
+const (
+	// QueueDeclared - the queue was declared on the pipeline init, CreateQueue is idempotent, so the queue might already exist
+	QueueDeclared string = "declared"
+	// QueueCreated - the queue didn't exist and was created by the pipeline (delete_on_stop)
+	QueueCreated string = "created"
+	// QueueRecreated - the queue was deleted externally and created again by the listener
+	QueueRecreated string = "recreated"
+	// QueueDeleted - the queue created by the pipeline was deleted on stop (delete_on_stop)
+	QueueDeleted string = "deleted"
+	// QueueFailedOver and QueueFailedBack - the pipeline switched to the secondary queue (failover_queue) and back
+	QueueFailedOver string = "failed_over"
+	QueueFailedBack string = "failed_back"
+)
+
+// QueueEvent is the provisioning audit record of the queue created or deleted by the driver, or the failover switch.
+// Before is nil for the declared/created queues, After is nil for the deleted ones and the failover switches.
+type QueueEvent struct {
+	Action string
+	Queue  string
+	URL    string
+	// queue attributes as reported by SQS (the requested ones if GetQueueAttributes failed)
+	Before map[string]string
+	After  map[string]string
+	// requested tags
+	Tags map[string]string
+	Time time.Time
+}
+
+// QueueEvents returns the provisioning audit events, nil if queue_events_buffer is not set. The events emitted on the
+// pipeline init are buffered, the events are dropped (with a warning) when the buffer is full, so the driver is never blocked.
+func (c *Driver) QueueEvents() <-chan QueueEvent {
+	if c.queueEvents == nil {
+		return nil
+	}
+
+	return c.queueEvents
+}
+
+// emitQueueEvent sends the event with the current queue attributes, no-op if the events are disabled
+func (c *Driver) emitQueueEvent(ctx context.Context, action string, before map[string]string) {
+	if c.queueEvents == nil {
+		return
+	}
+
+	ev := QueueEvent{
+		Action: action,
+		Queue:  getordefault(c.queue),
+		URL:    getordefault(c.queueURL),
+		Before: before,
+		Tags:   maps.Clone(c.tags),
+		Time:   time.Now(),
+	}
+
+	// the primary queue is unreachable on the failover
+	switch action {
+	case QueueDeleted, QueueFailedOver, QueueFailedBack:
+	default:
+		ev.After = c.queueAttributes(ctx)
+	}
+
+	select {
+	case c.queueEvents <- ev:
+	default:
+		c.log.Warn("queue event was dropped, the events buffer is full", zap.String("action", action), zap.Stringp("queue", c.queue))
+	}
+}
+
+// queueAttributes returns all the queue attributes, the requested attributes are returned if the queue can't be read
+func (c *Driver) queueAttributes(ctx context.Context) map[string]string {
+	out, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       c.queueURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+	})
+	if err != nil {
+		c.log.Debug("failed to read the queue attributes for the queue event", zap.Stringp("queue", c.queue), zap.Error(err))
+		return maps.Clone(c.attributes)
+	}
+
+	return out.Attributes
+}
+
+// newQueueEvents creates the events channel, nil if disabled (size <= 0)
+func newQueueEvents(size int) chan QueueEvent {
+	if size <= 0 {
+		return nil
+	}
+
+	return make(chan QueueEvent, size)
+}
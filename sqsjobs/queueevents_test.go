@@ -0,0 +1,59 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueEvents(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.deleteOnStop = true
+	c.queue = aws.String("ci-run-42")
+	c.attributes = map[string]string{"VisibilityTimeout": "60"}
+	c.tags = map[string]string{"team": "infra"}
+	c.queueEvents = newQueueEvents(1)
+
+	resolved := map[string]string{"VisibilityTimeout": "60", "MessageRetentionPeriod": "345600", "QueueArn": "arn:aws:sqs:us-east-1:000000000000:ci-run-42"}
+	fc := newFakeClient()
+	fc.getURLFn = func(_ context.Context, in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		if len(fc.created) == 0 {
+			return nil, &types.QueueDoesNotExist{Message: aws.String("no such queue")}
+		}
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(in.QueueName))}, nil
+	}
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return &sqs.GetQueueAttributesOutput{Attributes: resolved}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+
+	ev := <-c.QueueEvents()
+	require.Equal(t, QueueCreated, ev.Action)
+	require.Equal(t, "ci-run-42", ev.Queue)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/ci-run-42", ev.URL)
+	require.Nil(t, ev.Before)
+	require.Equal(t, resolved, ev.After)
+	require.Equal(t, map[string]string{"team": "infra"}, ev.Tags)
+	require.False(t, ev.Time.IsZero())
+
+	// the buffer is full, the event is dropped, the driver isn't blocked
+	c.emitQueueEvent(context.Background(), QueueDeclared, nil)
+	c.emitQueueEvent(context.Background(), QueueDeclared, nil)
+	require.Len(t, c.queueEvents, 1)
+	<-c.QueueEvents()
+
+	require.NoError(t, c.Stop(context.Background()))
+	ev = <-c.QueueEvents()
+	require.Equal(t, QueueDeleted, ev.Action)
+	require.Equal(t, resolved, ev.Before)
+	require.Nil(t, ev.After)
+
+	// disabled
+	require.Nil(t, newTestDriver(&testQueue{}, nil).QueueEvents())
+}
@@ -0,0 +1,152 @@
+package sqsjobs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+// the queue type can't be changed after the create, SetQueueAttributes rejects it
+var immutableQueueAttributes = map[string]struct{}{
+	FifoQueueAWS: {},
+}
+
+// lookupQueue resolves the existing queue before the declaration, so the boot doesn't call CreateQueue for the
+// existing queue (and doesn't fail on the attributes mismatch). Returns nil if the queue is missing or the lookup failed,
+// the queue is created then.
+func (c *Driver) lookupQueue(ctx context.Context) *string {
+	url, err := getQueueURL(ctx, c.client, c.queue, nil)
+	if err == nil {
+		return url
+	}
+
+	if !isNonExistentQueue(err) {
+		c.log.Debug("failed to resolve the queue before the create, falling back to CreateQueue", zap.Stringp("queue", c.queue), zap.Error(err))
+	}
+
+	return nil
+}
+
+// syncQueueAttributes updates the attributes of the existing queue which differ from the configured ones, no-op if
+// all of them match. The errors are logged, the existing queue is used with its current attributes then.
+func (c *Driver) syncQueueAttributes(ctx context.Context) {
+	if len(c.attributes) == 0 {
+		return
+	}
+
+	out, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       c.queueURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameAll},
+	})
+	if err != nil {
+		c.log.Warn("failed to read the queue attributes, the attributes are not updated", zap.Stringp("queue", c.queue), zap.Error(err))
+		return
+	}
+
+	diff := queueAttributesDiff(out.Attributes, c.attributes)
+	for name := range diff {
+		if _, ok := immutableQueueAttributes[name]; ok {
+			c.log.Warn("queue attribute can't be changed for the existing queue, skipped", zap.Stringp("queue", c.queue), zap.String("attribute", name), zap.String("current", out.Attributes[name]))
+			delete(diff, name)
+		}
+	}
+
+	if len(diff) == 0 {
+		c.log.Debug("queue exists with the configured attributes", zap.Stringp("queue", c.queue))
+		return
+	}
+
+	_, err = c.client.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   c.queueURL,
+		Attributes: diff,
+	})
+	if err != nil {
+		c.log.Warn("failed to update the queue attributes", zap.Stringp("queue", c.queue), zap.Strings("attributes", attributeNames(diff)), zap.Error(err))
+		return
+	}
+
+	c.log.Info("queue attributes were updated", zap.Stringp("queue", c.queue), zap.Strings("attributes", attributeNames(diff)))
+}
+
+// syncQueueTags adds the configured tags missing on the existing queue (or with the other value), the tags not in the
+// config are kept, e.g. set by the infrastructure tooling. The errors are logged, like for the attributes.
+func (c *Driver) syncQueueTags(ctx context.Context) {
+	if len(c.tags) == 0 {
+		return
+	}
+
+	out, err := c.client.ListQueueTags(ctx, &sqs.ListQueueTagsInput{QueueUrl: c.queueURL})
+	if err != nil {
+		c.log.Warn("failed to read the queue tags, the tags are not updated", zap.Stringp("queue", c.queue), zap.Error(err))
+		return
+	}
+
+	diff := make(map[string]string)
+	for k, v := range c.tags {
+		if cur, ok := out.Tags[k]; !ok || cur != v {
+			diff[k] = v
+		}
+	}
+
+	if len(diff) == 0 {
+		return
+	}
+
+	_, err = c.client.TagQueue(ctx, &sqs.TagQueueInput{
+		QueueUrl: c.queueURL,
+		Tags:     diff,
+	})
+	if err != nil {
+		c.log.Warn("failed to update the queue tags", zap.Stringp("queue", c.queue), zap.Strings("tags", attributeNames(diff)), zap.Error(err))
+		return
+	}
+
+	c.log.Info("queue tags were updated", zap.Stringp("queue", c.queue), zap.Strings("tags", attributeNames(diff)))
+}
+
+// queueAttributesDiff returns the desired attributes with the values different from the current ones
+func queueAttributesDiff(current, desired map[string]string) map[string]string {
+	diff := make(map[string]string)
+	for name, v := range desired {
+		if cur, ok := current[name]; ok && sameAttributeValue(cur, v) {
+			continue
+		}
+		diff[name] = v
+	}
+
+	return diff
+}
+
+// sameAttributeValue compares the attribute values, the JSON values (Policy, RedrivePolicy) are compared semantically,
+// since SQS doesn't keep the formatting
+func sameAttributeValue(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var ja, jb any
+	if json.Unmarshal([]byte(a), &ja) != nil || json.Unmarshal([]byte(b), &jb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(ja, jb)
+}
+
+func attributeNames(attrs map[string]string) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func lookupEnabled(v *bool) bool {
+	return v == nil || *v
+}
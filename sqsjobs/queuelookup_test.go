@@ -0,0 +1,118 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupBeforeCreateExisting(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.lookupFirst = true
+	c.queue = aws.String("orders")
+	c.attributes = map[string]string{
+		VisibilityTimeoutAWS: "30",
+		PolicyAWS:            `{"Version": "2012-10-17", "Statement": []}`,
+	}
+
+	fc := newFakeClient()
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		// SQS doesn't keep the policy formatting
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{
+			VisibilityTimeoutAWS: "30",
+			PolicyAWS:            `{"Statement":[],"Version":"2012-10-17"}`,
+			"QueueArn":           "arn:aws:sqs:us-east-1:000000000000:orders",
+		}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+	require.Equal(t, 0, fc.called("SetQueueAttributes"))
+	require.Equal(t, 1, fc.called("GetQueueUrl"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/orders", aws.ToString(c.queueURL))
+}
+
+func TestLookupBeforeCreateUpdatesDiff(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.lookupFirst = true
+	c.queue = aws.String("orders.fifo")
+	c.attributes = map[string]string{
+		VisibilityTimeoutAWS: "60",
+		DelaySecondsAWS:      "0",
+		FifoQueueAWS:         "true",
+	}
+
+	fc := newFakeClient()
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{
+			VisibilityTimeoutAWS: "30",
+			DelaySecondsAWS:      "0",
+		}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+	require.Len(t, fc.setAttrs, 1)
+	// only the changed attribute, the queue type can't be changed
+	require.Equal(t, map[string]string{VisibilityTimeoutAWS: "60"}, fc.setAttrs[0].Attributes)
+}
+
+func TestLookupBeforeCreateMissing(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.lookupFirst = true
+	c.queue = aws.String("orders")
+
+	fc := newFakeClient()
+	resolved := 0
+	fc.getURLFn = func(_ context.Context, in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		resolved++
+		if resolved == 1 {
+			return nil, &types.QueueDoesNotExist{}
+		}
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(in.QueueName))}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Equal(t, 1, fc.called("CreateQueue"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/orders", aws.ToString(c.queueURL))
+
+	// disabled - the queue is always declared with CreateQueue
+	c.lookupFirst = false
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Equal(t, 2, fc.called("CreateQueue"))
+	require.True(t, lookupEnabled(nil))
+	require.False(t, lookupEnabled(aws.Bool(false)))
+}
+
+func TestLookupBeforeCreateSyncsTags(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.lookupFirst = true
+	c.queue = aws.String("orders")
+	c.tags = map[string]string{"team": "billing", "env": "prod"}
+
+	fc := newFakeClient()
+	fc.listTagsFn = func(*sqs.ListQueueTagsInput) (*sqs.ListQueueTagsOutput, error) {
+		return &sqs.ListQueueTagsOutput{Tags: map[string]string{"team": "billing", "env": "staging", "owner": "infra"}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+	require.Len(t, fc.tagged, 1)
+	// only the changed tag, the other tags of the queue are kept
+	require.Equal(t, map[string]string{"env": "prod"}, fc.tagged[0].Tags)
+
+	// all tags match
+	fc.listTagsFn = func(*sqs.ListQueueTagsInput) (*sqs.ListQueueTagsOutput, error) {
+		return &sqs.ListQueueTagsOutput{Tags: map[string]string{"team": "billing", "env": "prod"}}, nil
+	}
+	require.NoError(t, manageQueue(context.Background(), c))
+	require.Len(t, fc.tagged, 1)
+}
@@ -0,0 +1,44 @@
+package sqsjobs
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/roadrunner-server/errors"
+)
+
+// accountIDLength is the length of the AWS account ID
+const accountIDLength int = 12
+
+// checkAccountID validates the queue_owner_account_id option, nil - not set (the queues belong to the caller account)
+func checkAccountID(id string) (*string, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	if len(id) != accountIDLength {
+		return nil, errors.Errorf("queue_owner_account_id should be a 12-digit AWS account ID, provided: %s", id)
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return nil, errors.Errorf("queue_owner_account_id should be a 12-digit AWS account ID, provided: %s", id)
+		}
+	}
+
+	return aws.String(id), nil
+}
+
+// crossAccountQueue disables the declaration of the queue owned by another account (queue_owner_account_id): the queue
+// is resolved by name with the owner, never created or recreated by the pipeline
+func (c *Driver) crossAccountQueue() error {
+	if c.queueOwner == nil {
+		return nil
+	}
+
+	if c.deleteOnStop {
+		return errors.Str("delete_on_stop can't be combined with the queue_owner_account_id, the queue is not declared by the pipeline")
+	}
+
+	c.skipDeclare = true
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAccountID(t *testing.T) {
+	id, err := checkAccountID("")
+	require.NoError(t, err)
+	require.Nil(t, id)
+
+	id, err = checkAccountID("123456789012")
+	require.NoError(t, err)
+	require.Equal(t, "123456789012", aws.ToString(id))
+
+	for _, bad := range []string{"12345678901", "1234567890123", "12345678901a", "arn:aws:iam::123456789012:root"} {
+		_, err = checkAccountID(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestQueueOwnerOnGetQueueURL(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.skipDeclare = true
+	c.queueOwner = aws.String("123456789012")
+	c.retryQueue = aws.String("retry")
+
+	require.NoError(t, c.setup(time.Second, true, aws.String("dlq")))
+	require.Len(t, fc.resolved, 3)
+	for _, in := range fc.resolved {
+		require.Equal(t, "123456789012", aws.ToString(in.QueueOwnerAWSAccountId), aws.ToString(in.QueueName))
+	}
+
+	// the queue of another account is never declared
+	c = newTestDriver(&testQueue{}, nil)
+	fc = newFakeClient()
+	c.client = fc
+	c.queueOwner = aws.String("123456789012")
+	require.NoError(t, c.crossAccountQueue())
+	require.True(t, c.skipDeclare)
+
+	require.NoError(t, c.setup(time.Second, true, nil))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+	require.Len(t, fc.resolved, 1)
+	require.Equal(t, "123456789012", aws.ToString(fc.resolved[0].QueueOwnerAWSAccountId))
+
+	c.deleteOnStop = true
+	require.Error(t, c.crossAccountQueue())
+}
+
+func TestSkipDeclareMissingQueueStop(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: NonExistentQueue}
+	}
+	c.client = fc
+	c.skipDeclare = true
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return fc.called("ReceiveMessage") > 0 }, time.Second*5, time.Millisecond*10)
+
+	// the wait before the next receive is canceled by the stop
+	stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	require.True(t, c.waitPollers(ctx))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+}
@@ -0,0 +1,77 @@
+package sqsjobs
+
+import (
+	stderr "errors"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+	"github.com/roadrunner-server/errors"
+)
+
+// InvalidAddress is returned by SQS for the malformed queue URL or the queue URL not matching the client region
+const InvalidAddress string = "InvalidAddress"
+
+// queueTarget splits the configured queue into the name and the URL. The value is either the queue name (the prefix
+// is applied) or the full queue URL which is used as is, e.g. https://sqs.us-east-1.amazonaws.com/123456789012/orders.
+// The URL is nil for the names, it is resolved (or created) on setup.
+func queueTarget(prefix, value string) (*string, *string, error) {
+	if !strings.Contains(value, "://") {
+		return aws.String(queueName(prefix, value)), nil, nil
+	}
+
+	name, err := parseQueueURL(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aws.String(name), aws.String(value), nil
+}
+
+// parseQueueURL validates the queue URL format: http(s)://<host>/<account id>/<queue name> or the LocalStack path-style
+// http(s)://<host>/queue/<region>/<account id>/<queue name>, and returns the queue name
+func parseQueueURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", errors.Errorf("malformed queue URL %s: %v", s, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", errors.Errorf("malformed queue URL %s: the scheme should be https or http, got: %s", s, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return "", errors.Errorf("malformed queue URL %s: the host is empty", s)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", errors.Errorf("malformed queue URL %s: the query, fragment and user info are not allowed", s)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) == 4 && parts[0] == "queue" {
+		parts = parts[2:]
+	}
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.Errorf("malformed queue URL %s: the path should be /<account id>/<queue name>", s)
+	}
+
+	return parts[1], nil
+}
+
+// isInvalidAddress checks the InvalidAddress API error
+func isInvalidAddress(err error) bool {
+	var apiErr smithy.APIError
+	return stderr.As(err, &apiErr) && apiErr.ErrorCode() == InvalidAddress
+}
+
+// addressError replaces the InvalidAddress API error with the clear one
+func addressError(err error, queue *string) error {
+	if !isInvalidAddress(err) {
+		return err
+	}
+
+	return errors.Errorf("malformed or wrong-region queue URL %s, check the queue and the region in the configuration: %v", getordefault(queue), err)
+}
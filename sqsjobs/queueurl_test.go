@@ -0,0 +1,72 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueTarget(t *testing.T) {
+	name, url, err := queueTarget("prod-", "orders")
+	require.NoError(t, err)
+	require.Equal(t, "prod-orders", aws.ToString(name))
+	require.Nil(t, url)
+
+	name, url, err = queueTarget("prod-", "https://sqs.us-east-1.amazonaws.com/123456789012/orders.fifo")
+	require.NoError(t, err)
+	require.Equal(t, "orders.fifo", aws.ToString(name))
+	require.Equal(t, "https://sqs.us-east-1.amazonaws.com/123456789012/orders.fifo", aws.ToString(url))
+
+	// LocalStack path-style
+	name, _, err = queueTarget("", "http://localhost:4566/queue/us-east-1/000000000000/orders")
+	require.NoError(t, err)
+	require.Equal(t, "orders", aws.ToString(name))
+
+	for _, bad := range []string{
+		"http://localhost:4566/queue/us-east-1//orders",
+		"ftp://sqs.us-east-1.amazonaws.com/123456789012/orders",
+		"https:///123456789012/orders",
+		"https://sqs.us-east-1.amazonaws.com/orders",
+		"https://sqs.us-east-1.amazonaws.com/123456789012/orders/extra",
+		"https://sqs.us-east-1.amazonaws.com/123456789012/orders?Action=ReceiveMessage",
+		"https://sqs.us-east-1.amazon aws.com/123456789012/orders",
+	} {
+		_, _, err = queueTarget("", bad)
+		require.Error(t, err, bad)
+		require.Contains(t, err.Error(), "malformed queue URL")
+	}
+}
+
+func TestQueueURLConfigured(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+	c.skipDeclare = true
+	c.queue, c.queueURL, _ = queueTarget("", "http://127.0.0.1:9324/000000000000/orders")
+	c.queueURLFixed = true
+
+	require.NoError(t, c.setup(time.Second, true, nil))
+	require.Equal(t, 0, fc.called("GetQueueUrl"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/orders", aws.ToString(c.queueURL))
+}
+
+func TestInvalidAddressOnSetup(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: InvalidAddress, Message: "The address https://sqs.eu-west-1.amazonaws.com/ is not valid for this endpoint."}
+	}
+	c.client = fc
+	c.skipDeclare = true
+	c.queue, c.queueURL, _ = queueTarget("", "https://sqs.eu-west-1.amazonaws.com/123456789012/orders")
+	c.queueURLFixed = true
+
+	err := c.setup(time.Second, false, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "malformed or wrong-region queue URL https://sqs.eu-west-1.amazonaws.com/123456789012/orders")
+}
@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// rateLimiter is the token bucket capping the dispatch rate of the pipeline (rate_limit messages per second, up to the
+// burst at once). The received messages wait for the token before they are sent to the priority queue.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates the full bucket, nil if disabled (rate 0). The burst defaults to the rate.
+func newRateLimiter(rate, burst int) (*rateLimiter, error) {
+	if rate < 0 || burst < 0 {
+		return nil, errors.Errorf("rate_limit and rate_limit_burst should not be negative, provided: %d, %d", rate, burst)
+	}
+
+	if rate == 0 {
+		if burst > 0 {
+			return nil, errors.Str("rate_limit_burst requires the rate_limit")
+		}
+		return nil, nil
+	}
+
+	if burst == 0 {
+		burst = rate
+	}
+
+	return &rateLimiter{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}, nil
+}
+
+// wait takes the token, blocks until it's available. The token is returned if the context is canceled while waiting.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// the token is reserved, the next callers wait for the refill after this one
+	l.tokens--
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if d == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,55 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l, err := newRateLimiter(20, 2)
+	require.NoError(t, err)
+
+	// the burst is dispatched right away, the rest is paced
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		require.NoError(t, l.wait(context.Background()))
+	}
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, time.Millisecond*190)
+	require.Less(t, elapsed, time.Second)
+
+	// canceled while waiting, the token is returned
+	l, err = newRateLimiter(10, 1)
+	require.NoError(t, err)
+	require.NoError(t, l.wait(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	require.Error(t, l.wait(ctx))
+	time.Sleep(time.Millisecond * 100)
+	start = time.Now()
+	require.NoError(t, l.wait(context.Background()))
+	require.Less(t, time.Since(start), time.Millisecond*50)
+
+	// unlimited
+	var nl *rateLimiter
+	require.NoError(t, nl.wait(context.Background()))
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	l, err := newRateLimiter(0, 0)
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	l, err = newRateLimiter(100, 0)
+	require.NoError(t, err)
+	require.Equal(t, float64(100), l.burst)
+
+	_, err = newRateLimiter(-1, 0)
+	require.Error(t, err)
+
+	_, err = newRateLimiter(0, 10)
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package sqsjobs
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// readiness of the pipeline to accept the work: the queue is resolved (with the credentials) on setup,
+// the pipeline is started and the first receive succeeded. Not ready until then, with the reason.
+type readiness struct {
+	mu     sync.Mutex
+	ready  bool
+	reason string
+}
+
+// notReady resets the readiness with the reason
+func (c *Driver) notReady(reason string) {
+	c.readiness.mu.Lock()
+	c.readiness.ready = false
+	c.readiness.reason = reason
+	c.readiness.mu.Unlock()
+}
+
+// receiveFailed keeps the pipeline not ready with the receive error until the first successful receive
+func (c *Driver) receiveFailed(err error) {
+	c.readiness.mu.Lock()
+	if !c.readiness.ready {
+		c.readiness.reason = "receive failed: " + redactError(err.Error())
+	}
+	c.readiness.mu.Unlock()
+}
+
+// receiveSucceeded marks the running pipeline ready, the late receive of the paused or stopped pipeline is ignored
+func (c *Driver) receiveSucceeded() {
+	if atomic.LoadUint32(&c.listeners) == 0 || atomic.LoadUint64(&c.stopped) == 1 {
+		return
+	}
+
+	c.readiness.mu.Lock()
+	c.readiness.ready = true
+	c.readiness.reason = ""
+	c.readiness.mu.Unlock()
+}
+
+// Ready returns true once the queue is resolved and the first receive of the running pipeline succeeded,
+// otherwise the reason of the not ready state
+func (c *Driver) Ready() (bool, string) {
+	c.readiness.mu.Lock()
+	defer c.readiness.mu.Unlock()
+
+	if c.readiness.ready {
+		return true, ""
+	}
+
+	if c.readiness.reason == "" {
+		return false, "queue is not resolved"
+	}
+
+	return false, c.readiness.reason
+}
@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessAfterQueueResolution(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.queueReadyTimeout = time.Millisecond * 100
+
+	var reachable, receiving atomic.Bool
+	fc := newFakeClient()
+	fc.createFn = func(context.Context, *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+		if !reachable.Load() {
+			return nil, stderr.New("dial tcp: connection refused")
+		}
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/test")}, nil
+	}
+	fc.receiveFn = func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		if !receiving.Load() {
+			time.Sleep(time.Millisecond * 10)
+			return nil, stderr.New("AccessDenied")
+		}
+		time.Sleep(time.Millisecond * 10)
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	c.client = fc
+
+	// SQS can't be reached
+	require.Error(t, c.setup(time.Second, true, nil))
+	ready, reason := c.Ready()
+	require.False(t, ready)
+	require.Equal(t, "queue is not resolved", reason)
+
+	reachable.Store(true)
+	require.NoError(t, c.setup(time.Second, true, nil))
+	ready, reason = c.Ready()
+	require.False(t, ready)
+	require.Equal(t, "pipeline is not started", reason)
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		_, reason := c.Ready()
+		return reason == "receive failed: AccessDenied"
+	}, time.Second*5, time.Millisecond*5)
+
+	// ready only after the first successful receive
+	receiving.Store(true)
+	require.Eventually(t, func() bool {
+		ready, _ := c.Ready()
+		return ready
+	}, time.Second*5, time.Millisecond*5)
+
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, st.NotReadyReason)
+
+	require.NoError(t, c.Pause(context.Background(), "test"))
+	ready, reason = c.Ready()
+	require.False(t, ready)
+	require.Equal(t, "pipeline is paused", reason)
+	require.False(t, c.Stopped())
+
+	require.NoError(t, c.Stop(context.Background()))
+	require.True(t, c.Stopped())
+	_, reason = c.Ready()
+	require.Equal(t, "pipeline is stopped", reason)
+}
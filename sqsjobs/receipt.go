@@ -0,0 +1,110 @@
+package sqsjobs
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// receiptHandle is the latest receipt handle of the received message. The message redelivered while still in flight
+// (e.g. the processing took longer than the visibility timeout) gets a new handle, only the latest one deletes it.
+type receiptHandle struct {
+	mu     sync.Mutex
+	id     string
+	handle *string
+	// nil if the message is not tracked
+	tracker *receiptTracker
+	// removes the message from the dedup window, nil if the dedup is disabled
+	forget func()
+}
+
+// get returns the latest receipt handle, nil-safe for the pushed jobs
+func (r *receiptHandle) get() *string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.handle
+}
+
+func (r *receiptHandle) set(handle *string) {
+	r.mu.Lock()
+	r.handle = handle
+	r.mu.Unlock()
+}
+
+// done removes the message from the in-flight registry (and the dedup window) once it is deleted or returned to the queue
+func (r *receiptHandle) done() {
+	if r == nil {
+		return
+	}
+
+	r.forgetDedup()
+
+	if r.tracker == nil {
+		return
+	}
+
+	r.tracker.mu.Lock()
+	// might be already replaced by the next delivery
+	if r.tracker.inFlight[r.id] == r {
+		delete(r.tracker.inFlight, r.id)
+	}
+	r.tracker.mu.Unlock()
+}
+
+// forgetDedup removes the message from the dedup window, nil-safe for the pushed jobs
+func (r *receiptHandle) forgetDedup() {
+	if r != nil && r.forget != nil {
+		r.forget()
+	}
+}
+
+// receiptTracker keeps the receipt handles of the in-flight messages by the message ID
+type receiptTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]*receiptHandle
+}
+
+func newReceiptTracker() *receiptTracker {
+	return &receiptTracker{inFlight: make(map[string]*receiptHandle)}
+}
+
+// track returns the receipt handle of the message, the handle of the in-flight delivery of the same message is updated and shared
+func (t *receiptTracker) track(msg *types.Message, forget func()) *receiptHandle {
+	if t == nil || msg.MessageId == nil {
+		return &receiptHandle{handle: msg.ReceiptHandle, forget: forget}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r, ok := t.inFlight[*msg.MessageId]; ok {
+		r.set(msg.ReceiptHandle)
+		return r
+	}
+
+	r := &receiptHandle{id: *msg.MessageId, handle: msg.ReceiptHandle, tracker: t, forget: forget}
+	t.inFlight[r.id] = r
+
+	return r
+}
+
+// refresh updates the handle of the in-flight delivery of the message (if any), so the ack of the first delivery
+// uses the latest handle even if the redelivered message is not dispatched (e.g. dropped as a duplicate)
+func (t *receiptTracker) refresh(msg *types.Message) {
+	if t == nil || msg.MessageId == nil {
+		return
+	}
+
+	t.mu.Lock()
+	r, ok := t.inFlight[*msg.MessageId]
+	t.mu.Unlock()
+
+	if ok {
+		r.set(msg.ReceiptHandle)
+	}
+}
@@ -0,0 +1,82 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptHandleRefresh(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.receipts = newReceiptTracker()
+	// the redelivered message is dropped as a duplicate
+	c.dedup = newDedupSet(time.Minute, 0)
+
+	var calls int32
+	fc := newFakeClient()
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")}}}, nil
+		case 2:
+			// the visibility timeout expired during the processing
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("body")}}}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) > 2
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, uint64(1), pq.Len())
+	item, ok := pq.ExtractMin().(*Item)
+	require.True(t, ok)
+	require.NoError(t, item.Ack())
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.deleted, 1)
+	require.Equal(t, "receipt-2", aws.ToString(fc.deleted[0].ReceiptHandle))
+	require.Empty(t, c.receipts.inFlight)
+}
+
+func TestReceiptTracker(t *testing.T) {
+	tr := newReceiptTracker()
+	first := tr.track(&types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1")}, nil)
+	// the same message delivered twice shares the handle
+	second := tr.track(&types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-2")}, nil)
+	require.True(t, first == second)
+	require.Equal(t, "receipt-2", aws.ToString(first.get()))
+
+	first.done()
+	require.Empty(t, tr.inFlight)
+
+	// the next delivery is not removed by the late done of the previous one
+	third := tr.track(&types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-3")}, nil)
+	second.done()
+	require.Len(t, tr.inFlight, 1)
+	third.done()
+	require.Empty(t, tr.inFlight)
+
+	// not tracked
+	var nilTracker *receiptTracker
+	r := nilTracker.track(&types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1")}, nil)
+	require.Equal(t, "receipt-1", aws.ToString(r.get()))
+	r.done()
+}
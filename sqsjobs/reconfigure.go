@@ -0,0 +1,170 @@
+package sqsjobs
+
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Reconfigure applies the settings which support live change: wait_time_seconds, visibility_timeout (checked against
+// the worker_timeout), prefetch and pollers.
+// Other changed settings require a pipeline restart, they are logged and skipped.
+func (c *Driver) Reconfigure(conf *Config) {
+	pipe := *c.pipeline.Load()
+
+	if skipped := c.restartRequired(conf); len(skipped) > 0 {
+		c.log.Warn("settings require the pipeline restart, skipped", zap.String("pipeline", pipe.Name()), zap.Strings("settings", skipped))
+	}
+
+	wait, err := receiveWaitTime(conf.WaitTimeSeconds)
+	if err != nil {
+		c.log.Warn("wait_time_seconds was not changed", zap.String("pipeline", pipe.Name()), zap.Error(err))
+	} else {
+		atomic.StoreInt32(&c.waitTime, wait)
+	}
+	atomic.StoreInt32(&c.visibilityTimeout, conf.VisibilityTimeout)
+	c.checkVisibility(conf.WorkerTimeout, conf.VisibilityMargin, conf.AutoAdjustVisibility)
+
+	if conf.Prefetch > 0 {
+		atomic.StoreInt32(c.msgInFlightLimit, conf.Prefetch)
+		// limit might be increased, wake up the listeners
+		c.cond.Broadcast()
+	}
+
+	if atomic.SwapInt64(&c.maxInFlightBytes, conf.MaxInFlightBytes) != conf.MaxInFlightBytes {
+		c.cond.Broadcast()
+	}
+
+	c.setPollers(c.adaptive.clamp(conf.Pollers))
+
+	// compare the next reload against this one, the skipped settings are reported once
+	c.conf = conf
+
+	c.log.Debug("pipeline was reconfigured", zap.String("pipeline", pipe.Name()), zap.Int32("wait_time_seconds", atomic.LoadInt32(&c.waitTime)), zap.Int32("visibility_timeout", atomic.LoadInt32(&c.visibilityTimeout)), zap.Int32("prefetch", conf.Prefetch), zap.Int("pollers", conf.Pollers))
+}
+
+// restartRequired returns the names of the changed settings which can't be applied to the running pipeline
+func (c *Driver) restartRequired(conf *Config) []string {
+	if c.conf == nil {
+		return nil
+	}
+
+	prev := c.conf
+	var changed []string
+	check := func(name string, diff bool) {
+		if diff {
+			changed = append(changed, name)
+		}
+	}
+
+	check(queue, getordefault(prev.Queue) != getordefault(conf.Queue))
+	check(queuePrefix, prev.QueuePrefix != conf.QueuePrefix)
+	check(queueOwnerAccountID, prev.QueueOwnerAccountID != conf.QueueOwnerAccountID)
+	check(endpointOpt, prev.Endpoint != conf.Endpoint || prev.Partition != conf.Partition)
+	check("region", prev.Region != conf.Region)
+	check("metadata_endpoint", prev.MetadataEndpoint != conf.MetadataEndpoint)
+	check("inside_aws", prev.InsideAWS != conf.InsideAWS)
+	// never log the values, only the fact of the change
+	check("credentials", prev.Key != conf.Key || prev.Secret != conf.Secret || prev.SessionToken != conf.SessionToken)
+	check(profile, prev.Profile != conf.Profile)
+	check(sharedConfigFile, prev.SharedConfigFile != conf.SharedConfigFile)
+	check(sharedCredsFile, prev.SharedCredentialsFile != conf.SharedCredentialsFile)
+	check(credentialChainOpt, !slices.Equal(prev.CredentialChain, conf.CredentialChain))
+	check(roleARN, prev.RoleARN != conf.RoleARN || prev.ExternalID != conf.ExternalID || prev.RoleSessionName != conf.RoleSessionName || prev.WebIdentityTokenFile != conf.WebIdentityTokenFile)
+	check(clientMaxLifetime, prev.ClientMaxLifetime != conf.ClientMaxLifetime)
+	check("adaptive_pollers", prev.AdaptiveMinPollers != conf.AdaptiveMinPollers || prev.AdaptiveMaxPollers != conf.AdaptiveMaxPollers)
+	check(dnsCacheTTL, prev.DNSCacheTTL != conf.DNSCacheTTL)
+	check(httpClientOpt, prev.HTTPClient != conf.HTTPClient)
+	check(endpointDiscovery, prev.EndpointDiscovery != conf.EndpointDiscovery)
+	check(networkRetries, prev.NetworkRetries != conf.NetworkRetries)
+	check(retryBudgetOpt, prev.RetryBudget != conf.RetryBudget || prev.RetryBudgetRefill != conf.RetryBudgetRefill)
+	check("encryption", prev.EncryptionKey != conf.EncryptionKey || prev.EncryptionKeyEnv != conf.EncryptionKeyEnv)
+	check(skipQueueDeclaration, prev.SkipQueueDeclaration != conf.SkipQueueDeclaration)
+	check(deleteOnStop, prev.DeleteOnStop != conf.DeleteOnStop)
+	check(queueEventsBuffer, prev.QueueEventsBuffer != conf.QueueEventsBuffer)
+	check(eventsBuffer, prev.EventsBuffer != conf.EventsBuffer)
+	check(maxJobAge, prev.MaxJobAge != conf.MaxJobAge || prev.MaxMessageAge != conf.MaxMessageAge)
+	check(expiredQueueOpt, prev.ExpiredQueue != conf.ExpiredQueue)
+	check(groupMaxParked, prev.StrictGroupOrdering != conf.StrictGroupOrdering || prev.GroupMaxParked != conf.GroupMaxParked)
+	check(inMemoryOpt, prev.InMemory != conf.InMemory || prev.Chaos != conf.Chaos)
+	check(fanOutQueuesOpt, !slices.Equal(prev.FanOutQueues, conf.FanOutQueues))
+	check(recreateQueueOpt, lookupEnabled(prev.RecreateQueue) != lookupEnabled(conf.RecreateQueue))
+	check(maxMessagesPerPoll, prev.MaxMessagesPerPoll != conf.MaxMessagesPerPoll)
+	check(messageAttrNames, !slices.Equal(prev.MessageAttributeNames, conf.MessageAttributeNames))
+	check(systemAttrNames, !slices.Equal(prev.MessageSystemAttributeNames, conf.MessageSystemAttributeNames))
+	check("failover", prev.FailoverQueue != conf.FailoverQueue || prev.FailoverRegion != conf.FailoverRegion ||
+		prev.FailoverThreshold != conf.FailoverThreshold || prev.FailoverProbeInterval != conf.FailoverProbeInterval || prev.FailoverReceive != conf.FailoverReceive)
+	check(fastRequeueShutdown, prev.FastRequeueOnShutdown != conf.FastRequeueOnShutdown)
+	check(drainTimeout, prev.DrainTimeout != conf.DrainTimeout)
+	check(healthCheckOpt, prev.HealthCheckInterval != conf.HealthCheckInterval)
+	check("circuit_breaker", prev.CircuitBreakerThreshold != conf.CircuitBreakerThreshold || prev.CircuitBreakerCooldown != conf.CircuitBreakerCooldown)
+	check(handlerTimeout, prev.HandlerTimeout != conf.HandlerTimeout)
+	check(heartbeatOpt, prev.VisibilityHeartbeat != conf.VisibilityHeartbeat || prev.MaxVisibilityExtension != conf.MaxVisibilityExtension)
+	check(messageGroupID, prev.MessageGroupID != conf.MessageGroupID)
+	check(messageDedupID, prev.MessageDeduplicationID != conf.MessageDeduplicationID)
+	check(fifoOpt, prev.FIFO != conf.FIFO)
+	check(contentDedup, prev.ContentBasedDeduplication != conf.ContentBasedDeduplication)
+	check(deadLetterQueue, prev.DeadLetterQueue != conf.DeadLetterQueue)
+	check(maxReceiveCount, prev.MaxReceiveCount != conf.MaxReceiveCount)
+	check(s3Bucket, prev.S3Bucket != conf.S3Bucket || prev.S3Prefix != conf.S3Prefix || prev.AlwaysThroughS3 != conf.AlwaysThroughS3 || prev.S3Threshold != conf.S3Threshold || prev.S3OffloadAttributes != conf.S3OffloadAttributes)
+	check(maxAppRetries, prev.MaxAppRetries != conf.MaxAppRetries)
+	check(redriveRate, prev.RedriveRate != conf.RedriveRate)
+	check(lookupBeforeCreate, lookupEnabled(prev.LookupBeforeCreate) != lookupEnabled(conf.LookupBeforeCreate))
+	check(dedupStoreTTL, prev.DedupStoreTTL != conf.DedupStoreTTL)
+	check(enrichRetryDelay, prev.EnrichRetryDelay != conf.EnrichRetryDelay)
+	check(skewThresholdOpt, prev.TimestampSkewThreshold != conf.TimestampSkewThreshold)
+	check(shardIndex, prev.ShardIndex != conf.ShardIndex || prev.ShardCount != conf.ShardCount)
+	check(verifyMD5, prev.VerifyMD5 != conf.VerifyMD5)
+	check(payloadWarnBytes, prev.PayloadWarnBytes != conf.PayloadWarnBytes)
+	check(snsUnwrap, prev.SNSUnwrap != conf.SNSUnwrap)
+	check(statsMaxStaleness, prev.StatsMaxStaleness != conf.StatsMaxStaleness)
+	check(overLimitBackoffOpt, prev.InFlightLimitBackoff != conf.InFlightLimitBackoff)
+	check(routeAttribute, prev.RouteAttribute != conf.RouteAttribute || !slices.Equal(prev.RoutePipelines, conf.RoutePipelines))
+	check(retryQueue, prev.RetryQueue != conf.RetryQueue || prev.RetryDelay != conf.RetryDelay)
+	check(rateLimitOpt, prev.RateLimit != conf.RateLimit || prev.RateLimitBurst != conf.RateLimitBurst)
+	check(nackBackoffOpt, prev.NackBackoff != conf.NackBackoff || prev.NackBackoffBase != conf.NackBackoffBase || prev.NackBackoffMax != conf.NackBackoffMax)
+	check(onNackOpt, prev.OnNack != conf.OnNack || prev.NackReleaseDelay != conf.NackReleaseDelay)
+	check(dispatchBuffer, prev.DispatchBuffer != conf.DispatchBuffer)
+	check(warmPoolSize, prev.WarmPoolSize != conf.WarmPoolSize)
+	check(scaleToZeroIdle, prev.ScaleToZeroIdle != conf.ScaleToZeroIdle)
+	check(batchSize, prev.BatchSize != conf.BatchSize)
+	check(maxBatchBytesOpt, prev.MaxBatchBytes != conf.MaxBatchBytes)
+	check(sendBatchOpt, prev.SendBatch != conf.SendBatch)
+	check(deleteBatchOpt, prev.DeleteBatch != conf.DeleteBatch)
+	check(deleteFlushCount, prev.DeleteFlushCount != conf.DeleteFlushCount)
+	check(splitArrays, prev.SplitOversizedArrays != conf.SplitOversizedArrays)
+	check(bodyFormat, prev.BodyFormat != conf.BodyFormat)
+	check(bodyEncoding, prev.BodyEncoding != conf.BodyEncoding)
+	check(replyAllowedQueues, !slices.Equal(prev.ReplyAllowedQueues, conf.ReplyAllowedQueues))
+	check(compression, prev.Compression != conf.Compression || prev.GzipLevel != conf.GzipLevel || prev.CompressionThreshold != conf.CompressionThreshold ||
+		prev.GzipDictionary != conf.GzipDictionary || prev.MaxDecompressedSize != conf.MaxDecompressedSize)
+	check("lease", prev.LeaseTTL != conf.LeaseTTL || prev.LeaseRetryDelay != conf.LeaseRetryDelay)
+	check("hints", prev.PriorityAttribute != conf.PriorityAttribute || prev.DelayAttribute != conf.DelayAttribute || prev.JobAttribute != conf.JobAttribute || prev.DefaultJob != conf.DefaultJob)
+	check(metadataMode, prev.MetadataMode != conf.MetadataMode)
+	check(deliveryMode, prev.Delivery != conf.Delivery)
+	check(partitionKeyOpt, prev.PartitionKeyAttribute != conf.PartitionKeyAttribute)
+	check(usePriorityQueue, priorityQueueEnabled(prev.UsePriorityQueue) != priorityQueueEnabled(conf.UsePriorityQueue))
+	check(correlationAttribute, prev.CorrelationAttribute != conf.CorrelationAttribute)
+	check(replyToAttribute, prev.ReplyToAttribute != conf.ReplyToAttribute || prev.ReplyQueue != conf.ReplyQueue)
+	check(sourceQueueHeader, prev.SourceQueueHeader != conf.SourceQueueHeader)
+	check(invalidBodyPolicy, prev.InvalidBodyPolicy != conf.InvalidBodyPolicy || prev.InvalidBodyQueue != conf.InvalidBodyQueue)
+	check(preserveAttrTypes, prev.PreserveAttributeTypes != conf.PreserveAttributeTypes)
+	check(executeAtAttribute, prev.ExecuteAtAttribute != conf.ExecuteAtAttribute)
+	check(deadlineAttribute, prev.DeadlineAttribute != conf.DeadlineAttribute)
+	check(tracing, prev.Tracing != conf.Tracing)
+	check(latencyMetricsOpt, prev.LatencyMetrics != conf.LatencyMetrics || !slices.Equal(prev.LatencyBuckets, conf.LatencyBuckets))
+	check(emptyBodyPolicy, prev.EmptyBodyPolicy != conf.EmptyBodyPolicy)
+	check(dedupWindow, prev.DedupWindow != conf.DedupWindow || prev.DedupDelete != conf.DedupDelete || prev.DedupMaxEntries != conf.DedupMaxEntries)
+	check(dedupAttribute, prev.DedupAttribute != conf.DedupAttribute)
+	check(sseManaged, prev.SSEManaged != conf.SSEManaged)
+	check(setQueueWaitTime, prev.SetQueueWaitTime != conf.SetQueueWaitTime)
+	check("headers", !slices.Equal(prev.PropagateHeaders, conf.PropagateHeaders) || !slices.Equal(prev.RedactHeaders, conf.RedactHeaders))
+	check(attributes, !maps.Equal(prev.Attributes, conf.Attributes))
+	check(priorityMap, !maps.Equal(prev.PriorityMap, conf.PriorityMap))
+	check(headerAttributesOpt, !maps.Equal(prev.HeaderAttributes, conf.HeaderAttributes) || !maps.Equal(prev.AttributeHeaders, conf.AttributeHeaders))
+	check(tags, !maps.Equal(prev.Tags, conf.Tags))
+
+	return changed
+}
@@ -0,0 +1,73 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestReconfigurePollers(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	c := newTestDriver(&testQueue{}, nil)
+	c.log = zap.New(core)
+	c.conf = &Config{Queue: aws.String("test"), Pollers: 1, Prefetch: 10, WaitTimeSeconds: 5}
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	t.Cleanup(func() {
+		require.NoError(t, c.Stop(context.Background()))
+	})
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&c.activePollers) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// simulate the reload
+	c.Reconfigure(&Config{Queue: aws.String("test"), Pollers: 3, Prefetch: 20, WaitTimeSeconds: 1, VisibilityTimeout: 30})
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&c.activePollers) == 3
+	}, time.Second*5, time.Millisecond*10)
+
+	require.Equal(t, int32(20), atomic.LoadInt32(c.msgInFlightLimit))
+	require.Equal(t, int32(1), atomic.LoadInt32(&c.waitTime))
+	require.Equal(t, int32(30), atomic.LoadInt32(&c.visibilityTimeout))
+	require.Equal(t, 0, logs.Len())
+
+	// scale down, queue change requires a restart
+	c.Reconfigure(&Config{Queue: aws.String("other"), Pollers: 1, Prefetch: 20, WaitTimeSeconds: 1})
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&c.activePollers) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	require.Equal(t, []interface{}{queue}, entries[0].ContextMap()["settings"])
+
+	// the same configuration again, the skipped settings are not reported twice
+	c.Reconfigure(&Config{Queue: aws.String("other"), Pollers: 1, Prefetch: 20, WaitTimeSeconds: 1})
+	require.Equal(t, 1, logs.Len())
+}
+
+func TestReconfigurePaused(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	// not started, applied on run
+	c.Reconfigure(&Config{Pollers: 2})
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.activePollers))
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&c.activePollers) == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, c.Pause(context.Background(), "test"))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&c.activePollers) == 0
+	}, time.Second*5, time.Millisecond*10)
+}
@@ -0,0 +1,67 @@
+package sqsjobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// queueRecreateDebounce is the time the recreated (or re-resolved) queue is trusted, the QueueDoesNotExist errors of
+// the calls started before the recreation don't trigger another attempt
+const queueRecreateDebounce = time.Second * 5
+
+// recreateQueue handles the queue deleted out-of-band: the queue URL is resolved again and, unless disabled
+// (recreate_queue: false, skip_queue_declaration, queue_owner_account_id), the queue is created again, waiting out
+// the 60 seconds QueueDeletedRecently lockout. The concurrent callers (the pollers and the pushes) share a single attempt.
+func (c *Driver) recreateQueue(ctx context.Context) error {
+	c.recreateMu.Lock()
+	defer c.recreateMu.Unlock()
+
+	if time.Since(c.recreatedAt) < queueRecreateDebounce {
+		return nil
+	}
+
+	url, err := getQueueURL(ctx, c.client, c.queue, c.queueOwner)
+	if err == nil {
+		// recreated externally
+		c.recreatedAt = time.Now()
+		c.checkRecreatedURL(url)
+		return nil
+	}
+
+	if !isNonExistentQueue(err) {
+		return err
+	}
+
+	if c.skipDeclare || !c.recreate {
+		return errors.Errorf("queue %s does not exist, recreation is disabled: %v", getordefault(c.queue), err)
+	}
+
+	c.log.Warn("queue does not exist, recreating", zap.Stringp("queue", c.queue))
+	url, err = c.createQueueRetry(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.waitQueueReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.recreatedAt = time.Now()
+	c.checkRecreatedURL(url)
+	c.emitQueueEvent(ctx, QueueRecreated, nil)
+	c.log.Info("queue was recreated", zap.Stringp("queue", c.queue), zap.Stringp("url", url))
+
+	return nil
+}
+
+// checkRecreatedURL compares the URL of the recreated queue with the one in use. The URL is derived from the account
+// and the queue name, so it's the same unless the queue moved (e.g. to another endpoint), that requires the restart.
+func (c *Driver) checkRecreatedURL(url *string) {
+	if getordefault(url) != getordefault(c.queueURL) {
+		c.log.Error("queue URL changed after the queue recreation, restart the pipeline", zap.Stringp("queue", c.queue), zap.Stringp("url", c.queueURL), zap.Stringp("new_url", url))
+	}
+}
@@ -0,0 +1,79 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+// deletedQueue fakes the queue deleted out-of-band, it exists again once created
+func deletedQueue(fc *fakeClient) {
+	var created atomic.Bool
+	fc.getURLFn = func(_ context.Context, params *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+		if !created.Load() {
+			return nil, &types.QueueDoesNotExist{Message: aws.String("no such queue")}
+		}
+		return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(params.QueueName))}, nil
+	}
+	fc.createFn = func(_ context.Context, params *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+		created.Store(true)
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://127.0.0.1:9324/000000000000/" + aws.ToString(params.QueueName))}, nil
+	}
+}
+
+func TestRecreateQueue(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	deletedQueue(fc)
+	c.client = fc
+
+	// disabled
+	require.Error(t, c.recreateQueue(context.Background()))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+
+	c.recreate = true
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, c.recreateQueue(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// a single attempt for the concurrent callers
+	require.Equal(t, 1, fc.called("CreateQueue"))
+}
+
+func TestRecreateQueueOnReceive(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.recreate = true
+	fc := newFakeClient()
+	deletedQueue(fc)
+
+	var deleted atomic.Bool
+	deleted.Store(true)
+	messages := receiveOnce(types.Message{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String("body")})
+	fc.receiveFn = func(ctx context.Context, in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		if deleted.Swap(false) {
+			return nil, &types.QueueDoesNotExist{Message: aws.String("no such queue")}
+		}
+		return messages(ctx, in)
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, 1, fc.called("CreateQueue"))
+}
@@ -0,0 +1,382 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// the empty long poll of the dead-letter queue ends the redrive
+	redriveWaitTime int32 = 2
+	// the progress is logged and the remaining count is refreshed once per interval
+	redriveReportInterval = time.Second * 10
+)
+
+// checkRedriveRate validates the redrive_rate option
+func checkRedriveRate(rate int) error {
+	if rate < 0 {
+		return errors.Errorf("redrive_rate should not be negative, provided: %d", rate)
+	}
+
+	return nil
+}
+
+// RedriveProgress is the state of the last dead-letter queue redrive
+type RedriveProgress struct {
+	// Running is true until the dead-letter queue is empty, the redrive is stopped or failed
+	Running bool `json:"running"`
+	Paused  bool `json:"paused"`
+	// Rate is the pace of the redrive in messages per second, 0 - unlimited
+	Rate int `json:"rate"`
+	// Moved is the number of messages moved back to the queue
+	Moved int64 `json:"moved"`
+	// Remaining is the approximate number of messages in the dead-letter queue at the last report, -1 if unknown
+	Remaining int64 `json:"remaining"`
+	// Error is the error the redrive was stopped with
+	Error string `json:"error,omitempty"`
+}
+
+type redriveRun struct {
+	mu       sync.Mutex
+	progress RedriveProgress
+	// closed on resume, nil if not paused
+	resumed chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Redrive starts moving the messages from the dead-letter queue back to the queue in background, one redrive at a time.
+// The moves are paced by the rate (messages per second), rate <= 0 - redrive_rate. The redrive ends when the
+// dead-letter queue is empty. The progress is logged periodically and returned by RedriveProgress.
+func (c *Driver) Redrive(rate int) error {
+	if c.dlqURL == nil {
+		return errors.Str("dead-letter queue is not configured")
+	}
+
+	if rate <= 0 {
+		rate = c.redriveRate
+	}
+
+	c.redriveMu.Lock()
+	defer c.redriveMu.Unlock()
+
+	if c.redrive != nil && c.redrive.state().Running {
+		return errors.Str("redrive is already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &redriveRun{
+		progress: RedriveProgress{Running: true, Rate: rate, Remaining: -1},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	c.redrive = r
+
+	go c.runRedrive(ctx, r, rate)
+
+	return nil
+}
+
+// PauseRedrive pauses the running redrive, the messages already received from the dead-letter queue are still moved
+func (c *Driver) PauseRedrive() error {
+	r := c.currentRedrive()
+	if r == nil {
+		return errors.Str("redrive is not running")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.resumed == nil {
+		r.resumed = make(chan struct{})
+	}
+	r.progress.Paused = true
+
+	return nil
+}
+
+// ResumeRedrive resumes the paused redrive
+func (c *Driver) ResumeRedrive() error {
+	r := c.currentRedrive()
+	if r == nil {
+		return errors.Str("redrive is not running")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.resumed != nil {
+		close(r.resumed)
+		r.resumed = nil
+	}
+	r.progress.Paused = false
+
+	return nil
+}
+
+// StopRedrive stops the running redrive and waits for the current move, no-op if not running
+func (c *Driver) StopRedrive() {
+	r := c.currentRedrive()
+	if r == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+}
+
+// RedriveProgress returns the progress of the last redrive, the zero value if no redrive was started
+func (c *Driver) RedriveProgress() RedriveProgress {
+	c.redriveMu.Lock()
+	r := c.redrive
+	c.redriveMu.Unlock()
+
+	if r == nil {
+		return RedriveProgress{}
+	}
+
+	return r.state()
+}
+
+// currentRedrive returns the running redrive, nil if none
+func (c *Driver) currentRedrive() *redriveRun {
+	c.redriveMu.Lock()
+	defer c.redriveMu.Unlock()
+
+	if c.redrive == nil || !c.redrive.state().Running {
+		return nil
+	}
+
+	return c.redrive
+}
+
+func (c *Driver) runRedrive(ctx context.Context, r *redriveRun, rate int) {
+	defer close(r.done)
+	defer r.cancel()
+
+	pacer := newRedrivePacer(rate)
+	// a paused redrive holds at most a second of the messages invisible
+	batch := maxBatchEntries
+	if rate > 0 && rate < batch {
+		batch = rate
+	}
+
+	report := time.NewTicker(redriveReportInterval)
+	defer report.Stop()
+
+	c.log.Info("dead-letter queue redrive started", zap.Stringp("dead-letter queue", c.dlqURL), zap.Int("rate", rate))
+	c.refreshRedrive(ctx, r)
+
+	for {
+		err := r.waitResumed(ctx)
+		if err != nil {
+			r.finish(nil)
+			c.log.Info("dead-letter queue redrive was stopped", zap.Int64("moved", r.state().Moved))
+			return
+		}
+
+		select {
+		case <-report.C:
+			c.refreshRedrive(ctx, r)
+			st := r.state()
+			c.log.Info("dead-letter queue redrive progress", zap.Int64("moved", st.Moved), zap.Int64("remaining", st.Remaining), zap.Bool("paused", st.Paused))
+		default:
+		}
+
+		out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              c.dlqURL,
+			MaxNumberOfMessages:   int32(batch),
+			AttributeNames:        []types.QueueAttributeName{types.QueueAttributeName(MessageGroupIDAttr)},
+			MessageAttributeNames: []string{All},
+			WaitTimeSeconds:       redriveWaitTime,
+		})
+		if err != nil {
+			c.stopRedriveWith(ctx, r, err)
+			return
+		}
+
+		if len(out.Messages) == 0 {
+			r.finish(nil)
+			r.setRemaining(0)
+			c.log.Info("dead-letter queue redrive finished", zap.Int64("moved", r.state().Moved))
+			return
+		}
+
+		for i := 0; i < len(out.Messages); i++ {
+			err = pacer.wait(ctx)
+			if err == nil {
+				err = c.redriveMessage(ctx, &out.Messages[i])
+			}
+			if err != nil {
+				c.stopRedriveWith(ctx, r, err)
+				return
+			}
+			r.moved()
+		}
+	}
+}
+
+// stopRedriveWith records the redrive error, the cancellation is not an error
+func (c *Driver) stopRedriveWith(ctx context.Context, r *redriveRun, err error) {
+	if ctx.Err() != nil {
+		r.finish(nil)
+		c.log.Info("dead-letter queue redrive was stopped", zap.Int64("moved", r.state().Moved))
+		return
+	}
+
+	r.finish(err)
+	c.log.Error("dead-letter queue redrive failed", zap.Int64("moved", r.state().Moved), zap.Error(err))
+}
+
+// redriveMessage sends the message back to the queue without the dead-letter metadata and deletes it from the dead-letter queue
+func (c *Driver) redriveMessage(ctx context.Context, m *types.Message) error {
+	attrs := make(map[string]types.MessageAttributeValue, len(m.MessageAttributes))
+	for k, v := range m.MessageAttributes {
+		switch k {
+		case DLQOriginalQueue, DLQReceiveCount, DLQFirstFailure, DLQLastError:
+			continue
+		}
+		attrs[k] = v
+	}
+
+	group := m.Attributes[MessageGroupIDAttr]
+	if group == "" {
+		group = c.messageGroupID
+	}
+
+	_, err := c.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:               c.queueURL,
+		MessageBody:            m.Body,
+		MessageAttributes:      attrs,
+		MessageDeduplicationId: dedup(getordefault(m.MessageId), c.queueURL),
+		MessageGroupId:         mgr(group),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      c.dlqURL,
+		ReceiptHandle: m.ReceiptHandle,
+	})
+
+	return err
+}
+
+// refreshRedrive updates the remaining count, the previous one is kept if the dead-letter queue can't be read
+func (c *Driver) refreshRedrive(ctx context.Context, r *redriveRun) {
+	n, err := c.queueDepth(ctx, c.dlqURL)
+	if err != nil {
+		c.log.Debug("failed to read the dead-letter queue depth", zap.Error(err))
+		return
+	}
+
+	r.setRemaining(n)
+}
+
+// queueDepth returns the approximate number of the visible messages in the queue
+func (c *Driver) queueDepth(ctx context.Context, url *string) (int64, error) {
+	attr, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       url,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	nom, err := strconv.ParseInt(attr.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("malformed %s: %v", types.QueueAttributeNameApproximateNumberOfMessages, err)
+	}
+
+	return nom, nil
+}
+
+func (r *redriveRun) state() RedriveProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.progress
+}
+
+func (r *redriveRun) moved() {
+	r.mu.Lock()
+	r.progress.Moved++
+	if r.progress.Remaining > 0 {
+		r.progress.Remaining--
+	}
+	r.mu.Unlock()
+}
+
+func (r *redriveRun) setRemaining(n int64) {
+	r.mu.Lock()
+	r.progress.Remaining = n
+	r.mu.Unlock()
+}
+
+func (r *redriveRun) finish(err error) {
+	r.mu.Lock()
+	r.progress.Running = false
+	r.progress.Paused = false
+	if err != nil {
+		r.progress.Error = err.Error()
+	}
+	r.mu.Unlock()
+}
+
+// waitResumed blocks while the redrive is paused
+func (r *redriveRun) waitResumed(ctx context.Context) error {
+	r.mu.Lock()
+	resumed := r.resumed
+	r.mu.Unlock()
+
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+		}
+	}
+
+	return ctx.Err()
+}
+
+// redrivePacer spaces the moves evenly, so the redrive never exceeds the rate, nil - unlimited
+type redrivePacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newRedrivePacer(rate int) *redrivePacer {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &redrivePacer{interval: time.Second / time.Duration(rate)}
+}
+
+// wait blocks until the next move is allowed, the time spent paused is not carried over as a burst
+func (p *redrivePacer) wait(ctx context.Context) error {
+	if p == nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	if d := p.next.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		now = p.next
+	}
+
+	p.next = now.Add(p.interval)
+	return nil
+}
@@ -0,0 +1,164 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+// dlqBacklog returns the messages of the dead-letter queue in the receive batches, empty responses afterward
+func dlqBacklog(n int) func(context.Context, *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	var mu sync.Mutex
+	next := 0
+	return func(_ context.Context, in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		out := &sqs.ReceiveMessageOutput{}
+		for ; next < n && len(out.Messages) < int(in.MaxNumberOfMessages); next++ {
+			out.Messages = append(out.Messages, types.Message{
+				MessageId:     aws.String(strconv.Itoa(next)),
+				ReceiptHandle: aws.String("rh-" + strconv.Itoa(next)),
+				Body:          aws.String("body"),
+				MessageAttributes: map[string]types.MessageAttributeValue{
+					"tenant":         {DataType: aws.String(StringType), StringValue: aws.String("acme")},
+					DLQLastError:     {DataType: aws.String(StringType), StringValue: aws.String("boom")},
+					DLQOriginalQueue: {DataType: aws.String(StringType), StringValue: aws.String("orders")},
+				},
+			})
+		}
+		return out, nil
+	}
+}
+
+func newRedriveDriver(fc *fakeClient, rate int) *Driver {
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = fc
+	c.queueURL = aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/orders")
+	c.dlqURL = aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/orders-dlq")
+	c.redriveRate = rate
+	return c
+}
+
+func waitRedrive(t *testing.T, c *Driver) RedriveProgress {
+	t.Helper()
+	require.Eventually(t, func() bool { return !c.RedriveProgress().Running }, time.Second*10, time.Millisecond*5)
+	return c.RedriveProgress()
+}
+
+func TestRedriveRate(t *testing.T) {
+	const total, rate = 20, 50
+
+	fc := newFakeClient()
+	fc.receiveFn = dlqBacklog(total)
+	var mu sync.Mutex
+	var sends []time.Time
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		mu.Lock()
+		sends = append(sends, time.Now())
+		mu.Unlock()
+		return &sqs.SendMessageOutput{}, nil
+	}
+
+	c := newRedriveDriver(fc, rate)
+	require.NoError(t, c.Redrive(0))
+	st := waitRedrive(t, c)
+
+	require.Empty(t, st.Error)
+	require.Equal(t, int64(total), st.Moved)
+	require.Equal(t, int64(0), st.Remaining)
+	require.Equal(t, rate, st.Rate)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, sends, total)
+	// the evenly spaced moves: n messages take at least (n-1)/rate
+	elapsed := sends[len(sends)-1].Sub(sends[0])
+	require.GreaterOrEqual(t, elapsed, time.Second*(total-1)/rate)
+	require.LessOrEqual(t, float64(total-1)/elapsed.Seconds(), float64(rate))
+
+	// no more than a second of the messages is received at once
+	for _, in := range fc.received {
+		require.LessOrEqual(t, in.MaxNumberOfMessages, int32(maxBatchEntries))
+		require.Equal(t, c.dlqURL, in.QueueUrl)
+	}
+
+	for _, in := range fc.sent {
+		require.Equal(t, c.queueURL, in.QueueUrl)
+		require.Contains(t, in.MessageAttributes, "tenant")
+		require.NotContains(t, in.MessageAttributes, DLQLastError)
+		require.NotContains(t, in.MessageAttributes, DLQOriginalQueue)
+	}
+	require.Len(t, fc.deleted, total)
+	require.Equal(t, c.dlqURL, fc.deleted[0].QueueUrl)
+}
+
+func TestRedrivePauseResume(t *testing.T) {
+	const total = 40
+
+	fc := newFakeClient()
+	fc.receiveFn = dlqBacklog(total)
+	c := newRedriveDriver(fc, 100)
+
+	require.Error(t, c.PauseRedrive())
+	require.NoError(t, c.Redrive(0))
+	require.Error(t, c.Redrive(0))
+
+	require.Eventually(t, func() bool { return c.RedriveProgress().Moved >= 5 }, time.Second*5, time.Millisecond)
+	require.NoError(t, c.PauseRedrive())
+	require.True(t, c.RedriveProgress().Paused)
+
+	// the received batch is moved, then the redrive stays paused
+	var moved int64
+	require.Eventually(t, func() bool {
+		m := c.RedriveProgress().Moved
+		stable := m == moved
+		moved = m
+		return stable && m%10 == 0
+	}, time.Second*5, time.Millisecond*200)
+	require.Less(t, moved, int64(total))
+	require.True(t, c.RedriveProgress().Running)
+
+	require.NoError(t, c.ResumeRedrive())
+	st := waitRedrive(t, c)
+	require.False(t, st.Paused)
+	require.Equal(t, int64(total), st.Moved)
+}
+
+func TestRedriveStopAndErrors(t *testing.T) {
+	fc := newFakeClient()
+	fc.receiveFn = dlqBacklog(1000)
+	c := newRedriveDriver(fc, 10)
+
+	require.NoError(t, c.Redrive(0))
+	require.Eventually(t, func() bool { return c.RedriveProgress().Moved >= 1 }, time.Second*5, time.Millisecond)
+	c.StopRedrive()
+	st := c.RedriveProgress()
+	require.False(t, st.Running)
+	require.Empty(t, st.Error)
+	require.Less(t, st.Moved, int64(1000))
+
+	// the failed send stops the redrive, the message stays in the dead-letter queue
+	fc = newFakeClient()
+	fc.receiveFn = dlqBacklog(5)
+	fc.sendFn = func(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		return nil, &types.QueueDoesNotExist{}
+	}
+	c = newRedriveDriver(fc, 0)
+	require.NoError(t, c.Redrive(0))
+	st = waitRedrive(t, c)
+	require.NotEmpty(t, st.Error)
+	require.Equal(t, int64(0), st.Moved)
+	require.Equal(t, 0, fc.called("DeleteMessage"))
+
+	c.dlqURL = nil
+	require.Error(t, c.Redrive(0))
+	require.Error(t, checkRedriveRate(-1))
+}
@@ -0,0 +1,98 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// maxRedriveReceiveCount is the SQS limit of the redrive policy maxReceiveCount
+const maxRedriveReceiveCount int = 1000
+
+// redrivePolicy is the RedrivePolicy queue attribute
+type redrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     string `json:"maxReceiveCount"`
+}
+
+// checkMaxReceiveCount validates the max_receive_count option, the explicit RedrivePolicy attribute can't be combined with it
+func checkMaxReceiveCount(count int, dlq string, attrs map[string]string) error {
+	if count < 0 || count > maxRedriveReceiveCount {
+		return errors.Errorf("max_receive_count should be in the range [0, %d], provided: %d", maxRedriveReceiveCount, count)
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	if dlq == "" {
+		return errors.Str("max_receive_count requires the dead_letter_queue")
+	}
+
+	if _, ok := attrs[RedrivePolicyAWS]; ok {
+		return errors.Errorf("max_receive_count conflicts with the %s attribute", RedrivePolicyAWS)
+	}
+
+	return nil
+}
+
+// declareDLQ creates the dead-letter queue (if missing) and sets the RedrivePolicy attribute of the queue to it (max_receive_count).
+// Called before the queue declaration, so the policy is applied on create. The explicitly configured dead-letter queue URL is not created.
+func (c *Driver) declareDLQ(ctx context.Context, dlq *string) error {
+	if c.maxReceiveCount == 0 || dlq == nil {
+		return nil
+	}
+
+	// the queue attributes are not applied without the declaration
+	if c.skipDeclare {
+		c.log.Warn("max_receive_count is ignored with skip_queue_declaration, the redrive policy of the queue is not changed", zap.Stringp("dead-letter queue", dlq))
+		return nil
+	}
+
+	var err error
+	if c.dlqURL == nil {
+		attrs := make(map[string]string, 1)
+		// the dead-letter queue of the FIFO queue should be the FIFO queue
+		if strings.HasSuffix(*dlq, fifoSuffix) {
+			attrs[FifoQueueAWS] = "true"
+		}
+
+		c.dlqURL, err = createQueue(ctx, c.client, dlq, attrs, c.tags)
+		if err != nil {
+			return errors.Errorf("failed to declare the dead-letter queue %s: %v", *dlq, err)
+		}
+	}
+
+	out, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       c.dlqURL,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return errors.Errorf("failed to get the dead-letter queue ARN: %v", err)
+	}
+
+	arn := out.Attributes[QueueArnAWS]
+	if arn == "" {
+		return errors.Errorf("dead-letter queue %s has no ARN", *c.dlqURL)
+	}
+
+	policy, err := json.Marshal(redrivePolicy{DeadLetterTargetArn: arn, MaxReceiveCount: strconv.Itoa(c.maxReceiveCount)})
+	if err != nil {
+		return err
+	}
+
+	if c.attributes == nil {
+		c.attributes = make(map[string]string, 1)
+	}
+	c.attributes[RedrivePolicyAWS] = string(policy)
+
+	c.log.Debug("redrive policy is set", zap.String("dead_letter_target_arn", arn), zap.Int("max_receive_count", c.maxReceiveCount))
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package sqsjobs
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclareDLQRedrivePolicy(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.maxReceiveCount = 5
+	c.attributes = map[string]string{}
+
+	fc := newFakeClient()
+	fc.getAttrsFn = func(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{QueueArnAWS: "arn:aws:sqs:us-east-1:000000000000:" + path.Base(aws.ToString(in.QueueUrl))}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.setup(time.Second*5, true, aws.String("test-dlq")))
+	require.Equal(t, 2, fc.called("CreateQueue"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/test-dlq", aws.ToString(c.dlqURL))
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	// the dead-letter queue is created first, the queue is created with the redrive policy
+	require.Equal(t, "test-dlq", aws.ToString(fc.created[0].QueueName))
+	require.Equal(t, "test", aws.ToString(fc.created[1].QueueName))
+
+	policy := redrivePolicy{}
+	require.NoError(t, json.Unmarshal([]byte(fc.created[1].Attributes[RedrivePolicyAWS]), &policy))
+	require.Equal(t, "arn:aws:sqs:us-east-1:000000000000:test-dlq", policy.DeadLetterTargetArn)
+	require.Equal(t, "5", policy.MaxReceiveCount)
+}
+
+func TestDeclareDLQFifo(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.maxReceiveCount = 3
+	c.queue = aws.String("test.fifo")
+
+	fc := newFakeClient()
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{QueueArnAWS: "arn:aws:sqs:us-east-1:000000000000:test-dlq.fifo"}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.setup(time.Second*5, true, aws.String("test-dlq.fifo")))
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Equal(t, "true", fc.created[0].Attributes[FifoQueueAWS])
+	require.Contains(t, fc.created[1].Attributes[RedrivePolicyAWS], "test-dlq.fifo")
+}
+
+func TestDeclareDLQSkipDeclare(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.maxReceiveCount = 3
+	c.skipDeclare = true
+	c.queueURLFixed = true
+
+	fc := newFakeClient()
+	c.client = fc
+
+	require.NoError(t, c.setup(time.Second*5, true, aws.String("test-dlq")))
+	require.Equal(t, 0, fc.called("CreateQueue"))
+	require.NotContains(t, c.attributes, RedrivePolicyAWS)
+}
+
+func TestCheckMaxReceiveCount(t *testing.T) {
+	require.NoError(t, checkMaxReceiveCount(0, "", nil))
+	require.NoError(t, checkMaxReceiveCount(5, "dlq", map[string]string{}))
+
+	require.Error(t, checkMaxReceiveCount(-1, "dlq", nil))
+	require.Error(t, checkMaxReceiveCount(1001, "dlq", nil))
+
+	err := checkMaxReceiveCount(5, "", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "dead_letter_queue")
+
+	err = checkMaxReceiveCount(5, "dlq", map[string]string{RedrivePolicyAWS: "{}"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "conflicts")
+}
@@ -0,0 +1,129 @@
+package sqsjobs
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const imdsRegionTimeout = time.Second * 5
+
+// regionSource is a single step of the region resolution chain, an empty region means the source has no value
+type regionSource struct {
+	name    string
+	resolve func(ctx context.Context) (string, error)
+}
+
+// regionChain returns the region resolution chain: explicit config -> AWS_REGION/AWS_DEFAULT_REGION ->
+// endpoint/queue URL -> EC2 IMDS identity document (inside AWS only)
+func regionChain(conf *Config, insideAWS bool) []regionSource {
+	chain := []regionSource{
+		{name: "config", resolve: func(context.Context) (string, error) {
+			return conf.Region, nil
+		}},
+		{name: "environment", resolve: func(context.Context) (string, error) {
+			return regionFromEnv(), nil
+		}},
+		{name: "endpoint", resolve: func(context.Context) (string, error) {
+			if region := regionFromURL(conf.Endpoint); region != "" {
+				return region, nil
+			}
+			// queue might be configured by its URL
+			return regionFromURL(getordefault(conf.Queue)), nil
+		}},
+	}
+
+	if insideAWS {
+		chain = append(chain, regionSource{name: "imds", resolve: func(ctx context.Context) (string, error) {
+			return regionFromIMDS(ctx, conf.MetadataEndpoint)
+		}})
+	}
+
+	return chain
+}
+
+// resolveRegion walks the chain and returns the first found region, the failed sources are logged and skipped
+func resolveRegion(ctx context.Context, chain []regionSource, log *zap.Logger) (string, error) {
+	tried := make([]string, 0, len(chain))
+	for _, src := range chain {
+		tried = append(tried, src.name)
+
+		region, err := src.resolve(ctx)
+		if err != nil {
+			log.Debug("failed to resolve the region, trying the next source", zap.String("source", src.name), zap.Error(err))
+			continue
+		}
+
+		if region != "" {
+			log.Debug("region was resolved", zap.String("source", src.name), zap.String("region", region))
+			return region, nil
+		}
+	}
+
+	return "", errors.Errorf("failed to resolve the AWS region, tried: %s; set the region in the sqs configuration or the AWS_REGION environment variable", strings.Join(tried, ", "))
+}
+
+func regionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// regionFromURL extracts the region from the SQS endpoint or queue URL host, e.g. sqs.us-east-1.amazonaws.com,
+// vpce-1a2b-3c4d.sqs.eu-west-1.vpce.amazonaws.com or us-west-2.queue.amazonaws.com
+func regionFromURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return ""
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+
+	for _, label := range strings.Split(u.Hostname(), ".") {
+		if isRegion(label) {
+			return label
+		}
+	}
+
+	return ""
+}
+
+// isRegion checks the region format: <partition prefix>-<name>-<number>, e.g. us-east-1, us-gov-west-1, ap-southeast-2
+func isRegion(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || len(parts[0]) != 2 {
+		return false
+	}
+
+	for _, p := range parts[:len(parts)-1] {
+		if p == "" || strings.Trim(p, "abcdefghijklmnopqrstuvwxyz") != "" {
+			return false
+		}
+	}
+
+	last := parts[len(parts)-1]
+	return last != "" && strings.Trim(last, "0123456789") == ""
+}
+
+// regionFromIMDS reads the region from the EC2 instance identity document, empty endpoint means the SDK default
+func regionFromIMDS(ctx context.Context, endpoint string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imdsRegionTimeout)
+	defer cancel()
+
+	out, err := imds.New(imds.Options{Endpoint: endpoint}).GetRegion(ctx, &imds.GetRegionInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return out.Region, nil
+}
@@ -0,0 +1,90 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func resolveChain(t *testing.T, conf *Config) (string, error) {
+	t.Helper()
+	return resolveRegion(context.Background(), regionChain(conf, false), zap.NewNop())
+}
+
+func TestRegionChainConfig(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	region, err := resolveChain(t, &Config{Region: "us-east-1", Endpoint: "https://sqs.ap-south-1.amazonaws.com"})
+	require.NoError(t, err)
+	require.Equal(t, "us-east-1", region)
+}
+
+func TestRegionChainEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-central-1")
+	region, err := resolveChain(t, &Config{Endpoint: "https://sqs.ap-south-1.amazonaws.com"})
+	require.NoError(t, err)
+	require.Equal(t, "eu-central-1", region)
+
+	// AWS_REGION takes precedence
+	t.Setenv("AWS_REGION", "eu-west-1")
+	region, err = resolveChain(t, &Config{})
+	require.NoError(t, err)
+	require.Equal(t, "eu-west-1", region)
+}
+
+func TestRegionChainURL(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	region, err := resolveChain(t, &Config{Endpoint: "https://sqs.ap-south-1.amazonaws.com"})
+	require.NoError(t, err)
+	require.Equal(t, "ap-south-1", region)
+
+	// queue URL, local endpoint
+	region, err = resolveChain(t, &Config{Endpoint: "http://127.0.0.1:9324", Queue: aws.String("https://sqs.us-gov-west-1.amazonaws.com/123456789012/orders")})
+	require.NoError(t, err)
+	require.Equal(t, "us-gov-west-1", region)
+
+	for in, want := range map[string]string{
+		"https://vpce-1a2b-3c4d.sqs.eu-west-1.vpce.amazonaws.com": "eu-west-1",
+		"https://us-west-2.queue.amazonaws.com/123/q":             "us-west-2",
+		"https://sqs.cn-north-1.amazonaws.com.cn":                 "cn-north-1",
+		"http://127.0.0.1:9324":                                   "",
+		"http://localhost:4566":                                   "",
+		"orders":                                                  "",
+	} {
+		require.Equal(t, want, regionFromURL(in), in)
+	}
+}
+
+func TestRegionChainIMDS(t *testing.T) {
+	chain := []regionSource{
+		{name: "config", resolve: func(context.Context) (string, error) { return "", nil }},
+		{name: "broken", resolve: func(context.Context) (string, error) { return "", stderr.New("boom") }},
+		{name: "imds", resolve: func(context.Context) (string, error) { return "sa-east-1", nil }},
+	}
+
+	region, err := resolveRegion(context.Background(), chain, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, "sa-east-1", region)
+
+	// IMDS is used inside AWS only
+	require.Len(t, regionChain(&Config{}, false), 3)
+	require.Equal(t, "imds", regionChain(&Config{}, true)[3].name)
+}
+
+func TestRegionChainError(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := resolveChain(t, &Config{Endpoint: "http://127.0.0.1:9324", Queue: aws.String("orders")})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "config, environment, endpoint")
+
+	_, err = checkEnv(false, &Config{Endpoint: "http://127.0.0.1:9324", SkipWarmup: true}, zap.NewNop())
+	require.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package sqsjobs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// replyTimeout limits the send of the worker response
+const replyTimeout = time.Minute
+
+// ReplyTo returns the reply queue (the name or the URL) of the RPC request (reply_to_attribute or reply_queue), empty if absent
+func (i *Item) ReplyTo() string {
+	if i.Options == nil {
+		return ""
+	}
+
+	return i.Options.replyTo
+}
+
+// Respond sends the worker response to the queue (the name or the URL), to the reply queue of the request if empty
+// (reply_to_attribute or reply_queue). The pushed jobs can't be responded.
+func (i *Item) Respond(payload []byte, queue string) error {
+	if i.Options == nil || i.Options.reply == nil {
+		return errors.Str("the response is supported only for the received messages")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+
+	return i.Options.reply(ctx, i, payload, queue)
+}
+
+// readReplyTo reads the reply queue from the message attribute, the reply_queue is the default.
+// The attribute is not trusted: the queues outside of the pipeline queue account are ignored unless allowed.
+func (c *Driver) readReplyTo(attrs map[string]types.MessageAttributeValue) string {
+	if c.replyToAttr != "" {
+		if attr, ok := attrs[c.replyToAttr]; ok && attr.StringValue != nil && strings.TrimSpace(*attr.StringValue) != "" {
+			replyTo := strings.TrimSpace(*attr.StringValue)
+			if c.replyAllowedQueue(replyTo) {
+				return replyTo
+			}
+
+			c.log.Warn("reply queue is outside of the pipeline queue account and not in the reply_allowed_queues, ignored",
+				zap.String("reply_to", replyTo), zap.String("reply_queue", c.replyQueue))
+		}
+	}
+
+	return c.replyQueue
+}
+
+// replyAllowlist returns the reply_allowed_queues set, nil if empty
+func replyAllowlist(queues []string) map[string]struct{} {
+	var allowed map[string]struct{}
+	for _, q := range queues {
+		q = strings.TrimSpace(q)
+		if q == "" {
+			continue
+		}
+
+		if allowed == nil {
+			allowed = make(map[string]struct{}, len(queues))
+		}
+		allowed[q] = struct{}{}
+	}
+
+	return allowed
+}
+
+// replyAllowedQueue reports whether the reply queue of the request might be used: the allowed ones, the names
+// (resolved in the pipeline account) and the URLs with the host and the account of the pipeline queue
+func (c *Driver) replyAllowedQueue(replyTo string) bool {
+	if _, ok := c.replyAllowed[replyTo]; ok {
+		return true
+	}
+
+	if !strings.Contains(replyTo, "://") {
+		return true
+	}
+
+	host, account, ok := queueOwner(replyTo)
+	if !ok {
+		return false
+	}
+
+	ownHost, ownAccount, ok := queueOwner(getordefault(c.queueURL))
+	return ok && strings.EqualFold(host, ownHost) && account == ownAccount
+}
+
+// queueOwner returns the host and the account ID of the queue URL, false if malformed
+func queueOwner(queueURL string) (string, string, bool) {
+	if _, err := parseQueueURL(queueURL); err != nil {
+		return "", "", false
+	}
+
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	// the path is /<account id>/<queue name> or /queue/<region>/<account id>/<queue name>
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	return u.Host, parts[len(parts)-2], true
+}
+
+// Reply sends the response body to the reply queue of the request (reply_to_attribute), the correlation ID of the request
+// (correlation_attribute) is copied to the response. The reply queue name is resolved once and cached.
+func (c *Driver) Reply(ctx context.Context, item *Item, body []byte) error {
+	return c.reply(ctx, item, body, "")
+}
+
+// reply sends the response to the queue, to the reply queue of the request if empty
+func (c *Driver) reply(ctx context.Context, item *Item, body []byte, replyTo string) error {
+	const op = errors.Op("sqs_reply")
+
+	if replyTo == "" {
+		replyTo = item.ReplyTo()
+	}
+
+	if replyTo == "" {
+		return errors.E(op, errors.Errorf("the message %s has no reply queue (reply_to_attribute: %s, reply_queue is not set)", item.ID(), c.replyToAttr))
+	}
+
+	url, err := c.replyURL(ctx, replyTo)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	in := &sqs.SendMessageInput{
+		QueueUrl:          url,
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: make(map[string]types.MessageAttributeValue, 1),
+	}
+
+	if c.correlationAttr != "" {
+		if id := headerValue(item.headers, c.correlationAttr); id != "" {
+			in.MessageAttributes[c.correlationAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(id)}
+		}
+	}
+
+	// the FIFO reply queue requires the group, the replies to the same request are deduplicated
+	if strings.HasSuffix(*url, fifoSuffix) {
+		group := item.Options.groupID
+		if group == "" {
+			group = item.ID()
+		}
+		in.MessageGroupId = aws.String(group)
+		in.MessageDeduplicationId = aws.String(item.ID() + "-reply")
+	}
+
+	_, err = c.client.SendMessage(ctx, in, withDeadline(ctx, sendDeadlineMargin))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	c.log.Debug("reply was sent", zap.String("ID", item.ID()), zap.String("reply_to", replyTo))
+
+	return nil
+}
+
+// replyURL returns the reply queue URL, the queue names are resolved with GetQueueUrl
+func (c *Driver) replyURL(ctx context.Context, replyTo string) (*string, error) {
+	if strings.Contains(replyTo, "://") {
+		if _, err := parseQueueURL(replyTo); err != nil {
+			return nil, err
+		}
+		return aws.String(replyTo), nil
+	}
+
+	if url, ok := c.replyURLs.Load(replyTo); ok {
+		return url.(*string), nil
+	}
+
+	url, err := getQueueURL(ctx, c.client, aws.String(replyTo), nil)
+	if err != nil {
+		return nil, errors.Errorf("failed to resolve the reply queue %s: %v", replyTo, err)
+	}
+
+	c.replyURLs.Store(replyTo, url)
+
+	return url, nil
+}
@@ -0,0 +1,111 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReply(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.replyToAttr = "ReplyTo"
+	c.correlationAttr = "X-Request-ID"
+	fc := newFakeClient()
+	c.client = fc
+
+	req := &types.Message{
+		MessageId:     aws.String("1"),
+		ReceiptHandle: aws.String("rh-1"),
+		Body:          aws.String("request"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"ReplyTo":      {DataType: aws.String(StringType), StringValue: aws.String("responses")},
+			"X-Request-ID": {DataType: aws.String(StringType), StringValue: aws.String("req-42")},
+		},
+	}
+
+	item, err := c.unpack(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "responses", item.ReplyTo())
+
+	require.NoError(t, c.Reply(context.Background(), item, []byte("response")))
+	require.NoError(t, c.Reply(context.Background(), item, []byte("response")))
+	// the reply queue is resolved once
+	require.Equal(t, 1, fc.called("GetQueueUrl"))
+
+	fc.mu.Lock()
+	require.Len(t, fc.sent, 2)
+	reply := fc.sent[0]
+	fc.mu.Unlock()
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/responses", *reply.QueueUrl)
+	require.Equal(t, "response", *reply.MessageBody)
+	require.Equal(t, "req-42", *reply.MessageAttributes["X-Request-ID"].StringValue)
+
+	// no reply queue
+	item, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("rh-2"), Body: aws.String("event")})
+	require.NoError(t, err)
+	require.Empty(t, item.ReplyTo())
+	require.Error(t, c.Reply(context.Background(), item, []byte("response")))
+}
+
+func TestRespond(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.replyToAttr = "ReplyTo"
+	c.replyQueue = "http://127.0.0.1:9324/000000000000/default-replies"
+	c.correlationAttr = "X-Request-ID"
+	fc := newFakeClient()
+	c.client = fc
+
+	// no reply attribute, the reply_queue is used
+	item, err := c.unpack(context.Background(), &types.Message{
+		MessageId:     aws.String("1"),
+		ReceiptHandle: aws.String("rh-1"),
+		Body:          aws.String("request"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"X-Request-ID": {DataType: aws.String(StringType), StringValue: aws.String("req-1")},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, c.replyQueue, item.ReplyTo())
+	require.NoError(t, item.Respond([]byte("response"), ""))
+
+	// the explicit queue
+	require.NoError(t, item.Respond([]byte("other"), "http://127.0.0.1:9324/000000000000/other"))
+
+	fc.mu.Lock()
+	require.Len(t, fc.sent, 2)
+	require.Equal(t, c.replyQueue, *fc.sent[0].QueueUrl)
+	require.Equal(t, "req-1", *fc.sent[0].MessageAttributes["X-Request-ID"].StringValue)
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/other", *fc.sent[1].QueueUrl)
+	require.Equal(t, "other", *fc.sent[1].MessageBody)
+	fc.mu.Unlock()
+
+	// the pushed jobs can't be responded
+	require.Error(t, (&Item{Options: &Options{}}).Respond([]byte("response"), ""))
+}
+
+func TestReplyAllowedQueues(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.replyToAttr = "ReplyTo"
+	c.replyQueue = "default-replies"
+	c.replyAllowed = replyAllowlist([]string{"https://sqs.us-east-1.amazonaws.com/111111111111/partner", " "})
+
+	replyTo := func(v string) string {
+		return c.readReplyTo(map[string]types.MessageAttributeValue{"ReplyTo": {DataType: aws.String(StringType), StringValue: aws.String(v)}})
+	}
+
+	// the name is resolved in the pipeline account
+	require.Equal(t, "responses", replyTo("responses"))
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/responses", replyTo("http://127.0.0.1:9324/000000000000/responses"))
+	require.Equal(t, "https://sqs.us-east-1.amazonaws.com/111111111111/partner", replyTo("https://sqs.us-east-1.amazonaws.com/111111111111/partner"))
+
+	// another account, another endpoint, malformed
+	require.Equal(t, "default-replies", replyTo("http://127.0.0.1:9324/222222222222/responses"))
+	require.Equal(t, "default-replies", replyTo("https://attacker.example.com/000000000000/responses"))
+	require.Equal(t, "default-replies", replyTo("http://127.0.0.1:9324/responses"))
+
+	require.Len(t, c.replyAllowed, 1)
+	require.Nil(t, replyAllowlist(nil))
+}
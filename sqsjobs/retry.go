@@ -0,0 +1,69 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// RetryCountAttr is the message attribute with the number of times the message was routed to the retry queue
+// (or nacked, with max_app_retries)
+const RetryCountAttr string = "X-Retry-Count"
+
+// checkRetryDelay validates the retry_delay option, SQS supports up to 15 minutes
+func checkRetryDelay(delay int) error {
+	if delay < 0 || delay > 900 {
+		return errors.Errorf("retry_delay should be between 0 and 900 seconds, provided: %d", delay)
+	}
+
+	return nil
+}
+
+// retry sends the nacked message to the retry queue with the incremented retry count, the original message is deleted by the caller
+func (c *Driver) retry(ctx context.Context, msg *Item) error {
+	msg.Options.retries++
+	msg.Options.Delay = int64(c.retryDelay)
+
+	d, err := c.prepare(ctx, msg, c.retryURL, c.retryQueue)
+	if err != nil {
+		return err
+	}
+
+	c.observePayload(d)
+
+	// batching is bound to the pipeline queue
+	_, err = c.client.SendMessage(ctx, d, withDeadline(ctx, sendDeadlineMargin))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// retryAttribute sets the retry count attribute, only for the messages routed to the retry queue (or nacked) at least once
+func retryAttribute(item *Item, in *sqs.SendMessageInput) {
+	if item.Options.retries == 0 {
+		return
+	}
+
+	in.MessageAttributes[RetryCountAttr] = types.MessageAttributeValue{DataType: aws.String(NumberType), StringValue: aws.String(strconv.FormatInt(item.Options.retries, 10))}
+}
+
+// retryCount reads the retry count attribute, 0 if absent or malformed
+func retryCount(attrs map[string]types.MessageAttributeValue) int64 {
+	attr, ok := attrs[RetryCountAttr]
+	if !ok {
+		return 0
+	}
+
+	n, err := strconv.ParseInt(getordefault(attr.StringValue), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return n
+}
@@ -0,0 +1,58 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNackRetryQueue(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.retryQueue = aws.String("test-retry")
+	c.retryURL = aws.String("http://127.0.0.1:9324/000000000000/test-retry")
+	c.retryDelay = 30
+	fc := newFakeClient()
+	c.client = fc
+
+	item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		RetryCountAttr: {DataType: aws.String(NumberType), StringValue: aws.String("2")},
+	}})
+	require.NoError(t, err)
+	// the message was dispatched
+	*c.msgInFlight = 1
+
+	require.NoError(t, item.Nack())
+
+	require.Len(t, fc.sent, 1)
+	sent := fc.sent[0]
+	require.Equal(t, aws.ToString(c.retryURL), aws.ToString(sent.QueueUrl))
+	require.Equal(t, "body", aws.ToString(sent.MessageBody))
+	require.Equal(t, "3", aws.ToString(sent.MessageAttributes[RetryCountAttr].StringValue))
+	require.Equal(t, int32(30), sent.DelaySeconds)
+
+	// the original is deleted from the pipeline queue
+	require.Len(t, fc.deleted, 1)
+	require.Equal(t, "receipt-1", aws.ToString(fc.deleted[0].ReceiptHandle))
+	require.Equal(t, aws.ToString(c.queueURL), aws.ToString(fc.deleted[0].QueueUrl))
+
+	// the first route starts the count
+	out, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("body")})
+	require.NoError(t, err)
+	*c.msgInFlight = 1
+	require.NoError(t, out.Nack())
+	require.Equal(t, "1", aws.ToString(fc.sent[1].MessageAttributes[RetryCountAttr].StringValue))
+
+	// no retry queue - the message is requeued to the pipeline queue without the count
+	c.retryURL = nil
+	out, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("3"), ReceiptHandle: aws.String("receipt-3"), Body: aws.String("body")})
+	require.NoError(t, err)
+	*c.msgInFlight = 1
+	require.NoError(t, out.Nack())
+	require.Equal(t, aws.ToString(c.queueURL), aws.ToString(fc.sent[2].QueueUrl))
+	require.NotContains(t, fc.sent[2].MessageAttributes, RetryCountAttr)
+
+	require.Error(t, checkRetryDelay(901))
+}
@@ -0,0 +1,150 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+)
+
+// maxRotateRetry limits the wait before the next rebuild attempt after a failure
+const maxRotateRetry = time.Minute
+
+// rotatingClient rebuilds the SQS client (re-resolving the credentials) after the lifetime (client_max_lifetime).
+// The new client is built in the background and swapped atomically, the in-flight calls finish on the old one.
+type rotatingClient struct {
+	mu       sync.RWMutex
+	current  sqsClient
+	deadline time.Time
+
+	lifetime time.Duration
+	build    func() (sqsClient, error)
+	log      *zap.Logger
+	// rebuild in progress
+	rebuilding uint32
+}
+
+func newRotatingClient(client sqsClient, lifetime time.Duration, build func() (sqsClient, error), log *zap.Logger) *rotatingClient {
+	return &rotatingClient{
+		current:  client,
+		deadline: time.Now().Add(lifetime),
+		lifetime: lifetime,
+		build:    build,
+		log:      log,
+	}
+}
+
+// get returns the current client and starts the rebuild if the lifetime has elapsed
+func (r *rotatingClient) get() sqsClient {
+	r.mu.RLock()
+	client, expired := r.current, time.Now().After(r.deadline)
+	r.mu.RUnlock()
+
+	if expired && atomic.CompareAndSwapUint32(&r.rebuilding, 0, 1) {
+		go r.rotate()
+	}
+
+	return client
+}
+
+func (r *rotatingClient) rotate() {
+	defer atomic.StoreUint32(&r.rebuilding, 0)
+
+	client, err := r.build()
+	if err != nil {
+		retry := min(r.lifetime, maxRotateRetry)
+		r.log.Error("failed to rebuild the sqs client, the current one is used", zap.Duration("retry_in", retry), zap.Error(err))
+		r.mu.Lock()
+		r.deadline = time.Now().Add(retry)
+		r.mu.Unlock()
+		return
+	}
+
+	r.mu.Lock()
+	r.current = client
+	r.deadline = time.Now().Add(r.lifetime)
+	r.mu.Unlock()
+
+	r.log.Debug("sqs client was rotated", zap.Duration("lifetime", r.lifetime))
+}
+
+// newClient builds the SQS client, rotated after the lifetime if set (0 - no rotation)
+func newClient(insideAWS bool, conf *Config, log *zap.Logger, lifetime time.Duration) (sqsClient, error) {
+	// in_memory, the queues of the process instead of SQS
+	if conf.InMemory {
+		return newMemoryClient(memoryQueues, conf.Chaos)
+	}
+
+	err := checkChaos(conf.InMemory, conf.Chaos)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := checkEnv(insideAWS, conf, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if lifetime <= 0 {
+		return client, nil
+	}
+
+	return newRotatingClient(client, lifetime, func() (sqsClient, error) {
+		return checkEnv(insideAWS, conf, log)
+	}, log), nil
+}
+
+func (r *rotatingClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	return r.get().SendMessage(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	return r.get().SendMessageBatch(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return r.get().ReceiveMessage(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return r.get().ChangeMessageVisibility(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return r.get().DeleteMessage(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	return r.get().DeleteMessageBatch(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	return r.get().CreateQueue(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return r.get().GetQueueUrl(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	return r.get().DeleteQueue(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	return r.get().GetQueueAttributes(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) SetQueueAttributes(ctx context.Context, params *sqs.SetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error) {
+	return r.get().SetQueueAttributes(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) ListQueueTags(ctx context.Context, params *sqs.ListQueueTagsInput, optFns ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	return r.get().ListQueueTags(ctx, params, optFns...)
+}
+
+func (r *rotatingClient) TagQueue(ctx context.Context, params *sqs.TagQueueInput, optFns ...func(*sqs.Options)) (*sqs.TagQueueOutput, error) {
+	return r.get().TagQueue(ctx, params, optFns...)
+}
@@ -0,0 +1,41 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRotatingClient(t *testing.T) {
+	first := newFakeClient()
+	second := newFakeClient()
+	var builds int32
+	r := newRotatingClient(first, time.Millisecond*100, func() (sqsClient, error) {
+		if atomic.AddInt32(&builds, 1) == 1 {
+			return nil, stderr.New("no credentials")
+		}
+		return second, nil
+	}, zap.NewNop())
+	send := func() {
+		_, err := r.SendMessage(context.Background(), &sqs.SendMessageInput{})
+		require.NoError(t, err)
+	}
+
+	send()
+	require.Equal(t, 1, first.called("SendMessage"))
+	require.Equal(t, int32(0), atomic.LoadInt32(&builds))
+
+	// the new client is built after the lifetime elapses, the failed rebuild is retried
+	require.Eventually(t, func() bool {
+		send()
+		return second.called("SendMessage") > 0
+	}, time.Second*5, time.Millisecond*20)
+	require.Equal(t, int32(2), atomic.LoadInt32(&builds))
+	require.GreaterOrEqual(t, first.called("SendMessage"), 2)
+}
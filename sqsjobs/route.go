@@ -0,0 +1,69 @@
+package sqsjobs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// pipelineRoutes is the route_attribute routing: the message attribute names the pipeline the job is dispatched to,
+// e.g. a single dispatcher queue carrying the jobs of several pipelines.
+//
+// The coupling with the jobs plugin: the priority queue is shared by all pipelines and the plugin resolves the pipeline
+// of the job by its GroupID (Options.Pipeline), so the routed job is executed by the workers of the target pipeline.
+// The commander channel can't carry a job (only the pipeline commands, e.g. stop), and the driver doesn't see the
+// pipelines of the plugin, so the targets are validated against the route_pipelines list. The message still belongs
+// to this queue: the ack, nack and requeue of the routed job are handled by this pipeline.
+type pipelineRoutes struct {
+	attr    string
+	targets map[string]struct{}
+}
+
+// newPipelineRoutes returns nil if the routing is disabled (no route_attribute)
+func newPipelineRoutes(attr string, pipelines []string) (*pipelineRoutes, error) {
+	if attr == "" {
+		return nil, nil
+	}
+
+	r := &pipelineRoutes{attr: attr, targets: make(map[string]struct{}, len(pipelines))}
+	for _, p := range pipelines {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		r.targets[p] = struct{}{}
+	}
+
+	if len(r.targets) == 0 {
+		return nil, errors.Errorf("route_attribute %s requires the route_pipelines list of the target pipelines", attr)
+	}
+
+	return r, nil
+}
+
+// routePipeline returns the pipeline to dispatch the job to: the one named in the route attribute, the consuming
+// pipeline if the routing is disabled or the attribute is absent. An unknown target is an error, the message is
+// treated as a poison one (never dispatched to the wrong workers).
+func (c *Driver) routePipeline(attrs map[string]types.MessageAttributeValue) (string, error) {
+	consuming := (*c.pipeline.Load()).Name()
+	if c.routes == nil {
+		return consuming, nil
+	}
+
+	v, ok := attrs[c.routes.attr]
+	if !ok || v.StringValue == nil || *v.StringValue == "" {
+		return consuming, nil
+	}
+
+	target := *v.StringValue
+	if target == consuming {
+		return consuming, nil
+	}
+
+	if _, ok := c.routes.targets[target]; !ok {
+		return "", errors.Errorf("route attribute %s names the unknown pipeline %s", c.routes.attr, target)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,68 @@
+package sqsjobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteAttribute(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	var err error
+	c.routes, err = newPipelineRoutes("target", []string{"billing", " emails "})
+	require.NoError(t, err)
+
+	route := func(id, target string) types.Message {
+		m := types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String(id), Body: aws.String(id)}
+		if target != "" {
+			m.MessageAttributes = map[string]types.MessageAttributeValue{
+				"target": {DataType: aws.String(StringType), StringValue: aws.String(target)},
+			}
+		}
+		return m
+	}
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(route("1", "billing"), route("2", ""), route("3", "emails"), route("4", "unknown"))
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	require.Eventually(t, func() bool { return pq.Len() == 3 }, time.Second*5, time.Millisecond*10)
+	// the unknown pipeline is never dispatched
+	time.Sleep(time.Millisecond * 50)
+	require.Equal(t, uint64(3), pq.Len())
+
+	pipelines := make(map[string]string)
+	for pq.Len() > 0 {
+		item := pq.ExtractMin().(*Item)
+		pipelines[string(item.Payload)] = item.GroupID()
+		// the routed message is still acknowledged in the consuming queue
+		require.NoError(t, item.Ack())
+	}
+	require.Equal(t, map[string]string{"1": "billing", "2": "test", "3": "emails"}, pipelines)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.deleted, 3)
+	for _, in := range fc.deleted {
+		require.Equal(t, c.queueURL, in.QueueUrl)
+	}
+}
+
+func TestPipelineRoutesConfig(t *testing.T) {
+	r, err := newPipelineRoutes("", []string{"billing"})
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	_, err = newPipelineRoutes("target", nil)
+	require.Error(t, err)
+
+	_, err = newPipelineRoutes("target", headerList(" , "))
+	require.Error(t, err)
+}
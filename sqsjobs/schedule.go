@@ -0,0 +1,92 @@
+package sqsjobs
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// epochMillisThreshold separates the epoch seconds from the epoch milliseconds in the execute_at attribute
+const epochMillisThreshold int64 = 1e12
+
+// executeAt reads the desired execution time from the execute_at_attribute: RFC 3339 or the epoch time
+// in seconds (or milliseconds). False is returned if the attribute is not configured, absent or malformed.
+func (c *Driver) executeAt(msg *types.Message) (time.Time, bool) {
+	if c.executeAtAttr == "" {
+		return time.Time{}, false
+	}
+
+	attr, ok := msg.MessageAttributes[c.executeAtAttr]
+	if !ok || attr.StringValue == nil {
+		return time.Time{}, false
+	}
+
+	at, err := parseExecuteAt(*attr.StringValue)
+	if err != nil {
+		c.log.Debug("failed to parse the execute_at attribute, dispatching the message now", zap.Stringp("ID", msg.MessageId), zap.String("attribute", c.executeAtAttr), zap.Error(err))
+		return time.Time{}, false
+	}
+
+	return at, true
+}
+
+func parseExecuteAt(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n >= epochMillisThreshold {
+			return time.UnixMilli(n), nil
+		}
+
+		return time.Unix(n, 0), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// scheduleLongDelay sends the message delayed over the 15 minutes DelaySeconds limit with the maximum delay and the
+// execution time in the execute_at_attribute, the consumers hold it until the time passes. No-op for the shorter delays.
+func (c *Driver) scheduleLongDelay(d *sqs.SendMessageInput) {
+	if c.executeAtAttr == "" || int64(d.DelaySeconds) <= maxDelay {
+		return
+	}
+
+	at := time.Now().Add(time.Duration(d.DelaySeconds) * time.Second).UTC()
+	d.DelaySeconds = int32(maxDelay)
+	if d.MessageAttributes == nil {
+		d.MessageAttributes = make(map[string]types.MessageAttributeValue, 1)
+	}
+	d.MessageAttributes[c.executeAtAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(at.Format(time.RFC3339))}
+}
+
+// holdUntil hides the message until the execution time with the visibility change, the message is received again
+// once the time passes (or after the 12 hours visibility limit, then it's held again)
+func (c *Driver) holdUntil(ctx context.Context, msg *types.Message, at time.Time) {
+	wait := time.Until(at)
+	timeout := int32(min(math.Ceil(wait.Seconds()), float64(maxVisibilityTimeout)))
+
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.ChangeMessageVisibility(ctxT, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queueURL,
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: timeout,
+	})
+	if isNotInflight(err) {
+		c.log.Debug("scheduled message is not in flight anymore, hold skipped", zap.Stringp("ID", msg.MessageId))
+		return
+	}
+
+	if err != nil {
+		c.log.Error("failed to hold the scheduled message, it will be visible again after the visibility timeout", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return
+	}
+
+	c.log.Debug("message is scheduled for later, held", zap.Stringp("ID", msg.MessageId), zap.Time("execute_at", at), zap.Int32("visibility_timeout", timeout))
+}
@@ -0,0 +1,105 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteAtHold(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.executeAtAttr = "execute_at"
+	fc := newFakeClient()
+
+	at := time.Now().Add(time.Millisecond * 1500)
+	msg := types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("body"), MessageAttributes: map[string]types.MessageAttributeValue{
+		"execute_at": {DataType: aws.String(NumberType), StringValue: aws.String(strconv.FormatInt(at.UnixMilli(), 10))},
+	}}
+
+	// the message is in flight after the receive (the default visibility) until the visibility change
+	var mu sync.Mutex
+	var visibleAt time.Time
+	fc.visibilityFn = func(in *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+		mu.Lock()
+		visibleAt = time.Now().Add(time.Duration(in.VisibilityTimeout) * time.Second)
+		mu.Unlock()
+		return &sqs.ChangeMessageVisibilityOutput{}, nil
+	}
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		mu.Lock()
+		if time.Now().After(visibleAt) {
+			visibleAt = time.Now().Add(time.Minute)
+			mu.Unlock()
+			return &sqs.ReceiveMessageOutput{Messages: []types.Message{msg}}, nil
+		}
+		mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 10):
+			return &sqs.ReceiveMessageOutput{}, nil
+		}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+
+	require.Eventually(t, func() bool { return fc.called("ChangeMessageVisibility") == 1 }, time.Second, time.Millisecond*10)
+	require.Equal(t, int32(2), fc.visibility[0].VisibilityTimeout)
+	// not dispatched before the time
+	require.Never(t, func() bool { return pq.Len() > 0 }, time.Until(at)-time.Millisecond*100, time.Millisecond*10)
+
+	require.Eventually(t, func() bool { return pq.Len() == 1 }, time.Second*5, time.Millisecond*10)
+	require.False(t, time.Now().Before(at))
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+}
+
+func TestParseExecuteAt(t *testing.T) {
+	at, err := parseExecuteAt("1700000000")
+	require.NoError(t, err)
+	require.Equal(t, int64(1700000000), at.Unix())
+
+	at, err = parseExecuteAt("1700000000123")
+	require.NoError(t, err)
+	require.Equal(t, int64(1700000000123), at.UnixMilli())
+
+	at, err = parseExecuteAt("2030-01-02T03:04:05Z")
+	require.NoError(t, err)
+	require.Equal(t, 2030, at.Year())
+
+	_, err = parseExecuteAt("tomorrow")
+	require.Error(t, err)
+}
+
+func TestScheduleLongDelay(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	c.client = fc
+
+	short := &Item{Job: "job", Ident: "short", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{Delay: 600}}
+	long := &Item{Job: "job", Ident: "long", Payload: []byte("body"), headers: map[string][]string{}, Options: &Options{Delay: 3600}}
+
+	c.executeAtAttr = "execute-at"
+	require.NoError(t, c.handleItem(context.Background(), short))
+	require.NoError(t, c.handleItem(context.Background(), long))
+
+	// up to 15 minutes the delay is sent as is
+	require.Len(t, fc.sent, 2)
+	require.Equal(t, int32(600), fc.sent[0].DelaySeconds)
+	require.NotContains(t, fc.sent[0].MessageAttributes, "execute-at")
+
+	require.Equal(t, int32(maxDelay), fc.sent[1].DelaySeconds)
+	at, err := parseExecuteAt(aws.ToString(fc.sent[1].MessageAttributes["execute-at"].StringValue))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), at, time.Minute)
+}
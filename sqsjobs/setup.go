@@ -0,0 +1,129 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	queueReadyBackoff    = time.Millisecond * 100
+	maxQueueReadyBackoff = time.Second * 2
+)
+
+// setup declares (or resolves) the queue (and the dead-letter queue with the max_receive_count), checks the permissions and resolves the dead-letter, retry and invalid body queues.
+// All calls share the timeout, so the pipeline init never hangs the server boot.
+func (c *Driver) setup(timeout time.Duration, skipPermissionCheck bool, dlq *string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the redrive policy (max_receive_count) is applied on the queue declaration
+	err := c.declareDLQ(ctx, dlq)
+	if err != nil {
+		return setupError(ctx, timeout, err)
+	}
+
+	// if the queue is already declared and user do not want to
+	err = manageQueue(ctx, c)
+	if err != nil {
+		return setupError(ctx, timeout, addressError(err, c.queueAddress()))
+	}
+
+	if !skipPermissionCheck {
+		err = c.checkPermissions(ctx)
+		if err != nil {
+			return setupError(ctx, timeout, err)
+		}
+	}
+
+	// the dead-letter and retry queue URLs are already set if configured explicitly
+	if dlq != nil && c.dlqURL == nil {
+		c.dlqURL, err = getQueueURL(ctx, c.client, dlq, c.queueOwner)
+		if err != nil {
+			return setupError(ctx, timeout, err)
+		}
+	}
+
+	if c.retryQueue != nil && c.retryURL == nil {
+		c.retryURL, err = getQueueURL(ctx, c.client, c.retryQueue, c.queueOwner)
+		if err != nil {
+			return setupError(ctx, timeout, err)
+		}
+	}
+
+	if c.expiredQueue != nil && c.expiredURL == nil {
+		c.expiredURL, err = getQueueURL(ctx, c.client, c.expiredQueue, c.queueOwner)
+		if err != nil {
+			return setupError(ctx, timeout, err)
+		}
+	}
+
+	for _, t := range c.fanOut {
+		if t.url == nil {
+			t.url, err = getQueueURL(ctx, c.client, t.name, c.queueOwner)
+			if err != nil {
+				return setupError(ctx, timeout, err)
+			}
+		}
+	}
+
+	if c.invalidBody.queue != nil && c.invalidBody.url == nil {
+		c.invalidBody.url, err = getQueueURL(ctx, c.client, c.invalidBody.queue, c.queueOwner)
+		if err != nil {
+			return setupError(ctx, timeout, err)
+		}
+	}
+
+	c.notReady("pipeline is not started")
+	return nil
+}
+
+// waitQueueReady resolves the just created queue, retrying with backoff on QueueDoesNotExist: right after CreateQueue
+// the queue might be briefly unavailable due to the eventual consistency. The wait is capped by the queue_ready_timeout.
+func (c *Driver) waitQueueReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.queueReadyTimeout)
+	defer cancel()
+
+	backoff := queueReadyBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := getQueueURL(ctx, c.client, c.queue, nil)
+		if err == nil {
+			return nil
+		}
+
+		if !isNonExistentQueue(err) {
+			return err
+		}
+
+		c.log.Debug("queue is not available yet after create, retrying", zap.Stringp("queue", c.queue), zap.Int("attempt", attempt), zap.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("queue %s is not available after create within %s (queue_ready_timeout): %v", getordefault(c.queue), c.queueReadyTimeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxQueueReadyBackoff)
+	}
+}
+
+// queueAddress returns the queue URL if it is known, the queue name otherwise
+func (c *Driver) queueAddress() *string {
+	if c.queueURL != nil {
+		return c.queueURL
+	}
+
+	return c.queue
+}
+
+// setupError replaces the deadline errors with the clear timeout error
+func setupError(ctx context.Context, timeout time.Duration, err error) error {
+	if stderr.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.Errorf("queue setup timed out after %s (setup_timeout), check the network connectivity and the IAM permissions: %v", timeout, err)
+	}
+
+	return err
+}
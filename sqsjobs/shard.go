@@ -0,0 +1,100 @@
+package sqsjobs
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// the instance specific shard, take precedence over the shard_index/shard_count (shared by all instances)
+const (
+	shardIndexEnv string = "RR_SQS_SHARD_INDEX"
+	shardCountEnv string = "RR_SQS_SHARD_COUNT"
+)
+
+// shardAffinity limits the instance to the messages with the partition key hashing into its shard,
+// the other messages are made visible again right away for the other instances
+type shardAffinity struct {
+	index uint32
+	count uint32
+}
+
+// newShardAffinity returns nil if the sharding is disabled (the shard count is 0 or 1)
+func newShardAffinity(index, count int) (*shardAffinity, error) {
+	var err error
+	if v, ok := os.LookupEnv(shardIndexEnv); ok {
+		index, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("%s should be a number: %v", shardIndexEnv, err)
+		}
+	}
+
+	if v, ok := os.LookupEnv(shardCountEnv); ok {
+		count, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("%s should be a number: %v", shardCountEnv, err)
+		}
+	}
+
+	if count <= 1 {
+		return nil, nil
+	}
+
+	if index < 0 || index >= count {
+		return nil, errors.Errorf("shard_index should be between 0 and %d (shard_count - 1), got %d", count-1, index)
+	}
+
+	return &shardAffinity{index: uint32(index), count: uint32(count)}, nil //nolint:gosec
+}
+
+// owns returns true if the partition key hashes into the shard, the messages without the key are processed by any shard
+func (s *shardAffinity) owns(key string) bool {
+	if key == "" {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return h.Sum32()%s.count == s.index
+}
+
+// notOwned returns true if the message belongs to another shard
+func (c *Driver) notOwned(msg *types.Message) bool {
+	if c.shard == nil {
+		return false
+	}
+
+	var key string
+	if attr, ok := msg.MessageAttributes[c.partitionKeyAttr()]; ok && attr.StringValue != nil {
+		key = *attr.StringValue
+	}
+
+	return !c.shard.owns(key)
+}
+
+// releaseNotOwned makes the message of another shard visible again, so the instance owning the shard receives it
+func (c *Driver) releaseNotOwned(ctx context.Context, msg *types.Message) {
+	client, queueURL, _ := c.sourceOf(ctx)
+	ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := client.ChangeMessageVisibility(ctxT, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queueURL,
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: 0,
+	})
+	if err != nil && !isNotInflight(err) {
+		c.log.Warn("failed to release the message of another shard, it will be visible again after the visibility timeout", zap.Stringp("ID", msg.MessageId), zap.Error(err))
+		return
+	}
+
+	c.log.Debug("message belongs to another shard, released", zap.Stringp("ID", msg.MessageId), zap.Uint32("shard", c.shard.index), zap.Uint32("shard_count", c.shard.count))
+}
@@ -0,0 +1,103 @@
+package sqsjobs
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardAffinity(t *testing.T) {
+	const shards = 2
+	keys := []string{"tenant-a", "tenant-b", "tenant-c", "tenant-d", "tenant-e", "tenant-f"}
+
+	msgs := make([]types.Message, 0, len(keys)+1)
+	for i, k := range keys {
+		id := strconv.Itoa(i)
+		msgs = append(msgs, types.Message{
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String("rh-" + id),
+			Body:          aws.String(k),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				defaultPartitionKeyAttr: {DataType: aws.String(StringType), StringValue: aws.String(k)},
+			},
+		})
+	}
+	// no partition key - any shard
+	msgs = append(msgs, types.Message{MessageId: aws.String("nokey"), ReceiptHandle: aws.String("rh-nokey"), Body: aws.String("nokey")})
+
+	dispatched := make([][]string, shards)
+	for i := 0; i < shards; i++ {
+		shard, err := newShardAffinity(i, shards)
+		require.NoError(t, err)
+
+		var want []string
+		for _, k := range keys {
+			if shard.owns(k) {
+				want = append(want, k)
+			}
+		}
+		want = append(want, "nokey")
+
+		pq := &testQueue{}
+		c := newTestDriver(pq, nil)
+		c.shard = shard
+		fc := newFakeClient()
+		fc.receiveFn = receiveOnce(msgs...)
+		c.client = fc
+
+		stop := runListener(c)
+		require.Eventually(t, func() bool { return pq.Len() == uint64(len(want)) }, time.Second*5, time.Millisecond*10)
+		require.Eventually(t, func() bool { return fc.called("ChangeMessageVisibility") == len(msgs)-len(want) }, time.Second*5, time.Millisecond*10)
+		stop()
+
+		for pq.Len() > 0 {
+			dispatched[i] = append(dispatched[i], string(pq.ExtractMin().(*Item).Payload))
+		}
+		sort.Strings(dispatched[i])
+		sort.Strings(want)
+		require.Equal(t, want, dispatched[i])
+
+		// the messages of another shard are visible again right away
+		fc.mu.Lock()
+		for _, in := range fc.visibility {
+			require.Equal(t, int32(0), in.VisibilityTimeout)
+		}
+		fc.mu.Unlock()
+	}
+
+	// every partition key is dispatched by exactly one shard
+	seen := make(map[string]int)
+	for _, d := range dispatched {
+		for _, k := range d {
+			seen[k]++
+		}
+	}
+	for _, k := range keys {
+		require.Equal(t, 1, seen[k], k)
+	}
+	require.Equal(t, shards, seen["nokey"])
+}
+
+func TestShardAffinityConfig(t *testing.T) {
+	s, err := newShardAffinity(0, 1)
+	require.NoError(t, err)
+	require.Nil(t, s)
+
+	_, err = newShardAffinity(2, 2)
+	require.Error(t, err)
+
+	// the instance specific index from the environment
+	t.Setenv(shardIndexEnv, "3")
+	s, err = newShardAffinity(0, 4)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), s.index)
+
+	t.Setenv(shardCountEnv, "two")
+	_, err = newShardAffinity(0, 4)
+	require.Error(t, err)
+}
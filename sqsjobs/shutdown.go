@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"go.uber.org/zap"
+)
+
+// unstarted collects the received messages which were not taken by a worker: removed from the priority queue,
+// staged in the dispatch buffer, parked by the group ordering and pending in the receive order. Should be called after the pollers and the dispatcher are stopped.
+func (c *Driver) unstarted(removed []jobs.Job) []*Item {
+	items := make([]*Item, 0, len(removed))
+	for _, j := range removed {
+		if item, ok := j.(*Item); ok {
+			items = append(items, item)
+		}
+	}
+
+	if c.dispatchCh != nil {
+	buffered:
+		for {
+			select {
+			case item := <-c.dispatchCh:
+				items = append(items, item)
+			default:
+				break buffered
+			}
+		}
+	}
+
+	if c.groups != nil {
+		items = append(items, c.groups.drain()...)
+	}
+
+	if c.order != nil {
+		items = append(items, c.order.drain()...)
+	}
+
+	return items
+}
+
+// requeueUnstarted makes the not started messages visible again right away (visibility timeout 0),
+// so another instance picks them up instead of waiting for the visibility timeout (fast_requeue_on_shutdown)
+func (c *Driver) requeueUnstarted(ctx context.Context, items []*Item) {
+	var requeued int
+	for _, item := range items {
+		// auto-acked messages are already deleted, the pushed jobs have no receipt handle
+		handle := item.Options.receipt.get()
+		if item.Options.AutoAck || handle == nil {
+			continue
+		}
+
+		// already returned to the queue on the handler_timeout
+		if !item.Options.watchdog.claim() {
+			continue
+		}
+		item.Options.heartbeat.stop()
+
+		_, err := item.Options.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          item.Options.queue,
+			ReceiptHandle:     handle,
+			VisibilityTimeout: 0,
+		})
+		if err != nil && !isNotInflight(err) {
+			c.log.Warn("failed to return the message to the queue on shutdown", zap.String("ID", item.ID()), zap.Error(err))
+			continue
+		}
+
+		item.Options.receipt.done()
+		requeued++
+	}
+
+	c.log.Debug("not started messages were returned to the queue", zap.Int("requeued", requeued), zap.Int("total", len(items)))
+}
@@ -0,0 +1,96 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFastRequeueOnShutdown(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.fastRequeue = true
+	c.groups = newGroupGate(0)
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("body")},
+		// the message 4 is parked behind the message 3 of the same group
+		types.Message{MessageId: aws.String("3"), ReceiptHandle: aws.String("receipt-3"), Body: aws.String("body"), Attributes: map[string]string{MessageGroupIDAttr: "g"}},
+		types.Message{MessageId: aws.String("4"), ReceiptHandle: aws.String("receipt-4"), Body: aws.String("body"), Attributes: map[string]string{MessageGroupIDAttr: "g"}},
+	)
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return pq.Len() == 3
+	}, time.Second*5, time.Millisecond*10)
+
+	// taken by a worker
+	processing := pq.ExtractMin()
+	require.Equal(t, "receipt-1", aws.ToString(processing.(*Item).Options.receipt.get()))
+
+	require.NoError(t, c.Stop(context.Background()))
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	handles := make([]string, 0, len(fc.visibility))
+	for _, in := range fc.visibility {
+		require.Equal(t, int32(0), in.VisibilityTimeout)
+		handles = append(handles, aws.ToString(in.ReceiptHandle))
+	}
+	require.ElementsMatch(t, []string{"receipt-2", "receipt-3", "receipt-4"}, handles)
+}
+
+func TestFastRequeueInProgressReceive(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.fastRequeue = true
+
+	received := make(chan struct{})
+	fc := newFakeClient()
+	var once sync.Once
+	fc.receiveFn = func(ctx context.Context, _ *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		once.Do(func() { close(received) })
+		<-ctx.Done()
+		// the response arrives after the stop
+		time.Sleep(time.Millisecond * 50)
+		return &sqs.ReceiveMessageOutput{Messages: []types.Message{{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")}}}, nil
+	}
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	<-received
+	require.NoError(t, c.Stop(context.Background()))
+
+	require.Equal(t, int32(0), atomic.LoadInt32(&c.activePollers))
+	require.Equal(t, uint64(0), pq.Len())
+	fc.mu.Lock()
+	require.Len(t, fc.visibility, 1)
+	require.Equal(t, "receipt-1", aws.ToString(fc.visibility[0].ReceiptHandle))
+	fc.mu.Unlock()
+}
+
+func TestFastRequeueDisabled(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")})
+	c.client = fc
+
+	require.NoError(t, c.Run(context.Background(), *c.pipeline.Load()))
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, c.Stop(context.Background()))
+	require.Equal(t, 0, fc.called("ChangeMessageVisibility"))
+}
@@ -0,0 +1,86 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+)
+
+// clockSkew corrects the SigV4 signing time with the offset between the local clock and the server time (the Date header),
+// the offset is detected on the clock skew errors (RequestTimeTooSkewed, etc.) and the request is retried
+type clockSkew struct {
+	// nanoseconds
+	offset int64
+	signer sqs.HTTPSignerV4
+	log    *zap.Logger
+}
+
+// newClockSkew returns nil if the correction is disabled (skip_clock_skew_correction)
+func newClockSkew(conf *Config, log *zap.Logger) *clockSkew {
+	if conf.SkipClockSkewCorrection {
+		return nil
+	}
+
+	return &clockSkew{signer: v4.NewSigner(), log: log}
+}
+
+// SignHTTP signs the request with the adjusted signing time
+func (s *clockSkew) SignHTTP(ctx context.Context, credentials aws.Credentials, r *http.Request, payloadHash string, service string, region string, signingTime time.Time, optFns ...func(*v4.SignerOptions)) error {
+	return s.signer.SignHTTP(ctx, credentials, r, payloadHash, service, region, signingTime.Add(time.Duration(atomic.LoadInt64(&s.offset))), optFns...)
+}
+
+// retryable adjusts the offset on the clock skew error and marks the error as retryable, other errors are left to the default retryables
+func (s *clockSkew) retryable(err error) aws.Ternary {
+	if !isClockSkewError(err) {
+		return aws.UnknownTernary
+	}
+
+	serverTime, ok := responseDate(err)
+	if !ok {
+		return aws.UnknownTernary
+	}
+
+	offset := time.Until(serverTime)
+	atomic.StoreInt64(&s.offset, int64(offset))
+	s.log.Warn("clock skew detected, the signing time is adjusted", zap.Duration("offset", offset), zap.Error(err))
+
+	return aws.TrueTernary
+}
+
+func isClockSkewError(err error) bool {
+	var apiErr smithy.APIError
+	if !stderr.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "RequestTimeTooSkewed", "RequestExpired", "SignatureExpired":
+		return true
+	default:
+		return false
+	}
+}
+
+// responseDate returns the server time from the Date header of the failed response
+func responseDate(err error) (time.Time, bool) {
+	var respErr *smithyhttp.ResponseError
+	if !stderr.As(err, &respErr) || respErr.Response == nil || respErr.Response.Response == nil {
+		return time.Time{}, false
+	}
+
+	date, errP := http.ParseTime(respErr.Response.Header.Get("Date"))
+	if errP != nil {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
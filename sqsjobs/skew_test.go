@@ -0,0 +1,60 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// timeSigner records the signing time
+type timeSigner struct {
+	signed time.Time
+}
+
+func (s *timeSigner) SignHTTP(_ context.Context, _ aws.Credentials, _ *http.Request, _ string, _ string, _ string, signingTime time.Time, _ ...func(*v4.SignerOptions)) error {
+	s.signed = signingTime
+	return nil
+}
+
+func TestClockSkewCorrection(t *testing.T) {
+	signer := &timeSigner{}
+	skew := newClockSkew(&Config{}, zap.NewNop())
+	skew.signer = signer
+
+	// the server clock is 10 minutes ahead, requests signed more than 5 minutes off are rejected
+	serverTime := time.Now().Add(time.Minute * 10)
+	send := func() error {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "https://sqs.us-east-1.amazonaws.com", nil)
+		require.NoError(t, skew.SignHTTP(context.Background(), aws.Credentials{}, req, "", "sqs", "us-east-1", time.Now()))
+		if serverTime.Sub(signer.signed).Abs() > time.Minute*5 {
+			return &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{"Date": {serverTime.UTC().Format(http.TimeFormat)}}}},
+				Err:      &smithy.GenericAPIError{Code: "RequestTimeTooSkewed", Message: "The difference between the request time and the current time is too large."},
+			}
+		}
+		return nil
+	}
+
+	err := send()
+	require.Error(t, err)
+	require.True(t, skew.retryable(err).Bool())
+
+	// retried with the corrected signing time
+	require.NoError(t, send())
+	require.WithinDuration(t, serverTime, signer.signed, time.Second*2)
+
+	// other errors are left to the default retryables
+	require.Equal(t, aws.UnknownTernary, skew.retryable(stderr.New("boom")))
+	require.Equal(t, aws.UnknownTernary, skew.retryable(&smithy.GenericAPIError{Code: "RequestTimeTooSkewed"}))
+
+	require.Nil(t, newClockSkew(&Config{SkipClockSkewCorrection: true}, zap.NewNop()))
+}
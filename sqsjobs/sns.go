@@ -0,0 +1,131 @@
+package sqsjobs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+const (
+	snsNotification string = "Notification"
+	// SNS string array attributes are delivered as the JSON array in the String attribute
+	snsStringArray string = "String.Array"
+)
+
+// detected SNS delivery mode of the subscription, only the changes are logged
+const (
+	snsModeUnknown int32 = iota
+	snsModeRaw
+	snsModeEnvelope
+)
+
+// snsEnvelope is the SNS notification delivered to the SQS subscription without the raw message delivery
+type snsEnvelope struct {
+	Type              string                  `json:"Type"`
+	MessageID         string                  `json:"MessageId"`
+	TopicArn          string                  `json:"TopicArn"`
+	Message           *string                 `json:"Message"`
+	MessageAttributes map[string]snsAttribute `json:"MessageAttributes"`
+}
+
+type snsAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// unwrapSNS replaces the SNS notification envelope with the published message, the envelope message attributes are
+// promoted to the SQS ones (the SQS attributes take precedence). The envelope is detected per message, so the subscription
+// might switch between the raw and the enveloped delivery without the config change (sns_unwrap).
+func (c *Driver) unwrapSNS(body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, map[string]types.MessageAttributeValue) {
+	if !c.snsUnwrap {
+		return body, attrs
+	}
+
+	env, ok := snsNotificationEnvelope(body)
+	if !ok {
+		c.snsDetected(snsModeRaw)
+		return body, attrs
+	}
+
+	c.snsDetected(snsModeEnvelope)
+
+	if len(env.MessageAttributes) > 0 {
+		promoted := make(map[string]types.MessageAttributeValue, len(attrs)+len(env.MessageAttributes))
+		for name, attr := range env.MessageAttributes {
+			v, err := snsAttributeValue(attr)
+			if err != nil {
+				c.log.Warn("failed to decode the SNS message attribute, skipped", zap.String("topic_arn", env.TopicArn), zap.String("attribute", name), zap.Error(err))
+				continue
+			}
+			promoted[name] = v
+		}
+		for name, attr := range attrs {
+			promoted[name] = attr
+		}
+		attrs = promoted
+	}
+
+	return []byte(*env.Message), attrs
+}
+
+// snsNotificationEnvelope returns the envelope if the body is the SNS notification
+func snsNotificationEnvelope(body []byte) (*snsEnvelope, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+
+	env := &snsEnvelope{}
+	err := json.Unmarshal(trimmed, env)
+	if err != nil || env.Type != snsNotification || env.TopicArn == "" || env.MessageID == "" || env.Message == nil {
+		return nil, false
+	}
+
+	return env, true
+}
+
+func snsAttributeValue(attr snsAttribute) (types.MessageAttributeValue, error) {
+	switch attr.Type {
+	case BinaryType:
+		b, err := base64.StdEncoding.DecodeString(attr.Value)
+		if err != nil {
+			return types.MessageAttributeValue{}, err
+		}
+		return types.MessageAttributeValue{DataType: aws.String(BinaryType), BinaryValue: b}, nil
+	case snsStringArray:
+		return types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(attr.Value)}, nil
+	default:
+		return types.MessageAttributeValue{DataType: aws.String(attr.Type), StringValue: aws.String(attr.Value)}, nil
+	}
+}
+
+// snsDetected logs the detected delivery mode on the first message and on every switch
+func (c *Driver) snsDetected(mode int32) {
+	prev := atomic.SwapInt32(&c.snsMode, mode)
+	if prev == mode {
+		return
+	}
+
+	if prev == snsModeUnknown {
+		c.log.Info("SNS delivery mode detected", zap.String("mode", snsModeName(mode)))
+		return
+	}
+
+	c.log.Info("SNS delivery mode switched", zap.String("mode", snsModeName(mode)), zap.String("previous", snsModeName(prev)))
+}
+
+func snsModeName(mode int32) string {
+	switch mode {
+	case snsModeRaw:
+		return "raw"
+	case snsModeEnvelope:
+		return "envelope"
+	default:
+		return "unknown"
+	}
+}
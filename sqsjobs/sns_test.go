@@ -0,0 +1,79 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const snsEnveloped = `{
+  "Type": "Notification",
+  "MessageId": "9b5a1a2e-6f0e-5c1e-9d8c-0f0e1c2b3a4d",
+  "TopicArn": "arn:aws:sns:us-east-1:123456789012:orders",
+  "Message": "{\"order\":1}",
+  "Timestamp": "2024-01-01T00:00:00.000Z",
+  "MessageAttributes": {
+    "tenant": {"Type": "String", "Value": "acme"},
+    "blob": {"Type": "Binary", "Value": "AQID"}
+  }
+}`
+
+func TestSNSUnwrapMixedDelivery(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	core, logs := observer.New(zapcore.InfoLevel)
+	c.log = zap.New(core)
+	c.snsUnwrap = true
+
+	enveloped := types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("rh-1"), Body: aws.String(snsEnveloped)}
+	// the raw message delivery, the SNS attributes are the SQS message attributes
+	raw := types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("rh-2"), Body: aws.String(`{"order":2}`),
+		MessageAttributes: map[string]types.MessageAttributeValue{"tenant": {DataType: aws.String(StringType), StringValue: aws.String("globex")}},
+	}
+
+	for _, tc := range []struct {
+		msg     types.Message
+		payload string
+		tenant  string
+	}{
+		{enveloped, `{"order":1}`, "acme"},
+		{raw, `{"order":2}`, "globex"},
+		// switched back
+		{enveloped, `{"order":1}`, "acme"},
+	} {
+		item, err := c.unpack(context.Background(), &tc.msg)
+		require.NoError(t, err)
+		require.Equal(t, tc.payload, string(item.Payload))
+		require.Equal(t, []string{tc.tenant}, item.headers["tenant"])
+	}
+
+	require.Equal(t, 1, logs.FilterMessage("SNS delivery mode detected").FilterField(zap.String("mode", "envelope")).Len())
+	require.Equal(t, 2, logs.FilterMessage("SNS delivery mode switched").Len())
+
+	// disabled - the envelope is passed as is
+	c.snsUnwrap = false
+	item, err := c.unpack(context.Background(), &enveloped)
+	require.NoError(t, err)
+	require.Equal(t, snsEnveloped, string(item.Payload))
+}
+
+func TestSNSNotificationEnvelope(t *testing.T) {
+	env, ok := snsNotificationEnvelope([]byte(snsEnveloped))
+	require.True(t, ok)
+	require.Equal(t, "arn:aws:sns:us-east-1:123456789012:orders", env.TopicArn)
+
+	v, err := snsAttributeValue(env.MessageAttributes["blob"])
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, v.BinaryValue)
+
+	// the JSON objects published as is are not the envelopes
+	for _, body := range []string{`{"Type":"Notification"}`, `{"Message":"x","TopicArn":"arn"}`, `[1,2]`, `not json`, ``} {
+		_, ok = snsNotificationEnvelope([]byte(body))
+		require.False(t, ok, body)
+	}
+}
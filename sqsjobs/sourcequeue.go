@@ -0,0 +1,11 @@
+package sqsjobs
+
+// stampSourceQueue sets the source queue header to the URL of the consumed queue. The header is set after the headers
+// filter, so it's not dropped by propagate_headers, and the value from the message (e.g. forwarded from another queue) is replaced.
+func (c *Driver) stampSourceQueue(h map[string][]string) {
+	if c.sourceHeader == "" || c.queueURL == nil {
+		return
+	}
+
+	h[c.sourceHeader] = []string{*c.queueURL}
+}
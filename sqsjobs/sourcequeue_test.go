@@ -0,0 +1,33 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceQueueHeader(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.sourceHeader = "X-Source-Queue"
+	// not in the propagate_headers list
+	c.headers = newHeaderFilter([]string{"X-Tenant"}, nil, c.prop.Fields())
+
+	// forwarded from another queue, the value is replaced with the consumed queue
+	in, err := (&Item{Job: "job", Ident: "id", headers: map[string][]string{"X-Source-Queue": {"http://127.0.0.1:9324/000000000000/other"}, "X-Tenant": {"a"}}, Options: &Options{}}).pack(c.queueURL, c.queue, "", false)
+	require.NoError(t, err)
+
+	item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String(""), MessageAttributes: in.MessageAttributes})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://127.0.0.1:9324/000000000000/test"}, item.headers["X-Source-Queue"])
+	require.Equal(t, []string{"a"}, item.headers["X-Tenant"])
+	require.Equal(t, "test", item.Options.Queue)
+
+	// disabled
+	c.sourceHeader = ""
+	item, err = c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), Body: aws.String(""), MessageAttributes: in.MessageAttributes})
+	require.NoError(t, err)
+	require.NotContains(t, item.headers, "X-Source-Queue")
+}
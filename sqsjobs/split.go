@@ -0,0 +1,141 @@
+package sqsjobs
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+)
+
+const (
+	// SplitIDAttr is the correlation ID (the original job ID) shared by the parts of the split payload
+	SplitIDAttr string = "X-RR-Split-ID"
+	// SplitPartAttr is the part number and the total number of parts, e.g. 2/5
+	SplitPartAttr string = "X-RR-Split-Part"
+
+	// maxMessageBytes is the SQS message size limit (256 KiB), including the attributes
+	maxMessageBytes int = 256 * 1024
+	// reserved for the split attributes
+	splitOverhead int = 256
+	// AES-GCM nonce and tag
+	encryptionOverhead int = 28
+)
+
+// splitInfo is the part of the split payload
+type splitInfo struct {
+	id    string
+	part  int
+	total int
+}
+
+// pushSplit sends the item, the oversized JSON array payload is split into several messages sharing the SplitIDAttr.
+// Every part is a valid JSON array with a subset of the elements, the consumers are responsible for the re-assembly.
+func (c *Driver) pushSplit(ctx context.Context, msg *Item) error {
+	d, err := c.prepare(ctx, msg, c.queueURL, c.queue)
+	if err != nil {
+		return err
+	}
+
+	size := messageSize(d)
+	if size <= maxMessageBytes || !isJSONArray(msg.Payload) {
+		return c.deliver(ctx, d)
+	}
+
+	// the attributes are the same for every part
+	budget := maxMessageBytes - (size - len(getordefault(d.MessageBody))) - splitOverhead
+	if c.aead != nil {
+		// base64 of the nonce + ciphertext + tag
+		budget = budget/4*3 - encryptionOverhead
+	}
+
+	chunks, err := splitArray(msg.Payload, budget)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(chunks); i++ {
+		opts := *msg.Options
+		opts.split = &splitInfo{id: msg.Ident, part: i + 1, total: len(chunks)}
+		part := &Item{
+			Job:     msg.Job,
+			Ident:   msg.Ident + "-" + strconv.Itoa(i+1),
+			Payload: chunks[i],
+			headers: maps.Clone(msg.headers),
+			Options: &opts,
+		}
+
+		pd, err := c.prepare(ctx, part, c.queueURL, c.queue)
+		if err != nil {
+			return err
+		}
+
+		err = c.deliver(ctx, pd)
+		if err != nil {
+			return errors.Errorf("failed to send the part %d of %d of the split payload: %v", i+1, len(chunks), err)
+		}
+	}
+
+	return nil
+}
+
+func isJSONArray(payload []byte) bool {
+	trimmed := bytes.TrimSpace(payload)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// splitArray splits the JSON array into the arrays not larger than the budget (in bytes)
+func splitArray(payload []byte, budget int) ([][]byte, error) {
+	var elems []json.RawMessage
+	err := json.Unmarshal(payload, &elems)
+	if err != nil {
+		return nil, errors.Errorf("failed to split the payload, not a valid JSON array: %v", err)
+	}
+
+	var chunks [][]byte
+	chunk := []byte{'['}
+	for i := 0; i < len(elems); i++ {
+		// brackets
+		if len(elems[i])+2 > budget {
+			return nil, errors.Errorf("array element %d (%d bytes) exceeds the maximum message size", i, len(elems[i]))
+		}
+
+		// comma and the closing bracket
+		if len(chunk) > 1 && len(chunk)+len(elems[i])+2 > budget {
+			chunks = append(chunks, append(chunk, ']'))
+			chunk = []byte{'['}
+		}
+
+		if len(chunk) > 1 {
+			chunk = append(chunk, ',')
+		}
+		chunk = append(chunk, elems[i]...)
+	}
+
+	return append(chunks, append(chunk, ']')), nil
+}
+
+// splitAttributes sets the correlation attributes of the split payload part
+func splitAttributes(item *Item, in *sqs.SendMessageInput) {
+	if item.Options.split == nil {
+		return
+	}
+
+	s := item.Options.split
+	in.MessageAttributes[SplitIDAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(s.id)}
+	in.MessageAttributes[SplitPartAttr] = types.MessageAttributeValue{DataType: aws.String(StringType), StringValue: aws.String(strconv.Itoa(s.part) + "/" + strconv.Itoa(s.total))}
+}
+
+// readSplit promotes the split attributes to the headers, so the workers are able to re-assemble the payload
+func readSplit(attrs map[string]types.MessageAttributeValue, h map[string][]string) {
+	for _, name := range []string{SplitIDAttr, SplitPartAttr} {
+		if attr, ok := attrs[name]; ok && attr.StringValue != nil && headerValue(h, name) == "" {
+			h[name] = []string{*attr.StringValue}
+		}
+	}
+}
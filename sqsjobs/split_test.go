@@ -0,0 +1,73 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitOversizedArray(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.splitArrays = true
+	fc := newFakeClient()
+	c.client = fc
+
+	// 1000 elements of ~1 KiB, ~1 MiB in total
+	elems := make([]string, 1000)
+	for i := 0; i < len(elems); i++ {
+		elems[i] = strconv.Itoa(i) + strings.Repeat("x", 1024)
+	}
+	payload, err := json.Marshal(elems)
+	require.NoError(t, err)
+
+	require.NoError(t, c.pushSplit(context.Background(), &Item{Job: "job", Ident: "agg", Payload: payload, headers: map[string][]string{}, Options: &Options{}}))
+
+	fc.mu.Lock()
+	sent := fc.sent
+	fc.mu.Unlock()
+	require.GreaterOrEqual(t, len(sent), 4)
+	require.Less(t, len(sent), 6)
+
+	var got []string
+	for i := 0; i < len(sent); i++ {
+		require.LessOrEqual(t, messageSize(sent[i]), maxMessageBytes)
+		require.Equal(t, "agg", aws.ToString(sent[i].MessageAttributes[SplitIDAttr].StringValue))
+		require.Equal(t, strconv.Itoa(i+1)+"/"+strconv.Itoa(len(sent)), aws.ToString(sent[i].MessageAttributes[SplitPartAttr].StringValue))
+
+		item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String(strconv.Itoa(i)), Body: sent[i].MessageBody, MessageAttributes: sent[i].MessageAttributes})
+		require.NoError(t, err)
+		require.Equal(t, "agg-"+strconv.Itoa(i+1), item.ID())
+		require.Equal(t, []string{"agg"}, item.headers[SplitIDAttr])
+
+		var part []string
+		require.NoError(t, json.Unmarshal(item.Payload, &part))
+		got = append(got, part...)
+	}
+	// re-assembled in order
+	require.Equal(t, elems, got)
+}
+
+func TestSplitSmallOrNotArray(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.splitArrays = true
+	fc := newFakeClient()
+	c.client = fc
+
+	require.NoError(t, c.pushSplit(context.Background(), &Item{Job: "job", Ident: "small", Payload: []byte(`[1,2,3]`), headers: map[string][]string{}, Options: &Options{}}))
+	// the oversized object is sent as is, SQS rejects it
+	require.NoError(t, c.pushSplit(context.Background(), &Item{Job: "job", Ident: "obj", Payload: []byte(`{"a":"` + strings.Repeat("x", maxMessageBytes) + `"}`), headers: map[string][]string{}, Options: &Options{}}))
+
+	require.Len(t, fc.sent, 2)
+	require.NotContains(t, fc.sent[0].MessageAttributes, SplitIDAttr)
+	require.NotContains(t, fc.sent[1].MessageAttributes, SplitIDAttr)
+
+	// a single element doesn't fit
+	_, err := splitArray([]byte(`["`+strings.Repeat("x", 100)+`"]`), 50)
+	require.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package sqsjobs
+
+import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// credentials sources, the values are never logged
+const (
+	credsStatic       string = "static"
+	credsProfile      string = "profile"
+	credsDefaultChain string = "default_chain"
+	credsChain        string = "credential_chain"
+	credsAssumeRole   string = "assume_role"
+)
+
+// clientOptions returns the options of the SQS client (the current one for the rotating client)
+func clientOptions(client sqsClient) (sqs.Options, bool) {
+	if b, ok := client.(*budgetClient); ok {
+		client = b.sqsClient
+	}
+
+	if m, ok := client.(*metricsClient); ok {
+		client = m.sqsClient
+	}
+
+	if r, ok := client.(*rotatingClient); ok {
+		client = r.get()
+	}
+
+	c, ok := client.(interface{ Options() sqs.Options })
+	if !ok {
+		return sqs.Options{}, false
+	}
+
+	return c.Options(), true
+}
+
+// credentialsSource returns the name of the credentials source, the same order as in the checkEnv
+func credentialsSource(conf *Config, insideAWS bool) string {
+	switch {
+	case conf.RoleARN != "":
+		return credsAssumeRole
+	case len(conf.CredentialChain) > 0:
+		return credsChain
+	case conf.Profile != "", conf.SharedConfigFile != "" || conf.SharedCredentialsFile != "":
+		return credsProfile
+	case insideAWS && conf.Secret != "" && conf.Key != "" && conf.SessionToken != "":
+		return credsStatic
+	case insideAWS:
+		return credsDefaultChain
+	default:
+		return credsStatic
+	}
+}
+
+// queueARN builds the queue ARN from the queue URL and the region, empty if the URL is not a valid queue URL
+func queueARN(region, queueURL string) string {
+	if region == "" {
+		return ""
+	}
+
+	if _, err := parseQueueURL(queueURL); err != nil {
+		return ""
+	}
+
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+
+	// /<account id>/<queue name>
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+
+	return "arn:" + regionPartition(region) + ":sqs:" + region + ":" + parts[0] + ":" + parts[1]
+}
+
+// logStartup logs the resolved pipeline environment as a single line for the support triage,
+// the verbose fields are added at the debug level
+func (c *Driver) logStartup(conf *Config, insideAWS bool) {
+	pipe := *c.pipeline.Load()
+
+	var region, endpoint string
+	if opts, ok := clientOptions(c.client); ok {
+		region = opts.Region
+		endpoint = getordefault(opts.BaseEndpoint)
+	}
+	if endpoint == "" {
+		endpoint = "resolved by SDK"
+	}
+
+	fields := []zap.Field{
+		zap.String("pipeline", pipe.Name()),
+		zap.String("region", region),
+		zap.Stringp("queue_url", c.queueURL),
+		zap.Bool("fifo", strings.HasSuffix(getordefault(c.queue), fifoSuffix)),
+		zap.Bool("inside_aws", insideAWS),
+		zap.Int("pollers", c.pollersNumber()),
+	}
+
+	if c.log.Core().Enabled(zapcore.DebugLevel) {
+		fields = append(fields,
+			zap.String("endpoint", endpoint),
+			zap.String("queue_arn", queueARN(region, getordefault(c.queueURL))),
+			zap.Int32("visibility_timeout", atomic.LoadInt32(&c.visibilityTimeout)),
+			zap.Int32("wait_time_seconds", atomic.LoadInt32(&c.waitTime)),
+			zap.Int32("prefetch", atomic.LoadInt32(c.msgInFlightLimit)),
+			zap.String("credentials", credentialsSource(conf, insideAWS)),
+		)
+	}
+
+	c.log.Info("pipeline was initialized", fields...)
+	c.logWaitTime()
+	c.logHeartbeat()
+}
@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStartupSummary(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	c := newTestDriver(&testQueue{}, nil)
+	c.log = zap.New(core)
+	c.client = sqs.New(sqs.Options{Region: "us-east-1", BaseEndpoint: aws.String("http://127.0.0.1:9324")})
+	c.queue = aws.String("orders.fifo")
+	c.queueURL = aws.String("http://127.0.0.1:9324/000000000000/orders.fifo")
+	c.visibilityTimeout = 60
+	c.waitTime = 20
+	c.pollers = 3
+
+	conf := &Config{Key: "AKIAEXAMPLE", Secret: "super-secret", SessionToken: "session-token"}
+	c.logStartup(conf, false)
+
+	entries := logs.FilterMessage("pipeline was initialized").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, zapcore.InfoLevel, entries[0].Level)
+
+	fields := entries[0].ContextMap()
+	require.Equal(t, "test", fields["pipeline"])
+	require.Equal(t, "us-east-1", fields["region"])
+	require.Equal(t, "http://127.0.0.1:9324/000000000000/orders.fifo", fields["queue_url"])
+	require.Equal(t, true, fields["fifo"])
+	require.Equal(t, false, fields["inside_aws"])
+	require.EqualValues(t, 3, fields["pollers"])
+	require.Equal(t, "http://127.0.0.1:9324", fields["endpoint"])
+	require.Equal(t, "arn:aws:sqs:us-east-1:000000000000:orders.fifo", fields["queue_arn"])
+	require.EqualValues(t, 60, fields["visibility_timeout"])
+	require.EqualValues(t, 20, fields["wait_time_seconds"])
+	require.Equal(t, credsStatic, fields["credentials"])
+
+	// never log the secrets
+	for _, v := range fields {
+		s := fmt.Sprint(v)
+		require.NotContains(t, s, "AKIAEXAMPLE")
+		require.NotContains(t, s, "super-secret")
+		require.NotContains(t, s, "session-token")
+	}
+
+	// verbose fields only at the debug level
+	core, logs = observer.New(zapcore.InfoLevel)
+	c.log = zap.New(core)
+	c.logStartup(conf, false)
+
+	fields = logs.FilterMessage("pipeline was initialized").All()[0].ContextMap()
+	require.Contains(t, fields, "queue_url")
+	require.NotContains(t, fields, "endpoint")
+	require.NotContains(t, fields, "credentials")
+}
+
+func TestCredentialsSource(t *testing.T) {
+	require.Equal(t, credsStatic, credentialsSource(&Config{}, false))
+	require.Equal(t, credsProfile, credentialsSource(&Config{Profile: "dev"}, false))
+	require.Equal(t, credsChain, credentialsSource(&Config{CredentialChain: []string{credsEnv}}, true))
+	require.Equal(t, credsDefaultChain, credentialsSource(&Config{}, true))
+	require.Equal(t, credsStatic, credentialsSource(&Config{Key: "k", Secret: "s", SessionToken: "t"}, true))
+	// the profile wins over the static credentials inside and outside AWS
+	require.Equal(t, credsProfile, credentialsSource(&Config{Profile: "dev", Key: "k", Secret: "s", SessionToken: "t"}, true))
+	require.Equal(t, credsProfile, credentialsSource(&Config{Profile: "dev", Key: "k", Secret: "s", SessionToken: "t"}, false))
+	require.Equal(t, credsAssumeRole, credentialsSource(&Config{Profile: "dev", RoleARN: "arn:aws:iam::123456789012:role/rr"}, false))
+}
@@ -0,0 +1,113 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/api/v4/plugins/v3/jobs"
+	"github.com/roadrunner-server/errors"
+)
+
+// Stats extends the pipeline state with the sqs specific values
+type Stats struct {
+	*jobs.State
+	// DLQMessages is the approximate number of messages in the dead-letter queue, nil if the dead-letter queue is not configured
+	DLQMessages *int64 `json:"dlq_messages,omitempty"`
+	// RecoveredPanics is the number of the panics recovered in the message handling since the pipeline start
+	RecoveredPanics uint64 `json:"recovered_panics"`
+	// EventsDropped is the number of the lifecycle and message events dropped on the full events_buffer
+	EventsDropped uint64 `json:"events_dropped"`
+	// InvalidBodies is the number of the messages rejected by the BodyValidator since the pipeline start
+	InvalidBodies uint64 `json:"invalid_bodies"`
+	// ExpiredMessages is the number of the messages dropped by the max_job_age (max_message_age)
+	ExpiredMessages uint64 `json:"expired_messages"`
+	// ExpiredOnAck is the number of the messages with the receipt handle expired before the ack (already visible again)
+	ExpiredOnAck uint64 `json:"expired_on_ack"`
+	// InFlightLimitReached is the number of the OverLimit receive responses (the queue in-flight messages quota),
+	// InFlightLimited is true while the receive is backing off on them
+	InFlightLimitReached uint64 `json:"in_flight_limit_reached"`
+	InFlightLimited      bool   `json:"in_flight_limited"`
+	// LastSuccessAt is the time of the last successful receive or send
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	// LastErrorAt and LastError are the time and the (redacted) text of the last failed receive or send
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	// HealthState is ok, degraded (throttling or auth errors within the last minute) or unavailable (the last health
+	// check failed), HealthReason is why the pipeline is not healthy
+	HealthState  string `json:"health_state"`
+	HealthReason string `json:"health_reason,omitempty"`
+	// LastHealthCheckAt is the time of the last health check (health_check_interval)
+	LastHealthCheckAt *time.Time `json:"last_health_check_at,omitempty"`
+	// CredentialsExpireAt is the expiration time of the temporary (STS) credentials, omitted for the static keys
+	CredentialsExpireAt *time.Time `json:"credentials_expire_at,omitempty"`
+	// NotReadyReason is why the pipeline is not ready yet (e.g. waiting for the first receive), empty if ready
+	NotReadyReason string `json:"not_ready_reason,omitempty"`
+	// ReceiveLatency and MessageAge are the poll loop histograms (latency_metrics), nil if disabled
+	ReceiveLatency *Histogram `json:"receive_latency,omitempty"`
+	MessageAge     *Histogram `json:"message_age,omitempty"`
+	// PayloadSize is the size (in bytes) of the sent messages, including the attributes (payload_warn_bytes or latency_metrics)
+	PayloadSize *Histogram `json:"payload_size,omitempty"`
+	// InFlight is the number of the messages received by this consumer and not acknowledged yet, unlike the queue-wide Reserved
+	InFlight int64 `json:"in_flight"`
+	// Throughput is the push/ack/nack/requeue activity of this consumer
+	Throughput *Throughput `json:"throughput,omitempty"`
+	// CircuitBreaker is closed, open or half_open, empty if the circuit_breaker_threshold is not set,
+	// CircuitBreakerTrips is the number of the times it was opened
+	CircuitBreaker      string `json:"circuit_breaker,omitempty"`
+	CircuitBreakerTrips uint64 `json:"circuit_breaker_trips"`
+	// Paused is true while the pipeline is paused, PausedAt and ResumedAt are the times of the last pause and resume
+	Paused    bool       `json:"paused"`
+	PausedAt  *time.Time `json:"paused_at,omitempty"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+}
+
+// Stats returns the pipeline state, including the dead-letter queue depth if configured
+func (c *Driver) Stats(ctx context.Context) (*Stats, error) {
+	const op = errors.Op("sqs_stats")
+
+	ctx, span := c.spanProvider(ctx).Tracer(tracerName).Start(ctx, "sqs_stats")
+	defer span.End()
+
+	st, err := c.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Stats{
+		State:                st,
+		RecoveredPanics:      c.RecoveredPanics(),
+		EventsDropped:        c.events.eventsDropped(),
+		InvalidBodies:        c.InvalidBodies(),
+		ExpiredMessages:      c.ExpiredMessages(),
+		ExpiredOnAck:         c.ExpiredOnAck(),
+		InFlightLimitReached: c.InFlightLimitReached(),
+		InFlightLimited:      atomic.LoadInt32(&c.inFlightLimited) > 0,
+	}
+	c.fillHealth(out)
+	_, out.NotReadyReason = c.Ready()
+	c.fillLatency(out)
+	out.PayloadSize = c.payload.snapshot()
+	out.InFlight = atomic.LoadInt64(c.msgInFlight)
+	out.Throughput = c.throughput.snapshot()
+	out.CircuitBreaker, out.CircuitBreakerTrips = c.breaker.stats()
+	c.fillPaused(out)
+	// poll the dead-letter queue only if configured
+	if c.dlqURL == nil {
+		return out, nil
+	}
+
+	attr, err := c.depthAttributes(ctx, c.dlqURL, types.QueueAttributeNameApproximateNumberOfMessages)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	nom, err := strconv.Atoi(attr[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+	if err == nil {
+		out.DLQMessages = ptr(int64(nom))
+	}
+
+	return out, nil
+}
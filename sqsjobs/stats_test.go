@@ -0,0 +1,41 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsDLQDepth(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	fc := newFakeClient()
+	var queues []string
+	fc.getAttrsFn = func(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		queues = append(queues, aws.ToString(in.QueueUrl))
+		depth := "3"
+		if aws.ToString(in.QueueUrl) == aws.ToString(c.dlqURL) {
+			depth = "42"
+		}
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{"ApproximateNumberOfMessages": depth}}, nil
+	}
+	c.client = fc
+
+	// no dead-letter queue - not polled
+	st, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), st.Active)
+	require.Nil(t, st.DLQMessages)
+	require.Equal(t, []string{aws.ToString(c.queueURL)}, queues)
+
+	queues = nil
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+	st, err = c.Stats(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), st.Active)
+	require.NotNil(t, st.DLQMessages)
+	require.Equal(t, int64(42), *st.DLQMessages)
+	require.Equal(t, []string{aws.ToString(c.queueURL), aws.ToString(c.dlqURL)}, queues)
+}
@@ -0,0 +1,75 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// statsCache keeps the queue depth attributes read by the State/Stats calls, so the frequent reads (e.g. the autoscalers
+// and the metrics scrapes) don't call GetQueueAttributes every time. A read older than stats_max_staleness refreshes
+// the attributes synchronously, the concurrent reads wait for the same refresh.
+type statsCache struct {
+	mu         sync.Mutex
+	maxStale   time.Duration
+	now        func() time.Time
+	attributes map[string]cachedAttributes
+}
+
+type cachedAttributes struct {
+	at    time.Time
+	attrs map[string]string
+}
+
+// newStatsCache returns nil if the caching is disabled (0 or negative), every read calls GetQueueAttributes then
+func newStatsCache(sec int) *statsCache {
+	if sec <= 0 {
+		return nil
+	}
+
+	return &statsCache{
+		maxStale:   time.Duration(sec) * time.Second,
+		now:        time.Now,
+		attributes: make(map[string]cachedAttributes),
+	}
+}
+
+// depthAttributes returns the queue attributes, from the cache if it's not older than the stats_max_staleness
+func (c *Driver) depthAttributes(ctx context.Context, queueURL *string, names ...types.QueueAttributeName) (map[string]string, error) {
+	s := c.statsCache
+	if s == nil {
+		return c.getAttributes(ctx, queueURL, names)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the queue URL might change on the recreate or the rotation
+	key := getordefault(queueURL)
+	if e, ok := s.attributes[key]; ok && s.now().Sub(e.at) < s.maxStale {
+		return e.attrs, nil
+	}
+
+	attrs, err := c.getAttributes(ctx, queueURL, names)
+	if err != nil {
+		return nil, err
+	}
+
+	s.attributes[key] = cachedAttributes{at: s.now(), attrs: attrs}
+	return attrs, nil
+}
+
+func (c *Driver) getAttributes(ctx context.Context, queueURL *string, names []types.QueueAttributeName) (map[string]string, error) {
+	out, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       queueURL,
+		AttributeNames: names,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Attributes, nil
+}
@@ -0,0 +1,56 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsMaxStaleness(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.statsCache = newStatsCache(5)
+	now := time.Now()
+	c.statsCache.now = func() time.Time { return now }
+
+	depth := 1
+	fc := newFakeClient()
+	fc.getAttrsFn = func(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+		return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{
+			string(types.QueueAttributeNameApproximateNumberOfMessages): strconv.Itoa(depth),
+		}}, nil
+	}
+	c.client = fc
+
+	st, err := c.State(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Active)
+
+	// within the staleness the cached depth is returned
+	depth = 100
+	now = now.Add(time.Second * 4)
+	st, err = c.State(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), st.Active)
+	require.Equal(t, 1, fc.called("GetQueueAttributes"))
+
+	// the stale cache is refreshed on the read
+	now = now.Add(time.Second)
+	st, err = c.State(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(100), st.Active)
+	require.Equal(t, 2, fc.called("GetQueueAttributes"))
+
+	// disabled - every read calls GetQueueAttributes
+	c.statsCache = newStatsCache(0)
+	require.Nil(t, c.statsCache)
+	_, err = c.State(context.Background())
+	require.NoError(t, err)
+	_, err = c.State(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4, fc.called("GetQueueAttributes"))
+}
@@ -0,0 +1,131 @@
+package sqsjobs
+
+import (
+	"sync"
+	"time"
+)
+
+// throughputWindow is the number of seconds the rates and the ack latency are averaged over
+const throughputWindow = 60
+
+// Throughput is the push/ack/nack/requeue activity of this consumer: the totals since the pipeline start and the
+// per-second rates over the last minute
+type Throughput struct {
+	Pushed   uint64 `json:"pushed"`
+	Acked    uint64 `json:"acked"`
+	Nacked   uint64 `json:"nacked"`
+	Requeued uint64 `json:"requeued"`
+
+	PushRate    float64 `json:"push_rate"`
+	AckRate     float64 `json:"ack_rate"`
+	NackRate    float64 `json:"nack_rate"`
+	RequeueRate float64 `json:"requeue_rate"`
+	// AvgAckLatency is the average time (in seconds) from the receive to the ack over the last minute, 0 if nothing was acked
+	AvgAckLatency float64 `json:"avg_ack_latency"`
+}
+
+// eventWindow counts the events (and sums their values) in the per-second buckets of the last minute
+type eventWindow struct {
+	total  uint64
+	secs   [throughputWindow]int64
+	counts [throughputWindow]uint64
+	sums   [throughputWindow]time.Duration
+}
+
+func (w *eventWindow) add(now time.Time, v time.Duration) {
+	sec := now.Unix()
+	i := sec % throughputWindow
+	if w.secs[i] != sec {
+		w.secs[i] = sec
+		w.counts[i] = 0
+		w.sums[i] = 0
+	}
+
+	w.total++
+	w.counts[i]++
+	w.sums[i] += v
+}
+
+// last returns the number of events and the sum of their values over the window
+func (w *eventWindow) last(now time.Time) (uint64, time.Duration) {
+	var n uint64
+	var sum time.Duration
+	from := now.Unix() - throughputWindow
+	for i := range w.secs {
+		if w.secs[i] > from {
+			n += w.counts[i]
+			sum += w.sums[i]
+		}
+	}
+
+	return n, sum
+}
+
+type throughput struct {
+	mu                              sync.Mutex
+	pushed, acked, nacked, requeued eventWindow
+	now                             func() time.Time
+}
+
+func newThroughput() *throughput {
+	return &throughput{now: time.Now}
+}
+
+func (t *throughput) record(w *eventWindow, v time.Duration) {
+	t.mu.Lock()
+	w.add(t.now(), v)
+	t.mu.Unlock()
+}
+
+// the methods are nil-safe, the pushed jobs are not counted on the ack
+
+func (t *throughput) push() {
+	if t != nil {
+		t.record(&t.pushed, 0)
+	}
+}
+
+func (t *throughput) ack(receivedAt time.Time) {
+	if t != nil {
+		t.record(&t.acked, t.now().Sub(receivedAt))
+	}
+}
+
+func (t *throughput) nack() {
+	if t != nil {
+		t.record(&t.nacked, 0)
+	}
+}
+
+func (t *throughput) requeue() {
+	if t != nil {
+		t.record(&t.requeued, 0)
+	}
+}
+
+// snapshot returns the totals and the rates, nil if disabled
+func (t *throughput) snapshot() *Throughput {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	out := &Throughput{Pushed: t.pushed.total, Acked: t.acked.total, Nacked: t.nacked.total, Requeued: t.requeued.total}
+
+	n, _ := t.pushed.last(now)
+	out.PushRate = float64(n) / throughputWindow
+	n, latency := t.acked.last(now)
+	out.AckRate = float64(n) / throughputWindow
+	if n > 0 {
+		out.AvgAckLatency = (latency / time.Duration(n)).Seconds()
+	}
+	n, _ = t.nacked.last(now)
+	out.NackRate = float64(n) / throughputWindow
+	n, _ = t.requeued.last(now)
+	out.RequeueRate = float64(n) / throughputWindow
+
+	return out
+}
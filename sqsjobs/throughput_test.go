@@ -0,0 +1,58 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThroughputWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tp := newThroughput()
+	tp.now = func() time.Time { return now }
+
+	for range 30 {
+		tp.push()
+	}
+	tp.ack(now.Add(-time.Second))
+	tp.ack(now.Add(-time.Second * 3))
+	tp.nack()
+
+	out := tp.snapshot()
+	require.Equal(t, uint64(30), out.Pushed)
+	require.Equal(t, uint64(2), out.Acked)
+	require.Equal(t, uint64(1), out.Nacked)
+	require.Equal(t, 0.5, out.PushRate)
+	require.Equal(t, 2.0, out.AvgAckLatency)
+
+	// the rates are reset after the window, the totals are kept
+	now = now.Add(time.Second * throughputWindow)
+	out = tp.snapshot()
+	require.Equal(t, uint64(30), out.Pushed)
+	require.Equal(t, 0.0, out.PushRate)
+	require.Equal(t, 0.0, out.AckRate)
+	require.Equal(t, 0.0, out.AvgAckLatency)
+
+	// nil-safe
+	var disabled *throughput
+	disabled.push()
+	disabled.ack(now)
+	require.Nil(t, disabled.snapshot())
+}
+
+func TestThroughputStats(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.client = newFakeClient()
+
+	item, err := c.unpack(context.Background(), &types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("1"), Body: aws.String("body")})
+	require.NoError(t, err)
+	require.NoError(t, item.Ack())
+
+	out := c.throughput.snapshot()
+	require.Equal(t, uint64(1), out.Acked)
+	require.Equal(t, uint64(0), out.Nacked)
+}
@@ -0,0 +1,78 @@
+package sqsjobs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracing modes
+const (
+	// tracingAuto - the tracer plugin is used if present, the spans are not exported otherwise (default)
+	tracingAuto string = "auto"
+	// tracingDisabled - no spans, even if the tracer plugin is present, the incoming trace context is passed through
+	tracingDisabled string = "disabled"
+	// tracingRequired - the pipeline fails to start without the tracer plugin
+	tracingRequired string = "required"
+)
+
+// span attributes, the messaging ones follow the OpenTelemetry messaging semantic conventions
+const (
+	spanMessagingSystem  string = "messaging.system"
+	spanDestinationName  string = "messaging.destination.name"
+	spanMessageID        string = "messaging.message.id"
+	spanQueueURL         string = "aws.sqs.queue.url"
+	spanReceiveCount     string = "aws.sqs.receive_count"
+	messagingSystemValue string = "aws_sqs"
+)
+
+// newTracerProvider returns the tracer provider for the tracing mode, the provider collected from the tracer plugin might be nil
+func newTracerProvider(mode string, tp *sdktrace.TracerProvider) (trace.TracerProvider, error) {
+	switch mode {
+	case "", tracingAuto:
+		if tp == nil {
+			return sdktrace.NewTracerProvider(), nil
+		}
+		return tp, nil
+	case tracingDisabled:
+		return noop.NewTracerProvider(), nil
+	case tracingRequired:
+		if tp == nil {
+			return nil, errors.Str("tracing: required, but no tracer provider is available (the otel plugin is not enabled)")
+		}
+		return tp, nil
+	default:
+		return nil, errors.Errorf("unknown tracing mode: %s, supported: auto, disabled, required", mode)
+	}
+}
+
+// spanProvider returns the provider of the context span (the jobs plugin trace), the pipeline one if the tracing is disabled
+func (c *Driver) spanProvider(ctx context.Context) trace.TracerProvider {
+	if c.tracingOff {
+		return c.tracer
+	}
+
+	return trace.SpanFromContext(ctx).TracerProvider()
+}
+
+// queueSpanAttributes returns the attributes of the pipeline queue: the system, the queue name and the URL
+func (c *Driver) queueSpanAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(spanMessagingSystem, messagingSystemValue),
+		attribute.String(spanDestinationName, getordefault(c.queue)),
+		attribute.String(spanQueueURL, getordefault(c.queueURL)),
+	}
+}
+
+// receiveSpanAttributes returns the queue attributes with the message ID and the receive count of the received message
+func (c *Driver) receiveSpanAttributes(m *types.Message, receiveCount int64) []attribute.KeyValue {
+	return append(c.queueSpanAttributes(),
+		attribute.String(spanMessageID, getordefault(m.MessageId)),
+		attribute.Int64(spanReceiveCount, receiveCount),
+	)
+}
@@ -0,0 +1,81 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracingModes(t *testing.T) {
+	plugin := sdktrace.NewTracerProvider()
+
+	tp, err := newTracerProvider("", plugin)
+	require.NoError(t, err)
+	require.Equal(t, plugin, tp)
+
+	tp, err = newTracerProvider(tracingRequired, plugin)
+	require.NoError(t, err)
+	require.Equal(t, plugin, tp)
+
+	// the tracer plugin is not enabled
+	_, err = newTracerProvider(tracingRequired, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no tracer provider is available")
+
+	tp, err = newTracerProvider(tracingDisabled, plugin)
+	require.NoError(t, err)
+	require.NotEqual(t, plugin, tp)
+
+	_, err = newTracerProvider("always", nil)
+	require.Error(t, err)
+}
+
+func TestNilTracerSafety(t *testing.T) {
+	for _, mode := range []string{tracingAuto, tracingDisabled} {
+		pq := &testQueue{}
+		c := newTestDriver(pq, nil)
+
+		var err error
+		c.tracer, err = newTracerProvider(mode, nil)
+		require.NoError(t, err)
+		c.tracingOff = mode == tracingDisabled
+
+		fc := newFakeClient()
+		fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("body")})
+		c.client = fc
+
+		stop := runListener(c)
+		require.Eventually(t, func() bool {
+			return pq.Len() == 1
+		}, time.Second*5, time.Millisecond*10, mode)
+		stop()
+
+		require.NoError(t, pq.ExtractMin().(*Item).Ack(), mode)
+
+		_, err = c.Stats(context.Background())
+		require.NoError(t, err, mode)
+	}
+}
+
+func TestReceiveSpanAttributes(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+
+	attrs := c.receiveSpanAttributes(&types.Message{MessageId: aws.String("id-1")}, 3)
+	values := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		values[string(kv.Key)] = kv.Value.AsInterface()
+	}
+
+	require.Equal(t, map[string]any{
+		spanMessagingSystem: messagingSystemValue,
+		spanDestinationName: "test",
+		spanQueueURL:        "http://127.0.0.1:9324/000000000000/test",
+		spanMessageID:       "id-1",
+		spanReceiveCount:    int64(3),
+	}, values)
+}
@@ -0,0 +1,38 @@
+package sqsjobs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+)
+
+// BodyTransformer rewrites the decoded message body before the job is built, e.g. unwraps an envelope or injects
+// the tenant context from the message attributes. An error is handled the same way as the decode error: the message
+// is moved to the dead-letter queue (if configured) or left in the queue until the visibility timeout.
+type BodyTransformer func(ctx context.Context, body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error)
+
+// RegisterBodyTransformer adds a transformer, the transformers are applied in the registration order
+func (c *Driver) RegisterBodyTransformer(tr BodyTransformer) {
+	c.decodersMu.Lock()
+	defer c.decodersMu.Unlock()
+
+	c.transformers = append(c.transformers, tr)
+}
+
+// transformBody applies the registered transformers to the decoded body
+func (c *Driver) transformBody(ctx context.Context, body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+	c.decodersMu.RLock()
+	transformers := c.transformers
+	c.decodersMu.RUnlock()
+
+	var err error
+	for _, tr := range transformers {
+		body, err = tr(ctx, body, attrs)
+		if err != nil {
+			return nil, errors.Errorf("failed to transform the message body: %v", err)
+		}
+	}
+
+	return body, nil
+}
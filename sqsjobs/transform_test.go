@@ -0,0 +1,73 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyTransformer(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.bodyFormat = formatJSON
+	c.dlqURL = aws.String("http://127.0.0.1:9324/000000000000/test-dlq")
+
+	// unwrap the envelope and inject the tenant
+	c.RegisterBodyTransformer(func(_ context.Context, body []byte, attrs map[string]types.MessageAttributeValue) ([]byte, error) {
+		var env struct {
+			Payload map[string]any `json:"payload"`
+		}
+		err := json.Unmarshal(body, &env)
+		if err != nil {
+			return nil, err
+		}
+		if env.Payload == nil {
+			return nil, stderr.New("no payload in the envelope")
+		}
+		env.Payload["tenant"] = aws.ToString(attrs["Tenant"].StringValue)
+		return json.Marshal(env.Payload)
+	})
+
+	tenant := map[string]types.MessageAttributeValue{"Tenant": {DataType: aws.String(StringType), StringValue: aws.String("acme")}}
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String(`{"payload":{"id":1}}`), MessageAttributes: tenant},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String(`{"meta":{}}`), MessageAttributes: tenant},
+	)
+	c.client = fc
+
+	stop := runListener(c)
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1 && fc.called("DeleteMessage") == 1
+	}, time.Second*5, time.Millisecond*10)
+	stop()
+
+	require.Equal(t, `{"id":1,"tenant":"acme"}`, string(pq.ExtractMin().Body()))
+
+	// transformer error, moved to the dead-letter queue
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	require.Len(t, fc.sent, 1)
+	require.Equal(t, c.dlqURL, fc.sent[0].QueueUrl)
+	require.Equal(t, "receipt-2", aws.ToString(fc.deleted[0].ReceiptHandle))
+}
+
+func TestBodyTransformerOrder(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.RegisterBodyTransformer(func(_ context.Context, body []byte, _ map[string]types.MessageAttributeValue) ([]byte, error) {
+		return append(body, 'a'), nil
+	})
+	c.RegisterBodyTransformer(func(_ context.Context, body []byte, _ map[string]types.MessageAttributeValue) ([]byte, error) {
+		return append(body, 'b'), nil
+	})
+
+	item, err := c.unpack(context.Background(), &types.Message{Body: aws.String("body"), MessageId: aws.String("1")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("bodyab"), item.Payload)
+}
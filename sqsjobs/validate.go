@@ -0,0 +1,147 @@
+package sqsjobs
+
+import (
+	"context"
+	stderr "errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// invalid body policies
+const (
+	// invalidBodyDeadLetter moves the message to the dead-letter queue if configured, otherwise leaves it in the queue (default)
+	invalidBodyDeadLetter string = "dead_letter"
+	// invalidBodyDelete deletes the message
+	invalidBodyDelete string = "delete"
+	// invalidBodyQueue moves the message to the invalid_body_queue
+	invalidBodyQueue string = "queue"
+)
+
+// BodyValidator is the validation stage of the received (decrypted, decoded and transformed) bodies, e.g. a JSON schema,
+// a protobuf or a custom validation. The invalid messages are never dispatched, they are handled by the invalid_body_policy.
+type BodyValidator func(ctx context.Context, body []byte, attrs map[string]types.MessageAttributeValue) error
+
+// RegisterBodyValidator enables the validation stage, nil disables it
+func (c *Driver) RegisterBodyValidator(v BodyValidator) {
+	if v == nil {
+		c.validator.Store(nil)
+		return
+	}
+
+	c.validator.Store(&v)
+}
+
+// invalidBodyError is the failed BodyValidator validation
+type invalidBodyError struct {
+	err error
+}
+
+func (e *invalidBodyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidBodyError) Unwrap() error {
+	return e.err
+}
+
+// isInvalidBody checks whether the unpack failed on the body validation
+func isInvalidBody(err error) bool {
+	var ib *invalidBodyError
+	return stderr.As(err, &ib)
+}
+
+// invalidBody is the invalid_body_policy configuration
+type invalidBody struct {
+	policy string
+	// the invalid_body_queue name and URL (if configured explicitly), the name is resolved on setup
+	queue *string
+	url   *string
+}
+
+// newInvalidBody validates the invalid_body_policy and the invalid_body_queue (the name or the URL)
+func newInvalidBody(policy, queue, prefix string) (invalidBody, error) {
+	switch policy {
+	case "":
+		policy = invalidBodyDeadLetter
+	case invalidBodyDeadLetter, invalidBodyDelete, invalidBodyQueue:
+	default:
+		return invalidBody{}, errors.Errorf("unknown invalid_body_policy: %s, supported: dead_letter, delete, queue", policy)
+	}
+
+	if (policy == invalidBodyQueue) != (queue != "") {
+		return invalidBody{}, errors.Str("invalid_body_queue should be set with the invalid_body_policy: queue only")
+	}
+
+	ib := invalidBody{policy: policy}
+	if queue == "" {
+		return ib, nil
+	}
+
+	var err error
+	ib.queue, ib.url, err = queueTarget(prefix, queue)
+	if err != nil {
+		return invalidBody{}, errors.Errorf("invalid_body_queue: %v", err)
+	}
+
+	return ib, nil
+}
+
+// validateBody runs the registered BodyValidator
+func (c *Driver) validateBody(ctx context.Context, body []byte, attrs map[string]types.MessageAttributeValue) error {
+	v := c.validator.Load()
+	if v == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	err := (*v)(ctx, body, attrs)
+	if err != nil {
+		return &invalidBodyError{err: errors.Errorf("body validation failed: %v", err)}
+	}
+
+	return nil
+}
+
+// rejectInvalid handles the message with the invalid body by the invalid_body_policy
+func (c *Driver) rejectInvalid(ctx context.Context, m *types.Message, reason error) {
+	atomic.AddUint64(&c.invalidBodies, 1)
+	log := c.messageLog(m)
+
+	var err error
+	switch c.invalidBody.policy {
+	case invalidBodyDelete:
+		client, queueURL, _ := c.sourceOf(ctx)
+		ctxT, cancel := context.WithTimeout(context.Background(), time.Minute)
+		_, err = client.DeleteMessage(ctxT, &sqs.DeleteMessageInput{QueueUrl: queueURL, ReceiptHandle: m.ReceiptHandle})
+		cancel()
+		if err == nil {
+			log.Warn("message with the invalid body was deleted", zap.Stringp("ID", m.MessageId), zap.Error(reason))
+		}
+	case invalidBodyQueue:
+		err = c.moveTo(ctx, m, c.invalidBody.url, reason)
+		if err == nil {
+			log.Warn("message with the invalid body was moved to the invalid_body_queue", zap.Stringp("ID", m.MessageId), zap.Error(reason))
+		}
+	default:
+		// left in the queue, it will be visible again after the visibility timeout (and redriven by the queue policy)
+		if c.dlqURL != nil {
+			err = c.moveToDLQ(ctx, m, reason)
+		}
+	}
+
+	if err != nil {
+		log.Error("failed to reject the message with the invalid body", zap.Stringp("ID", m.MessageId), zap.String("invalid_body_policy", c.invalidBody.policy), zap.Error(err))
+	}
+}
+
+// InvalidBodies returns the number of the messages rejected by the BodyValidator since the pipeline start
+func (c *Driver) InvalidBodies() uint64 {
+	return atomic.LoadUint64(&c.invalidBodies)
+}
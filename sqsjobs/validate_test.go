@@ -0,0 +1,69 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/roadrunner-server/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyValidatorPolicy(t *testing.T) {
+	for policy, queue := range map[string]string{
+		invalidBodyDelete: "",
+		invalidBodyQueue:  "http://127.0.0.1:9324/000000000000/test-invalid",
+	} {
+		t.Run(policy, func(t *testing.T) {
+			pq := &testQueue{}
+			c := newTestDriver(pq, nil)
+			ib, err := newInvalidBody(policy, queue, "")
+			require.NoError(t, err)
+			c.invalidBody = ib
+			c.RegisterBodyValidator(func(_ context.Context, body []byte, _ map[string]types.MessageAttributeValue) error {
+				if string(body) != "valid" {
+					return errors.Str("unexpected body")
+				}
+				return nil
+			})
+
+			fc := newFakeClient()
+			fc.receiveFn = receiveOnce(
+				types.Message{MessageId: aws.String("valid"), ReceiptHandle: aws.String("receipt-valid"), Body: aws.String("valid")},
+				types.Message{MessageId: aws.String("invalid"), ReceiptHandle: aws.String("receipt-invalid"), Body: aws.String("invalid")},
+			)
+			c.client = fc
+
+			stop := runListener(c)
+			require.Eventually(t, func() bool {
+				return pq.Len() == 1 && fc.called("DeleteMessage") == 1
+			}, time.Second*5, time.Millisecond*10)
+			stop()
+
+			require.Equal(t, uint64(1), c.InvalidBodies())
+			fc.mu.Lock()
+			defer fc.mu.Unlock()
+			require.Equal(t, "receipt-invalid", aws.ToString(fc.deleted[0].ReceiptHandle))
+			if policy == invalidBodyQueue {
+				require.Len(t, fc.sent, 1)
+				require.Equal(t, queue, aws.ToString(fc.sent[0].QueueUrl))
+				require.Equal(t, "invalid", aws.ToString(fc.sent[0].MessageBody))
+			} else {
+				require.Empty(t, fc.sent)
+			}
+		})
+	}
+
+	_, err := newInvalidBody("drop", "", "")
+	require.Error(t, err)
+	_, err = newInvalidBody(invalidBodyQueue, "", "")
+	require.Error(t, err)
+	_, err = newInvalidBody(invalidBodyDelete, "errors", "")
+	require.Error(t, err)
+	ib, err := newInvalidBody(invalidBodyQueue, "errors", "prefix-")
+	require.NoError(t, err)
+	require.Equal(t, "prefix-errors", aws.ToString(ib.queue))
+	require.Nil(t, ib.url)
+}
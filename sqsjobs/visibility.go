@@ -0,0 +1,68 @@
+package sqsjobs
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultVisibilityTimeout is the SQS visibility timeout (in seconds) of the queues created without the VisibilityTimeout attribute
+	defaultVisibilityTimeout int32 = 30
+	// maxVisibilityTimeout is the SQS visibility timeout limit (in seconds), 12 hours
+	maxVisibilityTimeout int32 = 43200
+	// defaultVisibilityMargin is added to the worker timeout (in seconds) when the margin is not configured
+	defaultVisibilityMargin int = 10
+)
+
+// effectiveVisibility returns the visibility timeout applied to the received messages: the receive visibility_timeout
+// if set, the declared VisibilityTimeout queue attribute otherwise. The SQS default is assumed for the not declared queues.
+func (c *Driver) effectiveVisibility() int32 {
+	if v := atomic.LoadInt32(&c.visibilityTimeout); v > 0 {
+		return v
+	}
+
+	if v, err := strconv.ParseInt(c.attributes[VisibilityTimeoutAWS], 10, 32); err == nil && v > 0 {
+		return int32(v)
+	}
+
+	return defaultVisibilityTimeout
+}
+
+// checkVisibility compares the effective visibility timeout with the worker timeout plus the margin (in seconds).
+// The shorter visibility means the message might be redelivered to another worker while the first one still processes it.
+// It's a warning by default, with autoAdjust the receive visibility timeout is raised to the worker timeout plus the margin.
+// Returns true if the visibility timeout was raised. Zero worker timeout disables the check.
+func (c *Driver) checkVisibility(workerTimeout, margin int, autoAdjust bool) bool {
+	if workerTimeout <= 0 {
+		return false
+	}
+
+	if margin <= 0 {
+		margin = defaultVisibilityMargin
+	}
+
+	visibility := c.effectiveVisibility()
+	required := int64(workerTimeout) + int64(margin)
+	if int64(visibility) >= required {
+		return false
+	}
+
+	if !autoAdjust {
+		c.log.Warn("visibility timeout is shorter than the worker timeout plus the margin, the messages might be redelivered while still processed; raise the visibility_timeout or set auto_adjust_visibility",
+			zap.Int32("visibility_timeout", visibility), zap.Int("worker_timeout", workerTimeout), zap.Int("visibility_margin", margin))
+		return false
+	}
+
+	if required > int64(maxVisibilityTimeout) {
+		c.log.Warn("worker timeout plus the margin exceeds the SQS visibility timeout limit, raising the visibility timeout to the limit",
+			zap.Int("worker_timeout", workerTimeout), zap.Int("visibility_margin", margin), zap.Int32("limit", maxVisibilityTimeout))
+		required = int64(maxVisibilityTimeout)
+	}
+
+	atomic.StoreInt32(&c.visibilityTimeout, int32(required))
+	c.log.Info("visibility timeout was raised to the worker timeout plus the margin", zap.Int32("from", visibility), zap.Int64("to", required))
+
+	return true
+}
@@ -0,0 +1,50 @@
+package sqsjobs
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestVisibilityShorterThanWorkerTimeout(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	c := newTestDriver(&testQueue{}, nil)
+	c.log = zap.New(core)
+	c.visibilityTimeout = 30
+
+	// 30 >= 20 + 10
+	require.False(t, c.checkVisibility(20, 0, false))
+	require.Equal(t, 0, logs.Len())
+
+	// warning only
+	require.False(t, c.checkVisibility(60, 10, false))
+	require.Equal(t, 1, logs.FilterMessageSnippet("visibility timeout is shorter").Len())
+	require.Equal(t, int32(30), atomic.LoadInt32(&c.visibilityTimeout))
+
+	// auto-adjust
+	require.True(t, c.checkVisibility(60, 10, true))
+	require.Equal(t, int32(70), atomic.LoadInt32(&c.visibilityTimeout))
+	require.False(t, c.checkVisibility(60, 10, true))
+
+	// capped by the SQS limit
+	require.True(t, c.checkVisibility(int(maxVisibilityTimeout), 10, true))
+	require.Equal(t, maxVisibilityTimeout, atomic.LoadInt32(&c.visibilityTimeout))
+}
+
+func TestEffectiveVisibility(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	require.Equal(t, defaultVisibilityTimeout, c.effectiveVisibility())
+
+	c.attributes = map[string]string{VisibilityTimeoutAWS: "120"}
+	require.Equal(t, int32(120), c.effectiveVisibility())
+	// the queue attribute is not enough, the receive visibility timeout is raised
+	require.True(t, c.checkVisibility(120, 10, true))
+	require.Equal(t, int32(130), c.effectiveVisibility())
+
+	c.visibilityTimeout = 5
+	require.Equal(t, int32(5), c.effectiveVisibility())
+}
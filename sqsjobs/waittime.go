@@ -0,0 +1,58 @@
+package sqsjobs
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// maxWaitTimeSeconds is the SQS maximum of the long polling wait, both for the queue attribute and the per-call value
+const maxWaitTimeSeconds int32 = 20
+
+// receiveWaitTime validates the per-call wait_time_seconds. Negative - 0, the short polling unless the queue
+// ReceiveMessageWaitTimeSeconds attribute is set (0 in the config is replaced with the default).
+func receiveWaitTime(wait int32) (int32, error) {
+	if wait > maxWaitTimeSeconds {
+		return 0, errors.Errorf("wait_time_seconds should be between 0 and %d, provided: %d", maxWaitTimeSeconds, wait)
+	}
+
+	return max(wait, 0), nil
+}
+
+// queueWaitTime sets the ReceiveMessageWaitTimeSeconds queue attribute to the wait time (set_queue_wait_time)
+func queueWaitTime(attrs map[string]string, enabled bool, wait int32) error {
+	if !enabled {
+		return nil
+	}
+
+	if wait < 0 || wait > maxWaitTimeSeconds {
+		return errors.Errorf("set_queue_wait_time: wait_time_seconds should be between 0 and %d, provided: %d", maxWaitTimeSeconds, wait)
+	}
+
+	value := strconv.Itoa(int(wait))
+	if v, ok := attrs[ReceiveMessageWaitTimeSecondsAWS]; ok && v != value {
+		return errors.Errorf("set_queue_wait_time conflicts with the %s attribute: %s, wait_time_seconds: %d", ReceiveMessageWaitTimeSecondsAWS, v, wait)
+	}
+
+	attrs[ReceiveMessageWaitTimeSecondsAWS] = value
+	return nil
+}
+
+// logWaitTime explains which wait time applies to the receives of this consumer: the per-call WaitTimeSeconds overrides
+// the queue ReceiveMessageWaitTimeSeconds attribute, but 0 is not sent (the SDK omits the zero value), so the queue attribute applies
+func (c *Driver) logWaitTime() {
+	queueWait, ok := c.attributes[ReceiveMessageWaitTimeSecondsAWS]
+	if !ok {
+		return
+	}
+
+	wait := atomic.LoadInt32(&c.waitTime)
+	if wait == 0 {
+		c.log.Debug("wait_time_seconds is not set, the queue ReceiveMessageWaitTimeSeconds attribute applies", zap.String("queue_wait_time_seconds", queueWait))
+		return
+	}
+
+	c.log.Debug("the per-call wait_time_seconds overrides the queue ReceiveMessageWaitTimeSeconds attribute for this consumer", zap.Int32("wait_time_seconds", wait), zap.String("queue_wait_time_seconds", queueWait))
+}
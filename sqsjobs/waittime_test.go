@@ -0,0 +1,50 @@
+package sqsjobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetQueueWaitTime(t *testing.T) {
+	c := newTestDriver(&testQueue{}, nil)
+	c.queueURL = nil
+	c.attributes = map[string]string{}
+	c.waitTime = 10
+	require.NoError(t, queueWaitTime(c.attributes, true, 20))
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce()
+	c.client = fc
+
+	// the queue default is set on the declaration
+	require.NoError(t, manageQueue(context.Background(), c))
+	fc.mu.Lock()
+	require.Len(t, fc.created, 1)
+	require.Equal(t, "20", fc.created[0].Attributes[ReceiveMessageWaitTimeSecondsAWS])
+	fc.mu.Unlock()
+
+	// the per-call wait time overrides it
+	stop := runListener(c)
+	require.Eventually(t, func() bool { return fc.called("ReceiveMessage") > 0 }, time.Second*5, time.Millisecond*10)
+	stop()
+	fc.mu.Lock()
+	require.Equal(t, int32(10), fc.received[0].WaitTimeSeconds)
+	fc.mu.Unlock()
+}
+
+func TestQueueWaitTimeValidation(t *testing.T) {
+	attrs := map[string]string{}
+	require.NoError(t, queueWaitTime(attrs, false, 30))
+	require.Empty(t, attrs)
+
+	require.Error(t, queueWaitTime(attrs, true, 21))
+	require.Error(t, queueWaitTime(attrs, true, -1))
+
+	// the explicit attribute should match
+	attrs[ReceiveMessageWaitTimeSecondsAWS] = "5"
+	require.Error(t, queueWaitTime(attrs, true, 10))
+	require.NoError(t, queueWaitTime(attrs, true, 5))
+}
@@ -0,0 +1,110 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// warmPoolBackoff is the time to wait before the next poll when the warm pool is at the target depth
+const warmPoolBackoff = time.Millisecond * 100
+
+// warmMessage is the received message waiting in the warm pool
+type warmMessage struct {
+	msg      *types.Message
+	received time.Time
+}
+
+// newWarmPool creates the warm pool buffer, nil if disabled (size <= 0)
+func newWarmPool(size int) chan warmMessage {
+	if size <= 0 {
+		return nil
+	}
+
+	return make(chan warmMessage, size)
+}
+
+// receiveBatch returns the number of messages to request: the max_messages_per_poll, up to the warm pool deficit if enabled,
+// 0 - the pool is full
+func (c *Driver) receiveBatch() int32 {
+	if c.warmPool == nil {
+		return c.poll.batch()
+	}
+
+	return min(c.poll.batch(), int32(cap(c.warmPool)-len(c.warmPool))) //nolint:gosec
+}
+
+// fillWarmPool puts the received messages into the warm pool, returns true if the listener was stopped.
+// The messages not put into the pool will be visible again after the visibility timeout.
+func (c *Driver) fillWarmPool(ctx context.Context, msgs []types.Message) bool {
+	now := time.Now()
+	for i := 0; i < len(msgs); i++ {
+		select {
+		case <-ctx.Done():
+			return true
+		case c.warmPool <- warmMessage{msg: &msgs[i], received: now}:
+		}
+	}
+
+	return false
+}
+
+// startWarmPool starts the consumer of the warm pool, the buffered messages are dispatched as soon as the prefetch
+// limit allows, without waiting for the ReceiveMessage. The not dispatched messages are returned to the queue
+// once the pipeline is stopped or paused.
+func (c *Driver) startWarmPool(ctx context.Context) {
+	if c.warmPool == nil {
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				c.requeueWarmPool()
+				return
+			case wm := <-c.warmPool:
+				// the receipt handle is not valid anymore, the message was already redelivered
+				if vt := atomic.LoadInt32(&c.visibilityTimeout); vt > 0 && time.Since(wm.received) >= time.Duration(vt)*time.Second {
+					c.log.Debug("visibility timeout of the buffered message expired, skipping", zap.Stringp("ID", wm.msg.MessageId))
+					continue
+				}
+
+				if c.handleMessage(ctx, wm.msg) {
+					c.requeueWarmPool()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// requeueWarmPool makes the buffered messages visible again right away
+func (c *Driver) requeueWarmPool() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var requeued int
+	for {
+		select {
+		case wm := <-c.warmPool:
+			_, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          c.queueURL,
+				ReceiptHandle:     wm.msg.ReceiptHandle,
+				VisibilityTimeout: 0,
+			})
+			if err != nil && !isNotInflight(err) {
+				c.log.Warn("failed to return the buffered message to the queue", zap.Stringp("ID", wm.msg.MessageId), zap.Error(err))
+				continue
+			}
+			requeued++
+		default:
+			c.log.Debug("warm pool messages were returned to the queue", zap.Int("requeued", requeued))
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package sqsjobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmPoolRefill(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	*c.msgInFlightLimit = 1
+	c.bytesInFlight = ptr(int64(0))
+	c.warmPool = newWarmPool(5)
+
+	var mu sync.Mutex
+	var received int
+	fc := newFakeClient()
+	fc.receiveFn = func(_ context.Context, in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		out := &sqs.ReceiveMessageOutput{}
+		for i := int32(0); i < in.MaxNumberOfMessages; i++ {
+			received++
+			id := strconv.Itoa(received)
+			out.Messages = append(out.Messages, types.Message{MessageId: aws.String(id), ReceiptHandle: aws.String("receipt-" + id), Body: aws.String("body")})
+		}
+		return out, nil
+	}
+	c.client = fc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.listen(ctx)
+	c.startWarmPool(ctx)
+
+	// one message is dispatched (prefetch 1), the next one waits for the prefetch limit, 5 are buffered
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1 && len(c.warmPool) == 5
+	}, time.Second*5, time.Millisecond*10)
+
+	fc.mu.Lock()
+	for _, in := range fc.received {
+		// never more than the pool deficit
+		require.LessOrEqual(t, in.MaxNumberOfMessages, int32(5))
+	}
+	fc.mu.Unlock()
+
+	// the worker takes the next message from the pool, the pool is refilled toward the target depth
+	require.NoError(t, pq.ExtractMin().(*Item).Ack())
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return pq.Len() == 1 && len(c.warmPool) == 5 && received == 8
+	}, time.Second*5, time.Millisecond*10)
+
+	// the buffered messages are returned to the queue on stop
+	cancel()
+	c.cond.Broadcast()
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") == 5
+	}, time.Second*5, time.Millisecond*10)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for _, in := range fc.visibility {
+		require.Equal(t, int32(0), in.VisibilityTimeout)
+	}
+	require.Empty(t, c.warmPool)
+}
@@ -0,0 +1,131 @@
+package sqsjobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/roadrunner-server/errors"
+	"go.uber.org/zap"
+)
+
+// maxHandlerBackoff is the maximum visibility timeout (in seconds) of the message returned to the queue on the handler_timeout
+const maxHandlerBackoff int32 = 900
+
+var errHandlerTimeout = errors.Str("handler_timeout exceeded, the message was already returned to the queue")
+
+// handlerWatchdog nacks the message which is not acknowledged within the handler_timeout, so a stuck worker
+// doesn't hold the message (and the prefetch slot) forever. The first of the ack/nack/requeue and the timeout wins.
+type handlerWatchdog struct {
+	once    sync.Once
+	done    uint32
+	timeout time.Duration
+	expire  func()
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// watchHandler returns the watchdog of the received message, nil if the handler_timeout is disabled
+// or the message is already deleted (auto_ack)
+func (c *Driver) watchHandler(item *Item) *handlerWatchdog {
+	if c.handlerTimeout <= 0 || item.Options.AutoAck {
+		return nil
+	}
+
+	return &handlerWatchdog{
+		timeout: c.handlerTimeout,
+		expire: func() {
+			c.handlerExpired(item)
+		},
+	}
+}
+
+// start starts the timer once the message is taken by a worker, the time spent in the priority queue
+// (or parked by the group ordering) is not counted
+func (w *handlerWatchdog) start() {
+	if w == nil {
+		return
+	}
+
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.timer = time.AfterFunc(w.timeout, w.expire)
+		w.mu.Unlock()
+	})
+}
+
+// claim marks the message as handled and stops the timer, false if the message was already handled
+func (w *handlerWatchdog) claim() bool {
+	if w == nil {
+		return true
+	}
+
+	if !atomic.CompareAndSwapUint32(&w.done, 0, 1) {
+		return false
+	}
+
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+
+	return true
+}
+
+// handlerExpired returns the message to the queue with the backoff by the receive count, the late ack/nack of the worker is refused
+func (c *Driver) handlerExpired(item *Item) {
+	if !item.Options.watchdog.claim() {
+		return
+	}
+	item.Options.heartbeat.stop()
+
+	defer func() {
+		item.Options.releaseBytes()
+		item.Options.cond.Signal()
+		atomic.AddInt64(item.Options.msgInFlight, ^int64(0))
+		if item.Options.release != nil {
+			item.Options.release()
+		}
+	}()
+
+	backoff := handlerBackoff(item.Options.approxReceiveCount)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	client, queue := c.itemQueue(item)
+	_, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queue,
+		ReceiptHandle:     item.Options.receipt.get(),
+		VisibilityTimeout: backoff,
+	})
+	if err != nil {
+		if isNotInflight(err) {
+			item.Options.receipt.done()
+			c.log.Debug("handler_timeout exceeded, the message is not in flight anymore (deleted or redelivered)", zap.String("ID", item.ID()))
+			return
+		}
+
+		c.log.Error("handler_timeout exceeded, failed to return the message to the queue", zap.String("ID", item.ID()), zap.Error(err))
+		return
+	}
+
+	item.Options.receipt.done()
+	c.log.Warn("handler_timeout exceeded, message was returned to the queue", zap.String("ID", item.ID()), zap.Duration("handler_timeout", c.handlerTimeout), zap.Int32("visibility_timeout", backoff))
+}
+
+// handlerBackoff returns the visibility timeout (in seconds) of the timed out message: 2^(receive count - 1), up to 15 minutes
+func handlerBackoff(receiveCount int64) int32 {
+	if receiveCount <= 1 {
+		return 1
+	}
+
+	if receiveCount > 10 {
+		return maxHandlerBackoff
+	}
+
+	return min(int32(1)<<(receiveCount-1), maxHandlerBackoff)
+}
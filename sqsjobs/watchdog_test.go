@@ -0,0 +1,119 @@
+package sqsjobs
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerTimeoutNack(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.handlerTimeout = time.Millisecond * 100
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(
+		types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("stuck"), Attributes: map[string]string{ApproximateReceiveCount: "3"}},
+		types.Message{MessageId: aws.String("2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("acked")},
+	)
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return pq.Len() == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	// waiting in the priority queue, the handler_timeout is not started
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, 0, fc.called("ChangeMessageVisibility"))
+
+	// taken by the workers
+	stuck := pq.ExtractMin().(*Item)
+	acked := pq.ExtractMin().(*Item)
+	for _, item := range []*Item{stuck, acked} {
+		_, err := item.Context()
+		require.NoError(t, err)
+	}
+	require.NoError(t, acked.Ack())
+
+	// not acknowledged in time, returned to the queue with the backoff
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, int64(0), atomic.LoadInt64(c.msgInFlight))
+
+	fc.mu.Lock()
+	require.Equal(t, "receipt-1", aws.ToString(fc.visibility[0].ReceiptHandle))
+	require.Equal(t, int32(4), fc.visibility[0].VisibilityTimeout)
+	fc.mu.Unlock()
+
+	// the late ack is refused
+	require.ErrorIs(t, stuck.Ack(), errHandlerTimeout)
+	require.Equal(t, 1, fc.called("DeleteMessage"))
+	require.Equal(t, int64(0), atomic.LoadInt64(c.msgInFlight))
+
+	// acked in time, the timer never fires
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+}
+
+func TestHandlerBackoff(t *testing.T) {
+	require.Equal(t, int32(1), handlerBackoff(0))
+	require.Equal(t, int32(1), handlerBackoff(1))
+	require.Equal(t, int32(2), handlerBackoff(2))
+	require.Equal(t, int32(512), handlerBackoff(10))
+	require.Equal(t, maxHandlerBackoff, handlerBackoff(11))
+	require.Equal(t, maxHandlerBackoff, handlerBackoff(1000))
+}
+
+func TestHandlerTimeoutNotInflight(t *testing.T) {
+	pq := &testQueue{}
+	c := newTestDriver(pq, nil)
+	c.handlerTimeout = time.Millisecond * 50
+	c.receipts = newReceiptTracker()
+
+	fc := newFakeClient()
+	fc.receiveFn = receiveOnce(types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String("stuck")})
+	// the message was already deleted or redelivered
+	fc.visibilityFn = func(*sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+		return nil, &types.MessageNotInflight{}
+	}
+	c.client = fc
+
+	stop := runListener(c)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return pq.Len() == 1
+	}, time.Second*5, time.Millisecond*10)
+	stuck := pq.ExtractMin().(*Item)
+	_, err := stuck.Context()
+	require.NoError(t, err)
+
+	// a single attempt, the message is not tracked anymore
+	require.Eventually(t, func() bool {
+		return fc.called("ChangeMessageVisibility") == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool {
+		c.receipts.mu.Lock()
+		defer c.receipts.mu.Unlock()
+		return len(c.receipts.inFlight) == 0
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, int64(0), atomic.LoadInt64(c.msgInFlight))
+
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, 1, fc.called("ChangeMessageVisibility"))
+	require.ErrorIs(t, stuck.Ack(), errHandlerTimeout)
+
+	require.True(t, isNotInflight(&smithy.GenericAPIError{Code: "MessageNotInflight"}))
+	require.True(t, isNotInflight(&smithy.GenericAPIError{Code: MessageNotInflight}))
+	// the expired receipt handle is another error
+	require.False(t, isNotInflight(&types.ReceiptHandleIsInvalid{}))
+	require.False(t, isExpiredHandle(&types.MessageNotInflight{}))
+}